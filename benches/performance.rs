@@ -1,6 +1,9 @@
+use roberto_mcp::mcp::encoding::ResponseEncoding;
+use roberto_mcp::mcp::{FindSymbolsResponse, SymbolMatch};
 use roberto_mcp::models::{Language, Location, Symbol, SymbolId, SymbolType, Visibility};
 use roberto_mcp::{SymbolIndexer, SymbolStore};
 use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion};
+use std::collections::BTreeMap;
 use std::path::PathBuf;
 use std::sync::Arc;
 use std::time::Instant;
@@ -18,6 +21,10 @@ fn create_test_symbol(name: &str, file: &str, line: u32) -> Symbol {
         namespace: None,
         visibility: Visibility::Public,
         source: None,
+        signature: None,
+        tags: BTreeMap::new(),
+        doc: None,
+        alias_of: None,
     }
 }
 
@@ -229,6 +236,50 @@ fn bench_concurrent_access(c: &mut Criterion) {
     });
 }
 
+fn bench_response_encoding(c: &mut Criterion) {
+    // A large find_symbols result: 1,000 documented matches
+    let response = FindSymbolsResponse {
+        symbols: (0..1_000)
+            .map(|i| {
+                let mut symbol = create_test_symbol(
+                    &format!("HandleUserRequest{}", i),
+                    "/home/dev/project/internal/service/user_service.go",
+                    i + 1,
+                );
+                symbol.namespace = Some("service".to_string());
+                symbol.doc = Some(format!(
+                    "HandleUserRequest{} validates the request and loads the user it names",
+                    i
+                ));
+                SymbolMatch {
+                    symbol,
+                    match_ranges: vec![(0, 10)],
+                    aliases: Vec::new(),
+                    synonym: None,
+                }
+            })
+            .collect(),
+    };
+
+    let mut group = c.benchmark_group("response_encoding");
+    for (name, encoding) in [
+        ("json", ResponseEncoding::Json),
+        ("msgpack", ResponseEncoding::MessagePack),
+    ] {
+        let size = encoding.to_bytes(&response).unwrap().len();
+        let wire_size = encoding.wire_size(&response).unwrap();
+        println!(
+            "find_symbols response, 1000 matches, {}: {} bytes, {} bytes as sent",
+            name, size, wire_size
+        );
+
+        group.bench_function(name, |b| {
+            b.iter(|| black_box(encoding.to_bytes(black_box(&response)).unwrap()))
+        });
+    }
+    group.finish();
+}
+
 criterion_group!(
     benches,
     bench_symbol_lookup,
@@ -236,7 +287,8 @@ criterion_group!(
     bench_prefix_search,
     bench_file_indexing_simulation,
     bench_memory_usage,
-    bench_concurrent_access
+    bench_concurrent_access,
+    bench_response_encoding
 );
 
 criterion_main!(benches);
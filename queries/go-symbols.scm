@@ -22,6 +22,13 @@
 (var_spec
   name: (identifier) @variable.name) @variable.definition
 
+; Closures assigned to variables: var handler = func(w http.ResponseWriter) {...}
+(var_spec
+  name: (identifier) @function.name
+  value: (expression_list
+    .
+    (func_literal))) @function.definition
+
 ; Short variable declarations
 (short_var_declaration
   left: (expression_list
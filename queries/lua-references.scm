@@ -0,0 +1,20 @@
+; Function calls
+(function_call
+  name: (identifier) @reference.name) @reference.usage
+
+; Table function calls: M.foo()
+(function_call
+  name: (dot_index_expression
+    field: (identifier) @reference.name)) @reference.usage
+
+; Method calls: obj:method()
+(function_call
+  name: (method_index_expression
+    method: (identifier) @reference.name)) @reference.usage
+
+; Field access
+(dot_index_expression
+  field: (identifier) @reference.name) @reference.usage
+
+; Variable/identifier references
+(identifier) @reference.name
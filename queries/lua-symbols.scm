@@ -0,0 +1,64 @@
+; Global, local and table functions: function foo(), local function foo(), function M.foo()
+(function_declaration
+  name: (identifier) @function.name) @function.definition
+
+(function_declaration
+  name: (dot_index_expression
+    field: (identifier) @function.name)) @function.definition
+
+; Methods with an implicit self: function Account:deposit(v)
+(function_declaration
+  name: (method_index_expression
+    method: (identifier) @method.name)) @method.definition
+
+; Functions assigned to variables and table fields: M.foo = function() end
+(assignment_statement
+  (variable_list
+    .
+    name: (identifier) @function.name)
+  (expression_list
+    .
+    value: (function_definition))) @function.definition
+
+(assignment_statement
+  (variable_list
+    .
+    name: (dot_index_expression
+      field: (identifier) @function.name))
+  (expression_list
+    .
+    value: (function_definition))) @function.definition
+
+; Functions in table constructors: { foo = function() end }
+(field
+  name: (identifier) @function.name
+  value: (function_definition)) @function.definition
+
+; Tables acting as modules/classes: local M = {}, M.sub = {}
+(assignment_statement
+  (variable_list
+    .
+    name: (identifier) @class.name)
+  (expression_list
+    .
+    value: (table_constructor))) @class.definition
+
+(assignment_statement
+  (variable_list
+    .
+    name: (dot_index_expression
+      field: (identifier) @class.name))
+  (expression_list
+    .
+    value: (table_constructor))) @class.definition
+
+; Imports: local json = require("json")
+(assignment_statement
+  (variable_list
+    .
+    name: (identifier) @import.name)
+  (expression_list
+    .
+    value: (function_call
+      name: (identifier) @_require
+      (#eq? @_require "require")))) @import.definition
@@ -0,0 +1,175 @@
+-- Complex Lua example for testing symbol extraction.
+-- Covers module tables, colon methods, metatable classes, table-field
+-- functions and syntax from both Lua 5.1 and Lua 5.3+.
+
+local json = require("json")
+local log = require("logging")
+
+local M = {}
+
+M.VERSION = "1.0.0"
+M.MAX_CONNECTIONS = 100
+
+-- Configuration table acting as a nested module
+M.config = {}
+
+function M.config.load(path)
+    local file = io.open(path, "r")
+    if not file then
+        return nil, "cannot open " .. path
+    end
+    local content = file:read("*a")
+    file:close()
+    return json.decode(content)
+end
+
+-- Abstract connection interface, implemented through metatables
+local DatabaseConnection = {}
+DatabaseConnection.__index = DatabaseConnection
+
+function DatabaseConnection.new(kind)
+    local self = setmetatable({}, DatabaseConnection)
+    self.kind = kind
+    self.connected = false
+    return self
+end
+
+function DatabaseConnection:connect()
+    error("connect not implemented for " .. self.kind)
+end
+
+function DatabaseConnection:execute_query(query, ...)
+    error("execute_query not implemented for " .. self.kind)
+end
+
+function DatabaseConnection:close()
+    self.connected = false
+end
+
+-- Postgres implementation inheriting from DatabaseConnection
+local PostgresConnection = setmetatable({}, { __index = DatabaseConnection })
+PostgresConnection.__index = PostgresConnection
+
+function PostgresConnection.new(host, port, database)
+    local self = DatabaseConnection.new("postgres")
+    setmetatable(self, PostgresConnection)
+    self.host = host
+    self.port = port or 5432
+    self.database = database
+    return self
+end
+
+function PostgresConnection:connect()
+    log.info("connecting to " .. self.host .. ":" .. self.port)
+    self.connected = true
+    return true
+end
+
+function PostgresConnection:execute_query(query, ...)
+    if not self.connected then
+        return nil, "not connected"
+    end
+    -- Lua 5.1 style varargs
+    local args = { ... }
+    local count = select("#", ...)
+    return { query = query, args = args, rows = count }
+end
+
+-- Lua 5.1: unpack is a global, Lua 5.2+: table.unpack
+local unpack = table.unpack or unpack
+
+local function quote(value)
+    return "'" .. tostring(value):gsub("'", "''") .. "'"
+end
+
+function M.format_query(query, ...)
+    local values = { ... }
+    for i = 1, #values do
+        values[i] = quote(values[i])
+    end
+    return string.format(query, unpack(values))
+end
+
+-- User model
+local User = {}
+User.__index = User
+
+function User.new(username, email)
+    return setmetatable({
+        username = username,
+        email = email,
+        active = true,
+    }, User)
+end
+
+function User:validate_email()
+    return self.email:match("^[%w._-]+@[%w.-]+%.%a+$") ~= nil
+end
+
+function User:to_json()
+    return json.encode({ username = self.username, email = self.email })
+end
+
+-- Service layer with functions assigned as table fields
+local UserService = {
+    find_by_id = function(self, id)
+        return self.db:execute_query("SELECT * FROM users WHERE id = $1", id)
+    end,
+}
+UserService.__index = UserService
+
+function UserService.new(db)
+    return setmetatable({ db = db, cache = {} }, UserService)
+end
+
+UserService.create = function(self, username, email)
+    local user = User.new(username, email)
+    if not user:validate_email() then
+        return nil, "invalid email"
+    end
+    self.cache[username] = user
+    return user
+end
+
+-- Lua 5.3+: integer division and bitwise operators
+function M.page_count(total, page_size)
+    return (total + page_size - 1) // page_size
+end
+
+function M.pack_flags(read, write, admin)
+    local flags = 0
+    if read then flags = flags | 0x1 end
+    if write then flags = flags | (1 << 1) end
+    if admin then flags = flags | (1 << 2) end
+    return flags & 0xFF, ~flags, flags >> 1, flags ~ 0x3
+end
+
+-- Lua 5.2+: goto and labels
+function M.first_active(users)
+    for _, user in ipairs(users) do
+        if not user.active then
+            goto continue
+        end
+        do return user end
+        ::continue::
+    end
+    return nil
+end
+
+-- Lua 5.4: const and to-be-closed variables
+function M.with_connection(db, callback)
+    local retries <const> = 3
+    for attempt = 1, retries do
+        if db:connect() then
+            return callback(db)
+        end
+        log.warn("retrying connection, attempt " .. attempt)
+    end
+end
+
+M.DatabaseConnection = DatabaseConnection
+M.PostgresConnection = PostgresConnection
+M.User = User
+M.UserService = UserService
+
+return M
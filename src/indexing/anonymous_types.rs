@@ -0,0 +1,147 @@
+use crate::indexing::type_members::base_type_name;
+use crate::models::{Location, Symbol, SymbolId, SymbolType, Visibility};
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+use tree_sitter::Node;
+
+/// Go type literals that can appear without a name
+const ANONYMOUS_KINDS: &[&str] = &["struct_type", "interface_type"];
+
+/// Whether `node` is a Go struct or interface literal not bound by a type declaration
+pub fn is_anonymous_type(node: Node) -> bool {
+    ANONYMOUS_KINDS.contains(&node.kind())
+        && node
+            .parent()
+            .map(|parent| !matches!(parent.kind(), "type_spec" | "type_alias"))
+            .unwrap_or(true)
+}
+
+/// The outermost anonymous type inside a field or parameter type, looking
+/// through pointers, slices, maps and channels
+pub fn find_anonymous_type(node: Node) -> Option<Node> {
+    if is_anonymous_type(node) {
+        return Some(node);
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    children.into_iter().find_map(find_anonymous_type)
+}
+
+/// Name an anonymous type after the declarations enclosing it:
+/// `Server.config` for a field, `Load.return` for a result,
+/// `TestParse.cases` for a table-driven test. Types with no named context
+/// fall back to their position, e.g. `struct@12:5`.
+pub fn synthetic_type_name(node: Node, source: &str) -> String {
+    let mut parts = Vec::new();
+    let mut current = node;
+
+    while let Some(parent) = current.parent() {
+        match parent.kind() {
+            "field_declaration"
+            | "parameter_declaration"
+            | "variadic_parameter_declaration"
+            | "var_spec"
+            | "const_spec" => parts.extend(field_text(parent, "name", source)),
+            "short_var_declaration" => {
+                let left = parent.child_by_field_name("left");
+                parts.extend(left.and_then(|left| first_named_text(left, source)));
+            }
+            "function_declaration" | "method_declaration" | "type_spec" => {
+                if parent.child_by_field_name("result") == Some(current) {
+                    parts.push("return".to_string());
+                }
+                parts.extend(field_text(parent, "name", source));
+                if let Some(receiver) = field_text(parent, "receiver", source) {
+                    parts.push(base_type_name(&receiver));
+                }
+                break;
+            }
+            "func_literal" | "function_type" => {
+                if parent.child_by_field_name("result") == Some(current) {
+                    parts.push("return".to_string());
+                }
+            }
+            _ => {}
+        }
+        current = parent;
+    }
+
+    if parts.is_empty() {
+        let position = node.start_position();
+        let kind = node.kind().trim_end_matches("_type");
+        return format!("{}@{}:{}", kind, position.row + 1, position.column + 1);
+    }
+    parts.reverse();
+    parts.join(".")
+}
+
+/// Index every anonymous struct and interface in a Go file under its synthetic name
+pub fn anonymous_type_symbols(
+    root: Node,
+    source: &str,
+    file_path: &PathBuf,
+    namespace: Option<&str>,
+) -> Vec<Symbol> {
+    let mut symbols = Vec::new();
+    collect(root, source, file_path, namespace, &mut symbols);
+    symbols
+}
+
+fn collect(
+    node: Node,
+    source: &str,
+    file_path: &PathBuf,
+    namespace: Option<&str>,
+    symbols: &mut Vec<Symbol>,
+) {
+    if is_anonymous_type(node) {
+        let start = node.start_position();
+        let end = node.end_position();
+        let location = Location::new(
+            file_path.clone(),
+            start.row as u32 + 1,
+            start.column as u32,
+            end.row as u32 + 1,
+            end.column as u32,
+        );
+        symbols.push(Symbol {
+            id: SymbolId::new(file_path, location.start_line, location.start_column),
+            name: synthetic_type_name(node, source),
+            symbol_type: if node.kind() == "interface_type" {
+                SymbolType::Interface
+            } else {
+                SymbolType::Class
+            },
+            location,
+            namespace: namespace.map(String::from),
+            visibility: Visibility::Private,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        });
+    }
+
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    for child in children {
+        collect(child, source, file_path, namespace, symbols);
+    }
+}
+
+fn field_text(node: Node, field: &str, source: &str) -> Option<String> {
+    let child = node.child_by_field_name(field)?;
+    child
+        .utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.trim().to_string())
+}
+
+fn first_named_text(node: Node, source: &str) -> Option<String> {
+    let first = node.named_child(0)?;
+    first
+        .utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.trim().to_string())
+}
@@ -0,0 +1,511 @@
+use crate::indexing::signature_compat::{compare_signatures, receiver_type, Compatibility};
+use crate::models::{Language, Symbol, SymbolType, Visibility};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::{BTreeMap, BTreeSet};
+
+/// Kinds that make up a public API, in report order
+const API_KINDS: &[SymbolType] = &[
+    SymbolType::Interface,
+    SymbolType::Struct,
+    SymbolType::Class,
+    SymbolType::Enum,
+    SymbolType::Function,
+    SymbolType::Method,
+    SymbolType::Constant,
+    SymbolType::Variable,
+];
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ApiChangeKind {
+    Added,
+    Removed,
+    Changed,
+}
+
+/// One public symbol that appeared, disappeared or changed its signature
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ApiChange {
+    pub change: ApiChangeKind,
+    pub symbol_type: SymbolType,
+    /// Name qualified by namespace and, for methods, receiver type
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub old_signature: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub new_signature: Option<String>,
+    /// For `Changed`: whether callers of the old signature still compile
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub compatibility: Option<Compatibility>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub reasons: Vec<String>,
+}
+
+impl ApiChange {
+    /// Removals and changes that break existing callers
+    pub fn breaking(&self) -> bool {
+        match self.change {
+            ApiChangeKind::Removed => true,
+            ApiChangeKind::Changed => self.compatibility == Some(Compatibility::Breaking),
+            ApiChangeKind::Added => false,
+        }
+    }
+}
+
+/// Layout of a rendered report
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ReportFormat {
+    Markdown,
+    Text,
+}
+
+impl ReportFormat {
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_lowercase().as_str() {
+            "markdown" | "md" => Some(ReportFormat::Markdown),
+            "text" | "plain" => Some(ReportFormat::Text),
+            _ => None,
+        }
+    }
+}
+
+/// Compare the public symbols of two versions of a codebase. Symbols are
+/// matched by kind and qualified name, never by file or position, so moved
+/// code is not reported. Overloads sharing a name are matched by signature.
+pub fn diff_public_api<'a>(
+    old: impl IntoIterator<Item = &'a Symbol>,
+    new: impl IntoIterator<Item = &'a Symbol>,
+) -> Vec<ApiChange> {
+    let old = public_api(old);
+    let new = public_api(new);
+    let keys: BTreeSet<&(usize, String)> = old.keys().chain(new.keys()).collect();
+
+    let mut changes = Vec::new();
+    for key in keys {
+        let before = old.get(key).map(Vec::as_slice).unwrap_or_default();
+        let after = new.get(key).map(Vec::as_slice).unwrap_or_default();
+        if let ([old_symbol], [new_symbol]) = (before, after) {
+            if let Some(change) = changed(&key.1, old_symbol, new_symbol) {
+                changes.push(change);
+            }
+            continue;
+        }
+
+        let old_texts: BTreeSet<String> = before.iter().map(|s| display(s, &key.1)).collect();
+        let new_texts: BTreeSet<String> = after.iter().map(|s| display(s, &key.1)).collect();
+        for symbol in after {
+            if !old_texts.contains(&display(symbol, &key.1)) {
+                changes.push(added_or_removed(ApiChangeKind::Added, &key.1, symbol));
+            }
+        }
+        for symbol in before {
+            if !new_texts.contains(&display(symbol, &key.1)) {
+                changes.push(added_or_removed(ApiChangeKind::Removed, &key.1, symbol));
+            }
+        }
+    }
+    changes.sort_by(|a, b| {
+        (a.change, kind_rank(&a.symbol_type), &a.name).cmp(&(
+            b.change,
+            kind_rank(&b.symbol_type),
+            &b.name,
+        ))
+    });
+    changes
+}
+
+/// The public API of some symbols as sorted lines, one per public symbol:
+/// kind, qualified name and the shape of its signature. Parameter names are
+/// left out where the types are known, so renaming a parameter, moving code
+/// or editing a body leaves the lines as they are.
+pub fn api_surface<'a>(symbols: impl IntoIterator<Item = &'a Symbol>) -> Vec<String> {
+    let mut lines: Vec<String> = public_api(symbols)
+        .into_iter()
+        .flat_map(|((_, name), symbols)| {
+            symbols
+                .into_iter()
+                .map(move |symbol| surface_line(symbol, &name))
+        })
+        .collect();
+    lines.sort();
+    lines.dedup();
+    lines
+}
+
+fn surface_line(symbol: &Symbol, name: &str) -> String {
+    let kind = symbol.symbol_type.as_str();
+    let Some(signature) = &symbol.signature else {
+        return match &symbol.alias_of {
+            Some(target) => format!("{} {} = {}", kind, name, target),
+            None => format!("{} {}", kind, name),
+        };
+    };
+    let type_parameters = if signature.type_parameters.is_empty() {
+        String::new()
+    } else {
+        format!("[{}]", signature.type_parameters.join(", "))
+    };
+    let parameters: Vec<String> = signature
+        .parameters
+        .iter()
+        .map(|parameter| {
+            let shape = parameter
+                .type_name
+                .as_deref()
+                .or(parameter.name.as_deref())
+                .unwrap_or("_");
+            let variadic = if parameter.variadic { "..." } else { "" };
+            let optional = if parameter.optional { "?" } else { "" };
+            format!("{}{}{}", variadic, shape, optional)
+        })
+        .collect();
+    let returns = signature
+        .return_type
+        .as_deref()
+        .map(|returns| format!(" {}", returns))
+        .unwrap_or_default();
+    format!(
+        "{} {}{}({}){}",
+        kind,
+        name,
+        type_parameters,
+        parameters.join(", "),
+        returns
+    )
+}
+
+/// A SHA-256 hex digest of an API surface, stable across runs and platforms
+pub fn api_hash(surface: &[String]) -> String {
+    let mut hasher = Sha256::new();
+    for line in surface {
+        hasher.update(line.as_bytes());
+        hasher.update(b"\n");
+    }
+    format!("{:x}", hasher.finalize())
+}
+
+/// Public symbols keyed by kind rank and qualified name
+fn public_api<'a>(
+    symbols: impl IntoIterator<Item = &'a Symbol>,
+) -> BTreeMap<(usize, String), Vec<&'a Symbol>> {
+    let mut api: BTreeMap<(usize, String), Vec<&Symbol>> = BTreeMap::new();
+    for symbol in symbols {
+        if symbol.visibility != Visibility::Public || !API_KINDS.contains(&symbol.symbol_type) {
+            continue;
+        }
+        api.entry((kind_rank(&symbol.symbol_type), qualified_name(symbol)))
+            .or_default()
+            .push(symbol);
+    }
+    api
+}
+
+fn kind_rank(symbol_type: &SymbolType) -> usize {
+    API_KINDS
+        .iter()
+        .position(|kind| kind == symbol_type)
+        .unwrap_or(API_KINDS.len())
+}
+
+fn qualified_name(symbol: &Symbol) -> String {
+    // Typed receivers (`p *Conn`) qualify methods; `self` says nothing
+    let receiver = symbol
+        .signature
+        .as_ref()
+        .and_then(|signature| signature.receiver.as_deref())
+        .filter(|receiver| receiver.contains(' '))
+        .map(|receiver| receiver_type(receiver).trim_start_matches(['*', '&']));
+    [
+        symbol.namespace.as_deref(),
+        receiver,
+        Some(symbol.name.as_str()),
+    ]
+    .into_iter()
+    .flatten()
+    .collect::<Vec<_>>()
+    .join(".")
+}
+
+/// The declaration as written, or the kind and name for symbols without one
+fn display(symbol: &Symbol, name: &str) -> String {
+    match &symbol.signature {
+        Some(signature) => signature.text.clone(),
+        None => format!("{} {}", symbol.symbol_type.as_str(), name),
+    }
+}
+
+fn added_or_removed(change: ApiChangeKind, name: &str, symbol: &Symbol) -> ApiChange {
+    let signature = Some(display(symbol, name));
+    let (old_signature, new_signature) = match change {
+        ApiChangeKind::Removed => (signature, None),
+        _ => (None, signature),
+    };
+    ApiChange {
+        change,
+        symbol_type: symbol.symbol_type.clone(),
+        name: name.to_string(),
+        old_signature,
+        new_signature,
+        compatibility: None,
+        reasons: Vec::new(),
+    }
+}
+
+fn changed(name: &str, old: &Symbol, new: &Symbol) -> Option<ApiChange> {
+    let (Some(old_signature), Some(new_signature)) = (&old.signature, &new.signature) else {
+        return None;
+    };
+    let comparison = compare_signatures(
+        old_signature,
+        new_signature,
+        Language::from_path(&new.location.file),
+    );
+    if comparison.compatibility == Compatibility::Identical {
+        return None;
+    }
+    Some(ApiChange {
+        change: ApiChangeKind::Changed,
+        symbol_type: new.symbol_type.clone(),
+        name: name.to_string(),
+        old_signature: Some(old_signature.text.clone()),
+        new_signature: Some(new_signature.text.clone()),
+        compatibility: Some(comparison.compatibility),
+        reasons: comparison.reasons,
+    })
+}
+
+fn plural(symbol_type: &SymbolType) -> &'static str {
+    match symbol_type {
+        SymbolType::Interface => "Interfaces",
+        SymbolType::Struct => "Structs",
+        SymbolType::Class => "Classes",
+        SymbolType::Enum => "Enums",
+        SymbolType::Function => "Functions",
+        SymbolType::Method => "Methods",
+        SymbolType::Constant => "Constants",
+        SymbolType::Variable => "Variables",
+        SymbolType::Module => "Modules",
+        SymbolType::Import => "Imports",
+        SymbolType::Test => "Tests",
+        SymbolType::Route => "Routes",
+    }
+}
+
+/// One-line count of the changes, e.g. `2 added, 1 removed, 1 changed (2 breaking)`
+pub fn summary(changes: &[ApiChange]) -> String {
+    let count = |kind: ApiChangeKind| changes.iter().filter(|c| c.change == kind).count();
+    let breaking = changes.iter().filter(|c| c.breaking()).count();
+    format!(
+        "{} added, {} removed, {} changed ({} breaking)",
+        count(ApiChangeKind::Added),
+        count(ApiChangeKind::Removed),
+        count(ApiChangeKind::Changed),
+        breaking
+    )
+}
+
+/// Render changes for a changelog, grouped by change category and then by
+/// symbol kind. `changes` must be in the order `diff_public_api` returns.
+pub fn render_report(changes: &[ApiChange], format: ReportFormat, title: &str) -> String {
+    let markdown = format == ReportFormat::Markdown;
+    let mut out = if markdown {
+        format!("## {}\n\n_{}_\n", title, summary(changes))
+    } else {
+        format!("{}: {}\n", title, summary(changes))
+    };
+    if changes.is_empty() {
+        out.push_str("\nNo public API changes.\n");
+        return out;
+    }
+
+    let mut category = None;
+    let mut kind = None;
+    for change in changes {
+        if category != Some(change.change) {
+            category = Some(change.change);
+            kind = None;
+            let heading = match change.change {
+                ApiChangeKind::Added => "Added",
+                ApiChangeKind::Removed => "Removed",
+                ApiChangeKind::Changed => "Changed",
+            };
+            if markdown {
+                out.push_str(&format!("\n### {}\n", heading));
+            } else {
+                out.push_str(&format!("\n{}\n", heading));
+            }
+        }
+        if kind != Some(&change.symbol_type) {
+            kind = Some(&change.symbol_type);
+            if markdown {
+                out.push_str(&format!("\n**{}**\n\n", plural(&change.symbol_type)));
+            } else {
+                out.push_str(&format!("  {}\n", plural(&change.symbol_type)));
+            }
+        }
+        render_change(&mut out, change, markdown);
+    }
+    out
+}
+
+fn render_change(out: &mut String, change: &ApiChange, markdown: bool) {
+    let signature = change
+        .new_signature
+        .as_deref()
+        .or(change.old_signature.as_deref())
+        .unwrap_or_default();
+    match (change.change, markdown) {
+        (ApiChangeKind::Changed, true) => {
+            let label = match change.compatibility {
+                Some(Compatibility::Breaking) => " (breaking)",
+                _ => "",
+            };
+            out.push_str(&format!("- `{}` signature changed{}\n", change.name, label));
+            if let Some(old) = &change.old_signature {
+                out.push_str(&format!("  - Before: `{}`\n", old));
+            }
+            out.push_str(&format!("  - After: `{}`\n", signature));
+            for reason in &change.reasons {
+                out.push_str(&format!("  - {}\n", reason));
+            }
+        }
+        (ApiChangeKind::Changed, false) => {
+            let label = match change.compatibility {
+                Some(Compatibility::Breaking) => " (breaking)",
+                _ => "",
+            };
+            out.push_str(&format!("    {} signature changed{}\n", change.name, label));
+            if let Some(old) = &change.old_signature {
+                out.push_str(&format!("      before: {}\n", old));
+            }
+            out.push_str(&format!("      after:  {}\n", signature));
+            for reason in &change.reasons {
+                out.push_str(&format!("      - {}\n", reason));
+            }
+        }
+        (_, true) => out.push_str(&format!("- `{}` (`{}`)\n", signature, change.name)),
+        (_, false) => out.push_str(&format!("    {} ({})\n", signature, change.name)),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, Parameter, Signature, SymbolId};
+    use std::collections::BTreeMap as Tags;
+    use std::path::PathBuf;
+
+    fn function(name: &str, line: u32, params: &[(&str, &str)], returns: &str) -> Symbol {
+        let file = PathBuf::from("users/users.go");
+        let list: Vec<String> = params.iter().map(|(n, t)| format!("{} {}", n, t)).collect();
+        Symbol {
+            id: SymbolId::new(&file, line, 0),
+            name: name.to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location::new(file, line, 0, line + 2, 1),
+            namespace: Some("users".to_string()),
+            visibility: if name.starts_with(char::is_uppercase) {
+                Visibility::Public
+            } else {
+                Visibility::Private
+            },
+            source: None,
+            signature: Some(Signature {
+                text: format!("func {}({}) {}", name, list.join(", "), returns),
+                receiver: None,
+                type_parameters: Vec::new(),
+                parameters: params
+                    .iter()
+                    .map(|(n, t)| Parameter {
+                        name: Some(n.to_string()),
+                        type_name: Some(t.to_string()),
+                        variadic: false,
+                        optional: false,
+                        default_value: None,
+                        channel: None,
+                    })
+                    .collect(),
+                return_type: Some(returns.to_string()),
+                return_channels: Vec::new(),
+                uses_defer: false,
+                deferred: Vec::new(),
+            }),
+            tags: Tags::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    #[test]
+    fn test_public_api_diff_report() {
+        let old = vec![
+            function("Lookup", 3, &[("id", "int")], "*User"),
+            function("Delete", 10, &[("id", "int")], "error"),
+            function("helper", 20, &[], "int"),
+        ];
+        let new = vec![
+            // Moved further down the file: not a change
+            function("Delete", 30, &[("id", "int")], "error"),
+            function("Lookup", 3, &[("id", "string")], "*User"),
+            function("NewUser", 12, &[("name", "string")], "*User"),
+            function("helper2", 20, &[], "int"),
+        ];
+
+        let changes = diff_public_api(&old, &new);
+        let summary: Vec<(ApiChangeKind, &str)> = changes
+            .iter()
+            .map(|c| (c.change, c.name.as_str()))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                (ApiChangeKind::Added, "users.NewUser"),
+                (ApiChangeKind::Changed, "users.Lookup"),
+            ]
+        );
+        assert_eq!(changes[1].compatibility, Some(Compatibility::Breaking));
+
+        let markdown = render_report(&changes, ReportFormat::Markdown, "Public API changes");
+        assert!(markdown.contains("_1 added, 0 removed, 1 changed (1 breaking)_"));
+        assert!(markdown.contains(
+            "### Added\n\n**Functions**\n\n- `func NewUser(name string) *User` (`users.NewUser`)"
+        ));
+        assert!(markdown.contains("- `users.Lookup` signature changed (breaking)"));
+        assert!(markdown.contains("  - Before: `func Lookup(id int) *User`"));
+
+        let removed = diff_public_api(&new, &old);
+        assert_eq!(removed[0].change, ApiChangeKind::Removed);
+        let text = render_report(&removed, ReportFormat::Text, "Public API changes");
+        assert!(text.contains("Removed\n  Functions\n    func NewUser(name string) *User"));
+
+        assert!(diff_public_api(&old, &old).is_empty());
+    }
+
+    #[test]
+    fn test_api_hash() {
+        let api = vec![
+            function("Lookup", 3, &[("id", "int")], "*User"),
+            function("helper", 20, &[], "int"),
+        ];
+        let surface = api_surface(&api);
+        assert_eq!(surface, vec!["function users.Lookup(int) *User"]);
+        let hash = api_hash(&surface);
+        assert_eq!(hash.len(), 64);
+
+        // Moved, parameter renamed, private helper changed: same API
+        let same = vec![
+            function("helper", 3, &[("n", "int")], "string"),
+            function("Lookup", 40, &[("userID", "int")], "*User"),
+        ];
+        assert_eq!(api_hash(&api_surface(&same)), hash);
+
+        let changed = vec![function("Lookup", 3, &[("id", "string")], "*User")];
+        assert_ne!(api_hash(&api_surface(&changed)), hash);
+        let added = vec![
+            function("Lookup", 3, &[("id", "int")], "*User"),
+            function("NewUser", 12, &[("name", "string")], "*User"),
+        ];
+        assert_ne!(api_hash(&api_surface(&added)), hash);
+    }
+}
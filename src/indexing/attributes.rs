@@ -0,0 +1,382 @@
+use crate::indexing::tags::doc_comment;
+use crate::models::{Language, Symbol};
+use std::collections::{BTreeMap, HashSet};
+use tree_sitter::Node;
+
+/// Prefix of the tags recording a definition's annotations, decorators and
+/// attributes: `@Deprecated`, `@app.route`, `@derive`, `@nolint`
+pub const ATTRIBUTE_TAG_PREFIX: &str = "@";
+
+/// Nodes holding one annotation, decorator or attribute: Java and Kotlin
+/// annotations, Python and TypeScript decorators, C#, PHP and Swift
+/// attributes, Rust attribute items
+const ATTRIBUTE_KINDS: &[&str] = &[
+    "annotation",
+    "marker_annotation",
+    "decorator",
+    "attribute",
+    "attribute_item",
+];
+
+/// Children of a definition that group its attributes
+const CONTAINER_KINDS: &[&str] = &["modifiers", "attribute_list", "attribute_group"];
+
+/// Parents whose attributes belong to the definition inside them
+const WRAPPER_KINDS: &[&str] = &["decorated_definition", "export_statement"];
+
+/// Annotations, decorators and attributes of a definition as tags keyed by
+/// `@` and the name as written, valued with their arguments. Go records its
+/// `//go:`, `//nolint` and other directive comments, and the names its
+/// struct fields give in each struct tag key, comma separated. An attribute
+/// written more than once keeps every value, separated by `; `.
+pub fn attribute_tags(
+    definition: Node,
+    source: &str,
+    language: Language,
+) -> BTreeMap<String, String> {
+    let attributes = match language {
+        Language::Go => go_attributes(definition, source),
+        _ => attribute_nodes(definition)
+            .into_iter()
+            .filter_map(|node| parse_attribute(node.utf8_text(source.as_bytes()).ok()?))
+            .collect(),
+    };
+    let mut tags: BTreeMap<String, String> = BTreeMap::new();
+    for (name, value) in attributes {
+        let key = format!("{}{}", ATTRIBUTE_TAG_PREFIX, name);
+        match tags.get_mut(&key) {
+            Some(existing) if !value.is_empty() => {
+                if !existing.is_empty() {
+                    existing.push_str("; ");
+                }
+                existing.push_str(&value);
+            }
+            Some(_) => {}
+            None => {
+                tags.insert(key, value);
+            }
+        }
+    }
+    tags
+}
+
+/// Whether a symbol carries an attribute matching `filter`: `Deprecated` or
+/// `@Deprecated` for presence, `@stability=experimental` for a value. Names
+/// match case-insensitively, qualified names (`java.lang.Deprecated`,
+/// `app.route`) by their last segment too. A value matches the whole value
+/// or any comma separated item of it, quotes removed, with `*` matching any
+/// run of characters. `@key: value` doc comment tags count as attributes.
+pub fn has_attribute(symbol: &Symbol, filter: &str) -> bool {
+    let filter = filter.trim().trim_start_matches(ATTRIBUTE_TAG_PREFIX);
+    let (name, pattern) = match filter.split_once('=') {
+        Some((name, pattern)) => (name.trim(), Some(pattern.trim())),
+        None => (filter, None),
+    };
+    if name.is_empty() {
+        return false;
+    }
+    symbol.tags.iter().any(|(key, value)| {
+        let key = key.strip_prefix(ATTRIBUTE_TAG_PREFIX).unwrap_or(key);
+        names_match(key, name) && pattern.is_none_or(|pattern| value_matches(value, pattern))
+    })
+}
+
+fn names_match(key: &str, name: &str) -> bool {
+    let last = key.rsplit(['.', ':']).next().unwrap_or(key);
+    key.eq_ignore_ascii_case(name) || last.eq_ignore_ascii_case(name)
+}
+
+fn value_matches(value: &str, pattern: &str) -> bool {
+    let unquote = |text: &str| {
+        text.trim()
+            .trim_matches(|c| matches!(c, '"' | '\'' | '`'))
+            .to_string()
+    };
+    let pattern = unquote(pattern);
+    std::iter::once(value)
+        .chain(value.split([',', ';']))
+        .any(|item| glob_match(&unquote(item), &pattern))
+}
+
+/// `*` matches any run of characters; everything else literally, ignoring
+/// case
+fn glob_match(text: &str, pattern: &str) -> bool {
+    let text = text.to_lowercase();
+    let pattern = pattern.to_lowercase();
+    let parts: Vec<&str> = pattern.split('*').collect();
+    if parts.len() == 1 {
+        return text == pattern;
+    }
+    let (first, last) = (parts[0], parts[parts.len() - 1]);
+    if !text.starts_with(first) || text.len() < first.len() + last.len() {
+        return false;
+    }
+    let mut rest = &text[first.len()..text.len() - last.len()];
+    for part in &parts[1..parts.len() - 1] {
+        match rest.find(part) {
+            Some(index) => rest = &rest[index + part.len()..],
+            None => return false,
+        }
+    }
+    text.ends_with(last)
+}
+
+/// The attribute nodes of a definition: its own and those grouped in its
+/// modifiers or attribute lists, those of a decorating or exporting
+/// wrapper, and the Rust attribute items and TypeScript member decorators
+/// written just before it
+fn attribute_nodes(definition: Node) -> Vec<Node> {
+    let mut nodes = Vec::new();
+    collect_attributes(definition, &mut nodes);
+    if let Some(parent) = definition
+        .parent()
+        .filter(|parent| WRAPPER_KINDS.contains(&parent.kind()))
+    {
+        let mut cursor = parent.walk();
+        for child in parent.children(&mut cursor) {
+            if child.id() == definition.id() {
+                break;
+            }
+            if ATTRIBUTE_KINDS.contains(&child.kind()) {
+                nodes.push(child);
+            }
+        }
+    }
+
+    let mut preceding = Vec::new();
+    let mut sibling = definition.prev_sibling();
+    while let Some(node) = sibling {
+        match node.kind() {
+            "attribute_item" | "decorator" => preceding.push(node),
+            kind if kind.contains("comment") => {}
+            _ => break,
+        }
+        sibling = node.prev_sibling();
+    }
+    preceding.reverse();
+    preceding.extend(nodes);
+    // A Python decorator is both a child of the wrapper and a sibling
+    let mut seen = HashSet::new();
+    preceding.retain(|node| seen.insert(node.id()));
+    preceding
+}
+
+fn collect_attributes<'a>(node: Node<'a>, nodes: &mut Vec<Node<'a>>) {
+    let mut cursor = node.walk();
+    for child in node.children(&mut cursor) {
+        if ATTRIBUTE_KINDS.contains(&child.kind()) {
+            nodes.push(child);
+        } else if CONTAINER_KINDS.contains(&child.kind()) {
+            collect_attributes(child, nodes);
+        }
+    }
+}
+
+/// `@Deprecated(since = "9")` -> (`Deprecated`, `since = "9"`);
+/// `#[derive(Debug, Clone)]` -> (`derive`, `Debug, Clone`);
+/// `#[doc = "x"]` -> (`doc`, `"x"`). Arguments have whitespace collapsed.
+fn parse_attribute(text: &str) -> Option<(String, String)> {
+    let text = text.split_whitespace().collect::<Vec<_>>().join(" ");
+    let text = text
+        .trim_start_matches("#!")
+        .trim_start_matches('#')
+        .trim_start_matches('@')
+        .trim();
+    let text = match text.strip_prefix('[') {
+        Some(inner) => inner.strip_suffix(']').unwrap_or(inner).trim(),
+        None => text,
+    };
+    let name_len = text
+        .find(|c: char| !(c.is_alphanumeric() || matches!(c, '_' | '.' | ':' | '$')))
+        .unwrap_or(text.len());
+    let name = text[..name_len].trim_end_matches([':', '.']);
+    if name.is_empty() {
+        return None;
+    }
+    let rest = text[name_len..].trim();
+    let value = if let Some(arguments) = rest.strip_prefix('(') {
+        match arguments.rfind(')') {
+            Some(end) => &arguments[..end],
+            None => arguments,
+        }
+    } else if let Some(value) = rest.strip_prefix('=') {
+        value
+    } else {
+        ""
+    };
+    Some((name.to_string(), value.trim().to_string()))
+}
+
+/// Directive comments above a Go declaration and, for struct types, the
+/// field names given per struct tag key
+fn go_attributes(definition: Node, source: &str) -> Vec<(String, String)> {
+    let mut attributes: Vec<(String, String)> = doc_comment(definition, source)
+        .map(|comment| comment.lines().filter_map(go_directive).collect())
+        .unwrap_or_default();
+
+    let fields = definition
+        .child_by_field_name("type")
+        .filter(|node| node.kind() == "struct_type")
+        .and_then(|node| node.named_child(0));
+    let Some(fields) = fields else {
+        return attributes;
+    };
+    let mut by_key: Vec<(String, Vec<String>)> = Vec::new();
+    let mut cursor = fields.walk();
+    for field in fields.named_children(&mut cursor) {
+        let Some(tag) = field
+            .child_by_field_name("tag")
+            .and_then(|tag| tag.utf8_text(source.as_bytes()).ok())
+        else {
+            continue;
+        };
+        for (key, name) in struct_tag_names(tag.trim_matches('`')) {
+            match by_key.iter_mut().find(|(existing, _)| *existing == key) {
+                Some((_, names)) => names.push(name),
+                None => by_key.push((key, vec![name])),
+            }
+        }
+    }
+    attributes.extend(
+        by_key
+            .into_iter()
+            .map(|(key, names)| (key, names.join(","))),
+    );
+    attributes
+}
+
+/// `//nolint:errcheck,gosec` -> (`nolint`, `errcheck,gosec`);
+/// `//go:generate stringer -type=Kind` -> (`go:generate`, `stringer -type=Kind`).
+/// Directives follow Go's convention of no space after `//` and a
+/// lowercase `word:` prefix; `//nolint` and `//export` are recognized bare.
+fn go_directive(line: &str) -> Option<(String, String)> {
+    let directive = line.trim().strip_prefix("//")?;
+    let (token, rest) = directive
+        .split_once(char::is_whitespace)
+        .unwrap_or((directive, ""));
+    if let Some(linters) = token.strip_prefix("nolint:") {
+        return Some(("nolint".to_string(), linters.to_string()));
+    }
+    let conventional = token.split_once(':').is_some_and(|(prefix, name)| {
+        !prefix.is_empty()
+            && prefix
+                .chars()
+                .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit())
+            && name.starts_with(|c: char| c.is_ascii_lowercase())
+    });
+    if !(conventional || matches!(token, "nolint" | "export")) {
+        return None;
+    }
+    Some((token.to_string(), rest.trim().to_string()))
+}
+
+/// `json:"user_id,omitempty" db:"user_id"` -> (`json`, `user_id`),
+/// (`db`, `user_id`); `-` and empty names are left out
+fn struct_tag_names(tag: &str) -> Vec<(String, String)> {
+    let mut names = Vec::new();
+    let mut rest = tag.trim();
+    while let Some((key, after)) = rest.split_once(":\"") {
+        let Some(end) = after.find('"') else { break };
+        let name = after[..end].split(',').next().unwrap_or("").trim();
+        if !name.is_empty() && name != "-" {
+            names.push((key.trim().to_string(), name.to_string()));
+        }
+        rest = &after[end + 1..];
+    }
+    names
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, SymbolId, SymbolType, Visibility};
+    use std::path::PathBuf;
+
+    fn symbol(tags: &[(&str, &str)]) -> Symbol {
+        let file = PathBuf::from("x.java");
+        Symbol {
+            id: SymbolId::new(&file, 1, 0),
+            name: "x".to_string(),
+            symbol_type: SymbolType::Method,
+            location: Location::new(file, 1, 0, 1, 1),
+            namespace: None,
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: tags
+                .iter()
+                .map(|(k, v)| (k.to_string(), v.to_string()))
+                .collect(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    #[test]
+    fn test_parse_attribute() {
+        let parsed = |text| parse_attribute(text).unwrap();
+        assert_eq!(parsed("@Override"), ("Override".into(), "".into()));
+        assert_eq!(
+            parsed("@Deprecated(since = \"9\",\n    forRemoval = true)"),
+            (
+                "Deprecated".into(),
+                "since = \"9\", forRemoval = true".into()
+            )
+        );
+        assert_eq!(
+            parsed("#[derive(Debug, Clone)]"),
+            ("derive".into(), "Debug, Clone".into())
+        );
+        assert_eq!(parsed("#[doc = \"x\"]"), ("doc".into(), "\"x\"".into()));
+        assert_eq!(
+            parsed("@app.route(\"/users\")"),
+            ("app.route".into(), "\"/users\"".into())
+        );
+        assert_eq!(
+            parsed("Obsolete(\"Use Get\")"),
+            ("Obsolete".into(), "\"Use Get\"".into())
+        );
+
+        assert_eq!(
+            go_directive("//nolint:errcheck,gosec // flushed on close"),
+            Some(("nolint".into(), "errcheck,gosec".into()))
+        );
+        assert_eq!(
+            go_directive("//go:generate stringer -type=Kind"),
+            Some(("go:generate".into(), "stringer -type=Kind".into()))
+        );
+        assert_eq!(go_directive("// Charge bills a customer: now"), None);
+        assert_eq!(go_directive("//TODO: fix"), None);
+
+        assert_eq!(
+            struct_tag_names(r#"json:"user_id,omitempty" db:"user_id" yaml:"-""#),
+            vec![
+                ("json".to_string(), "user_id".to_string()),
+                ("db".to_string(), "user_id".to_string())
+            ]
+        );
+    }
+
+    #[test]
+    fn test_has_attribute() {
+        let method = symbol(&[
+            ("@java.lang.Deprecated", "since = \"9\""),
+            ("@Route", "\"/users\", methods = GET; \"/people\""),
+            ("@json", "user_id,name"),
+            ("stability", "experimental"),
+        ]);
+        assert!(has_attribute(&method, "@Deprecated"));
+        assert!(has_attribute(&method, "deprecated"));
+        assert!(has_attribute(&method, "java.lang.Deprecated"));
+        assert!(!has_attribute(&method, "@Override"));
+
+        assert!(has_attribute(&method, "@stability=experimental"));
+        assert!(!has_attribute(&method, "@stability=stable"));
+        assert!(has_attribute(&method, "@Route=/people"));
+        assert!(has_attribute(&method, "@Route=\"/users\""));
+        assert!(has_attribute(&method, "@json=*_*"));
+        assert!(!has_attribute(&method, "@json=*-*"));
+        assert!(has_attribute(&method, "@Deprecated=since*"));
+        assert!(!has_attribute(&method, "@"));
+    }
+}
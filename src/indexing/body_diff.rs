@@ -0,0 +1,296 @@
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+
+/// Largest LCS table, in cells, a diff may need after common prefixes and
+/// suffixes are trimmed; about 100MB
+const MAX_DIFF_CELLS: usize = 25_000_000;
+
+/// What a body diff compares
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize, Deserialize, JsonSchema)]
+#[serde(rename_all = "snake_case")]
+pub enum DiffGranularity {
+    /// Whole lines
+    #[default]
+    Line,
+    /// Identifiers, literals and punctuation, ignoring layout
+    Token,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SegmentKind {
+    /// In both definitions
+    Common,
+    /// Only in the second definition
+    Added,
+    /// Only in the first definition
+    Removed,
+}
+
+/// A run of lines or tokens on the same side of the diff
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DiffSegment {
+    pub kind: SegmentKind,
+    /// File line of the segment's first item in the first definition;
+    /// absent for added segments
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub a_line: Option<u32>,
+    /// File line of the segment's first item in the second definition;
+    /// absent for removed segments
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub b_line: Option<u32>,
+    /// The lines or tokens, as written in the second definition for common
+    /// segments
+    pub items: Vec<String>,
+}
+
+/// Differences between two definitions
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct BodyDiff {
+    pub segments: Vec<DiffSegment>,
+    /// Lines or tokens only in the second definition
+    pub added: usize,
+    /// Lines or tokens only in the first definition
+    pub removed: usize,
+    pub common: usize,
+    /// Share of lines or tokens in common, from 0 to 1
+    pub similarity: f64,
+}
+
+/// One line or token with the key it is compared by
+struct Item<'a> {
+    text: &'a str,
+    key: String,
+    line: u32,
+}
+
+/// Diff two definitions, each given with the file line it starts on. With
+/// `ignore_whitespace`, lines are compared with runs of whitespace
+/// collapsed and leading and trailing whitespace removed, and blank lines
+/// are skipped; tokens never depend on layout.
+pub fn diff_bodies(
+    a: (&str, u32),
+    b: (&str, u32),
+    granularity: DiffGranularity,
+    ignore_whitespace: bool,
+) -> Result<BodyDiff, String> {
+    let (a, b) = match granularity {
+        DiffGranularity::Line => (
+            line_items(a.0, a.1, ignore_whitespace),
+            line_items(b.0, b.1, ignore_whitespace),
+        ),
+        DiffGranularity::Token => (token_items(a.0, a.1), token_items(b.0, b.1)),
+    };
+    let edits = edit_script(&a, &b)?;
+
+    let mut diff = BodyDiff {
+        segments: Vec::new(),
+        added: 0,
+        removed: 0,
+        common: 0,
+        similarity: 0.0,
+    };
+    for (kind, a_index, b_index) in edits {
+        let item = match kind {
+            SegmentKind::Removed => &a[a_index],
+            _ => &b[b_index],
+        };
+        match kind {
+            SegmentKind::Common => diff.common += 1,
+            SegmentKind::Added => diff.added += 1,
+            SegmentKind::Removed => diff.removed += 1,
+        }
+        match diff.segments.last_mut() {
+            Some(segment) if segment.kind == kind => segment.items.push(item.text.to_string()),
+            _ => diff.segments.push(DiffSegment {
+                kind,
+                a_line: (kind != SegmentKind::Added).then(|| a[a_index].line),
+                b_line: (kind != SegmentKind::Removed).then(|| b[b_index].line),
+                items: vec![item.text.to_string()],
+            }),
+        }
+    }
+    let total = a.len() + b.len();
+    diff.similarity = match total {
+        0 => 1.0,
+        _ => (2 * diff.common) as f64 / total as f64,
+    };
+    Ok(diff)
+}
+
+fn line_items(source: &str, first_line: u32, ignore_whitespace: bool) -> Vec<Item<'_>> {
+    source
+        .lines()
+        .enumerate()
+        .map(|(row, text)| Item {
+            text,
+            key: match ignore_whitespace {
+                true => text.split_whitespace().collect::<Vec<_>>().join(" "),
+                false => text.to_string(),
+            },
+            line: first_line + row as u32,
+        })
+        .filter(|item| !(ignore_whitespace && item.key.is_empty()))
+        .collect()
+}
+
+/// Split source into identifiers and numbers, string literals, and single
+/// punctuation characters
+fn token_items(source: &str, first_line: u32) -> Vec<Item<'_>> {
+    let mut items = Vec::new();
+    for (row, line) in source.lines().enumerate() {
+        let line_number = first_line + row as u32;
+        let mut chars = line.char_indices().peekable();
+        while let Some((start, c)) = chars.next() {
+            if c.is_whitespace() {
+                continue;
+            }
+            let mut end = start + c.len_utf8();
+            if c.is_alphanumeric() || c == '_' {
+                while let Some(&(index, next)) = chars.peek() {
+                    if !(next.is_alphanumeric() || next == '_') {
+                        break;
+                    }
+                    end = index + next.len_utf8();
+                    chars.next();
+                }
+            } else if matches!(c, '"' | '\'' | '`') {
+                let mut escaped = false;
+                for (index, next) in chars.by_ref() {
+                    end = index + next.len_utf8();
+                    if next == c && !escaped {
+                        break;
+                    }
+                    escaped = next == '\\' && !escaped;
+                }
+            }
+            let text = &line[start..end];
+            items.push(Item {
+                text,
+                key: text.to_string(),
+                line: line_number,
+            });
+        }
+    }
+    items
+}
+
+/// The longest common subsequence of two item lists as a sequence of
+/// (kind, index in `a`, index in `b`) steps; removals come before
+/// additions within a change
+fn edit_script(a: &[Item], b: &[Item]) -> Result<Vec<(SegmentKind, usize, usize)>, String> {
+    let prefix = a.iter().zip(b).take_while(|(x, y)| x.key == y.key).count();
+    let suffix = a[prefix..]
+        .iter()
+        .rev()
+        .zip(b[prefix..].iter().rev())
+        .take_while(|(x, y)| x.key == y.key)
+        .count();
+    let (a_mid, b_mid) = (&a[prefix..a.len() - suffix], &b[prefix..b.len() - suffix]);
+    let (n, m) = (a_mid.len(), b_mid.len());
+    if n.saturating_mul(m) > MAX_DIFF_CELLS {
+        return Err(format!(
+            "The definitions differ in {} and {} items, too many to diff at this granularity",
+            n, m
+        ));
+    }
+
+    // lengths[i][j]: LCS length of a_mid[i..] and b_mid[j..]
+    let width = m + 1;
+    let mut lengths = vec![0u32; (n + 1) * width];
+    for i in (0..n).rev() {
+        for j in (0..m).rev() {
+            lengths[i * width + j] = if a_mid[i].key == b_mid[j].key {
+                lengths[(i + 1) * width + j + 1] + 1
+            } else {
+                lengths[(i + 1) * width + j].max(lengths[i * width + j + 1])
+            };
+        }
+    }
+
+    let mut steps: Vec<(SegmentKind, usize, usize)> = (0..prefix)
+        .map(|index| (SegmentKind::Common, index, index))
+        .collect();
+    let (mut i, mut j) = (0, 0);
+    while i < n || j < m {
+        if i < n && j < m && a_mid[i].key == b_mid[j].key {
+            steps.push((SegmentKind::Common, prefix + i, prefix + j));
+            i += 1;
+            j += 1;
+        } else if j == m || (i < n && lengths[(i + 1) * width + j] >= lengths[i * width + j + 1]) {
+            steps.push((SegmentKind::Removed, prefix + i, prefix + j));
+            i += 1;
+        } else {
+            steps.push((SegmentKind::Added, prefix + i, prefix + j));
+            j += 1;
+        }
+    }
+    steps.extend((0..suffix).map(|offset| {
+        (
+            SegmentKind::Common,
+            a.len() - suffix + offset,
+            b.len() - suffix + offset,
+        )
+    }));
+    Ok(steps)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const POSTGRES: &str = "func (t *PostgresTransaction) ExecuteQuery(q string) (*Result, error) {\n    return t.conn.Query(q)\n}";
+    const MYSQL: &str = "func (t *MySQLTransaction) ExecuteQuery(q string) (*Result, error) {\n\n      return t.conn.Query(q)\n}";
+
+    #[test]
+    fn test_diff_lines() {
+        let diff = diff_bodies((POSTGRES, 10), (MYSQL, 40), DiffGranularity::Line, true).unwrap();
+        let kinds: Vec<(SegmentKind, Option<u32>, Option<u32>, usize)> = diff
+            .segments
+            .iter()
+            .map(|s| (s.kind, s.a_line, s.b_line, s.items.len()))
+            .collect();
+        assert_eq!(
+            kinds,
+            vec![
+                (SegmentKind::Removed, Some(10), None, 1),
+                (SegmentKind::Added, None, Some(40), 1),
+                (SegmentKind::Common, Some(11), Some(42), 2),
+            ]
+        );
+        assert_eq!(diff.segments[2].items[0], "      return t.conn.Query(q)");
+        assert_eq!((diff.added, diff.removed, diff.common), (1, 1, 2));
+
+        // Without normalization the indentation and blank line count too
+        let diff = diff_bodies((POSTGRES, 10), (MYSQL, 40), DiffGranularity::Line, false).unwrap();
+        assert_eq!((diff.added, diff.removed, diff.common), (3, 2, 1));
+    }
+
+    #[test]
+    fn test_diff_tokens() {
+        let diff = diff_bodies((POSTGRES, 10), (MYSQL, 40), DiffGranularity::Token, false).unwrap();
+        let changed: Vec<(SegmentKind, Vec<&str>)> = diff
+            .segments
+            .iter()
+            .filter(|s| s.kind != SegmentKind::Common)
+            .map(|s| (s.kind, s.items.iter().map(String::as_str).collect()))
+            .collect();
+        assert_eq!(
+            changed,
+            vec![
+                (SegmentKind::Removed, vec!["PostgresTransaction"]),
+                (SegmentKind::Added, vec!["MySQLTransaction"]),
+            ]
+        );
+        assert!(diff.similarity > 0.95);
+
+        let tokens: Vec<&str> = token_items(r#"fmt.Errorf("bad \"id\"", id)"#, 1)
+            .iter()
+            .map(|item| item.text)
+            .collect();
+        assert_eq!(
+            tokens,
+            vec!["fmt", ".", "Errorf", "(", r#""bad \"id\"""#, ",", "id", ")"]
+        );
+    }
+}
@@ -0,0 +1,327 @@
+use std::path::Path;
+
+/// Tag key under which a Go file's build constraint is recorded on its symbols
+pub const BUILD_TAG: &str = "build";
+
+const KNOWN_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "nacl",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+    "zos",
+];
+
+/// Operating systems that satisfy the `unix` build tag
+const UNIX_OS: &[&str] = &[
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "linux",
+    "netbsd",
+    "openbsd",
+    "solaris",
+];
+
+const KNOWN_ARCH: &[&str] = &[
+    "386",
+    "amd64",
+    "amd64p32",
+    "arm",
+    "armbe",
+    "arm64",
+    "arm64be",
+    "loong64",
+    "mips",
+    "mipsle",
+    "mips64",
+    "mips64le",
+    "mips64p32",
+    "mips64p32le",
+    "ppc",
+    "ppc64",
+    "ppc64le",
+    "riscv",
+    "riscv64",
+    "s390",
+    "s390x",
+    "sparc",
+    "sparc64",
+    "wasm",
+];
+
+/// The build constraint of a Go file as a `//go:build` expression, combining
+/// the constraint comments above the package clause with `_GOOS`/`_GOARCH`
+/// file name suffixes. `None` when the file is part of every build.
+pub fn file_constraint(file_path: &Path, source: &str) -> Option<String> {
+    let header = header_constraint(source);
+    let suffix = file_name_constraint(file_path);
+
+    match (header, suffix) {
+        (Some(header), Some(suffix)) => Some(format!("{} && {}", parenthesize(&header), suffix)),
+        (header, suffix) => header.or(suffix),
+    }
+}
+
+/// Whether a constraint expression holds for a build context given as a comma
+/// separated tag list such as `linux,amd64`. `unix` is implied by Unix-like
+/// operating systems. Expressions that fail to parse are treated as satisfied
+/// so malformed constraints never hide symbols.
+pub fn satisfies(constraint: &str, context: &str) -> bool {
+    let mut tags: Vec<&str> = context
+        .split([',', ' '])
+        .map(str::trim)
+        .filter(|tag| !tag.is_empty())
+        .collect();
+    if tags.iter().any(|tag| UNIX_OS.contains(tag)) {
+        tags.push("unix");
+    }
+
+    let tokens = tokenize(constraint);
+    let mut parser = ExprParser {
+        tokens: &tokens,
+        position: 0,
+        tags: &tags,
+    };
+    match parser.or() {
+        Some(result) if parser.position == tokens.len() => result,
+        _ => true,
+    }
+}
+
+/// `//go:build` line, or the legacy `// +build` lines, above the package clause
+fn header_constraint(source: &str) -> Option<String> {
+    let mut legacy: Vec<String> = Vec::new();
+    let mut in_block_comment = false;
+
+    for line in source.lines() {
+        let line = line.trim();
+        if in_block_comment {
+            in_block_comment = !line.contains("*/");
+            continue;
+        }
+        if line.is_empty() {
+            continue;
+        }
+        if line.starts_with("/*") {
+            in_block_comment = !line.contains("*/");
+            continue;
+        }
+        let Some(comment) = line.strip_prefix("//") else {
+            // The package clause ends the header
+            break;
+        };
+
+        if let Some(expression) = comment.strip_prefix("go:build") {
+            let expression = expression.trim();
+            if !expression.is_empty() {
+                return Some(expression.to_string());
+            }
+        } else if let Some(options) = comment.trim_start().strip_prefix("+build") {
+            if let Some(expression) = legacy_expression(options) {
+                legacy.push(expression);
+            }
+        }
+    }
+
+    match legacy.len() {
+        0 => None,
+        1 => legacy.pop(),
+        _ => Some(
+            legacy
+                .iter()
+                .map(|expression| parenthesize(expression))
+                .collect::<Vec<_>>()
+                .join(" && "),
+        ),
+    }
+}
+
+/// Convert `// +build a,b !c` (space is OR, comma is AND) to `a && b || !c`
+fn legacy_expression(options: &str) -> Option<String> {
+    let alternatives: Vec<String> = options
+        .split_whitespace()
+        .map(|option| option.split(',').collect::<Vec<_>>().join(" && "))
+        .collect();
+    (!alternatives.is_empty()).then(|| alternatives.join(" || "))
+}
+
+/// `name_GOOS.go`, `name_GOARCH.go` and `name_GOOS_GOARCH.go`, with or
+/// without a `_test` suffix
+fn file_name_constraint(file_path: &Path) -> Option<String> {
+    let stem = file_path.file_stem()?.to_str()?;
+    let stem = stem.strip_suffix("_test").unwrap_or(stem);
+    let parts: Vec<&str> = stem.split('_').collect();
+
+    let n = parts.len();
+    if n >= 3 && KNOWN_OS.contains(&parts[n - 2]) && KNOWN_ARCH.contains(&parts[n - 1]) {
+        return Some(format!("{} && {}", parts[n - 2], parts[n - 1]));
+    }
+    if n >= 2 && (KNOWN_OS.contains(&parts[n - 1]) || KNOWN_ARCH.contains(&parts[n - 1])) {
+        return Some(parts[n - 1].to_string());
+    }
+    None
+}
+
+fn parenthesize(expression: &str) -> String {
+    if expression.contains("||") {
+        format!("({})", expression)
+    } else {
+        expression.to_string()
+    }
+}
+
+fn tokenize(expression: &str) -> Vec<String> {
+    let mut tokens = Vec::new();
+    let mut chars = expression.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            ' ' | '\t' => {}
+            '(' | ')' | '!' => tokens.push(c.to_string()),
+            '&' | '|' => {
+                if chars.peek() == Some(&c) {
+                    chars.next();
+                }
+                tokens.push(format!("{}{}", c, c));
+            }
+            _ => {
+                let mut tag = c.to_string();
+                while let Some(&next) = chars.peek() {
+                    if next.is_alphanumeric() || next == '_' || next == '.' {
+                        tag.push(next);
+                        chars.next();
+                    } else {
+                        break;
+                    }
+                }
+                tokens.push(tag);
+            }
+        }
+    }
+    tokens
+}
+
+/// Recursive descent over `||`, `&&`, `!` and parentheses, in Go's precedence
+struct ExprParser<'a> {
+    tokens: &'a [String],
+    position: usize,
+    tags: &'a [&'a str],
+}
+
+impl ExprParser<'_> {
+    fn or(&mut self) -> Option<bool> {
+        let mut result = self.and()?;
+        while self.eat("||") {
+            result |= self.and()?;
+        }
+        Some(result)
+    }
+
+    fn and(&mut self) -> Option<bool> {
+        let mut result = self.unary()?;
+        while self.eat("&&") {
+            result &= self.unary()?;
+        }
+        Some(result)
+    }
+
+    fn unary(&mut self) -> Option<bool> {
+        if self.eat("!") {
+            return self.unary().map(|value| !value);
+        }
+        if self.eat("(") {
+            let result = self.or()?;
+            return self.eat(")").then_some(result);
+        }
+        let tag = self.tokens.get(self.position)?;
+        if matches!(tag.as_str(), ")" | "&&" | "||") {
+            return None;
+        }
+        self.position += 1;
+        Some(self.tags.contains(&tag.as_str()))
+    }
+
+    fn eat(&mut self, token: &str) -> bool {
+        if self.tokens.get(self.position).map(String::as_str) == Some(token) {
+            self.position += 1;
+            true
+        } else {
+            false
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    #[test]
+    fn test_file_constraint() {
+        let plain = "package main\n\nfunc main() {}\n";
+        assert_eq!(file_constraint(&PathBuf::from("main.go"), plain), None);
+        assert_eq!(
+            file_constraint(&PathBuf::from("poll_linux.go"), plain).as_deref(),
+            Some("linux")
+        );
+        assert_eq!(
+            file_constraint(&PathBuf::from("asm_linux_amd64_test.go"), plain).as_deref(),
+            Some("linux && amd64")
+        );
+        // A bare GOOS name has no prefix and is not a constraint
+        assert_eq!(file_constraint(&PathBuf::from("linux.go"), plain), None);
+
+        let tagged = "// Copyright 2024\n\n//go:build darwin || freebsd\n\npackage poll\n";
+        assert_eq!(
+            file_constraint(&PathBuf::from("poll.go"), tagged).as_deref(),
+            Some("darwin || freebsd")
+        );
+        assert_eq!(
+            file_constraint(&PathBuf::from("poll_arm64.go"), tagged).as_deref(),
+            Some("(darwin || freebsd) && arm64")
+        );
+
+        let legacy = "// +build linux,386 darwin,!cgo\n// +build !purego\n\npackage poll\n";
+        assert_eq!(
+            file_constraint(&PathBuf::from("poll.go"), legacy).as_deref(),
+            Some("(linux && 386 || darwin && !cgo) && !purego")
+        );
+
+        // Constraints after the package clause are ignored
+        let late = "package main\n\n//go:build linux\n";
+        assert_eq!(file_constraint(&PathBuf::from("main.go"), late), None);
+    }
+
+    #[test]
+    fn test_satisfies() {
+        assert!(satisfies("linux", "linux"));
+        assert!(!satisfies("windows", "linux"));
+        assert!(satisfies("linux && amd64", "linux,amd64"));
+        assert!(!satisfies("linux && amd64", "linux,arm64"));
+        assert!(satisfies("darwin || linux", "linux"));
+        assert!(satisfies("!windows", "linux"));
+        assert!(satisfies("unix && !darwin", "linux"));
+        assert!(!satisfies("unix", "windows"));
+        assert!(satisfies("(linux || darwin) && !cgo", "darwin"));
+        assert!(!satisfies("(linux || darwin) && !cgo", "darwin,cgo"));
+        // Malformed constraints never exclude symbols
+        assert!(satisfies("linux &&", "windows"));
+    }
+}
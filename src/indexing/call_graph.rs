@@ -0,0 +1,495 @@
+use crate::indexing::symbol_analysis::{calls, find_definition, method_values};
+use crate::models::{Language, Symbol, SymbolId, SymbolType};
+use crate::storage::store::SymbolStore;
+use std::collections::HashMap;
+use std::hash::Hash;
+use std::path::Path;
+use tree_sitter::Parser;
+
+/// Receivers that refer to the enclosing object in method bodies
+const SELF_RECEIVERS: &[&str] = &["self", "this", "Self", "$this"];
+
+/// Languages where an unqualified call inside a method can call another method
+const IMPLICIT_RECEIVER_LANGUAGES: &[Language] = &[
+    Language::Java,
+    Language::CSharp,
+    Language::Cpp,
+    Language::Kotlin,
+    Language::Scala,
+    Language::Swift,
+    Language::Ruby,
+];
+
+/// Calls between indexed functions and methods, resolved by name.
+///
+/// Resolution is deliberately conservative so recursion is not reported for
+/// calls that merely share a name: unqualified calls resolve to functions of
+/// the same package (and to methods of the same file in languages with an
+/// implicit `this`), `self.`/`this.`/receiver
+/// calls to methods of the same type, and `pkg.Func` calls to functions of the
+/// package named `pkg`. Calls through other values are not followed.
+/// Go method values and method expressions handed to other code, such as
+/// `sort.Slice(xs, s.less)` or `(*Conn).Close` stored as a callback, count as
+/// calls of the method since the code receiving them calls it.
+#[derive(Debug, Default)]
+pub struct CallGraph {
+    edges: HashMap<SymbolId, Vec<SymbolId>>,
+}
+
+impl CallGraph {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Parse a file and add the calls made by its functions and methods
+    pub fn add_file(
+        &mut self,
+        store: &SymbolStore,
+        file_path: &Path,
+        source: &str,
+        functions: &[Symbol],
+    ) {
+        let Some(language) = Language::from_path(file_path) else {
+            return;
+        };
+        let mut parser = Parser::new();
+        if parser
+            .set_language(&language.tree_sitter_language())
+            .is_err()
+        {
+            return;
+        }
+        let Some(tree) = parser.parse(source, None) else {
+            return;
+        };
+
+        for caller in functions {
+            let Some(definition) = find_definition(tree.root_node(), &caller.location) else {
+                continue;
+            };
+            let mut targets: Vec<SymbolId> = Vec::new();
+            let values = if language == Language::Go {
+                method_values(definition, source)
+            } else {
+                Vec::new()
+            };
+            for call in calls(definition, source).into_iter().chain(values) {
+                for target in resolve_call(store, caller, &call) {
+                    if !targets.contains(&target) {
+                        targets.push(target);
+                    }
+                }
+            }
+            self.edges.insert(caller.id, targets);
+        }
+    }
+
+    pub fn callees(&self, id: &SymbolId) -> &[SymbolId] {
+        self.edges.get(id).map_or(&[], Vec::as_slice)
+    }
+
+    /// The reverse graph: every called function with the functions calling it
+    pub fn callers(&self) -> HashMap<SymbolId, Vec<SymbolId>> {
+        let mut callers: HashMap<SymbolId, Vec<SymbolId>> = HashMap::new();
+        for (caller, callees) in &self.edges {
+            for callee in callees {
+                callers.entry(*callee).or_default().push(*caller);
+            }
+        }
+        for ids in callers.values_mut() {
+            ids.sort_by_key(|id| id.0);
+        }
+        callers
+    }
+
+    /// Groups of functions that call each other, directly or through other
+    /// members of the group: every strongly connected component with more
+    /// than one member, plus functions that call themselves
+    pub fn recursion_cycles(&self) -> Vec<Vec<SymbolId>> {
+        strongly_connected_components(&self.edges)
+            .into_iter()
+            .filter(|component| {
+                component.len() > 1 || self.callees(&component[0]).contains(&component[0])
+            })
+            .collect()
+    }
+}
+
+/// Indexed functions a call expression can reach from `caller`
+fn resolve_call(store: &SymbolStore, caller: &Symbol, call: &str) -> Vec<SymbolId> {
+    let (qualifier, name) = match call.rsplit_once(['.', ':']) {
+        Some((qualifier, name)) => (Some(qualifier.trim_end_matches(':')), name),
+        None => (None, call),
+    };
+
+    let receiver = caller
+        .signature
+        .as_ref()
+        .and_then(|signature| signature.receiver.as_deref())
+        .map(go_receiver);
+
+    let implicit_receiver = Language::from_path(&caller.location.file)
+        .is_some_and(|language| IMPLICIT_RECEIVER_LANGUAGES.contains(&language));
+
+    store
+        .get_symbols(name)
+        .into_iter()
+        .filter(|target| match qualifier {
+            None => match target.symbol_type {
+                SymbolType::Function => same_package(caller, target),
+                SymbolType::Method => {
+                    implicit_receiver && target.location.file == caller.location.file
+                }
+                _ => false,
+            },
+            Some(qualifier) if qualifier.starts_with('(') => {
+                // Method expression: `(*Conn).Close`, `(*db.Conn).Close`
+                let type_name = qualifier
+                    .trim_matches(['(', ')', '*'])
+                    .rsplit('.')
+                    .next()
+                    .unwrap_or_default();
+                target.symbol_type == SymbolType::Method
+                    && receiver_type(target).as_deref() == Some(type_name)
+            }
+            Some(qualifier) => {
+                let own_receiver = SELF_RECEIVERS.contains(&qualifier)
+                    || receiver.is_some_and(|(name, _)| name == qualifier);
+                if own_receiver {
+                    target.symbol_type == SymbolType::Method && same_type(caller, target)
+                } else {
+                    target.symbol_type == SymbolType::Function
+                        && target
+                            .namespace
+                            .as_deref()
+                            .and_then(|namespace| namespace.rsplit(['/', '.', ':']).next())
+                            == Some(qualifier)
+                }
+            }
+        })
+        .map(|target| target.id)
+        .collect()
+}
+
+fn same_package(caller: &Symbol, target: &Symbol) -> bool {
+    caller.namespace == target.namespace
+        || (caller.namespace.is_none() && target.location.file == caller.location.file)
+}
+
+/// Base type of a Go method's receiver: `Server` for `s *Server[T]`
+pub(crate) fn receiver_type(symbol: &Symbol) -> Option<String> {
+    symbol
+        .signature
+        .as_ref()
+        .and_then(|signature| signature.receiver.as_deref())
+        .map(go_receiver)
+        .map(|(_, type_name)| type_name.to_string())
+}
+
+/// Whether a Go method has a pointer receiver, `s *Server`, and so is only
+/// in the method set of `*Server`
+pub(crate) fn has_pointer_receiver(symbol: &Symbol) -> bool {
+    symbol
+        .signature
+        .as_ref()
+        .and_then(|signature| signature.receiver.as_deref())
+        .is_some_and(is_pointer_receiver)
+}
+
+fn is_pointer_receiver(receiver: &str) -> bool {
+    let receiver = receiver.trim();
+    let type_name = receiver.split_once(' ').map_or(receiver, |(_, t)| t);
+    type_name.trim().starts_with('*')
+}
+
+/// Methods of the same type: the same Go receiver type, otherwise the same file
+fn same_type(caller: &Symbol, target: &Symbol) -> bool {
+    match (receiver_type(caller), receiver_type(target)) {
+        (Some(caller_type), Some(target_type)) => {
+            caller_type == target_type && same_package(caller, target)
+        }
+        _ => target.location.file == caller.location.file,
+    }
+}
+
+/// Split a Go receiver like `s *Server[T]` into its name and base type
+fn go_receiver(receiver: &str) -> (&str, &str) {
+    let (name, type_name) = receiver.trim().split_once(' ').unwrap_or(("", receiver));
+    let type_name = type_name.trim().trim_start_matches('*');
+    let type_name = type_name.split('[').next().unwrap_or(type_name);
+    (name.trim(), type_name)
+}
+
+/// Tarjan's algorithm, iterative so deep call chains cannot overflow the
+/// stack. Components and their members come out in a stable order.
+pub fn strongly_connected_components<T: Copy + Eq + Hash + Ord>(
+    edges: &HashMap<T, Vec<T>>,
+) -> Vec<Vec<T>> {
+    struct NodeState {
+        index: usize,
+        low_link: usize,
+        on_stack: bool,
+    }
+
+    let mut states: HashMap<T, NodeState> = HashMap::new();
+    let mut stack: Vec<T> = Vec::new();
+    let mut components = Vec::new();
+    let mut next_index = 0;
+
+    let mut roots: Vec<&T> = edges.keys().collect();
+    roots.sort();
+
+    for root in roots {
+        if states.contains_key(root) {
+            continue;
+        }
+
+        // Each frame is a node and the position of the next edge to explore
+        let mut frames: Vec<(T, usize)> = vec![(*root, 0)];
+        states.insert(
+            *root,
+            NodeState {
+                index: next_index,
+                low_link: next_index,
+                on_stack: true,
+            },
+        );
+        next_index += 1;
+        stack.push(*root);
+
+        while let Some((node, edge)) = frames.last_mut() {
+            let node = *node;
+            let successors = edges.get(&node).map_or(&[][..], Vec::as_slice);
+
+            if let Some(&next) = successors.get(*edge) {
+                *edge += 1;
+                match states.get(&next) {
+                    None => {
+                        states.insert(
+                            next,
+                            NodeState {
+                                index: next_index,
+                                low_link: next_index,
+                                on_stack: true,
+                            },
+                        );
+                        next_index += 1;
+                        stack.push(next);
+                        frames.push((next, 0));
+                    }
+                    Some(state) if state.on_stack => {
+                        let index = state.index;
+                        let current = states.get_mut(&node).unwrap();
+                        current.low_link = current.low_link.min(index);
+                    }
+                    Some(_) => {}
+                }
+                continue;
+            }
+
+            // All edges explored: close the component rooted here, if any
+            frames.pop();
+            let (index, low_link) = {
+                let state = &states[&node];
+                (state.index, state.low_link)
+            };
+            if let Some((parent, _)) = frames.last() {
+                let parent = states.get_mut(parent).unwrap();
+                parent.low_link = parent.low_link.min(low_link);
+            }
+            if low_link == index {
+                let mut component = Vec::new();
+                while let Some(member) = stack.pop() {
+                    states.get_mut(&member).unwrap().on_stack = false;
+                    component.push(member);
+                    if member == node {
+                        break;
+                    }
+                }
+                component.sort();
+                components.push(component);
+            }
+        }
+    }
+
+    components
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn graph(edges: &[(u64, &[u64])]) -> CallGraph {
+        CallGraph {
+            edges: edges
+                .iter()
+                .map(|(from, to)| (SymbolId(*from), to.iter().map(|id| SymbolId(*id)).collect()))
+                .collect(),
+        }
+    }
+
+    #[test]
+    fn test_strongly_connected_components() {
+        // Packages: a <-> b, b -> c -> d -> c, e alone
+        let edges: HashMap<usize, Vec<usize>> = HashMap::from([
+            (0, vec![1]),
+            (1, vec![0, 2]),
+            (2, vec![3]),
+            (3, vec![2]),
+            (4, vec![]),
+        ]);
+        let mut components = strongly_connected_components(&edges);
+        components.sort();
+        assert_eq!(components, vec![vec![0, 1], vec![2, 3], vec![4]]);
+    }
+
+    #[test]
+    fn test_recursion_cycles() {
+        // 1 calls itself; 2 -> 3 -> 4 -> 2 is mutual recursion; 5 -> 6 is not recursive
+        let graph = graph(&[
+            (1, &[1, 5]),
+            (2, &[3]),
+            (3, &[4]),
+            (4, &[2, 5]),
+            (5, &[6]),
+            (6, &[]),
+        ]);
+
+        let mut cycles = graph.recursion_cycles();
+        cycles.sort_by_key(|cycle| cycle[0].0);
+        assert_eq!(
+            cycles,
+            vec![
+                vec![SymbolId(1)],
+                vec![SymbolId(2), SymbolId(3), SymbolId(4)]
+            ]
+        );
+
+        let callers = graph.callers();
+        assert_eq!(callers[&SymbolId(5)], vec![SymbolId(1), SymbolId(4)]);
+        assert_eq!(callers[&SymbolId(1)], vec![SymbolId(1)]);
+    }
+
+    #[test]
+    fn test_go_call_resolution() {
+        use crate::indexing::SymbolIndexer;
+        use std::path::PathBuf;
+
+        let source = r#"package parity
+
+func isEven(n int) bool {
+    if n == 0 {
+        return true
+    }
+    return isOdd(n - 1)
+}
+
+func isOdd(n int) bool {
+    if n == 0 {
+        return false
+    }
+    return isEven(n - 1)
+}
+
+func containsSubstring(s, sub string) bool {
+    return strings.Contains(s, sub)
+}
+
+type Name struct{ inner fmt.Stringer }
+
+func (n Name) String() string {
+    return n.inner.String()
+}
+
+func (n Name) Walk(depth int) {
+    if depth > 0 {
+        n.Walk(depth - 1)
+    }
+}
+"#;
+        let file = PathBuf::from("parity.go");
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let symbols = indexer
+            .extract_symbols(source, Language::Go, &file)
+            .unwrap();
+        let store = SymbolStore::new();
+        store.insert_symbols_unchecked(symbols.clone());
+
+        let functions: Vec<Symbol> = symbols
+            .iter()
+            .filter(|s| matches!(s.symbol_type, SymbolType::Function | SymbolType::Method))
+            .cloned()
+            .collect();
+        let mut graph = CallGraph::new();
+        graph.add_file(&store, &file, source, &functions);
+
+        let id = |name: &str| symbols.iter().find(|s| s.name == name).unwrap().id;
+        let mut cycles = graph.recursion_cycles();
+        cycles
+            .iter_mut()
+            .for_each(|cycle| cycle.sort_by_key(|id| id.0));
+        cycles.sort_by_key(|cycle| cycle.len());
+
+        let mut parity = vec![id("isEven"), id("isOdd")];
+        parity.sort_by_key(|id| id.0);
+        assert_eq!(cycles, vec![vec![id("Walk")], parity]);
+    }
+
+    #[test]
+    fn test_go_method_values() {
+        use crate::indexing::SymbolIndexer;
+        use std::path::PathBuf;
+
+        let source = r#"package db
+
+type Conn struct{}
+
+func (c *Conn) Rollback() error {
+    return nil
+}
+
+func (c *Conn) Less(i, j int) bool {
+    return i < j
+}
+
+func (c *Conn) Sort(xs []int) {
+    sort.Slice(xs, c.Less)
+}
+
+func cleanup(c *Conn) {
+    hooks := []func(*Conn) error{(*Conn).Rollback}
+    run(hooks, c)
+}
+"#;
+        let file = PathBuf::from("db/conn.go");
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let symbols = indexer
+            .extract_symbols(source, Language::Go, &file)
+            .unwrap();
+        let store = SymbolStore::new();
+        store.insert_symbols_unchecked(symbols.clone());
+
+        let functions: Vec<Symbol> = symbols
+            .iter()
+            .filter(|s| matches!(s.symbol_type, SymbolType::Function | SymbolType::Method))
+            .cloned()
+            .collect();
+        let mut graph = CallGraph::new();
+        graph.add_file(&store, &file, source, &functions);
+
+        let id = |name: &str| symbols.iter().find(|s| s.name == name).unwrap().id;
+        assert_eq!(graph.callees(&id("Sort")), &[id("Less")]);
+        assert_eq!(graph.callees(&id("cleanup")), &[id("Rollback")]);
+        assert_eq!(graph.callers()[&id("Rollback")], vec![id("cleanup")]);
+    }
+
+    #[test]
+    fn test_go_receiver() {
+        assert_eq!(go_receiver("s *Server"), ("s", "Server"));
+        assert_eq!(go_receiver("l List[T]"), ("l", "List"));
+        assert_eq!(go_receiver("*Server"), ("", "Server"));
+        assert!(is_pointer_receiver("s *Server"));
+        assert!(is_pointer_receiver("*Server"));
+        assert!(!is_pointer_receiver("l List[*T]"));
+    }
+}
@@ -0,0 +1,101 @@
+use std::collections::HashMap;
+use std::hash::Hash;
+
+/// Probability of following a reference rather than jumping to a random
+/// node, as in the original PageRank formulation
+pub const DAMPING: f64 = 0.85;
+
+const MAX_ITERATIONS: usize = 100;
+const TOLERANCE: f64 = 1e-9;
+
+/// PageRank of every node of a directed graph whose edges point from the
+/// referencing node to the referenced one. An edge listed several times
+/// carries that much more weight. Nodes without outgoing edges spread
+/// their rank over every node.
+///
+/// Scores are scaled so the average node scores 1.0, which keeps them
+/// readable however large the graph is.
+pub fn page_rank<N: Copy + Eq + Hash>(nodes: &[N], edges: &[(N, N)]) -> HashMap<N, f64> {
+    let mut index: HashMap<N, usize> = HashMap::new();
+    for node in nodes {
+        let next = index.len();
+        index.entry(*node).or_insert(next);
+    }
+    for (from, to) in edges {
+        for node in [from, to] {
+            let next = index.len();
+            index.entry(*node).or_insert(next);
+        }
+    }
+    let count = index.len();
+    if count == 0 {
+        return HashMap::new();
+    }
+
+    let mut out_degree = vec![0usize; count];
+    let mut incoming: Vec<Vec<usize>> = vec![Vec::new(); count];
+    for (from, to) in edges {
+        let (from, to) = (index[from], index[to]);
+        out_degree[from] += 1;
+        incoming[to].push(from);
+    }
+
+    let n = count as f64;
+    let mut rank = vec![1.0 / n; count];
+    for _ in 0..MAX_ITERATIONS {
+        let dangling: f64 = (0..count)
+            .filter(|node| out_degree[*node] == 0)
+            .map(|node| rank[node])
+            .sum();
+        let base = (1.0 - DAMPING) / n + DAMPING * dangling / n;
+        let next: Vec<f64> = incoming
+            .iter()
+            .map(|sources| {
+                base + DAMPING
+                    * sources
+                        .iter()
+                        .map(|source| rank[*source] / out_degree[*source] as f64)
+                        .sum::<f64>()
+            })
+            .collect();
+        let change: f64 = next.iter().zip(&rank).map(|(a, b)| (a - b).abs()).sum();
+        rank = next;
+        if change < TOLERANCE {
+            break;
+        }
+    }
+
+    index
+        .into_iter()
+        .map(|(node, position)| (node, rank[position] * n))
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_page_rank() {
+        // Handlers use the service, which runs queries; a helper is unused
+        let edges = [
+            ("GetUserHandler", "GetUser"),
+            ("ListUsersHandler", "ListUsers"),
+            ("GetUser", "ExecuteQuery"),
+            ("ListUsers", "ExecuteQuery"),
+            ("GetUser", "User"),
+            ("ListUsers", "User"),
+            ("ExecuteQuery", "QueryResult"),
+        ];
+        let scores = page_rank(&["formatName"], &edges);
+        assert_eq!(scores.len(), 8);
+
+        let total: f64 = scores.values().sum();
+        assert!((total - 8.0).abs() < 1e-6);
+        assert!(scores["QueryResult"] > scores["ExecuteQuery"]);
+        assert!(scores["ExecuteQuery"] > scores["GetUser"]);
+        assert!(scores["User"] > scores["GetUser"]);
+        assert!((scores["formatName"] - scores["GetUserHandler"]).abs() < 1e-9);
+        assert!(page_rank::<&str>(&[], &[]).is_empty());
+    }
+}
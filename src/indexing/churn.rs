@@ -0,0 +1,227 @@
+use crate::models::SymbolId;
+use crate::utils::{CommitDiff, FileDiff, Hunk};
+use std::collections::HashMap;
+use std::path::PathBuf;
+
+/// Lines `start..=end` of a file, 1-based
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct LineSpan {
+    pub start: u32,
+    pub end: u32,
+}
+
+/// Which of `commits` (indices, newest first as `git log` lists them)
+/// changed the lines of each symbol.
+///
+/// Spans are those of the working tree. They are first carried back
+/// through `worktree`, the changes since the newest commit, which do not
+/// count. Then each commit is checked against the spans as they were right
+/// after it, and the spans are carried back to its parent. A symbol's span
+/// is followed through renames and shrinks to the lines that existed
+/// before; it stops being followed at the commit that added it.
+pub fn symbol_churn(
+    spans: Vec<(SymbolId, PathBuf, LineSpan)>,
+    worktree: &[FileDiff],
+    commits: &[CommitDiff],
+) -> HashMap<SymbolId, Vec<usize>> {
+    let mut tracked: HashMap<PathBuf, Vec<(SymbolId, LineSpan)>> = HashMap::new();
+    for (id, file, span) in spans {
+        tracked.entry(file).or_default().push((id, span));
+    }
+
+    let mut churn: HashMap<SymbolId, Vec<usize>> = HashMap::new();
+    carry_back(&mut tracked, worktree, |_, _| {});
+    for (index, commit) in commits.iter().enumerate() {
+        if tracked.is_empty() {
+            break;
+        }
+        carry_back(&mut tracked, &commit.files, |id, _| {
+            churn.entry(id).or_default().push(index)
+        });
+    }
+    churn
+}
+
+/// Move `tracked` spans from after `files` changed to before, calling
+/// `touched` for each span the changes overlap
+fn carry_back(
+    tracked: &mut HashMap<PathBuf, Vec<(SymbolId, LineSpan)>>,
+    files: &[FileDiff],
+    mut touched: impl FnMut(SymbolId, LineSpan),
+) {
+    // Collected apart so files that swap names do not collide
+    let mut moved: Vec<(PathBuf, Vec<(SymbolId, LineSpan)>)> = Vec::new();
+    for file in files {
+        let Some(spans) = file
+            .new_path
+            .as_ref()
+            .and_then(|new_path| tracked.remove(new_path))
+        else {
+            continue;
+        };
+        let Some(old_path) = &file.old_path else {
+            // Added by this change: everything in it is new
+            for (id, span) in spans {
+                touched(id, span);
+            }
+            continue;
+        };
+        let mut before = Vec::new();
+        for (id, span) in spans {
+            if file.hunks.iter().any(|hunk| touches(span, hunk)) {
+                touched(id, span);
+            }
+            if let Some(span) = map_span(span, &file.hunks) {
+                before.push((id, span));
+            }
+        }
+        moved.push((old_path.clone(), before));
+    }
+    for (path, spans) in moved {
+        if !spans.is_empty() {
+            tracked.entry(path).or_default().extend(spans);
+        }
+    }
+}
+
+/// Whether the change replaced or inserted lines inside `span`, or deleted
+/// lines from between its first and last line
+fn touches(span: LineSpan, hunk: &Hunk) -> bool {
+    if hunk.new_count == 0 {
+        // Lines deleted right after new_start
+        return span.start <= hunk.new_start && hunk.new_start < span.end;
+    }
+    hunk.new_start <= span.end && hunk.new_start + hunk.new_count - 1 >= span.start
+}
+
+/// The lines `span` covered before `hunks` were applied. Lines the hunks
+/// added are dropped from the ends; `None` when nothing of it existed.
+fn map_span(span: LineSpan, hunks: &[Hunk]) -> Option<LineSpan> {
+    let start = map_line(span.start, hunks, true);
+    let end = map_line(span.end, hunks, false);
+    (start <= end && end > 0).then_some(LineSpan { start, end })
+}
+
+/// A line after the change as a line before it. A line the change added
+/// maps to the first (`at_start`) or last line it replaced, or next to
+/// where it was inserted.
+fn map_line(line: u32, hunks: &[Hunk], at_start: bool) -> u32 {
+    let mut shift: i64 = 0;
+    for hunk in hunks {
+        let before_line = if hunk.new_count == 0 {
+            hunk.new_start < line
+        } else {
+            hunk.new_start + hunk.new_count <= line
+        };
+        if before_line {
+            shift += hunk.old_count as i64 - hunk.new_count as i64;
+            continue;
+        }
+        if hunk.new_count > 0 && hunk.new_start <= line {
+            return match (at_start, hunk.old_count) {
+                (true, 0) => hunk.old_start + 1,
+                (true, _) => hunk.old_start,
+                (false, 0) => hunk.old_start,
+                (false, count) => hunk.old_start + count - 1,
+            };
+        }
+        break;
+    }
+    (line as i64 + shift).max(0) as u32
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::utils::CommitInfo;
+
+    fn hunk(old_start: u32, old_count: u32, new_start: u32, new_count: u32) -> Hunk {
+        Hunk {
+            old_start,
+            old_count,
+            new_start,
+            new_count,
+        }
+    }
+
+    fn commit(name: &str, files: Vec<FileDiff>) -> CommitDiff {
+        CommitDiff {
+            info: CommitInfo {
+                commit: name.to_string(),
+                author: String::new(),
+                date: String::new(),
+                summary: String::new(),
+            },
+            files,
+        }
+    }
+
+    fn diff(old: Option<&str>, new: &str, hunks: Vec<Hunk>) -> FileDiff {
+        FileDiff {
+            old_path: old.map(PathBuf::from),
+            new_path: Some(PathBuf::from(new)),
+            hunks,
+        }
+    }
+
+    #[test]
+    fn test_map_span() {
+        let span = LineSpan { start: 10, end: 20 };
+        // Two lines inserted above shift it up
+        assert_eq!(
+            map_span(span, &[hunk(3, 0, 4, 2)]),
+            Some(LineSpan { start: 8, end: 18 })
+        );
+        // A line deleted above shifts it down
+        assert_eq!(
+            map_span(span, &[hunk(5, 1, 4, 0)]),
+            Some(LineSpan { start: 11, end: 21 })
+        );
+        // Lines added at its end are dropped
+        assert_eq!(
+            map_span(span, &[hunk(17, 0, 18, 3)]),
+            Some(LineSpan { start: 10, end: 17 })
+        );
+        // Entirely added
+        assert_eq!(map_span(span, &[hunk(9, 0, 10, 11)]), None);
+    }
+
+    #[test]
+    fn test_symbol_churn() {
+        let open = SymbolId(1);
+        let close = SymbolId(2);
+        let spans = vec![
+            (open, PathBuf::from("db.go"), LineSpan { start: 3, end: 8 }),
+            (
+                close,
+                PathBuf::from("db.go"),
+                LineSpan { start: 10, end: 12 },
+            ),
+        ];
+        // Uncommitted: a comment added at the top
+        let worktree = vec![diff(Some("db.go"), "db.go", vec![hunk(1, 0, 2, 1)])];
+        let commits = vec![
+            // Newest: edits Close, now at 9..=11
+            commit(
+                "c3",
+                vec![diff(Some("db.go"), "db.go", vec![hunk(10, 1, 10, 1)])],
+            ),
+            // Renamed from conn.go, editing Open at 2..=7
+            commit(
+                "c2",
+                vec![diff(Some("conn.go"), "db.go", vec![hunk(4, 1, 4, 1)])],
+            ),
+            // Adds Close below Open
+            commit(
+                "c1",
+                vec![diff(Some("conn.go"), "conn.go", vec![hunk(7, 0, 8, 4)])],
+            ),
+            // Adds the file
+            commit("c0", vec![diff(None, "conn.go", vec![hunk(0, 0, 1, 7)])]),
+        ];
+
+        let churn = symbol_churn(spans, &worktree, &commits);
+        assert_eq!(churn[&open], vec![1, 3]);
+        assert_eq!(churn[&close], vec![0, 2]);
+    }
+}
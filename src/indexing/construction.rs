@@ -0,0 +1,141 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// A Go composite literal of a named type: `User{...}`, `&models.User{...}`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CompositeLiteral {
+    /// The type as written, e.g. `models.User` or `Page[User]`
+    pub type_text: String,
+    /// Position of the type's name, where the index records the reference
+    pub line: u32,
+    pub column: u32,
+    /// Whether the literal's address is taken: `&User{...}`
+    pub pointer: bool,
+    /// Fields set by name, in order
+    pub fields: Vec<String>,
+    /// Elements given without a field name, `User{"ann", 42}`
+    pub positional: usize,
+}
+
+/// Every composite literal of a named type in a Go source file. Literals of
+/// slice, map and array types are left out, and so are the elements they
+/// hold with the type elided (`[]User{{...}}`).
+pub fn find_composite_literals(
+    source: &str,
+) -> Result<Vec<CompositeLiteral>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut literals = Vec::new();
+    collect_literals(tree.root_node(), source, &mut literals);
+    Ok(literals)
+}
+
+fn collect_literals(node: Node, source: &str, literals: &mut Vec<CompositeLiteral>) {
+    if node.kind() == "composite_literal" {
+        if let Some(literal) = composite_literal(node, source) {
+            literals.push(literal);
+        }
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_literals(child, source, literals);
+    }
+}
+
+fn composite_literal(node: Node, source: &str) -> Option<CompositeLiteral> {
+    let literal_type = node.child_by_field_name("type")?;
+    let name = type_name(literal_type)?;
+    let position = name.start_position();
+    let pointer = node.parent().is_some_and(|parent| {
+        parent.kind() == "unary_expression"
+            && parent
+                .child_by_field_name("operator")
+                .and_then(|operator| operator.utf8_text(source.as_bytes()).ok())
+                == Some("&")
+    });
+
+    let (mut fields, mut positional) = (Vec::new(), 0);
+    if let Some(body) = node.child_by_field_name("body") {
+        let mut cursor = body.walk();
+        for element in body.named_children(&mut cursor) {
+            match element.kind() {
+                "keyed_element" => {
+                    let key = element
+                        .child_by_field_name("key")
+                        .or_else(|| element.named_child(0))
+                        .and_then(|key| key.utf8_text(source.as_bytes()).ok());
+                    if let Some(key) = key {
+                        fields.push(key.to_string());
+                    }
+                }
+                "literal_element" => positional += 1,
+                _ => {}
+            }
+        }
+    }
+
+    Some(CompositeLiteral {
+        type_text: literal_type.utf8_text(source.as_bytes()).ok()?.to_string(),
+        line: position.row as u32 + 1,
+        column: position.column as u32,
+        pointer,
+        fields,
+        positional,
+    })
+}
+
+/// The name node of a named literal type: `User` of `models.User` and of
+/// `Page[User]`; none for slices, maps, arrays and struct literals
+fn type_name(literal_type: Node) -> Option<Node> {
+    match literal_type.kind() {
+        "type_identifier" => Some(literal_type),
+        "qualified_type" => literal_type.child_by_field_name("name"),
+        "generic_type" => type_name(literal_type.child_by_field_name("type")?),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_composite_literals() {
+        let source = r#"package users
+
+func GetUser(id string) *User {
+    roles := []Role{{Name: "admin"}}
+    _ = models.Address{"Main St", 1}
+    return &User{
+        ID:    id,
+        Roles: roles,
+    }
+}
+"#;
+        let literals = find_composite_literals(source).unwrap();
+        assert_eq!(
+            literals,
+            vec![
+                CompositeLiteral {
+                    type_text: "models.Address".to_string(),
+                    line: 5,
+                    column: 15,
+                    pointer: false,
+                    fields: Vec::new(),
+                    positional: 2,
+                },
+                CompositeLiteral {
+                    type_text: "User".to_string(),
+                    line: 6,
+                    column: 12,
+                    pointer: true,
+                    fields: vec!["ID".to_string(), "Roles".to_string()],
+                    positional: 0,
+                },
+            ]
+        );
+    }
+}
@@ -0,0 +1,281 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use tree_sitter::{Node, Parser};
+
+/// Calls that make a context with no deadline and no cancellation
+const FRESH_CONTEXTS: &[&str] = &["context.Background", "context.TODO"];
+
+/// A call in a function that accepts a `context.Context` which passes a
+/// fresh context instead, so the caller's cancellation and deadline are
+/// lost
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FreshContextCall {
+    /// The enclosing function or method
+    pub function: String,
+    /// Name of the function's context parameter
+    pub context_param: String,
+    /// The called function as written: `s.db.ExecuteQuery`
+    pub callee: String,
+    /// The argument as written: `context.Background()` or a variable
+    pub argument: String,
+    /// Where the fresh context comes from: `context.Background()` or
+    /// `context.TODO()`
+    pub origin: String,
+    pub line: u32,
+    pub column: u32,
+    /// The call runs in a goroutine started by the function
+    pub in_goroutine: bool,
+}
+
+/// Find calls in Go functions with a `context.Context` parameter that pass
+/// `context.Background()` or `context.TODO()` instead, directly, derived
+/// through `context.With*` or through a local variable holding one.
+pub fn find_fresh_context_calls(
+    source: &str,
+) -> Result<Vec<FreshContextCall>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut calls = Vec::new();
+    let root = tree.root_node();
+    let mut cursor = root.walk();
+    for function in root.named_children(&mut cursor) {
+        if !matches!(
+            function.kind(),
+            "function_declaration" | "method_declaration"
+        ) {
+            continue;
+        }
+        let (Some(name), Some(body)) = (
+            function
+                .child_by_field_name("name")
+                .and_then(|name| text(name, source)),
+            function.child_by_field_name("body"),
+        ) else {
+            continue;
+        };
+        let Some(context_param) = function
+            .child_by_field_name("parameters")
+            .and_then(|parameters| context_parameter(parameters, source))
+        else {
+            continue;
+        };
+        let mut scan = Scan {
+            source,
+            function: name,
+            context_param,
+            fresh: HashMap::new(),
+            calls: &mut calls,
+        };
+        scan.visit(body, false);
+    }
+    Ok(calls)
+}
+
+/// The name of the first `context.Context` parameter
+fn context_parameter(parameters: Node, source: &str) -> Option<String> {
+    let mut cursor = parameters.walk();
+    let parameter = parameters.named_children(&mut cursor).find(|parameter| {
+        parameter
+            .child_by_field_name("type")
+            .and_then(|node| text(node, source))
+            .as_deref()
+            == Some("context.Context")
+    })?;
+    let mut names = parameter.walk();
+    let name = parameter
+        .children_by_field_name("name", &mut names)
+        .next()
+        .and_then(|name| text(name, source))
+        .unwrap_or_else(|| "_".to_string());
+    Some(name)
+}
+
+struct Scan<'a> {
+    source: &'a str,
+    function: String,
+    context_param: String,
+    /// Local variables currently holding a fresh context, with its origin
+    fresh: HashMap<String, String>,
+    calls: &'a mut Vec<FreshContextCall>,
+}
+
+impl Scan<'_> {
+    fn visit(&mut self, node: Node, in_goroutine: bool) {
+        match node.kind() {
+            "short_var_declaration" | "assignment_statement" => {
+                if let Some(right) = node.child_by_field_name("right") {
+                    self.visit(right, in_goroutine);
+                }
+                self.assign(node);
+                return;
+            }
+            "call_expression" => self.check_call(node, in_goroutine),
+            _ => {}
+        }
+        let in_goroutine = in_goroutine || node.kind() == "go_statement";
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.named_children(&mut cursor).collect();
+        for child in children {
+            self.visit(child, in_goroutine);
+        }
+    }
+
+    /// Track variables assigned a fresh context. Only the first value of
+    /// `ctx, cancel := context.WithTimeout(...)` is a context.
+    fn assign(&mut self, node: Node) {
+        let (Some(left), Some(right)) = (
+            node.child_by_field_name("left"),
+            node.child_by_field_name("right"),
+        ) else {
+            return;
+        };
+        let mut cursor = left.walk();
+        let names: Vec<Node> = left.named_children(&mut cursor).collect();
+        let mut cursor = right.walk();
+        let values: Vec<Node> = right.named_children(&mut cursor).collect();
+        for (index, name) in names.iter().enumerate() {
+            let Some(name) = text(*name, self.source) else {
+                continue;
+            };
+            let value = match values.len() {
+                1 if index == 0 => values.first(),
+                1 => None,
+                _ => values.get(index),
+            };
+            match value.and_then(|value| self.origin(*value)) {
+                Some(origin) => {
+                    self.fresh.insert(name, origin);
+                }
+                None => {
+                    self.fresh.remove(&name);
+                }
+            }
+        }
+    }
+
+    /// Where the fresh context an expression evaluates to comes from, if it
+    /// is one
+    fn origin(&self, node: Node) -> Option<String> {
+        match node.kind() {
+            "parenthesized_expression" => self.origin(node.named_child(0)?),
+            "identifier" => self.fresh.get(&text(node, self.source)?).cloned(),
+            "call_expression" => {
+                let callee = text(node.child_by_field_name("function")?, self.source)?;
+                if FRESH_CONTEXTS.contains(&callee.as_str()) {
+                    return Some(format!("{}()", callee));
+                }
+                // context.WithTimeout(context.Background(), d) is as fresh
+                if callee.starts_with("context.With") {
+                    let arguments = node.child_by_field_name("arguments")?;
+                    return self.origin(arguments.named_child(0)?);
+                }
+                None
+            }
+            _ => None,
+        }
+    }
+
+    fn check_call(&mut self, call: Node, in_goroutine: bool) {
+        let Some(callee) = call
+            .child_by_field_name("function")
+            .and_then(|function| text(function, self.source))
+        else {
+            return;
+        };
+        // Deriving from a fresh context is reported where it is passed on
+        if callee.starts_with("context.") {
+            return;
+        }
+        let Some(arguments) = call.child_by_field_name("arguments") else {
+            return;
+        };
+        let mut cursor = arguments.walk();
+        for argument in arguments.named_children(&mut cursor) {
+            let Some(origin) = self.origin(argument) else {
+                continue;
+            };
+            let start = argument.start_position();
+            self.calls.push(FreshContextCall {
+                function: self.function.clone(),
+                context_param: self.context_param.clone(),
+                callee: callee.clone(),
+                argument: text(argument, self.source).unwrap_or_default(),
+                origin,
+                line: start.row as u32 + 1,
+                column: start.column as u32,
+                in_goroutine,
+            });
+        }
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_fresh_context_calls() {
+        let source = r#"package users
+
+func (s *UserService) GetUser(ctx context.Context, id string) (*User, error) {
+    row, err := s.db.ExecuteQuery(context.Background(), "SELECT", id)
+    tctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+    defer cancel()
+    s.cache.Get(tctx, id)
+    tctx = ctx
+    s.cache.Set(tctx, id, row)
+    go s.audit.Record(context.Background(), id)
+    return s.load(ctx, id)
+}
+
+func Warm(id string) {
+    load(context.Background(), id)
+}
+"#;
+        let calls = find_fresh_context_calls(source).unwrap();
+        let found: Vec<(&str, &str, &str, u32, bool)> = calls
+            .iter()
+            .map(|c| {
+                (
+                    c.callee.as_str(),
+                    c.argument.as_str(),
+                    c.origin.as_str(),
+                    c.line,
+                    c.in_goroutine,
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (
+                    "s.db.ExecuteQuery",
+                    "context.Background()",
+                    "context.Background()",
+                    4,
+                    false
+                ),
+                ("s.cache.Get", "tctx", "context.TODO()", 7, false),
+                (
+                    "s.audit.Record",
+                    "context.Background()",
+                    "context.Background()",
+                    10,
+                    true
+                ),
+            ]
+        );
+        assert!(calls
+            .iter()
+            .all(|c| c.function == "GetUser" && c.context_param == "ctx"));
+    }
+}
@@ -0,0 +1,155 @@
+use crate::indexing::symbol_analysis::find_definition;
+use crate::models::{Language, Location, SymbolType};
+use tree_sitter::{Node, Parser};
+
+/// The declaration of the definition at `location` without its body: all
+/// lines of a function's signature, or a type's header with its fields or
+/// members collapsed to `{ ... }`. `None` for kinds without a body to drop,
+/// such as constants, whose full source is already short.
+pub fn declaration_text(
+    source: &str,
+    language: Language,
+    location: &Location,
+    symbol_type: &SymbolType,
+) -> Option<String> {
+    let collapse = match symbol_type {
+        SymbolType::Function | SymbolType::Method | SymbolType::Test => false,
+        SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum => true,
+        _ => return None,
+    };
+
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language()).ok()?;
+    let tree = parser.parse(source, None)?;
+    let definition = find_definition(tree.root_node(), location)?;
+    let body = if collapse {
+        type_body(definition)?
+    } else {
+        function_body(definition)?
+    };
+
+    // Whole lines, so `type`, modifiers and indentation before the name stay
+    let line_start = source[..definition.start_byte()]
+        .rfind('\n')
+        .map_or(0, |i| i + 1);
+    let header = source.get(line_start..body.start_byte())?.trim_end();
+    if !collapse {
+        return Some(header.to_string());
+    }
+    let placeholder = if source[body.start_byte()..].starts_with('{') {
+        "{ ... }"
+    } else {
+        // Python `class Repo(Base):` bodies have no braces
+        "..."
+    };
+    Some(format!("{} {}", header, placeholder))
+}
+
+fn function_body(definition: Node) -> Option<Node> {
+    if let Some(body) = definition.child_by_field_name("body") {
+        return Some(body);
+    }
+    // Go closures bound to variables: `var handler = func(...) {...}`
+    if let Some(value) = definition.child_by_field_name("value") {
+        if let Some(body) = value
+            .named_child(0)
+            .and_then(|function| function.child_by_field_name("body"))
+        {
+            return Some(body);
+        }
+    }
+    let mut cursor = definition.walk();
+    let body = definition.named_children(&mut cursor).find(|child| {
+        matches!(
+            child.kind(),
+            "function_body" | "block" | "compound_statement"
+        )
+    });
+    body
+}
+
+fn type_body(definition: Node) -> Option<Node> {
+    if let Some(body) = definition.child_by_field_name("body") {
+        return Some(body);
+    }
+    // Go `type_spec`: the field list of a struct, or an interface's braces
+    let underlying = definition.child_by_field_name("type")?;
+    let mut cursor = underlying.walk();
+    let body = underlying
+        .children(&mut cursor)
+        .find(|child| matches!(child.kind(), "field_declaration_list" | "{"));
+    body
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    fn location(start: (u32, u32), end: (u32, u32)) -> Location {
+        Location::new(PathBuf::from("user.go"), start.0, start.1, end.0, end.1)
+    }
+
+    #[test]
+    fn test_go_declarations() {
+        let source = r#"package users
+
+type UserService struct {
+    repo  Repository
+    clock Clock
+}
+
+type Repository interface {
+    Get(id string) (*User, error)
+}
+
+func (s *UserService) CreateUser(
+    ctx context.Context,
+    username, email string,
+) (*User, error) {
+    return nil, nil
+}
+
+const MaxUsers = 100
+"#;
+        assert_eq!(
+            declaration_text(
+                source,
+                Language::Go,
+                &location((3, 5), (6, 1)),
+                &SymbolType::Class
+            )
+            .unwrap(),
+            "type UserService struct { ... }"
+        );
+        assert_eq!(
+            declaration_text(
+                source,
+                Language::Go,
+                &location((8, 5), (10, 1)),
+                &SymbolType::Class
+            )
+            .unwrap(),
+            "type Repository interface { ... }"
+        );
+        assert_eq!(
+            declaration_text(
+                source,
+                Language::Go,
+                &location((12, 0), (17, 1)),
+                &SymbolType::Method
+            )
+            .unwrap(),
+            "func (s *UserService) CreateUser(\n    ctx context.Context,\n    username, email string,\n) (*User, error)"
+        );
+        assert_eq!(
+            declaration_text(
+                source,
+                Language::Go,
+                &location((19, 6), (19, 20)),
+                &SymbolType::Constant
+            ),
+            None
+        );
+    }
+}
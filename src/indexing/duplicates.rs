@@ -0,0 +1,241 @@
+use crate::models::Language;
+use std::collections::hash_map::DefaultHasher;
+use std::collections::{HashMap, HashSet};
+use std::hash::{Hash, Hasher};
+use tree_sitter::{Node, Parser, Point};
+
+/// Length of the token runs compared between bodies
+const SHINGLE_SIZE: usize = 4;
+
+/// A function body reduced to its shape: identifiers and literals are
+/// replaced by placeholders, comments and whitespace dropped, so renamed
+/// copies compare equal
+#[derive(Debug, Clone)]
+pub struct BodyFingerprint {
+    pub tokens: usize,
+    /// Hash of the whole normalized token sequence
+    pub hash: u64,
+    shingles: HashSet<u64>,
+}
+
+impl BodyFingerprint {
+    pub fn from_tokens(tokens: &[String]) -> Self {
+        let shingles = if tokens.len() < SHINGLE_SIZE {
+            HashSet::from([hash_of(tokens)])
+        } else {
+            tokens.windows(SHINGLE_SIZE).map(hash_of).collect()
+        };
+        Self {
+            tokens: tokens.len(),
+            hash: hash_of(tokens),
+            shingles,
+        }
+    }
+
+    /// 1.0 for identical normalized bodies, otherwise the share of token
+    /// runs the two bodies have in common
+    pub fn similarity(&self, other: &Self) -> f64 {
+        if self.hash == other.hash && self.tokens == other.tokens {
+            return 1.0;
+        }
+        let shared = self.shingles.intersection(&other.shingles).count();
+        let total = self.shingles.len() + other.shingles.len() - shared;
+        if total == 0 {
+            0.0
+        } else {
+            shared as f64 / total as f64
+        }
+    }
+}
+
+fn hash_of(tokens: &[String]) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    tokens.hash(&mut hasher);
+    hasher.finish()
+}
+
+/// Group fingerprints whose similarity reaches `min_similarity`, directly
+/// or through other members. Returns each group of two or more indexes
+/// with the lowest similarity between any two of its members.
+pub fn cluster(fingerprints: &[BodyFingerprint], min_similarity: f64) -> Vec<(Vec<usize>, f64)> {
+    let mut order: Vec<usize> = (0..fingerprints.len()).collect();
+    order.sort_by_key(|&i| fingerprints[i].shingles.len());
+
+    let mut parent: Vec<usize> = (0..fingerprints.len()).collect();
+    fn find(parent: &mut [usize], i: usize) -> usize {
+        let mut root = i;
+        while parent[root] != root {
+            root = parent[root];
+        }
+        let mut node = i;
+        while parent[node] != root {
+            let next = parent[node];
+            parent[node] = root;
+            node = next;
+        }
+        root
+    }
+
+    for (position, &a) in order.iter().enumerate() {
+        let smaller = fingerprints[a].shingles.len() as f64;
+        for &b in &order[position + 1..] {
+            // Shared runs cannot exceed the smaller set, which bounds the score
+            if smaller < min_similarity * fingerprints[b].shingles.len() as f64 {
+                break;
+            }
+            if fingerprints[a].similarity(&fingerprints[b]) >= min_similarity {
+                let (root_a, root_b) = (find(&mut parent, a), find(&mut parent, b));
+                parent[root_a] = root_b;
+            }
+        }
+    }
+
+    let mut groups: HashMap<usize, Vec<usize>> = HashMap::new();
+    for i in 0..fingerprints.len() {
+        let root = find(&mut parent, i);
+        groups.entry(root).or_default().push(i);
+    }
+    let mut clusters: Vec<(Vec<usize>, f64)> = groups
+        .into_values()
+        .filter(|members| members.len() > 1)
+        .map(|members| {
+            let mut lowest = 1.0f64;
+            for (position, &a) in members.iter().enumerate() {
+                for &b in &members[position + 1..] {
+                    lowest = lowest.min(fingerprints[a].similarity(&fingerprints[b]));
+                }
+            }
+            (members, lowest)
+        })
+        .collect();
+    clusters.sort_by(|a, b| a.0.cmp(&b.0));
+    clusters
+}
+
+/// Normalized body tokens of the definitions starting at each position
+/// (1-based line, 0-based byte column), as recorded in symbol locations
+pub fn body_tokens(
+    source: &str,
+    language: Language,
+    starts: &[(u32, u32)],
+) -> Result<HashMap<(u32, u32), Vec<String>>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+
+    let mut bodies = HashMap::new();
+    for &(line, column) in starts {
+        let point = Point {
+            row: line.saturating_sub(1) as usize,
+            column: column as usize,
+        };
+        let Some(mut definition) = root.descendant_for_point_range(point, point) else {
+            continue;
+        };
+        // The outermost node starting here is the whole definition
+        while let Some(parent) = definition.parent() {
+            if parent.start_position() != point || parent.id() == root.id() {
+                break;
+            }
+            definition = parent;
+        }
+        let body = definition.child_by_field_name("body").unwrap_or(definition);
+
+        let mut tokens = Vec::new();
+        collect_tokens(body, &mut tokens);
+        bodies.insert((line, column), tokens);
+    }
+    Ok(bodies)
+}
+
+fn collect_tokens(node: Node, tokens: &mut Vec<String>) {
+    let kind = node.kind();
+    if kind.contains("comment") {
+        return;
+    }
+    // String literals have content and escape children; one placeholder
+    // stands for the whole literal
+    if node.is_named()
+        && (kind.contains("string") || kind.contains("char") || kind == "rune_literal")
+    {
+        tokens.push("$str".to_string());
+        return;
+    }
+    if node.child_count() == 0 {
+        let token = if !node.is_named() {
+            // Keywords and punctuation are kept as written
+            kind.to_string()
+        } else if kind.contains("identifier") {
+            "$id".to_string()
+        } else if kind.contains("int") || kind.contains("float") || kind.contains("number") {
+            "$num".to_string()
+        } else {
+            kind.to_string()
+        };
+        tokens.push(token);
+        return;
+    }
+    let mut cursor = node.walk();
+    for child in node.children(&mut cursor) {
+        collect_tokens(child, tokens);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn tokens(text: &str) -> Vec<String> {
+        text.split_whitespace().map(str::to_string).collect()
+    }
+
+    #[test]
+    fn test_cluster_similar_bodies() {
+        let body = "{ $id , $id := $id . $id ( $id ) if $id != nil { return nil , $id } return $id . $id ( $id , $id ) }";
+        let fingerprints = vec![
+            BodyFingerprint::from_tokens(&tokens(body)),
+            BodyFingerprint::from_tokens(&tokens(
+                "{ for $id := range $id { $id = append ( $id , $id ) } return $id }",
+            )),
+            BodyFingerprint::from_tokens(&tokens(body)),
+            // The same body with one more statement at the end
+            BodyFingerprint::from_tokens(&tokens(&body.replace(
+                "return $id . $id ( $id , $id ) }",
+                "return $id . $id ( $id , $id ) $id ( ) }",
+            ))),
+        ];
+
+        assert_eq!(fingerprints[0].similarity(&fingerprints[2]), 1.0);
+        let near = fingerprints[0].similarity(&fingerprints[3]);
+        assert!(near > 0.8 && near < 1.0, "similarity {}", near);
+        assert!(fingerprints[0].similarity(&fingerprints[1]) < 0.3);
+
+        let exact = cluster(&fingerprints, 1.0);
+        assert_eq!(exact, vec![(vec![0, 2], 1.0)]);
+
+        let similar = cluster(&fingerprints, 0.8);
+        assert_eq!(similar.len(), 1);
+        assert_eq!(similar[0].0, vec![0, 2, 3]);
+        assert_eq!(similar[0].1, near);
+    }
+
+    #[test]
+    fn test_body_tokens_ignore_names() {
+        let source = r#"package db
+
+func (t *Tx) ExecuteQuery(ctx context.Context, query string) (*Result, error) {
+    // delegate to the connection
+    return t.conn.ExecuteQuery(ctx, query)
+}
+
+func (s *Session) ExecuteQuery(c context.Context, q string) (*Result, error) {
+    return s.db.ExecuteQuery(c, q)
+}
+"#;
+        let bodies = body_tokens(source, Language::Go, &[(3, 0), (8, 0)]).unwrap();
+        assert_eq!(bodies[&(3, 0)], bodies[&(8, 0)]);
+        assert_eq!(bodies[&(3, 0)][0], "{");
+        assert!(bodies[&(3, 0)].contains(&"return".to_string()));
+    }
+}
@@ -0,0 +1,239 @@
+use crate::indexing::symbol_analysis::is_error_sentinel;
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Calls that wrap the errors passed to them, so returning the call also
+/// returns its arguments
+const WRAPPING_CALLS: &[&str] = &["fmt.Errorf", "errors.Join"];
+
+/// A package-level `error` var: `var ErrNotFound = errors.New("not found")`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ErrorSentinel {
+    pub name: String,
+    pub line: u32,
+    /// The message given to `errors.New` or `fmt.Errorf`, when literal
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+}
+
+/// A name returned from a function, possibly an error sentinel
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReturnedName {
+    /// As written, qualifier included: `ErrNotFound`, `store.ErrNotFound`
+    pub name: String,
+    pub line: u32,
+    /// Returned inside `fmt.Errorf("...: %w", ErrNotFound)` or `errors.Join`
+    pub wrapped: bool,
+}
+
+/// Parse a Go file and find its error sentinels and the names it returns
+pub fn find_go_errors(
+    source: &str,
+) -> Result<(Vec<ErrorSentinel>, Vec<ReturnedName>), Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+    Ok((error_sentinels(root, source), returned_names(root, source)))
+}
+
+/// Package-level vars that hold errors: declared `error`, initialized with
+/// `errors.New`/`fmt.Errorf`, or named by the `ErrXxx` convention
+pub fn error_sentinels(root: Node, source: &str) -> Vec<ErrorSentinel> {
+    let mut sentinels = Vec::new();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if declaration.kind() != "var_declaration" {
+            continue;
+        }
+        let mut specs = Vec::new();
+        collect_var_specs(declaration, &mut specs);
+        for spec in specs {
+            let declared_error = spec
+                .child_by_field_name("type")
+                .and_then(|type_node| type_node.utf8_text(source.as_bytes()).ok())
+                == Some("error");
+            let mut names_cursor = spec.walk();
+            let names: Vec<Node> = spec
+                .children_by_field_name("name", &mut names_cursor)
+                .collect();
+            let values: Vec<Node> = match spec.child_by_field_name("value") {
+                Some(list) => {
+                    let mut values_cursor = list.walk();
+                    let values = list.named_children(&mut values_cursor).collect();
+                    values
+                }
+                None => Vec::new(),
+            };
+
+            for (index, name_node) in names.iter().enumerate() {
+                let Ok(name) = name_node.utf8_text(source.as_bytes()) else {
+                    continue;
+                };
+                let constructor = values
+                    .get(index)
+                    .filter(|value| value.kind() == "call_expression")
+                    .filter(|value| {
+                        value
+                            .child_by_field_name("function")
+                            .and_then(|function| function.utf8_text(source.as_bytes()).ok())
+                            .is_some_and(|callee| matches!(callee, "errors.New" | "fmt.Errorf"))
+                    });
+                if name == "_"
+                    || !(declared_error || constructor.is_some() || is_error_sentinel(name))
+                {
+                    continue;
+                }
+                sentinels.push(ErrorSentinel {
+                    name: name.to_string(),
+                    line: name_node.start_position().row as u32 + 1,
+                    message: constructor.and_then(|call| message(*call, source)),
+                });
+            }
+        }
+    }
+    sentinels
+}
+
+/// `var x = 1` and the specs of `var ( ... )` blocks
+fn collect_var_specs<'t>(node: Node<'t>, specs: &mut Vec<Node<'t>>) {
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        match child.kind() {
+            "var_spec" => specs.push(child),
+            "var_spec_list" => collect_var_specs(child, specs),
+            _ => {}
+        }
+    }
+}
+
+/// The first argument of a constructor call when it is a string literal
+fn message(call: Node, source: &str) -> Option<String> {
+    let arguments = call.child_by_field_name("arguments")?;
+    let first = arguments.named_child(0)?;
+    if !matches!(
+        first.kind(),
+        "interpreted_string_literal" | "raw_string_literal"
+    ) {
+        return None;
+    }
+    let text = first.utf8_text(source.as_bytes()).ok()?;
+    Some(text.trim_matches(|c| c == '"' || c == '`').to_string())
+}
+
+/// Identifiers and qualified names returned by `return` statements, as a
+/// whole value or wrapped by `fmt.Errorf`/`errors.Join`
+pub fn returned_names(root: Node, source: &str) -> Vec<ReturnedName> {
+    let mut returned = Vec::new();
+    let mut stack = vec![root];
+    while let Some(node) = stack.pop() {
+        if node.kind() == "return_statement" {
+            let mut cursor = node.walk();
+            for value in node.named_children(&mut cursor) {
+                let mut values_cursor = value.walk();
+                let values: Vec<Node> = if value.kind() == "expression_list" {
+                    value.named_children(&mut values_cursor).collect()
+                } else {
+                    vec![value]
+                };
+                for value in values {
+                    collect_returned(value, source, false, &mut returned);
+                }
+            }
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.named_children(&mut cursor));
+    }
+    returned.sort_by_key(|name| name.line);
+    returned
+}
+
+fn collect_returned(value: Node, source: &str, wrapped: bool, returned: &mut Vec<ReturnedName>) {
+    match value.kind() {
+        "identifier" | "selector_expression" => {
+            if let Ok(name) = value.utf8_text(source.as_bytes()) {
+                if name != "nil" {
+                    returned.push(ReturnedName {
+                        name: name.to_string(),
+                        line: value.start_position().row as u32 + 1,
+                        wrapped,
+                    });
+                }
+            }
+        }
+        "call_expression" => {
+            let wraps = value
+                .child_by_field_name("function")
+                .and_then(|function| function.utf8_text(source.as_bytes()).ok())
+                .is_some_and(|callee| WRAPPING_CALLS.contains(&callee));
+            if !wraps {
+                return;
+            }
+            if let Some(arguments) = value.child_by_field_name("arguments") {
+                let mut cursor = arguments.walk();
+                for argument in arguments.named_children(&mut cursor) {
+                    collect_returned(argument, source, true, returned);
+                }
+            }
+        }
+        _ => {}
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_go_errors() {
+        let source = r#"package users
+
+var (
+    ErrUserNotFound     = errors.New("user not found")
+    ErrValidationFailed = errors.New("validation failed")
+    errTimeout          = fmt.Errorf("timed out after %d", 30)
+    ErrLegacy           error
+    MaxUsers            = 100
+)
+
+func (s *Service) Get(id string) (*User, error) {
+    if id == "" {
+        return nil, fmt.Errorf("get %q: %w", id, ErrUserNotFound)
+    }
+    if s.closed {
+        return nil, store.ErrClosed
+    }
+    return nil, ErrUserNotFound
+}
+"#;
+        let (sentinels, returned) = find_go_errors(source).unwrap();
+        let names: Vec<(&str, u32, Option<&str>)> = sentinels
+            .iter()
+            .map(|s| (s.name.as_str(), s.line, s.message.as_deref()))
+            .collect();
+        assert_eq!(
+            names,
+            vec![
+                ("ErrUserNotFound", 4, Some("user not found")),
+                ("ErrValidationFailed", 5, Some("validation failed")),
+                ("errTimeout", 6, Some("timed out after %d")),
+                ("ErrLegacy", 7, None),
+            ]
+        );
+
+        let returned: Vec<(&str, u32, bool)> = returned
+            .iter()
+            .map(|r| (r.name.as_str(), r.line, r.wrapped))
+            .collect();
+        assert_eq!(
+            returned,
+            vec![
+                ("id", 13, true),
+                ("ErrUserNotFound", 13, true),
+                ("store.ErrClosed", 16, false),
+                ("ErrUserNotFound", 18, false),
+            ]
+        );
+    }
+}
@@ -0,0 +1,398 @@
+use crate::indexing::indexer::SymbolIndexer;
+use crate::indexing::language_priority::LanguagePriority;
+use crate::indexing::routes::RoutePatterns;
+use crate::indexing::tags::TagKeys;
+use crate::models::{Language, Reference, Symbol};
+use crate::utils::error::CodeAnalysisError;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+/// Symbols and references extracted from one file
+#[derive(Debug, Clone, Default)]
+pub struct FrontendOutput {
+    pub symbols: Vec<Symbol>,
+    pub references: Vec<Reference>,
+}
+
+/// Settings the pipeline passes to every frontend
+#[derive(Debug, Clone, Default)]
+pub struct FrontendConfig {
+    /// Doc comment `@key: value` tags to record on symbols
+    pub tag_keys: TagKeys,
+    /// Longest a single file may take to parse; frontends that can stop
+    /// early should fail the file with `CodeAnalysisError::ParseTimeout`
+    pub parse_timeout: Option<Duration>,
+    /// Router calls whose routes are extracted as route symbols
+    pub route_patterns: RoutePatterns,
+    /// Leave type arguments, `User` of `List[User]`, out of the references
+    pub skip_type_arguments: bool,
+}
+
+/// Turns the bytes of a source file into symbols and references.
+///
+/// Frontends are registered with a [`FrontendRegistry`] for a set of file
+/// extensions; the built-in languages are [`TreeSitterFrontend`]s. The output
+/// must follow the same conventions as the built-in frontends so search,
+/// outlines and definition lookups work unchanged:
+///
+/// - Positions: `start_line`/`end_line` are 1-based, `start_column`/
+///   `end_column` are 0-based byte offsets within the line, and the end is
+///   exclusive. A symbol's location spans its whole definition (not just the
+///   name), so source extraction returns the full body.
+/// - Identity: `Symbol::id` is built with `SymbolId::new(file, start_line,
+///   start_column)` so ids are stable across rebuilds.
+/// - Kinds: use the closest existing [`crate::models::SymbolType`]; functions
+///   attached to a type are `Method`, type-like declarations are `Class`,
+///   `Struct`, `Interface` or `Enum`, and `Module` is for namespaces or files
+///   acting as one. `namespace` is the package or module the symbol belongs to.
+/// - References: each reference's location spans exactly the referenced name.
+///   The pipeline links references to symbols by that name, so
+///   `target_symbol` may be left as a placeholder.
+///
+/// Returning an error marks the file as failed in `get_index_diagnostics`
+/// without stopping the build.
+pub trait LanguageFrontend: Send {
+    /// Language name recorded for code search, e.g. `go`
+    fn name(&self) -> &str;
+
+    /// File extensions handled, without the leading dot
+    fn extensions(&self) -> Vec<String>;
+
+    /// The built-in language this frontend serves, if any, so per-language
+    /// settings such as ROBERTO_INDEX_KINDS apply to it
+    fn language(&self) -> Option<Language> {
+        None
+    }
+
+    /// Apply pipeline-wide settings; frontends that don't use them can ignore this
+    fn configure(&mut self, _config: &FrontendConfig) {}
+
+    fn parse(
+        &mut self,
+        source: &[u8],
+        file_path: &Path,
+    ) -> Result<FrontendOutput, Box<dyn std::error::Error>>;
+}
+
+/// A built-in language parsed with tree-sitter grammars and queries
+pub struct TreeSitterFrontend {
+    language: Language,
+    indexer: SymbolIndexer,
+}
+
+impl TreeSitterFrontend {
+    pub fn new(language: Language) -> Result<Self, Box<dyn std::error::Error>> {
+        Ok(Self {
+            language,
+            indexer: SymbolIndexer::with_languages(&[language])?,
+        })
+    }
+}
+
+impl TreeSitterFrontend {
+    fn extract(
+        &mut self,
+        source: &str,
+        file_path: &PathBuf,
+    ) -> Result<FrontendOutput, Box<dyn std::error::Error>> {
+        let symbols = self
+            .indexer
+            .extract_symbols(source, self.language, file_path)?;
+
+        // Symbols are still useful when the reference pass fails, unless
+        // the file ran out of time, which skips it as a whole
+        let references = match self
+            .indexer
+            .extract_references(source, self.language, file_path)
+        {
+            Ok(references) => references,
+            Err(e)
+                if e.downcast_ref::<CodeAnalysisError>()
+                    .is_some_and(|e| matches!(e, CodeAnalysisError::ParseTimeout { .. })) =>
+            {
+                return Err(e)
+            }
+            Err(e) => {
+                tracing::debug!("Reference extraction failed for {:?}: {}", file_path, e);
+                Vec::new()
+            }
+        };
+
+        Ok(FrontendOutput {
+            symbols,
+            references,
+        })
+    }
+}
+
+impl LanguageFrontend for TreeSitterFrontend {
+    fn name(&self) -> &str {
+        self.language.as_str()
+    }
+
+    fn extensions(&self) -> Vec<String> {
+        self.language
+            .file_extensions()
+            .iter()
+            .map(|ext| ext.to_string())
+            .collect()
+    }
+
+    fn language(&self) -> Option<Language> {
+        Some(self.language)
+    }
+
+    fn configure(&mut self, config: &FrontendConfig) {
+        self.indexer.set_tag_keys(config.tag_keys.clone());
+        self.indexer.set_parse_timeout(config.parse_timeout);
+        self.indexer
+            .set_route_patterns(config.route_patterns.clone());
+        self.indexer
+            .set_type_argument_references(!config.skip_type_arguments);
+    }
+
+    fn parse(
+        &mut self,
+        source: &[u8],
+        file_path: &Path,
+    ) -> Result<FrontendOutput, Box<dyn std::error::Error>> {
+        let source = std::str::from_utf8(source)?;
+        let file_path = file_path.to_path_buf();
+        // Both passes share the file's parse timeout
+        self.indexer.start_file();
+        let output = self.extract(source, &file_path);
+        self.indexer.finish_file();
+        output
+    }
+}
+
+/// Frontends by file extension. A frontend registered later takes over the
+/// extensions it shares with earlier ones, so a custom frontend can replace a
+/// built-in language. Extensions several built-in languages use (`.h`) are
+/// parsed by the language the [`LanguagePriority`] picks for the content.
+#[derive(Default)]
+pub struct FrontendRegistry {
+    frontends: Vec<Box<dyn LanguageFrontend>>,
+    by_extension: HashMap<String, usize>,
+    language_priority: LanguagePriority,
+}
+
+impl FrontendRegistry {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Registry with a tree-sitter frontend for every built-in language
+    pub fn with_builtin_frontends() -> Result<Self, Box<dyn std::error::Error>> {
+        let mut registry = Self::new();
+        for language in Language::ALL {
+            registry.register(Box::new(TreeSitterFrontend::new(language)?));
+        }
+        Ok(registry)
+    }
+
+    pub fn register(&mut self, frontend: Box<dyn LanguageFrontend>) {
+        let index = self.frontends.len();
+        for extension in frontend.extensions() {
+            let extension = extension.trim_start_matches('.').to_lowercase();
+            if let Some(previous) = self.by_extension.insert(extension.clone(), index) {
+                tracing::info!(
+                    "Frontend '{}' replaces '{}' for .{} files",
+                    frontend.name(),
+                    self.frontends[previous].name(),
+                    extension
+                );
+            }
+        }
+        self.frontends.push(frontend);
+    }
+
+    /// Remove the frontends named `name`. Extensions they claimed go back to
+    /// the frontends registered for them before. Returns whether any was
+    /// registered.
+    pub fn unregister(&mut self, name: &str) -> bool {
+        let registered = self.frontends.len();
+        self.frontends.retain(|frontend| frontend.name() != name);
+        if self.frontends.len() == registered {
+            return false;
+        }
+        self.by_extension.clear();
+        for (index, frontend) in self.frontends.iter().enumerate() {
+            for extension in frontend.extensions() {
+                let extension = extension.trim_start_matches('.').to_lowercase();
+                self.by_extension.insert(extension, index);
+            }
+        }
+        true
+    }
+
+    pub fn frontend_for(&mut self, path: &Path) -> Option<&mut (dyn LanguageFrontend + 'static)> {
+        let index = *self.by_extension.get(&Self::extension(path)?)?;
+        Some(self.frontends[index].as_mut())
+    }
+
+    /// The frontend to parse `source` with. For an extension with language
+    /// priorities this is the built-in language picked for the content,
+    /// unless a custom frontend was registered for the extension.
+    pub fn frontend_for_source(
+        &mut self,
+        path: &Path,
+        source: &str,
+    ) -> Option<&mut (dyn LanguageFrontend + 'static)> {
+        let extension = Self::extension(path)?;
+        let registered = self.by_extension.get(&extension).copied();
+        let replaced = registered.is_some_and(|index| {
+            let language = self.frontends[index].language();
+            language.is_none() || language != Language::from_extension(&extension)
+        });
+        let chosen = match self.language_priority.choose(&extension, source) {
+            Some(choice) if !replaced => {
+                tracing::debug!(
+                    "Parsing {:?} as {}: {}",
+                    path,
+                    choice.language.as_str(),
+                    choice.reason
+                );
+                self.frontends
+                    .iter()
+                    .rposition(|frontend| frontend.language() == Some(choice.language))
+            }
+            _ => None,
+        };
+        let index = chosen.or(registered)?;
+        Some(self.frontends[index].as_mut())
+    }
+
+    /// Whether some frontend handles files like `path`
+    pub fn handles(&self, path: &Path) -> bool {
+        Self::extension(path).is_some_and(|ext| {
+            self.by_extension.contains_key(&ext) || self.language_priority.covers(&ext)
+        })
+    }
+
+    /// Change how the language of files with shared extensions is picked
+    pub fn set_language_priority(&mut self, language_priority: LanguagePriority) {
+        self.language_priority = language_priority;
+    }
+
+    pub fn language_priority(&self) -> &LanguagePriority {
+        &self.language_priority
+    }
+
+    pub fn configure(&mut self, config: &FrontendConfig) {
+        for frontend in &mut self.frontends {
+            frontend.configure(config);
+        }
+    }
+
+    /// Canonical description of the extensions not served by their built-in
+    /// language, persisted with the cache so registering a frontend rebuilds
+    /// the index. Empty with only the built-in frontends.
+    pub fn fingerprint(&self) -> String {
+        let mut entries: Vec<String> = self
+            .by_extension
+            .iter()
+            .filter(|(ext, index)| {
+                let language = self.frontends[**index].language();
+                language.is_none() || language != Language::from_extension(ext)
+            })
+            .map(|(ext, index)| format!("{}={}", ext, self.frontends[*index].name()))
+            .collect();
+        entries.sort();
+        entries.join(",")
+    }
+
+    fn extension(path: &Path) -> Option<String> {
+        path.extension()
+            .and_then(|ext| ext.to_str())
+            .map(|ext| ext.to_lowercase())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, SymbolId, SymbolType, Visibility};
+    use std::collections::BTreeMap;
+
+    /// Minimal line-based frontend: `def <name>` declares a function
+    struct DefFrontend;
+
+    impl LanguageFrontend for DefFrontend {
+        fn name(&self) -> &str {
+            "defs"
+        }
+
+        fn extensions(&self) -> Vec<String> {
+            vec!["defs".to_string(), "go".to_string()]
+        }
+
+        fn parse(
+            &mut self,
+            source: &[u8],
+            file_path: &Path,
+        ) -> Result<FrontendOutput, Box<dyn std::error::Error>> {
+            let source = std::str::from_utf8(source)?;
+            let symbols = source
+                .lines()
+                .enumerate()
+                .filter_map(|(row, line)| {
+                    let name = line.strip_prefix("def ")?.trim().to_string();
+                    let line_number = row as u32 + 1;
+                    Some(Symbol {
+                        id: SymbolId::new(&file_path.to_path_buf(), line_number, 0),
+                        name,
+                        symbol_type: SymbolType::Function,
+                        location: Location::new(
+                            file_path.to_path_buf(),
+                            line_number,
+                            0,
+                            line_number,
+                            line.len() as u32,
+                        ),
+                        namespace: None,
+                        visibility: Visibility::Public,
+                        source: None,
+                        signature: None,
+                        tags: BTreeMap::new(),
+                        doc: None,
+                        alias_of: None,
+                    })
+                })
+                .collect();
+            Ok(FrontendOutput {
+                symbols,
+                references: Vec::new(),
+            })
+        }
+    }
+
+    #[test]
+    fn test_registry_dispatch_and_override() {
+        let mut registry = FrontendRegistry::new();
+        registry.register(Box::new(DefFrontend));
+
+        assert!(registry.handles(Path::new("rules.DEFS")));
+        assert!(!registry.handles(Path::new("main.rs")));
+
+        let frontend = registry.frontend_for(Path::new("a/rules.defs")).unwrap();
+        let output = frontend
+            .parse(b"def alpha\nnoise\ndef beta\n", Path::new("a/rules.defs"))
+            .unwrap();
+        let names: Vec<&str> = output.symbols.iter().map(|s| s.name.as_str()).collect();
+        assert_eq!(names, vec!["alpha", "beta"]);
+        assert_eq!(output.symbols[1].location.start_line, 3);
+        assert_eq!(registry.fingerprint(), "defs=defs,go=defs");
+    }
+
+    #[test]
+    fn test_registry_unregister() {
+        let mut registry = FrontendRegistry::new();
+        registry.register(Box::new(DefFrontend));
+        assert!(registry.unregister("defs"));
+        assert!(!registry.handles(Path::new("rules.defs")));
+        assert_eq!(registry.fingerprint(), "");
+        assert!(!registry.unregister("defs"));
+    }
+}
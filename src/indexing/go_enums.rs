@@ -0,0 +1,332 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use tree_sitter::{Node, Parser};
+
+/// Tag naming the enum a constant belongs to
+pub const ENUM_TAG: &str = "enum";
+/// Tag holding a constant's computed enum value
+pub const ENUM_VALUE_TAG: &str = "enum_value";
+
+/// A Go `const` block that uses `iota`, read as an enumeration
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GoEnum {
+    /// The declared type (`type Weekday int`), else the first member's name
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_name: Option<String>,
+    pub line: u32,
+    pub end_line: u32,
+    /// Members in declaration order; `_` placeholders are skipped
+    pub members: Vec<EnumMember>,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct EnumMember {
+    pub name: String,
+    /// `None` when the expression cannot be evaluated statically
+    pub value: Option<i128>,
+    /// The expression as written; absent when repeated implicitly
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub expression: Option<String>,
+    pub line: u32,
+}
+
+/// Parse a Go file and find its iota enumerations
+pub fn find_go_enums(source: &str) -> Result<Vec<GoEnum>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    Ok(go_enums(tree.root_node(), source))
+}
+
+/// Every `const ( ... )` block under `root` whose values mention `iota`.
+///
+/// Values follow the Go spec: `iota` is the index of the spec within the
+/// block, a spec without values repeats the previous expression list, and
+/// earlier constants of the block may be referenced. Integer literals,
+/// `+ - * / % << >> & | ^ &^`, unary operators and conversions like
+/// `Weekday(iota)` are evaluated; anything else leaves the value empty.
+pub fn go_enums(root: Node, source: &str) -> Vec<GoEnum> {
+    let mut enums = Vec::new();
+    let mut stack = vec![root];
+    while let Some(node) = stack.pop() {
+        if node.kind() == "const_declaration" {
+            if let Some(go_enum) = const_block(node, source) {
+                enums.push(go_enum);
+            }
+            continue;
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.named_children(&mut cursor));
+    }
+    enums.sort_by_key(|go_enum| go_enum.line);
+    enums
+}
+
+fn const_block(declaration: Node, source: &str) -> Option<GoEnum> {
+    let mut cursor = declaration.walk();
+    let specs: Vec<Node> = declaration
+        .named_children(&mut cursor)
+        .filter(|child| child.kind() == "const_spec")
+        .collect();
+    if !specs.iter().any(|spec| mentions_iota(*spec, source)) {
+        return None;
+    }
+
+    // The enum's type is declared on the first spec, or by a conversion there
+    let first_spec = specs.first()?;
+    let type_name = first_spec
+        .child_by_field_name("type")
+        .and_then(|node| text(node, source))
+        .or_else(|| {
+            first_spec
+                .child_by_field_name("value")
+                .and_then(|list| list.named_child(0))
+                .and_then(|value| conversion_type(value, source))
+        });
+
+    let mut values: HashMap<String, i128> = HashMap::new();
+    let mut members = Vec::new();
+    let mut previous: Vec<Node> = Vec::new();
+
+    for (iota, spec) in specs.iter().enumerate() {
+        let explicit: Vec<Node> = spec
+            .child_by_field_name("value")
+            .map(|list| {
+                let mut cursor = list.walk();
+                list.named_children(&mut cursor).collect()
+            })
+            .unwrap_or_default();
+        let implicit = explicit.is_empty();
+        if !implicit {
+            previous = explicit;
+        }
+
+        let mut name_cursor = spec.walk();
+        let names: Vec<Node> = spec
+            .children_by_field_name("name", &mut name_cursor)
+            .collect();
+        for (index, name_node) in names.into_iter().enumerate() {
+            let Some(name) = text(name_node, source) else {
+                continue;
+            };
+            let expression = previous.get(index).copied();
+            let value = expression.and_then(|node| evaluate(node, source, iota as i128, &values));
+            if name == "_" {
+                continue;
+            }
+            if let Some(value) = value {
+                values.insert(name.clone(), value);
+            }
+            members.push(EnumMember {
+                name,
+                value,
+                expression: if implicit {
+                    None
+                } else {
+                    expression.and_then(|node| text(node, source))
+                },
+                line: name_node.start_position().row as u32 + 1,
+            });
+        }
+    }
+
+    let first = members.first()?;
+    Some(GoEnum {
+        name: type_name.clone().unwrap_or_else(|| first.name.clone()),
+        type_name,
+        line: declaration.start_position().row as u32 + 1,
+        end_line: declaration.end_position().row as u32 + 1,
+        members,
+    })
+}
+
+fn mentions_iota(node: Node, source: &str) -> bool {
+    if node.kind() == "iota"
+        || (node.kind() == "identifier" && text(node, source).as_deref() == Some("iota"))
+    {
+        return true;
+    }
+    let mut cursor = node.walk();
+    let found = node
+        .named_children(&mut cursor)
+        .any(|child| mentions_iota(child, source));
+    found
+}
+
+/// `Weekday(iota)` -> `Weekday`
+fn conversion_type(node: Node, source: &str) -> Option<String> {
+    if node.kind() != "call_expression" {
+        return None;
+    }
+    let function = node.child_by_field_name("function")?;
+    matches!(
+        function.kind(),
+        "identifier" | "type_identifier" | "qualified_type"
+    )
+    .then(|| text(function, source))
+    .flatten()
+}
+
+fn evaluate(node: Node, source: &str, iota: i128, values: &HashMap<String, i128>) -> Option<i128> {
+    match node.kind() {
+        "iota" => Some(iota),
+        "int_literal" => parse_int(&text(node, source)?),
+        "identifier" => {
+            let name = text(node, source)?;
+            if name == "iota" {
+                Some(iota)
+            } else {
+                values.get(&name).copied()
+            }
+        }
+        "parenthesized_expression" => evaluate(node.named_child(0)?, source, iota, values),
+        "call_expression" => {
+            // Only single-argument conversions keep the value
+            let arguments = node.child_by_field_name("arguments")?;
+            if arguments.named_child_count() != 1 {
+                return None;
+            }
+            evaluate(arguments.named_child(0)?, source, iota, values)
+        }
+        "unary_expression" => {
+            let operand = evaluate(node.child_by_field_name("operand")?, source, iota, values)?;
+            match text(node.child_by_field_name("operator")?, source)?.as_str() {
+                "-" => operand.checked_neg(),
+                "+" => Some(operand),
+                "^" => Some(!operand),
+                _ => None,
+            }
+        }
+        "binary_expression" => {
+            let left = evaluate(node.child_by_field_name("left")?, source, iota, values)?;
+            let right = evaluate(node.child_by_field_name("right")?, source, iota, values)?;
+            match text(node.child_by_field_name("operator")?, source)?.as_str() {
+                "+" => left.checked_add(right),
+                "-" => left.checked_sub(right),
+                "*" => left.checked_mul(right),
+                "/" => left.checked_div(right),
+                "%" => left.checked_rem(right),
+                "<<" => u32::try_from(right)
+                    .ok()
+                    .filter(|shift| *shift < 127)
+                    .and_then(|shift| left.checked_mul(1i128 << shift)),
+                ">>" => u32::try_from(right)
+                    .ok()
+                    .map(|shift| left >> shift.min(127)),
+                "&" => Some(left & right),
+                "|" => Some(left | right),
+                "^" => Some(left ^ right),
+                "&^" => Some(left & !right),
+                _ => None,
+            }
+        }
+        _ => None,
+    }
+}
+
+/// Go integer literal: decimal, `0x`, `0o`/`0`, `0b`, with `_` separators
+fn parse_int(literal: &str) -> Option<i128> {
+    let digits = literal.replace('_', "");
+    let lower = digits.to_ascii_lowercase();
+    if let Some(hex) = lower.strip_prefix("0x") {
+        i128::from_str_radix(hex, 16).ok()
+    } else if let Some(binary) = lower.strip_prefix("0b") {
+        i128::from_str_radix(binary, 2).ok()
+    } else if let Some(octal) = lower.strip_prefix("0o") {
+        i128::from_str_radix(octal, 8).ok()
+    } else if lower.len() > 1 && lower.starts_with('0') {
+        i128::from_str_radix(&lower[1..], 8).ok()
+    } else {
+        lower.parse().ok()
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_int() {
+        assert_eq!(parse_int("42"), Some(42));
+        assert_eq!(parse_int("0x1F"), Some(31));
+        assert_eq!(parse_int("0b1010"), Some(10));
+        assert_eq!(parse_int("0o17"), Some(15));
+        assert_eq!(parse_int("017"), Some(15));
+        assert_eq!(parse_int("1_000"), Some(1000));
+        assert_eq!(parse_int("0"), Some(0));
+    }
+
+    #[test]
+    fn test_iota_enums() {
+        let source = r#"package week
+
+type Weekday int
+
+const (
+    Sunday Weekday = iota
+    Monday
+    Tuesday
+)
+
+type Perm uint8
+
+const (
+    Read Perm = 1 << iota
+    Write
+    _
+    Exec
+)
+
+const (
+    _  = iota
+    KB = 1 << (10 * iota)
+    MB
+)
+
+const Limit = 10
+"#;
+        let enums = find_go_enums(source).unwrap();
+        let summary: Vec<(&str, Vec<(&str, Option<i128>)>)> = enums
+            .iter()
+            .map(|e| {
+                (
+                    e.name.as_str(),
+                    e.members
+                        .iter()
+                        .map(|m| (m.name.as_str(), m.value))
+                        .collect(),
+                )
+            })
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                (
+                    "Weekday",
+                    vec![
+                        ("Sunday", Some(0)),
+                        ("Monday", Some(1)),
+                        ("Tuesday", Some(2))
+                    ]
+                ),
+                (
+                    "Perm",
+                    vec![("Read", Some(1)), ("Write", Some(2)), ("Exec", Some(8))]
+                ),
+                ("KB", vec![("KB", Some(1024)), ("MB", Some(1_048_576))]),
+            ]
+        );
+        assert_eq!(enums[0].members[0].expression.as_deref(), Some("iota"));
+        assert_eq!(enums[0].members[1].expression, None);
+        assert_eq!(enums[2].type_name, None);
+    }
+}
@@ -0,0 +1,415 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use tree_sitter::{Node, Parser};
+
+/// Tag on code that runs at package initialization: `init` on `init`
+/// functions, `var` on package-level vars whose initializer calls functions
+pub const INIT_TAG: &str = "init";
+
+/// Calls in an initializer that do no real work: builtin conversions and
+/// allocations, and sentinel errors
+const TRIVIAL_CALLS: &[&str] = &[
+    "bool",
+    "byte",
+    "rune",
+    "string",
+    "int",
+    "int8",
+    "int16",
+    "int32",
+    "int64",
+    "uint",
+    "uint8",
+    "uint16",
+    "uint32",
+    "uint64",
+    "uintptr",
+    "float32",
+    "float64",
+    "make",
+    "new",
+    "errors.New",
+];
+
+/// Longest initializer expression returned; longer ones are cut
+const MAX_EXPRESSION_CHARS: usize = 120;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum InitKind {
+    /// A package-level var with an initializer
+    Var,
+    /// An `init()` function
+    Init,
+}
+
+/// Code in one file that runs when its package is initialized
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageInitializer {
+    pub kind: InitKind,
+    /// `init`, the var's name, or `a, b` for `var a, b = f()`
+    pub name: String,
+    pub line: u32,
+    /// The initializer, whitespace collapsed; vars only
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub expression: Option<String>,
+    /// The initializer calls functions or receives from a channel, so it
+    /// runs code with possible side effects; vars only
+    pub non_trivial: bool,
+    /// Identifiers the initializer (or the `init` body) mentions
+    #[serde(skip)]
+    pub mentions: Vec<String>,
+}
+
+/// What one Go file contributes to its package's initialization
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct FileInitializers {
+    /// Vars and `init` functions in declaration order
+    pub initializers: Vec<PackageInitializer>,
+    /// Identifiers each package-level function mentions, to follow
+    /// dependencies through calls
+    pub functions: HashMap<String, Vec<String>>,
+}
+
+/// One step of a package's initialization
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct InitStep {
+    pub step: usize,
+    pub file: String,
+    #[serde(flatten)]
+    pub initializer: PackageInitializer,
+    /// Package-level vars that must be initialized first
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub depends_on: Vec<String>,
+}
+
+/// Parse a Go file and find what runs when its package is initialized
+pub fn find_go_initializers(source: &str) -> Result<FileInitializers, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    Ok(go_initializers(tree.root_node(), source))
+}
+
+/// Package-level vars with initializers and `init` functions declared at the
+/// top level under `root`. Every `init` in a file is its own entry.
+pub fn go_initializers(root: Node, source: &str) -> FileInitializers {
+    let mut file = FileInitializers::default();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        match declaration.kind() {
+            "var_declaration" => {
+                let mut specs = Vec::new();
+                collect_var_specs(declaration, &mut specs);
+                file.initializers.extend(
+                    specs
+                        .into_iter()
+                        .filter_map(|spec| var_initializer(spec, source)),
+                );
+            }
+            "function_declaration" => {
+                let Some(name) = declaration
+                    .child_by_field_name("name")
+                    .and_then(|name| name.utf8_text(source.as_bytes()).ok())
+                else {
+                    continue;
+                };
+                let mut mentions = Vec::new();
+                if let Some(body) = declaration.child_by_field_name("body") {
+                    collect_identifiers(body, source, &mut mentions);
+                }
+                if name == "init" {
+                    file.initializers.push(PackageInitializer {
+                        kind: InitKind::Init,
+                        name: name.to_string(),
+                        line: declaration.start_position().row as u32 + 1,
+                        expression: None,
+                        non_trivial: true,
+                        mentions,
+                    });
+                } else {
+                    file.functions.insert(name.to_string(), mentions);
+                }
+            }
+            _ => {}
+        }
+    }
+    file
+}
+
+/// `var x = 1` and the specs of `var ( ... )` blocks
+fn collect_var_specs<'t>(node: Node<'t>, specs: &mut Vec<Node<'t>>) {
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        match child.kind() {
+            "var_spec" => specs.push(child),
+            "var_spec_list" => collect_var_specs(child, specs),
+            _ => {}
+        }
+    }
+}
+
+fn var_initializer(spec: Node, source: &str) -> Option<PackageInitializer> {
+    let value = spec.child_by_field_name("value")?;
+    let mut cursor = spec.walk();
+    let names: Vec<&str> = spec
+        .children_by_field_name("name", &mut cursor)
+        .filter_map(|name| name.utf8_text(source.as_bytes()).ok())
+        .collect();
+    let text = value.utf8_text(source.as_bytes()).ok()?;
+    let mut mentions = Vec::new();
+    collect_identifiers(value, source, &mut mentions);
+    Some(PackageInitializer {
+        kind: InitKind::Var,
+        name: names.join(", "),
+        line: spec.start_position().row as u32 + 1,
+        expression: Some(truncate(
+            &text.split_whitespace().collect::<Vec<_>>().join(" "),
+        )),
+        non_trivial: runs_code(value, source),
+        mentions,
+    })
+}
+
+/// Whether evaluating `node` calls a function or receives from a channel.
+/// Function literals only run when called, so their bodies do not count.
+fn runs_code(node: Node, source: &str) -> bool {
+    match node.kind() {
+        "func_literal" => return false,
+        "call_expression" => {
+            let callee = node
+                .child_by_field_name("function")
+                .and_then(|function| function.utf8_text(source.as_bytes()).ok())
+                .unwrap_or_default();
+            if !TRIVIAL_CALLS.contains(&callee) {
+                return true;
+            }
+        }
+        "unary_expression" => {
+            if node
+                .child_by_field_name("operator")
+                .is_some_and(|operator| operator.kind() == "<-")
+            {
+                return true;
+            }
+        }
+        _ => {}
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    children.into_iter().any(|child| runs_code(child, source))
+}
+
+/// Plain identifiers under `node`, each once. Selectors like `cfg.Port`
+/// contribute their operand only.
+fn collect_identifiers(node: Node, source: &str, identifiers: &mut Vec<String>) {
+    if node.kind() == "identifier" {
+        if let Ok(name) = node.utf8_text(source.as_bytes()) {
+            if !identifiers.iter().any(|existing| existing == name) {
+                identifiers.push(name.to_string());
+            }
+        }
+        return;
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_identifiers(child, source, identifiers);
+    }
+}
+
+/// Order the initialization of a package from its files, given in the order
+/// the compiler sees them (`go build` sorts them by name).
+///
+/// As in the Go spec, vars are initialized one at a time, each time picking
+/// the earliest in declaration order whose dependencies are all initialized;
+/// a var depends on the vars its initializer mentions directly or through
+/// the package functions it mentions. `init` functions then run in the
+/// order they appear. Method values and shadowed names are not resolved, so
+/// the order is an approximation.
+pub fn init_order(files: Vec<(String, FileInitializers)>) -> Vec<InitStep> {
+    let functions: HashMap<&str, &Vec<String>> = files
+        .iter()
+        .flat_map(|(_, file)| file.functions.iter())
+        .map(|(name, mentions)| (name.as_str(), mentions))
+        .collect();
+
+    let mut vars = Vec::new();
+    let mut inits = Vec::new();
+    for (path, file) in &files {
+        for initializer in &file.initializers {
+            match initializer.kind {
+                InitKind::Var => vars.push((path, initializer)),
+                InitKind::Init => inits.push((path, initializer)),
+            }
+        }
+    }
+
+    // Which var initializer declares each package-level name
+    let mut declared_by: HashMap<&str, usize> = HashMap::new();
+    for (index, (_, var)) in vars.iter().enumerate() {
+        for name in var.name.split(", ").filter(|name| *name != "_") {
+            declared_by.insert(name, index);
+        }
+    }
+    let dependencies = |mentions: &[String]| -> Vec<usize> {
+        let mut found = Vec::new();
+        let mut seen_functions = HashSet::new();
+        let mut pending: Vec<&str> = mentions.iter().map(String::as_str).collect();
+        while let Some(name) = pending.pop() {
+            if let Some(&index) = declared_by.get(name) {
+                if !found.contains(&index) {
+                    found.push(index);
+                }
+            } else if let Some(body) = functions.get(name) {
+                if seen_functions.insert(name) {
+                    pending.extend(body.iter().map(String::as_str));
+                }
+            }
+        }
+        found.sort_unstable();
+        found
+    };
+    let var_dependencies: Vec<Vec<usize>> = vars
+        .iter()
+        .enumerate()
+        .map(|(index, (_, var))| {
+            let mut found = dependencies(&var.mentions);
+            found.retain(|&dependency| dependency != index);
+            found
+        })
+        .collect();
+    let names = |indices: &[usize]| -> Vec<String> {
+        indices
+            .iter()
+            .map(|&index| vars[index].1.name.clone())
+            .collect()
+    };
+
+    let mut steps: Vec<InitStep> = Vec::new();
+    let mut initialized = vec![false; vars.len()];
+    while steps.len() < vars.len() {
+        let ready = (0..vars.len()).find(|&index| {
+            !initialized[index]
+                && var_dependencies[index]
+                    .iter()
+                    .all(|&dependency| initialized[dependency])
+        });
+        // A dependency cycle does not compile; take the earliest to move on
+        let Some(next) = ready.or_else(|| (0..vars.len()).find(|&index| !initialized[index]))
+        else {
+            break;
+        };
+        initialized[next] = true;
+        let (path, var) = vars[next];
+        steps.push(InitStep {
+            step: steps.len() + 1,
+            file: path.clone(),
+            initializer: var.clone(),
+            depends_on: names(&var_dependencies[next]),
+        });
+    }
+    for (path, init) in inits {
+        steps.push(InitStep {
+            step: steps.len() + 1,
+            file: path.clone(),
+            initializer: init.clone(),
+            depends_on: names(&dependencies(&init.mentions)),
+        });
+    }
+    steps
+}
+
+fn truncate(expression: &str) -> String {
+    if expression.chars().count() <= MAX_EXPRESSION_CHARS {
+        return expression.to_string();
+    }
+    let cut: String = expression.chars().take(MAX_EXPRESSION_CHARS).collect();
+    format!("{}...", cut)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_go_initializers() {
+        let source = r#"package cache
+
+var ErrMiss = errors.New("cache miss")
+
+var (
+    defaultTTL = 5 * time.Minute
+    shared     = NewMemoryCache(defaultTTL)
+    handler    = func() {}
+)
+
+var count int
+
+func init() {
+    register(shared)
+}
+
+func init() {
+    log.Println("cache ready")
+}
+"#;
+        let file = find_go_initializers(source).unwrap();
+        let found: Vec<(InitKind, &str, u32, bool)> = file
+            .initializers
+            .iter()
+            .map(|i| (i.kind, i.name.as_str(), i.line, i.non_trivial))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (InitKind::Var, "ErrMiss", 3, false),
+                (InitKind::Var, "defaultTTL", 6, false),
+                (InitKind::Var, "shared", 7, true),
+                (InitKind::Var, "handler", 8, false),
+                (InitKind::Init, "init", 13, true),
+                (InitKind::Init, "init", 17, true),
+            ]
+        );
+        assert_eq!(
+            file.initializers[2].expression.as_deref(),
+            Some("NewMemoryCache(defaultTTL)")
+        );
+    }
+
+    #[test]
+    fn test_init_order() {
+        let a = r#"package app
+
+var total = sum()
+
+func sum() int { return base + extra }
+
+func init() { total++ }
+"#;
+        let b = r#"package app
+
+var base = 10
+var extra = base * 2
+"#;
+        let steps = init_order(vec![
+            ("a.go".to_string(), find_go_initializers(a).unwrap()),
+            ("b.go".to_string(), find_go_initializers(b).unwrap()),
+        ]);
+        let order: Vec<(&str, &str)> = steps
+            .iter()
+            .map(|step| (step.file.as_str(), step.initializer.name.as_str()))
+            .collect();
+        assert_eq!(
+            order,
+            vec![
+                ("b.go", "base"),
+                ("b.go", "extra"),
+                ("a.go", "total"),
+                ("a.go", "init"),
+            ]
+        );
+        assert_eq!(steps[2].depends_on, vec!["base", "extra"]);
+        assert_eq!(steps[3].depends_on, vec!["total"]);
+    }
+}
@@ -0,0 +1,424 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Comment that marks a `go` statement, on its line or the line above, as
+/// intentionally long-lived
+pub const NOLINT_COMMENT: &str = "nolint:goroutineleak";
+
+/// Channel names that conventionally signal shutdown: `done`, `c.stopCh`,
+/// `quit`
+const STOP_WORDS: &[&str] = &[
+    "done", "stop", "quit", "exit", "close", "shutdown", "cancel", "term", "kill", "die",
+];
+
+/// How sure a leak finding is
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum LeakConfidence {
+    Low,
+    Medium,
+    High,
+}
+
+impl LeakConfidence {
+    /// One step less sure; low stays low
+    pub fn lowered(self) -> Self {
+        match self {
+            Self::High => Self::Medium,
+            _ => Self::Low,
+        }
+    }
+}
+
+/// A `go` statement
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GoroutineLaunch {
+    /// The function started as written: `cache.cleanup`, `worker`, or
+    /// `func literal`
+    pub target: String,
+    /// The enclosing function or method; `None` at package level
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    pub line: u32,
+    pub column: u32,
+    /// The statement as written, whitespace collapsed
+    pub statement: String,
+    /// Carries a `nolint:goroutineleak` comment
+    #[serde(skip)]
+    pub suppressed: bool,
+    /// For func literals, their loop analyzed in place
+    #[serde(skip)]
+    pub literal_loop: Option<LoopProfile>,
+}
+
+/// What the first loop of a function that blocks on a channel or timer
+/// waits on, and whether anything in it leaves the loop
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct LoopProfile {
+    /// Line of the `for`
+    pub line: u32,
+    /// Channels and timers the loop blocks on: `ticker.C`, `time.Sleep`,
+    /// `jobs`
+    pub waits_on: Vec<String>,
+    /// Something leaves the loop: a context's `Done()`, a receive from a
+    /// stop channel, `return`, `break` or `goto`
+    pub exits: bool,
+}
+
+impl LoopProfile {
+    /// `None` when the loop has a way out. A loop blocked only on tickers
+    /// and timers is a high-confidence leak; one that also receives from
+    /// other channels may be stopped through one of them.
+    pub fn leak_confidence(&self) -> Option<LeakConfidence> {
+        if self.exits || self.waits_on.is_empty() {
+            return None;
+        }
+        match self.waits_on.iter().all(|wait| is_timer(wait)) {
+            true => Some(LeakConfidence::High),
+            false => Some(LeakConfidence::Medium),
+        }
+    }
+}
+
+/// Find the `go` statements of a Go source file
+pub fn find_goroutine_launches(
+    source: &str,
+) -> Result<Vec<GoroutineLaunch>, Box<dyn std::error::Error>> {
+    let tree = parse(source)?;
+    let lines: Vec<&str> = source.lines().collect();
+    let mut launches = Vec::new();
+    visit(tree.root_node(), source, &lines, None, &mut launches);
+    Ok(launches)
+}
+
+/// The loop profile of the function or method declared at `line` (1-based),
+/// `None` when it has no infinite loop blocking on a channel or timer
+pub fn function_loop_profile(
+    source: &str,
+    line: u32,
+) -> Result<Option<LoopProfile>, Box<dyn std::error::Error>> {
+    let tree = parse(source)?;
+    let mut cursor = tree.root_node().walk();
+    let declaration = tree.root_node().named_children(&mut cursor).find(|node| {
+        matches!(node.kind(), "function_declaration" | "method_declaration")
+            && node.start_position().row as u32 + 1 == line
+    });
+    Ok(declaration
+        .and_then(|declaration| declaration.child_by_field_name("body"))
+        .and_then(|body| loop_profile(body, source)))
+}
+
+fn parse(source: &str) -> Result<tree_sitter::Tree, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    Ok(parser.parse(source, None).ok_or("Failed to parse source")?)
+}
+
+fn visit(
+    node: Node,
+    source: &str,
+    lines: &[&str],
+    function: Option<&str>,
+    launches: &mut Vec<GoroutineLaunch>,
+) {
+    let named_function = match node.kind() {
+        "function_declaration" | "method_declaration" => node
+            .child_by_field_name("name")
+            .and_then(|name| text(name, source)),
+        _ => None,
+    };
+    let function = named_function.as_deref().or(function);
+
+    if node.kind() == "go_statement" {
+        if let Some(launch) = launch(node, source, lines, function) {
+            launches.push(launch);
+        }
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, lines, function, launches);
+    }
+}
+
+fn launch(
+    node: Node,
+    source: &str,
+    lines: &[&str],
+    function: Option<&str>,
+) -> Option<GoroutineLaunch> {
+    let call = node.named_child(0)?;
+    if call.kind() != "call_expression" {
+        return None;
+    }
+    let callee = call.child_by_field_name("function")?;
+    let (target, literal_loop) = match callee.kind() {
+        "func_literal" => (
+            "func literal".to_string(),
+            callee
+                .child_by_field_name("body")
+                .and_then(|body| loop_profile(body, source)),
+        ),
+        _ => (text(callee, source)?, None),
+    };
+
+    let row = node.start_position().row;
+    let suppressed = [Some(row), row.checked_sub(1)]
+        .into_iter()
+        .flatten()
+        .filter_map(|row| lines.get(row))
+        .any(|line| line.contains(NOLINT_COMMENT));
+    Some(GoroutineLaunch {
+        target,
+        function: function.map(str::to_string),
+        line: row as u32 + 1,
+        column: node.start_position().column as u32,
+        statement: text(node, source).unwrap_or_default(),
+        suppressed,
+        literal_loop,
+    })
+}
+
+/// Profile of the first infinite `for` in a body, outside nested func
+/// literals, that blocks on a channel or timer
+fn loop_profile(body: Node, source: &str) -> Option<LoopProfile> {
+    let mut stack = vec![body];
+    while let Some(node) = stack.pop() {
+        if node.kind() == "func_literal" {
+            continue;
+        }
+        if node.kind() == "for_statement" {
+            if let Some(profile) = for_profile(node, source) {
+                return Some(profile);
+            }
+        }
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.named_children(&mut cursor).collect();
+        stack.extend(children.into_iter().rev());
+    }
+    None
+}
+
+/// `for { ... }`, `for ;; { ... }` and `for range ticker.C { ... }`; other
+/// loops end by their condition or when their collection is exhausted
+fn for_profile(node: Node, source: &str) -> Option<LoopProfile> {
+    let body = node.child_by_field_name("body")?;
+    let mut waits_on = Vec::new();
+    let mut cursor = node.walk();
+    for clause in node.named_children(&mut cursor) {
+        if clause.id() == body.id() || clause.kind() == "comment" {
+            continue;
+        }
+        match clause.kind() {
+            "for_clause" if clause.child_by_field_name("condition").is_none() => {}
+            "range_clause" => {
+                let range = text(clause.child_by_field_name("right")?, source)?;
+                if !is_timer(&range) {
+                    return None;
+                }
+                waits_on.push(range);
+            }
+            _ => return None,
+        }
+    }
+
+    let mut exits = false;
+    scan_loop_body(body, source, 0, &mut waits_on, &mut exits);
+    let mut seen = Vec::new();
+    waits_on.retain(|wait| {
+        let new = !seen.contains(wait);
+        seen.push(wait.clone());
+        new
+    });
+    (!waits_on.is_empty() || exits).then(|| LoopProfile {
+        line: node.start_position().row as u32 + 1,
+        waits_on,
+        exits,
+    })
+}
+
+/// Collect what a loop body blocks on and whether it can leave the loop.
+/// `breakable` counts the `select`, `switch` and `for` statements between
+/// `node` and the loop, which an unlabeled `break` leaves instead.
+fn scan_loop_body(
+    node: Node,
+    source: &str,
+    breakable: usize,
+    waits_on: &mut Vec<String>,
+    exits: &mut bool,
+) {
+    match node.kind() {
+        "func_literal" => return,
+        "return_statement" | "goto_statement" => *exits = true,
+        "break_statement" => {
+            let labeled = node.named_child(0).is_some();
+            if labeled || breakable == 0 {
+                *exits = true;
+            }
+        }
+        "unary_expression" => {
+            let operator = node
+                .child_by_field_name("operator")
+                .and_then(|operator| text(operator, source));
+            if operator.as_deref() == Some("<-") {
+                if let Some(channel) = node
+                    .child_by_field_name("operand")
+                    .and_then(|operand| text(operand, source))
+                {
+                    match is_stop_signal(&channel) {
+                        true => *exits = true,
+                        false => waits_on.push(channel),
+                    }
+                }
+            }
+        }
+        "call_expression" => {
+            let callee = node
+                .child_by_field_name("function")
+                .and_then(|callee| text(callee, source));
+            if callee.as_deref() == Some("time.Sleep") {
+                waits_on.push("time.Sleep".to_string());
+            }
+        }
+        _ => {}
+    }
+
+    let breakable = match node.kind() {
+        "select_statement"
+        | "expression_switch_statement"
+        | "type_switch_statement"
+        | "for_statement" => breakable + 1,
+        _ => breakable,
+    };
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        scan_loop_body(child, source, breakable, waits_on, exits);
+    }
+}
+
+/// A context's `Done()` or a channel named for shutdown
+fn is_stop_signal(channel: &str) -> bool {
+    if channel.ends_with(".Done()") || channel == "Done()" {
+        return true;
+    }
+    let name = channel
+        .rsplit('.')
+        .next()
+        .unwrap_or(channel)
+        .trim_end_matches("()")
+        .to_lowercase();
+    STOP_WORDS.iter().any(|word| name.contains(word))
+}
+
+/// Tickers and timers, which fire forever and are never closed
+fn is_timer(channel: &str) -> bool {
+    channel == "time.Sleep"
+        || channel.ends_with(".C")
+        || channel.starts_with("time.Tick(")
+        || channel.starts_with("time.After(")
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const SOURCE: &str = r#"package cache
+
+func NewMemoryCache() *MemoryCache {
+    c := &MemoryCache{}
+    go c.cleanup()
+    //nolint:goroutineleak // runs for the life of the process
+    go c.report()
+    go func() {
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case job := <-c.jobs:
+                c.run(job)
+            }
+        }
+    }()
+    return c
+}
+
+func (c *MemoryCache) cleanup() {
+    ticker := time.NewTicker(time.Minute)
+    for range ticker.C {
+        c.evict()
+    }
+}
+
+func (c *MemoryCache) drain() {
+    for {
+        select {
+        case job := <-c.jobs:
+            c.run(job)
+        case <-time.After(time.Second):
+            break
+        }
+    }
+}
+
+func (c *MemoryCache) watch() {
+    for {
+        select {
+        case <-c.stopCh:
+            return
+        case <-ticker.C:
+        }
+    }
+}
+"#;
+
+    #[test]
+    fn test_goroutine_launches() {
+        let launches = find_goroutine_launches(SOURCE).unwrap();
+        let summary: Vec<(&str, u32, bool)> = launches
+            .iter()
+            .map(|l| (l.target.as_str(), l.line, l.suppressed))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("c.cleanup", 5, false),
+                ("c.report", 7, true),
+                ("func literal", 8, false),
+            ]
+        );
+        assert_eq!(launches[0].function.as_deref(), Some("NewMemoryCache"));
+        // The literal selects on ctx.Done()
+        assert_eq!(
+            launches[2]
+                .literal_loop
+                .as_ref()
+                .and_then(LoopProfile::leak_confidence),
+            None
+        );
+    }
+
+    #[test]
+    fn test_loop_profiles() {
+        let cleanup = function_loop_profile(SOURCE, 21).unwrap().unwrap();
+        assert_eq!(cleanup.waits_on, vec!["ticker.C"]);
+        assert_eq!(cleanup.leak_confidence(), Some(LeakConfidence::High));
+
+        // `break` inside select leaves the select, not the loop
+        let drain = function_loop_profile(SOURCE, 28).unwrap().unwrap();
+        assert_eq!(drain.waits_on, vec!["c.jobs", "time.After(time.Second)"]);
+        assert_eq!(drain.leak_confidence(), Some(LeakConfidence::Medium));
+
+        let watch = function_loop_profile(SOURCE, 39).unwrap().unwrap();
+        assert!(watch.exits);
+        assert_eq!(watch.leak_confidence(), None);
+
+        assert_eq!(function_loop_profile(SOURCE, 3).unwrap(), None);
+    }
+}
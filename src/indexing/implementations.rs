@@ -0,0 +1,152 @@
+use crate::indexing::interface_contract::ContractMethod;
+use std::collections::HashMap;
+
+/// Words in class headers that are not type names
+const HEADER_KEYWORDS: &[&str] = &[
+    "class",
+    "interface",
+    "struct",
+    "enum",
+    "record",
+    "trait",
+    "object",
+    "extends",
+    "implements",
+    "with",
+    "where",
+    "public",
+    "private",
+    "protected",
+    "internal",
+    "abstract",
+    "final",
+    "sealed",
+    "open",
+    "static",
+    "partial",
+    "data",
+    "export",
+    "default",
+    "declare",
+    "metaclass",
+];
+
+/// Whether a Go type whose methods are `methods` (name to parameter count)
+/// has every method of `contract`. Empty interfaces are satisfied by every
+/// type, which is never a useful answer, so they match nothing.
+pub fn satisfies(methods: &HashMap<String, usize>, contract: &[ContractMethod]) -> bool {
+    !contract.is_empty()
+        && contract.iter().all(|method| {
+            methods
+                .get(&method.name)
+                .is_some_and(|&count| count == method.parameters.len())
+        })
+}
+
+/// Type names a class declaration lists as its bases or interfaces:
+/// `class UserRepo extends Base implements Repo, Closeable {`,
+/// `class UserRepo(Base, Repo):` or `class UserRepo : Repo {`. Generic
+/// arguments are left out: `Repository<User>` names only `Repository`.
+pub fn declared_supertypes(header: &str, name: &str) -> Vec<String> {
+    let header = header.split('{').next().unwrap_or(header);
+    // Bases start after the class name
+    let Some(start) = find_word(header, name) else {
+        return Vec::new();
+    };
+    let mut bases = String::new();
+    let mut generic_depth = 0usize;
+    for c in header[start + name.len()..].chars() {
+        match c {
+            '<' | '[' => generic_depth += 1,
+            '>' | ']' => generic_depth = generic_depth.saturating_sub(1),
+            _ if generic_depth == 0 => bases.push(c),
+            _ => {}
+        }
+    }
+
+    let mut supertypes: Vec<String> = Vec::new();
+    for token in bases.split(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.')) {
+        let token = token.trim_matches('.');
+        let base = token.rsplit('.').next().unwrap_or(token);
+        if base.is_empty()
+            || base.starts_with(|c: char| c.is_ascii_digit())
+            || HEADER_KEYWORDS.contains(&base)
+            || base == name
+        {
+            continue;
+        }
+        if !supertypes.iter().any(|existing| existing == base) {
+            supertypes.push(base.to_string());
+        }
+    }
+    supertypes
+}
+
+/// Byte offset of `word` in `text` as a whole word
+fn find_word(text: &str, word: &str) -> Option<usize> {
+    let is_ident = |c: char| c.is_alphanumeric() || c == '_';
+    text.match_indices(word)
+        .map(|(index, _)| index)
+        .find(|&index| {
+            let before = text[..index].chars().next_back();
+            let after = text[index + word.len()..].chars().next();
+            !before.is_some_and(is_ident) && !after.is_some_and(is_ident)
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn method(name: &str, parameters: usize) -> ContractMethod {
+        ContractMethod {
+            name: name.to_string(),
+            parameters: (0..parameters)
+                .map(|i| crate::models::Parameter {
+                    name: Some(format!("p{}", i)),
+                    type_name: Some("string".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                })
+                .collect(),
+            returns: Vec::new(),
+            returns_error: false,
+            signature: String::new(),
+            line: 1,
+            from: None,
+        }
+    }
+
+    #[test]
+    fn test_satisfies() {
+        let methods: HashMap<String, usize> =
+            HashMap::from([("Get".to_string(), 1), ("Close".to_string(), 0)]);
+        assert!(satisfies(&methods, &[method("Get", 1)]));
+        assert!(satisfies(&methods, &[method("Get", 1), method("Close", 0)]));
+        assert!(!satisfies(&methods, &[method("Get", 2)]));
+        assert!(!satisfies(&methods, &[method("Put", 1)]));
+        assert!(!satisfies(&methods, &[]));
+    }
+
+    #[test]
+    fn test_declared_supertypes() {
+        assert_eq!(
+            declared_supertypes(
+                "public class UserRepo extends BaseRepo implements Repository<User>, java.io.Closeable {",
+                "UserRepo"
+            ),
+            vec!["BaseRepo", "Repository", "Closeable"]
+        );
+        assert_eq!(
+            declared_supertypes("class UserRepo(Base, metaclass=ABCMeta):", "UserRepo"),
+            vec!["Base", "ABCMeta"]
+        );
+        assert_eq!(
+            declared_supertypes("class UserRepo : IRepository, IDisposable", "UserRepo"),
+            vec!["IRepository", "IDisposable"]
+        );
+        assert!(declared_supertypes("class UserRepository {", "UserRepo").is_empty());
+    }
+}
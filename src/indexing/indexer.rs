@@ -1,9 +1,30 @@
+use crate::indexing::anonymous_types::anonymous_type_symbols;
+use crate::indexing::attributes::attribute_tags;
+use crate::indexing::build_constraints::{file_constraint, BUILD_TAG};
+use crate::indexing::go_enums::{go_enums, ENUM_TAG, ENUM_VALUE_TAG};
+use crate::indexing::go_init::{go_initializers, InitKind, INIT_TAG};
+use crate::indexing::lua::apply_module_surface;
+use crate::indexing::return_counts::{count_returns, EARLY_RETURN_COUNT_TAG, RETURN_COUNT_TAG};
+use crate::indexing::routes::{route_symbols, RoutePatterns};
+use crate::indexing::scala::apply_scala_model;
+use crate::indexing::side_effects::{function_effects, SIDE_EFFECTS_TAG};
+use crate::indexing::signature::extract_signature;
+use crate::indexing::symbol_analysis::{is_callee, is_constructed};
+use crate::indexing::tags::{doc_comment, TagKeys};
+use crate::indexing::test_detection::is_test_function;
+use crate::indexing::type_forms::go_type_tags;
+use crate::indexing::type_references::{
+    in_type_arguments, is_type_expression, named_types, nested_type_names,
+};
+use crate::indexing::var_types::go_var_tags;
 use crate::models::{
     Language, Location, Reference, ReferenceType, Symbol, SymbolId, SymbolType, Visibility,
 };
-use std::collections::HashMap;
+use crate::utils::error::CodeAnalysisError;
+use std::collections::{HashMap, HashSet};
 use std::path::PathBuf;
-use tree_sitter::{Parser, Query, StreamingIterator};
+use std::time::{Duration, Instant};
+use tree_sitter::{ParseOptions, Parser, Query, StreamingIterator, Tree};
 
 const RUST_QUERY: &str = include_str!("../../queries/rust-symbols.scm");
 const PYTHON_QUERY: &str = include_str!("../../queries/python-symbols.scm");
@@ -20,37 +41,43 @@ const SCALA_QUERY: &str = include_str!("../../queries/scala-symbols.scm");
 const SWIFT_QUERY: &str = include_str!("../../queries/swift-symbols.scm");
 const PHP_QUERY: &str = include_str!("../../queries/php-symbols.scm");
 const OBJC_QUERY: &str = include_str!("../../queries/objc-symbols.scm");
+const LUA_QUERY: &str = include_str!("../../queries/lua-symbols.scm");
 
 pub struct SymbolIndexer {
     parsers: HashMap<Language, Parser>,
     queries: HashMap<Language, Query>,
     reference_queries: HashMap<Language, Query>,
+    tag_keys: TagKeys,
+    parse_timeout: Option<Duration>,
+    /// When the current file must be done by, shared by every parse and
+    /// query of it; set by `start_file`
+    file_deadline: Option<Instant>,
+    route_patterns: RoutePatterns,
+    type_argument_references: bool,
 }
 
 impl SymbolIndexer {
     pub fn new() -> Result<Self, Box<dyn std::error::Error>> {
+        Self::with_languages(&Language::ALL)
+    }
+
+    /// Indexer with parsers and queries for only the given languages
+    pub fn with_languages(languages: &[Language]) -> Result<Self, Box<dyn std::error::Error>> {
         let mut indexer = Self {
             parsers: HashMap::new(),
             queries: HashMap::new(),
             reference_queries: HashMap::new(),
+            tag_keys: TagKeys::from_env(),
+            parse_timeout: None,
+            file_deadline: None,
+            route_patterns: RoutePatterns::default(),
+            type_argument_references: true,
         };
 
         // Initialize parsers and queries for each language
-        indexer.init_language(Language::Rust)?;
-        indexer.init_language(Language::Python)?;
-        indexer.init_language(Language::C)?;
-        indexer.init_language(Language::Cpp)?;
-        indexer.init_language(Language::Java)?;
-        indexer.init_language(Language::Go)?;
-        indexer.init_language(Language::JavaScript)?;
-        indexer.init_language(Language::TypeScript)?;
-        indexer.init_language(Language::Ruby)?;
-        indexer.init_language(Language::CSharp)?;
-        indexer.init_language(Language::Kotlin)?;
-        indexer.init_language(Language::Scala)?;
-        indexer.init_language(Language::Swift)?;
-        indexer.init_language(Language::PHP)?;
-        indexer.init_language(Language::ObjectiveC)?;
+        for language in languages {
+            indexer.init_language(*language)?;
+        }
 
         Ok(indexer)
     }
@@ -80,6 +107,7 @@ impl SymbolIndexer {
             Language::Swift => SWIFT_QUERY,
             Language::PHP => PHP_QUERY,
             Language::ObjectiveC => OBJC_QUERY,
+            Language::Lua => LUA_QUERY,
         };
 
         let query = Query::new(&ts_language, query_source)
@@ -103,6 +131,7 @@ impl SymbolIndexer {
             Language::Swift => include_str!("../../queries/swift-references.scm"),
             Language::PHP => include_str!("../../queries/php-references.scm"),
             Language::ObjectiveC => include_str!("../../queries/objc-references.scm"),
+            Language::Lua => include_str!("../../queries/lua-references.scm"),
         };
 
         let ref_query = Query::new(&ts_language, ref_query_source).map_err(|e| {
@@ -122,6 +151,7 @@ impl SymbolIndexer {
         language: Language,
         file_path: &PathBuf,
     ) -> Result<Vec<Symbol>, Box<dyn std::error::Error>> {
+        let deadline = self.deadline();
         let parser = self
             .parsers
             .get_mut(&language)
@@ -133,7 +163,7 @@ impl SymbolIndexer {
             .ok_or("Query not found for language")?;
 
         // Parse source code with error handling
-        let tree = match parser.parse(source, None) {
+        let tree = match parse_until(parser, source, deadline)? {
             Some(tree) => tree,
             None => {
                 // Return empty symbols instead of failing completely
@@ -147,19 +177,107 @@ impl SymbolIndexer {
             tracing::warn!("Parse tree contains errors, extracting partial symbols");
         }
 
+        // Resolve the package/module declared by the file, falling back to its location
+        let file_namespace = Self::declared_namespace(tree.root_node(), source)
+            .or_else(|| Self::fallback_namespace(file_path, language));
+
         let mut symbols = Vec::new();
         let mut cursor = tree_sitter::QueryCursor::new();
 
         // Execute tree-sitter query and extract symbols
         let mut matches = cursor.matches(&query, tree.root_node(), source.as_bytes());
+        let mut matched = 0;
         while let Some(match_) = matches.next() {
-            if let Some(symbol) =
-                self.create_symbol_from_match(&match_, source, file_path, language)
-            {
+            matched += 1;
+            check_deadline(deadline, matched)?;
+            if let Some(symbol) = self.create_symbol_from_match(
+                &match_,
+                source,
+                file_path,
+                language,
+                file_namespace.as_deref(),
+            ) {
                 symbols.push(symbol);
             }
         }
 
+        // Go inline struct and interface types get synthetic names so they stay searchable
+        if language == Language::Go {
+            symbols.extend(anonymous_type_symbols(
+                tree.root_node(),
+                source,
+                file_path,
+                file_namespace.as_deref(),
+            ));
+            if !self.route_patterns.is_empty() {
+                symbols.extend(route_symbols(
+                    tree.root_node(),
+                    source,
+                    file_path,
+                    file_namespace.as_deref(),
+                    &self.route_patterns,
+                ));
+            }
+        }
+
+        Self::drop_shadowed_variables(&mut symbols);
+
+        // Platform-specific Go files only exist in some builds; record the constraint
+        if language == Language::Go {
+            if let Some(constraint) = file_constraint(file_path, source) {
+                for symbol in &mut symbols {
+                    symbol
+                        .tags
+                        .insert(BUILD_TAG.to_string(), constraint.clone());
+                }
+            }
+
+            // Members of iota const blocks record their enum and computed value
+            for go_enum in go_enums(tree.root_node(), source) {
+                for member in &go_enum.members {
+                    let symbol = symbols.iter_mut().find(|symbol| {
+                        symbol.name == member.name
+                            && symbol.location.start_line <= member.line
+                            && symbol.location.end_line >= member.line
+                    });
+                    if let Some(symbol) = symbol {
+                        symbol
+                            .tags
+                            .insert(ENUM_TAG.to_string(), go_enum.name.clone());
+                        if let Some(value) = member.value {
+                            symbol
+                                .tags
+                                .insert(ENUM_VALUE_TAG.to_string(), value.to_string());
+                        }
+                    }
+                }
+            }
+
+            // Code that runs when the package is initialized
+            for initializer in go_initializers(tree.root_node(), source).initializers {
+                let value = match initializer.kind {
+                    InitKind::Init => "init",
+                    InitKind::Var if initializer.non_trivial => "var",
+                    InitKind::Var => continue,
+                };
+                for symbol in symbols.iter_mut().filter(|symbol| {
+                    symbol.location.start_line == initializer.line
+                        && initializer.name.split(", ").any(|name| name == symbol.name)
+                }) {
+                    symbol.tags.insert(INIT_TAG.to_string(), value.to_string());
+                }
+            }
+        }
+
+        // Lua modules expose their surface through the table they return
+        if language == Language::Lua {
+            apply_module_surface(tree.root_node(), source, &mut symbols);
+        }
+
+        if language == Language::Scala {
+            apply_scala_model(tree.root_node(), source, file_path, &mut symbols);
+        }
+
         Ok(symbols)
     }
 
@@ -174,35 +292,70 @@ impl SymbolIndexer {
             .get(&language)
             .ok_or("Reference query not found")?;
 
+        let deadline = self.deadline();
         let parser = self.parsers.get_mut(&language).ok_or("Parser not found")?;
 
-        let tree = parser.parse(source, None).ok_or("Failed to parse")?;
+        let tree = parse_until(parser, source, deadline)?.ok_or("Failed to parse")?;
 
         let mut references = Vec::new();
+        // Patterns overlap (a method name is both a call and a field access),
+        // so each node is recorded once
+        let mut seen = HashSet::new();
         let mut cursor = tree_sitter::QueryCursor::new();
 
+        let mut nodes = Vec::new();
         let mut matches = cursor.matches(query, tree.root_node(), source.as_bytes());
+        let mut matched = 0;
         while let Some(match_) = matches.next() {
+            matched += 1;
+            check_deadline(deadline, matched)?;
             for capture in match_.captures {
-                let node = capture.node;
-                let start_pos = node.start_position();
-                let end_pos = node.end_position();
-
-                let location = Location::new(
-                    file_path.clone(),
-                    start_pos.row as u32 + 1,
-                    start_pos.column as u32,
-                    end_pos.row as u32 + 1,
-                    end_pos.column as u32,
-                );
-
-                references.push(Reference {
-                    location,
-                    reference_type: ReferenceType::Usage,
-                    target_symbol: SymbolId::new(file_path, 0, 0), // Will be resolved later
-                });
+                // A captured type expression such as C++ `vector<User>` is
+                // recorded as the types it names
+                if is_type_expression(capture.node) {
+                    nodes.extend(named_types(capture.node));
+                } else {
+                    nodes.push(capture.node);
+                }
             }
         }
+        // Types nested in slices, maps, pointers, channels and type
+        // arguments, where the query only captures bare identifiers
+        nodes.extend(nested_type_names(tree.root_node()));
+
+        for node in nodes {
+            if !seen.insert(node.byte_range()) {
+                continue;
+            }
+            if !self.type_argument_references && in_type_arguments(node) {
+                continue;
+            }
+            let start_pos = node.start_position();
+            let end_pos = node.end_position();
+
+            let location = Location::new(
+                file_path.clone(),
+                start_pos.row as u32 + 1,
+                start_pos.column as u32,
+                end_pos.row as u32 + 1,
+                end_pos.column as u32,
+            );
+
+            // Method values such as `(*Conn).Close` passed as callbacks
+            // stay usages of the method
+            let reference_type = if is_callee(node) {
+                ReferenceType::Call
+            } else if is_constructed(node) {
+                ReferenceType::Construction
+            } else {
+                ReferenceType::Usage
+            };
+            references.push(Reference {
+                location,
+                reference_type,
+                target_symbol: SymbolId::new(file_path, 0, 0), // Will be resolved later
+            });
+        }
 
         Ok(references)
     }
@@ -213,6 +366,7 @@ impl SymbolIndexer {
         source: &str,
         file_path: &PathBuf,
         language: Language,
+        file_namespace: Option<&str>,
     ) -> Option<Symbol> {
         let query = self.queries.get(&language)?;
 
@@ -232,7 +386,7 @@ impl SymbolIndexer {
 
         // Determine symbol type from capture name
         let capture_name = &query.capture_names()[name_capture.index as usize];
-        let symbol_type = self.determine_symbol_type(capture_name);
+        let mut symbol_type = self.determine_symbol_type(capture_name);
 
         // Use definition node for location if available, otherwise use name node
         let location_node = definition_capture.map(|c| c.node).unwrap_or(name_node);
@@ -250,17 +404,177 @@ impl SymbolIndexer {
         // Generate symbol ID
         let symbol_id = SymbolId::new(file_path, location.start_line, location.start_column);
 
+        // Promote test functions (Go TestXxx, Python test_xxx, Rust #[test]) to their own kind
+        if matches!(symbol_type, SymbolType::Function | SymbolType::Method)
+            && is_test_function(&name, location_node, source, file_path, language)
+        {
+            symbol_type = SymbolType::Test;
+        }
+
+        // Capture signatures for callables
+        let signature = match symbol_type {
+            SymbolType::Function | SymbolType::Method | SymbolType::Test => {
+                definition_capture.and_then(|c| extract_signature(c.node, source))
+            }
+            _ => None,
+        };
+
+        let mut tags = self.tag_keys.extract(location_node, source);
+        tags.extend(attribute_tags(location_node, source, language));
+
+        // Return counts feed readability metrics; functions without returns are untagged
+        if matches!(symbol_type, SymbolType::Function | SymbolType::Method) {
+            if let Some(definition) = definition_capture {
+                let count = count_returns(definition.node);
+                if count.returns > 0 {
+                    tags.insert(RETURN_COUNT_TAG.to_string(), count.returns.to_string());
+                    tags.insert(
+                        EARLY_RETURN_COUNT_TAG.to_string(),
+                        count.early_returns.to_string(),
+                    );
+                }
+            }
+        }
+
+        // Go functions record the side effects their own body shows
+        if language == Language::Go
+            && matches!(symbol_type, SymbolType::Function | SymbolType::Method)
+        {
+            if let Some(effects) =
+                definition_capture.and_then(|definition| function_effects(definition.node, source))
+            {
+                tags.insert(SIDE_EFFECTS_TAG.to_string(), effects.tag_value());
+            }
+        }
+
+        // Go type declarations record whether they alias a type or define a new one
+        if language == Language::Go {
+            tags.extend(go_type_tags(location_node, source));
+        }
+
+        // Package-level Go vars record the type their declaration shows and
+        // the call that decides it otherwise
+        if language == Language::Go && symbol_type == SymbolType::Variable {
+            tags.extend(go_var_tags(location_node, &name, source));
+        }
+
+        // Package docs; a file without one gets none rather than a guess
+        let doc = match symbol_type {
+            SymbolType::Module => package_doc(location_node, source),
+            _ => None,
+        };
+
+        // Type aliases and re-exports stand for another symbol
+        let alias_of = alias_target(location_node, name_node, source);
+
+        // Nested namespace blocks take precedence over the file-level package
+        let namespace = Self::enclosing_namespace(location_node, source, language)
+            .or_else(|| file_namespace.map(String::from));
+
         Some(Symbol {
             id: symbol_id,
             name,
             symbol_type,
             location,
-            namespace: None,                // TODO: Extract namespace in future
+            namespace,
             visibility: Visibility::Public, // TODO: Determine visibility
             source: None,
+            signature,
+            tags,
+            doc,
+            alias_of,
         })
     }
 
+    /// A declaration matched by several patterns (a package var holding a closure
+    /// is both a variable and a function) keeps only its more specific symbol
+    fn drop_shadowed_variables(symbols: &mut Vec<Symbol>) {
+        let specific: HashSet<(SymbolId, String)> = symbols
+            .iter()
+            .filter(|s| s.symbol_type != SymbolType::Variable)
+            .map(|s| (s.id, s.name.clone()))
+            .collect();
+        symbols.retain(|s| {
+            s.symbol_type != SymbolType::Variable || !specific.contains(&(s.id, s.name.clone()))
+        });
+    }
+
+    /// Find the package/namespace declared at the top level of a file
+    fn declared_namespace(root: tree_sitter::Node, source: &str) -> Option<String> {
+        for i in 0..root.named_child_count() {
+            let child = root.named_child(i)?;
+            let is_package = match child.kind() {
+                // Go, Java, Kotlin, Scala and file-scoped C# namespaces
+                "package_clause"
+                | "package_declaration"
+                | "package_header"
+                | "file_scoped_namespace_declaration" => true,
+                // PHP `namespace Foo;` without a block body
+                "namespace_definition" => child.child_by_field_name("body").is_none(),
+                _ => false,
+            };
+            if !is_package {
+                continue;
+            }
+
+            let name_node = child
+                .child_by_field_name("name")
+                .or_else(|| child.named_child(0))?;
+            let name = name_node.utf8_text(source.as_bytes()).ok()?.trim();
+            if !name.is_empty() {
+                return Some(name.to_string());
+            }
+        }
+        None
+    }
+
+    /// Collect the names of namespace blocks enclosing a node
+    fn enclosing_namespace(
+        node: tree_sitter::Node,
+        source: &str,
+        language: Language,
+    ) -> Option<String> {
+        let (kinds, separator): (&[&str], &str) = match language {
+            Language::CSharp => (&["namespace_declaration"], "."),
+            Language::Cpp => (&["namespace_definition"], "::"),
+            Language::PHP => (&["namespace_definition"], "\\"),
+            Language::Rust => (&["mod_item"], "::"),
+            Language::Ruby => (&["module"], "::"),
+            Language::TypeScript => (&["internal_module", "module"], "."),
+            _ => return None,
+        };
+
+        let mut parts = Vec::new();
+        let mut current = node.parent();
+        while let Some(parent) = current {
+            if kinds.contains(&parent.kind()) {
+                if let Some(name) = parent
+                    .child_by_field_name("name")
+                    .and_then(|n| n.utf8_text(source.as_bytes()).ok())
+                {
+                    parts.push(name.to_string());
+                }
+            }
+            current = parent.parent();
+        }
+
+        if parts.is_empty() {
+            return None;
+        }
+        parts.reverse();
+        Some(parts.join(separator))
+    }
+
+    /// Namespace for files without a declaration: the module name for Python and Lua, else the directory
+    fn fallback_namespace(file_path: &PathBuf, language: Language) -> Option<String> {
+        let component = if matches!(language, Language::Python | Language::Lua) {
+            file_path.file_stem()
+        } else {
+            file_path.parent().and_then(|parent| parent.file_name())
+        };
+        component.map(|name| name.to_string_lossy().to_string())
+    }
+
     fn determine_symbol_type(&self, capture_name: &str) -> SymbolType {
         if capture_name.starts_with("function") {
             SymbolType::Function
@@ -287,6 +601,52 @@ impl SymbolIndexer {
         }
     }
 
+    /// Doc comment tag keys attached to symbols as metadata
+    pub fn set_tag_keys(&mut self, tag_keys: TagKeys) {
+        self.tag_keys = tag_keys;
+    }
+
+    pub fn tag_keys(&self) -> &TagKeys {
+        &self.tag_keys
+    }
+
+    /// Give up on a file once parsing it takes longer than `timeout`;
+    /// `None` lets every parse run to completion
+    pub fn set_parse_timeout(&mut self, timeout: Option<Duration>) {
+        self.parse_timeout = timeout;
+    }
+
+    /// Start the parse timeout for a file. Symbol and reference extraction
+    /// of it share the one budget until `finish_file`.
+    pub fn start_file(&mut self) {
+        self.file_deadline = self.parse_timeout.map(|timeout| Instant::now() + timeout);
+    }
+
+    pub fn finish_file(&mut self) {
+        self.file_deadline = None;
+    }
+
+    /// The current file's deadline, or outside `start_file` a fresh one for
+    /// a single extraction
+    fn deadline(&self) -> Option<(Instant, Duration)> {
+        let timeout = self.parse_timeout?;
+        let deadline = self
+            .file_deadline
+            .unwrap_or_else(|| Instant::now() + timeout);
+        Some((deadline, timeout))
+    }
+
+    /// Extract the routes registered with these router calls in Go files
+    pub fn set_route_patterns(&mut self, patterns: RoutePatterns) {
+        self.route_patterns = patterns;
+    }
+
+    /// Whether the type arguments of generic instantiations, `User` of
+    /// `List[User]`, are recorded as references to their types
+    pub fn set_type_argument_references(&mut self, enabled: bool) {
+        self.type_argument_references = enabled;
+    }
+
     pub fn get_parser(&mut self, language: Language) -> Option<&mut Parser> {
         self.parsers.get_mut(&language)
     }
@@ -300,9 +660,111 @@ impl SymbolIndexer {
     }
 }
 
+/// The comment directly above a package declaration, without build
+/// directives such as `//go:build`
+fn package_doc(declaration: tree_sitter::Node, source: &str) -> Option<String> {
+    let comment = doc_comment(declaration, source)?;
+    let lines: Vec<&str> = comment
+        .lines()
+        .filter(|line| {
+            let line = line.trim();
+            !line.starts_with("//go:") && !line.starts_with("// +build")
+        })
+        .collect();
+    (!lines.is_empty()).then(|| lines.join("\n"))
+}
+
+/// The symbol a type alias or re-export stands for: `U` for Go
+/// `type T = U`, `pkg.U` for `type T = pkg.U`, `X` for TypeScript
+/// `export { X } from './y'`. Aliases of composite types such as
+/// `type Pair = [2]int` stand for no single symbol.
+fn alias_target(
+    definition: tree_sitter::Node,
+    name: tree_sitter::Node,
+    source: &str,
+) -> Option<String> {
+    if name
+        .parent()
+        .is_some_and(|parent| parent.kind() == "export_specifier")
+    {
+        return name.utf8_text(source.as_bytes()).ok().map(String::from);
+    }
+    let target = match definition.kind() {
+        "type_alias" => definition.child_by_field_name("type"),
+        "type_alias_declaration" => definition.child_by_field_name("value"),
+        _ => None,
+    }?;
+    let text = target.utf8_text(source.as_bytes()).ok()?;
+    // Generic instantiations alias their base type
+    let base = text.split(['[', '<']).next()?.trim();
+    let is_name = !base.is_empty()
+        && base
+            .chars()
+            .all(|c| c.is_alphanumeric() || c == '_' || c == '.');
+    is_name.then(|| base.to_string())
+}
+
+/// Query matches processed between deadline checks
+const DEADLINE_CHECK_MATCHES: usize = 256;
+
+/// Parse `source`, abandoning the parse once it runs past `deadline`.
+/// `Ok(None)` is tree-sitter declining to parse, as with `Parser::parse`.
+fn parse_until(
+    parser: &mut Parser,
+    source: &str,
+    deadline: Option<(Instant, Duration)>,
+) -> Result<Option<Tree>, CodeAnalysisError> {
+    let Some((deadline, timeout)) = deadline else {
+        return Ok(parser.parse(source, None));
+    };
+    // A small file may parse without ever reaching the progress callback
+    if Instant::now() >= deadline {
+        return Err(CodeAnalysisError::ParseTimeout {
+            timeout_ms: timeout.as_millis() as u64,
+        });
+    }
+
+    let mut expired = |_: &tree_sitter::ParseState| Instant::now() >= deadline;
+    let options = ParseOptions::new().progress_callback(&mut expired);
+    let bytes = source.as_bytes();
+    let tree = parser.parse_with_options(
+        &mut |offset, _| bytes.get(offset..).unwrap_or_default(),
+        None,
+        Some(options),
+    );
+    if tree.is_none() && Instant::now() >= deadline {
+        // A cancelled parse resumes on the next call unless reset
+        parser.reset();
+        return Err(CodeAnalysisError::ParseTimeout {
+            timeout_ms: timeout.as_millis() as u64,
+        });
+    }
+    Ok(tree)
+}
+
+/// Fail once `deadline` has passed, looking at the clock on the first query
+/// match and then only every `DEADLINE_CHECK_MATCHES` matches
+fn check_deadline(
+    deadline: Option<(Instant, Duration)>,
+    matched: usize,
+) -> Result<(), CodeAnalysisError> {
+    match deadline {
+        Some((deadline, timeout))
+            if (matched == 1 || matched % DEADLINE_CHECK_MATCHES == 0)
+                && Instant::now() >= deadline =>
+        {
+            Err(CodeAnalysisError::ParseTimeout {
+                timeout_ms: timeout.as_millis() as u64,
+            })
+        }
+        _ => Ok(()),
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
+    use crate::models::{ChannelDirection, ChannelType, SignatureStyle};
     use std::path::Path;
 
     #[test]
@@ -407,6 +869,938 @@ test_var = 42
             .any(|s| s.name == "TestClass" && s.symbol_type == SymbolType::Class));
     }
 
+    #[test]
+    fn test_lua_symbol_extraction() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let lua_code = r#"
+local M = {}
+
+function M.open(path)
+end
+
+M.close = function(handle) end
+
+local function helper() end
+
+local Account = {}
+
+function Account:deposit(v)
+end
+
+return M
+"#;
+
+        let symbols = indexer
+            .extract_symbols(lua_code, Language::Lua, &PathBuf::from("lib/store.lua"))
+            .unwrap();
+        let find = |name: &str| symbols.iter().find(|s| s.name == name).unwrap();
+
+        assert_eq!(find("M").symbol_type, SymbolType::Module);
+        assert_eq!(find("open").visibility, Visibility::Public);
+        assert_eq!(find("close").visibility, Visibility::Public);
+        assert_eq!(find("helper").visibility, Visibility::Private);
+        assert_eq!(find("deposit").visibility, Visibility::Private);
+        assert_eq!(
+            find("deposit")
+                .signature
+                .as_ref()
+                .unwrap()
+                .receiver
+                .as_deref(),
+            Some("self Account")
+        );
+        assert_eq!(find("open").namespace.as_deref(), Some("store"));
+    }
+
+    #[test]
+    fn test_file_deadline_shared_across_passes() {
+        let mut indexer = SymbolIndexer::with_languages(&[Language::Go]).unwrap();
+        let go_code = "package main\n\nfunc main() { run() }\n";
+        let file_path = PathBuf::from("main.go");
+        indexer.set_parse_timeout(Some(Duration::from_millis(20)));
+
+        // Each pass alone fits in the budget
+        indexer.start_file();
+        assert!(indexer
+            .extract_symbols(go_code, Language::Go, &file_path)
+            .is_ok());
+        std::thread::sleep(Duration::from_millis(30));
+        // The reference pass has no budget left of the file's
+        let error = indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .unwrap_err();
+        assert!(matches!(
+            error.downcast_ref::<CodeAnalysisError>(),
+            Some(CodeAnalysisError::ParseTimeout { timeout_ms: 20 })
+        ));
+
+        // Outside a file each extraction gets its own budget
+        indexer.finish_file();
+        assert!(indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .is_ok());
+    }
+
+    #[test]
+    fn test_lua_syntax_versions() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        // Lua 5.1: varargs through select, global unpack, module-less tables
+        let lua51 = r##"
+local unpack = table.unpack or unpack
+
+local function sum(...)
+    local total = 0
+    for i = 1, select("#", ...) do
+        total = total + select(i, ...)
+    end
+    return total
+end
+
+function apply(f, args)
+    return f(unpack(args))
+end
+"##;
+        // Lua 5.3+: integer division, bitwise operators, goto and labels,
+        // 5.4 attributes
+        let lua53 = r##"
+local bits = {}
+
+function bits.pages(total, size)
+    return (total + size - 1) // size
+end
+
+function bits.mask(a, b)
+    return (a & b) | (a ~ b) | ~a | (a << 2) | (b >> 1)
+end
+
+function bits.skip_odd(values)
+    local limit <const> = #values
+    for i = 1, limit do
+        if values[i] % 2 == 1 then goto continue end
+        print(values[i])
+        ::continue::
+    end
+end
+
+function bits.last() end
+
+return bits
+"##;
+
+        let symbols = indexer
+            .extract_symbols(lua51, Language::Lua, &PathBuf::from("sum.lua"))
+            .unwrap();
+        let names: Vec<&str> = symbols.iter().map(|s| s.name.as_str()).collect();
+        assert!(names.contains(&"sum"));
+        assert!(names.contains(&"apply"));
+
+        let symbols = indexer
+            .extract_symbols(lua53, Language::Lua, &PathBuf::from("bits.lua"))
+            .unwrap();
+        let find = |name: &str| symbols.iter().find(|s| s.name == name).unwrap();
+        assert_eq!(find("bits").symbol_type, SymbolType::Module);
+        for name in ["pages", "mask", "skip_odd", "last"] {
+            assert_eq!(find(name).visibility, Visibility::Public, "{}", name);
+        }
+        assert_eq!(find("skip_odd").location.start_line, 12);
+    }
+
+    #[test]
+    fn test_go_closures_and_anonymous_types() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package handlers
+
+var handler = func(w Writer) error { return nil }
+
+func Load() struct{ Name string } {
+    return struct{ Name string }{}
+}
+"#;
+
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("handlers.go"))
+            .unwrap();
+
+        let handlers: Vec<_> = symbols.iter().filter(|s| s.name == "handler").collect();
+        assert_eq!(handlers.len(), 1);
+        assert_eq!(handlers[0].symbol_type, SymbolType::Function);
+        let signature = handlers[0].signature.as_ref().unwrap();
+        assert_eq!(signature.parameters[0].name.as_deref(), Some("w"));
+        assert_eq!(signature.return_type.as_deref(), Some("error"));
+
+        assert!(symbols
+            .iter()
+            .any(|s| s.name == "Load.return" && s.symbol_type == SymbolType::Class));
+    }
+
+    #[test]
+    fn test_doc_comment_tags() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package billing
+
+// Charge bills a customer.
+// @owner: payments-team
+// @stability: experimental
+func Charge() {}
+
+// @owner: platform
+type Invoice struct{}
+
+func Refund() {}
+"#;
+
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("billing.go"))
+            .unwrap();
+        let find = |name: &str| symbols.iter().find(|s| s.name == name).unwrap();
+
+        assert_eq!(find("Charge").tags["owner"], "payments-team");
+        assert_eq!(find("Charge").tags["stability"], "experimental");
+        assert_eq!(find("Invoice").tags["owner"], "platform");
+        assert!(find("Refund").tags.is_empty());
+    }
+
+    #[test]
+    fn test_attribute_tags() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let mut extract = |code: &str, language: Language, file: &str| {
+            indexer
+                .extract_symbols(code, language, &PathBuf::from(file))
+                .unwrap()
+        };
+        let tags = |symbols: &[Symbol], name: &str| {
+            symbols
+                .iter()
+                .find(|s| s.name == name)
+                .unwrap()
+                .tags
+                .clone()
+        };
+
+        let java = extract(
+            r#"
+class Users {
+    @Override
+    @Deprecated(since = "9")
+    public String toString() { return ""; }
+}
+"#,
+            Language::Java,
+            "Users.java",
+        );
+        assert_eq!(tags(&java, "toString")["@Override"], "");
+        assert_eq!(tags(&java, "toString")["@Deprecated"], "since = \"9\"");
+
+        let python = extract(
+            r#"
+@app.route("/users")
+@login_required
+def list_users():
+    pass
+"#,
+            Language::Python,
+            "views.py",
+        );
+        assert_eq!(tags(&python, "list_users")["@app.route"], "\"/users\"");
+        assert!(tags(&python, "list_users").contains_key("@login_required"));
+
+        let rust = extract(
+            "#[derive(Debug, Clone)]\n#[serde(rename_all = \"snake_case\")]\nstruct Config {}\n",
+            Language::Rust,
+            "config.rs",
+        );
+        assert_eq!(tags(&rust, "Config")["@derive"], "Debug, Clone");
+        assert_eq!(
+            tags(&rust, "Config")["@serde"],
+            "rename_all = \"snake_case\""
+        );
+
+        let typescript = extract(
+            "@Component({ selector: 'app-root' })\nclass AppComponent {}\n",
+            Language::TypeScript,
+            "app.ts",
+        );
+        assert!(tags(&typescript, "AppComponent").contains_key("@Component"));
+
+        let csharp = extract(
+            "class Api {\n    [Obsolete(\"Use Get\")]\n    public void Fetch() {}\n}\n",
+            Language::CSharp,
+            "Api.cs",
+        );
+        assert_eq!(tags(&csharp, "Fetch")["@Obsolete"], "\"Use Get\"");
+
+        let go = extract(
+            r#"
+package users
+
+//nolint:errcheck
+func Flush() {}
+
+type User struct {
+    ID        int    `json:"id"`
+    CreatedAt string `json:"created_at,omitempty" db:"created_at"`
+}
+"#,
+            Language::Go,
+            "users.go",
+        );
+        assert_eq!(tags(&go, "Flush")["@nolint"], "errcheck");
+        assert_eq!(tags(&go, "User")["@json"], "id,created_at");
+        assert_eq!(tags(&go, "User")["@db"], "created_at");
+    }
+
+    #[test]
+    fn test_go_build_constraints() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let code = "//go:build !cgo\n\npackage poll\n\nfunc Wait() {}\n";
+
+        let linux = indexer
+            .extract_symbols(code, Language::Go, &PathBuf::from("poll_linux.go"))
+            .unwrap();
+        let windows = indexer
+            .extract_symbols(code, Language::Go, &PathBuf::from("poll_windows.go"))
+            .unwrap();
+        let portable = indexer
+            .extract_symbols(
+                "package poll\n\nfunc Wait() {}\n",
+                Language::Go,
+                &PathBuf::from("poll.go"),
+            )
+            .unwrap();
+
+        assert_eq!(linux[0].tags["build"], "!cgo && linux");
+        assert!(linux[0].has_tag("build:linux"));
+        assert!(!windows[0].has_tag("build:linux"));
+        assert!(!linux[0].has_tag("build:linux,cgo"));
+        assert!(portable[0].has_tag("build:linux"));
+        assert!(portable[0].has_tag("build:windows"));
+    }
+
+    #[test]
+    fn test_go_enum_tags() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let code =
+            "package perm\n\ntype Perm uint8\n\nconst (\n\tRead Perm = 1 << iota\n\tWrite\n)\n";
+        let symbols = indexer
+            .extract_symbols(code, Language::Go, &PathBuf::from("perm.go"))
+            .unwrap();
+
+        let write = symbols.iter().find(|s| s.name == "Write").unwrap();
+        assert_eq!(write.tags["enum"], "Perm");
+        assert_eq!(write.tags["enum_value"], "2");
+        assert!(write.has_tag("enum:Perm"));
+    }
+
+    #[test]
+    fn test_go_package_namespace() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package storage
+
+func NewStore() *Store { return nil }
+"#;
+
+        let file_path = PathBuf::from("pkg/db/store.go");
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &file_path)
+            .unwrap();
+
+        let func = symbols.iter().find(|s| s.name == "NewStore").unwrap();
+        assert_eq!(func.namespace.as_deref(), Some("storage"));
+    }
+
+    #[test]
+    fn test_go_method_value_references() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package db
+
+func (s *Store) Run(ctx context.Context, q string) {
+    s.db.ExecuteQuery(ctx, q)
+    exec := (*PostgresConnection).ExecuteQuery
+    retry(s.db.ExecuteQuery, 3)
+    exec(s.db, ctx, q)
+}
+"#;
+        let file_path = PathBuf::from("db/store.go");
+        let references = indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .unwrap();
+
+        let lines: Vec<&str> = go_code.lines().collect();
+        let execute_query: Vec<(u32, ReferenceType)> = references
+            .iter()
+            .filter(|r| {
+                let line = lines[r.location.start_line as usize - 1];
+                line.get(r.location.start_column as usize..r.location.end_column as usize)
+                    == Some("ExecuteQuery")
+            })
+            .map(|r| (r.location.start_line, r.reference_type.clone()))
+            .collect();
+        assert_eq!(
+            execute_query,
+            vec![
+                (4, ReferenceType::Call),
+                (5, ReferenceType::Usage),
+                (6, ReferenceType::Usage),
+            ]
+        );
+        assert!(references
+            .iter()
+            .any(|r| r.location.start_line == 7 && r.reference_type == ReferenceType::Call));
+    }
+
+    #[test]
+    fn test_go_construction_references() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package users
+
+func GetUser(id string) *User {
+    var u User
+    page := Page[User]{Items: []User{}}
+    _ = models.User{Name: "x"}
+    return &User{ID: id}
+}
+"#;
+        let file_path = PathBuf::from("users/service.go");
+        let references = indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .unwrap();
+
+        let lines: Vec<&str> = go_code.lines().collect();
+        let user: Vec<(u32, ReferenceType)> = references
+            .iter()
+            .filter(|r| {
+                let line = lines[r.location.start_line as usize - 1];
+                line.get(r.location.start_column as usize..r.location.end_column as usize)
+                    == Some("User")
+            })
+            .map(|r| (r.location.start_line, r.reference_type.clone()))
+            .collect();
+        assert_eq!(
+            user,
+            vec![
+                (3, ReferenceType::Usage),
+                (4, ReferenceType::Usage),
+                (5, ReferenceType::Usage),
+                (5, ReferenceType::Usage),
+                (6, ReferenceType::Construction),
+                (7, ReferenceType::Construction),
+            ]
+        );
+        assert!(references.iter().any(|r| {
+            r.location.start_line == 5
+                && r.location.start_column == 12
+                && r.reference_type == ReferenceType::Construction
+        }));
+    }
+
+    #[test]
+    fn test_go_nested_type_references() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package store
+
+type Cache struct {
+    byID    map[string]*Product
+    pages   []Page[map[ID][]*models.User]
+    updates <-chan func(ctx context.Context, p *Product) (Result[List[Product]], error)
+    slots   [4]struct{ owner *Owner }
+}
+"#;
+        let file_path = PathBuf::from("store/cache.go");
+        let lines: Vec<&str> = go_code.lines().collect();
+        let names = |references: &[Reference]| -> Vec<(u32, String)> {
+            let mut names: Vec<(u32, String)> = references
+                .iter()
+                .filter_map(|r| {
+                    let line = lines[r.location.start_line as usize - 1];
+                    let name =
+                        line.get(r.location.start_column as usize..r.location.end_column as usize)?;
+                    name.starts_with(char::is_uppercase)
+                        .then(|| (r.location.start_line, name.to_string()))
+                })
+                .collect();
+            names.sort();
+            names
+        };
+
+        let references = indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .unwrap();
+        let expected: Vec<(u32, String)> = [
+            (3, "Cache"),
+            (4, "Product"),
+            (5, "ID"),
+            (5, "Page"),
+            (5, "User"),
+            (6, "Context"),
+            (6, "List"),
+            (6, "Product"),
+            (6, "Product"),
+            (6, "Result"),
+            (7, "Owner"),
+        ]
+        .iter()
+        .map(|(line, name)| (*line, name.to_string()))
+        .collect();
+        assert_eq!(names(&references), expected);
+
+        // Without type arguments only the generic types themselves remain
+        indexer.set_type_argument_references(false);
+        let references = indexer
+            .extract_references(go_code, Language::Go, &file_path)
+            .unwrap();
+        let kept: Vec<(u32, String)> = names(&references)
+            .into_iter()
+            .filter(|(line, _)| *line == 5 || *line == 6)
+            .collect();
+        assert_eq!(
+            kept,
+            vec![
+                (5, "Page".to_string()),
+                (6, "Context".to_string()),
+                (6, "Product".to_string()),
+                (6, "Result".to_string()),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_go_signature_extraction() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package main
+
+func (p *PostgresConnection) ExecuteQuery(ctx context.Context, query string, args ...interface{}) (*QueryResult, error) {
+    return nil, nil
+}
+
+func Map[T any, U any](xs []T, f func(T) U) []U {
+    return nil
+}
+"#;
+
+        let file_path = PathBuf::from("db.go");
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &file_path)
+            .unwrap();
+
+        let execute = symbols.iter().find(|s| s.name == "ExecuteQuery").unwrap();
+        let signature = execute.signature.as_ref().unwrap();
+        assert_eq!(
+            signature.text,
+            "func (p *PostgresConnection) ExecuteQuery(ctx context.Context, query string, args ...interface{}) (*QueryResult, error)"
+        );
+        assert_eq!(
+            signature.render("ExecuteQuery", SignatureStyle::Compact),
+            "ExecuteQuery(ctx, query, ...args) -> (*QueryResult, error)"
+        );
+
+        let map = symbols.iter().find(|s| s.name == "Map").unwrap();
+        assert_eq!(
+            map.signature
+                .as_ref()
+                .unwrap()
+                .render("Map", SignatureStyle::Compact),
+            "Map[T, U](xs, f) -> []U"
+        );
+    }
+
+    #[test]
+    fn test_go_channel_signatures() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package pubsub
+
+func (b *Broker) Subscribe(topic string, done <-chan struct{}) (<-chan User, error) {
+    return nil, nil
+}
+
+func Publish(out chan<- User, events chan Event) {}
+"#;
+
+        let file_path = PathBuf::from("broker.go");
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &file_path)
+            .unwrap();
+
+        let subscribe = symbols.iter().find(|s| s.name == "Subscribe").unwrap();
+        let signature = subscribe.signature.as_ref().unwrap();
+        assert_eq!(signature.parameters[0].channel, None);
+        assert_eq!(
+            signature.parameters[1].channel,
+            Some(ChannelType {
+                direction: ChannelDirection::Receive,
+                element_type: "struct{}".to_string(),
+            })
+        );
+        assert_eq!(
+            signature.return_channels,
+            vec![ChannelType {
+                direction: ChannelDirection::Receive,
+                element_type: "User".to_string(),
+            }]
+        );
+        assert_eq!(
+            signature.render("Subscribe", SignatureStyle::Compact),
+            "Subscribe(topic, done <-chan struct{}) -> (<-chan User, error)"
+        );
+
+        let publish = symbols.iter().find(|s| s.name == "Publish").unwrap();
+        let directions: Vec<Option<ChannelDirection>> = publish
+            .signature
+            .as_ref()
+            .unwrap()
+            .parameters
+            .iter()
+            .map(|p| p.channel.as_ref().map(|c| c.direction))
+            .collect();
+        assert_eq!(
+            directions,
+            vec![
+                Some(ChannelDirection::Send),
+                Some(ChannelDirection::Bidirectional)
+            ]
+        );
+    }
+
+    #[test]
+    fn test_go_deferred_calls() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package main
+
+func main() {
+    db := open()
+    defer db.Close()
+    run(db)
+}
+
+func (s *Service) CreateUser(ctx context.Context, u *User) (err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    tx, err := s.db.Begin(ctx)
+    defer func() {
+        if err != nil {
+            tx.Rollback(ctx)
+        }
+    }()
+    go func() {
+        defer wg.Done()
+    }()
+    return tx.Commit(ctx)
+}
+
+func helper() {}
+"#;
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("main.go"))
+            .unwrap();
+        let signature = |name: &str| {
+            symbols
+                .iter()
+                .find(|s| s.name == name)
+                .unwrap()
+                .signature
+                .clone()
+                .unwrap()
+        };
+
+        let main = signature("main");
+        assert!(main.uses_defer);
+        assert_eq!(main.deferred[0].target.as_deref(), Some("db.Close"));
+        assert_eq!(main.deferred[0].line, 5);
+
+        let create = signature("CreateUser");
+        assert_eq!(create.deferred.len(), 2);
+        assert_eq!(create.deferred[0].target.as_deref(), Some("s.mu.Unlock"));
+        assert!(create.deferred[1].closure);
+        assert_eq!(create.deferred[1].target, None);
+        assert_eq!(create.deferred[1].calls, vec!["tx.Rollback"]);
+
+        let helper = signature("helper");
+        assert!(!helper.uses_defer);
+        assert!(helper.deferred.is_empty());
+    }
+
+    #[test]
+    fn test_go_init_tags() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package cache
+
+var ErrMiss = errors.New("cache miss")
+var shared = NewMemoryCache()
+
+func init() {
+    register(shared)
+}
+
+func init() {
+    warm(shared)
+}
+"#;
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("cache.go"))
+            .unwrap();
+        let init_tag = |name: &str| -> Vec<Option<String>> {
+            symbols
+                .iter()
+                .filter(|s| s.name == name)
+                .map(|s| s.tags.get(INIT_TAG).cloned())
+                .collect()
+        };
+
+        assert_eq!(init_tag("ErrMiss"), vec![None]);
+        assert_eq!(init_tag("shared"), vec![Some("var".to_string())]);
+        assert_eq!(
+            init_tag("init"),
+            vec![Some("init".to_string()), Some("init".to_string())]
+        );
+    }
+
+    #[test]
+    fn test_go_package_doc() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let documented = r#"//go:build linux
+
+// Package store persists users.
+// It is safe for concurrent use.
+package store
+"#;
+        let symbols = indexer
+            .extract_symbols(documented, Language::Go, &PathBuf::from("store/doc.go"))
+            .unwrap();
+        let package = symbols
+            .iter()
+            .find(|s| s.symbol_type == SymbolType::Module)
+            .unwrap();
+        assert_eq!(
+            package.doc.as_deref(),
+            Some("// Package store persists users.\n// It is safe for concurrent use.")
+        );
+        assert!(symbols
+            .iter()
+            .filter(|s| s.symbol_type != SymbolType::Module)
+            .all(|s| s.doc.is_none()));
+
+        let undocumented = "// Copyright 2024 Example\n\npackage store\n";
+        let symbols = indexer
+            .extract_symbols(undocumented, Language::Go, &PathBuf::from("store/user.go"))
+            .unwrap();
+        assert_eq!(symbols[0].symbol_type, SymbolType::Module);
+        assert_eq!(symbols[0].doc, None);
+    }
+
+    #[test]
+    fn test_scala_model() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let scala_code = r#"package com.example.users
+
+trait Repository[T] {
+  def find(id: Long): Option[T]
+}
+
+case class User(id: Long, private val email: String) extends Entity with Serializable
+
+object User {
+  implicit val ordering: Ordering[User] = Ordering.by(_.id)
+  private[users] def fromRow(row: Row): User = ???
+}
+
+class UserService(val repo: Repository[User], clock: Clock) extends Service {
+  protected def now(): Long = clock.millis
+}
+"#;
+        let symbols = indexer
+            .extract_symbols(scala_code, Language::Scala, &PathBuf::from("Users.scala"))
+            .unwrap();
+        let find = |name: &str, symbol_type: SymbolType| {
+            symbols
+                .iter()
+                .find(|s| s.name == name && s.symbol_type == symbol_type)
+                .unwrap_or_else(|| panic!("{} not found", name))
+        };
+
+        assert_eq!(
+            find("Repository", SymbolType::Interface).visibility,
+            Visibility::Public
+        );
+        assert_eq!(find("find", SymbolType::Method).name, "find");
+
+        let (user_class, user_object): (Vec<&Symbol>, Vec<&Symbol>) = symbols
+            .iter()
+            .filter(|s| s.name == "User" && s.symbol_type == SymbolType::Class)
+            .partition(|s| s.tags.contains_key("case"));
+        assert_eq!(user_class[0].tags["extends"], "Entity, Serializable");
+        assert_eq!(user_class[0].tags["companion"], "object");
+        assert!(user_class[0].tags["generated"].contains("copy"));
+        assert_eq!(user_object[0].tags["companion"], "class");
+
+        assert_eq!(
+            find("id", SymbolType::Variable).visibility,
+            Visibility::Public
+        );
+        assert_eq!(
+            find("email", SymbolType::Variable).visibility,
+            Visibility::Private
+        );
+        assert_eq!(
+            find("repo", SymbolType::Variable).visibility,
+            Visibility::Public
+        );
+        assert!(!symbols.iter().any(|s| s.name == "clock"));
+
+        assert_eq!(
+            find("ordering", SymbolType::Variable).tags["implicit"],
+            "true"
+        );
+        assert_eq!(
+            find("fromRow", SymbolType::Method).visibility,
+            Visibility::Internal
+        );
+        assert_eq!(
+            find("now", SymbolType::Method).visibility,
+            Visibility::Protected
+        );
+        assert_eq!(
+            find("UserService", SymbolType::Class).tags["extends"],
+            "Service"
+        );
+    }
+
+    #[test]
+    fn test_alias_targets() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"package users
+
+type ID = ids.UUID
+type Repo = Repository
+type Pair = [2]int
+type User struct{}
+type Count int
+"#;
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("users/types.go"))
+            .unwrap();
+        let alias_of = |name: &str| {
+            symbols
+                .iter()
+                .find(|s| s.name == name)
+                .unwrap()
+                .alias_of
+                .clone()
+        };
+        assert_eq!(alias_of("ID").as_deref(), Some("ids.UUID"));
+        assert_eq!(alias_of("Repo").as_deref(), Some("Repository"));
+        assert_eq!(alias_of("Pair"), None);
+        assert_eq!(alias_of("User"), None);
+
+        let tag = |name: &str, key: &str| {
+            symbols
+                .iter()
+                .find(|s| s.name == name)
+                .unwrap()
+                .tags
+                .get(key)
+                .cloned()
+        };
+        assert_eq!(tag("Repo", "type_form").as_deref(), Some("alias"));
+        assert_eq!(tag("Pair", "type_form").as_deref(), Some("alias"));
+        assert_eq!(tag("Pair", "type_expr").as_deref(), Some("[2]int"));
+        assert_eq!(tag("User", "type_form").as_deref(), Some("defined"));
+        assert_eq!(tag("User", "type_expr"), None);
+        assert_eq!(tag("Count", "type_form").as_deref(), Some("defined"));
+        assert_eq!(tag("Count", "type_expr").as_deref(), Some("int"));
+
+        let ts_code = "export { UserService } from './user-service';\n";
+        let symbols = indexer
+            .extract_symbols(ts_code, Language::TypeScript, &PathBuf::from("index.ts"))
+            .unwrap();
+        let export = symbols.iter().find(|s| s.name == "UserService").unwrap();
+        assert_eq!(export.alias_of.as_deref(), Some("UserService"));
+    }
+
+    #[test]
+    fn test_python_default_parameters() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let python_code = r#"
+def connect(host, timeout=30, *, retries: int = 3, **options):
+    pass
+"#;
+
+        let file_path = PathBuf::from("client.py");
+        let symbols = indexer
+            .extract_symbols(python_code, Language::Python, &file_path)
+            .unwrap();
+
+        let connect = symbols.iter().find(|s| s.name == "connect").unwrap();
+        let parameters = &connect.signature.as_ref().unwrap().parameters;
+        let defaults: Vec<(Option<&str>, bool, Option<&str>)> = parameters
+            .iter()
+            .map(|p| (p.name.as_deref(), p.optional, p.default_value.as_deref()))
+            .collect();
+        assert_eq!(
+            defaults,
+            vec![
+                (Some("host"), false, None),
+                (Some("timeout"), true, Some("30")),
+                (Some("retries"), true, Some("3")),
+                (Some("options"), false, None),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_go_test_detection() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let go_code = r#"
+package users
+
+func TestCreateUser(t *testing.T) {}
+
+func BenchmarkCreateUser(b *testing.B) {}
+
+func helper() {}
+"#;
+
+        let test_path = PathBuf::from("users_test.go");
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &test_path)
+            .unwrap();
+        let kind_of = |name: &str| {
+            symbols
+                .iter()
+                .find(|s| s.name == name)
+                .map(|s| s.symbol_type.clone())
+        };
+        assert_eq!(kind_of("TestCreateUser"), Some(SymbolType::Test));
+        assert_eq!(kind_of("BenchmarkCreateUser"), Some(SymbolType::Test));
+        assert_eq!(kind_of("helper"), Some(SymbolType::Function));
+
+        // Same names outside a _test.go file are ordinary functions
+        let symbols = indexer
+            .extract_symbols(go_code, Language::Go, &PathBuf::from("users.go"))
+            .unwrap();
+        assert!(symbols.iter().all(|s| s.symbol_type != SymbolType::Test));
+    }
+
+    #[test]
+    fn test_namespace_fallback() {
+        let mut indexer = SymbolIndexer::new().unwrap();
+
+        let python_path = PathBuf::from("app/helpers.py");
+        let symbols = indexer
+            .extract_symbols("def helper():\n    pass\n", Language::Python, &python_path)
+            .unwrap();
+        assert_eq!(symbols[0].namespace.as_deref(), Some("helpers"));
+
+        let c_path = PathBuf::from("src/net/socket.c");
+        let symbols = indexer
+            .extract_symbols("int open_socket() { return 0; }", Language::C, &c_path)
+            .unwrap();
+        assert_eq!(symbols[0].namespace.as_deref(), Some("net"));
+    }
+
     #[test]
     fn test_language_support() {
         assert!(SymbolIndexer::supports_language(Language::Rust));
@@ -1,21 +1,70 @@
-use crate::indexing::indexer::SymbolIndexer;
-use crate::models::{FileInfo, Language, ParseStatus, Reference, Symbol};
-use crate::storage::cache::CacheManager;
+use crate::indexing::frontend::{FrontendConfig, FrontendRegistry, LanguageFrontend};
+use crate::indexing::kind_filter::KindFilter;
+use crate::indexing::language_priority::LanguagePriority;
+use crate::indexing::markdown::MarkdownFrontend;
+use crate::indexing::name_filter::NameFilter;
+use crate::indexing::routes::RoutePatterns;
+use crate::indexing::settings::{
+    reindex_scope, setting_var, ReindexScope, SettingChange, SettingsSnapshot,
+};
+use crate::indexing::tags::TagKeys;
+use crate::indexing::vendor::{VendorMode, VendorPaths, VENDOR_TAG};
+use crate::models::{FileInfo, ParseStatus, Reference, Symbol};
+use crate::storage::cache::{CacheManager, MergeReport, PersistedIndex};
 use crate::storage::store::SymbolStore;
 use crate::utils::error::{CodeAnalysisError, ErrorRecovery};
-use crate::utils::filesystem::FileSystemWalker;
+use crate::utils::filesystem::{FileSystemWalker, SymlinkPolicy};
+use crate::utils::git::{git_read_blobs, git_tree_files, GitChange, TreeFile};
 use sha2::{Digest, Sha256};
-use std::path::Path;
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
 use std::sync::Arc;
-use std::time::SystemTime;
+use std::time::{Duration, SystemTime};
+use tokio::sync::mpsc::UnboundedSender;
+
+/// Default maximum size of a file that will be parsed (1MB)
+const DEFAULT_MAX_FILE_SIZE_KB: u64 = 1024;
+/// Generous enough that only pathological inputs hit it
+const DEFAULT_PARSE_TIMEOUT_MS: u64 = 10_000;
 
 pub struct IndexingPipeline {
-    indexer: SymbolIndexer,
+    frontends: FrontendRegistry,
+    frontend_config: FrontendConfig,
     store: Arc<SymbolStore>,
     cache_manager: CacheManager,
+    max_file_size: u64,
+    kind_filter: KindFilter,
+    name_filter: NameFilter,
+    symlink_policy: SymlinkPolicy,
+    vendor_paths: VendorPaths,
+    /// The environment settings the pipeline was configured from
+    settings: SettingsSnapshot,
 }
 
-#[derive(Debug)]
+/// Events pushed while a single directory build runs
+#[derive(Debug, Clone)]
+pub enum IndexProgress {
+    /// Source files were discovered and indexing is about to begin
+    Started {
+        total_files: usize,
+    },
+    FileStarted {
+        path: PathBuf,
+        index: usize,
+    },
+    FileFinished {
+        path: PathBuf,
+        symbols: usize,
+    },
+    FileFailed {
+        path: PathBuf,
+        error: String,
+    },
+    /// Last event of the build; the channel closes right after it
+    Finished(IndexingResult),
+}
+
+#[derive(Debug, Clone)]
 pub struct IndexingResult {
     pub files_processed: u32,
     pub symbols_found: u32,
@@ -50,17 +99,276 @@ impl IndexingResult {
 
 impl IndexingPipeline {
     pub fn new(store: Arc<SymbolStore>) -> Result<Self, Box<dyn std::error::Error>> {
-        let indexer = SymbolIndexer::new()?;
+        let mut frontends = FrontendRegistry::with_builtin_frontends()?;
+        if MarkdownFrontend::enabled_from_env() {
+            frontends.register(Box::new(MarkdownFrontend::new()?));
+        }
         let cache_manager = CacheManager::new()?;
-        Ok(Self {
-            indexer,
+        let mut pipeline = Self {
+            frontends,
+            frontend_config: FrontendConfig {
+                tag_keys: TagKeys::from_env(),
+                parse_timeout: Self::parse_timeout_from_env(),
+                route_patterns: RoutePatterns::from_env(),
+                skip_type_arguments: !Self::type_argument_references_from_env(),
+            },
             store,
             cache_manager,
-        })
+            max_file_size: Self::max_file_size_from_env(),
+            kind_filter: KindFilter::from_env(),
+            name_filter: NameFilter::from_env(),
+            symlink_policy: SymlinkPolicy::from_env(),
+            vendor_paths: VendorPaths::from_env(),
+            settings: SettingsSnapshot::from_env(),
+        };
+        pipeline.frontends.configure(&pipeline.frontend_config);
+        pipeline
+            .frontends
+            .set_language_priority(LanguagePriority::from_env());
+        pipeline.refresh_index_config();
+        Ok(pipeline)
+    }
+
+    /// Record the settings that shape extracted symbols so cached indexes
+    /// built with other settings are rebuilt
+    fn refresh_index_config(&mut self) {
+        let config = format!(
+            "kinds={};names={};tags={};frontends={};languages={};routes={};type_args={};vendor={}",
+            self.kind_filter.fingerprint(),
+            self.name_filter.fingerprint(),
+            self.frontend_config.tag_keys.fingerprint(),
+            self.frontends.fingerprint(),
+            self.frontends.language_priority().fingerprint(),
+            self.frontend_config.route_patterns.fingerprint(),
+            !self.frontend_config.skip_type_arguments,
+            self.vendor_paths.fingerprint()
+        );
+        self.cache_manager.set_index_config(config);
+    }
+
+    /// Re-read the settings from the environment and apply the ones that
+    /// shape the index. Returns the settings changed since the pipeline was
+    /// created or last reloaded, each with the re-indexing it calls for; run
+    /// it with `reindex_directory`. Vendored files whose tagging changed are
+    /// marked to be parsed again.
+    pub fn reload_settings(&mut self) -> Vec<SettingChange> {
+        let current = SettingsSnapshot::from_env();
+        let changes = self.settings.changes(&current);
+        self.settings = current;
+        if reindex_scope(&changes) == ReindexScope::None {
+            return changes;
+        }
+
+        self.max_file_size = Self::max_file_size_from_env();
+        self.kind_filter = KindFilter::from_env();
+        self.name_filter = NameFilter::from_env();
+        self.symlink_policy = SymlinkPolicy::from_env();
+        self.frontend_config = FrontendConfig {
+            tag_keys: TagKeys::from_env(),
+            parse_timeout: Self::parse_timeout_from_env(),
+            route_patterns: RoutePatterns::from_env(),
+            skip_type_arguments: !Self::type_argument_references_from_env(),
+        };
+        self.frontends.configure(&self.frontend_config);
+        self.frontends
+            .set_language_priority(LanguagePriority::from_env());
+        if changes
+            .iter()
+            .any(|change| change.setting == "ROBERTO_INDEX_DOCS")
+        {
+            self.frontends.unregister("markdown");
+            if MarkdownFrontend::enabled_from_env() {
+                match MarkdownFrontend::new() {
+                    Ok(frontend) => self.register_frontend(Box::new(frontend)),
+                    Err(e) => tracing::warn!("Cannot index markdown code blocks: {}", e),
+                }
+            }
+        }
+
+        let vendor_paths = VendorPaths::from_env();
+        let vendor_tag = |paths: &VendorPaths, file: &Path| {
+            (paths.mode() == VendorMode::Separate)
+                .then(|| paths.vendor_prefix(file).map(str::to_string))
+                .flatten()
+        };
+        let retagged: Vec<PathBuf> = self
+            .store
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| vendor_tag(&self.vendor_paths, file) != vendor_tag(&vendor_paths, file))
+            .collect();
+        for file in &retagged {
+            self.mark_stale(file);
+        }
+        self.vendor_paths = vendor_paths;
+        self.refresh_index_config();
+        changes
+    }
+
+    /// Make the next build parse an indexed file again and replace its
+    /// symbols, even though its content did not change
+    fn mark_stale(&self, file_path: &PathBuf) {
+        if let Some(mut file_info) = self.store.get_file_info(file_path) {
+            file_info.content_hash = [0; 32];
+            self.store.update_file_info(file_path.clone(), file_info);
+        }
+    }
+
+    /// Maximum file size in bytes, configured through ROBERTO_MAX_FILE_SIZE_KB
+    pub fn max_file_size_from_env() -> u64 {
+        setting_var("ROBERTO_MAX_FILE_SIZE_KB")
+            .ok()
+            .and_then(|s| s.parse::<u64>().ok())
+            .unwrap_or(DEFAULT_MAX_FILE_SIZE_KB)
+            * 1024
+    }
+
+    /// Per-file parse timeout, configured through ROBERTO_PARSE_TIMEOUT_MS;
+    /// 0 disables it
+    pub fn parse_timeout_from_env() -> Option<Duration> {
+        let millis = setting_var("ROBERTO_PARSE_TIMEOUT_MS")
+            .ok()
+            .and_then(|s| s.parse::<u64>().ok())
+            .unwrap_or(DEFAULT_PARSE_TIMEOUT_MS);
+        (millis > 0).then(|| Duration::from_millis(millis))
+    }
+
+    /// Whether type arguments of generic instantiations are recorded as
+    /// references, configured through ROBERTO_TYPE_ARGUMENT_REFS (default: true)
+    pub fn type_argument_references_from_env() -> bool {
+        match setting_var("ROBERTO_TYPE_ARGUMENT_REFS") {
+            Ok(value) => !matches!(value.trim().to_lowercase().as_str(), "0" | "false" | "no"),
+            Err(_) => true,
+        }
+    }
+
+    /// Abandon files whose parse takes longer than `timeout`; `None` waits
+    /// for every parse to finish
+    pub fn set_parse_timeout(&mut self, timeout: Option<Duration>) {
+        self.frontend_config.parse_timeout = timeout;
+        self.frontends.configure(&self.frontend_config);
+    }
+
+    /// Override the maximum size in bytes of files that will be parsed
+    pub fn set_max_file_size(&mut self, max_file_size: u64) {
+        self.max_file_size = max_file_size;
+    }
+
+    /// Maximum size in bytes of files that will be parsed
+    pub fn max_file_size(&self) -> u64 {
+        self.max_file_size
+    }
+
+    /// Restrict which symbol kinds are stored per language. Cached indexes
+    /// built with a different filter are rebuilt instead of reused.
+    pub fn set_kind_filter(&mut self, kind_filter: KindFilter) {
+        self.kind_filter = kind_filter;
+        self.refresh_index_config();
+    }
+
+    pub fn kind_filter(&self) -> &KindFilter {
+        &self.kind_filter
+    }
+
+    /// Skip symbols by name length or pattern, with an allowlist override
+    pub fn set_name_filter(&mut self, name_filter: NameFilter) {
+        self.name_filter = name_filter;
+        self.refresh_index_config();
+    }
+
+    pub fn name_filter(&self) -> &NameFilter {
+        &self.name_filter
+    }
+
+    /// Choose whether symlinks under the indexed root are skipped or followed
+    pub fn set_symlink_policy(&mut self, symlink_policy: SymlinkPolicy) {
+        self.symlink_policy = symlink_policy;
+    }
+
+    /// Choose where vendored code lives and whether it is indexed, tagged
+    /// apart or skipped. Cached indexes built otherwise are rebuilt.
+    pub fn set_vendor_paths(&mut self, vendor_paths: VendorPaths) {
+        self.vendor_paths = vendor_paths;
+        self.refresh_index_config();
+    }
+
+    pub fn vendor_paths(&self) -> &VendorPaths {
+        &self.vendor_paths
+    }
+
+    /// Change which doc comment tags are attached to symbols
+    pub fn set_tag_keys(&mut self, tag_keys: TagKeys) {
+        self.frontend_config.tag_keys = tag_keys;
+        self.frontends.configure(&self.frontend_config);
+        self.refresh_index_config();
+    }
+
+    /// Choose which built-in language parses files whose extension several
+    /// languages use. Cached indexes built with other priorities are rebuilt.
+    pub fn set_language_priority(&mut self, language_priority: LanguagePriority) {
+        self.frontends.set_language_priority(language_priority);
+        self.refresh_index_config();
+    }
+
+    /// Parse files with the frontend's extensions using `frontend`, replacing
+    /// any frontend (built-in or custom) registered for them before
+    pub fn register_frontend(&mut self, mut frontend: Box<dyn LanguageFrontend>) {
+        frontend.configure(&self.frontend_config);
+        self.frontends.register(frontend);
+        self.refresh_index_config();
+    }
+
+    /// Whether a registered frontend parses files like `path`
+    pub fn handles(&self, path: &Path) -> bool {
+        self.frontends.handles(path)
+    }
+
+    /// Record a file that was deliberately not parsed, with the reason
+    fn record_skipped_file(&self, file_path: PathBuf, reason: &CodeAnalysisError, size: u64) {
+        ErrorRecovery::log_error_and_continue(reason, &file_path.display().to_string());
+
+        // Drop anything indexed from a previous version of the file
+        let _update = self.store.begin_update();
+        if self.store.has_file(&file_path) {
+            self.drop_file_symbols(&file_path);
+        }
+
+        let file_info = FileInfo {
+            last_modified: SystemTime::now(),
+            content_hash: [0; 32],
+            symbol_count: 0,
+            parse_status: ParseStatus::Skipped(reason.to_string()),
+            file_size: size,
+        };
+        self.store.update_file_info(file_path, file_info);
     }
 
     /// Index directory with cache optimization and graceful degradation
     pub async fn index_directory_with_cache<P: AsRef<Path>>(&mut self, path: P) -> IndexingResult {
+        self.build_with_cache(path, None).await
+    }
+
+    /// Like `index_directory_with_cache`, pushing progress events to `progress`.
+    ///
+    /// The sender is unbounded so reporting never waits on a slow consumer, and
+    /// it is dropped after the `Finished` event, closing the channel exactly once.
+    pub async fn index_directory_with_progress<P: AsRef<Path>>(
+        &mut self,
+        path: P,
+        progress: UnboundedSender<IndexProgress>,
+    ) -> IndexingResult {
+        let result = self.build_with_cache(path, Some(&progress)).await;
+        // A receiver that went away just stops listening; the build is done either way
+        let _ = progress.send(IndexProgress::Finished(result.clone()));
+        result
+    }
+
+    async fn build_with_cache<P: AsRef<Path>>(
+        &mut self,
+        path: P,
+        progress: Option<&UnboundedSender<IndexProgress>>,
+    ) -> IndexingResult {
         let start_time = std::time::Instant::now();
         let path = path.as_ref();
 
@@ -87,6 +395,8 @@ impl IndexingPipeline {
                     self.store.symbols_by_name.clear();
                     self.store.symbol_data.clear();
                     self.store.references.clear();
+                    self.store.references_by_file.clear();
+                    self.store.unresolved_references.clear();
                     self.store.files.clear();
                 }
             }
@@ -99,7 +409,7 @@ impl IndexingPipeline {
         }
 
         // Cache miss, invalid, or corrupted - do full indexing
-        let mut result = self.index_directory(path).await;
+        let mut result = self.build_directory(path, progress).await;
         result.cache_used = false;
 
         // Save to cache for next time, even if there were some errors
@@ -149,11 +459,110 @@ impl IndexingPipeline {
 
     /// Index all source files in a directory with graceful degradation
     pub async fn index_directory<P: AsRef<Path>>(&mut self, path: P) -> IndexingResult {
+        self.build_directory(path, None).await
+    }
+
+    /// Bring the index of a directory up to date with the files on disk:
+    /// new and changed files are parsed, unchanged ones are skipped by
+    /// content hash and deleted ones are removed. Each file's symbols are
+    /// replaced in one step, so queries keep being answered from the old
+    /// content until the new content is ready.
+    pub async fn rescan_directory<P: AsRef<Path>>(&mut self, path: P) -> IndexingResult {
+        let path = path.as_ref();
+        let result = self.build_directory(path, None).await;
+
+        let deleted: Vec<PathBuf> = self
+            .store
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| file.starts_with(path) && !file.is_file())
+            .collect();
+        for file in &deleted {
+            self.remove_file(file);
+        }
+        if !deleted.is_empty() {
+            tracing::info!(
+                "Removed {} deleted files from the index of {:?}",
+                deleted.len(),
+                path
+            );
+        }
+        result
+    }
+
+    /// Bring the index of a directory in line with changed settings. `Full`
+    /// parses every file again; otherwise only new, changed and stale files
+    /// are parsed. Either way, files the walk no longer selects, deleted or
+    /// newly excluded, are removed. Returns the build and the number of
+    /// files removed.
+    pub async fn reindex_directory<P: AsRef<Path>>(
+        &mut self,
+        path: P,
+        scope: ReindexScope,
+    ) -> (IndexingResult, usize) {
+        let path = path.as_ref();
+        let indexed = |pipeline: &Self| -> Vec<PathBuf> {
+            pipeline
+                .store
+                .files
+                .iter()
+                .map(|entry| entry.key().clone())
+                .filter(|file| file.starts_with(path))
+                .collect()
+        };
+        if scope == ReindexScope::Full {
+            for file in &indexed(self) {
+                self.mark_stale(file);
+            }
+        }
+        let result = self.build_directory(path, None).await;
+
+        // A failed walk leaves the index as it is rather than emptying it
+        let Ok(selected) = self.source_files(path) else {
+            return (result, 0);
+        };
+        let selected: HashSet<PathBuf> = selected.into_iter().collect();
+        let removed: Vec<PathBuf> = indexed(self)
+            .into_iter()
+            .filter(|file| !selected.contains(file))
+            .collect();
+        for file in &removed {
+            self.remove_file(file);
+        }
+        if !removed.is_empty() {
+            tracing::info!(
+                "Removed {} files no longer indexed from {:?}",
+                removed.len(),
+                path
+            );
+        }
+        (result, removed.len())
+    }
+
+    /// The files under `path` a build parses
+    fn source_files(&self, path: &Path) -> Result<Vec<PathBuf>, Box<dyn std::error::Error>> {
+        FileSystemWalker::find_files_with_policy(path, self.symlink_policy, |file| {
+            self.frontends.handles(file) && !self.vendor_paths.skips(file)
+        })
+    }
+
+    async fn build_directory<P: AsRef<Path>>(
+        &mut self,
+        path: P,
+        progress: Option<&UnboundedSender<IndexProgress>>,
+    ) -> IndexingResult {
+        // Sending on an unbounded channel never blocks; a dropped receiver is ignored
+        let report = |event: IndexProgress| {
+            if let Some(sender) = progress {
+                let _ = sender.send(event);
+            }
+        };
         let start_time = std::time::Instant::now();
         let mut result = IndexingResult::new();
 
         // Find all source files
-        let source_files = match FileSystemWalker::find_source_files(&path) {
+        let source_files = match self.source_files(path.as_ref()) {
             Ok(files) => files,
             Err(e) => {
                 result
@@ -165,6 +574,7 @@ impl IndexingPipeline {
         };
 
         let total_files = source_files.len();
+        report(IndexProgress::Started { total_files });
         let mut critical_errors = 0;
         const MAX_CRITICAL_ERRORS: usize = 10; // Stop after 10 critical errors
 
@@ -182,13 +592,26 @@ impl IndexingPipeline {
                 break;
             }
 
+            report(IndexProgress::FileStarted {
+                path: file_path.clone(),
+                index,
+            });
+
             match self.index_file(file_path).await {
                 Ok(symbols) => {
                     result.files_processed += 1;
                     result.symbols_found += symbols.len() as u32;
+                    report(IndexProgress::FileFinished {
+                        path: file_path.clone(),
+                        symbols: symbols.len(),
+                    });
                 }
                 Err(e) => {
                     let error_msg = format!("Failed to index {}: {}", file_path.display(), e);
+                    report(IndexProgress::FileFailed {
+                        path: file_path.clone(),
+                        error: e.to_string(),
+                    });
 
                     // Classify error severity
                     if let Some(analysis_error) = e.downcast_ref::<CodeAnalysisError>() {
@@ -256,67 +679,163 @@ impl IndexingPipeline {
             .into());
         }
 
+        // Skip oversized files before reading them so one huge file can't stall the build
+        if let Ok(size) = FileSystemWalker::get_file_size(&file_path).await {
+            if size > self.max_file_size {
+                let reason = CodeAnalysisError::FileTooLarge {
+                    size_bytes: size,
+                    limit_bytes: self.max_file_size,
+                };
+                self.record_skipped_file(file_path, &reason, size);
+                return Ok(Vec::new());
+            }
+        }
+
         // Read file content with error handling
-        let content = match FileSystemWalker::read_file_content(&file_path).await {
-            Ok(content) => content,
-            Err(io_error) => {
-                let error = ErrorRecovery::handle_file_error(&file_path_str, io_error);
-                ErrorRecovery::log_error_and_continue(&error, "file reading");
-
-                if ErrorRecovery::should_continue_indexing(&error) {
-                    // Update file info with error status
-                    let file_info = FileInfo {
-                        last_modified: SystemTime::now(),
-                        content_hash: [0; 32], // Empty hash for failed files
-                        symbol_count: 0,
-                        parse_status: ParseStatus::Failed(error.to_string()),
-                        file_size: 0,
-                    };
-                    self.store.update_file_info(file_path, file_info);
-                    return Ok(Vec::new()); // Return empty symbols, continue processing
-                }
-                return Err(error.into());
+        let bytes = match tokio::fs::read(&file_path).await {
+            Ok(bytes) => bytes,
+            Err(io_error) => return self.handle_read_error(file_path, io_error),
+        };
+
+        // Record the file's own mtime so recency queries reflect actual edits
+        let last_modified = tokio::fs::metadata(&file_path)
+            .await
+            .and_then(|metadata| metadata.modified())
+            .unwrap_or_else(|_| SystemTime::now());
+
+        self.index_bytes(file_path, bytes, last_modified).await
+    }
+
+    /// Index the files under `root` as they are in git revision `rev`,
+    /// reading their content from the object store so the working tree is
+    /// left alone. Files get the paths they would have checked out at
+    /// `root`.
+    pub async fn index_git_ref<P: AsRef<Path>>(&mut self, root: P, rev: &str) -> IndexingResult {
+        let root = root.as_ref();
+        let start_time = std::time::Instant::now();
+        let mut result = IndexingResult::new();
+
+        let files: Vec<TreeFile> = match git_tree_files(root, rev) {
+            Ok(files) => files
+                .into_iter()
+                .filter(|file| {
+                    self.frontends.handles(&file.path) && !self.vendor_paths.skips(&file.path)
+                })
+                .collect(),
+            Err(e) => {
+                result
+                    .errors
+                    .push(format!("Failed to list files at {}: {}", rev, e));
+                result.duration_ms = start_time.elapsed().as_millis() as u64;
+                return result;
             }
         };
 
-        // Check file size limit (10MB)
-        const MAX_FILE_SIZE: u64 = 10 * 1024 * 1024;
-        if content.len() as u64 > MAX_FILE_SIZE {
-            let error = CodeAnalysisError::FileTooLarge {
-                size_mb: content.len() as u64 / (1024 * 1024),
+        // Blobs are read in batches to bound memory on large trees
+        const BLOB_BATCH: usize = 256;
+        for batch in files.chunks(BLOB_BATCH) {
+            let blobs: Vec<String> = batch.iter().map(|file| file.blob.clone()).collect();
+            let contents = match git_read_blobs(root, &blobs) {
+                Ok(contents) => contents,
+                Err(e) => {
+                    result
+                        .errors
+                        .push(format!("Failed to read files at {}: {}", rev, e));
+                    result.files_skipped += batch.len() as u32;
+                    result.partial_success = true;
+                    continue;
+                }
             };
-            ErrorRecovery::log_error_and_continue(&error, &file_path_str);
+            for (file, bytes) in batch.iter().zip(contents) {
+                match self
+                    .index_bytes(file.path.clone(), bytes, SystemTime::now())
+                    .await
+                {
+                    Ok(symbols) => {
+                        result.files_processed += 1;
+                        result.symbols_found += symbols.len() as u32;
+                    }
+                    Err(e) => {
+                        result.errors.push(format!(
+                            "Failed to index {}: {}",
+                            file.path.display(),
+                            e
+                        ));
+                        result.files_skipped += 1;
+                        result.partial_success = true;
+                    }
+                }
+            }
+        }
 
-            let file_info = FileInfo {
-                last_modified: SystemTime::now(),
-                content_hash: self.calculate_content_hash(&content),
-                symbol_count: 0,
-                parse_status: ParseStatus::Failed(error.to_string()),
-                file_size: content.len() as u64,
+        result.duration_ms = start_time.elapsed().as_millis() as u64;
+        tracing::info!(
+            "Indexed {:?} at {}: {}/{} files processed, {} symbols found",
+            root,
+            rev,
+            result.files_processed,
+            files.len(),
+            result.symbols_found
+        );
+        result
+    }
+
+    /// Index the content of a file read from disk or from git
+    async fn index_bytes(
+        &mut self,
+        file_path: PathBuf,
+        bytes: Vec<u8>,
+        last_modified: SystemTime,
+    ) -> Result<Vec<Symbol>, Box<dyn std::error::Error>> {
+        let file_path_str = file_path.display().to_string();
+
+        // Skip binary files even when they carry a source extension
+        if FileSystemWalker::is_binary_content(&bytes) {
+            let size = bytes.len() as u64;
+            self.record_skipped_file(file_path, &CodeAnalysisError::BinaryFile, size);
+            return Ok(Vec::new());
+        }
+
+        let content = match String::from_utf8(bytes) {
+            Ok(content) => content,
+            Err(e) => {
+                let io_error = std::io::Error::new(std::io::ErrorKind::InvalidData, e);
+                return self.handle_read_error(file_path, io_error);
+            }
+        };
+
+        // Re-check the size in case the file grew after the metadata lookup
+        if content.len() as u64 > self.max_file_size {
+            let reason = CodeAnalysisError::FileTooLarge {
+                size_bytes: content.len() as u64,
+                limit_bytes: self.max_file_size,
             };
-            self.store.update_file_info(file_path, file_info);
+            self.record_skipped_file(file_path, &reason, content.len() as u64);
             return Ok(Vec::new());
         }
 
         // Calculate content hash for change detection
         let content_hash = self.calculate_content_hash(&content);
 
-        // Check if file has changed
-        if let Some(existing_info) = self.store.get_file_info(&file_path) {
-            if existing_info.content_hash == content_hash {
+        // Check if file has changed. The old content stays visible to
+        // queries until the new symbols are ready to replace it.
+        let replacing = match self.store.get_file_info(&file_path) {
+            Some(existing_info) if existing_info.content_hash == content_hash => {
                 tracing::debug!("File {:?} unchanged, skipping re-indexing", file_path);
                 // File hasn't changed, return existing symbols
                 return Ok(self.store.get_symbols_by_file(&file_path));
             }
+            Some(_) => {
+                tracing::info!("File {:?} changed, re-indexing", file_path);
+                true
+            }
+            None => false,
+        };
 
-            tracing::info!("File {:?} changed, removing old content", file_path);
-            // File has changed, remove old symbols and BM25 content
-            self.store.remove_file_symbols(&file_path);
-        }
-
-        // Detect language
-        let language = match Language::from_path(&file_path) {
-            Some(lang) => lang,
+        // Pick the frontend registered for the file's extension, or the
+        // language its content suggests when several share the extension
+        let frontend = match self.frontends.frontend_for_source(&file_path, &content) {
+            Some(frontend) => frontend,
             None => {
                 let ext = file_path
                     .extension()
@@ -334,15 +853,28 @@ impl IndexingPipeline {
                     parse_status: ParseStatus::Failed(error.to_string()),
                     file_size: content.len() as u64,
                 };
+                let _update = self.store.begin_update();
+                if replacing {
+                    self.drop_file_symbols(&file_path);
+                }
                 self.store.update_file_info(file_path, file_info);
                 return Ok(Vec::new());
             }
         };
+        let language = frontend.language();
+        let language_name = frontend.name().to_string();
 
-        // Extract symbols with error recovery
-        let symbols = match self.indexer.extract_symbols(&content, language, &file_path) {
-            Ok(symbols) => symbols,
+        // Extract symbols and references with error recovery
+        let output = match frontend.parse(content.as_bytes(), &file_path) {
+            Ok(output) => output,
             Err(e) => {
+                // A file that is too slow to parse is skipped like an oversized one
+                if let Some(timeout @ CodeAnalysisError::ParseTimeout { .. }) =
+                    e.downcast_ref::<CodeAnalysisError>()
+                {
+                    self.record_skipped_file(file_path, timeout, content.len() as u64);
+                    return Ok(Vec::new());
+                }
                 let error = ErrorRecovery::handle_parse_error(&file_path_str, &e.to_string());
                 ErrorRecovery::log_error_and_continue(&error, "symbol extraction");
 
@@ -354,10 +886,42 @@ impl IndexingPipeline {
                     parse_status: ParseStatus::Failed(error.to_string()),
                     file_size: content.len() as u64,
                 };
+                let _update = self.store.begin_update();
+                if replacing {
+                    self.drop_file_symbols(&file_path);
+                }
                 self.store.update_file_info(file_path, file_info);
                 return Ok(Vec::new()); // Return empty symbols, continue processing
             }
         };
+        let mut symbols = output.symbols;
+
+        // Drop disabled kinds and noise names before they reach the store
+        symbols.retain(|symbol| {
+            language.is_none_or(|language| self.kind_filter.allows(language, &symbol.symbol_type))
+                && self.name_filter.allows(&symbol.name)
+        });
+
+        // Vendored code is kept apart from first-party code by its tag
+        if self.vendor_paths.mode() == VendorMode::Separate {
+            if let Some(prefix) = self.vendor_paths.vendor_prefix(&file_path) {
+                for symbol in &mut symbols {
+                    symbol
+                        .tags
+                        .insert(VENDOR_TAG.to_string(), prefix.to_string());
+                }
+            }
+        }
+
+        // Swap the old content for the new in one step as seen by queries
+        let _update = self.store.begin_update();
+        // Remove old symbols, BM25 content and the reference edges touching
+        // them; references from other files are re-linked below
+        let incoming = if replacing {
+            self.store.detach_file(&file_path)
+        } else {
+            Vec::new()
+        };
 
         // Store symbols in symbol store with memory checking
         let mut stored_symbols = 0;
@@ -376,26 +940,33 @@ impl IndexingPipeline {
             }
         }
 
-        // Extract references (simple approach)
-        if let Ok(references) = self
-            .indexer
-            .extract_references(&content, language, &file_path)
-        {
-            // For each reference, try to find matching symbols and link them
-            for reference in references {
-                // Extract the reference name from the source
-                if let Some(ref_name) = self.extract_reference_name(&reference, &content) {
-                    // Find symbols with this name
-                    let matching_symbols = self.store.get_symbols(&ref_name);
-                    for symbol in matching_symbols {
-                        let mut linked_ref = reference.clone();
-                        linked_ref.target_symbol = symbol.id;
-                        self.store.add_reference(symbol.id, linked_ref);
-                    }
+        // Link references to symbols by name (simple approach)
+        for reference in output.references {
+            // Extract the reference name from the source
+            if let Some(ref_name) = self.extract_reference_name(&reference, &content) {
+                // Find symbols with this name
+                let matching_symbols = self.store.get_symbols(&ref_name);
+                if matching_symbols.is_empty() {
+                    // Linked when a file defining the name is indexed or merged
+                    self.store.add_unresolved_reference(&ref_name, reference);
+                    continue;
+                }
+                for symbol in matching_symbols {
+                    let mut linked_ref = reference.clone();
+                    linked_ref.target_symbol = symbol.id;
+                    self.store.add_reference(symbol.id, linked_ref);
                 }
             }
         }
 
+        // References from files indexed earlier to names this file defines
+        for symbol in &symbols {
+            self.store.resolve_pending_references(&symbol.name);
+        }
+        // References that pointed at the old symbols follow their name: to
+        // the new symbol, to another file's, or back to pending when renamed
+        self.store.relink_references(incoming);
+
         // Update file info with success status
         let parse_status = if stored_symbols == symbols.len() {
             ParseStatus::Success
@@ -408,7 +979,7 @@ impl IndexingPipeline {
         };
 
         let file_info = FileInfo {
-            last_modified: SystemTime::now(),
+            last_modified,
             content_hash,
             symbol_count: stored_symbols as u32,
             parse_status,
@@ -417,23 +988,7 @@ impl IndexingPipeline {
         self.store.update_file_info(file_path.clone(), file_info);
 
         // Add to BM25 index for code search
-        let language_str = match language {
-            Language::Rust => "rust",
-            Language::Python => "python",
-            Language::C => "c",
-            Language::Cpp => "cpp",
-            Language::Java => "java",
-            Language::Go => "go",
-            Language::JavaScript => "javascript",
-            Language::TypeScript => "typescript",
-            Language::Ruby => "ruby",
-            Language::CSharp => "csharp",
-            Language::Kotlin => "kotlin",
-            Language::Scala => "scala",
-            Language::Swift => "swift",
-            Language::PHP => "php",
-            Language::ObjectiveC => "objc",
-        };
+        let language_str = language_name.as_str();
 
         // Normalize path to relative for BM25 consistency
         let normalized_path = if let Ok(current_dir) = std::env::current_dir() {
@@ -451,6 +1006,30 @@ impl IndexingPipeline {
         Ok(symbols)
     }
 
+    fn handle_read_error(
+        &self,
+        file_path: PathBuf,
+        io_error: std::io::Error,
+    ) -> Result<Vec<Symbol>, Box<dyn std::error::Error>> {
+        let file_path_str = file_path.display().to_string();
+        let error = ErrorRecovery::handle_file_error(&file_path_str, io_error);
+        ErrorRecovery::log_error_and_continue(&error, "file reading");
+
+        if ErrorRecovery::should_continue_indexing(&error) {
+            // Update file info with error status
+            let file_info = FileInfo {
+                last_modified: SystemTime::now(),
+                content_hash: [0; 32], // Empty hash for failed files
+                symbol_count: 0,
+                parse_status: ParseStatus::Failed(error.to_string()),
+                file_size: 0,
+            };
+            self.store.update_file_info(file_path, file_info);
+            return Ok(Vec::new()); // Return empty symbols, continue processing
+        }
+        Err(error.into())
+    }
+
     fn extract_reference_name(&self, reference: &Reference, content: &str) -> Option<String> {
         let lines: Vec<&str> = content.lines().collect();
         let line_idx = (reference.location.start_line as usize).saturating_sub(1);
@@ -516,7 +1095,127 @@ impl IndexingPipeline {
     /// Remove file from index (for deleted files)
     pub fn remove_file<P: AsRef<Path>>(&mut self, file_path: P) {
         let file_path = file_path.as_ref().to_path_buf();
-        self.store.remove_file_symbols(&file_path);
+        let _update = self.store.begin_update();
+        self.drop_file_symbols(&file_path);
+    }
+
+    /// Drop a file's symbols and the reference edges touching them, without
+    /// a full reference pass: references other files made to them move to
+    /// remaining symbols of the same name, or wait for one to be indexed
+    fn drop_file_symbols(&self, file_path: &PathBuf) {
+        let incoming = self.store.detach_file(file_path);
+        self.store.relink_references(incoming);
+    }
+
+    /// Apply a set of changed files, such as the diff against a git ref,
+    /// instead of walking the whole tree. Deleted and renamed-away files are
+    /// removed. References from unchanged files into changed ones are
+    /// re-linked by name as each file is replaced, so only the changed files
+    /// are parsed.
+    pub async fn index_changes(&mut self, changes: &[GitChange]) -> IndexingResult {
+        let start_time = std::time::Instant::now();
+        let mut result = IndexingResult::new();
+
+        let mut removed: Vec<PathBuf> = Vec::new();
+        let mut updated: Vec<PathBuf> = Vec::new();
+        for change in changes {
+            match change {
+                GitChange::Modified(path) => updated.push(path.clone()),
+                GitChange::Deleted(path) => removed.push(path.clone()),
+                GitChange::Renamed { from, to } => {
+                    removed.push(from.clone());
+                    updated.push(to.clone());
+                }
+            }
+        }
+
+        for path in &removed {
+            self.remove_file(path);
+        }
+
+        let to_index: Vec<PathBuf> = updated
+            .into_iter()
+            .filter(|path| {
+                path.is_file() && self.frontends.handles(path) && !self.vendor_paths.skips(path)
+            })
+            .collect();
+        for path in &to_index {
+            match self.index_file(path).await {
+                Ok(symbols) => {
+                    result.files_processed += 1;
+                    result.symbols_found += symbols.len() as u32;
+                }
+                Err(e) => {
+                    let error_msg = format!("Failed to index {}: {}", path.display(), e);
+                    tracing::warn!("{}", error_msg);
+                    result.errors.push(error_msg);
+                    result.files_skipped += 1;
+                    result.partial_success = true;
+                }
+            }
+        }
+
+        result.duration_ms = start_time.elapsed().as_millis() as u64;
+        tracing::info!(
+            "Indexed {} changed files ({} removed), {} symbols found",
+            result.files_processed,
+            removed.len(),
+            result.symbols_found
+        );
+        result
+    }
+
+    /// Write the current index to `output` so it can be merged with
+    /// indexes of other parts of the same checkout
+    pub async fn write_snapshot(
+        &mut self,
+        root_path: &Path,
+        output: &Path,
+    ) -> Result<(), Box<dyn std::error::Error>> {
+        self.cache_manager
+            .write_snapshot(&self.store, root_path, output)
+            .await
+    }
+
+    /// Read an index written by `write_snapshot`, checking it was built
+    /// with the same cache version and settings
+    pub async fn read_snapshot(
+        &mut self,
+        path: &Path,
+    ) -> Result<PersistedIndex, Box<dyn std::error::Error>> {
+        self.cache_manager.read_snapshot(path).await
+    }
+
+    /// Merge index snapshots built from disjoint file sets, optionally
+    /// writing the result to `output` and replacing the store's contents
+    /// with it
+    pub async fn merge_snapshots(
+        &mut self,
+        snapshots: &[PathBuf],
+        output: Option<&Path>,
+        load: bool,
+    ) -> Result<MergeReport, Box<dyn std::error::Error>> {
+        let mut shards = Vec::with_capacity(snapshots.len());
+        for path in snapshots {
+            let index = self.cache_manager.read_snapshot(path).await?;
+            shards.push((path.display().to_string(), index));
+        }
+        let (merged, report) = PersistedIndex::merge(shards)?;
+
+        if let Some(output) = output {
+            self.cache_manager.write_index(&merged, output).await?;
+        }
+        if load {
+            merged.restore_to_store(&self.store);
+        }
+        tracing::info!(
+            "Merged {} indexes: {} files, {} symbols, {} conflicts",
+            report.shards,
+            report.files,
+            report.symbols,
+            report.conflicts.len()
+        );
+        Ok(report)
     }
 
     /// Get indexing progress/statistics
@@ -545,6 +1244,7 @@ pub struct IndexingStats {
 #[cfg(test)]
 mod tests {
     use super::*;
+    use std::collections::HashSet;
     use tempfile::TempDir;
     use tokio::fs;
 
@@ -666,6 +1366,166 @@ mod tests {
         assert!(!symbols2.is_empty());
     }
 
+    #[tokio::test]
+    async fn test_update_between_definition_read_and_cache() {
+        let temp_dir = TempDir::new().unwrap();
+        let test_file = temp_dir.path().join("test.rs");
+        fs::write(&test_file, "fn original() {}").await.unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.index_file(&test_file).await.unwrap();
+
+        let style = crate::models::SignatureStyle::Full;
+        let mut symbol = store.get_symbols("original").remove(0);
+        let file = symbol.location.file.clone();
+        assert!(store.definition_cache.get(symbol.id, style).is_none());
+        let generation = store.definition_cache.generation(&file);
+        symbol.source = Some(fs::read_to_string(&file).await.unwrap());
+
+        // The file is re-indexed after the read, before the insert
+        fs::write(&test_file, "fn original() { changed() }")
+            .await
+            .unwrap();
+        pipeline.update_file(&test_file).await.unwrap();
+        store
+            .definition_cache
+            .insert(style, symbol.clone(), generation);
+        assert!(store.definition_cache.get(symbol.id, style).is_none());
+
+        // A read after the update is cached
+        let generation = store.definition_cache.generation(&file);
+        symbol.source = Some(fs::read_to_string(&file).await.unwrap());
+        store
+            .definition_cache
+            .insert(style, symbol.clone(), generation);
+        let cached = store.definition_cache.get(symbol.id, style).unwrap();
+        assert_eq!(
+            cached.source.as_deref(),
+            Some("fn original() { changed() }")
+        );
+    }
+
+    #[tokio::test]
+    async fn test_references_follow_single_file_edits() {
+        let temp_dir = TempDir::new().unwrap();
+        let lib = temp_dir.path().join("lib.rs");
+        let main = temp_dir.path().join("main.rs");
+        fs::write(&lib, "fn helper() {}\n").await.unwrap();
+        fs::write(&main, "fn main() {\n    helper();\n}\n")
+            .await
+            .unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.index_file(&lib).await.unwrap();
+        pipeline.index_file(&main).await.unwrap();
+        let callers = |name: &str| -> Vec<u32> {
+            let mut lines: Vec<u32> = store
+                .get_references_by_name(name)
+                .iter()
+                .filter(|reference| reference.location.file == main)
+                .map(|reference| reference.location.start_line)
+                .collect();
+            lines.sort();
+            lines
+        };
+        assert_eq!(callers("helper"), vec![2]);
+
+        // Moving the definition changes its ID; the call follows it
+        fs::write(&lib, "\n\nfn helper() {}\n").await.unwrap();
+        pipeline.update_file(&lib).await.unwrap();
+        assert_eq!(callers("helper"), vec![2]);
+
+        // Renaming it leaves the call dangling instead of pointing nowhere
+        fs::write(&lib, "fn assist() {}\n").await.unwrap();
+        pipeline.update_file(&lib).await.unwrap();
+        assert!(callers("helper").is_empty());
+        assert!(callers("assist").is_empty());
+
+        // Updating the caller links it to the new name
+        fs::write(&main, "fn main() {\n    assist();\n}\n")
+            .await
+            .unwrap();
+        pipeline.update_file(&main).await.unwrap();
+        assert_eq!(callers("assist"), vec![2]);
+
+        // Renaming back while main.rs still calls `assist`, then fixing
+        // main.rs, never duplicates or loses the edge
+        fs::write(&lib, "fn helper() {}\nfn other() {}\n")
+            .await
+            .unwrap();
+        pipeline.update_file(&lib).await.unwrap();
+        assert!(callers("assist").is_empty());
+        fs::write(&main, "fn main() {\n\n    helper();\n}\n")
+            .await
+            .unwrap();
+        pipeline.update_file(&main).await.unwrap();
+        assert_eq!(callers("helper"), vec![3]);
+        assert!(callers("assist").is_empty());
+        assert!(store
+            .unresolved_references
+            .get("helper")
+            .map_or(true, |refs| refs.iter().all(|r| r.location.file != main)));
+    }
+
+    #[tokio::test]
+    async fn test_queries_never_see_partial_updates() {
+        use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+
+        let temp_dir = TempDir::new().unwrap();
+        let test_file = temp_dir.path().join("gen.rs");
+        let names = ["alpha", "beta", "gamma", "delta", "epsilon"];
+        let source = |generation: usize| {
+            names
+                .iter()
+                .map(|name| format!("fn {}_{}() {{}}\n", name, generation))
+                .collect::<String>()
+        };
+        fs::write(&test_file, source(0)).await.unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.index_file(&test_file).await.unwrap();
+
+        let done = Arc::new(AtomicBool::new(false));
+        let reads = Arc::new(AtomicUsize::new(0));
+        let readers: Vec<_> = (0..4)
+            .map(|_| {
+                let (store, done, reads) = (store.clone(), done.clone(), reads.clone());
+                let test_file = test_file.clone();
+                std::thread::spawn(move || {
+                    while !done.load(Ordering::Relaxed) {
+                        let _snapshot = store.read_snapshot();
+                        let symbols = store.get_symbols_by_file(&test_file);
+                        let info = store.get_file_info(&test_file).unwrap();
+                        assert_eq!(symbols.len(), names.len(), "saw a partial update");
+                        assert_eq!(info.symbol_count as usize, symbols.len());
+                        let generations: HashSet<&str> = symbols
+                            .iter()
+                            .map(|symbol| symbol.name.rsplit('_').next().unwrap())
+                            .collect();
+                        assert_eq!(generations.len(), 1, "mixed symbols: {:?}", symbols);
+                        reads.fetch_add(1, Ordering::Relaxed);
+                    }
+                })
+            })
+            .collect();
+
+        for generation in 1..=50 {
+            fs::write(&test_file, source(generation)).await.unwrap();
+            pipeline.update_file(&test_file).await.unwrap();
+        }
+        done.store(true, Ordering::Relaxed);
+        for reader in readers {
+            reader.join().expect("reader saw an inconsistent index");
+        }
+
+        assert!(reads.load(Ordering::Relaxed) > 0);
+        assert_eq!(store.get_symbols("alpha_50").len(), 1);
+        assert!(store.get_symbols("alpha_49").is_empty());
+    }
+
     #[tokio::test]
     async fn test_error_handling() {
         let store = Arc::new(SymbolStore::new());
@@ -682,6 +1542,204 @@ mod tests {
         assert!(result.errors.is_empty());
     }
 
+    #[tokio::test]
+    async fn test_oversized_and_binary_files_skipped() {
+        let temp_dir = TempDir::new().unwrap();
+        let large_file = temp_dir.path().join("generated.go");
+        let binary_file = temp_dir.path().join("blob.rs");
+        let normal_file = temp_dir.path().join("main.rs");
+
+        fs::write(&large_file, "package main\n".repeat(200))
+            .await
+            .unwrap();
+        fs::write(&binary_file, b"fn main() {}\x00\x01\x02")
+            .await
+            .unwrap();
+        fs::write(&normal_file, "fn main() {}").await.unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.set_max_file_size(1024);
+
+        let result = pipeline.index_directory(temp_dir.path()).await;
+        assert_eq!(result.files_processed, 3);
+
+        let large_info = store.get_file_info(&large_file).unwrap();
+        assert!(
+            matches!(large_info.parse_status, ParseStatus::Skipped(ref r) if r.contains("too large"))
+        );
+        let binary_info = store.get_file_info(&binary_file).unwrap();
+        assert!(
+            matches!(binary_info.parse_status, ParseStatus::Skipped(ref r) if r.contains("Binary"))
+        );
+        assert!(!store.get_symbols("main").is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_reindex_after_settings_change() {
+        let temp_dir = TempDir::new().unwrap();
+        let main_file = temp_dir.path().join("main.go");
+        let vendor_file = temp_dir.path().join("vendor/lib/lib.go");
+        fs::create_dir_all(vendor_file.parent().unwrap())
+            .await
+            .unwrap();
+        fs::write(
+            &main_file,
+            "package main\n\ntype Config struct{}\n\nfunc Run() {}\n",
+        )
+        .await
+        .unwrap();
+        fs::write(&vendor_file, "package lib\n\nfunc Helper() {}\n")
+            .await
+            .unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.index_directory(temp_dir.path()).await;
+        assert!(!store.get_symbols("Config").is_empty());
+
+        // Unchanged files are parsed again so the new filter applies
+        pipeline.set_kind_filter(KindFilter::parse("go=function").unwrap());
+        let (_, removed) = pipeline
+            .reindex_directory(temp_dir.path(), ReindexScope::Full)
+            .await;
+        assert_eq!(removed, 0);
+        assert!(store.get_symbols("Config").is_empty());
+        assert!(!store.get_symbols("Run").is_empty());
+
+        // Newly excluded files are dropped without parsing the rest
+        pipeline.set_vendor_paths(VendorPaths::parse(VendorMode::Skip, "vendor/"));
+        let (_, removed) = pipeline
+            .reindex_directory(temp_dir.path(), ReindexScope::Files)
+            .await;
+        assert_eq!(removed, 1);
+        assert!(store.get_symbols("Helper").is_empty());
+        assert!(!store.has_file(&vendor_file));
+        assert!(!store.get_symbols("Run").is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_reload_settings_reindexes() {
+        use crate::indexing::settings::set_setting;
+
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(
+            temp_dir.path().join("main.go"),
+            "package main\n\nfunc ReloadExcluded() {}\n\nfunc ReloadKept() {}\n",
+        )
+        .await
+        .unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.index_directory(temp_dir.path()).await;
+        assert!(!store.get_symbols("ReloadExcluded").is_empty());
+
+        // A setting read only at startup leaves the index alone
+        set_setting("ROBERTO_LOG_FORMAT", Some("json".to_string()));
+        let changes = pipeline.reload_settings();
+        set_setting("ROBERTO_LOG_FORMAT", None);
+        assert_eq!(changes.len(), 1);
+        assert_eq!(changes[0].setting, "ROBERTO_LOG_FORMAT");
+        assert_eq!(reindex_scope(&changes), ReindexScope::None);
+
+        // Names matching the new pattern are dropped once files are parsed again
+        set_setting(
+            "ROBERTO_EXCLUDE_NAMES",
+            Some("^ReloadExcluded$".to_string()),
+        );
+        let changes = pipeline.reload_settings();
+        let summary: Vec<(&str, ReindexScope)> = changes
+            .iter()
+            .map(|change| (change.setting.as_str(), change.reindex))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("ROBERTO_EXCLUDE_NAMES", ReindexScope::Full),
+                ("ROBERTO_LOG_FORMAT", ReindexScope::None),
+            ]
+        );
+        let (_, removed) = pipeline
+            .reindex_directory(temp_dir.path(), reindex_scope(&changes))
+            .await;
+        assert_eq!(removed, 0);
+        assert!(store.get_symbols("ReloadExcluded").is_empty());
+        assert!(!store.get_symbols("ReloadKept").is_empty());
+        set_setting("ROBERTO_EXCLUDE_NAMES", None);
+    }
+
+    #[tokio::test]
+    async fn test_slow_parse_skipped_after_timeout() {
+        let temp_dir = TempDir::new().unwrap();
+        let nested_file = temp_dir.path().join("nested.py");
+        let normal_file = temp_dir.path().join("main.rs");
+
+        let depth = 5_000;
+        let line = format!("x = {}1{}\n", "(".repeat(depth), ")".repeat(depth));
+        fs::write(&nested_file, line.repeat(200)).await.unwrap();
+        fs::write(&normal_file, "fn main() {}").await.unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+        pipeline.set_max_file_size(64 * 1024 * 1024);
+        pipeline.set_parse_timeout(Some(Duration::from_millis(1)));
+
+        let started = std::time::Instant::now();
+        let result = pipeline.index_directory(temp_dir.path()).await;
+        assert!(started.elapsed() < Duration::from_secs(5));
+        assert_eq!(result.files_processed, 2);
+
+        let nested_info = store.get_file_info(&nested_file).unwrap();
+        assert!(
+            matches!(nested_info.parse_status, ParseStatus::Skipped(ref r) if r.contains("took longer"))
+        );
+        assert!(store.get_symbols("x").is_empty());
+        assert!(!store.get_symbols("main").is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_index_progress_events() {
+        let temp_dir = TempDir::new().unwrap();
+        fs::write(temp_dir.path().join("a.rs"), "fn alpha() {}")
+            .await
+            .unwrap();
+        fs::write(temp_dir.path().join("b.rs"), "fn beta() {}\nfn gamma() {}")
+            .await
+            .unwrap();
+
+        let store = Arc::new(SymbolStore::new());
+        let mut pipeline = IndexingPipeline::new(store).unwrap();
+        let (sender, mut receiver) = tokio::sync::mpsc::unbounded_channel();
+
+        let result = pipeline
+            .index_directory_with_progress(temp_dir.path(), sender)
+            .await;
+
+        let mut events = Vec::new();
+        while let Some(event) = receiver.recv().await {
+            events.push(event);
+        }
+
+        // recv() returning None means every sender was dropped: the channel is closed
+        assert!(matches!(
+            events.first(),
+            Some(IndexProgress::Started { total_files: 2 })
+        ));
+        let finished_symbols: usize = events
+            .iter()
+            .filter_map(|event| match event {
+                IndexProgress::FileFinished { symbols, .. } => Some(*symbols),
+                _ => None,
+            })
+            .sum();
+        assert_eq!(finished_symbols, 3);
+        assert!(matches!(
+            events.last(),
+            Some(IndexProgress::Finished(summary)) if summary.symbols_found == result.symbols_found
+        ));
+    }
+
     #[tokio::test]
     async fn test_content_hash_detection() {
         let temp_dir = TempDir::new().unwrap();
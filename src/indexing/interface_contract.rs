@@ -0,0 +1,179 @@
+use crate::indexing::json_schema::type_spec;
+use crate::indexing::signature::extract_signature;
+use crate::indexing::signature_compat::return_values;
+use crate::indexing::symbol_analysis::find_definition;
+use crate::models::{Language, Location, Parameter};
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// A method an implementation of an interface must provide
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ContractMethod {
+    pub name: String,
+    pub parameters: Vec<Parameter>,
+    /// Result types in order; empty when the method returns nothing
+    pub returns: Vec<String>,
+    /// The last result is `error`
+    pub returns_error: bool,
+    /// The method as declared, e.g. `ExecuteQuery(query string) (*QueryResult, error)`
+    pub signature: String,
+    pub line: u32,
+    /// The embedded interface the method was promoted from
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub from: Option<String>,
+}
+
+/// The declared method set of a Go interface type
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub struct GoInterface {
+    pub methods: Vec<ContractMethod>,
+    /// Embedded interfaces, as written (`io.Closer`, `Reader`)
+    pub embedded: Vec<String>,
+}
+
+/// Find the Go interface declared at `location` and read its own methods and
+/// embedded interfaces. Returns `None` when the type is not an interface.
+pub fn go_interface(source: &str, location: &Location) -> Option<GoInterface> {
+    let mut parser = Parser::new();
+    parser
+        .set_language(&Language::Go.tree_sitter_language())
+        .ok()?;
+    let tree = parser.parse(source, None)?;
+    let definition = find_definition(tree.root_node(), location)?;
+    let underlying = type_spec(definition)?.child_by_field_name("type")?;
+    if underlying.kind() != "interface_type" {
+        return None;
+    }
+
+    let mut interface = GoInterface::default();
+    let mut cursor = underlying.walk();
+    for element in underlying.named_children(&mut cursor) {
+        match element.kind() {
+            "method_elem" => {
+                if let Some(method) = contract_method(element, source) {
+                    interface.methods.push(method);
+                }
+            }
+            // A single type is an embedded interface; unions like
+            // `~int | ~string` only constrain type parameters
+            "type_elem" if element.named_child_count() == 1 => {
+                if let Some(embedded) = element.named_child(0).and_then(|node| text(node, source)) {
+                    if !embedded.starts_with('~') {
+                        interface.embedded.push(embedded);
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+    Some(interface)
+}
+
+/// The method set of the predeclared `error` interface
+pub fn error_interface_methods() -> Vec<ContractMethod> {
+    vec![ContractMethod {
+        name: "Error".to_string(),
+        parameters: Vec::new(),
+        returns: vec!["string".to_string()],
+        returns_error: false,
+        signature: "Error() string".to_string(),
+        line: 0,
+        from: Some("error".to_string()),
+    }]
+}
+
+fn contract_method(element: Node, source: &str) -> Option<ContractMethod> {
+    let name = text(element.child_by_field_name("name")?, source)?;
+    let signature = extract_signature(element, source);
+    let returns = signature
+        .as_ref()
+        .and_then(|signature| signature.return_type.as_deref())
+        .map(return_values)
+        .unwrap_or_default();
+    Some(ContractMethod {
+        returns_error: returns.last().is_some_and(|value| value == "error"),
+        parameters: signature
+            .as_ref()
+            .map(|signature| signature.parameters.clone())
+            .unwrap_or_default(),
+        signature: signature
+            .map(|signature| signature.text)
+            .or_else(|| text(element, source))?,
+        returns,
+        line: element.start_position().row as u32 + 1,
+        name,
+        from: None,
+    })
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    #[test]
+    fn test_go_interface() {
+        let source = r#"package db
+
+type DatabaseConnection interface {
+    io.Closer
+    Pinger
+    Connect(ctx context.Context, dsn string) error
+    ExecuteQuery(query string, args ...any) (*QueryResult, error)
+    BeginTransaction() (Tx, error)
+    Name() string
+}
+
+type Number interface {
+    ~int | ~float64
+}
+"#;
+        let location = Location {
+            file: PathBuf::from("db.go"),
+            start_line: 3,
+            start_column: 5,
+            end_line: 10,
+            end_column: 1,
+        };
+        let interface = go_interface(source, &location).unwrap();
+        assert_eq!(interface.embedded, vec!["io.Closer", "Pinger"]);
+
+        let methods: Vec<(&str, Vec<&str>, bool)> = interface
+            .methods
+            .iter()
+            .map(|m| {
+                (
+                    m.name.as_str(),
+                    m.returns.iter().map(String::as_str).collect(),
+                    m.returns_error,
+                )
+            })
+            .collect();
+        assert_eq!(
+            methods,
+            vec![
+                ("Connect", vec!["error"], true),
+                ("ExecuteQuery", vec!["*QueryResult", "error"], true),
+                ("BeginTransaction", vec!["Tx", "error"], true),
+                ("Name", vec!["string"], false),
+            ]
+        );
+        assert_eq!(interface.methods[1].parameters.len(), 2);
+
+        let constraint = Location {
+            start_line: 12,
+            end_line: 14,
+            ..location
+        };
+        assert!(go_interface(source, &constraint)
+            .unwrap()
+            .embedded
+            .is_empty());
+    }
+}
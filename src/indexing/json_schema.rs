@@ -0,0 +1,499 @@
+use crate::indexing::symbol_analysis::find_definition;
+use crate::models::{Language, Location};
+use serde_json::{json, Map, Value};
+use tree_sitter::{Node, Parser};
+
+/// A field of a Go struct as written, one entry per declared name
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GoField {
+    pub name: String,
+    pub type_text: String,
+    /// The raw struct tag without its quotes
+    pub tag: Option<String>,
+    /// Embedded fields are named after their type
+    pub embedded: bool,
+}
+
+/// The underlying type of a Go type declaration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum GoTypeDefinition {
+    Struct(Vec<GoField>),
+    /// Any other type: `type Status string`, `type IDs []int64`, aliases
+    Other(String),
+}
+
+/// The `json` key of a struct tag, as interpreted by encoding/json
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct JsonTag {
+    /// Name override; `None` keeps the Go field name
+    pub name: Option<String>,
+    /// `json:"-"`: the field is never encoded
+    pub skip: bool,
+    pub omitempty: bool,
+    /// `,string`: numbers and booleans are encoded as JSON strings
+    pub string: bool,
+}
+
+/// Find the Go type declared at `location` and read its underlying type
+pub fn go_type_definition(source: &str, location: &Location) -> Option<GoTypeDefinition> {
+    let mut parser = Parser::new();
+    parser
+        .set_language(&Language::Go.tree_sitter_language())
+        .ok()?;
+    let tree = parser.parse(source, None)?;
+    let definition = find_definition(tree.root_node(), location)?;
+    let spec = type_spec(definition)?;
+    let underlying = spec.child_by_field_name("type")?;
+
+    if underlying.kind() == "struct_type" {
+        Some(GoTypeDefinition::Struct(struct_fields(underlying, source)))
+    } else {
+        Some(GoTypeDefinition::Other(text(underlying, source)?))
+    }
+}
+
+/// The `type_spec` or `type_alias` at or directly below a definition node
+pub(crate) fn type_spec(node: Node) -> Option<Node> {
+    if matches!(node.kind(), "type_spec" | "type_alias") {
+        return Some(node);
+    }
+    let mut cursor = node.walk();
+    let spec = node
+        .named_children(&mut cursor)
+        .find(|child| matches!(child.kind(), "type_spec" | "type_alias"));
+    spec
+}
+
+fn struct_fields(struct_type: Node, source: &str) -> Vec<GoField> {
+    let mut fields = Vec::new();
+    let Some(list) = struct_type
+        .named_children(&mut struct_type.walk())
+        .find(|child| child.kind() == "field_declaration_list")
+    else {
+        return fields;
+    };
+
+    let mut cursor = list.walk();
+    for declaration in list.named_children(&mut cursor) {
+        if declaration.kind() != "field_declaration" {
+            continue;
+        }
+        let Some(type_text) = declaration
+            .child_by_field_name("type")
+            .and_then(|node| text(node, source))
+        else {
+            continue;
+        };
+        let tag = declaration
+            .child_by_field_name("tag")
+            .and_then(|node| text(node, source))
+            .map(|tag| tag.trim_matches(['`', '"']).to_string());
+
+        let mut name_cursor = declaration.walk();
+        let names: Vec<String> = declaration
+            .children_by_field_name("name", &mut name_cursor)
+            .filter_map(|node| text(node, source))
+            .collect();
+
+        if names.is_empty() {
+            // `*Base` or `pkg.Base`: the field is named after the type; a
+            // pointer embed has the `*` outside the type node
+            let pointer = declaration
+                .child(0)
+                .is_some_and(|first| first.kind() == "*");
+            fields.push(GoField {
+                name: embedded_name(&type_text),
+                type_text: if pointer {
+                    format!("*{}", type_text)
+                } else {
+                    type_text
+                },
+                tag,
+                embedded: true,
+            });
+        } else {
+            for name in names {
+                fields.push(GoField {
+                    name,
+                    type_text: type_text.clone(),
+                    tag: tag.clone(),
+                    embedded: false,
+                });
+            }
+        }
+    }
+    fields
+}
+
+/// `*pkg.Base[T]` -> `Base`
+fn embedded_name(type_text: &str) -> String {
+    let name = type_text.trim_start_matches('*');
+    let name = name.split('[').next().unwrap_or(name);
+    name.rsplit('.').next().unwrap_or(name).to_string()
+}
+
+/// Read the `json` key of a struct tag like `json:"name,omitempty" db:"name"`.
+/// Returns the default (field name kept) when the tag has no `json` key.
+pub fn json_tag(tag: &str) -> JsonTag {
+    let Some(value) = tag_value(tag, "json") else {
+        return JsonTag::default();
+    };
+    if value == "-" {
+        return JsonTag {
+            skip: true,
+            ..Default::default()
+        };
+    }
+
+    let mut parts = value.split(',');
+    let name = parts.next().filter(|name| !name.is_empty());
+    let mut json_tag = JsonTag {
+        name: name.map(str::to_string),
+        ..Default::default()
+    };
+    for option in parts {
+        match option {
+            "omitempty" | "omitzero" => json_tag.omitempty = true,
+            "string" => json_tag.string = true,
+            _ => {}
+        }
+    }
+    json_tag
+}
+
+/// Value of `key` in a conventional `key:"value" key2:"value2"` struct tag
+fn tag_value<'a>(tag: &'a str, key: &str) -> Option<&'a str> {
+    let mut rest = tag.trim();
+    while !rest.is_empty() {
+        let colon = rest.find(':')?;
+        let name = rest[..colon].trim();
+        let quoted = rest[colon + 1..].strip_prefix('"')?;
+        let end = quoted.find('"')?;
+        if name == key {
+            return Some(&quoted[..end]);
+        }
+        rest = quoted[end + 1..].trim_start();
+    }
+    None
+}
+
+/// JSON Schema for a struct's encoded form.
+///
+/// Follows encoding/json: unexported fields and `json:"-"` are dropped, tag
+/// names replace field names, fields without `omitempty` are `required`, and
+/// untagged embedded structs contribute their fields through `allOf`. Named
+/// types are emitted as `$ref`s into `#/$defs/` and collected in `refs`.
+pub fn struct_schema(fields: &[GoField], refs: &mut Vec<String>) -> Value {
+    let mut properties = Map::new();
+    let mut required = Vec::new();
+    let mut embedded = Vec::new();
+
+    for field in fields {
+        let tag = field.tag.as_deref().map(json_tag).unwrap_or_default();
+        if tag.skip {
+            continue;
+        }
+        if field.embedded && tag.name.is_none() {
+            // An untagged embedded struct's fields are promoted to this one
+            embedded.push(type_schema(&field.type_text, refs));
+            continue;
+        }
+        if !field.embedded && !is_exported(&field.name) {
+            continue;
+        }
+
+        let name = tag.name.unwrap_or_else(|| field.name.clone());
+        let mut schema = type_schema(&field.type_text, refs);
+        if tag.string {
+            schema = json!({ "type": "string", "x-go-type": field.type_text });
+        }
+        if !tag.omitempty {
+            required.push(Value::String(name.clone()));
+        }
+        properties.insert(name, schema);
+    }
+
+    let mut schema = Map::new();
+    schema.insert("type".to_string(), json!("object"));
+    schema.insert("properties".to_string(), Value::Object(properties));
+    if !required.is_empty() {
+        schema.insert("required".to_string(), Value::Array(required));
+    }
+    if !embedded.is_empty() {
+        schema.insert("allOf".to_string(), Value::Array(embedded));
+    }
+    Value::Object(schema)
+}
+
+/// JSON Schema for a Go type expression. Named types become `$ref`s and are
+/// pushed onto `refs` for the caller to resolve.
+pub fn type_schema(type_text: &str, refs: &mut Vec<String>) -> Value {
+    let type_text = type_text.trim();
+
+    if let Some(pointee) = type_text.strip_prefix('*') {
+        return type_schema(pointee, refs);
+    }
+    if type_text == "[]byte" || type_text == "[]uint8" || type_text == "json.RawMessage" {
+        return match type_text {
+            // Raw messages are embedded verbatim and can hold any JSON value
+            "json.RawMessage" => json!({}),
+            _ => json!({ "type": "string", "contentEncoding": "base64" }),
+        };
+    }
+    if let Some(element) = type_text.strip_prefix("[]") {
+        return json!({ "type": "array", "items": type_schema(element, refs) });
+    }
+    if type_text.starts_with('[') {
+        if let Some(close) = type_text.find(']') {
+            return json!({ "type": "array", "items": type_schema(&type_text[close + 1..], refs) });
+        }
+    }
+    if let Some(rest) = type_text.strip_prefix("map[") {
+        if let Some(close) = matching_bracket(rest) {
+            let value = type_schema(&rest[close + 1..], refs);
+            return json!({ "type": "object", "additionalProperties": value });
+        }
+    }
+    if type_text.starts_with("struct") {
+        return json!({ "type": "object" });
+    }
+    if type_text.starts_with("interface") || type_text == "any" {
+        return json!({});
+    }
+
+    match type_text {
+        "string" => json!({ "type": "string" }),
+        "bool" => json!({ "type": "boolean" }),
+        "int" | "int8" | "int16" | "int32" | "int64" | "uint" | "uint8" | "uint16" | "uint32"
+        | "uint64" | "uintptr" | "byte" | "rune" => json!({ "type": "integer" }),
+        "float32" | "float64" => json!({ "type": "number" }),
+        "time.Time" => json!({ "type": "string", "format": "date-time" }),
+        "time.Duration" => json!({ "type": "integer", "description": "nanoseconds" }),
+        "error" => json!({}),
+        _ if type_text.starts_with("func") || type_text.starts_with("chan") => json!({}),
+        _ if type_text.starts_with("complex") => json!({}),
+        _ => {
+            let name = type_text.split('[').next().unwrap_or(type_text).to_string();
+            let reference = json!({ "$ref": format!("#/$defs/{}", name) });
+            if !refs.contains(&name) {
+                refs.push(name);
+            }
+            reference
+        }
+    }
+}
+
+/// Index of the `]` closing a `[` already consumed
+fn matching_bracket(text: &str) -> Option<usize> {
+    let mut depth = 1;
+    for (index, c) in text.char_indices() {
+        match c {
+            '[' => depth += 1,
+            ']' => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(index);
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+fn is_exported(name: &str) -> bool {
+    name.chars().next().is_some_and(char::is_uppercase)
+}
+
+/// Simplified view of a schema: property name to a short type description,
+/// `?` marking optional properties, with embedded structs' fields inlined
+/// from `defs`
+pub fn field_map(schema: &Value, defs: &Map<String, Value>) -> Map<String, Value> {
+    let mut fields = Map::new();
+    collect_fields(schema, defs, &mut fields, 0);
+    fields
+}
+
+fn collect_fields(
+    schema: &Value,
+    defs: &Map<String, Value>,
+    fields: &mut Map<String, Value>,
+    depth: usize,
+) {
+    // Embedded chains deeper than this are cyclic
+    if depth > 16 {
+        return;
+    }
+    let required: Vec<&str> = schema["required"]
+        .as_array()
+        .map(|names| names.iter().filter_map(Value::as_str).collect())
+        .unwrap_or_default();
+
+    for embedded in schema["allOf"].as_array().into_iter().flatten() {
+        if let Some(target) = resolve_ref(embedded, defs) {
+            collect_fields(target, defs, fields, depth + 1);
+        }
+    }
+    if let Some(properties) = schema["properties"].as_object() {
+        for (name, property) in properties {
+            let key = if required.contains(&name.as_str()) {
+                name.clone()
+            } else {
+                format!("{}?", name)
+            };
+            // Outer fields shadow promoted ones
+            fields.remove(&format!("{}?", name));
+            fields.remove(name);
+            fields.insert(key, Value::String(describe(property)));
+        }
+    }
+}
+
+fn resolve_ref<'a>(schema: &Value, defs: &'a Map<String, Value>) -> Option<&'a Value> {
+    let name = schema["$ref"].as_str()?.strip_prefix("#/$defs/")?;
+    defs.get(name)
+}
+
+/// `string`, `integer[]`, `map<string, User>`, `User`
+fn describe(schema: &Value) -> String {
+    if let Some(reference) = schema["$ref"].as_str() {
+        return reference.trim_start_matches("#/$defs/").to_string();
+    }
+    match schema["type"].as_str() {
+        Some("array") => format!("{}[]", describe(&schema["items"])),
+        Some("object") if schema.get("additionalProperties").is_some() => {
+            format!("map<string, {}>", describe(&schema["additionalProperties"]))
+        }
+        Some("string") => match schema["format"].as_str() {
+            Some(format) => format!("string({})", format),
+            None => "string".to_string(),
+        },
+        Some(kind) => kind.to_string(),
+        None => "any".to_string(),
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_json_tag() {
+        assert_eq!(
+            json_tag(r#"json:"created_at,omitempty" db:"created_at""#),
+            JsonTag {
+                name: Some("created_at".to_string()),
+                omitempty: true,
+                ..Default::default()
+            }
+        );
+        assert!(json_tag(r#"json:"-""#).skip);
+        // `-,` names the field "-" instead of skipping it
+        assert_eq!(json_tag(r#"json:"-,""#).name.as_deref(), Some("-"));
+        assert!(json_tag(r#"json:",string""#).string);
+        assert_eq!(json_tag(r#"db:"id""#), JsonTag::default());
+    }
+
+    #[test]
+    fn test_struct_schema() {
+        let field = |name: &str, type_text: &str, tag: Option<&str>| GoField {
+            name: name.to_string(),
+            type_text: type_text.to_string(),
+            tag: tag.map(str::to_string),
+            embedded: false,
+        };
+        let fields = vec![
+            field("ID", "int64", Some(r#"json:"id""#)),
+            field("Email", "string", Some(r#"json:"email,omitempty""#)),
+            field("Password", "string", Some(r#"json:"-""#)),
+            field("CreatedAt", "time.Time", Some(r#"json:"created_at""#)),
+            field("Roles", "[]*Role", Some(r#"json:"roles""#)),
+            field("Meta", "map[string]any", None),
+            field("internal", "string", None),
+            GoField {
+                name: "Base".to_string(),
+                type_text: "*models.Base".to_string(),
+                tag: None,
+                embedded: true,
+            },
+        ];
+
+        let mut refs = Vec::new();
+        let schema = struct_schema(&fields, &mut refs);
+        assert_eq!(
+            schema,
+            json!({
+                "type": "object",
+                "properties": {
+                    "id": { "type": "integer" },
+                    "email": { "type": "string" },
+                    "created_at": { "type": "string", "format": "date-time" },
+                    "roles": { "type": "array", "items": { "$ref": "#/$defs/Role" } },
+                    "Meta": { "type": "object", "additionalProperties": {} }
+                },
+                "required": ["id", "created_at", "roles", "Meta"],
+                "allOf": [{ "$ref": "#/$defs/models.Base" }]
+            })
+        );
+        assert_eq!(refs, vec!["Role", "models.Base"]);
+
+        let mut defs = Map::new();
+        defs.insert(
+            "models.Base".to_string(),
+            json!({
+                "type": "object",
+                "properties": { "version": { "type": "integer" } }
+            }),
+        );
+        let fields = field_map(&schema, &defs);
+        assert_eq!(fields["id"], "integer");
+        assert_eq!(fields["email?"], "string");
+        assert_eq!(fields["roles"], "Role[]");
+        assert_eq!(fields["version?"], "integer");
+        assert_eq!(fields["created_at"], "string(date-time)");
+    }
+
+    #[test]
+    fn test_go_type_definition() {
+        let source = r#"package models
+
+type User struct {
+    ID, OwnerID int64 `json:"id"`
+    *Base
+    Name string
+}
+
+type Status string
+"#;
+        let user = Location::new("user.go".into(), 3, 0, 7, 1);
+        let Some(GoTypeDefinition::Struct(fields)) = go_type_definition(source, &user) else {
+            panic!("expected a struct");
+        };
+        let summary: Vec<(&str, &str, bool)> = fields
+            .iter()
+            .map(|f| (f.name.as_str(), f.type_text.as_str(), f.embedded))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("ID", "int64", false),
+                ("OwnerID", "int64", false),
+                ("Base", "*Base", true),
+                ("Name", "string", false),
+            ]
+        );
+        assert_eq!(fields[0].tag.as_deref(), Some(r#"json:"id""#));
+
+        let status = Location::new("user.go".into(), 9, 5, 9, 18);
+        assert_eq!(
+            go_type_definition(source, &status),
+            Some(GoTypeDefinition::Other("string".to_string()))
+        );
+    }
+}
@@ -0,0 +1,157 @@
+use crate::indexing::settings::setting_var;
+use crate::models::{Language, SymbolType};
+use std::collections::{BTreeSet, HashMap};
+use std::sync::{Mutex, PoisonError};
+
+/// Kinds of the types methods belong to, kept whenever methods are indexed:
+/// Go methods also hang off named non-struct types such as `type Status int`,
+/// indexed as enums, and other languages declare methods on interfaces and
+/// enums
+const TYPE_KINDS_FOR_METHODS: &[SymbolType] = &[
+    SymbolType::Struct,
+    SymbolType::Class,
+    SymbolType::Enum,
+    SymbolType::Interface,
+];
+
+/// Kinds already reported as enabled for methods, by language, so the
+/// filter built on every pipeline construction and reload logs it once
+static REPORTED_METHOD_TYPES: Mutex<BTreeSet<String>> = Mutex::new(BTreeSet::new());
+
+/// Per-language set of symbol kinds to index, configured through
+/// ROBERTO_INDEX_KINDS as `go=function,method,struct,interface;python=class,function`.
+/// Languages without an entry index every kind.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct KindFilter {
+    enabled: HashMap<Language, Vec<SymbolType>>,
+}
+
+impl KindFilter {
+    /// Filter configured through ROBERTO_INDEX_KINDS; invalid values are
+    /// logged and ignored so indexing still covers every kind
+    pub fn from_env() -> Self {
+        match setting_var("ROBERTO_INDEX_KINDS") {
+            Ok(spec) => Self::parse(&spec).unwrap_or_else(|e| {
+                tracing::warn!("Ignoring ROBERTO_INDEX_KINDS: {}", e);
+                Self::default()
+            }),
+            Err(_) => Self::default(),
+        }
+    }
+
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let mut enabled = HashMap::new();
+
+        for entry in spec.split(';').filter(|entry| !entry.trim().is_empty()) {
+            let (language, kinds) = entry
+                .split_once('=')
+                .ok_or_else(|| format!("expected language=kind,... but got '{}'", entry))?;
+            let language = Language::from_name(language)
+                .ok_or_else(|| format!("unknown language '{}'", language.trim()))?;
+
+            let mut allowed: Vec<SymbolType> = Vec::new();
+            for kind in kinds.split(',').filter(|kind| !kind.trim().is_empty()) {
+                let kind = SymbolType::from_name(kind)
+                    .ok_or_else(|| format!("unknown symbol kind '{}'", kind.trim()))?;
+                if !allowed.contains(&kind) {
+                    allowed.push(kind);
+                }
+            }
+
+            // Methods hang off their type; keep the type so they stay reachable
+            if allowed.contains(&SymbolType::Method) {
+                for kind in TYPE_KINDS_FOR_METHODS {
+                    if !allowed.contains(kind) {
+                        let adjustment = format!("{} for {}", kind.as_str(), language.as_str());
+                        if REPORTED_METHOD_TYPES
+                            .lock()
+                            .unwrap_or_else(PoisonError::into_inner)
+                            .insert(adjustment.clone())
+                        {
+                            tracing::warn!("Enabling {} because methods are indexed", adjustment);
+                        }
+                        allowed.push(kind.clone());
+                    }
+                }
+            }
+
+            enabled.insert(language, allowed);
+        }
+
+        Ok(Self { enabled })
+    }
+
+    /// Whether symbols of `kind` should be stored for `language`
+    pub fn allows(&self, language: Language, kind: &SymbolType) -> bool {
+        self.enabled
+            .get(&language)
+            .map_or(true, |allowed| allowed.contains(kind))
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.enabled.is_empty()
+    }
+
+    /// Canonical form of the configuration, persisted with the cache so a
+    /// change forces a rebuild. Empty when every kind is indexed.
+    pub fn fingerprint(&self) -> String {
+        let mut entries: Vec<String> = self
+            .enabled
+            .iter()
+            .map(|(language, kinds)| {
+                let mut names: Vec<&str> = kinds.iter().map(|kind| kind.as_str()).collect();
+                names.sort_unstable();
+                format!("{}={}", language.as_str(), names.join(","))
+            })
+            .collect();
+        entries.sort();
+        entries.join(";")
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_and_allows() {
+        let filter = KindFilter::parse("go=function,method,struct,interface").unwrap();
+        assert!(filter.allows(Language::Go, &SymbolType::Function));
+        assert!(filter.allows(Language::Go, &SymbolType::Interface));
+        assert!(!filter.allows(Language::Go, &SymbolType::Variable));
+        assert!(!filter.allows(Language::Go, &SymbolType::Constant));
+
+        // Languages without an entry keep every kind
+        assert!(filter.allows(Language::Python, &SymbolType::Variable));
+        assert!(KindFilter::default().is_empty());
+    }
+
+    #[test]
+    fn test_methods_keep_their_types() {
+        let filter = KindFilter::parse("python=method").unwrap();
+        assert!(filter.allows(Language::Python, &SymbolType::Method));
+        assert!(filter.allows(Language::Python, &SymbolType::Class));
+        assert!(!filter.allows(Language::Python, &SymbolType::Function));
+
+        // Go methods on `type Status int` and on interfaces keep those too
+        let filter = KindFilter::parse("go=method").unwrap();
+        assert!(filter.allows(Language::Go, &SymbolType::Enum));
+        assert!(filter.allows(Language::Go, &SymbolType::Interface));
+        assert!(!filter.allows(Language::Go, &SymbolType::Variable));
+    }
+
+    #[test]
+    fn test_invalid_spec() {
+        assert!(KindFilter::parse("go").is_err());
+        assert!(KindFilter::parse("cobol=function").is_err());
+        assert!(KindFilter::parse("go=function,widget").is_err());
+    }
+
+    #[test]
+    fn test_fingerprint_is_canonical() {
+        let a = KindFilter::parse("rs=struct,function;go=method").unwrap();
+        let b = KindFilter::parse(" go = method,struct ; rust=function,struct").unwrap();
+        assert_eq!(a.fingerprint(), b.fingerprint());
+        assert_eq!(KindFilter::default().fingerprint(), "");
+    }
+}
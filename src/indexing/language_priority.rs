@@ -0,0 +1,249 @@
+use crate::indexing::settings::setting_var;
+use crate::models::Language;
+use std::collections::HashMap;
+
+/// Extensions shared by several built-in languages, with the candidates in
+/// priority order; the first is the fallback. `.h` stays C unless the
+/// content says otherwise.
+const DEFAULT_PRIORITIES: &[(&str, &[Language])] =
+    &[("h", &[Language::C, Language::Cpp, Language::ObjectiveC])];
+
+/// Line prefixes that only appear in Objective-C headers and sources
+const OBJC_MARKERS: &[&str] = &[
+    "#import",
+    "@interface",
+    "@implementation",
+    "@protocol",
+    "@property",
+    "@class",
+    "@end",
+];
+
+/// Line prefixes and fragments that C++ has and C does not
+const CPP_LINE_MARKERS: &[&str] = &[
+    "namespace ",
+    "template<",
+    "template <",
+    "class ",
+    "using namespace",
+    "public:",
+    "private:",
+    "protected:",
+];
+const CPP_FRAGMENTS: &[&str] = &["std::"];
+
+/// Which language parses files with an extension several languages use,
+/// configured through ROBERTO_LANGUAGE_PRIORITY as `h=cpp,c,objc;m=objc`.
+/// Candidates are tried in order against content heuristics and the first
+/// is used when none matches; a single language is used without looking at
+/// the content. Entries replace the defaults for their extension.
+#[derive(Debug, Clone, PartialEq)]
+pub struct LanguagePriority {
+    priorities: HashMap<String, Vec<Language>>,
+}
+
+/// The language picked for a file and why
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LanguageChoice {
+    pub language: Language,
+    pub reason: String,
+}
+
+impl Default for LanguagePriority {
+    fn default() -> Self {
+        Self {
+            priorities: DEFAULT_PRIORITIES
+                .iter()
+                .map(|(extension, languages)| (extension.to_string(), languages.to_vec()))
+                .collect(),
+        }
+    }
+}
+
+impl LanguagePriority {
+    /// Priorities configured through ROBERTO_LANGUAGE_PRIORITY; invalid
+    /// values are logged and ignored so the defaults apply
+    pub fn from_env() -> Self {
+        match setting_var("ROBERTO_LANGUAGE_PRIORITY") {
+            Ok(spec) => Self::parse(&spec).unwrap_or_else(|e| {
+                tracing::warn!("Ignoring ROBERTO_LANGUAGE_PRIORITY: {}", e);
+                Self::default()
+            }),
+            Err(_) => Self::default(),
+        }
+    }
+
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let mut priority = Self::default();
+
+        for entry in spec.split(';').filter(|entry| !entry.trim().is_empty()) {
+            let (extension, languages) = entry
+                .split_once('=')
+                .ok_or_else(|| format!("expected extension=language,... but got '{}'", entry))?;
+            let extension = extension.trim().trim_start_matches('.').to_lowercase();
+            if extension.is_empty() {
+                return Err(format!("missing extension in '{}'", entry));
+            }
+
+            let mut candidates: Vec<Language> = Vec::new();
+            for language in languages.split(',').filter(|l| !l.trim().is_empty()) {
+                let language = Language::from_name(language)
+                    .ok_or_else(|| format!("unknown language '{}'", language.trim()))?;
+                if !candidates.contains(&language) {
+                    candidates.push(language);
+                }
+            }
+            if candidates.is_empty() {
+                return Err(format!("no languages given for .{}", extension));
+            }
+
+            priority.priorities.insert(extension, candidates);
+        }
+
+        Ok(priority)
+    }
+
+    /// Whether files with `extension` are parsed by a language picked here
+    pub fn covers(&self, extension: &str) -> bool {
+        self.priorities.contains_key(extension)
+    }
+
+    /// The language to parse `source` with, for an extension with configured
+    /// candidates
+    pub fn choose(&self, extension: &str, source: &str) -> Option<LanguageChoice> {
+        let candidates = self.priorities.get(extension)?;
+        let fallback = *candidates.first()?;
+        if candidates.len() == 1 {
+            return Some(LanguageChoice {
+                language: fallback,
+                reason: format!("configured for .{}", extension),
+            });
+        }
+
+        for &language in candidates {
+            if let Some(marker) = content_marker(language, source) {
+                return Some(LanguageChoice {
+                    language,
+                    reason: format!("found `{}`", marker.trim()),
+                });
+            }
+        }
+        Some(LanguageChoice {
+            language: fallback,
+            reason: format!("no heuristic matched, default for .{}", extension),
+        })
+    }
+
+    /// Canonical form of the configuration, persisted with the cache so a
+    /// change forces a rebuild. Empty with the default priorities.
+    pub fn fingerprint(&self) -> String {
+        if *self == Self::default() {
+            return String::new();
+        }
+        let mut entries: Vec<String> = self
+            .priorities
+            .iter()
+            .map(|(extension, languages)| {
+                let names: Vec<&str> = languages.iter().map(|l| l.as_str()).collect();
+                format!("{}={}", extension, names.join(","))
+            })
+            .collect();
+        entries.sort();
+        entries.join(";")
+    }
+}
+
+/// The first construct in `source` that only `language` has among the
+/// languages sharing an extension. C has none: it is what a header is when
+/// nothing else matches.
+fn content_marker(language: Language, source: &str) -> Option<&'static str> {
+    let (line_markers, fragments): (&[&str], &[&str]) = match language {
+        Language::ObjectiveC => (OBJC_MARKERS, &[]),
+        Language::Cpp => (CPP_LINE_MARKERS, CPP_FRAGMENTS),
+        _ => return None,
+    };
+    source
+        .lines()
+        .map(str::trim_start)
+        .filter(|line| !line.starts_with("//") && !line.starts_with('*'))
+        .find_map(|line| {
+            line_markers
+                .iter()
+                .find(|marker| line.starts_with(*marker))
+                .or_else(|| fragments.iter().find(|fragment| line.contains(*fragment)))
+                .copied()
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const C_HEADER: &str = "#ifndef LIST_H\n#define LIST_H\n\nstruct list { int len; };\nint list_push(struct list *l, int v);\n#endif\n";
+    const CPP_HEADER: &str = "#pragma once\n\n// A class for lists\nnamespace util {\nclass List {\npublic:\n    void push(int v);\n};\n}\n";
+    const OBJC_HEADER: &str =
+        "#import <Foundation/Foundation.h>\n\n@interface List : NSObject\n- (void)push:(int)v;\n@end\n";
+
+    #[test]
+    fn test_default_heuristics() {
+        let priority = LanguagePriority::default();
+
+        let choice = priority.choose("h", C_HEADER).unwrap();
+        assert_eq!(choice.language, Language::C);
+        assert_eq!(choice.reason, "no heuristic matched, default for .h");
+
+        let choice = priority.choose("h", CPP_HEADER).unwrap();
+        assert_eq!(choice.language, Language::Cpp);
+        assert_eq!(choice.reason, "found `namespace`");
+
+        let choice = priority.choose("h", OBJC_HEADER).unwrap();
+        assert_eq!(choice.language, Language::ObjectiveC);
+        assert_eq!(choice.reason, "found `#import`");
+
+        assert!(priority.choose("go", "package main").is_none());
+    }
+
+    #[test]
+    fn test_configured_priority() {
+        let priority = LanguagePriority::parse("h=cpp,c;.M=objc").unwrap();
+
+        // Plain C headers now fall back to C++
+        assert_eq!(
+            priority.choose("h", C_HEADER).unwrap().language,
+            Language::Cpp
+        );
+        // Objective-C is no longer a candidate for .h
+        assert_eq!(
+            priority.choose("h", OBJC_HEADER).unwrap().language,
+            Language::Cpp
+        );
+
+        let choice = priority.choose("m", "function y = f(x)\n").unwrap();
+        assert_eq!(choice.language, Language::ObjectiveC);
+        assert_eq!(choice.reason, "configured for .m");
+        assert!(priority.covers("m"));
+    }
+
+    #[test]
+    fn test_invalid_spec() {
+        assert!(LanguagePriority::parse("h").is_err());
+        assert!(LanguagePriority::parse("h=cobol").is_err());
+        assert!(LanguagePriority::parse("h=").is_err());
+        assert!(LanguagePriority::parse("=c").is_err());
+    }
+
+    #[test]
+    fn test_fingerprint() {
+        assert_eq!(LanguagePriority::default().fingerprint(), "");
+        assert_eq!(
+            LanguagePriority::parse("h=c,cpp,objc")
+                .unwrap()
+                .fingerprint(),
+            ""
+        );
+        assert_eq!(
+            LanguagePriority::parse("h=cpp,c").unwrap().fingerprint(),
+            "h=cpp,c"
+        );
+    }
+}
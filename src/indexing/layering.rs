@@ -0,0 +1,217 @@
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+
+/// A package of the package graph: the directory its files live in, and the
+/// package or namespace name they declare
+#[derive(Debug, Clone, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+pub struct Package {
+    /// The directory, as `display_path` writes it
+    pub path: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub name: Option<String>,
+}
+
+impl Package {
+    /// Whether `pattern` names this package: its package name, its
+    /// directory or the trailing directories of it (`service` matches
+    /// `internal/service`), or any directory under `prefix` for `prefix/...`
+    pub fn matches(&self, pattern: &str) -> bool {
+        self.name.as_deref() == Some(pattern) || path_matches(pattern, &self.path)
+    }
+}
+
+fn path_matches(pattern: &str, path: &str) -> bool {
+    let (prefix, subtree) = match pattern.strip_suffix("/...") {
+        Some(prefix) => (prefix, true),
+        None => (pattern, false),
+    };
+    let prefix = prefix.trim_matches('/');
+    if prefix.is_empty() {
+        return false;
+    }
+    path.match_indices(prefix).any(|(start, _)| {
+        let rest = &path[start + prefix.len()..];
+        (start == 0 || path[..start].ends_with('/'))
+            && (rest.is_empty() || (subtree && rest.starts_with('/')))
+    })
+}
+
+/// A dependency forbidden by a layering policy
+#[derive(Debug, Clone, PartialEq, Eq, Deserialize, Serialize, JsonSchema)]
+pub struct DeniedDependency {
+    /// The depending package
+    pub from: String,
+    /// The package it must not depend on
+    pub to: String,
+}
+
+/// Which packages may depend on which. Packages are named by the patterns
+/// [`Package::matches`] accepts.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Deserialize, Serialize, JsonSchema)]
+pub struct LayeringPolicy {
+    /// The packages each package may depend on, e.g.
+    /// `{"transport": ["service"], "service": ["storage"]}`. A package
+    /// matched by no key may depend on anything; packages matched by the
+    /// same key may depend on each other.
+    #[serde(default)]
+    pub allow: BTreeMap<String, Vec<String>>,
+    /// Dependencies forbidden whatever `allow` says
+    #[serde(default)]
+    pub deny: Vec<DeniedDependency>,
+}
+
+impl LayeringPolicy {
+    /// The rule a dependency of `from` on `to` breaks, if any
+    pub fn violation(&self, from: &Package, to: &Package) -> Option<String> {
+        if let Some(denied) = self
+            .deny
+            .iter()
+            .find(|denied| from.matches(&denied.from) && to.matches(&denied.to))
+        {
+            return Some(format!("deny: {} -> {}", denied.from, denied.to));
+        }
+
+        let layers: Vec<(&String, &Vec<String>)> = self
+            .allow
+            .iter()
+            .filter(|(layer, _)| from.matches(layer))
+            .collect();
+        if layers.is_empty() {
+            return None;
+        }
+        let allowed = layers.iter().any(|(layer, allowed)| {
+            to.matches(layer) || allowed.iter().any(|pattern| to.matches(pattern))
+        });
+        if allowed {
+            return None;
+        }
+        let rules: Vec<String> = layers
+            .iter()
+            .map(|(layer, allowed)| format!("allow: {} -> [{}]", layer, allowed.join(", ")))
+            .collect();
+        Some(rules.join("; "))
+    }
+
+    /// A cycle among the `allow` rules, which must form a DAG:
+    /// `["service", "storage", "service"]`
+    pub fn cycle(&self) -> Option<Vec<String>> {
+        let mut state: BTreeMap<&str, bool> = BTreeMap::new();
+        let mut path = Vec::new();
+        for layer in self.allow.keys() {
+            if let Some(cycle) = self.find_cycle(layer, &mut state, &mut path) {
+                return Some(cycle);
+            }
+        }
+        None
+    }
+
+    /// Depth-first search; `state` is false while a layer is on the current
+    /// path and true once it is finished
+    fn find_cycle<'a>(
+        &'a self,
+        layer: &'a str,
+        state: &mut BTreeMap<&'a str, bool>,
+        path: &mut Vec<&'a str>,
+    ) -> Option<Vec<String>> {
+        match state.get(layer) {
+            Some(true) => return None,
+            Some(false) => {
+                let start = path.iter().position(|l| *l == layer).unwrap_or(0);
+                let mut cycle: Vec<String> = path[start..].iter().map(|l| l.to_string()).collect();
+                cycle.push(layer.to_string());
+                return Some(cycle);
+            }
+            None => {}
+        }
+        state.insert(layer, false);
+        path.push(layer);
+        for next in self.allow.get(layer).into_iter().flatten() {
+            if next != layer && self.allow.contains_key(next) {
+                if let Some(cycle) = self.find_cycle(next, state, path) {
+                    return Some(cycle);
+                }
+            }
+        }
+        path.pop();
+        state.insert(layer, true);
+        None
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn package(path: &str, name: &str) -> Package {
+        Package {
+            path: path.to_string(),
+            name: Some(name.to_string()),
+        }
+    }
+
+    #[test]
+    fn test_package_matches() {
+        let service = package("internal/service/users", "users");
+        assert!(service.matches("users"));
+        assert!(service.matches("service/users"));
+        assert!(service.matches("internal/service/..."));
+        assert!(service.matches("service/..."));
+        assert!(!service.matches("service"));
+        assert!(!service.matches("ervice/users"));
+        assert!(!service.matches("transport/..."));
+        assert!(package("/src/app/internal", "internal").matches("internal/..."));
+    }
+
+    #[test]
+    fn test_layering_violations() {
+        let policy: LayeringPolicy = serde_json::from_str(
+            r#"{
+                "allow": {
+                    "transport/...": ["service/...", "models"],
+                    "service/...": ["storage", "models"]
+                },
+                "deny": [{"from": "models", "to": "storage"}]
+            }"#,
+        )
+        .unwrap();
+        assert_eq!(policy.cycle(), None);
+
+        let transport = package("transport/http", "http");
+        let users = package("service/users", "users");
+        let orders = package("service/orders", "orders");
+        let storage = package("storage", "storage");
+        let models = package("models", "models");
+
+        assert_eq!(policy.violation(&transport, &users), None);
+        assert_eq!(policy.violation(&users, &orders), None);
+        assert_eq!(policy.violation(&users, &storage), None);
+        assert_eq!(
+            policy.violation(&users, &transport).as_deref(),
+            Some("allow: service/... -> [storage, models]")
+        );
+        assert_eq!(
+            policy.violation(&models, &storage).as_deref(),
+            Some("deny: models -> storage")
+        );
+        // Packages no rule names are unconstrained
+        assert_eq!(policy.violation(&storage, &transport), None);
+    }
+
+    #[test]
+    fn test_layering_policy_cycle() {
+        let policy: LayeringPolicy = serde_json::from_str(
+            r#"{"allow": {"service": ["storage"], "storage": ["cache"], "cache": ["service"]}}"#,
+        )
+        .unwrap();
+        assert_eq!(
+            policy.cycle(),
+            Some(vec![
+                "cache".to_string(),
+                "service".to_string(),
+                "storage".to_string(),
+                "cache".to_string()
+            ])
+        );
+    }
+}
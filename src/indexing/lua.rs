@@ -0,0 +1,172 @@
+use crate::models::{Symbol, SymbolType, Visibility};
+use std::collections::HashSet;
+use tree_sitter::{Node, Point};
+
+/// Name of the table a Lua chunk returns as its module (`return M`)
+pub fn module_table(root: Node, source: &str) -> Option<String> {
+    let mut cursor = root.walk();
+    let statement = root
+        .named_children(&mut cursor)
+        .filter(|child| child.kind() == "return_statement")
+        .last()?;
+    let values = statement.named_child(0)?;
+    let value = if values.kind() == "expression_list" {
+        values.named_child(0)?
+    } else {
+        values
+    };
+    if value.kind() != "identifier" {
+        return None;
+    }
+    node_text(value, source)
+}
+
+/// Mark the returned table as the module and derive symbol visibility from it.
+///
+/// `local` declarations are private; members of a local table are private
+/// unless that table is the one the chunk returns. Globals stay public.
+pub fn apply_module_surface(root: Node, source: &str, symbols: &mut [Symbol]) {
+    let module = module_table(root, source);
+    let local_tables = local_names(root, source);
+
+    for symbol in symbols.iter_mut() {
+        let definition = match definition_node(root, symbol) {
+            Some(node) => node,
+            None => continue,
+        };
+        let owner = owner_table(definition, source);
+        let is_module = owner.is_none() && module.as_deref() == Some(symbol.name.as_str());
+
+        if is_module && symbol.symbol_type == SymbolType::Class {
+            symbol.symbol_type = SymbolType::Module;
+        }
+
+        let exported = if is_module {
+            true
+        } else if is_local(definition) {
+            false
+        } else {
+            match owner.as_deref().map(root_table) {
+                Some(table) => module.as_deref() == Some(table) || !local_tables.contains(table),
+                None => true,
+            }
+        };
+        symbol.visibility = if exported {
+            Visibility::Public
+        } else {
+            Visibility::Private
+        };
+    }
+}
+
+/// Find the definition node a symbol was created from
+fn definition_node<'t>(root: Node<'t>, symbol: &Symbol) -> Option<Node<'t>> {
+    let location = &symbol.location;
+    let start = Point::new(
+        location.start_line.saturating_sub(1) as usize,
+        location.start_column as usize,
+    );
+    let end = Point::new(
+        location.end_line.saturating_sub(1) as usize,
+        location.end_column as usize,
+    );
+
+    let mut node = root.descendant_for_point_range(start, end)?;
+    loop {
+        if matches!(
+            node.kind(),
+            "function_declaration" | "assignment_statement" | "field"
+        ) {
+            return Some(node);
+        }
+        node = node.parent()?;
+    }
+}
+
+/// Table a definition is attached to: `M` for `function M.foo()`, `M:foo()`,
+/// `M.foo = function() end` and `local M = { foo = function() end }`
+fn owner_table(definition: Node, source: &str) -> Option<String> {
+    match definition.kind() {
+        "function_declaration" => {
+            let name = definition.child_by_field_name("name")?;
+            node_text(name.child_by_field_name("table")?, source)
+        }
+        "assignment_statement" => {
+            let target = first_target(definition)?;
+            node_text(target.child_by_field_name("table")?, source)
+        }
+        "field" => {
+            let constructor = definition.parent()?;
+            let assignment = constructor.parent()?.parent()?;
+            if assignment.kind() != "assignment_statement" {
+                return None;
+            }
+            node_text(first_target(assignment)?, source)
+        }
+        _ => None,
+    }
+}
+
+fn first_target(assignment: Node) -> Option<Node> {
+    let mut cursor = assignment.walk();
+    let variables = assignment
+        .named_children(&mut cursor)
+        .find(|child| child.kind() == "variable_list")?;
+    variables.named_child(0)
+}
+
+fn is_local(definition: Node) -> bool {
+    match definition.kind() {
+        "function_declaration" => definition
+            .child(0)
+            .map(|first| first.kind() == "local")
+            .unwrap_or(false),
+        "assignment_statement" => definition
+            .parent()
+            .map(|parent| parent.kind() == "variable_declaration")
+            .unwrap_or(false),
+        _ => false,
+    }
+}
+
+/// Names declared `local` at the top level of the chunk
+fn local_names(root: Node, source: &str) -> HashSet<String> {
+    let mut names = HashSet::new();
+    let mut cursor = root.walk();
+    for statement in root.named_children(&mut cursor) {
+        if statement.kind() != "variable_declaration" {
+            continue;
+        }
+        let declared = match statement.named_child(0) {
+            Some(node) if node.kind() == "assignment_statement" => first_target(node),
+            Some(node) => node.named_child(0),
+            None => None,
+        };
+        if let Some(name) = declared.and_then(|node| node_text(node, source)) {
+            names.insert(name);
+        }
+    }
+    names
+}
+
+/// `M` for `M.sub.inner`
+fn root_table(table: &str) -> &str {
+    table.split(['.', ':']).next().unwrap_or(table)
+}
+
+fn node_text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.trim().to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_root_table() {
+        assert_eq!(root_table("M.sub.inner"), "M");
+        assert_eq!(root_table("Account"), "Account");
+    }
+}
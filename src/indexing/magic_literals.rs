@@ -0,0 +1,295 @@
+use crate::indexing::return_counts::FUNCTION_KINDS;
+use crate::indexing::sql_queries::literal_content;
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Numeric literal node kinds across the supported grammars
+const NUMBER_KINDS: &[&str] = &[
+    "int_literal",
+    "float_literal",
+    "imaginary_literal",
+    "integer_literal",
+    "integer",
+    "float",
+    "number",
+    "number_literal",
+    "real_literal",
+    "decimal_integer_literal",
+    "hex_integer_literal",
+    "octal_integer_literal",
+    "binary_integer_literal",
+    "decimal_floating_point_literal",
+    "floating_point_literal",
+];
+
+/// Declarations that already name their values
+const CONSTANT_KINDS: &[&str] = &["const_declaration", "const_item", "enum_item"];
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum LiteralKind {
+    Number,
+    String,
+}
+
+impl LiteralKind {
+    pub const NAMES: &'static [&'static str] = &["number", "string"];
+
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.to_ascii_lowercase().as_str() {
+            "number" => Some(LiteralKind::Number),
+            "string" => Some(LiteralKind::String),
+            _ => None,
+        }
+    }
+}
+
+/// A literal in a function body
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct MagicLiteral {
+    pub kind: LiteralKind,
+    /// Numbers as written, with a unit they multiply (`100 * time.Millisecond`);
+    /// strings without their quotes
+    pub value: String,
+    pub line: u32,
+    pub column: u32,
+}
+
+/// Which literals are worth reporting
+#[derive(Debug, Clone)]
+pub struct LiteralFilter {
+    pub kinds: Vec<LiteralKind>,
+    /// Bare numbers whose magnitude is below this are skipped
+    pub min_number: Option<f64>,
+    /// Strings shorter than this, in characters, are skipped
+    pub min_string_length: usize,
+    /// Values never reported, compared with `MagicLiteral::value`
+    pub ignore: Vec<String>,
+}
+
+impl Default for LiteralFilter {
+    fn default() -> Self {
+        Self {
+            kinds: vec![LiteralKind::Number, LiteralKind::String],
+            min_number: None,
+            min_string_length: 1,
+            ignore: Vec::new(),
+        }
+    }
+}
+
+impl LiteralFilter {
+    fn allows(&self, literal: &MagicLiteral, bare: bool) -> bool {
+        if !self.kinds.contains(&literal.kind) || self.ignore.contains(&literal.value) {
+            return false;
+        }
+        match literal.kind {
+            LiteralKind::String => literal.value.chars().count() >= self.min_string_length,
+            // A unit multiplier is the value: `1 * time.Hour` is an hour
+            LiteralKind::Number if !bare => true,
+            LiteralKind::Number => match number_value(&literal.value) {
+                Some(value) => {
+                    value != 0.0
+                        && value != 1.0
+                        && self.min_number.is_none_or(|min| value.abs() >= min)
+                }
+                None => true,
+            },
+        }
+    }
+}
+
+/// Find the numeric and string literals in function bodies that could be
+/// named constants. 0, 1 and empty strings are never reported. A number
+/// multiplying a unit (`50 * time.Millisecond`, `1*time.Hour`) is reported
+/// as the whole product. Literals in constant declarations and strings that
+/// stand alone as a statement (docstrings, `"use strict"`) are skipped.
+pub fn find_magic_literals(
+    source: &str,
+    language: Language,
+    filter: &LiteralFilter,
+) -> Result<Vec<MagicLiteral>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut literals = Vec::new();
+    visit(tree.root_node(), source, false, filter, &mut literals);
+    Ok(literals)
+}
+
+fn visit(
+    node: Node,
+    source: &str,
+    in_function: bool,
+    filter: &LiteralFilter,
+    literals: &mut Vec<MagicLiteral>,
+) {
+    if CONSTANT_KINDS.contains(&node.kind()) {
+        return;
+    }
+    let in_function = in_function || FUNCTION_KINDS.contains(&node.kind());
+    let text = || node.utf8_text(source.as_bytes()).unwrap_or_default();
+    let position = node.start_position();
+
+    if node.kind().contains("string") {
+        let statement = node
+            .parent()
+            .is_some_and(|parent| parent.kind() == "expression_statement");
+        if in_function && !statement {
+            let literal = MagicLiteral {
+                kind: LiteralKind::String,
+                value: literal_content(text()).to_string(),
+                line: position.row as u32 + 1,
+                column: position.column as u32,
+            };
+            if filter.allows(&literal, true) {
+                literals.push(literal);
+            }
+        }
+        return;
+    }
+
+    if NUMBER_KINDS.contains(&node.kind()) {
+        if in_function {
+            let (value, bare) = match unit_product(node, source) {
+                Some(product) => (product, false),
+                None => (text().to_string(), true),
+            };
+            let literal = MagicLiteral {
+                kind: LiteralKind::Number,
+                value,
+                line: position.row as u32 + 1,
+                column: position.column as u32,
+            };
+            if filter.allows(&literal, bare) {
+                literals.push(literal);
+            }
+        }
+        return;
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, in_function, filter, literals);
+    }
+}
+
+/// `100 * time.Millisecond` when `number` multiplies a qualified name
+fn unit_product(number: Node, source: &str) -> Option<String> {
+    let product = number.parent()?;
+    let operator = product.child_by_field_name("operator")?;
+    if operator.utf8_text(source.as_bytes()).ok()? != "*" {
+        return None;
+    }
+    let left = product.child_by_field_name("left")?;
+    let right = product.child_by_field_name("right")?;
+    let unit = if left.id() == number.id() {
+        right
+    } else {
+        left
+    };
+    if !matches!(
+        unit.kind(),
+        "selector_expression" | "member_expression" | "field_access" | "attribute"
+    ) {
+        return None;
+    }
+    product
+        .utf8_text(source.as_bytes())
+        .ok()
+        .map(str::to_string)
+}
+
+/// The value of a numeric literal as written: `1_000`, `0x1F`, `2.5e3`,
+/// `10u32`; `None` for forms it does not read
+fn number_value(text: &str) -> Option<f64> {
+    let text = text.replace('_', "").to_ascii_lowercase();
+    let radix = [("0x", 16), ("0o", 8), ("0b", 2)]
+        .iter()
+        .find_map(|(prefix, radix)| text.strip_prefix(prefix).map(|digits| (digits, *radix)));
+    if let Some((digits, radix)) = radix {
+        let digits = digits.trim_end_matches(|c: char| !c.is_digit(radix));
+        return i64::from_str_radix(digits, radix).ok().map(|v| v as f64);
+    }
+    // Drop type suffixes: `10u32`, `1.5f`, `2L`
+    let end = text
+        .find(|c: char| !(c.is_ascii_digit() || matches!(c, '.' | 'e' | '+' | '-')))
+        .unwrap_or(text.len());
+    text[..end].parse().ok()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_number_value() {
+        assert_eq!(number_value("1_000"), Some(1000.0));
+        assert_eq!(number_value("0x1F"), Some(31.0));
+        assert_eq!(number_value("2.5e3"), Some(2500.0));
+        assert_eq!(number_value("10u32"), Some(10.0));
+        assert_eq!(number_value("1.0f"), Some(1.0));
+        assert_eq!(number_value("0"), Some(0.0));
+    }
+
+    #[test]
+    fn test_literal_filter() {
+        let literal = |kind, value: &str| MagicLiteral {
+            kind,
+            value: value.to_string(),
+            line: 1,
+            column: 0,
+        };
+        let filter = LiteralFilter {
+            min_number: Some(10.0),
+            min_string_length: 3,
+            ignore: vec!["404".to_string()],
+            ..LiteralFilter::default()
+        };
+        assert!(!filter.allows(&literal(LiteralKind::Number, "1"), true));
+        assert!(!filter.allows(&literal(LiteralKind::Number, "0.0"), true));
+        assert!(!filter.allows(&literal(LiteralKind::Number, "5"), true));
+        assert!(filter.allows(&literal(LiteralKind::Number, "1*time.Hour"), false));
+        assert!(filter.allows(&literal(LiteralKind::Number, "500"), true));
+        assert!(!filter.allows(&literal(LiteralKind::Number, "404"), true));
+        assert!(!filter.allows(&literal(LiteralKind::String, "id"), true));
+        assert!(filter.allows(&literal(LiteralKind::String, "user:%d"), true));
+    }
+
+    #[test]
+    fn test_find_magic_literals() {
+        let source = r#"package cache
+
+const DefaultTTL = 30 * time.Second
+
+func (c *Client) Get(key string) (string, error) {
+    for i := 0; i < 3; i++ {
+        select {
+        case v := <-c.fetch(key):
+            return v, nil
+        case <-time.After(100 * time.Millisecond):
+        }
+    }
+    c.cache.Set(key, "", 1*time.Hour)
+    return "", fmt.Errorf("miss: %s", key)
+}
+"#;
+        let literals =
+            find_magic_literals(source, Language::Go, &LiteralFilter::default()).unwrap();
+        let found: Vec<(LiteralKind, &str, u32)> = literals
+            .iter()
+            .map(|l| (l.kind, l.value.as_str(), l.line))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (LiteralKind::Number, "3", 6),
+                (LiteralKind::Number, "100 * time.Millisecond", 10),
+                (LiteralKind::Number, "1*time.Hour", 13),
+                (LiteralKind::String, "miss: %s", 14),
+            ]
+        );
+    }
+}
@@ -0,0 +1,262 @@
+use crate::indexing::frontend::{FrontendConfig, FrontendOutput, LanguageFrontend};
+use crate::indexing::indexer::SymbolIndexer;
+use crate::indexing::settings::setting_var;
+use crate::models::{Language, Location, SymbolId};
+use std::path::Path;
+
+/// Tag on symbols extracted from a code block of a markdown file
+pub const FROM_DOCS_TAG: &str = "from_docs";
+/// Tag with the 1-based line of the opening fence of the block
+pub const DOC_BLOCK_TAG: &str = "doc_block";
+/// Tag with the language the block was parsed as
+pub const DOC_LANGUAGE_TAG: &str = "doc_language";
+
+/// A fenced code block of a markdown document
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CodeBlock {
+    /// The language named by the info string, if it is a supported one
+    pub language: Option<Language>,
+    /// The info string after the opening fence, e.g. `go title="main.go"`
+    pub info: String,
+    /// Line of the opening fence, 1-based
+    pub fence_line: u32,
+    /// Indentation of the fence, removed from every line of the code
+    pub indent: usize,
+    pub code: String,
+}
+
+/// Language named by the first word of a fence's info string: `go`, `py`,
+/// `golang`, `{.python}`. None for untagged blocks and unknown languages.
+pub fn block_language(info: &str) -> Option<Language> {
+    let word = info
+        .trim_start_matches(['{', '.'])
+        .split(|c: char| c.is_whitespace() || c == ',' || c == '}')
+        .next()?
+        .to_lowercase();
+    match word.as_str() {
+        "" => None,
+        "golang" => Some(Language::Go),
+        "python3" | "py3" => Some(Language::Python),
+        "c++" => Some(Language::Cpp),
+        "c#" => Some(Language::CSharp),
+        "objective-c" | "objectivec" => Some(Language::ObjectiveC),
+        word => Language::from_name(word),
+    }
+}
+
+/// Fenced code blocks (```` ``` ```` or `~~~`) of a markdown document. A
+/// block left open runs to the end of the document.
+pub fn code_blocks(source: &str) -> Vec<CodeBlock> {
+    let mut blocks = Vec::new();
+    let mut open: Option<(char, usize, CodeBlock)> = None;
+    for (row, line) in source.lines().enumerate() {
+        let trimmed = line.trim_start();
+        let indent = line.len() - trimmed.len();
+        let fence = fence(trimmed);
+
+        if let Some((fence_char, fence_len, block)) = &mut open {
+            let closes = fence.is_some_and(|(c, len, info)| {
+                c == *fence_char && len >= *fence_len && info.is_empty()
+            });
+            if closes {
+                blocks.push(open.take().unwrap().2);
+                continue;
+            }
+            let strip = line
+                .char_indices()
+                .take_while(|(i, c)| *i < block.indent && *c == ' ')
+                .count();
+            block.code.push_str(&line[strip..]);
+            block.code.push('\n');
+            continue;
+        }
+
+        if let Some((fence_char, fence_len, info)) = fence {
+            // A backtick fence's info string cannot contain backticks
+            if fence_char == '`' && info.contains('`') {
+                continue;
+            }
+            let block = CodeBlock {
+                language: block_language(info),
+                info: info.to_string(),
+                fence_line: row as u32 + 1,
+                indent,
+                code: String::new(),
+            };
+            open = Some((fence_char, fence_len, block));
+        }
+    }
+    blocks.extend(open.map(|(_, _, block)| block));
+    blocks
+}
+
+/// The character, length and info string of a fence line
+fn fence(line: &str) -> Option<(char, usize, &str)> {
+    let fence_char = line.chars().next().filter(|c| *c == '`' || *c == '~')?;
+    let len = line.chars().take_while(|c| *c == fence_char).count();
+    if len < 3 {
+        return None;
+    }
+    Some((fence_char, len, line[len..].trim()))
+}
+
+/// Indexes the fenced code blocks of markdown files with the frontend of the
+/// language each block is tagged with, so doc examples can be checked
+/// against the real API. Symbols and references keep positions in the
+/// markdown file; symbols carry the [`FROM_DOCS_TAG`], [`DOC_BLOCK_TAG`] and
+/// [`DOC_LANGUAGE_TAG`] tags. Blocks without a language tag or with an
+/// unsupported language are skipped.
+pub struct MarkdownFrontend {
+    indexer: SymbolIndexer,
+}
+
+impl MarkdownFrontend {
+    pub fn new() -> Result<Self, Box<dyn std::error::Error>> {
+        Ok(Self {
+            indexer: SymbolIndexer::new()?,
+        })
+    }
+
+    /// Whether ROBERTO_INDEX_DOCS turns on indexing markdown code blocks
+    pub fn enabled_from_env() -> bool {
+        setting_var("ROBERTO_INDEX_DOCS")
+            .is_ok_and(|value| matches!(value.trim().to_lowercase().as_str(), "1" | "true" | "yes"))
+    }
+}
+
+impl LanguageFrontend for MarkdownFrontend {
+    fn name(&self) -> &str {
+        "markdown"
+    }
+
+    fn extensions(&self) -> Vec<String> {
+        vec!["md".to_string(), "markdown".to_string()]
+    }
+
+    fn configure(&mut self, config: &FrontendConfig) {
+        self.indexer.set_tag_keys(config.tag_keys.clone());
+        self.indexer.set_parse_timeout(config.parse_timeout);
+        self.indexer
+            .set_route_patterns(config.route_patterns.clone());
+        self.indexer
+            .set_type_argument_references(!config.skip_type_arguments);
+    }
+
+    fn parse(
+        &mut self,
+        source: &[u8],
+        file_path: &Path,
+    ) -> Result<FrontendOutput, Box<dyn std::error::Error>> {
+        let source = std::str::from_utf8(source)?;
+        let file_path = file_path.to_path_buf();
+        let mut output = FrontendOutput::default();
+
+        // Every block of the document shares its parse timeout
+        self.indexer.start_file();
+        for block in code_blocks(source) {
+            let Some(language) = block.language else {
+                continue;
+            };
+            // A block that fails to parse does not fail the whole document
+            let mut symbols = match self
+                .indexer
+                .extract_symbols(&block.code, language, &file_path)
+            {
+                Ok(symbols) => symbols,
+                Err(e) => {
+                    tracing::debug!(
+                        "Skipping {} block at {:?}:{}: {}",
+                        language.as_str(),
+                        file_path,
+                        block.fence_line,
+                        e
+                    );
+                    continue;
+                }
+            };
+            let mut references = self
+                .indexer
+                .extract_references(&block.code, language, &file_path)
+                .unwrap_or_default();
+
+            // Code starts on the line after the fence
+            let shift = |location: &mut Location| {
+                location.start_line += block.fence_line;
+                location.end_line += block.fence_line;
+                location.start_column += block.indent as u32;
+                location.end_column += block.indent as u32;
+            };
+            for symbol in &mut symbols {
+                shift(&mut symbol.location);
+                symbol.id = SymbolId::new(
+                    &file_path,
+                    symbol.location.start_line,
+                    symbol.location.start_column,
+                );
+                symbol
+                    .tags
+                    .insert(FROM_DOCS_TAG.to_string(), "true".to_string());
+                symbol
+                    .tags
+                    .insert(DOC_BLOCK_TAG.to_string(), block.fence_line.to_string());
+                symbol
+                    .tags
+                    .insert(DOC_LANGUAGE_TAG.to_string(), language.as_str().to_string());
+            }
+            for reference in &mut references {
+                shift(&mut reference.location);
+            }
+            output.symbols.extend(symbols);
+            output.references.extend(references);
+        }
+        self.indexer.finish_file();
+        Ok(output)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_code_blocks() {
+        let source = r#"# Users
+
+```go
+func GetUser(id string) *User
+```
+
+1. Install:
+   ~~~bash
+   go get example.com/users
+   ~~~
+2. Call it:
+   ```{.python}
+   users.get_user("ann")
+   ```
+
+```
+plain
+```
+"#;
+        let blocks = code_blocks(source);
+        let found: Vec<(Option<Language>, u32, usize, &str)> = blocks
+            .iter()
+            .map(|b| (b.language, b.fence_line, b.indent, b.code.as_str()))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (Some(Language::Go), 3, 0, "func GetUser(id string) *User\n"),
+                (None, 8, 3, "go get example.com/users\n"),
+                (Some(Language::Python), 12, 3, "users.get_user(\"ann\")\n"),
+                (None, 16, 0, "plain\n"),
+            ]
+        );
+        assert_eq!(
+            block_language("golang title=\"main.go\""),
+            Some(Language::Go)
+        );
+        assert_eq!(block_language("text"), None);
+    }
+}
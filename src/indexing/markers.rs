@@ -0,0 +1,160 @@
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+
+/// Tokens that open a line or trailing comment in the indexed languages
+const COMMENT_STARTS: &[&str] = &["//", "/*", "#", "--", "<!--"];
+
+/// The kind of a debt marker left in a comment
+#[derive(
+    Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize, JsonSchema,
+)]
+#[serde(rename_all = "UPPERCASE")]
+pub enum MarkerKind {
+    Todo,
+    Fixme,
+    Hack,
+    Xxx,
+}
+
+impl MarkerKind {
+    const ALL: [MarkerKind; 4] = [Self::Todo, Self::Fixme, Self::Hack, Self::Xxx];
+
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Todo => "TODO",
+            Self::Fixme => "FIXME",
+            Self::Hack => "HACK",
+            Self::Xxx => "XXX",
+        }
+    }
+}
+
+/// A `TODO`, `FIXME`, `HACK` or `XXX` marker in a comment
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Marker {
+    pub kind: MarkerKind,
+    /// The name in `TODO(alice):`, if any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub owner: Option<String>,
+    /// The rest of the comment line: `retry on timeout`
+    pub text: String,
+    pub line: u32,
+    pub column: u32,
+}
+
+/// Find the debt markers in the comments of a source file of any language,
+/// in source order. A marker counts when it is written in capitals as a
+/// whole word after a comment token on its line, or on a ` * ` continuation
+/// line of a block comment; at most one marker is read per line.
+pub fn find_markers(source: &str) -> Vec<Marker> {
+    source
+        .lines()
+        .enumerate()
+        .filter_map(|(row, line)| {
+            let comment = comment_start(line)?;
+            let mut marker = marker_in(&line[comment..])?;
+            marker.line = row as u32 + 1;
+            marker.column += comment as u32;
+            Some(marker)
+        })
+        .collect()
+}
+
+/// Byte offset where the comment on `line` starts
+fn comment_start(line: &str) -> Option<usize> {
+    let trimmed = line.trim_start();
+    if trimmed.starts_with('*') && !trimmed.starts_with("*/") {
+        return Some(line.len() - trimmed.len());
+    }
+    COMMENT_STARTS
+        .iter()
+        .filter_map(|start| line.find(start))
+        .min()
+}
+
+fn marker_in(comment: &str) -> Option<Marker> {
+    for (start, _) in comment.char_indices() {
+        let rest = &comment[start..];
+        let Some(kind) = MarkerKind::ALL
+            .into_iter()
+            .find(|kind| rest.starts_with(kind.as_str()))
+        else {
+            continue;
+        };
+        let preceded_by_word = comment[..start]
+            .chars()
+            .next_back()
+            .is_some_and(|c| c.is_alphanumeric() || c == '_');
+        let after = &rest[kind.as_str().len()..];
+        let followed_by_word = after
+            .chars()
+            .next()
+            .is_some_and(|c| c.is_alphanumeric() || c == '_');
+        if preceded_by_word || followed_by_word {
+            continue;
+        }
+
+        let (owner, after) = match after.strip_prefix('(').and_then(|s| s.split_once(')')) {
+            Some((owner, after)) => (Some(owner.trim().to_string()), after),
+            None => (None, after),
+        };
+        let text = after
+            .trim_start_matches([':', '-', ' ', '\t'])
+            .trim_end_matches("*/")
+            .trim_end_matches("-->")
+            .trim();
+        return Some(Marker {
+            kind,
+            owner: owner.filter(|owner| !owner.is_empty()),
+            text: text.to_string(),
+            line: 0,
+            column: start as u32,
+        });
+    }
+    None
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_markers() {
+        let source = r#"package users
+
+// TODO(alice): retry on timeout
+func GetUser(id string) *User {
+    todo := "TODO: not a comment"
+    return cache[id] // FIXME - cache is never invalidated
+}
+
+/*
+ * HACK: works around the driver bug
+ */
+# XXX
+// TODOS and NOTTODO are words, not markers
+"#;
+        let markers = find_markers(source);
+        let found: Vec<(MarkerKind, Option<&str>, &str, u32)> = markers
+            .iter()
+            .map(|marker| {
+                (
+                    marker.kind,
+                    marker.owner.as_deref(),
+                    marker.text.as_str(),
+                    marker.line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (MarkerKind::Todo, Some("alice"), "retry on timeout", 3),
+                (MarkerKind::Fixme, None, "cache is never invalidated", 6),
+                (MarkerKind::Hack, None, "works around the driver bug", 10),
+                (MarkerKind::Xxx, None, "", 12),
+            ]
+        );
+        assert_eq!(markers[1].column, 24);
+    }
+}
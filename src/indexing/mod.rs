@@ -1,5 +1,60 @@
+pub mod anonymous_types;
+pub mod api_diff;
+pub mod attributes;
+pub mod body_diff;
+pub mod build_constraints;
+pub mod call_graph;
+pub mod centrality;
+pub mod churn;
+pub mod construction;
+pub mod context_propagation;
+pub mod declaration;
+pub mod duplicates;
+pub mod error_sentinels;
+pub mod frontend;
+pub mod go_enums;
+pub mod go_init;
+pub mod goroutine_leaks;
+pub mod implementations;
 pub mod indexer;
 pub mod indexing_pipeline;
+pub mod interface_contract;
+pub mod json_schema;
+pub mod kind_filter;
+pub mod language_priority;
+pub mod layering;
+pub mod lua;
+pub mod magic_literals;
+pub mod markdown;
+pub mod markers;
+pub mod name_filter;
+pub mod naming_rules;
+pub mod package_usage;
+pub mod panics;
+pub mod receiver_mutation;
+pub mod receiver_types;
+pub mod return_counts;
+pub mod routes;
+pub mod scala;
+pub mod sections;
+pub mod settings;
+pub mod shadowing;
+pub mod side_effects;
+pub mod signature;
+pub mod signature_compat;
+pub mod sql_queries;
+pub mod symbol_analysis;
+pub mod tags;
+pub mod test_detection;
+pub mod type_assertions;
+pub mod type_closure;
+pub mod type_forms;
+pub mod type_members;
+pub mod type_references;
+pub mod type_usage;
+pub mod unchecked_errors;
+pub mod var_types;
+pub mod vendor;
 
 pub use indexer::*;
 pub use indexing_pipeline::*;
@@ -0,0 +1,126 @@
+use crate::indexing::settings::setting_var;
+use regex::Regex;
+
+/// Drops noise symbols by name before they are stored, configured through
+/// ROBERTO_MIN_NAME_LENGTH (shorter names are skipped), ROBERTO_EXCLUDE_NAMES
+/// (a regex; matching names are skipped) and ROBERTO_ALLOW_NAMES (a comma
+/// separated list of names that are always kept).
+#[derive(Debug, Clone, Default)]
+pub struct NameFilter {
+    min_length: usize,
+    exclude: Option<Regex>,
+    allow: Vec<String>,
+}
+
+impl NameFilter {
+    /// Filter configured through the environment; an invalid minimum length or
+    /// exclude pattern is logged and that setting ignored
+    pub fn from_env() -> Self {
+        let min_length = match setting_var("ROBERTO_MIN_NAME_LENGTH") {
+            Ok(value) => value.trim().parse().unwrap_or_else(|_| {
+                tracing::warn!("Ignoring invalid ROBERTO_MIN_NAME_LENGTH: {}", value);
+                0
+            }),
+            Err(_) => 0,
+        };
+        let exclude = setting_var("ROBERTO_EXCLUDE_NAMES").ok();
+        let allow = setting_var("ROBERTO_ALLOW_NAMES").unwrap_or_default();
+
+        Self::new(min_length, exclude.as_deref(), &allow).unwrap_or_else(|e| {
+            tracing::warn!("Ignoring ROBERTO_EXCLUDE_NAMES: {}", e);
+            Self::new(min_length, None, &allow).unwrap_or_default()
+        })
+    }
+
+    pub fn new(min_length: usize, exclude: Option<&str>, allow: &str) -> Result<Self, String> {
+        let exclude = match exclude.map(str::trim).filter(|pattern| !pattern.is_empty()) {
+            Some(pattern) => Some(
+                Regex::new(pattern).map_err(|e| format!("invalid pattern '{}': {}", pattern, e))?,
+            ),
+            None => None,
+        };
+
+        let mut allow: Vec<String> = allow
+            .split(',')
+            .map(|name| name.trim().to_string())
+            .filter(|name| !name.is_empty())
+            .collect();
+        allow.sort();
+        allow.dedup();
+
+        Ok(Self {
+            min_length,
+            exclude,
+            allow,
+        })
+    }
+
+    /// Whether a symbol called `name` should be stored
+    pub fn allows(&self, name: &str) -> bool {
+        if self.allow.iter().any(|allowed| allowed == name) {
+            return true;
+        }
+        if name.chars().count() < self.min_length {
+            return false;
+        }
+        !self
+            .exclude
+            .as_ref()
+            .is_some_and(|pattern| pattern.is_match(name))
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.min_length == 0 && self.exclude.is_none()
+    }
+
+    /// Canonical form of the configuration, persisted with the cache so a
+    /// change forces a rebuild. Empty when every name is indexed.
+    pub fn fingerprint(&self) -> String {
+        if self.is_empty() {
+            return String::new();
+        }
+        format!(
+            "min={},exclude={},allow={}",
+            self.min_length,
+            self.exclude.as_ref().map_or("", |pattern| pattern.as_str()),
+            self.allow.join("|")
+        )
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_min_length_and_exclude() {
+        let filter = NameFilter::new(3, Some("^_"), "").unwrap();
+        assert!(filter.allows("Parse"));
+        assert!(!filter.allows("fn"));
+        assert!(!filter.allows("_internal"));
+        // Length counts characters, not bytes
+        assert!(filter.allows("ñam"));
+    }
+
+    #[test]
+    fn test_allowlist_overrides() {
+        let filter = NameFilter::new(3, Some("^_"), "Do, id,_init").unwrap();
+        assert!(filter.allows("Do"));
+        assert!(filter.allows("id"));
+        assert!(filter.allows("_init"));
+        assert!(!filter.allows("x"));
+    }
+
+    #[test]
+    fn test_default_and_invalid() {
+        let filter = NameFilter::default();
+        assert!(filter.is_empty());
+        assert!(filter.allows("x"));
+        assert_eq!(filter.fingerprint(), "");
+
+        assert!(NameFilter::new(0, Some("(unclosed"), "").is_err());
+
+        // The allowlist alone filters nothing and does not invalidate caches
+        assert!(NameFilter::new(0, None, "Do").unwrap().is_empty());
+    }
+}
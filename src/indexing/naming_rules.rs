@@ -0,0 +1,210 @@
+use crate::models::SymbolType;
+use regex::Regex;
+
+/// Built-in rules that only run when enabled by name
+pub const OPTIONAL_RULES: &[&str] = &["interface-er-suffix"];
+
+/// Which symbols of the rule's kinds a naming rule checks
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum NameScope {
+    All,
+    Exported,
+    Unexported,
+    /// Functions whose first result is a type declared in their package
+    Constructor,
+}
+
+impl NameScope {
+    pub const NAMES: &'static [&'static str] = &["all", "exported", "unexported", "constructor"];
+
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_lowercase().as_str() {
+            "all" => Some(NameScope::All),
+            "exported" => Some(NameScope::Exported),
+            "unexported" => Some(NameScope::Unexported),
+            "constructor" => Some(NameScope::Constructor),
+            _ => None,
+        }
+    }
+
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            NameScope::All => "all",
+            NameScope::Exported => "exported",
+            NameScope::Unexported => "unexported",
+            NameScope::Constructor => "constructor",
+        }
+    }
+}
+
+/// A naming convention: names of symbols of `kinds` within `scope` must
+/// match `pattern` (or must not, with `must_match` off)
+#[derive(Debug, Clone)]
+pub struct NamingRule {
+    pub name: String,
+    pub kinds: Vec<SymbolType>,
+    pub scope: NameScope,
+    pattern: Regex,
+    pub must_match: bool,
+    pub message: String,
+}
+
+impl NamingRule {
+    pub fn new(
+        name: &str,
+        kinds: Vec<SymbolType>,
+        scope: NameScope,
+        pattern: &str,
+        must_match: bool,
+        message: &str,
+    ) -> Result<Self, String> {
+        let pattern =
+            Regex::new(pattern).map_err(|e| format!("invalid pattern '{}': {}", pattern, e))?;
+        Ok(Self {
+            name: name.to_string(),
+            kinds,
+            scope,
+            pattern,
+            must_match,
+            message: message.to_string(),
+        })
+    }
+
+    pub fn pattern(&self) -> &str {
+        self.pattern.as_str()
+    }
+
+    /// Whether a symbol of `kind` falls under this rule
+    pub fn applies_to(&self, kind: &SymbolType, exported: bool, constructor: bool) -> bool {
+        self.kinds.contains(kind)
+            && match self.scope {
+                NameScope::All => true,
+                NameScope::Exported => exported,
+                NameScope::Unexported => !exported,
+                NameScope::Constructor => constructor,
+            }
+    }
+
+    pub fn is_violated_by(&self, name: &str) -> bool {
+        self.pattern.is_match(name) != self.must_match
+    }
+}
+
+/// Go conventions from Effective Go and the Go code review comments. Rules
+/// named in OPTIONAL_RULES are included only when listed in `enable`.
+pub fn default_go_rules(enable: &[String]) -> Vec<NamingRule> {
+    let rules = [
+        (
+            "mixed-caps",
+            vec![
+                SymbolType::Function,
+                SymbolType::Method,
+                SymbolType::Struct,
+                SymbolType::Interface,
+                SymbolType::Class,
+                SymbolType::Enum,
+                SymbolType::Constant,
+                SymbolType::Variable,
+            ],
+            NameScope::All,
+            r"^([A-Za-z][A-Za-z0-9]*|_)$",
+            "Go names use MixedCaps, not underscores",
+        ),
+        (
+            "constructor-prefix",
+            vec![SymbolType::Function],
+            NameScope::Constructor,
+            r"^[Nn]ew",
+            "functions returning a type of their package are named New<Type> (new<Type> when unexported)",
+        ),
+        (
+            "unexported-type-case",
+            vec![
+                SymbolType::Struct,
+                SymbolType::Interface,
+                SymbolType::Class,
+                SymbolType::Enum,
+            ],
+            NameScope::Unexported,
+            r"^[a-z]([a-z0-9].*)?$",
+            "unexported type name starts like an exported initialism; lowercase the whole initialism (httpClient, not hTTPClient)",
+        ),
+        (
+            "interface-er-suffix",
+            vec![SymbolType::Interface],
+            NameScope::All,
+            r"er$",
+            "interfaces are named for their method plus -er (Reader, Closer)",
+        ),
+    ];
+
+    rules
+        .into_iter()
+        .filter(|(name, ..)| !OPTIONAL_RULES.contains(name) || enable.iter().any(|e| e == name))
+        .map(|(name, kinds, scope, pattern, message)| {
+            NamingRule::new(name, kinds, scope, pattern, true, message)
+                .expect("built-in naming rule patterns are valid")
+        })
+        .collect()
+}
+
+/// Go exports identifiers that start with an upper-case letter
+pub fn is_go_exported(name: &str) -> bool {
+    name.chars().next().is_some_and(|c| c.is_uppercase())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn rule<'a>(rules: &'a [NamingRule], name: &str) -> &'a NamingRule {
+        rules.iter().find(|rule| rule.name == name).unwrap()
+    }
+
+    #[test]
+    fn test_default_go_rules() {
+        let rules = default_go_rules(&[]);
+        assert!(!rules.iter().any(|rule| rule.name == "interface-er-suffix"));
+
+        let mixed_caps = rule(&rules, "mixed-caps");
+        assert!(!mixed_caps.is_violated_by("CreateUser"));
+        assert!(!mixed_caps.is_violated_by("_"));
+        assert!(mixed_caps.is_violated_by("MAX_CONNECTIONS"));
+        assert!(mixed_caps.is_violated_by("create_user"));
+        assert!(!mixed_caps.applies_to(&SymbolType::Test, true, false));
+
+        let constructor = rule(&rules, "constructor-prefix");
+        assert!(constructor.applies_to(&SymbolType::Function, true, true));
+        assert!(!constructor.applies_to(&SymbolType::Function, true, false));
+        assert!(!constructor.is_violated_by("NewUserService"));
+        assert!(!constructor.is_violated_by("newMemoryCache"));
+        assert!(constructor.is_violated_by("UserFromJSON"));
+
+        let type_case = rule(&rules, "unexported-type-case");
+        assert!(!type_case.applies_to(&SymbolType::Struct, true, false));
+        assert!(!type_case.is_violated_by("httpClient"));
+        assert!(!type_case.is_violated_by("t"));
+        assert!(type_case.is_violated_by("hTTPClient"));
+    }
+
+    #[test]
+    fn test_optional_and_custom_rules() {
+        let rules = default_go_rules(&["interface-er-suffix".to_string()]);
+        let er = rule(&rules, "interface-er-suffix");
+        assert!(!er.is_violated_by("Logger"));
+        assert!(er.is_violated_by("Cache"));
+
+        let no_impl = NamingRule::new(
+            "no-impl-suffix",
+            vec![SymbolType::Struct],
+            NameScope::Exported,
+            "Impl$",
+            false,
+            "drop the Impl suffix",
+        )
+        .unwrap();
+        assert!(no_impl.is_violated_by("UserServiceImpl"));
+        assert!(!no_impl.is_violated_by("UserService"));
+        assert!(NamingRule::new("bad", vec![], NameScope::All, "(", true, "").is_err());
+    }
+}
@@ -0,0 +1,302 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// A Go import: `import j "encoding/json"`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct GoImport {
+    pub path: String,
+    /// The name the file refers to the package by: the alias, or the last
+    /// path element that is not a major version (`yaml` for
+    /// `gopkg.in/yaml.v3`, `chi` for `github.com/go-chi/chi/v5`)
+    pub name: String,
+    pub line: u32,
+}
+
+/// The uses of one imported package inside a function or method
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PackageUse {
+    /// The function or method; methods as `Type.Method`
+    pub function: String,
+    /// First line of the function
+    pub line: u32,
+    pub import: GoImport,
+    /// Package members used, in order of first use: `Marshal`, `Decoder`
+    pub identifiers: Vec<String>,
+    /// Lines using the package
+    pub lines: Vec<u32>,
+}
+
+/// Whether `path` is matched by `pattern`: the same import path, or any
+/// package under `prefix` for `prefix/...`
+pub fn import_matches(pattern: &str, path: &str) -> bool {
+    match pattern.strip_suffix("/...") {
+        Some(prefix) => {
+            path == prefix
+                || path
+                    .strip_prefix(prefix)
+                    .is_some_and(|rest| rest.starts_with('/'))
+        }
+        None => pattern == path,
+    }
+}
+
+/// Parse a Go file and find its imports matching `pattern` and the
+/// functions and methods whose bodies use them. Uses are qualified
+/// identifiers (`json.Marshal`) and qualified types (`json.Decoder`);
+/// dot and blank imports, and locals shadowing the package name, are not
+/// told apart.
+pub fn find_package_uses(
+    source: &str,
+    pattern: &str,
+) -> Result<(Vec<GoImport>, Vec<PackageUse>), Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+
+    let imports: Vec<GoImport> = go_imports(root, source)
+        .into_iter()
+        .filter(|import| import_matches(pattern, &import.path))
+        .collect();
+    if imports.is_empty() {
+        return Ok((imports, Vec::new()));
+    }
+
+    let mut uses = Vec::new();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if !matches!(
+            declaration.kind(),
+            "function_declaration" | "method_declaration"
+        ) {
+            continue;
+        }
+        let Some(function) = function_name(declaration, source) else {
+            continue;
+        };
+        for import in &imports {
+            let mut identifiers: Vec<String> = Vec::new();
+            let mut lines: Vec<u32> = Vec::new();
+            collect_uses(
+                declaration,
+                source,
+                &import.name,
+                &mut identifiers,
+                &mut lines,
+            );
+            if identifiers.is_empty() {
+                continue;
+            }
+            uses.push(PackageUse {
+                function: function.clone(),
+                line: declaration.start_position().row as u32 + 1,
+                import: import.clone(),
+                identifiers,
+                lines,
+            });
+        }
+    }
+    Ok((imports, uses))
+}
+
+/// The file's imports other than dot and blank ones
+pub fn go_imports(root: Node, source: &str) -> Vec<GoImport> {
+    let mut specs = Vec::new();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if declaration.kind() == "import_declaration" {
+            collect_import_specs(declaration, &mut specs);
+        }
+    }
+
+    specs
+        .into_iter()
+        .filter_map(|spec| {
+            let path = text(spec.child_by_field_name("path")?, source)?
+                .trim_matches(|c| c == '"' || c == '`')
+                .to_string();
+            let name = match spec.child_by_field_name("name") {
+                Some(alias) if alias.kind() == "package_identifier" => text(alias, source)?,
+                Some(_) => return None,
+                None => package_name(&path).to_string(),
+            };
+            Some(GoImport {
+                path,
+                name,
+                line: spec.start_position().row as u32 + 1,
+            })
+        })
+        .collect()
+}
+
+fn collect_import_specs<'t>(node: Node<'t>, specs: &mut Vec<Node<'t>>) {
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        match child.kind() {
+            "import_spec" => specs.push(child),
+            "import_spec_list" => collect_import_specs(child, specs),
+            _ => {}
+        }
+    }
+}
+
+/// The name a package is conventionally imported by
+fn package_name(path: &str) -> &str {
+    let mut elements = path.rsplit('/');
+    let last = elements.next().unwrap_or(path);
+    let is_major_version = |element: &str| {
+        element
+            .strip_prefix('v')
+            .is_some_and(|n| !n.is_empty() && n.chars().all(|c| c.is_ascii_digit()))
+    };
+    let name = match elements.next() {
+        Some(parent) if is_major_version(last) => parent,
+        _ => last,
+    };
+    // `gopkg.in/yaml.v3` is package `yaml`
+    name.split('.').next().unwrap_or(name)
+}
+
+fn function_name(declaration: Node, source: &str) -> Option<String> {
+    let name = text(declaration.child_by_field_name("name")?, source)?;
+    if declaration.kind() != "method_declaration" {
+        return Some(name);
+    }
+    let receiver = text(declaration.child_by_field_name("receiver")?, source)?;
+    let receiver = receiver.trim_matches(|c| c == '(' || c == ')');
+    let type_name = receiver.rsplit(' ').next().unwrap_or(receiver);
+    let type_name = type_name.trim_start_matches('*');
+    let type_name = type_name.split('[').next().unwrap_or(type_name);
+    Some(format!("{}.{}", type_name, name))
+}
+
+fn collect_uses(
+    node: Node,
+    source: &str,
+    package: &str,
+    identifiers: &mut Vec<String>,
+    lines: &mut Vec<u32>,
+) {
+    let qualified = match node.kind() {
+        "selector_expression" => node
+            .child_by_field_name("operand")
+            .filter(|operand| operand.kind() == "identifier")
+            .zip(node.child_by_field_name("field")),
+        "qualified_type" => node
+            .child_by_field_name("package")
+            .zip(node.child_by_field_name("name")),
+        _ => None,
+    };
+    if let Some((qualifier, member)) = qualified {
+        if text(qualifier, source).as_deref() == Some(package) {
+            if let Some(member) = text(member, source) {
+                if !identifiers.contains(&member) {
+                    identifiers.push(member);
+                }
+                let line = node.start_position().row as u32 + 1;
+                if lines.last() != Some(&line) {
+                    lines.push(line);
+                }
+            }
+            return;
+        }
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_uses(child, source, package, identifiers, lines);
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes()).ok().map(str::to_string)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_import_matches() {
+        assert!(import_matches("encoding/json", "encoding/json"));
+        assert!(!import_matches("encoding", "encoding/json"));
+        assert!(import_matches("encoding/...", "encoding/json"));
+        assert!(import_matches("encoding/...", "encoding"));
+        assert!(!import_matches("encoding/...", "encodings/json"));
+    }
+
+    #[test]
+    fn test_package_name() {
+        assert_eq!(package_name("encoding/json"), "json");
+        assert_eq!(package_name("github.com/go-chi/chi/v5"), "chi");
+        assert_eq!(package_name("gopkg.in/yaml.v3"), "yaml");
+        assert_eq!(package_name("sync"), "sync");
+    }
+
+    #[test]
+    fn test_find_package_uses() {
+        let source = r#"package users
+
+import (
+    "encoding/json"
+    ex "os/exec"
+    "sync"
+)
+
+type Store struct {
+    mu sync.Mutex
+}
+
+func (u *User) ToJSON() ([]byte, error) {
+    return json.Marshal(u)
+}
+
+func UserFromJSON(data []byte) (*User, error) {
+    var u User
+    dec := json.NewDecoder(bytes.NewReader(data))
+    var _ *json.Decoder = dec
+    err := json.Unmarshal(data, &u)
+    return &u, err
+}
+
+func Run() error {
+    return ex.Command("ls").Run()
+}
+"#;
+        let (imports, uses) = find_package_uses(source, "encoding/json").unwrap();
+        assert_eq!(imports.len(), 1);
+        assert_eq!(imports[0].line, 4);
+        let found: Vec<(&str, Vec<&str>, &[u32])> = uses
+            .iter()
+            .map(|u| {
+                (
+                    u.function.as_str(),
+                    u.identifiers.iter().map(String::as_str).collect(),
+                    u.lines.as_slice(),
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                ("User.ToJSON", vec!["Marshal"], &[15][..]),
+                (
+                    "UserFromJSON",
+                    vec!["NewDecoder", "Decoder", "Unmarshal"],
+                    &[20, 21, 22][..]
+                ),
+            ]
+        );
+
+        let (_, exec) = find_package_uses(source, "os/exec").unwrap();
+        assert_eq!(exec.len(), 1);
+        assert_eq!(exec[0].function, "Run");
+        assert_eq!(exec[0].import.name, "ex");
+
+        // Struct fields are not function bodies
+        let (imports, uses) = find_package_uses(source, "sync").unwrap();
+        assert_eq!(imports.len(), 1);
+        assert!(uses.is_empty());
+    }
+}
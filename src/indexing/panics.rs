@@ -0,0 +1,209 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Literal kinds whose value a panic message can be read from
+const LITERAL_KINDS: &[&str] = &[
+    "interpreted_string_literal",
+    "raw_string_literal",
+    "int_literal",
+    "float_literal",
+    "imaginary_literal",
+    "rune_literal",
+];
+
+/// A call to one of Go's panic builtins
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum PanicSiteKind {
+    /// `panic(v)`
+    Panic,
+    /// `recover()`
+    Recover,
+}
+
+/// A direct `panic(...)` or `recover()` call in Go code
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PanicSite {
+    pub kind: PanicSiteKind,
+    /// The enclosing function or method; `None` at package level
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    /// The panic value as written: `fmt.Sprintf("bad id %q", id)`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub argument: Option<String>,
+    /// The panic value when it is a literal, without its quotes:
+    /// `unreachable` for `panic("unreachable")`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub literal: Option<String>,
+    /// `recover()` inside a deferred closure, the only place it stops a
+    /// panic. A named function run with `defer` can recover too; that
+    /// depends on its callers and is not detected.
+    pub deferred: bool,
+    pub line: u32,
+    pub column: u32,
+}
+
+/// Find the `panic(...)` and `recover()` calls of a Go source file, in
+/// source order. Panics raised by the runtime, such as nil dereferences or
+/// failed type assertions, are not calls and are not reported.
+pub fn find_panic_sites(source: &str) -> Result<Vec<PanicSite>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut sites = Vec::new();
+    visit(tree.root_node(), source, None, &mut sites);
+    Ok(sites)
+}
+
+fn visit(node: Node, source: &str, function: Option<&str>, sites: &mut Vec<PanicSite>) {
+    let named_function = match node.kind() {
+        "function_declaration" | "method_declaration" => node
+            .child_by_field_name("name")
+            .and_then(|name| text(name, source)),
+        _ => None,
+    };
+    let function = named_function.as_deref().or(function);
+
+    if let Some(site) = panic_site(node, source, function) {
+        sites.push(site);
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, function, sites);
+    }
+}
+
+fn panic_site(node: Node, source: &str, function: Option<&str>) -> Option<PanicSite> {
+    if node.kind() != "call_expression" {
+        return None;
+    }
+    let callee = node.child_by_field_name("function")?;
+    let arguments = node.child_by_field_name("arguments")?;
+    let kind = match (callee.kind(), text(callee, source)?.as_str()) {
+        ("identifier", "panic") => PanicSiteKind::Panic,
+        ("identifier", "recover") => PanicSiteKind::Recover,
+        _ => return None,
+    };
+
+    let value = arguments.named_child(0);
+    let start = node.start_position();
+    Some(PanicSite {
+        kind,
+        function: function.map(str::to_string),
+        argument: value.and_then(|value| text(value, source)),
+        literal: value.and_then(|value| literal(value, source)),
+        deferred: kind == PanicSiteKind::Recover && in_deferred_closure(node),
+        line: start.row as u32 + 1,
+        column: start.column as u32,
+    })
+}
+
+/// The value of a literal, with string quotes removed
+fn literal(node: Node, source: &str) -> Option<String> {
+    if !LITERAL_KINDS.contains(&node.kind()) {
+        return None;
+    }
+    let value = node.utf8_text(source.as_bytes()).ok()?;
+    let quoted = matches!(
+        node.kind(),
+        "interpreted_string_literal" | "raw_string_literal"
+    );
+    if quoted && value.len() >= 2 {
+        return Some(value[1..value.len() - 1].to_string());
+    }
+    Some(value.to_string())
+}
+
+/// Whether the nearest enclosing function is a closure run by `defer`:
+/// `defer func() { recover() }()`
+fn in_deferred_closure(node: Node) -> bool {
+    let mut current = node.parent();
+    while let Some(parent) = current {
+        match parent.kind() {
+            "func_literal" => {
+                return parent
+                    .parent()
+                    .filter(|call| call.kind() == "call_expression")
+                    .and_then(|call| call.parent())
+                    .is_some_and(|statement| statement.kind() == "defer_statement");
+            }
+            "function_declaration" | "method_declaration" => return false,
+            _ => current = parent.parent(),
+        }
+    }
+    false
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_panic_sites() {
+        let source = r#"package users
+
+func MustGetUser(id string) *User {
+    user, err := GetUser(id)
+    if err != nil {
+        panic(fmt.Sprintf("user %q: %v", id, err))
+    }
+    if user == nil {
+        panic("unreachable")
+    }
+    return user
+}
+
+func (s *UserService) Handle(id string) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("recovered: %v", r)
+        }
+    }()
+    defer recover()
+    panic(42)
+}
+"#;
+        let sites = find_panic_sites(source).unwrap();
+        let found: Vec<(PanicSiteKind, &str, Option<&str>, bool, u32)> = sites
+            .iter()
+            .map(|site| {
+                (
+                    site.kind,
+                    site.function.as_deref().unwrap_or_default(),
+                    site.literal.as_deref(),
+                    site.deferred,
+                    site.line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (PanicSiteKind::Panic, "MustGetUser", None, false, 6),
+                (
+                    PanicSiteKind::Panic,
+                    "MustGetUser",
+                    Some("unreachable"),
+                    false,
+                    9
+                ),
+                (PanicSiteKind::Recover, "Handle", None, true, 16),
+                (PanicSiteKind::Recover, "Handle", None, false, 20),
+                (PanicSiteKind::Panic, "Handle", Some("42"), false, 21),
+            ]
+        );
+        assert_eq!(
+            sites[0].argument.as_deref(),
+            Some(r#"fmt.Sprintf("user %q: %v", id, err)"#)
+        );
+    }
+}
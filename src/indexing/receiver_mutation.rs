@@ -0,0 +1,199 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// An assignment to a receiver field inside a Go method
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReceiverMutation {
+    pub method: String,
+    pub receiver: String,
+    pub receiver_type: String,
+    /// `true` for `func (s *T)`, where the assignment is visible to the caller
+    pub pointer_receiver: bool,
+    pub field: String,
+    pub line: u32,
+    pub column: u32,
+    /// The assignment as written, whitespace collapsed
+    pub statement: String,
+}
+
+impl ReceiverMutation {
+    /// Assignments through a value receiver change a copy and are lost on return
+    pub fn persists(&self) -> bool {
+        self.pointer_receiver
+    }
+}
+
+/// Find assignments to receiver fields (`s.count = 0`, `s.count++`, `s.n += 1`)
+/// in every method declared in a Go source file.
+///
+/// Only direct fields of the receiver are reported: writes through an index
+/// (`s.items[i] = x`) or a nested field (`s.cfg.Name = x`) may land in shared
+/// memory (slices, maps, pointers) even from a value receiver.
+pub fn find_receiver_mutations(
+    source: &str,
+) -> Result<Vec<ReceiverMutation>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut mutations = Vec::new();
+    let root = tree.root_node();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if declaration.kind() == "method_declaration" {
+            method_mutations(declaration, source, &mut mutations);
+        }
+    }
+    Ok(mutations)
+}
+
+fn method_mutations(method: Node, source: &str, mutations: &mut Vec<ReceiverMutation>) {
+    let Some(name) = method
+        .child_by_field_name("name")
+        .and_then(|node| text(node, source))
+    else {
+        return;
+    };
+    let Some(receiver) = method
+        .child_by_field_name("receiver")
+        .and_then(|list| list.named_child(0))
+        .filter(|param| param.kind() == "parameter_declaration")
+    else {
+        return;
+    };
+    // An unnamed receiver (`func (T) M()`) cannot be assigned through
+    let Some(receiver_name) = receiver
+        .child_by_field_name("name")
+        .and_then(|node| text(node, source))
+    else {
+        return;
+    };
+    let Some(receiver_type) = receiver.child_by_field_name("type") else {
+        return;
+    };
+    let pointer_receiver = receiver_type.kind() == "pointer_type";
+    let receiver_type = text(receiver_type, source)
+        .unwrap_or_default()
+        .trim_start_matches('*')
+        .to_string();
+
+    let Some(body) = method.child_by_field_name("body") else {
+        return;
+    };
+
+    let method = MethodContext {
+        name: &name,
+        receiver: &receiver_name,
+        receiver_type: &receiver_type,
+        pointer_receiver,
+    };
+    visit(body, source, &method, mutations);
+}
+
+struct MethodContext<'a> {
+    name: &'a str,
+    receiver: &'a str,
+    receiver_type: &'a str,
+    pointer_receiver: bool,
+}
+
+fn visit(node: Node, source: &str, method: &MethodContext, mutations: &mut Vec<ReceiverMutation>) {
+    let targets: Vec<Node> = match node.kind() {
+        "assignment_statement" => node
+            .child_by_field_name("left")
+            .map(|left| {
+                let mut cursor = left.walk();
+                left.named_children(&mut cursor).collect()
+            })
+            .unwrap_or_default(),
+        "inc_statement" | "dec_statement" => node.named_child(0).into_iter().collect(),
+        _ => Vec::new(),
+    };
+
+    for target in targets {
+        if let Some(field) = receiver_field(target, source, method.receiver) {
+            let start = node.start_position();
+            mutations.push(ReceiverMutation {
+                method: method.name.to_string(),
+                receiver: method.receiver.to_string(),
+                receiver_type: method.receiver_type.to_string(),
+                pointer_receiver: method.pointer_receiver,
+                field,
+                line: start.row as u32 + 1,
+                column: start.column as u32,
+                statement: text(node, source).unwrap_or_default(),
+            });
+        }
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, method, mutations);
+    }
+}
+
+/// `recv.field` as an assignment target, returning the field name
+fn receiver_field(target: Node, source: &str, receiver: &str) -> Option<String> {
+    if target.kind() != "selector_expression" {
+        return None;
+    }
+    let operand = target.child_by_field_name("operand")?;
+    if operand.kind() != "identifier" || text(operand, source)? != receiver {
+        return None;
+    }
+    text(target.child_by_field_name("field")?, source)
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_value_and_pointer_receivers() {
+        let source = r#"package model
+
+type Record struct {
+    UpdatedAt int64
+    Hits      int
+    Tags      []string
+    Meta      *Meta
+}
+
+func (r *Record) UpdateTimestamp(now int64) {
+    r.UpdatedAt = now
+}
+
+func (r Record) Touch(now int64) {
+    r.UpdatedAt = now
+    r.Hits++
+    r.Tags[0] = "seen"
+    r.Meta.Owner = "me"
+}
+
+func (Record) Kind() string { return "record" }
+"#;
+        let mutations = find_receiver_mutations(source).unwrap();
+        let summary: Vec<(&str, &str, bool, u32)> = mutations
+            .iter()
+            .map(|m| (m.method.as_str(), m.field.as_str(), m.persists(), m.line))
+            .collect();
+
+        assert_eq!(
+            summary,
+            vec![
+                ("UpdateTimestamp", "UpdatedAt", true, 11),
+                ("Touch", "UpdatedAt", false, 15),
+                ("Touch", "Hits", false, 16),
+            ]
+        );
+        assert_eq!(mutations[1].receiver_type, "Record");
+        assert_eq!(mutations[1].statement, "r.UpdatedAt = now");
+    }
+}
@@ -0,0 +1,649 @@
+use crate::indexing::package_usage::go_imports;
+use crate::indexing::symbol_analysis::find_definition;
+use crate::indexing::type_members::{base_type_name, TypeMembers};
+use crate::models::{Language, Location};
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use tree_sitter::{Node, Parser};
+
+/// What the analysis knows about a Go package's types
+#[derive(Debug, Clone, Default)]
+pub struct PackageTypes {
+    /// Types declared in the package by name, promoted members included
+    pub types: HashMap<String, TypeMembers>,
+    /// Result types of package-level functions and of methods keyed
+    /// `Type.Method`, as declared: `(*User, error)`
+    pub functions: HashMap<String, String>,
+}
+
+impl PackageTypes {
+    /// Declared type of a field of `type_name`
+    fn field_type(&self, type_name: &str, field: &str) -> Option<String> {
+        self.types
+            .get(&base_type_name(type_name))?
+            .fields
+            .iter()
+            .find(|f| f.name == field)?
+            .type_name
+            .clone()
+    }
+
+    /// First result type of a method of `type_name`: an indexed method, or
+    /// one declared by an interface
+    fn method_result(&self, type_name: &str, method: &str) -> Option<String> {
+        let base = base_type_name(type_name);
+        if let Some(results) = self.functions.get(&format!("{}.{}", base, method)) {
+            return first_result(results);
+        }
+        let signature = self
+            .types
+            .get(&base)?
+            .methods
+            .iter()
+            .find(|m| m.name == method)?
+            .signature
+            .clone()?;
+        first_result(results_after(&signature, method)?)
+    }
+
+    /// `struct`, `interface` or `type` for package types; `None` otherwise
+    pub fn kind(&self, type_name: &str) -> Option<&str> {
+        self.types
+            .get(&base_type_name(type_name))
+            .map(|t| t.kind.as_str())
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ResolutionStatus {
+    Resolved,
+    Unresolved,
+}
+
+/// A method call and the static type of its receiver
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct ReceiverCall {
+    /// The callee as written: `s.cache.Set`
+    pub call: String,
+    /// The receiver expression: `s.cache`
+    pub receiver: String,
+    pub method: String,
+    pub line: u32,
+    pub column: u32,
+    pub status: ResolutionStatus,
+    /// The receiver's declared type: `Cache`, `*User`, `sql.DB`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub static_type: Option<String>,
+    /// How the type was found, one step per line:
+    /// `s: *UserService (receiver)`, `s.cache: Cache (field of UserService)`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub trace: Vec<String>,
+    /// Why the type could not be resolved
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub reason: Option<String>,
+}
+
+/// A local name's type and where it came from
+#[derive(Debug, Clone)]
+struct Binding {
+    /// `None` when declared more than once with different types
+    type_name: Option<String>,
+    origin: String,
+    /// Steps that typed the value it was initialized with
+    trace: Vec<String>,
+}
+
+/// Parse a Go file and resolve the method calls of the function spanning
+/// `location`; see `resolve_receiver_calls`
+pub fn find_receiver_calls(
+    source: &str,
+    location: &Location,
+    package: &PackageTypes,
+) -> Result<Vec<ReceiverCall>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+    let definition = find_definition(root, location).ok_or("Definition not found")?;
+    Ok(resolve_receiver_calls(root, definition, source, package))
+}
+
+/// Resolve the static receiver type of every method call in a Go function
+/// or method, closures included. Receivers are traced through the method
+/// receiver, parameters, declared and inferred locals (`T{}`, `&T{}`,
+/// `new(T)`, calls to package functions and to methods of resolved types)
+/// and declared field types, promoted fields included. Scoping is by name
+/// for the whole function: a name declared twice with different types is
+/// unresolved. Calls qualified by an imported package are not method calls
+/// and are skipped; everything else that cannot be traced is reported
+/// `unresolved` with a reason instead of a guess.
+pub fn resolve_receiver_calls(
+    root: Node,
+    definition: Node,
+    source: &str,
+    package: &PackageTypes,
+) -> Vec<ReceiverCall> {
+    let imports: HashSet<String> = go_imports(root, source)
+        .into_iter()
+        .map(|import| import.name)
+        .collect();
+    let mut resolver = Resolver {
+        source,
+        package,
+        scope: HashMap::new(),
+    };
+    resolver.declare_signature(definition);
+    resolver.declare_locals(definition);
+
+    let mut calls = Vec::new();
+    let mut stack = vec![definition];
+    while let Some(node) = stack.pop() {
+        if let Some(call) = resolver.method_call(node, &imports) {
+            calls.push(call);
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.named_children(&mut cursor));
+    }
+    calls.sort_by_key(|call| (call.line, call.column));
+    calls
+}
+
+struct Resolver<'a> {
+    source: &'a str,
+    package: &'a PackageTypes,
+    scope: HashMap<String, Binding>,
+}
+
+impl<'a> Resolver<'a> {
+    fn bind(&mut self, name: &str, typed: Option<(String, Vec<String>)>, origin: &str) {
+        if name == "_" {
+            return;
+        }
+        let Some((type_name, trace)) = typed else {
+            // A name whose value cannot be typed hides any earlier binding
+            self.scope.insert(
+                name.to_string(),
+                Binding {
+                    type_name: None,
+                    origin: origin.to_string(),
+                    trace: Vec::new(),
+                },
+            );
+            return;
+        };
+        match self.scope.get_mut(name) {
+            Some(binding) if binding.type_name.as_deref() != Some(type_name.as_str()) => {
+                binding.type_name = None;
+                binding.origin = "declared with different types".to_string();
+            }
+            Some(_) => {}
+            None => {
+                self.scope.insert(
+                    name.to_string(),
+                    Binding {
+                        type_name: Some(type_name),
+                        origin: origin.to_string(),
+                        trace,
+                    },
+                );
+            }
+        }
+    }
+
+    /// The receiver and parameters of the function
+    fn declare_signature(&mut self, definition: Node) {
+        if let Some(receiver) = definition.child_by_field_name("receiver") {
+            self.declare_parameters(receiver, "receiver");
+        }
+        if let Some(parameters) = definition.child_by_field_name("parameters") {
+            self.declare_parameters(parameters, "parameter");
+        }
+    }
+
+    fn declare_parameters(&mut self, list: Node, origin: &str) {
+        let mut cursor = list.walk();
+        for parameter in list.named_children(&mut cursor) {
+            if parameter.kind() != "parameter_declaration" {
+                continue;
+            }
+            let type_name = parameter
+                .child_by_field_name("type")
+                .and_then(|t| self.text(t));
+            let mut names = parameter.walk();
+            for name in parameter.children_by_field_name("name", &mut names) {
+                if let Some(name) = self.text(name) {
+                    self.bind(&name, type_name.clone().map(|t| (t, Vec::new())), origin);
+                }
+            }
+        }
+    }
+
+    /// `var` declarations, short variable declarations and closure
+    /// parameters, in source order so inferred types can build on each other
+    fn declare_locals(&mut self, node: Node) {
+        match node.kind() {
+            "var_spec" => {
+                let declared = node.child_by_field_name("type").and_then(|t| self.text(t));
+                let names = self.field_nodes(node, "name");
+                let values = node
+                    .child_by_field_name("value")
+                    .map(|list| self.list(list))
+                    .unwrap_or_default();
+                self.declare_assigned(&names, &values, declared);
+            }
+            "short_var_declaration" => {
+                let names = node
+                    .child_by_field_name("left")
+                    .map(|list| self.list(list))
+                    .unwrap_or_default();
+                let values = node
+                    .child_by_field_name("right")
+                    .map(|list| self.list(list))
+                    .unwrap_or_default();
+                self.declare_assigned(&names, &values, None);
+            }
+            "func_literal" => {
+                if let Some(parameters) = node.child_by_field_name("parameters") {
+                    self.declare_parameters(parameters, "closure parameter");
+                }
+            }
+            // Element types of ranged collections are not tracked
+            "range_clause" => {
+                for name in node
+                    .child_by_field_name("left")
+                    .map(|list| self.list(list))
+                    .unwrap_or_default()
+                {
+                    if let Some(name) = self.text(name) {
+                        self.bind(&name, None, "range variable");
+                    }
+                }
+            }
+            "type_switch_statement" => {
+                for alias in self.field_nodes(node, "alias") {
+                    for name in self.list(alias) {
+                        if let Some(name) = self.text(name) {
+                            self.bind(&name, None, "type switch variable");
+                        }
+                    }
+                }
+            }
+            _ => {}
+        }
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            self.declare_locals(child);
+        }
+    }
+
+    fn declare_assigned(&mut self, names: &[Node], values: &[Node], declared: Option<String>) {
+        for (index, name) in names.iter().enumerate() {
+            let Some(name) = self.text(*name) else {
+                continue;
+            };
+            let typed = match (&declared, values.len() == names.len()) {
+                (Some(declared), _) => Some((declared.clone(), Vec::new())),
+                (None, true) => self.infer(values[index]).ok(),
+                // `u, err := load()` types the first name from the call's first result
+                (None, false) if index == 0 && values.len() == 1 => self.infer(values[0]).ok(),
+                (None, false) => None,
+            };
+            self.bind(&name, typed, "local");
+        }
+    }
+
+    fn method_call(&self, node: Node, imports: &HashSet<String>) -> Option<ReceiverCall> {
+        if node.kind() != "call_expression" {
+            return None;
+        }
+        let callee = node.child_by_field_name("function")?;
+        if callee.kind() != "selector_expression" {
+            return None;
+        }
+        let receiver = callee.child_by_field_name("operand")?;
+        let method = self.text(callee.child_by_field_name("field")?)?;
+        let receiver_text = self.text(receiver)?;
+        // `fmt.Println`: a package, not a receiver, unless a local hides it
+        if receiver.kind() == "identifier"
+            && imports.contains(&receiver_text)
+            && !self.scope.contains_key(&receiver_text)
+        {
+            return None;
+        }
+
+        let start = callee.start_position();
+        let mut call = ReceiverCall {
+            call: self.text(callee)?,
+            receiver: receiver_text,
+            method,
+            line: start.row as u32 + 1,
+            column: start.column as u32,
+            status: ResolutionStatus::Unresolved,
+            static_type: None,
+            trace: Vec::new(),
+            reason: None,
+        };
+        match self.infer(receiver) {
+            Ok((static_type, trace)) => {
+                call.status = ResolutionStatus::Resolved;
+                call.static_type = Some(static_type);
+                call.trace = trace;
+            }
+            Err(reason) => call.reason = Some(reason),
+        }
+        Some(call)
+    }
+
+    /// The static type of an expression and the steps that found it
+    fn infer(&self, node: Node) -> Result<(String, Vec<String>), String> {
+        let text = self.text(node).unwrap_or_default();
+        match node.kind() {
+            "identifier" => {
+                let binding = self
+                    .scope
+                    .get(&text)
+                    .ok_or_else(|| format!("'{}' is not a local, parameter or receiver", text))?;
+                let type_name = binding.type_name.clone().ok_or_else(|| {
+                    format!("type of '{}' is not known: {}", text, binding.origin)
+                })?;
+                let mut trace = binding.trace.clone();
+                trace.push(format!("{}: {} ({})", text, type_name, binding.origin));
+                Ok((type_name, trace))
+            }
+            "parenthesized_expression" => {
+                self.infer(node.named_child(0).ok_or("empty parentheses")?)
+            }
+            "selector_expression" => {
+                let operand = node.child_by_field_name("operand").ok_or("no operand")?;
+                let field = node
+                    .child_by_field_name("field")
+                    .and_then(|f| self.text(f))
+                    .ok_or("no field")?;
+                let (owner, mut trace) = self.infer(operand)?;
+                let owner_name = base_type_name(&owner);
+                let type_name = match self.package.kind(&owner) {
+                    None => return Err(format!("type {} is not declared in this package", owner)),
+                    Some("interface") => {
+                        return Err(format!("{} is an interface and has no fields", owner_name))
+                    }
+                    Some(_) => self
+                        .package
+                        .field_type(&owner, &field)
+                        .ok_or_else(|| format!("{} has no field {}", owner_name, field))?,
+                };
+                trace.push(format!("{}: {} (field of {})", text, type_name, owner_name));
+                Ok((type_name, trace))
+            }
+            "composite_literal" => {
+                let type_name = node
+                    .child_by_field_name("type")
+                    .and_then(|t| self.text(t))
+                    .ok_or("composite literal without a type")?;
+                Ok((
+                    type_name.clone(),
+                    vec![format!("{}: {} (literal)", text, type_name)],
+                ))
+            }
+            "unary_expression" => {
+                let operator = node
+                    .child_by_field_name("operator")
+                    .and_then(|op| self.text(op));
+                let operand = node.child_by_field_name("operand").ok_or("no operand")?;
+                let (type_name, trace) = self.infer(operand)?;
+                match operator.as_deref() {
+                    Some("&") => Ok((format!("*{}", type_name), trace)),
+                    Some("*") => Ok((type_name.trim_start_matches('*').to_string(), trace)),
+                    _ => Err(format!("cannot type '{}'", text)),
+                }
+            }
+            "call_expression" => self.infer_call(node, &text),
+            _ => Err(format!("cannot type '{}'", text)),
+        }
+    }
+
+    fn infer_call(&self, node: Node, text: &str) -> Result<(String, Vec<String>), String> {
+        let callee = node.child_by_field_name("function").ok_or("no callee")?;
+        let callee_text = self.text(callee).unwrap_or_default();
+        match callee.kind() {
+            "identifier" if callee_text == "new" => {
+                let argument = node
+                    .child_by_field_name("arguments")
+                    .and_then(|arguments| arguments.named_child(0))
+                    .and_then(|argument| self.text(argument))
+                    .ok_or("new without a type")?;
+                let type_name = format!("*{}", argument);
+                Ok((
+                    type_name.clone(),
+                    vec![format!("{}: {} (new)", text, type_name)],
+                ))
+            }
+            "identifier" => {
+                let type_name = self
+                    .package
+                    .functions
+                    .get(&callee_text)
+                    .and_then(|results| first_result(results))
+                    .ok_or_else(|| format!("result type of {} is not known", callee_text))?;
+                let step = format!("{}: {} (result of {})", text, type_name, callee_text);
+                Ok((type_name, vec![step]))
+            }
+            "selector_expression" => {
+                let operand = callee.child_by_field_name("operand").ok_or("no operand")?;
+                let method = callee
+                    .child_by_field_name("field")
+                    .and_then(|f| self.text(f))
+                    .ok_or("no method")?;
+                let (owner, mut trace) = self.infer(operand)?;
+                let type_name = self.package.method_result(&owner, &method).ok_or_else(|| {
+                    format!(
+                        "result type of {}.{} is not known",
+                        base_type_name(&owner),
+                        method
+                    )
+                })?;
+                trace.push(format!(
+                    "{}: {} (result of {}.{})",
+                    text,
+                    type_name,
+                    base_type_name(&owner),
+                    method
+                ));
+                Ok((type_name, trace))
+            }
+            _ => Err(format!("cannot type '{}'", text)),
+        }
+    }
+
+    fn field_nodes<'t>(&self, node: Node<'t>, field: &str) -> Vec<Node<'t>> {
+        let mut cursor = node.walk();
+        let nodes: Vec<Node<'t>> = node.children_by_field_name(field, &mut cursor).collect();
+        nodes
+    }
+
+    /// The expressions of an `expression_list`, or the node itself
+    fn list<'t>(&self, node: Node<'t>) -> Vec<Node<'t>> {
+        if node.kind() != "expression_list" {
+            return vec![node];
+        }
+        let mut cursor = node.walk();
+        let nodes: Vec<Node<'t>> = node.named_children(&mut cursor).collect();
+        nodes
+    }
+
+    fn text(&self, node: Node) -> Option<String> {
+        node.utf8_text(self.source.as_bytes())
+            .ok()
+            .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+}
+
+/// The results of a declared method, the text after its parameter list:
+/// `(Transaction, error)` in `BeginTransaction(ctx context.Context) (Transaction, error)`
+fn results_after<'s>(signature: &'s str, method: &str) -> Option<&'s str> {
+    let start = signature.find(&format!("{}(", method))? + method.len();
+    let mut depth = 0;
+    for (offset, c) in signature[start..].char_indices() {
+        match c {
+            '(' => depth += 1,
+            ')' => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some(signature[start + offset + 1..].trim());
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+/// The first type of a result list: `*User` for `(*User, error)` or for
+/// `(u *User, err error)`; `None` when nothing is returned
+pub fn first_result(results: &str) -> Option<String> {
+    let results = results.trim();
+    let first = match results.strip_prefix('(') {
+        Some(inner) => {
+            let inner = inner.strip_suffix(')').unwrap_or(inner);
+            let mut depth = 0;
+            let end = inner
+                .char_indices()
+                .find(|&(_, c)| {
+                    match c {
+                        '(' | '[' | '{' => depth += 1,
+                        ')' | ']' | '}' => depth -= 1,
+                        _ => {}
+                    }
+                    c == ',' && depth == 0
+                })
+                .map_or(inner.len(), |(index, _)| index);
+            let first = inner[..end].trim();
+            // Named results: `u *User`
+            match first.split_once(' ') {
+                Some((name, type_name))
+                    if !matches!(name, "chan" | "func" | "<-chan" | "map")
+                        && !name.contains(['[', '(', '*']) =>
+                {
+                    type_name.trim()
+                }
+                _ => first,
+            }
+        }
+        None => results,
+    };
+    (!first.is_empty()).then(|| first.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::indexing::type_members::extract_type_members;
+
+    #[test]
+    fn test_first_result() {
+        assert_eq!(first_result("(*User, error)").as_deref(), Some("*User"));
+        assert_eq!(
+            first_result("(u *User, err error)").as_deref(),
+            Some("*User")
+        );
+        assert_eq!(first_result("Transaction").as_deref(), Some("Transaction"));
+        assert_eq!(
+            first_result("(map[string]int, error)").as_deref(),
+            Some("map[string]int")
+        );
+        assert_eq!(first_result("(chan int)").as_deref(), Some("chan int"));
+        assert_eq!(first_result(""), None);
+        assert_eq!(
+            results_after(
+                "BeginTransaction(ctx context.Context) (Transaction, error)",
+                "BeginTransaction"
+            ),
+            Some("(Transaction, error)")
+        );
+    }
+
+    #[test]
+    fn test_resolve_receiver_calls() {
+        let source = r#"package users
+
+import "fmt"
+
+type Cache interface {
+    Set(key string, value interface{})
+}
+
+type Database interface {
+    Begin() (Tx, error)
+}
+
+type Tx interface {
+    Commit() error
+}
+
+type UserService struct {
+    db    Database
+    cache Cache
+}
+
+func (s *UserService) CreateUser(name string) (*User, error) {
+    user := NewUser(name)
+    user.Validate()
+    tx, err := s.db.Begin()
+    tx.Commit()
+    s.cache.Set(name, user)
+    fmt.Println(err)
+    s.metrics.Inc()
+    lookup().Close()
+    return user, nil
+}
+"#;
+        let mut parser = Parser::new();
+        parser
+            .set_language(&Language::Go.tree_sitter_language())
+            .unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        let root = tree.root_node();
+        let mut cursor = root.walk();
+        let definition = root
+            .named_children(&mut cursor)
+            .find(|node| node.kind() == "method_declaration")
+            .unwrap();
+
+        let package = PackageTypes {
+            types: extract_type_members(source, Language::Go)
+                .unwrap()
+                .into_iter()
+                .map(|t| (t.name.clone(), t))
+                .collect(),
+            functions: HashMap::from([("NewUser".to_string(), "*User".to_string())]),
+        };
+
+        let calls = resolve_receiver_calls(root, definition, source, &package);
+        let found: Vec<(&str, Option<&str>)> = calls
+            .iter()
+            .map(|c| (c.call.as_str(), c.static_type.as_deref()))
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                ("user.Validate", Some("*User")),
+                ("s.db.Begin", Some("Database")),
+                ("tx.Commit", Some("Tx")),
+                ("s.cache.Set", Some("Cache")),
+                ("s.metrics.Inc", None),
+                ("lookup().Close", None),
+            ]
+        );
+        assert_eq!(
+            calls[3].trace,
+            vec![
+                "s: *UserService (receiver)".to_string(),
+                "s.cache: Cache (field of UserService)".to_string()
+            ]
+        );
+        assert_eq!(
+            calls[4].reason.as_deref(),
+            Some("UserService has no field metrics")
+        );
+    }
+}
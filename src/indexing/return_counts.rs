@@ -0,0 +1,147 @@
+use tree_sitter::Node;
+
+/// Tag on functions and methods with the number of return statements in
+/// their own body
+pub const RETURN_COUNT_TAG: &str = "return_count";
+
+/// Tag on functions and methods with the number of those returns that are
+/// not the last statement of the body: guard clauses and error paths
+pub const EARLY_RETURN_COUNT_TAG: &str = "early_return_count";
+
+/// Node kinds that return from the enclosing function
+const RETURN_KINDS: &[&str] = &["return_statement", "return_expression", "return"];
+
+/// Node kinds with their own body to return from. Returns inside them belong
+/// to them, not to the function they are nested in. Ruby blocks are left
+/// out: `return` in a block returns from the method.
+pub(crate) const FUNCTION_KINDS: &[&str] = &[
+    "function_declaration",
+    "method_declaration",
+    "func_literal",
+    "function_item",
+    "closure_expression",
+    "function_definition",
+    "lambda",
+    "function_expression",
+    "arrow_function",
+    "method_definition",
+    "generator_function",
+    "generator_function_declaration",
+    "lambda_expression",
+    "constructor_declaration",
+    "local_function_statement",
+    "anonymous_method_expression",
+    "anonymous_function",
+    "method",
+    "singleton_method",
+];
+
+/// Return statements of one function
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct ReturnCount {
+    pub returns: u32,
+    /// Returns that are not the last statement of the body
+    pub early_returns: u32,
+}
+
+/// Count the returns of the function a definition declares, at any depth
+/// of nested blocks but not inside closures or nested functions. A
+/// definition wrapping its function (`const f = () => {...}`) counts the
+/// first function inside it.
+pub fn count_returns(definition: Node) -> ReturnCount {
+    let function = if FUNCTION_KINDS.contains(&definition.kind()) {
+        definition
+    } else {
+        first_function(definition).unwrap_or(definition)
+    };
+    let body = function.child_by_field_name("body").unwrap_or(function);
+    let tail = last_statement(body).filter(|last| RETURN_KINDS.contains(&last.kind()));
+
+    let mut count = ReturnCount::default();
+    let mut stack = vec![body];
+    while let Some(node) = stack.pop() {
+        if RETURN_KINDS.contains(&node.kind()) {
+            count.returns += 1;
+            if tail.map(|tail| tail.id()) != Some(node.id()) {
+                count.early_returns += 1;
+            }
+        }
+        let mut cursor = node.walk();
+        stack.extend(
+            node.named_children(&mut cursor)
+                .filter(|child| !FUNCTION_KINDS.contains(&child.kind())),
+        );
+    }
+    count
+}
+
+fn first_function(node: Node) -> Option<Node> {
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    children.into_iter().find_map(|child| {
+        if FUNCTION_KINDS.contains(&child.kind()) {
+            Some(child)
+        } else {
+            first_function(child)
+        }
+    })
+}
+
+/// The last statement of a body, skipping trailing comments; Go wraps
+/// statements in a `statement_list`
+fn last_statement(body: Node) -> Option<Node> {
+    let mut cursor = body.walk();
+    let last = body
+        .named_children(&mut cursor)
+        .filter(|child| !child.kind().contains("comment"))
+        .last()?;
+    if last.kind() == "statement_list" {
+        return last_statement(last);
+    }
+    Some(last)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::Language;
+    use tree_sitter::Parser;
+
+    #[test]
+    fn test_count_returns() {
+        let source = r#"package users
+
+func (s *UserService) CreateUser(name string) (*User, error) {
+    if name == "" {
+        return nil, ErrInvalidName
+    }
+    for _, u := range s.users {
+        if u.Name == name {
+            return nil, ErrDuplicate
+        }
+    }
+    valid := func(n string) bool {
+        return len(n) < 64
+    }
+    if !valid(name) {
+        return nil, ErrInvalidName
+    }
+    return NewUser(name), nil
+}
+"#;
+        let mut parser = Parser::new();
+        parser
+            .set_language(&Language::Go.tree_sitter_language())
+            .unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        let function = tree.root_node().named_child(1).unwrap();
+
+        assert_eq!(
+            count_returns(function),
+            ReturnCount {
+                returns: 4,
+                early_returns: 3
+            }
+        );
+    }
+}
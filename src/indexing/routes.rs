@@ -0,0 +1,432 @@
+use crate::indexing::settings::setting_var;
+use crate::models::{Location, Symbol, SymbolId, SymbolType, Visibility};
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+use tree_sitter::Node;
+
+/// Tag on route symbols with the HTTP method, `ANY` when every method matches
+pub const ROUTE_METHOD_TAG: &str = "http_method";
+
+/// Tag on route symbols with the path pattern as registered
+pub const ROUTE_PATH_TAG: &str = "route_path";
+
+/// Tag on route symbols with the handler argument as written
+pub const ROUTE_HANDLER_TAG: &str = "handler";
+
+/// Tag on route symbols with the router whose call registered them
+pub const ROUTE_ROUTER_TAG: &str = "router";
+
+const HTTP_METHODS: &[&str] = &[
+    "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE",
+];
+
+/// Where a registration call gets the HTTP method from
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum MethodSource {
+    /// The call registers one method: `r.Get`, `e.POST`
+    Fixed(String),
+    /// Any method, unless the pattern starts with one: `GET /users` for
+    /// net/http since Go 1.22
+    Any,
+    /// The first argument names the method: `r.Method("GET", ...)`
+    Argument,
+}
+
+/// A method call that registers a route, such as `HandleFunc`
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RouteCall {
+    pub router: String,
+    pub call: String,
+    pub method: MethodSource,
+}
+
+/// Router registration calls recognized in Go code, configured through
+/// ROBERTO_ROUTES as a comma separated list of routers (`net/http`, `chi`,
+/// `gin`, `echo`) and custom calls (`Route` for any method, `AddGet=GET`).
+/// Unset, no routes are extracted.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct RoutePatterns {
+    calls: Vec<RouteCall>,
+    spec: Vec<String>,
+}
+
+impl RoutePatterns {
+    pub const ROUTERS: &'static [&'static str] = &["net/http", "chi", "gin", "echo"];
+
+    pub fn from_env() -> Self {
+        setting_var("ROBERTO_ROUTES")
+            .map(|spec| Self::parse(&spec))
+            .unwrap_or_default()
+    }
+
+    pub fn parse(spec: &str) -> Self {
+        let mut patterns = Self::default();
+        for entry in spec.split(',').map(str::trim).filter(|e| !e.is_empty()) {
+            match preset(entry) {
+                Some(calls) => patterns.calls.extend(calls),
+                None => {
+                    let (call, method) = match entry.split_once('=') {
+                        Some((call, method)) => {
+                            let method = method.trim().to_uppercase();
+                            let method = if method == "*" || method == "ANY" {
+                                MethodSource::Any
+                            } else {
+                                MethodSource::Fixed(method)
+                            };
+                            (call.trim(), method)
+                        }
+                        None => (entry, MethodSource::Any),
+                    };
+                    patterns.calls.push(RouteCall {
+                        router: "custom".to_string(),
+                        call: call.to_string(),
+                        method,
+                    });
+                }
+            }
+            patterns.spec.push(entry.to_string());
+        }
+        patterns.spec.sort();
+        patterns.spec.dedup();
+        patterns
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.calls.is_empty()
+    }
+
+    /// Canonical form of the configuration, persisted with the cache
+    pub fn fingerprint(&self) -> String {
+        self.spec.join(",")
+    }
+
+    /// The registration calls named `call`, those taking the method as an
+    /// argument first: their shape is the easiest to rule out
+    fn candidates<'a>(&'a self, call: &'a str) -> impl Iterator<Item = &'a RouteCall> {
+        let named = move |c: &&RouteCall| c.call == call;
+        let by_argument = self
+            .calls
+            .iter()
+            .filter(named)
+            .filter(|c| c.method == MethodSource::Argument);
+        let others = self
+            .calls
+            .iter()
+            .filter(named)
+            .filter(|c| c.method != MethodSource::Argument);
+        by_argument.chain(others)
+    }
+}
+
+fn preset(router: &str) -> Option<Vec<RouteCall>> {
+    let fixed = |names: &[&str]| -> Vec<(String, MethodSource)> {
+        names
+            .iter()
+            .zip(HTTP_METHODS)
+            .map(|(name, method)| (name.to_string(), MethodSource::Fixed(method.to_string())))
+            .collect()
+    };
+    let calls = match router.to_lowercase().as_str() {
+        "net/http" | "http" => vec![
+            ("Handle".to_string(), MethodSource::Any),
+            ("HandleFunc".to_string(), MethodSource::Any),
+        ],
+        "chi" => {
+            let mut calls = fixed(&[
+                "Get", "Post", "Put", "Patch", "Delete", "Head", "Options", "Connect", "Trace",
+            ]);
+            calls.push(("Handle".to_string(), MethodSource::Any));
+            calls.push(("HandleFunc".to_string(), MethodSource::Any));
+            calls.push(("Method".to_string(), MethodSource::Argument));
+            calls.push(("MethodFunc".to_string(), MethodSource::Argument));
+            calls
+        }
+        "gin" => {
+            let mut calls = fixed(&["GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"]);
+            calls.push(("Any".to_string(), MethodSource::Any));
+            calls.push(("Handle".to_string(), MethodSource::Argument));
+            calls
+        }
+        "echo" => {
+            let mut calls = fixed(&[
+                "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE",
+            ]);
+            calls.push(("Any".to_string(), MethodSource::Any));
+            calls.push(("Add".to_string(), MethodSource::Argument));
+            calls
+        }
+        _ => return None,
+    };
+    let router = if router == "http" { "net/http" } else { router };
+    Some(
+        calls
+            .into_iter()
+            .map(|(call, method)| RouteCall {
+                router: router.to_lowercase(),
+                call,
+                method,
+            })
+            .collect(),
+    )
+}
+
+/// A route registered by a recognized call
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Route {
+    pub method: String,
+    pub path: String,
+    pub handler: String,
+    pub router: String,
+}
+
+/// Route symbols for the registration calls in a Go file, named
+/// `METHOD /path` and tagged with the method, path, handler and router.
+/// Only calls with a string literal pattern are recognized; prefixes of
+/// route groups are not applied.
+pub fn route_symbols(
+    root: Node,
+    source: &str,
+    file_path: &PathBuf,
+    namespace: Option<&str>,
+    patterns: &RoutePatterns,
+) -> Vec<Symbol> {
+    let mut symbols = Vec::new();
+    let mut stack = vec![root];
+    while let Some(node) = stack.pop() {
+        let mut cursor = node.walk();
+        stack.extend(node.named_children(&mut cursor));
+        if node.kind() != "call_expression" {
+            continue;
+        }
+        let Some(route) = route_at(node, source, patterns) else {
+            continue;
+        };
+        let start = node.start_position();
+        let end = node.end_position();
+        let location = Location::new(
+            file_path.clone(),
+            start.row as u32 + 1,
+            start.column as u32,
+            end.row as u32 + 1,
+            end.column as u32,
+        );
+        let tags = BTreeMap::from([
+            (ROUTE_METHOD_TAG.to_string(), route.method.clone()),
+            (ROUTE_PATH_TAG.to_string(), route.path.clone()),
+            (ROUTE_HANDLER_TAG.to_string(), route.handler),
+            (ROUTE_ROUTER_TAG.to_string(), route.router),
+        ]);
+        symbols.push(Symbol {
+            id: SymbolId::new(file_path, location.start_line, location.start_column),
+            name: format!("{} {}", route.method, route.path),
+            symbol_type: SymbolType::Route,
+            location,
+            namespace: namespace.map(String::from),
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags,
+            doc: None,
+            alias_of: None,
+        });
+    }
+    symbols.sort_by_key(|symbol| (symbol.location.start_line, symbol.location.start_column));
+    symbols
+}
+
+/// The route a call registers, when it is a recognized registration call
+fn route_at(call: Node, source: &str, patterns: &RoutePatterns) -> Option<Route> {
+    let function = call.child_by_field_name("function")?;
+    if function.kind() != "selector_expression" {
+        return None;
+    }
+    let name = function
+        .child_by_field_name("field")?
+        .utf8_text(source.as_bytes())
+        .ok()?;
+    let arguments = call.child_by_field_name("arguments")?;
+    let mut cursor = arguments.walk();
+    let arguments: Vec<Node> = arguments.named_children(&mut cursor).collect();
+    let literal = |index: usize| -> Option<String> {
+        let node = arguments.get(index)?;
+        if !matches!(
+            node.kind(),
+            "interpreted_string_literal" | "raw_string_literal"
+        ) {
+            return None;
+        }
+        let text = node.utf8_text(source.as_bytes()).ok()?;
+        Some(text.trim_matches(|c| c == '"' || c == '`').to_string())
+    };
+
+    // Routers share call names with different shapes, like chi's
+    // Handle(pattern, h) and gin's Handle(method, path, h)
+    for candidate in patterns.candidates(name) {
+        let (method, path, first_handler) = match &candidate.method {
+            MethodSource::Argument => {
+                let method = literal(0).map(|method| method.to_uppercase());
+                match (method, literal(1)) {
+                    (Some(method), Some(path)) if HTTP_METHODS.contains(&method.as_str()) => {
+                        (method, path, 2)
+                    }
+                    _ => continue,
+                }
+            }
+            MethodSource::Fixed(method) => match literal(0) {
+                Some(path) => (method.clone(), path, 1),
+                None => continue,
+            },
+            MethodSource::Any => match literal(0) {
+                Some(pattern) => {
+                    let (method, path) = split_method(&pattern);
+                    (method, path, 1)
+                }
+                None => continue,
+            },
+        };
+        // Middleware may come first; the handler is the last argument
+        if arguments.len() <= first_handler {
+            continue;
+        }
+        let handler = handler_text(*arguments.last()?, source)?;
+        return Some(Route {
+            method,
+            path,
+            handler,
+            router: candidate.router.clone(),
+        });
+    }
+    None
+}
+
+/// `GET /users/{id}` as registered with net/http since Go 1.22; other
+/// patterns match any method
+fn split_method(pattern: &str) -> (String, String) {
+    if let Some((method, path)) = pattern.split_once(' ') {
+        if HTTP_METHODS.contains(&method) {
+            return (method.to_string(), path.trim().to_string());
+        }
+    }
+    ("ANY".to_string(), pattern.to_string())
+}
+
+/// The handler as written, seen through `http.HandlerFunc(h)` conversions;
+/// inline handlers are `func literal`
+fn handler_text(node: Node, source: &str) -> Option<String> {
+    if node.kind() == "func_literal" {
+        return Some("func literal".to_string());
+    }
+    if node.kind() == "call_expression" {
+        let function = node.child_by_field_name("function")?;
+        let function_text = function.utf8_text(source.as_bytes()).ok()?;
+        let arguments = node.child_by_field_name("arguments")?;
+        if function_text.ends_with("HandlerFunc") && arguments.named_child_count() == 1 {
+            return handler_text(arguments.named_child(0)?, source);
+        }
+    }
+    node.utf8_text(source.as_bytes()).ok().map(String::from)
+}
+
+/// The function a handler expression names: `(Some("users"), "List")` for
+/// `users.List`, `(Some("s"), "handleUsers")` for `s.handleUsers`,
+/// `(None, "health")` for `health`. `None` for calls and literals, which
+/// build their handler at run time.
+pub fn handler_name(handler: &str) -> Option<(Option<&str>, &str)> {
+    let valid =
+        |part: &str| !part.is_empty() && part.chars().all(|c| c.is_alphanumeric() || c == '_');
+    match handler.rsplit_once('.') {
+        Some((qualifier, name)) if valid(qualifier) && valid(name) => Some((Some(qualifier), name)),
+        None if valid(handler) => Some((None, handler)),
+        _ => None,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::Language;
+    use tree_sitter::Parser;
+
+    #[test]
+    fn test_route_patterns() {
+        let patterns = RoutePatterns::parse("gin, net/http, Route, AddGet=get");
+        assert!(!patterns.is_empty());
+        assert_eq!(patterns.fingerprint(), "AddGet=get,Route,gin,net/http");
+        assert!(RoutePatterns::parse("").is_empty());
+
+        let handle: Vec<&RouteCall> = patterns.candidates("Handle").collect();
+        assert_eq!(handle.len(), 2);
+        assert_eq!(handle[0].method, MethodSource::Argument);
+        assert_eq!(handle[1].router, "net/http");
+        let custom: Vec<&RouteCall> = patterns.candidates("AddGet").collect();
+        assert_eq!(custom[0].method, MethodSource::Fixed("GET".to_string()));
+    }
+
+    #[test]
+    fn test_split_method_and_handler_name() {
+        assert_eq!(
+            split_method("GET /users/{id}"),
+            ("GET".to_string(), "/users/{id}".to_string())
+        );
+        assert_eq!(
+            split_method("/healthz"),
+            ("ANY".to_string(), "/healthz".to_string())
+        );
+        assert_eq!(handler_name("users.List"), Some((Some("users"), "List")));
+        assert_eq!(handler_name("health"), Some((None, "health")));
+        assert_eq!(handler_name("h.Users()"), None);
+        assert_eq!(handler_name("func literal"), None);
+    }
+
+    #[test]
+    fn test_route_symbols() {
+        let source = r#"package api
+
+func routes(s *Server, r chi.Router, g *gin.Engine) {
+    http.HandleFunc("GET /users/{id}", s.getUser)
+    http.Handle("/static/", http.HandlerFunc(serveStatic))
+    r.Get("/orders", listOrders)
+    r.Method("DELETE", "/orders/{id}", s.deleteOrder)
+    g.Handle("POST", "/login", authMiddleware, s.login)
+    g.GET(path, s.dynamic)
+    r.Post("/ping", func(w http.ResponseWriter, r *http.Request) {})
+}
+"#;
+        let mut parser = Parser::new();
+        parser
+            .set_language(&Language::Go.tree_sitter_language())
+            .unwrap();
+        let tree = parser.parse(source, None).unwrap();
+        let patterns = RoutePatterns::parse("net/http,chi,gin");
+        let symbols = route_symbols(
+            tree.root_node(),
+            source,
+            &PathBuf::from("api/routes.go"),
+            Some("api"),
+            &patterns,
+        );
+        let routes: Vec<(&str, &str, &str)> = symbols
+            .iter()
+            .map(|s| {
+                (
+                    s.name.as_str(),
+                    s.tags[ROUTE_HANDLER_TAG].as_str(),
+                    s.tags[ROUTE_ROUTER_TAG].as_str(),
+                )
+            })
+            .collect();
+        assert_eq!(
+            routes,
+            vec![
+                ("GET /users/{id}", "s.getUser", "net/http"),
+                ("ANY /static/", "serveStatic", "net/http"),
+                ("GET /orders", "listOrders", "chi"),
+                ("DELETE /orders/{id}", "s.deleteOrder", "chi"),
+                ("POST /login", "s.login", "gin"),
+                ("POST /ping", "func literal", "chi"),
+            ]
+        );
+        assert!(symbols.iter().all(|s| s.symbol_type == SymbolType::Route));
+        assert_eq!(symbols[0].location.start_line, 4);
+    }
+}
@@ -0,0 +1,263 @@
+use crate::models::{Location, Symbol, SymbolId, SymbolType, Visibility};
+use std::collections::{BTreeMap, HashMap};
+use std::path::PathBuf;
+use tree_sitter::Node;
+
+/// Tag on case classes and case objects
+pub const CASE_TAG: &str = "case";
+/// Tag on `implicit` definitions
+pub const IMPLICIT_TAG: &str = "implicit";
+/// Tag on a class or trait and its companion object, naming the kind of
+/// the other half: `object` on the class, `class` or `trait` on the object
+pub const COMPANION_TAG: &str = "companion";
+/// Tag with the supertypes named in `extends A with B`, comma separated
+pub const EXTENDS_TAG: &str = "extends";
+/// Tag on case classes listing the members the compiler generates
+pub const GENERATED_TAG: &str = "generated";
+
+/// Members generated for every case class and its companion object
+const CASE_CLASS_MEMBERS: &str = "apply, unapply, copy, equals, hashCode, toString";
+
+const DEFINITION_KINDS: &[&str] = &[
+    "class_definition",
+    "object_definition",
+    "trait_definition",
+    "enum_definition",
+    "function_definition",
+    "function_declaration",
+    "val_definition",
+    "var_definition",
+    "type_definition",
+];
+
+/// Refine the symbols the Scala query found with what the query cannot
+/// express: `def`s inside a class, object or trait become methods, access
+/// modifiers set the visibility, `case`, `implicit`, companion objects and
+/// `extends`/`with` supertypes are recorded as tags, and the parameters of
+/// case classes (and `val`/`var` class parameters) are added as fields.
+///
+/// Only definitions the grammar parsed are touched, so a file with syntax
+/// the grammar does not know keeps the symbols found around it.
+pub fn apply_scala_model(root: Node, source: &str, file_path: &PathBuf, symbols: &mut Vec<Symbol>) {
+    let mut by_start: HashMap<(u32, u32), Vec<usize>> = HashMap::new();
+    for (index, symbol) in symbols.iter().enumerate() {
+        by_start
+            .entry((symbol.location.start_line, symbol.location.start_column))
+            .or_default()
+            .push(index);
+    }
+    let mut definitions = Vec::new();
+    collect_definitions(root, &mut definitions);
+
+    let mut fields = Vec::new();
+    for definition in &definitions {
+        let start = definition.start_position();
+        let Some(indices) = by_start.get(&(start.row as u32 + 1, start.column as u32)) else {
+            continue;
+        };
+        let modifiers = modifier_words(*definition, source);
+        let is_case = modifiers.iter().any(|word| word == "case");
+        let supertypes = supertypes(*definition, source);
+        let companion = companion_kind(*definition, source);
+
+        for &index in indices {
+            let symbol = &mut symbols[index];
+            symbol.visibility = visibility(&modifiers);
+            if symbol.symbol_type == SymbolType::Function && is_member(*definition) {
+                symbol.symbol_type = SymbolType::Method;
+            }
+            if modifiers.iter().any(|word| word == "implicit") {
+                symbol
+                    .tags
+                    .insert(IMPLICIT_TAG.to_string(), "true".to_string());
+            }
+            if is_case {
+                symbol.tags.insert(CASE_TAG.to_string(), "true".to_string());
+                if definition.kind() == "class_definition" {
+                    symbol
+                        .tags
+                        .insert(GENERATED_TAG.to_string(), CASE_CLASS_MEMBERS.to_string());
+                }
+            }
+            if !supertypes.is_empty() {
+                symbol
+                    .tags
+                    .insert(EXTENDS_TAG.to_string(), supertypes.join(", "));
+            }
+            if let Some(companion) = companion {
+                symbol
+                    .tags
+                    .insert(COMPANION_TAG.to_string(), companion.to_string());
+            }
+        }
+
+        if definition.kind() == "class_definition" {
+            let namespace = indices
+                .first()
+                .and_then(|&index| symbols[index].namespace.clone());
+            fields.extend(class_fields(
+                *definition,
+                source,
+                file_path,
+                namespace,
+                is_case,
+            ));
+        }
+    }
+    symbols.extend(fields);
+}
+
+fn collect_definitions<'t>(node: Node<'t>, definitions: &mut Vec<Node<'t>>) {
+    if DEFINITION_KINDS.contains(&node.kind()) {
+        definitions.push(node);
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_definitions(child, definitions);
+    }
+}
+
+/// Words before the definition's name: annotations, modifiers and keywords
+/// such as `private[service]`, `implicit`, `case`, `def`
+fn modifier_words(definition: Node, source: &str) -> Vec<String> {
+    let end = definition
+        .child_by_field_name("name")
+        .or_else(|| definition.child_by_field_name("pattern"))
+        .map_or(definition.end_byte(), |name| name.start_byte());
+    source
+        .get(definition.start_byte()..end)
+        .unwrap_or_default()
+        .split_whitespace()
+        .map(String::from)
+        .collect()
+}
+
+/// `private[pkg]` is visible within a package, like Kotlin's `internal`
+fn visibility(modifiers: &[String]) -> Visibility {
+    for word in modifiers {
+        if word.starts_with("private[") {
+            return Visibility::Internal;
+        }
+        if word == "private" {
+            return Visibility::Private;
+        }
+        if word.starts_with("protected") {
+            return Visibility::Protected;
+        }
+    }
+    Visibility::Public
+}
+
+/// Defined in the body of a class, object or trait
+fn is_member(definition: Node) -> bool {
+    definition
+        .parent()
+        .is_some_and(|parent| parent.kind() == "template_body")
+}
+
+/// Supertypes in `extends A(args) with B[T]`, generic arguments and
+/// qualifiers dropped: `["A", "B"]`
+fn supertypes(definition: Node, source: &str) -> Vec<String> {
+    let Some(clause) = definition.child_by_field_name("extend") else {
+        return Vec::new();
+    };
+    let mut names: Vec<String> = Vec::new();
+    let mut cursor = clause.walk();
+    for child in clause.named_children(&mut cursor) {
+        if child.kind() == "arguments" {
+            continue;
+        }
+        let Ok(text) = child.utf8_text(source.as_bytes()) else {
+            continue;
+        };
+        let base = text.split('[').next().unwrap_or(text).trim();
+        let name = base.rsplit('.').next().unwrap_or(base);
+        if !name.is_empty() && !names.iter().any(|existing| existing == name) {
+            names.push(name.to_string());
+        }
+    }
+    names
+}
+
+/// For a class or trait with an object of the same name next to it (or the
+/// reverse), the kind of that other definition
+fn companion_kind(definition: Node, source: &str) -> Option<&'static str> {
+    let partner_kinds: &[&str] = match definition.kind() {
+        "class_definition" | "trait_definition" => &["object_definition"],
+        "object_definition" => &["class_definition", "trait_definition"],
+        _ => return None,
+    };
+    let name = definition
+        .child_by_field_name("name")?
+        .utf8_text(source.as_bytes())
+        .ok()?;
+    let parent = definition.parent()?;
+    let mut cursor = parent.walk();
+    let partner = parent.named_children(&mut cursor).find(|sibling| {
+        partner_kinds.contains(&sibling.kind())
+            && sibling
+                .child_by_field_name("name")
+                .and_then(|other| other.utf8_text(source.as_bytes()).ok())
+                == Some(name)
+    })?;
+    Some(match partner.kind() {
+        "object_definition" => "object",
+        "trait_definition" => "trait",
+        _ => "class",
+    })
+}
+
+/// Constructor parameters that are also members: every parameter of a case
+/// class, and `val`/`var` parameters of other classes
+fn class_fields(
+    class: Node,
+    source: &str,
+    file_path: &PathBuf,
+    namespace: Option<String>,
+    is_case: bool,
+) -> Vec<Symbol> {
+    let mut fields = Vec::new();
+    let mut cursor = class.walk();
+    for parameters in class.children_by_field_name("class_parameters", &mut cursor) {
+        let mut parameter_cursor = parameters.walk();
+        for parameter in parameters.named_children(&mut parameter_cursor) {
+            if parameter.kind() != "class_parameter" {
+                continue;
+            }
+            let modifiers = modifier_words(parameter, source);
+            let declared = modifiers.iter().any(|word| word == "val" || word == "var");
+            if !is_case && !declared {
+                continue;
+            }
+            let Some(name) = parameter
+                .child_by_field_name("name")
+                .and_then(|name| name.utf8_text(source.as_bytes()).ok())
+            else {
+                continue;
+            };
+            let start = parameter.start_position();
+            let end = parameter.end_position();
+            let location = Location::new(
+                file_path.clone(),
+                start.row as u32 + 1,
+                start.column as u32,
+                end.row as u32 + 1,
+                end.column as u32,
+            );
+            fields.push(Symbol {
+                id: SymbolId::new(file_path, location.start_line, location.start_column),
+                name: name.to_string(),
+                symbol_type: SymbolType::Variable,
+                location,
+                namespace: namespace.clone(),
+                visibility: visibility(&modifiers),
+                source: None,
+                signature: None,
+                tags: BTreeMap::new(),
+                doc: None,
+                alias_of: None,
+            });
+        }
+    }
+    fields
+}
@@ -0,0 +1,184 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Titles longer than this read as prose rather than a heading
+const MAX_TITLE_WORDS: usize = 8;
+
+/// A top-level comment that groups the declarations after it, such as
+/// `// Database implementations`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Section {
+    pub title: String,
+    /// 1-based line of the comment
+    pub line: u32,
+    /// Last line before the next section, or the end of the file
+    pub end_line: u32,
+}
+
+/// Find section comments: single-line comments at the top level of a file
+/// that are not the doc comment of the declaration below them. A comment
+/// whose first word appears on the next declaration's first line documents
+/// that declaration (`// NewServer creates...`), as do sentences, file
+/// headers before the package clause and directives like `//go:build`.
+pub fn find_sections(
+    source: &str,
+    language: Language,
+) -> Result<Vec<Section>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+    let lines: Vec<&str> = source.lines().collect();
+
+    let mut sections: Vec<Section> = Vec::new();
+    let mut cursor = root.walk();
+    let children: Vec<Node> = root.named_children(&mut cursor).collect();
+    for (index, node) in children.iter().enumerate() {
+        if !node.kind().contains("comment") || node.start_position().row != node.end_position().row
+        {
+            continue;
+        }
+        // Part of a comment block
+        let touches = |other: Option<&Node>| {
+            other.is_some_and(|other| {
+                other.kind().contains("comment")
+                    && other
+                        .start_position()
+                        .row
+                        .abs_diff(node.start_position().row)
+                        == 1
+            })
+        };
+        if touches(index.checked_sub(1).and_then(|i| children.get(i)))
+            || touches(children.get(index + 1))
+        {
+            continue;
+        }
+        // Comments before the package clause are file headers or package docs
+        if children[index + 1..].iter().any(|later| {
+            matches!(
+                later.kind(),
+                "package_clause" | "package_declaration" | "package_header"
+            )
+        }) {
+            continue;
+        }
+        let Some(title) = node
+            .utf8_text(source.as_bytes())
+            .ok()
+            .and_then(comment_title)
+        else {
+            continue;
+        };
+
+        // A comment directly above a declaration that names it is its doc
+        if let Some(next) = children.get(index + 1) {
+            let adjacent = next.start_position().row == node.end_position().row + 1;
+            let first_word = title.split_whitespace().next().unwrap_or_default();
+            let declaration = lines
+                .get(next.start_position().row)
+                .copied()
+                .unwrap_or_default();
+            if adjacent && contains_word(declaration, first_word) {
+                continue;
+            }
+        }
+
+        let line = node.start_position().row as u32 + 1;
+        if let Some(previous) = sections.last_mut() {
+            previous.end_line = line - 1;
+        }
+        sections.push(Section {
+            title,
+            line,
+            end_line: lines.len().max(1) as u32,
+        });
+    }
+    Ok(sections)
+}
+
+/// The heading text of a comment, or `None` for directives and prose
+fn comment_title(comment: &str) -> Option<String> {
+    let comment = comment.trim();
+    if comment.starts_with("//go:")
+        || comment.starts_with("// +build")
+        || comment.starts_with("#!")
+        || comment.starts_with("# -*-")
+    {
+        return None;
+    }
+    let text = comment
+        .trim_start_matches("///")
+        .trim_start_matches("//")
+        .trim_start_matches("/*")
+        .trim_end_matches("*/")
+        .trim_start_matches("--")
+        .trim_start_matches('#')
+        .trim_matches(|c: char| c.is_whitespace() || c == '-' || c == '=' || c == '*');
+    let words = text.split_whitespace().count();
+    if words == 0 || words > MAX_TITLE_WORDS || text.ends_with('.') {
+        return None;
+    }
+    Some(text.to_string())
+}
+
+fn contains_word(text: &str, word: &str) -> bool {
+    !word.is_empty()
+        && text
+            .split(|c: char| !(c.is_alphanumeric() || c == '_'))
+            .any(|token| token == word)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_comment_title() {
+        assert_eq!(
+            comment_title("// Database implementations").unwrap(),
+            "Database implementations"
+        );
+        assert_eq!(comment_title("# ---- Helpers ----").unwrap(), "Helpers");
+        assert_eq!(comment_title("/* Handlers */").unwrap(), "Handlers");
+        assert_eq!(comment_title("//go:build linux"), None);
+        assert_eq!(comment_title("// Close releases the pool."), None);
+        assert_eq!(comment_title("//"), None);
+    }
+
+    #[test]
+    fn test_go_sections() {
+        let source = r#"// Package store keeps users.
+package store
+
+// Constants
+const MaxUsers = 100
+
+// Interfaces
+type Repository interface {
+    Get(id string) (*User, error)
+}
+
+// Repository implementations
+
+// NewMemoryRepository returns an empty repository
+func NewMemoryRepository() *MemoryRepository {
+    return &MemoryRepository{}
+}
+"#;
+        let sections = find_sections(source, Language::Go).unwrap();
+        let summary: Vec<(&str, u32, u32)> = sections
+            .iter()
+            .map(|s| (s.title.as_str(), s.line, s.end_line))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("Constants", 4, 6),
+                ("Interfaces", 7, 11),
+                ("Repository implementations", 12, 17),
+            ]
+        );
+    }
+}
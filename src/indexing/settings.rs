@@ -0,0 +1,218 @@
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::env::VarError;
+use std::sync::{PoisonError, RwLock};
+
+/// Settings changed while the server runs, by variable name; `None` unsets
+/// one that is present in the environment. Changing the process
+/// environment is unsound once other threads may read it, so changes are
+/// kept here and read through `setting_var`.
+static OVERRIDES: RwLock<BTreeMap<String, Option<String>>> = RwLock::new(BTreeMap::new());
+
+/// The value of a setting like `std::env::var`, with changes made through
+/// `set_setting` taking the place of the environment
+pub fn setting_var(name: &str) -> Result<String, VarError> {
+    match OVERRIDES
+        .read()
+        .unwrap_or_else(PoisonError::into_inner)
+        .get(name)
+    {
+        Some(Some(value)) => Ok(value.clone()),
+        Some(None) => Err(VarError::NotPresent),
+        None => std::env::var(name),
+    }
+}
+
+/// Change a setting for the rest of the process; `None` unsets it
+pub fn set_setting(name: &str, value: Option<String>) {
+    OVERRIDES
+        .write()
+        .unwrap_or_else(PoisonError::into_inner)
+        .insert(name.to_string(), value);
+}
+
+/// How much of the index a settings change invalidates
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ReindexScope {
+    /// The index is unaffected. These settings are read once at startup,
+    /// so a change takes effect when the server restarts.
+    None,
+    /// Which files are indexed changed: files no longer selected are
+    /// dropped and newly selected ones parsed
+    Files,
+    /// What is extracted from every file changed, so all are parsed again
+    Full,
+}
+
+impl ReindexScope {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ReindexScope::None => "none",
+            ReindexScope::Files => "files",
+            ReindexScope::Full => "full",
+        }
+    }
+}
+
+/// The environment settings the server reads, with what a change to each
+/// requires of the index
+pub const SETTINGS: &[(&str, ReindexScope)] = &[
+    ("ROBERTO_INDEX_KINDS", ReindexScope::Full),
+    ("ROBERTO_MIN_NAME_LENGTH", ReindexScope::Full),
+    ("ROBERTO_EXCLUDE_NAMES", ReindexScope::Full),
+    ("ROBERTO_ALLOW_NAMES", ReindexScope::Full),
+    ("ROBERTO_TAG_KEYS", ReindexScope::Full),
+    ("ROBERTO_LANGUAGE_PRIORITY", ReindexScope::Full),
+    ("ROBERTO_ROUTES", ReindexScope::Full),
+    ("ROBERTO_TYPE_ARGUMENT_REFS", ReindexScope::Full),
+    ("ROBERTO_PARSE_TIMEOUT_MS", ReindexScope::Full),
+    ("ROBERTO_MAX_FILE_SIZE_KB", ReindexScope::Files),
+    ("ROBERTO_FOLLOW_SYMLINKS", ReindexScope::Files),
+    ("ROBERTO_VENDOR", ReindexScope::Files),
+    ("ROBERTO_VENDOR_PATHS", ReindexScope::Files),
+    ("ROBERTO_INDEX_DOCS", ReindexScope::Files),
+    ("ROBERTO_LOG_LEVEL", ReindexScope::None),
+    ("ROBERTO_LOG_FORMAT", ReindexScope::None),
+    ("ROBERTO_LOG_FILE", ReindexScope::None),
+    ("ROBERTO_KIND_MAP", ReindexScope::None),
+    ("ROBERTO_SYNONYMS", ReindexScope::None),
+    ("ROBERTO_MEMORY_LIMIT", ReindexScope::None),
+    ("ROBERTO_DEFINITION_CACHE_ENTRIES", ReindexScope::None),
+    ("ROBERTO_DEFINITION_CACHE_MB", ReindexScope::None),
+    ("ROBERTO_REF_INDEX_CACHE", ReindexScope::None),
+    ("ROBERTO_CONSISTENT_READS", ReindexScope::None),
+    ("ROBERTO_NORMALIZE_PATHS", ReindexScope::None),
+    ("ROBERTO_REINDEX_ON_SIGHUP", ReindexScope::None),
+];
+
+/// What a change to `setting` requires of the index; `None` for names the
+/// server does not read
+pub fn setting_scope(setting: &str) -> Option<ReindexScope> {
+    SETTINGS
+        .iter()
+        .find(|(name, _)| *name == setting)
+        .map(|(_, scope)| *scope)
+}
+
+/// One setting whose value changed
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SettingChange {
+    pub setting: String,
+    /// Unset when absent
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub before: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub after: Option<String>,
+    pub reindex: ReindexScope,
+}
+
+/// The values of every known setting at one point in time
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SettingsSnapshot {
+    values: BTreeMap<&'static str, Option<String>>,
+}
+
+impl SettingsSnapshot {
+    pub fn from_env() -> Self {
+        Self::from_lookup(|name| setting_var(name).ok())
+    }
+
+    pub fn from_lookup(lookup: impl Fn(&str) -> Option<String>) -> Self {
+        Self {
+            values: SETTINGS
+                .iter()
+                .map(|(name, _)| (*name, lookup(name)))
+                .collect(),
+        }
+    }
+
+    /// Settings whose value differs in `newer`, in table order
+    pub fn changes(&self, newer: &SettingsSnapshot) -> Vec<SettingChange> {
+        SETTINGS
+            .iter()
+            .filter_map(|(name, scope)| {
+                let before = self.values.get(name).cloned().flatten();
+                let after = newer.values.get(name).cloned().flatten();
+                (before != after).then(|| SettingChange {
+                    setting: name.to_string(),
+                    before,
+                    after,
+                    reindex: *scope,
+                })
+            })
+            .collect()
+    }
+}
+
+/// The widest re-indexing any of `changes` requires
+pub fn reindex_scope(changes: &[SettingChange]) -> ReindexScope {
+    changes
+        .iter()
+        .map(|change| change.reindex)
+        .max()
+        .unwrap_or(ReindexScope::None)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_settings_changes() {
+        let before = SettingsSnapshot::from_lookup(|name| match name {
+            "ROBERTO_LOG_LEVEL" => Some("info".to_string()),
+            "ROBERTO_MAX_FILE_SIZE_KB" => Some("512".to_string()),
+            _ => None,
+        });
+        let after = SettingsSnapshot::from_lookup(|name| match name {
+            "ROBERTO_LOG_LEVEL" => Some("debug".to_string()),
+            "ROBERTO_MAX_FILE_SIZE_KB" => Some("512".to_string()),
+            "ROBERTO_VENDOR" => Some("skip".to_string()),
+            _ => None,
+        });
+
+        let changes = before.changes(&after);
+        let summary: Vec<(&str, ReindexScope)> = changes
+            .iter()
+            .map(|change| (change.setting.as_str(), change.reindex))
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("ROBERTO_VENDOR", ReindexScope::Files),
+                ("ROBERTO_LOG_LEVEL", ReindexScope::None),
+            ]
+        );
+        assert_eq!(changes[0].before, None);
+        assert_eq!(changes[0].after.as_deref(), Some("skip"));
+        assert_eq!(reindex_scope(&changes), ReindexScope::Files);
+        assert_eq!(reindex_scope(&changes[1..]), ReindexScope::None);
+
+        let kinds = SettingsSnapshot::from_lookup(|name| {
+            (name == "ROBERTO_INDEX_KINDS").then(|| "go=function".to_string())
+        });
+        assert_eq!(reindex_scope(&after.changes(&kinds)), ReindexScope::Full);
+        assert!(after.changes(&after).is_empty());
+        assert_eq!(setting_scope("ROBERTO_LOG_LEVEL"), Some(ReindexScope::None));
+        assert_eq!(setting_scope("HOME"), None);
+    }
+
+    #[test]
+    fn test_setting_overrides() {
+        assert!(setting_var("ROBERTO_TEST_OVERRIDE").is_err());
+        set_setting("ROBERTO_TEST_OVERRIDE", Some("on".to_string()));
+        assert_eq!(setting_var("ROBERTO_TEST_OVERRIDE").as_deref(), Ok("on"));
+        set_setting("ROBERTO_TEST_OVERRIDE", None);
+        assert_eq!(
+            setting_var("ROBERTO_TEST_OVERRIDE"),
+            Err(VarError::NotPresent)
+        );
+
+        // An unset override hides the environment without changing it
+        assert!(std::env::var("PATH").is_ok());
+        set_setting("PATH", None);
+        assert_eq!(setting_var("PATH"), Err(VarError::NotPresent));
+        assert!(std::env::var("PATH").is_ok());
+    }
+}
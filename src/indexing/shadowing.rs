@@ -0,0 +1,347 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use tree_sitter::{Node, Parser};
+
+/// Statements that open an implicit scope around their own clauses
+const STATEMENT_SCOPES: &[&str] = &[
+    "if_statement",
+    "for_statement",
+    "expression_switch_statement",
+    "type_switch_statement",
+    "select_statement",
+    "expression_case",
+    "type_case",
+    "default_case",
+    "communication_case",
+];
+
+/// Where a variable is declared
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Declaration {
+    pub line: u32,
+    pub column: u32,
+    /// The construct whose scope holds it: `function`, `block`, `if`, `for`,
+    /// `switch`, `case`, `select` or `func literal`
+    pub scope: String,
+}
+
+/// A variable declared in an inner scope with the name of one declared in
+/// an enclosing scope of the same function
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Shadowing {
+    pub name: String,
+    /// The function or method the declarations are in
+    pub function: String,
+    pub shadowing: Declaration,
+    pub shadowed: Declaration,
+    /// The name looks like an error variable: `err`, `errTx`, `parseErr`
+    pub error_variable: bool,
+    /// A deferred closure of the function reads a variable of this name, so
+    /// assignments to the inner variable may be missed by it, as in the
+    /// `defer func() { if err != nil { tx.Rollback() } }()` pattern
+    pub read_by_defer: bool,
+}
+
+/// Whether a Go variable name reads as an error by convention
+pub fn is_error_name(name: &str) -> bool {
+    name == "err"
+        || name.ends_with("Err")
+        || name
+            .strip_prefix("err")
+            .and_then(|rest| rest.chars().next())
+            .is_some_and(|c| c.is_uppercase())
+}
+
+/// Find variables in a Go source file that shadow a variable of an
+/// enclosing scope within the same function: `:=` and `var` in blocks,
+/// `if`/`for`/`switch` headers, range loops, type switch bindings and
+/// closures. Parameters and named results count as declared in the
+/// function's scope. `_` and package-level variables are ignored.
+pub fn find_shadowing(source: &str) -> Result<Vec<Shadowing>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut findings = Vec::new();
+    let root = tree.root_node();
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if !matches!(
+            declaration.kind(),
+            "function_declaration" | "method_declaration"
+        ) {
+            continue;
+        }
+        let Some(name) = declaration
+            .child_by_field_name("name")
+            .and_then(|node| node.utf8_text(source.as_bytes()).ok())
+        else {
+            continue;
+        };
+        let mut deferred = HashSet::new();
+        deferred_reads(declaration, source, false, &mut deferred);
+        let mut walker = ScopeWalker {
+            source,
+            function: name.to_string(),
+            scopes: Vec::new(),
+            deferred,
+            findings: &mut findings,
+        };
+        walker.function(declaration, "function");
+    }
+    Ok(findings)
+}
+
+struct ScopeWalker<'a> {
+    source: &'a str,
+    function: String,
+    scopes: Vec<HashMap<String, Declaration>>,
+    /// Names read inside deferred closures of the function
+    deferred: HashSet<String>,
+    findings: &'a mut Vec<Shadowing>,
+}
+
+impl ScopeWalker<'_> {
+    fn function(&mut self, node: Node, scope: &str) {
+        self.scopes.push(HashMap::new());
+        for field in ["receiver", "parameters", "result"] {
+            // An unnamed single result is a type, not a parameter list
+            if let Some(list) = node
+                .child_by_field_name(field)
+                .filter(|list| list.kind() == "parameter_list")
+            {
+                self.parameters(list, scope);
+            }
+        }
+        // The body's top level shares the scope of the parameters
+        if let Some(body) = node.child_by_field_name("body") {
+            self.children(body, scope);
+        }
+        self.scopes.pop();
+    }
+
+    fn parameters(&mut self, list: Node, scope: &str) {
+        let mut cursor = list.walk();
+        for parameter in list.named_children(&mut cursor) {
+            let mut names = parameter.walk();
+            for name in parameter.children_by_field_name("name", &mut names) {
+                self.declare(name, scope);
+            }
+        }
+    }
+
+    fn children(&mut self, node: Node, scope: &str) {
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.named_children(&mut cursor).collect();
+        for child in children {
+            self.visit(child, scope);
+        }
+    }
+
+    fn visit(&mut self, node: Node, scope: &str) {
+        match node.kind() {
+            "func_literal" => self.function(node, "func literal"),
+            "block" => {
+                self.scopes.push(HashMap::new());
+                self.children(node, "block");
+                self.scopes.pop();
+            }
+            kind if STATEMENT_SCOPES.contains(&kind) => {
+                let scope = statement_scope(kind);
+                self.scopes.push(HashMap::new());
+                // `switch v := x.(type)` binds v for every case
+                if let Some(alias) = node.child_by_field_name("alias") {
+                    self.declare_list(alias, scope);
+                }
+                self.children(node, scope);
+                self.scopes.pop();
+            }
+            "short_var_declaration" => {
+                // The right side is evaluated before the new variables exist
+                if let Some(right) = node.child_by_field_name("right") {
+                    self.visit(right, scope);
+                }
+                if let Some(left) = node.child_by_field_name("left") {
+                    self.declare_list(left, scope);
+                }
+            }
+            "range_clause" | "receive_statement" if declares(node, self.source) => {
+                if let Some(right) = node.child_by_field_name("right") {
+                    self.visit(right, scope);
+                }
+                if let Some(left) = node.child_by_field_name("left") {
+                    self.declare_list(left, scope);
+                }
+            }
+            "var_spec" => {
+                if let Some(value) = node.child_by_field_name("value") {
+                    self.visit(value, scope);
+                }
+                let mut cursor = node.walk();
+                for name in node.children_by_field_name("name", &mut cursor) {
+                    self.declare(name, scope);
+                }
+            }
+            _ => self.children(node, scope),
+        }
+    }
+
+    fn declare_list(&mut self, left: Node, scope: &str) {
+        if left.kind() == "identifier" {
+            self.declare(left, scope);
+            return;
+        }
+        let mut cursor = left.walk();
+        let names: Vec<Node> = left
+            .named_children(&mut cursor)
+            .filter(|name| name.kind() == "identifier")
+            .collect();
+        for name in names {
+            self.declare(name, scope);
+        }
+    }
+
+    fn declare(&mut self, node: Node, scope: &str) {
+        let Ok(name) = node.utf8_text(self.source.as_bytes()) else {
+            return;
+        };
+        if name == "_" {
+            return;
+        }
+        let Some((current, outer)) = self.scopes.split_last_mut() else {
+            return;
+        };
+        // `:=` with an existing variable of the same scope assigns to it
+        if current.contains_key(name) {
+            return;
+        }
+        let position = node.start_position();
+        let declaration = Declaration {
+            line: position.row as u32 + 1,
+            column: position.column as u32,
+            scope: scope.to_string(),
+        };
+        if let Some(shadowed) = outer.iter().rev().find_map(|scope| scope.get(name)) {
+            self.findings.push(Shadowing {
+                name: name.to_string(),
+                function: self.function.clone(),
+                shadowing: declaration.clone(),
+                shadowed: shadowed.clone(),
+                error_variable: is_error_name(name),
+                read_by_defer: self.deferred.contains(name),
+            });
+        }
+        current.insert(name.to_string(), declaration);
+    }
+}
+
+fn statement_scope(kind: &str) -> &'static str {
+    match kind {
+        "if_statement" => "if",
+        "for_statement" => "for",
+        "expression_switch_statement" | "type_switch_statement" => "switch",
+        "select_statement" => "select",
+        _ => "case",
+    }
+}
+
+/// Whether a range clause or select receive declares its variables with `:=`
+fn declares(node: Node, source: &str) -> bool {
+    let mut cursor = node.walk();
+    let declares = node
+        .children(&mut cursor)
+        .any(|child| child.utf8_text(source.as_bytes()) == Ok(":="));
+    declares
+}
+
+/// Identifiers read inside the closures of `defer func() { ... }()`
+fn deferred_reads(node: Node, source: &str, in_defer: bool, names: &mut HashSet<String>) {
+    if in_defer && node.kind() == "identifier" {
+        if let Ok(name) = node.utf8_text(source.as_bytes()) {
+            names.insert(name.to_string());
+        }
+    }
+    let in_defer = in_defer || node.kind() == "defer_statement";
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        deferred_reads(child, source, in_defer, names);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_is_error_name() {
+        assert!(is_error_name("err"));
+        assert!(is_error_name("errTx"));
+        assert!(is_error_name("parseErr"));
+        assert!(!is_error_name("errors"));
+        assert!(!is_error_name("user"));
+    }
+
+    #[test]
+    fn test_find_shadowing() {
+        let source = r#"package users
+
+func (s *UserService) CreateUser(ctx context.Context, name string) (*User, error) {
+    tx, err := s.db.BeginTransaction(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer func() {
+        if err != nil {
+            tx.Rollback()
+        }
+    }()
+
+    if name != "" {
+        _, err := tx.ExecuteQuery(ctx, name)
+        if err != nil {
+            return nil, err
+        }
+    }
+    for _, name := range s.names {
+        user, err := s.lookup(name)
+        _ = user
+    }
+    err = tx.Commit()
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    go func(tx Transaction) {
+        var count int
+        _ = count
+    }(tx)
+    return nil, err
+}
+"#;
+        let findings = find_shadowing(source).unwrap();
+        let found: Vec<(&str, u32, &str, u32, bool)> = findings
+            .iter()
+            .map(|f| {
+                (
+                    f.name.as_str(),
+                    f.shadowing.line,
+                    f.shadowing.scope.as_str(),
+                    f.shadowed.line,
+                    f.read_by_defer,
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                ("err", 15, "block", 4, true),
+                ("name", 20, "for", 3, false),
+                ("err", 21, "block", 4, true),
+                ("tx", 27, "func literal", 4, true),
+            ]
+        );
+        assert!(findings.iter().all(|f| f.function == "CreateUser"));
+        assert!(findings[0].error_variable);
+        assert_eq!(findings[0].shadowed.scope, "function");
+        assert_eq!(findings[1].shadowing.scope, "for");
+    }
+}
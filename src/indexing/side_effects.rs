@@ -0,0 +1,518 @@
+use crate::indexing::type_assertions::PREDECLARED_TYPES;
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeSet, HashSet};
+use tree_sitter::{Node, Parser};
+
+/// Tag on Go functions and methods listing the side effects their own body
+/// shows, comma separated, or `none`
+pub const SIDE_EFFECTS_TAG: &str = "side_effects";
+
+/// Standard library packages whose functions only compute a result
+const PURE_PACKAGES: &[&str] = &[
+    "bits", "bytes", "cmp", "cmplx", "errors", "html", "math", "path", "regexp", "strconv",
+    "strings", "unicode", "url", "utf16", "utf8",
+];
+
+/// Functions computing a result in packages that otherwise do I/O
+const PURE_FUNCTIONS: &[&str] = &[
+    "fmt.Errorf",
+    "fmt.Sprint",
+    "fmt.Sprintf",
+    "fmt.Sprintln",
+    "filepath.Base",
+    "filepath.Clean",
+    "filepath.Dir",
+    "filepath.Ext",
+    "filepath.FromSlash",
+    "filepath.IsAbs",
+    "filepath.Join",
+    "filepath.Match",
+    "filepath.Rel",
+    "filepath.Split",
+    "filepath.ToSlash",
+];
+
+/// Packages whose functions read or write outside the process
+const IO_PACKAGES: &[&str] = &[
+    "bufio", "exec", "fmt", "http", "io", "ioutil", "log", "net", "os", "signal", "slog", "sql",
+    "syscall",
+];
+
+/// Values that usually wrap I/O: a method called on `s.db`, `logger` or `w`
+/// is taken to perform I/O
+const IO_VALUES: &[&str] = &[
+    "client", "conn", "db", "file", "log", "logger", "out", "stderr", "stdout", "tx", "w", "writer",
+];
+
+/// Builtins without effects
+const PURE_BUILTINS: &[&str] = &[
+    "append", "cap", "complex", "imag", "len", "make", "max", "min", "new", "panic", "real",
+    "recover",
+];
+
+/// Builtins writing to their first argument
+const WRITING_BUILTINS: &[&str] = &["clear", "copy", "delete"];
+
+/// A side effect of a function
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SideEffect {
+    /// Calls into fmt printing, log, os, io, net, database/sql and other I/O
+    /// packages, or methods of a value named like a logger, database handle,
+    /// connection, client or writer
+    Io,
+    /// Writes through the receiver: `s.count++`, `s.items[id] = item`
+    ReceiverMutation,
+    /// Writes through a parameter: `u.Name = name`, `*out = v`
+    ArgumentMutation,
+    /// Writes to a variable not declared in the function, normally
+    /// package-level state
+    PackageState,
+    /// Starts a goroutine
+    Goroutine,
+    /// Sends, receives, selects on or closes a channel
+    Channel,
+    /// Calls a function whose effects are not known
+    UnknownCall,
+}
+
+impl SideEffect {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            SideEffect::Io => "io",
+            SideEffect::ReceiverMutation => "receiver_mutation",
+            SideEffect::ArgumentMutation => "argument_mutation",
+            SideEffect::PackageState => "package_state",
+            SideEffect::Goroutine => "goroutine",
+            SideEffect::Channel => "channel",
+            SideEffect::UnknownCall => "unknown_call",
+        }
+    }
+}
+
+/// A call whose effects are those of the function called
+#[derive(Debug, Clone, PartialEq, Eq, Hash, Serialize, Deserialize)]
+pub struct EffectCall {
+    /// The package of `pkg.Name()`; `None` for bare and method calls
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub package: Option<String>,
+    pub name: String,
+    /// A method called on a value, `x.Name()`
+    pub method: bool,
+}
+
+/// The side effects a Go function's body shows
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FunctionEffects {
+    pub function: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub receiver_type: Option<String>,
+    pub line: u32,
+    /// Effects of the body itself, closures inside it included
+    pub effects: BTreeSet<SideEffect>,
+    /// Calls outside the standard library functions known to be pure. The
+    /// function is pure only if they all are.
+    pub calls: Vec<EffectCall>,
+}
+
+impl FunctionEffects {
+    /// The [`SIDE_EFFECTS_TAG`] value: the effects in order, with
+    /// `unknown_call` for any call left to resolve, or `none`
+    pub fn tag_value(&self) -> String {
+        let mut effects = self.effects.clone();
+        if !self.calls.is_empty() {
+            effects.insert(SideEffect::UnknownCall);
+        }
+        if effects.is_empty() {
+            return "none".to_string();
+        }
+        effects
+            .iter()
+            .map(SideEffect::as_str)
+            .collect::<Vec<_>>()
+            .join(",")
+    }
+}
+
+/// The side effects of every function and method declared in a Go source
+/// file, in source order
+pub fn find_function_effects(
+    source: &str,
+) -> Result<Vec<FunctionEffects>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let root = tree.root_node();
+    let mut cursor = root.walk();
+    let functions = root
+        .named_children(&mut cursor)
+        .filter_map(|declaration| function_effects(declaration, source))
+        .collect();
+    Ok(functions)
+}
+
+/// The side effects of one `function_declaration` or `method_declaration`.
+///
+/// The heuristic is conservative. Writes are judged by the variable they
+/// start from: locals are free, parameters and the receiver are mutated
+/// unless reassigned outright, and anything else is package state. Values
+/// reached through a local alias (`u := s.users[0]; u.Name = x`) are not
+/// followed. Calls are effects unless known to be pure.
+pub fn function_effects(function: Node, source: &str) -> Option<FunctionEffects> {
+    if !matches!(
+        function.kind(),
+        "function_declaration" | "method_declaration"
+    ) {
+        return None;
+    }
+    let name = text(function.child_by_field_name("name")?, source)?;
+    let receiver = function
+        .child_by_field_name("receiver")
+        .and_then(|list| list.named_child(0));
+    let receiver_type = receiver
+        .and_then(|receiver| receiver.child_by_field_name("type"))
+        .and_then(|node| text(node, source))
+        .map(|type_name| type_name.trim_start_matches('*').to_string());
+
+    let mut scope = Scope::default();
+    if let Some(name) = receiver
+        .and_then(|receiver| receiver.child_by_field_name("name"))
+        .and_then(|node| text(node, source))
+    {
+        scope.receiver = Some(name);
+    }
+    if let Some(parameters) = function.child_by_field_name("parameters") {
+        declared_names(parameters, source, &mut scope.parameters);
+    }
+    if let Some(result) = function.child_by_field_name("result") {
+        declared_names(result, source, &mut scope.locals);
+    }
+    let body = function.child_by_field_name("body")?;
+    locals(body, source, &mut scope.locals);
+
+    let mut effects = FunctionEffects {
+        function: name,
+        receiver_type,
+        line: function.start_position().row as u32 + 1,
+        ..Default::default()
+    };
+    visit(body, source, &scope, &mut effects);
+    Some(effects)
+}
+
+#[derive(Default)]
+struct Scope {
+    receiver: Option<String>,
+    parameters: HashSet<String>,
+    locals: HashSet<String>,
+}
+
+impl Scope {
+    /// The effect of writing through a variable; `bare` for assigning the
+    /// variable itself
+    fn write(&self, root: &str, bare: bool) -> Option<SideEffect> {
+        if root == "_" || self.locals.contains(root) {
+            None
+        } else if self.receiver.as_deref() == Some(root) {
+            (!bare).then_some(SideEffect::ReceiverMutation)
+        } else if self.parameters.contains(root) {
+            (!bare).then_some(SideEffect::ArgumentMutation)
+        } else {
+            Some(SideEffect::PackageState)
+        }
+    }
+
+    fn declares(&self, name: &str) -> bool {
+        self.receiver.as_deref() == Some(name)
+            || self.parameters.contains(name)
+            || self.locals.contains(name)
+    }
+}
+
+/// Names declared by parameter and result lists
+fn declared_names(list: Node, source: &str, names: &mut HashSet<String>) {
+    let mut cursor = list.walk();
+    for declaration in list.named_children(&mut cursor) {
+        let mut declared = declaration.walk();
+        for name in declaration.children_by_field_name("name", &mut declared) {
+            names.extend(text(name, source));
+        }
+    }
+}
+
+/// Names declared anywhere in a body, closures' parameters included.
+/// Shadowing is ignored.
+fn locals(node: Node, source: &str, names: &mut HashSet<String>) {
+    match node.kind() {
+        "short_var_declaration" | "range_clause" | "receive_statement" => {
+            if let Some(left) = node.child_by_field_name("left") {
+                let mut cursor = left.walk();
+                for name in left.named_children(&mut cursor) {
+                    if name.kind() == "identifier" {
+                        names.extend(text(name, source));
+                    }
+                }
+            }
+        }
+        "var_spec" | "const_spec" => {
+            let mut cursor = node.walk();
+            for name in node.children_by_field_name("name", &mut cursor) {
+                names.extend(text(name, source));
+            }
+        }
+        "type_switch_statement" => {
+            if let Some(alias) = node.child_by_field_name("alias") {
+                let mut cursor = alias.walk();
+                for name in alias.named_children(&mut cursor) {
+                    names.extend(text(name, source));
+                }
+            }
+        }
+        "func_literal" => {
+            if let Some(parameters) = node.child_by_field_name("parameters") {
+                declared_names(parameters, source, names);
+            }
+            if let Some(result) = node.child_by_field_name("result") {
+                declared_names(result, source, names);
+            }
+        }
+        _ => {}
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        locals(child, source, names);
+    }
+}
+
+fn visit(node: Node, source: &str, scope: &Scope, effects: &mut FunctionEffects) {
+    match node.kind() {
+        "go_statement" => {
+            effects.effects.insert(SideEffect::Goroutine);
+        }
+        "send_statement" | "select_statement" => {
+            effects.effects.insert(SideEffect::Channel);
+        }
+        "unary_expression" => {
+            let receive = node
+                .child_by_field_name("operator")
+                .is_some_and(|operator| operator.kind() == "<-");
+            if receive {
+                effects.effects.insert(SideEffect::Channel);
+            }
+        }
+        "assignment_statement" => {
+            if let Some(left) = node.child_by_field_name("left") {
+                let mut cursor = left.walk();
+                for target in left.named_children(&mut cursor) {
+                    write(target, source, scope, effects);
+                }
+            }
+        }
+        "inc_statement" | "dec_statement" => {
+            if let Some(target) = node.named_child(0) {
+                write(target, source, scope, effects);
+            }
+        }
+        "call_expression" => call(node, source, scope, effects),
+        _ => {}
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, scope, effects);
+    }
+}
+
+fn write(target: Node, source: &str, scope: &Scope, effects: &mut FunctionEffects) {
+    // Unknown targets, such as a write through a call result, are effects
+    let effect = match written_root(target, source) {
+        Some((root, bare)) => scope.write(&root, bare),
+        None => Some(SideEffect::PackageState),
+    };
+    effects.effects.extend(effect);
+}
+
+/// The variable an assignment target starts from, and whether the target
+/// is that variable itself: `s` and false for `s.items[i]`
+fn written_root(target: Node, source: &str) -> Option<(String, bool)> {
+    match target.kind() {
+        "identifier" => Some((text(target, source)?, true)),
+        "parenthesized_expression" => written_root(target.named_child(0)?, source),
+        "selector_expression" | "index_expression" => {
+            let (root, _) = written_root(target.child_by_field_name("operand")?, source)?;
+            Some((root, false))
+        }
+        "unary_expression" => {
+            let (root, _) = written_root(target.child_by_field_name("operand")?, source)?;
+            Some((root, false))
+        }
+        _ => None,
+    }
+}
+
+fn call(node: Node, source: &str, scope: &Scope, effects: &mut FunctionEffects) {
+    let Some(callee) = node.child_by_field_name("function") else {
+        return;
+    };
+    match callee.kind() {
+        "identifier" => {
+            let Some(name) = text(callee, source) else {
+                return;
+            };
+            if WRITING_BUILTINS.contains(&name.as_str()) && !scope.declares(&name) {
+                if let Some(target) = node
+                    .child_by_field_name("arguments")
+                    .and_then(|arguments| arguments.named_child(0))
+                {
+                    write(target, source, scope, effects);
+                }
+            } else if name == "close" && !scope.declares(&name) {
+                effects.effects.insert(SideEffect::Channel);
+            } else if matches!(name.as_str(), "print" | "println") && !scope.declares(&name) {
+                effects.effects.insert(SideEffect::Io);
+            } else if !PURE_BUILTINS.contains(&name.as_str())
+                && !PREDECLARED_TYPES.contains(&name.as_str())
+            {
+                effects.calls.push(EffectCall {
+                    package: None,
+                    name,
+                    method: false,
+                });
+            }
+        }
+        "selector_expression" => {
+            let (Some(operand), Some(field)) = (
+                callee.child_by_field_name("operand"),
+                callee
+                    .child_by_field_name("field")
+                    .and_then(|field| text(field, source)),
+            ) else {
+                return;
+            };
+            let package = text(operand, source)
+                .filter(|name| operand.kind() == "identifier" && !scope.declares(name));
+            match package {
+                Some(package) => {
+                    let qualified = format!("{}.{}", package, field);
+                    if PURE_PACKAGES.contains(&package.as_str())
+                        || PURE_FUNCTIONS.contains(&qualified.as_str())
+                    {
+                        return;
+                    }
+                    if IO_PACKAGES.contains(&package.as_str()) {
+                        effects.effects.insert(SideEffect::Io);
+                        return;
+                    }
+                    effects.calls.push(EffectCall {
+                        package: Some(package),
+                        name: field,
+                        method: false,
+                    });
+                }
+                None => {
+                    if is_io_value(operand, source) {
+                        effects.effects.insert(SideEffect::Io);
+                        return;
+                    }
+                    effects.calls.push(EffectCall {
+                        package: None,
+                        name: field,
+                        method: true,
+                    });
+                }
+            }
+        }
+        // An immediately called closure is visited as part of the body
+        "func_literal" => {}
+        _ => {
+            effects.effects.insert(SideEffect::UnknownCall);
+        }
+    }
+}
+
+/// Whether the value a method is called on is named like an I/O handle:
+/// `s.db`, `logger`, `w`
+fn is_io_value(operand: Node, source: &str) -> bool {
+    let name = match operand.kind() {
+        "selector_expression" => operand.child_by_field_name("field"),
+        "identifier" => Some(operand),
+        _ => None,
+    };
+    name.and_then(|name| text(name, source))
+        .is_some_and(|name| {
+            let name = name.to_lowercase();
+            IO_VALUES.contains(&name.as_str())
+                || ["db", "logger", "client", "conn", "writer"]
+                    .iter()
+                    .any(|suffix| name.ends_with(suffix))
+        })
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_function_effects() {
+        let source = r#"package users
+
+var cache = map[string]*User{}
+
+func contains(items []string, item string) bool {
+    for _, candidate := range items {
+        if strings.EqualFold(candidate, item) {
+            return true
+        }
+    }
+    return false
+}
+
+func (s *UserService) CreateUser(ctx context.Context, u *User) error {
+    u.ID = newID()
+    cache[u.ID] = u
+    s.count++
+    s.logger.Info("created", u.ID)
+    go s.notify(u)
+    return s.db.Insert(ctx, u)
+}
+
+func label(u User) string {
+    u = normalize(u)
+    return fmt.Sprintf("%s <%s>", u.Name, u.Email)
+}
+"#;
+        let functions = find_function_effects(source).unwrap();
+        let found: Vec<(&str, String, Vec<&str>)> = functions
+            .iter()
+            .map(|f| {
+                (
+                    f.function.as_str(),
+                    f.tag_value(),
+                    f.calls.iter().map(|call| call.name.as_str()).collect(),
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                ("contains", "none".to_string(), vec![]),
+                (
+                    "CreateUser",
+                    "io,receiver_mutation,argument_mutation,package_state,goroutine,unknown_call"
+                        .to_string(),
+                    vec!["newID", "notify"]
+                ),
+                ("label", "unknown_call".to_string(), vec!["normalize"]),
+            ]
+        );
+        assert_eq!(functions[1].receiver_type.as_deref(), Some("UserService"));
+        assert!(functions[1].calls[1].method);
+    }
+}
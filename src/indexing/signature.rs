@@ -0,0 +1,364 @@
+use crate::models::{ChannelType, DeferredCall, Parameter, Signature};
+use tree_sitter::Node;
+
+/// Extract the signature of a function or method definition node
+pub fn extract_signature(definition: Node, source: &str) -> Option<Signature> {
+    // Lua and Go bind anonymous functions to names: `M.foo = function(a) end`,
+    // `var handler = func(w http.ResponseWriter) {...}`
+    let callable = assigned_function(definition).unwrap_or(definition);
+    let text = signature_text(definition, callable, source)?;
+
+    let receiver = definition
+        .child_by_field_name("receiver")
+        .and_then(|node| node_text(node, source))
+        .map(|text| {
+            text.trim_start_matches('(')
+                .trim_end_matches(')')
+                .trim()
+                .to_string()
+        })
+        .or_else(|| implicit_self(definition, source));
+
+    let type_parameters = definition
+        .child_by_field_name("type_parameters")
+        .map(|node| type_parameter_names(node, source))
+        .unwrap_or_default();
+
+    let parameters = find_parameter_list(callable)
+        .map(|node| {
+            let mut cursor = node.walk();
+            node.named_children(&mut cursor)
+                .flat_map(|param| extract_parameters(param, source))
+                .collect()
+        })
+        .unwrap_or_default();
+
+    let result = ["result", "return_type", "returns", "type"]
+        .iter()
+        .find_map(|field| callable.child_by_field_name(field));
+    let return_channels = result
+        .map(|node| return_channels(node, source))
+        .unwrap_or_default();
+    let return_type = result
+        .and_then(|node| node_text(node, source))
+        .map(|text| {
+            text.trim_start_matches("->")
+                .trim_start_matches(':')
+                .trim()
+                .to_string()
+        })
+        .filter(|text| !text.is_empty());
+
+    let mut deferred = Vec::new();
+    if let Some(body) = find_body(callable) {
+        collect_deferred(body, source, &mut deferred);
+    }
+
+    Some(Signature {
+        text,
+        receiver,
+        type_parameters,
+        parameters,
+        return_type,
+        return_channels,
+        uses_defer: !deferred.is_empty(),
+        deferred,
+    })
+}
+
+/// Go `defer` statements in a body, not descending into closures, whose
+/// defers run when the closure returns
+fn collect_deferred(node: Node, source: &str, deferred: &mut Vec<DeferredCall>) {
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        match child.kind() {
+            "defer_statement" => {
+                if let Some(call) = deferred_call(child, source) {
+                    deferred.push(call);
+                }
+            }
+            "func_literal" => {}
+            _ => collect_deferred(child, source, deferred),
+        }
+    }
+}
+
+fn deferred_call(statement: Node, source: &str) -> Option<DeferredCall> {
+    let call = statement.named_child(0)?;
+    let function = call.child_by_field_name("function")?;
+    let line = statement.start_position().row as u32 + 1;
+    if function.kind() != "func_literal" {
+        return Some(DeferredCall {
+            target: node_text(function, source),
+            closure: false,
+            calls: Vec::new(),
+            line,
+        });
+    }
+
+    let mut calls = Vec::new();
+    let mut stack = vec![function];
+    while let Some(node) = stack.pop() {
+        if node.kind() == "call_expression" {
+            if let Some(callee) = node
+                .child_by_field_name("function")
+                .and_then(|callee| node_text(callee, source))
+            {
+                if !calls.contains(&callee) {
+                    calls.push(callee);
+                }
+            }
+        }
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.named_children(&mut cursor).collect();
+        stack.extend(children.into_iter().rev());
+    }
+    Some(DeferredCall {
+        target: None,
+        closure: true,
+        calls,
+        line,
+    })
+}
+
+/// Go channel results: `<-chan T` alone or inside `(<-chan T, error)`
+fn return_channels(result: Node, source: &str) -> Vec<ChannelType> {
+    if result.kind() != "parameter_list" {
+        return channel_type(result, source).into_iter().collect();
+    }
+    let mut cursor = result.walk();
+    let channels = result
+        .named_children(&mut cursor)
+        .filter_map(|declaration| declaration.child_by_field_name("type"))
+        .filter_map(|node| channel_type(node, source))
+        .collect();
+    channels
+}
+
+fn channel_type(node: Node, source: &str) -> Option<ChannelType> {
+    if node.kind() != "channel_type" {
+        return None;
+    }
+    ChannelType::parse(&node_text(node, source)?)
+}
+
+/// Declaration text up to (not including) the body, whitespace collapsed
+fn signature_text(definition: Node, callable: Node, source: &str) -> Option<String> {
+    let end = find_body(callable)
+        .map(|body| body.start_byte())
+        .unwrap_or_else(|| callable.end_byte());
+    let raw = source.get(definition.start_byte()..end)?;
+
+    let collapsed = raw.split_whitespace().collect::<Vec<_>>().join(" ");
+    let trimmed = collapsed
+        .trim_end_matches(|c: char| c == '{' || c == ':' || c == '=' || c == ';')
+        .trim_end();
+
+    if trimmed.is_empty() {
+        None
+    } else {
+        Some(trimmed.to_string())
+    }
+}
+
+/// The function value of a Lua assignment or table field, or a Go var declaration
+fn assigned_function(definition: Node) -> Option<Node> {
+    let value = match definition.kind() {
+        "assignment_statement" => {
+            let mut cursor = definition.walk();
+            let values = definition
+                .named_children(&mut cursor)
+                .find(|child| child.kind() == "expression_list")?;
+            values.named_child(0)
+        }
+        "field" => definition.child_by_field_name("value"),
+        "var_spec" => definition.child_by_field_name("value")?.named_child(0),
+        _ => None,
+    }?;
+    matches!(value.kind(), "function_definition" | "func_literal").then_some(value)
+}
+
+/// Lua colon methods (`function Account:deposit(v)`) take an implicit `self`
+fn implicit_self(definition: Node, source: &str) -> Option<String> {
+    let name = definition.child_by_field_name("name")?;
+    if name.kind() != "method_index_expression" {
+        return None;
+    }
+    let table = node_text(name.child_by_field_name("table")?, source)?;
+    Some(format!("self {}", table))
+}
+
+fn find_body(definition: Node) -> Option<Node> {
+    if let Some(body) = definition.child_by_field_name("body") {
+        return Some(body);
+    }
+
+    // Grammars without a body field (e.g. Kotlin's function_body)
+    let mut cursor = definition.walk();
+    let body = definition.named_children(&mut cursor).find(|child| {
+        matches!(
+            child.kind(),
+            "function_body" | "block" | "compound_statement"
+        )
+    });
+    body
+}
+
+fn find_parameter_list(node: Node) -> Option<Node> {
+    if let Some(params) = node.child_by_field_name("parameters") {
+        return Some(params);
+    }
+
+    // C/C++ nest the parameter list inside (possibly pointer) declarators
+    if let Some(declarator) = node.child_by_field_name("declarator") {
+        if let Some(params) = find_parameter_list(declarator) {
+            return Some(params);
+        }
+    }
+
+    let mut cursor = node.walk();
+    let params = node.named_children(&mut cursor).find(|child| {
+        matches!(
+            child.kind(),
+            "function_value_parameters" | "formal_parameters" | "parameter_list" | "parameters"
+        )
+    });
+    params
+}
+
+fn type_parameter_names(node: Node, source: &str) -> Vec<String> {
+    let mut names = Vec::new();
+    let mut cursor = node.walk();
+    for param in node.named_children(&mut cursor) {
+        let mut name_cursor = param.walk();
+        let declared: Vec<String> = param
+            .children_by_field_name("name", &mut name_cursor)
+            .filter_map(|name| node_text(name, source))
+            .collect();
+
+        if !declared.is_empty() {
+            names.extend(declared);
+        } else if let Some(first) = param.named_child(0).or(Some(param)) {
+            if let Some(text) = node_text(first, source) {
+                names.push(text);
+            }
+        }
+    }
+    names
+}
+
+fn extract_parameters(param: Node, source: &str) -> Vec<Parameter> {
+    let kind = param.kind();
+    if matches!(
+        kind,
+        "comment" | "line_comment" | "block_comment" | "keyword_separator" | "positional_separator"
+    ) {
+        return Vec::new();
+    }
+
+    let text = node_text(param, source).unwrap_or_default();
+    let variadic = kind.contains("variadic")
+        || kind.contains("splat")
+        || kind.contains("spread")
+        || kind.contains("rest")
+        || text.starts_with("...")
+        || text.starts_with('*');
+    let default_value = default_value(param, source);
+    let optional = kind.contains("default")
+        || kind.contains("optional")
+        || kind == "assignment_pattern"
+        || default_value.is_some();
+
+    let type_node = param.child_by_field_name("type");
+    let type_name = type_node
+        .and_then(|node| node_text(node, source))
+        .map(|text| text.trim_start_matches(':').trim().to_string());
+    let channel = type_node.and_then(|node| channel_type(node, source));
+
+    let mut cursor = param.walk();
+    let mut names: Vec<String> = param
+        .children_by_field_name("name", &mut cursor)
+        .filter_map(|node| node_text(node, source))
+        .collect();
+
+    if names.is_empty() {
+        let name = ["pattern", "declarator", "left"]
+            .iter()
+            .find_map(|field| param.child_by_field_name(field))
+            .or_else(|| {
+                if kind == "identifier" {
+                    Some(param)
+                } else {
+                    let mut cursor = param.walk();
+                    let first_identifier = param
+                        .named_children(&mut cursor)
+                        .find(|child| child.kind().ends_with("identifier"));
+                    first_identifier
+                }
+            })
+            .and_then(|node| node_text(node, source));
+
+        match name {
+            Some(name) => names.push(name),
+            // Receivers like `self`/`&self` carry neither a name nor a type field
+            None if type_name.is_none() && !text.is_empty() => names.push(text.clone()),
+            None => {}
+        }
+    }
+
+    let names: Vec<Option<String>> = if names.is_empty() {
+        vec![None]
+    } else {
+        names
+            .into_iter()
+            .map(|name| {
+                Some(
+                    name.trim_start_matches(|c| c == '*' || c == '&' || c == '.')
+                        .to_string(),
+                )
+            })
+            .collect()
+    };
+
+    names
+        .into_iter()
+        .map(|name| Parameter {
+            name,
+            type_name: type_name.clone(),
+            variadic,
+            optional,
+            default_value: default_value.clone(),
+            channel: channel.clone(),
+        })
+        .collect()
+}
+
+/// The default expression of a parameter: Python `timeout=30`, TypeScript
+/// `retries: number = 3`, JavaScript `(a = 1)`, C# `int port = 80`, PHP
+/// `$mode = 'r'`
+fn default_value(param: Node, source: &str) -> Option<String> {
+    let value = ["default_value", "value"]
+        .iter()
+        .find_map(|field| param.child_by_field_name(field))
+        .or_else(|| {
+            (param.kind() == "assignment_pattern")
+                .then(|| param.child_by_field_name("right"))
+                .flatten()
+        })
+        .or_else(|| {
+            let mut cursor = param.walk();
+            let clause = param
+                .named_children(&mut cursor)
+                .find(|child| child.kind() == "equals_value_clause");
+            clause
+        })?;
+    node_text(value, source)
+        .map(|text| text.trim_start_matches('=').trim().to_string())
+        .filter(|text| !text.is_empty())
+}
+
+fn node_text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
@@ -0,0 +1,509 @@
+use crate::models::{Language, Parameter, Signature};
+use serde::{Deserialize, Serialize};
+
+/// Outcome of comparing two versions of a signature
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Compatibility {
+    Identical,
+    Compatible,
+    Breaking,
+}
+
+/// Classification of a signature change together with the reasons behind it
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SignatureComparison {
+    pub compatibility: Compatibility,
+    pub reasons: Vec<String>,
+}
+
+impl SignatureComparison {
+    fn record(&mut self, compatibility: Compatibility, reason: String) {
+        if compatibility == Compatibility::Breaking
+            || self.compatibility == Compatibility::Identical
+        {
+            self.compatibility = compatibility;
+        }
+        self.reasons.push(reason);
+    }
+}
+
+/// Classify the change from `old` to `new` for callers of the symbol.
+///
+/// Removed, reordered or retyped parameters and changed return types are
+/// breaking; added optional/variadic parameters and widened return types are
+/// compatible. `language` decides whether renaming a parameter breaks keyword
+/// arguments.
+pub fn compare_signatures(
+    old: &Signature,
+    new: &Signature,
+    language: Option<Language>,
+) -> SignatureComparison {
+    let mut comparison = SignatureComparison {
+        compatibility: Compatibility::Identical,
+        reasons: Vec::new(),
+    };
+
+    if old.receiver.as_deref().map(receiver_type) != new.receiver.as_deref().map(receiver_type) {
+        comparison.record(
+            Compatibility::Breaking,
+            format!(
+                "receiver changed from `{}` to `{}`",
+                old.receiver.as_deref().unwrap_or("none"),
+                new.receiver.as_deref().unwrap_or("none")
+            ),
+        );
+    }
+
+    if old.type_parameters.len() != new.type_parameters.len() {
+        comparison.record(
+            Compatibility::Breaking,
+            format!(
+                "type parameter count changed from {} to {}",
+                old.type_parameters.len(),
+                new.type_parameters.len()
+            ),
+        );
+    }
+
+    compare_parameters(&old.parameters, &new.parameters, language, &mut comparison);
+    compare_returns(
+        old.return_type.as_deref(),
+        new.return_type.as_deref(),
+        &mut comparison,
+    );
+
+    if comparison.compatibility == Compatibility::Identical && old.text != new.text {
+        comparison.record(
+            Compatibility::Compatible,
+            "declaration text changed without affecting callers".to_string(),
+        );
+    }
+
+    comparison
+}
+
+fn compare_parameters(
+    old: &[Parameter],
+    new: &[Parameter],
+    language: Option<Language>,
+    comparison: &mut SignatureComparison,
+) {
+    if is_reordering(old, new) {
+        comparison.record(Compatibility::Breaking, "parameters reordered".to_string());
+        return;
+    }
+
+    for (index, (old_param, new_param)) in old.iter().zip(new.iter()).enumerate() {
+        let label = parameter_label(old_param, index);
+        if old_param.type_name != new_param.type_name {
+            comparison.record(
+                Compatibility::Breaking,
+                format!(
+                    "parameter {} type changed from `{}` to `{}`",
+                    label,
+                    old_param.type_name.as_deref().unwrap_or("untyped"),
+                    new_param.type_name.as_deref().unwrap_or("untyped")
+                ),
+            );
+        }
+        if old_param.variadic != new_param.variadic {
+            comparison.record(
+                Compatibility::Breaking,
+                format!("parameter {} variadic changed", label),
+            );
+        }
+        if old_param.optional && !new_param.optional {
+            comparison.record(
+                Compatibility::Breaking,
+                format!("parameter {} is no longer optional", label),
+            );
+        } else if !old_param.optional && new_param.optional {
+            comparison.record(
+                Compatibility::Compatible,
+                format!("parameter {} became optional", label),
+            );
+        }
+        if let (Some(old_default), Some(new_default)) =
+            (&old_param.default_value, &new_param.default_value)
+        {
+            if old_default != new_default {
+                comparison.record(
+                    Compatibility::Compatible,
+                    format!(
+                        "parameter {} default changed from `{}` to `{}`",
+                        label, old_default, new_default
+                    ),
+                );
+            }
+        }
+        if old_param.name != new_param.name {
+            let severity = if has_keyword_arguments(language) {
+                Compatibility::Breaking
+            } else {
+                Compatibility::Compatible
+            };
+            comparison.record(
+                severity,
+                format!(
+                    "parameter {} renamed to `{}`",
+                    label,
+                    new_param.name.as_deref().unwrap_or("_")
+                ),
+            );
+        }
+    }
+
+    for (index, removed) in old.iter().enumerate().skip(new.len()) {
+        comparison.record(
+            Compatibility::Breaking,
+            format!("parameter {} removed", parameter_label(removed, index)),
+        );
+    }
+
+    for (index, added) in new.iter().enumerate().skip(old.len()) {
+        let label = parameter_label(added, index);
+        if added.optional || added.variadic {
+            comparison.record(
+                Compatibility::Compatible,
+                format!("added optional parameter {}", label),
+            );
+        } else {
+            comparison.record(
+                Compatibility::Breaking,
+                format!("added required parameter {}", label),
+            );
+        }
+    }
+}
+
+fn compare_returns(old: Option<&str>, new: Option<&str>, comparison: &mut SignatureComparison) {
+    let old_values = old.map(return_values).unwrap_or_default();
+    let new_values = new.map(return_values).unwrap_or_default();
+
+    if old_values == new_values {
+        return;
+    }
+
+    let old_display = old.unwrap_or("none");
+    let new_display = new.unwrap_or("none");
+
+    if old_values.is_empty() {
+        comparison.record(
+            Compatibility::Compatible,
+            format!("return type added: `{}`", new_display),
+        );
+    } else if new_values.is_empty() {
+        comparison.record(
+            Compatibility::Breaking,
+            format!("return type `{}` removed", old_display),
+        );
+    } else if old_values.len() != new_values.len() {
+        comparison.record(
+            Compatibility::Breaking,
+            format!(
+                "return arity changed from {} to {} (`{}` -> `{}`)",
+                old_values.len(),
+                new_values.len(),
+                old_display,
+                new_display
+            ),
+        );
+    } else if old_values
+        .iter()
+        .zip(new_values.iter())
+        .all(|(old_value, new_value)| is_widened(old_value, new_value))
+    {
+        comparison.record(
+            Compatibility::Compatible,
+            format!(
+                "return type widened from `{}` to `{}`",
+                old_display, new_display
+            ),
+        );
+    } else {
+        comparison.record(
+            Compatibility::Breaking,
+            format!(
+                "return type changed from `{}` to `{}`",
+                old_display, new_display
+            ),
+        );
+    }
+}
+
+/// Same parameters in a different order
+fn is_reordering(old: &[Parameter], new: &[Parameter]) -> bool {
+    if old.len() != new.len() || old.len() < 2 || old == new {
+        return false;
+    }
+    let key = |param: &Parameter| (param.name.clone(), param.type_name.clone());
+    let mut old_keys: Vec<_> = old.iter().map(key).collect();
+    let mut new_keys: Vec<_> = new.iter().map(key).collect();
+    if old_keys == new_keys {
+        return false;
+    }
+    old_keys.sort();
+    new_keys.sort();
+    old_keys == new_keys
+}
+
+/// Split a return type into its values: Go's `(*QueryResult, error)` has two
+pub(crate) fn return_values(return_type: &str) -> Vec<String> {
+    let trimmed = return_type.trim();
+    let inner = match trimmed
+        .strip_prefix('(')
+        .and_then(|rest| rest.strip_suffix(')'))
+    {
+        Some(inner) if is_balanced(inner) => inner,
+        _ => return vec![normalize_type(trimmed)],
+    };
+
+    split_top_level(inner, ',')
+        .into_iter()
+        .map(|value| {
+            // Named Go results `(n int, err error)` compare by type only
+            let value = value.trim();
+            match value.split_once(char::is_whitespace) {
+                Some((name, type_name)) if is_result_name(name) => normalize_type(type_name),
+                _ => normalize_type(value),
+            }
+        })
+        .filter(|value| !value.is_empty())
+        .collect()
+}
+
+/// Whether the first word of a result is its name rather than part of an
+/// unnamed type such as `chan int`, `<-chan int` or `func(int) error`
+fn is_result_name(word: &str) -> bool {
+    !matches!(word, "chan" | "func" | "map" | "struct" | "interface")
+        && word
+            .chars()
+            .next()
+            .is_some_and(|c| c.is_alphabetic() || c == '_')
+        && word.chars().all(|c| c.is_alphanumeric() || c == '_')
+}
+
+/// `new` accepts every value `old` could return
+fn is_widened(old: &str, new: &str) -> bool {
+    if old == new {
+        return true;
+    }
+    if matches!(new, "any" | "interface{}" | "object" | "Object" | "Any") {
+        return true;
+    }
+    let old_members = split_top_level(old, '|');
+    let new_members = split_top_level(new, '|');
+    old_members.iter().all(|member| {
+        new_members
+            .iter()
+            .any(|candidate| candidate.trim() == member.trim())
+    })
+}
+
+fn split_top_level(text: &str, separator: char) -> Vec<String> {
+    let mut parts = Vec::new();
+    let mut depth = 0i32;
+    let mut current = String::new();
+    let mut chars = text.chars().peekable();
+    let mut previous = None;
+    while let Some(c) = chars.next() {
+        // Go channel arrows `<-chan T` and `chan<- T` are not brackets
+        match c {
+            '<' if chars.peek() == Some(&'-') => {}
+            '>' if previous == Some('-') => {}
+            '(' | '[' | '{' | '<' => depth += 1,
+            ')' | ']' | '}' | '>' => depth -= 1,
+            _ => {}
+        }
+        previous = Some(c);
+        if c == separator && depth == 0 {
+            parts.push(current.trim().to_string());
+            current.clear();
+        } else {
+            current.push(c);
+        }
+    }
+    if !current.trim().is_empty() {
+        parts.push(current.trim().to_string());
+    }
+    parts
+}
+
+fn is_balanced(text: &str) -> bool {
+    let mut depth = 0i32;
+    for c in text.chars() {
+        match c {
+            '(' => depth += 1,
+            ')' => depth -= 1,
+            _ => {}
+        }
+        if depth < 0 {
+            return false;
+        }
+    }
+    depth == 0
+}
+
+fn normalize_type(type_name: &str) -> String {
+    type_name.split_whitespace().collect::<Vec<_>>().join(" ")
+}
+
+/// Receiver type without the receiver name: `p *Conn` -> `*Conn`
+/// The type of a receiver such as `p *Conn`: `*Conn`
+pub(crate) fn receiver_type(receiver: &str) -> &str {
+    receiver
+        .rsplit_once(' ')
+        .map(|(_, type_name)| type_name)
+        .unwrap_or(receiver)
+}
+
+fn has_keyword_arguments(language: Option<Language>) -> bool {
+    matches!(
+        language,
+        Some(
+            Language::Python
+                | Language::Kotlin
+                | Language::Swift
+                | Language::CSharp
+                | Language::Scala
+                | Language::PHP
+        )
+    )
+}
+
+fn parameter_label(param: &Parameter, index: usize) -> String {
+    match &param.name {
+        Some(name) => format!("`{}`", name),
+        None => format!("#{}", index + 1),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn param(name: &str, type_name: &str) -> Parameter {
+        Parameter {
+            name: Some(name.to_string()),
+            type_name: Some(type_name.to_string()),
+            variadic: false,
+            optional: false,
+            default_value: None,
+            channel: None,
+        }
+    }
+
+    fn signature(parameters: Vec<Parameter>, return_type: Option<&str>) -> Signature {
+        Signature {
+            text: String::new(),
+            receiver: None,
+            type_parameters: Vec::new(),
+            parameters,
+            return_type: return_type.map(String::from),
+            return_channels: Vec::new(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_go_return_arity_is_breaking() {
+        let params = vec![param("ctx", "context.Context"), param("query", "string")];
+        let old = signature(params.clone(), Some("(*QueryResult, error)"));
+        let new = signature(params, Some("(*QueryResult, bool, error)"));
+
+        let result = compare_signatures(&old, &new, Some(Language::Go));
+        assert_eq!(result.compatibility, Compatibility::Breaking);
+        assert!(result.reasons[0].contains("return arity changed from 2 to 3"));
+
+        let same = compare_signatures(&old, &old, Some(Language::Go));
+        assert_eq!(same.compatibility, Compatibility::Identical);
+        assert!(same.reasons.is_empty());
+    }
+
+    #[test]
+    fn test_go_result_types() {
+        assert_eq!(return_values("(n int, err error)"), vec!["int", "error"]);
+        assert_eq!(
+            return_values("(chan int, error)"),
+            vec!["chan int", "error"]
+        );
+        assert_eq!(
+            return_values("(<-chan int, error)"),
+            vec!["<-chan int", "error"]
+        );
+        assert_eq!(
+            return_values("(map[string] int, func(int) error)"),
+            vec!["map[string] int", "func(int) error"]
+        );
+        assert_eq!(
+            return_values("(events chan<- Event, done <-chan struct{})"),
+            vec!["chan<- Event", "<-chan struct{}"]
+        );
+
+        // A channel losing its send direction is a change callers see
+        let old = signature(Vec::new(), Some("(chan int, error)"));
+        let new = signature(Vec::new(), Some("(<-chan int, error)"));
+        let result = compare_signatures(&old, &new, Some(Language::Go));
+        assert_ne!(result.compatibility, Compatibility::Identical);
+    }
+
+    #[test]
+    fn test_parameter_changes() {
+        let old = signature(vec![param("a", "int"), param("b", "str")], None);
+
+        let reordered = signature(vec![param("b", "str"), param("a", "int")], None);
+        let result = compare_signatures(&old, &reordered, Some(Language::Python));
+        assert_eq!(result.compatibility, Compatibility::Breaking);
+        assert_eq!(result.reasons, vec!["parameters reordered"]);
+
+        let mut optional = param("c", "bool");
+        optional.optional = true;
+        let extended = signature(vec![param("a", "int"), param("b", "str"), optional], None);
+        let result = compare_signatures(&old, &extended, Some(Language::Python));
+        assert_eq!(result.compatibility, Compatibility::Compatible);
+
+        let mut longer = param("b", "str");
+        longer.optional = true;
+        longer.default_value = Some("\"x\"".to_string());
+        let defaulted = signature(vec![param("a", "int"), longer.clone()], None);
+        longer.default_value = Some("\"y\"".to_string());
+        let redefaulted = signature(vec![param("a", "int"), longer], None);
+        let result = compare_signatures(&defaulted, &redefaulted, Some(Language::Python));
+        assert_eq!(result.compatibility, Compatibility::Compatible);
+        assert_eq!(
+            result.reasons,
+            vec!["parameter `b` default changed from `\"x\"` to `\"y\"`"]
+        );
+
+        let removed = signature(vec![param("a", "int")], None);
+        let result = compare_signatures(&old, &removed, Some(Language::Python));
+        assert_eq!(result.compatibility, Compatibility::Breaking);
+
+        // Renaming only breaks languages with keyword arguments
+        let renamed = signature(vec![param("x", "int"), param("b", "str")], None);
+        assert_eq!(
+            compare_signatures(&old, &renamed, Some(Language::Go)).compatibility,
+            Compatibility::Compatible
+        );
+        assert_eq!(
+            compare_signatures(&old, &renamed, Some(Language::Python)).compatibility,
+            Compatibility::Breaking
+        );
+    }
+
+    #[test]
+    fn test_widened_return() {
+        let old = signature(Vec::new(), Some("string"));
+        let new = signature(Vec::new(), Some("string | number"));
+        assert_eq!(
+            compare_signatures(&old, &new, Some(Language::TypeScript)).compatibility,
+            Compatibility::Compatible
+        );
+        assert_eq!(
+            compare_signatures(&new, &old, Some(Language::TypeScript)).compatibility,
+            Compatibility::Breaking
+        );
+    }
+}
@@ -0,0 +1,321 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Words that can follow FROM, INTO, UPDATE or JOIN without naming a table
+const NOT_TABLES: &[&str] = &[
+    "select", "set", "where", "values", "lateral", "only", "as", "on", "using", "default",
+];
+
+/// Longest query text returned; longer queries are cut at a word boundary
+const MAX_QUERY_CHARS: usize = 200;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SqlOperation {
+    Select,
+    Insert,
+    Update,
+    Delete,
+}
+
+impl SqlOperation {
+    pub const NAMES: &'static [&'static str] = &["select", "insert", "update", "delete"];
+
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.to_ascii_lowercase().as_str() {
+            "select" => Some(SqlOperation::Select),
+            "insert" => Some(SqlOperation::Insert),
+            "update" => Some(SqlOperation::Update),
+            "delete" => Some(SqlOperation::Delete),
+            _ => None,
+        }
+    }
+}
+
+/// A string literal that reads as a SQL statement
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SqlQuery {
+    pub operation: SqlOperation,
+    /// Tables named after FROM, INTO, UPDATE and JOIN, as written
+    pub tables: Vec<String>,
+    pub line: u32,
+    /// The statement, whitespace collapsed
+    pub query: String,
+}
+
+/// Find SQL statements embedded as string literals. Parsing is lenient: a
+/// literal counts when it starts with SELECT, INSERT INTO, UPDATE ... SET,
+/// DELETE FROM or WITH and names at least one table. Queries assembled from
+/// several literals are only seen through the literal that holds the verb.
+pub fn find_sql_queries(
+    source: &str,
+    language: Language,
+) -> Result<Vec<SqlQuery>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut queries = Vec::new();
+    visit(tree.root_node(), source, &mut queries);
+    Ok(queries)
+}
+
+fn visit(node: Node, source: &str, queries: &mut Vec<SqlQuery>) {
+    if node.kind().contains("string") {
+        // A string on its own is a docstring or directive, never a query
+        let statement = node
+            .parent()
+            .is_some_and(|parent| parent.kind() == "expression_statement");
+        if !statement {
+            if let Some(query) = node
+                .utf8_text(source.as_bytes())
+                .ok()
+                .and_then(|text| parse_sql(literal_content(text)))
+            {
+                queries.push(SqlQuery {
+                    line: node.start_position().row as u32 + 1,
+                    ..query
+                });
+            }
+        }
+        return;
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, queries);
+    }
+}
+
+/// The text of a string literal without its prefix and quotes:
+/// `r#"..."#`, `@"..."`, `f'...'`, `` `...` ``
+pub(crate) fn literal_content(text: &str) -> &str {
+    text.trim_start_matches(|c: char| c.is_ascii_alphabetic() || c == '@' || c == '$')
+        .trim_matches(|c: char| matches!(c, '"' | '\'' | '`' | '#'))
+}
+
+/// Read a SQL statement: its operation and the tables it names. `None`
+/// when the text does not look like SQL.
+pub fn parse_sql(text: &str) -> Option<SqlQuery> {
+    let tokens = tokenize(text);
+    let keyword = |index: usize| {
+        tokens
+            .get(index)
+            .map(|token| token.to_ascii_lowercase())
+            .unwrap_or_default()
+    };
+    let has = |word: &str| tokens.iter().any(|token| token.eq_ignore_ascii_case(word));
+
+    let operation = match keyword(0).as_str() {
+        "select" if has("from") => SqlOperation::Select,
+        "insert" if keyword(1) == "into" => SqlOperation::Insert,
+        "update" if has("set") => SqlOperation::Update,
+        "delete" if keyword(1) == "from" => SqlOperation::Delete,
+        // `WITH recent AS (SELECT ...) DELETE FROM ...`: the statement after the CTEs
+        "with" => {
+            let mut depth = 0usize;
+            let mut main = None;
+            for token in &tokens[1..] {
+                match token.as_str() {
+                    "(" => depth += 1,
+                    ")" => depth = depth.saturating_sub(1),
+                    _ if depth == 0 => {
+                        if let Some(operation) = SqlOperation::from_name(token) {
+                            main = Some(operation);
+                            break;
+                        }
+                    }
+                    _ => {}
+                }
+            }
+            main?
+        }
+        _ => return None,
+    };
+
+    let mut tables: Vec<String> = Vec::new();
+    for (index, token) in tokens.iter().enumerate() {
+        let introduces = match token.to_ascii_lowercase().as_str() {
+            "from" | "into" | "join" => true,
+            // Not `ON DUPLICATE KEY UPDATE` or `ON CONFLICT ... DO UPDATE`
+            "update" => !(index > 0 && matches!(keyword(index - 1).as_str(), "key" | "do")),
+            _ => false,
+        };
+        if !introduces {
+            continue;
+        }
+        let Some(table) = tokens.get(index + 1).and_then(|next| table_name(next)) else {
+            continue;
+        };
+        // CTE names are not tables
+        let cte = tokens.windows(3).any(|w| {
+            w[0].eq_ignore_ascii_case(&table) && w[1].eq_ignore_ascii_case("as") && w[2] == "("
+        });
+        if !cte
+            && !tables
+                .iter()
+                .any(|existing| existing.eq_ignore_ascii_case(&table))
+        {
+            tables.push(table);
+        }
+    }
+    if tables.is_empty() {
+        return None;
+    }
+
+    Some(SqlQuery {
+        operation,
+        tables,
+        line: 0,
+        query: truncate(&text.split_whitespace().collect::<Vec<_>>().join(" ")),
+    })
+}
+
+/// Whether `table` as written in a query is `name`, ignoring case, quotes
+/// and, when `name` is unqualified, the schema: `public.users` is `users`.
+pub fn table_matches(table: &str, name: &str) -> bool {
+    table.eq_ignore_ascii_case(name)
+        || (!name.contains('.')
+            && table
+                .rsplit('.')
+                .next()
+                .is_some_and(|last| last.eq_ignore_ascii_case(name)))
+}
+
+/// Words, with parentheses, commas and semicolons as tokens of their own
+fn tokenize(text: &str) -> Vec<String> {
+    let mut tokens = Vec::new();
+    let mut current = String::new();
+    for c in text.chars() {
+        if c.is_whitespace() || matches!(c, '(' | ')' | ',' | ';') {
+            if !current.is_empty() {
+                tokens.push(std::mem::take(&mut current));
+            }
+            if !c.is_whitespace() {
+                tokens.push(c.to_string());
+            }
+        } else {
+            current.push(c);
+        }
+    }
+    if !current.is_empty() {
+        tokens.push(current);
+    }
+    tokens
+}
+
+/// A token as a table name, without quoting: `"users"`, `` `users` ``,
+/// `[dbo].[users]`. Placeholders, subqueries and keywords are not tables.
+fn table_name(token: &str) -> Option<String> {
+    let name: String = token
+        .chars()
+        .filter(|c| !matches!(c, '"' | '`' | '[' | ']' | '\''))
+        .collect();
+    let valid = name.starts_with(|c: char| c.is_alphabetic() || c == '_')
+        && name
+            .chars()
+            .all(|c| c.is_alphanumeric() || c == '_' || c == '.');
+    if !valid || NOT_TABLES.contains(&name.to_ascii_lowercase().as_str()) {
+        return None;
+    }
+    Some(name)
+}
+
+fn truncate(query: &str) -> String {
+    if query.chars().count() <= MAX_QUERY_CHARS {
+        return query.to_string();
+    }
+    let cut: String = query.chars().take(MAX_QUERY_CHARS).collect();
+    let cut = cut.rsplit_once(' ').map_or(cut.as_str(), |(head, _)| head);
+    format!("{}...", cut)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn summary(text: &str) -> Option<(SqlOperation, Vec<String>)> {
+        parse_sql(text).map(|query| (query.operation, query.tables))
+    }
+
+    #[test]
+    fn test_parse_sql() {
+        assert_eq!(
+            summary("INSERT INTO users (id, username, email) VALUES ($1, $2, $3)"),
+            Some((SqlOperation::Insert, vec!["users".to_string()]))
+        );
+        assert_eq!(
+            summary(
+                "SELECT u.id, o.total FROM users u\n JOIN \"orders\" o ON o.user_id = u.id WHERE u.id = ?"
+            ),
+            Some((
+                SqlOperation::Select,
+                vec!["users".to_string(), "orders".to_string()]
+            ))
+        );
+        assert_eq!(
+            summary("update Users set email = $1 where id = $2"),
+            Some((SqlOperation::Update, vec!["Users".to_string()]))
+        );
+        assert_eq!(
+            summary("DELETE FROM public.sessions WHERE expires_at < now()"),
+            Some((SqlOperation::Delete, vec!["public.sessions".to_string()]))
+        );
+        assert_eq!(
+            summary(
+                "WITH stale AS (SELECT id FROM sessions) DELETE FROM tokens WHERE session_id IN (SELECT id FROM stale)"
+            ),
+            Some((
+                SqlOperation::Delete,
+                vec!["sessions".to_string(), "tokens".to_string()]
+            ))
+        );
+        assert_eq!(
+            summary("INSERT INTO users (id) VALUES (?) ON DUPLICATE KEY UPDATE id = id"),
+            Some((SqlOperation::Insert, vec!["users".to_string()]))
+        );
+        assert_eq!(summary("update the cache"), None);
+        assert_eq!(summary("SELECT 1"), None);
+        assert_eq!(summary("user not found"), None);
+    }
+
+    #[test]
+    fn test_table_matches() {
+        assert!(table_matches("users", "USERS"));
+        assert!(table_matches("public.users", "users"));
+        assert!(table_matches("public.users", "public.users"));
+        assert!(!table_matches("users", "public.users"));
+        assert!(!table_matches("user_roles", "users"));
+    }
+
+    #[test]
+    fn test_go_queries() {
+        let source = r#"package store
+
+func (r *Repo) CreateUser(u *User) error {
+    _, err := r.db.Exec(`INSERT INTO users (username, email)
+        VALUES ($1, $2)`, u.Username, u.Email)
+    return err
+}
+
+func (r *Repo) GetUser(id string) (*User, error) {
+    row := r.db.QueryRow("SELECT id, username FROM users WHERE id = $1", id)
+    return scan(row)
+}
+
+func greet() string { return "hello from the other side" }
+"#;
+        let queries = find_sql_queries(source, Language::Go).unwrap();
+        let found: Vec<(SqlOperation, u32)> =
+            queries.iter().map(|q| (q.operation, q.line)).collect();
+        assert_eq!(
+            found,
+            vec![(SqlOperation::Insert, 4), (SqlOperation::Select, 10)]
+        );
+        assert_eq!(
+            queries[0].query,
+            "INSERT INTO users (username, email) VALUES ($1, $2)"
+        );
+    }
+}
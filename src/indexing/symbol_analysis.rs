@@ -0,0 +1,383 @@
+use crate::indexing::tags::doc_comment;
+use crate::models::{Language, Location};
+use tree_sitter::{Node, Parser, Point};
+
+/// Node kinds that add a branch to a function's control flow
+const DECISION_KINDS: &[&str] = &[
+    "if_statement",
+    "if_expression",
+    "elif_clause",
+    "else_if_clause",
+    "for_statement",
+    "for_expression",
+    "for_in_statement",
+    "enhanced_for_statement",
+    "while_statement",
+    "while_expression",
+    "do_statement",
+    "loop_expression",
+    "expression_case",
+    "type_case",
+    "communication_case",
+    "case_clause",
+    "switch_case",
+    "switch_label",
+    "match_arm",
+    "catch_clause",
+    "except_clause",
+    "rescue",
+    "conditional_expression",
+    "ternary_expression",
+];
+
+/// Node kinds that call a function
+pub(crate) const CALL_KINDS: &[&str] = &[
+    "call_expression",
+    "call",
+    "method_invocation",
+    "invocation_expression",
+    "function_call_expression",
+    "member_call_expression",
+    "function_call",
+];
+
+/// Parents of a Go selector that pass it on as a value instead of reading a
+/// field or calling it: arguments, right-hand sides, results, literal fields
+const VALUE_CONTEXTS: &[&str] = &[
+    "argument_list",
+    "expression_list",
+    "literal_element",
+    "keyed_element",
+    "send_statement",
+];
+
+/// Node kinds that raise an exception
+const THROW_KINDS: &[&str] = &["raise_statement", "throw_statement", "throw_expression"];
+
+/// Facts read from a symbol's definition that the index does not store
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct DefinitionAnalysis {
+    pub doc_comment: Option<String>,
+    /// Names of functions and methods called in the body, in order of first use
+    pub callees: Vec<String>,
+    /// Type names mentioned in the signature or body, in order of first use
+    pub referenced_types: Vec<String>,
+    /// Errors the definition can produce: thrown/raised expressions, Go
+    /// `errors.New`/`fmt.Errorf` calls and `Err...` sentinels, Rust `Err(..)`
+    pub possible_errors: Vec<String>,
+    /// McCabe complexity: one plus the number of branch points
+    pub cyclomatic_complexity: u32,
+}
+
+/// Parse `source` and analyze the definition spanning `location`
+pub fn analyze_definition(
+    source: &str,
+    language: Language,
+    location: &Location,
+) -> Option<DefinitionAnalysis> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language()).ok()?;
+    let tree = parser.parse(source, None)?;
+    let definition = find_definition(tree.root_node(), location)?;
+    Some(analyze_node(definition, source))
+}
+
+/// The outermost node covering exactly the span recorded for a symbol
+pub fn find_definition<'t>(root: Node<'t>, location: &Location) -> Option<Node<'t>> {
+    let start = Point::new(
+        location.start_line.saturating_sub(1) as usize,
+        location.start_column as usize,
+    );
+    let end = Point::new(
+        location.end_line.saturating_sub(1) as usize,
+        location.end_column as usize,
+    );
+
+    let mut node = root.descendant_for_point_range(start, end)?;
+    while let Some(parent) = node.parent() {
+        if parent.start_position() != start || parent.end_position() != end {
+            break;
+        }
+        node = parent;
+    }
+    Some(node)
+}
+
+pub fn analyze_node(definition: Node, source: &str) -> DefinitionAnalysis {
+    let mut analysis = DefinitionAnalysis {
+        doc_comment: doc_comment(definition, source),
+        cyclomatic_complexity: 1,
+        ..Default::default()
+    };
+    visit(definition, source, &mut analysis);
+    analysis
+}
+
+/// Callee expressions of every call in a definition as written, qualifier
+/// included (`s.db.Save`, `fmt.Errorf`, `helper`), in order of first use
+pub fn calls(definition: Node, source: &str) -> Vec<String> {
+    let mut calls = Vec::new();
+    collect_calls(definition, source, &mut calls);
+    calls
+}
+
+/// Go method values and method expressions a definition passes around
+/// without calling them, as written: `s.less` in `sort.Slice(xs, s.less)`,
+/// `(*Conn).Close` in `close := (*Conn).Close`. Field reads in the same
+/// positions are included; resolving against indexed methods tells them apart.
+pub fn method_values(definition: Node, source: &str) -> Vec<String> {
+    let mut values = Vec::new();
+    collect_method_values(definition, source, &mut values);
+    values
+}
+
+fn collect_method_values(node: Node, source: &str, values: &mut Vec<String>) {
+    if node.kind() == "selector_expression"
+        && node
+            .parent()
+            .is_some_and(|parent| VALUE_CONTEXTS.contains(&parent.kind()))
+    {
+        if let Some(value) = text(node, source) {
+            push_unique(values, value);
+        }
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_method_values(child, source, values);
+    }
+}
+
+/// Whether `name` is what a call invokes, directly or as the last step of a
+/// member access: `save` in `save(u)`, `Save` in `s.db.Save(u)`
+pub fn is_callee(name: Node) -> bool {
+    let mut callee = name;
+    if let Some(parent) = name.parent() {
+        let last = parent
+            .named_child(parent.named_child_count().saturating_sub(1))
+            .is_some_and(|last| last.id() == name.id());
+        if last && !CALL_KINDS.contains(&parent.kind()) && parent.named_child_count() > 1 {
+            callee = parent;
+        }
+    }
+    let Some(call) = callee
+        .parent()
+        .filter(|call| CALL_KINDS.contains(&call.kind()))
+    else {
+        return false;
+    };
+    ["function", "name", "method"]
+        .iter()
+        .find_map(|field| call.child_by_field_name(field))
+        .is_some_and(|function| function.id() == callee.id() || function.id() == name.id())
+}
+
+/// Whether `name` is the type a composite literal builds: `User` in
+/// `User{}`, `&models.User{}` or `Page[User]{}`
+pub fn is_constructed(name: Node) -> bool {
+    if name.kind() != "type_identifier" {
+        return false;
+    }
+    let mut current = name;
+    while let Some(parent) = current.parent() {
+        let field = match parent.kind() {
+            "qualified_type" => "name",
+            "generic_type" => "type",
+            "composite_literal" => {
+                return parent
+                    .child_by_field_name("type")
+                    .is_some_and(|literal_type| literal_type.id() == current.id());
+            }
+            _ => return false,
+        };
+        if parent
+            .child_by_field_name(field)
+            .is_none_or(|child| child.id() != current.id())
+        {
+            return false;
+        }
+        current = parent;
+    }
+    false
+}
+
+fn collect_calls(node: Node, source: &str, calls: &mut Vec<String>) {
+    if CALL_KINDS.contains(&node.kind()) {
+        if let Some(callee) = callee_name(node, source) {
+            push_unique(calls, callee);
+        }
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_calls(child, source, calls);
+    }
+}
+
+fn visit(node: Node, source: &str, analysis: &mut DefinitionAnalysis) {
+    let kind = node.kind();
+
+    if DECISION_KINDS.contains(&kind) || is_short_circuit(node, source) {
+        analysis.cyclomatic_complexity += 1;
+    }
+
+    if kind == "type_identifier" {
+        if let Some(name) = text(node, source) {
+            push_unique(&mut analysis.referenced_types, name);
+        }
+    }
+
+    if CALL_KINDS.contains(&kind) {
+        if let Some(callee) = callee_name(node, source) {
+            match callee.as_str() {
+                "errors.New" | "fmt.Errorf" | "Err" => {
+                    if let Some(call) = text(node, source) {
+                        push_unique(&mut analysis.possible_errors, call);
+                    }
+                }
+                _ => {}
+            }
+            let short = callee.rsplit(['.', ':']).next().unwrap_or(&callee);
+            push_unique(&mut analysis.callees, short.to_string());
+        }
+    }
+
+    if THROW_KINDS.contains(&kind) {
+        if let Some(thrown) = node.named_child(0).and_then(|n| text(n, source)) {
+            push_unique(&mut analysis.possible_errors, thrown);
+        }
+    }
+
+    // Go sentinel errors returned directly: `return nil, ErrNotFound`
+    if kind == "return_statement" {
+        let mut cursor = node.walk();
+        for value in node.named_children(&mut cursor) {
+            let mut values = value.walk();
+            let candidates: Vec<Node> = if value.kind() == "expression_list" {
+                value.named_children(&mut values).collect()
+            } else {
+                vec![value]
+            };
+            for candidate in candidates {
+                if let Some(name) = text(candidate, source) {
+                    if is_error_sentinel(&name) {
+                        push_unique(&mut analysis.possible_errors, name);
+                    }
+                }
+            }
+        }
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, analysis);
+    }
+}
+
+/// Go naming convention for sentinel errors: `ErrNotFound`, `sql.ErrNoRows`
+pub fn is_error_sentinel(name: &str) -> bool {
+    let last = name.rsplit('.').next().unwrap_or(name);
+    last.len() > 3
+        && last.starts_with("Err")
+        && last[3..].starts_with(|c: char| c.is_ascii_uppercase())
+}
+
+fn is_short_circuit(node: Node, source: &str) -> bool {
+    if !matches!(node.kind(), "binary_expression" | "boolean_operator") {
+        return false;
+    }
+    node.child_by_field_name("operator")
+        .and_then(|op| op.utf8_text(source.as_bytes()).ok())
+        .is_some_and(|op| matches!(op, "&&" | "||" | "and" | "or"))
+}
+
+fn callee_name(call: Node, source: &str) -> Option<String> {
+    let callee = ["function", "name", "method"]
+        .iter()
+        .find_map(|field| call.child_by_field_name(field))
+        .or_else(|| call.named_child(0))?;
+    let mut name = text(callee, source)?;
+
+    // Java and Ruby keep the receiver in its own field: `repo.save(user)`
+    if let Some(object) = ["object", "receiver"]
+        .iter()
+        .find_map(|field| call.child_by_field_name(field))
+        .filter(|object| object.id() != callee.id())
+    {
+        name = format!("{}.{}", text(object, source)?, name);
+    }
+
+    // Chained calls keep only the last step: `a.Get().Close` -> `().Close`
+    if let Some(end) = name.rfind(')') {
+        name = format!("(){}", &name[end + 1..]);
+    }
+
+    // Drop generic instantiation: `Map[K, V]` -> `Map`, `parse::<T>` -> `parse`
+    let name = name
+        .split(['[', '<'])
+        .next()
+        .unwrap_or(&name)
+        .trim_end_matches("::")
+        .to_string();
+    (!name.is_empty()).then_some(name)
+}
+
+fn push_unique(items: &mut Vec<String>, item: String) {
+    if !items.contains(&item) {
+        items.push(item);
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::path::PathBuf;
+
+    #[test]
+    fn test_error_sentinels() {
+        assert!(is_error_sentinel("ErrNotFound"));
+        assert!(is_error_sentinel("sql.ErrNoRows"));
+        assert!(!is_error_sentinel("Errors"));
+        assert!(!is_error_sentinel("err"));
+    }
+
+    #[test]
+    fn test_analyze_go_function() {
+        let source = r#"package users
+
+// CreateUser validates and stores a user.
+func (s *UserService) CreateUser(name string) (*User, error) {
+    if name == "" || len(name) > 64 {
+        return nil, ErrInvalidName
+    }
+    user := NewUser(name)
+    if err := s.db.Save(user); err != nil {
+        return nil, fmt.Errorf("save user: %w", err)
+    }
+    return user, nil
+}
+"#;
+        let location = Location::new(PathBuf::from("users.go"), 4, 0, 13, 1);
+        let analysis = analyze_definition(source, Language::Go, &location).unwrap();
+
+        assert_eq!(
+            analysis.doc_comment.as_deref(),
+            Some("// CreateUser validates and stores a user.")
+        );
+        assert_eq!(analysis.cyclomatic_complexity, 4);
+        assert!(analysis.callees.contains(&"NewUser".to_string()));
+        assert!(analysis.callees.contains(&"Save".to_string()));
+        assert!(analysis.referenced_types.contains(&"User".to_string()));
+        assert_eq!(
+            analysis.possible_errors,
+            vec![
+                "ErrInvalidName".to_string(),
+                "fmt.Errorf(\"save user: %w\", err)".to_string()
+            ]
+        );
+    }
+}
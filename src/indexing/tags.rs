@@ -0,0 +1,196 @@
+use crate::indexing::settings::setting_var;
+use std::collections::BTreeMap;
+use tree_sitter::Node;
+
+/// Tag keys recognized when ROBERTO_TAG_KEYS is not set
+const DEFAULT_TAG_KEYS: &str = "owner,stability";
+
+/// Declaration wrappers whose leading comment documents the symbol inside them
+const WRAPPER_KINDS: &[&str] = &[
+    "type_declaration",
+    "const_declaration",
+    "var_declaration",
+    "export_statement",
+    "decorated_definition",
+    "lexical_declaration",
+    "variable_declaration",
+];
+
+/// Structured `@key: value` tags recognized in doc comments, configured through
+/// ROBERTO_TAG_KEYS as a comma separated list; `*` accepts any key
+#[derive(Debug, Clone, PartialEq)]
+pub struct TagKeys {
+    keys: Vec<String>,
+    any: bool,
+}
+
+impl Default for TagKeys {
+    fn default() -> Self {
+        Self::parse(DEFAULT_TAG_KEYS)
+    }
+}
+
+impl TagKeys {
+    pub fn from_env() -> Self {
+        setting_var("ROBERTO_TAG_KEYS")
+            .map(|spec| Self::parse(&spec))
+            .unwrap_or_default()
+    }
+
+    pub fn parse(spec: &str) -> Self {
+        let mut keys: Vec<String> = spec
+            .split(',')
+            .map(|key| key.trim().trim_start_matches('@').to_lowercase())
+            .filter(|key| !key.is_empty())
+            .collect();
+        keys.sort();
+        keys.dedup();
+
+        let any = keys.iter().any(|key| key == "*");
+        keys.retain(|key| key != "*");
+        Self { keys, any }
+    }
+
+    pub fn accepts(&self, key: &str) -> bool {
+        self.any || self.keys.iter().any(|k| k == key)
+    }
+
+    /// Canonical form of the configuration, persisted with the cache
+    pub fn fingerprint(&self) -> String {
+        if self.any {
+            "*".to_string()
+        } else {
+            self.keys.join(",")
+        }
+    }
+
+    /// Tags from the doc comment attached to a definition node
+    pub fn extract(&self, definition: Node, source: &str) -> BTreeMap<String, String> {
+        if !self.any && self.keys.is_empty() {
+            return BTreeMap::new();
+        }
+        doc_comment(definition, source)
+            .map(|comment| self.parse_tags(&comment))
+            .unwrap_or_default()
+    }
+
+    /// Collect recognized `@key: value` tags, one per line; later values win
+    pub fn parse_tags(&self, comment: &str) -> BTreeMap<String, String> {
+        let mut tags = BTreeMap::new();
+        for line in comment.lines() {
+            if let Some((key, value)) = parse_tag_line(line) {
+                if self.accepts(&key) {
+                    tags.insert(key, value);
+                }
+            }
+        }
+        tags
+    }
+}
+
+/// `// @owner: payments-team` -> (`owner`, `payments-team`)
+fn parse_tag_line(line: &str) -> Option<(String, String)> {
+    let (_, rest) = line.split_once('@')?;
+    let key_len = rest
+        .find(|c: char| !(c.is_alphanumeric() || c == '_' || c == '-'))
+        .unwrap_or(rest.len());
+    if key_len == 0 {
+        return None;
+    }
+
+    let (key, after) = rest.split_at(key_len);
+    let value = after.trim_start().strip_prefix(':')?;
+    let value = value.trim().trim_end_matches("*/").trim();
+    if value.is_empty() {
+        return None;
+    }
+    Some((key.to_lowercase(), value.to_string()))
+}
+
+/// Comments directly above a definition (or its declaration wrapper), plus
+/// Python docstrings
+pub fn doc_comment(definition: Node, source: &str) -> Option<String> {
+    let mut node = definition;
+    loop {
+        if let Some(comment) = leading_comments(node, source) {
+            return Some(comment);
+        }
+        match node.parent() {
+            Some(parent) if WRAPPER_KINDS.contains(&parent.kind()) => node = parent,
+            _ => break,
+        }
+    }
+    docstring(definition, source)
+}
+
+fn leading_comments(node: Node, source: &str) -> Option<String> {
+    let mut lines = Vec::new();
+    let mut next_row = node.start_position().row;
+    let mut current = node.prev_sibling();
+
+    while let Some(sibling) = current {
+        let kind = sibling.kind();
+        if kind.contains("comment") {
+            // Only comments touching the definition (or the comment below them)
+            if sibling.end_position().row + 1 < next_row {
+                break;
+            }
+            lines.push(sibling.utf8_text(source.as_bytes()).ok()?.to_string());
+            next_row = sibling.start_position().row;
+        } else if !matches!(kind, "attribute_item" | "attribute" | "decorator") {
+            break;
+        }
+        current = sibling.prev_sibling();
+    }
+
+    if lines.is_empty() {
+        return None;
+    }
+    lines.reverse();
+    Some(lines.join("\n"))
+}
+
+fn docstring(definition: Node, source: &str) -> Option<String> {
+    let body = definition.child_by_field_name("body")?;
+    let first = body.named_child(0)?;
+    if first.kind() != "expression_statement" {
+        return None;
+    }
+    let string = first.named_child(0)?;
+    if string.kind() != "string" {
+        return None;
+    }
+    string.utf8_text(source.as_bytes()).ok().map(String::from)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_tags() {
+        let keys = TagKeys::default();
+        let comment = "// CreateUser stores a user\n// @owner: payments-team\n// @stability: experimental\n// @since: 1.2";
+        let tags = keys.parse_tags(comment);
+        assert_eq!(tags.len(), 2);
+        assert_eq!(tags["owner"], "payments-team");
+        assert_eq!(tags["stability"], "experimental");
+
+        let block = "/**\n * @Owner: core\n * Contact: core@example.com\n */";
+        assert_eq!(keys.parse_tags(block)["owner"], "core");
+
+        assert!(keys.parse_tags("// plain comment").is_empty());
+        assert!(keys.parse_tags("/** @param id the user id */").is_empty());
+    }
+
+    #[test]
+    fn test_configured_keys() {
+        let keys = TagKeys::parse("team, @since");
+        assert!(keys.accepts("since"));
+        assert!(!keys.accepts("owner"));
+        assert_eq!(keys.fingerprint(), "since,team");
+
+        let any = TagKeys::parse("*");
+        assert_eq!(any.parse_tags("# @since: 1.2")["since"], "1.2");
+    }
+}
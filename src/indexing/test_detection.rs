@@ -0,0 +1,136 @@
+use crate::models::Language;
+use std::path::Path;
+use tree_sitter::Node;
+
+/// Go test function prefixes recognised by `go test`
+const GO_TEST_PREFIXES: &[&str] = &["Test", "Benchmark", "Fuzz", "Example"];
+
+/// Check whether a function definition is a test
+pub fn is_test_function(
+    name: &str,
+    definition: Node,
+    source: &str,
+    file_path: &Path,
+    language: Language,
+) -> bool {
+    match language {
+        Language::Go => is_test_file(file_path) && go_test_stem(name).is_some(),
+        Language::Python => is_test_file(file_path) && name.starts_with("test"),
+        Language::Rust => has_rust_test_attribute(definition, source),
+        _ => false,
+    }
+}
+
+/// Check whether a file holds tests by naming convention (`_test.go`, `test_*.py`, `*_test.py`)
+pub fn is_test_file(file_path: &Path) -> bool {
+    let file_name = file_path
+        .file_name()
+        .map(|name| name.to_string_lossy().to_string())
+        .unwrap_or_default();
+
+    if file_name.ends_with("_test.go") {
+        return true;
+    }
+    match file_name.strip_suffix(".py") {
+        Some(stem) => stem.starts_with("test_") || stem.ends_with("_test"),
+        None => false,
+    }
+}
+
+/// Names of the symbols a test appears to cover, most specific first.
+///
+/// `TestCreateUser` → `CreateUser`, `TestUserService_CreateUser` → `CreateUser`
+/// and `UserService`, `test_create_user` → `create_user`.
+pub fn test_target_names(test_name: &str) -> Vec<String> {
+    let stem = go_test_stem(test_name)
+        .or_else(|| test_name.strip_prefix("test_"))
+        .or_else(|| test_name.strip_prefix("test"))
+        .unwrap_or(test_name)
+        .trim_matches('_');
+
+    if stem.is_empty() {
+        return Vec::new();
+    }
+
+    let mut targets = vec![stem.to_string()];
+    let parts: Vec<&str> = stem.split('_').filter(|part| !part.is_empty()).collect();
+    if parts.len() > 1
+        && !stem
+            .chars()
+            .all(|c| c.is_lowercase() || c == '_' || c.is_numeric())
+    {
+        // Go style `TestType_Method_scenario`
+        targets.push(parts[1].to_string());
+        targets.push(parts[0].to_string());
+    } else if parts.len() > 1 {
+        // Python style `test_create_user_invalid_email`: try shorter prefixes
+        for end in (1..parts.len()).rev() {
+            targets.push(parts[..end].join("_"));
+        }
+    }
+
+    targets.dedup();
+    targets
+}
+
+/// Check whether `test_name` covers the symbol `target_name`
+pub fn test_covers(test_name: &str, target_name: &str) -> bool {
+    test_target_names(test_name)
+        .iter()
+        .any(|candidate| candidate.eq_ignore_ascii_case(target_name))
+}
+
+fn go_test_stem(name: &str) -> Option<&str> {
+    GO_TEST_PREFIXES.iter().find_map(|prefix| {
+        let rest = name.strip_prefix(prefix)?;
+        // `Testing` is not a test: the suffix must not start with a lowercase letter
+        match rest.chars().next() {
+            None => Some(rest),
+            Some(c) if !c.is_lowercase() => Some(rest),
+            _ => None,
+        }
+    })
+}
+
+fn has_rust_test_attribute(definition: Node, source: &str) -> bool {
+    let mut sibling = definition.prev_named_sibling();
+    while let Some(node) = sibling {
+        if node.kind() != "attribute_item" {
+            break;
+        }
+        if let Ok(text) = node.utf8_text(source.as_bytes()) {
+            let attribute = text.trim_start_matches("#[").trim_end_matches(']').trim();
+            if attribute == "test" || attribute.ends_with("::test") {
+                return true;
+            }
+        }
+        sibling = node.prev_named_sibling();
+    }
+    false
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_go_test_names() {
+        assert_eq!(go_test_stem("TestCreateUser"), Some("CreateUser"));
+        assert_eq!(go_test_stem("BenchmarkQuery"), Some("Query"));
+        assert_eq!(go_test_stem("Test"), Some(""));
+        assert_eq!(go_test_stem("Testing"), None);
+        assert_eq!(go_test_stem("CreateUser"), None);
+    }
+
+    #[test]
+    fn test_target_heuristics() {
+        assert_eq!(test_target_names("TestCreateUser"), vec!["CreateUser"]);
+        assert_eq!(
+            test_target_names("TestUserService_CreateUser"),
+            vec!["UserService_CreateUser", "CreateUser", "UserService"]
+        );
+        assert!(test_covers("test_create_user_invalid_email", "create_user"));
+        assert!(test_covers("TestParseConfig", "parseConfig"));
+        assert!(!test_covers("TestCreateUser", "DeleteUser"));
+    }
+}
@@ -0,0 +1,267 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::HashSet;
+use tree_sitter::{Node, Parser};
+
+/// Go's predeclared types. Converting to one, `int64(n)`, parses as a call.
+pub(crate) const PREDECLARED_TYPES: &[&str] = &[
+    "any",
+    "bool",
+    "byte",
+    "complex64",
+    "complex128",
+    "error",
+    "float32",
+    "float64",
+    "int",
+    "int8",
+    "int16",
+    "int32",
+    "int64",
+    "rune",
+    "string",
+    "uint",
+    "uint8",
+    "uint16",
+    "uint32",
+    "uint64",
+    "uintptr",
+];
+
+/// How a value changes type
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum CastForm {
+    /// `v := x.(T)`: panics when `x` does not hold a `T`
+    Unchecked,
+    /// `v, ok := x.(T)`: `ok` is false instead
+    Checked,
+    /// `T(x)`: checked at compile time
+    Conversion,
+}
+
+/// A Go type assertion or conversion
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TypeCast {
+    pub form: CastForm,
+    /// The value asserted or converted, as written: `row["id"]`
+    pub operand: String,
+    /// The type asserted or converted to: `int64`
+    pub target_type: String,
+    /// The enclosing function or method; `None` at package level
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    pub line: u32,
+    pub column: u32,
+    /// The expression as written, whitespace collapsed
+    pub expression: String,
+}
+
+impl TypeCast {
+    /// Whether this can panic at run time
+    pub fn panics(&self) -> bool {
+        self.form == CastForm::Unchecked
+    }
+}
+
+/// Find the type assertions and conversions in a Go source file.
+/// Conversions are recognized when the type is written as a composite type
+/// (`[]byte(s)`), a predeclared type or a type declared in the same file;
+/// conversions to types from other files look like calls and are missed.
+/// Type switches never panic and are not reported.
+pub fn find_type_casts(source: &str) -> Result<Vec<TypeCast>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+    let root = tree.root_node();
+
+    let mut types: HashSet<String> = PREDECLARED_TYPES.iter().map(|t| t.to_string()).collect();
+    declared_types(root, source, &mut types);
+
+    let mut casts = Vec::new();
+    visit(root, source, &types, None, &mut casts);
+    Ok(casts)
+}
+
+/// Names of the types declared at package level
+fn declared_types(root: Node, source: &str, types: &mut HashSet<String>) {
+    let mut cursor = root.walk();
+    for declaration in root.named_children(&mut cursor) {
+        if declaration.kind() != "type_declaration" {
+            continue;
+        }
+        let mut specs = declaration.walk();
+        for spec in declaration.named_children(&mut specs) {
+            if let Some(name) = spec
+                .child_by_field_name("name")
+                .and_then(|name| text(name, source))
+            {
+                types.insert(name);
+            }
+        }
+    }
+}
+
+fn visit(
+    node: Node,
+    source: &str,
+    types: &HashSet<String>,
+    function: Option<&str>,
+    casts: &mut Vec<TypeCast>,
+) {
+    let named_function = match node.kind() {
+        "function_declaration" | "method_declaration" => node
+            .child_by_field_name("name")
+            .and_then(|name| text(name, source)),
+        _ => None,
+    };
+    let function = named_function.as_deref().or(function);
+
+    if let Some(cast) = type_cast(node, source, types, function) {
+        casts.push(cast);
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, types, function, casts);
+    }
+}
+
+fn type_cast(
+    node: Node,
+    source: &str,
+    types: &HashSet<String>,
+    function: Option<&str>,
+) -> Option<TypeCast> {
+    let (form, operand, target_type) = match node.kind() {
+        "type_assertion_expression" => {
+            let form = if is_comma_ok(node) {
+                CastForm::Checked
+            } else {
+                CastForm::Unchecked
+            };
+            (
+                form,
+                node.child_by_field_name("operand")?,
+                node.child_by_field_name("type")?,
+            )
+        }
+        "type_conversion_expression" => (
+            CastForm::Conversion,
+            node.child_by_field_name("operand")?,
+            node.child_by_field_name("type")?,
+        ),
+        "call_expression" => {
+            let callee = node.child_by_field_name("function")?;
+            let arguments = node.child_by_field_name("arguments")?;
+            if callee.kind() != "identifier"
+                || arguments.named_child_count() != 1
+                || !types.contains(&text(callee, source)?)
+            {
+                return None;
+            }
+            (CastForm::Conversion, arguments.named_child(0)?, callee)
+        }
+        _ => return None,
+    };
+
+    let start = node.start_position();
+    Some(TypeCast {
+        form,
+        operand: text(operand, source)?,
+        target_type: text(target_type, source)?,
+        function: function.map(str::to_string),
+        line: start.row as u32 + 1,
+        column: start.column as u32,
+        expression: text(node, source).unwrap_or_default(),
+    })
+}
+
+/// Whether an assertion is the only value assigned to two targets:
+/// `v, ok := x.(T)`, `v, ok = x.(T)` or `var v, ok = x.(T)`
+fn is_comma_ok(assertion: Node) -> bool {
+    let Some(values) = assertion.parent() else {
+        return false;
+    };
+    if values.kind() != "expression_list" || values.named_child_count() != 1 {
+        return false;
+    }
+    let Some(statement) = values.parent() else {
+        return false;
+    };
+    match statement.kind() {
+        "short_var_declaration" | "assignment_statement" => statement
+            .child_by_field_name("left")
+            .is_some_and(|left| left.named_child_count() == 2),
+        "var_spec" => {
+            let mut cursor = statement.walk();
+            statement
+                .children_by_field_name("name", &mut cursor)
+                .count()
+                == 2
+        }
+        _ => false,
+    }
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_find_type_casts() {
+        let source = r#"package users
+
+type UserID int64
+
+func GetUser(row map[string]interface{}) *User {
+    id := row["id"].(int64)
+    name, ok := row["name"].(string)
+    if !ok {
+        name = ""
+    }
+    var email, found = row["email"].(string)
+    _ = found
+    switch v := row["age"].(type) {
+    case int:
+        _ = v
+    }
+    return &User{ID: UserID(id), Name: name, Email: email, Raw: []byte(name), Score: float64(len(name))}
+}
+"#;
+        let casts = find_type_casts(source).unwrap();
+        let found: Vec<(CastForm, &str, &str, u32)> = casts
+            .iter()
+            .map(|cast| {
+                (
+                    cast.form,
+                    cast.operand.as_str(),
+                    cast.target_type.as_str(),
+                    cast.line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            found,
+            vec![
+                (CastForm::Unchecked, r#"row["id"]"#, "int64", 6),
+                (CastForm::Checked, r#"row["name"]"#, "string", 7),
+                (CastForm::Checked, r#"row["email"]"#, "string", 11),
+                (CastForm::Conversion, "id", "UserID", 17),
+                (CastForm::Conversion, "name", "[]byte", 17),
+                (CastForm::Conversion, "len(name)", "float64", 17),
+            ]
+        );
+        assert!(casts
+            .iter()
+            .all(|cast| cast.function.as_deref() == Some("GetUser")));
+        assert!(casts[0].panics());
+        assert!(!casts[1].panics());
+    }
+}
@@ -0,0 +1,155 @@
+/// Words that appear in type expressions and signatures of the indexed
+/// languages without naming a type
+const NON_TYPE_WORDS: &[&str] = &[
+    "func",
+    "fn",
+    "def",
+    "map",
+    "chan",
+    "struct",
+    "interface",
+    "type",
+    "const",
+    "var",
+    "let",
+    "mut",
+    "dyn",
+    "impl",
+    "pub",
+    "public",
+    "private",
+    "protected",
+    "internal",
+    "static",
+    "final",
+    "readonly",
+    "async",
+    "override",
+    "virtual",
+    "abstract",
+    "self",
+    "Self",
+    "this",
+    "where",
+    "extends",
+    "implements",
+    "in",
+    "out",
+    "ref",
+];
+
+/// The names in a field type or method signature that may refer to a type,
+/// in order of first appearance: qualified names (`models.User`,
+/// `crate::db::Pool`) whole, keywords left out. Parameter and method names
+/// are returned too; they drop out when no type of that name is indexed.
+pub fn candidate_type_names(text: &str) -> Vec<String> {
+    let mut names: Vec<String> = Vec::new();
+    let mut chars = text.char_indices().peekable();
+    while let Some((start, c)) = chars.next() {
+        if !(c.is_alphabetic() || c == '_') {
+            continue;
+        }
+        let mut end = start + c.len_utf8();
+        while let Some(&(index, next)) = chars.peek() {
+            let continues = next.is_alphanumeric()
+                || next == '_'
+                || (matches!(next, '.' | ':') && continues_path(&text[index..]));
+            if !continues {
+                break;
+            }
+            end = index + next.len_utf8();
+            chars.next();
+            if next == ':' {
+                // The second colon of `::`
+                end += 1;
+                chars.next();
+            }
+        }
+        let name = &text[start..end];
+        if !NON_TYPE_WORDS.contains(&name) && !names.iter().any(|seen| seen == name) {
+            names.push(name.to_string());
+        }
+    }
+    names
+}
+
+/// Whether a `.` or `::` at the start of `rest` joins two parts of a
+/// qualified name
+fn continues_path(rest: &str) -> bool {
+    let after = match rest.strip_prefix("::") {
+        Some(after) => after,
+        None if rest.starts_with(':') => return false,
+        None => &rest[1..],
+    };
+    after
+        .chars()
+        .next()
+        .is_some_and(|c| c.is_alphabetic() || c == '_')
+}
+
+/// Order the nodes reachable from `root` so each comes after every node it
+/// depends on, `root` last. `edges[n]` lists the dependencies of node `n`.
+/// A dependency closing a cycle is placed where it was first reached, so
+/// the order is topological except along cycles.
+pub fn dependency_order(root: usize, edges: &[Vec<usize>]) -> Vec<usize> {
+    let mut order = Vec::new();
+    let mut visited = vec![false; edges.len()];
+    // Each frame is a node and the index of its next dependency to visit
+    let mut stack = vec![(root, 0)];
+    visited[root] = true;
+    while let Some((node, next)) = stack.pop() {
+        match edges[node].get(next) {
+            Some(&dependency) => {
+                stack.push((node, next + 1));
+                if !visited[dependency] {
+                    visited[dependency] = true;
+                    stack.push((dependency, 0));
+                }
+            }
+            None => order.push(node),
+        }
+    }
+    order
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_candidate_type_names() {
+        assert_eq!(
+            candidate_type_names("map[string][]*models.User"),
+            vec!["string", "models.User"]
+        );
+        assert_eq!(
+            candidate_type_names(
+                "func (s *UserService) Get(ctx context.Context, id string) (*User, error)"
+            ),
+            vec![
+                "s",
+                "UserService",
+                "Get",
+                "ctx",
+                "context.Context",
+                "id",
+                "string",
+                "User",
+                "error"
+            ]
+        );
+        assert_eq!(
+            candidate_type_names("fn pool(&self) -> Arc<crate::db::Pool>"),
+            vec!["pool", "Arc", "crate::db::Pool"]
+        );
+        assert_eq!(candidate_type_names("name: str"), vec!["name", "str"]);
+    }
+
+    #[test]
+    fn test_dependency_order() {
+        // 0 -> 1, 2; 1 -> 3; 2 -> 3; 3 -> 1 (cycle)
+        let edges = vec![vec![1, 2], vec![3], vec![3], vec![1], vec![]];
+        assert_eq!(dependency_order(0, &edges), vec![3, 1, 2, 0]);
+        assert_eq!(dependency_order(4, &edges), vec![4]);
+    }
+}
@@ -0,0 +1,241 @@
+use crate::models::Symbol;
+use serde::{Deserialize, Serialize};
+use std::collections::{BTreeSet, HashMap, HashSet};
+use tree_sitter::Node;
+
+/// Tag on Go type declarations: `alias` for `type T = U`, `defined` for
+/// `type T U`
+pub const TYPE_FORM_TAG: &str = "type_form";
+
+/// Tag on Go type declarations with the type written on the right, unless
+/// it is a struct or interface literal
+pub const TYPE_EXPR_TAG: &str = "type_expr";
+
+/// How a Go type name relates to the type it is declared with. An alias is
+/// another name for the same type and shares its method set; a defined type
+/// is a new type with the same underlying type and none of its methods.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum TypeForm {
+    Alias,
+    Defined,
+}
+
+impl TypeForm {
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name {
+            "alias" => Some(TypeForm::Alias),
+            "defined" => Some(TypeForm::Defined),
+            _ => None,
+        }
+    }
+
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            TypeForm::Alias => "alias",
+            TypeForm::Defined => "defined",
+        }
+    }
+
+    /// The form recorded on a Go type symbol at indexing time
+    pub fn of(symbol: &Symbol) -> Option<Self> {
+        symbol
+            .tags
+            .get(TYPE_FORM_TAG)
+            .and_then(|form| Self::from_name(form))
+    }
+}
+
+/// Tags for a Go `type_spec` or `type_alias` node; none for other nodes
+pub fn go_type_tags(definition: Node, source: &str) -> Vec<(String, String)> {
+    let form = match definition.kind() {
+        "type_alias" => TypeForm::Alias,
+        "type_spec" => TypeForm::Defined,
+        _ => return Vec::new(),
+    };
+    let mut tags = vec![(TYPE_FORM_TAG.to_string(), form.as_str().to_string())];
+    let written = definition
+        .child_by_field_name("type")
+        .filter(|node| !matches!(node.kind(), "struct_type" | "interface_type"))
+        .and_then(|node| node.utf8_text(source.as_bytes()).ok());
+    if let Some(text) = written {
+        let text = text.split_whitespace().collect::<Vec<_>>().join(" ");
+        tags.push((TYPE_EXPR_TAG.to_string(), text));
+    }
+    tags
+}
+
+/// A Go type declaration of one package, as far as method sets go
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GoTypeDecl {
+    pub form: TypeForm,
+    /// The type written on the right; `None` for struct and interface literals
+    pub type_expr: Option<String>,
+    pub interface: bool,
+}
+
+/// The package type a plain type name on the right of a declaration refers
+/// to. Pointers, composites, instantiations and qualified names refer to
+/// none.
+fn local_name<'a>(types: &'a HashMap<String, GoTypeDecl>, expr: &str) -> Option<&'a str> {
+    types.get_key_value(expr).map(|(name, _)| name.as_str())
+}
+
+/// The type an alias chain ends at: `name` itself unless it is an alias of
+/// another type of the package
+pub fn canonical_type<'a>(types: &'a HashMap<String, GoTypeDecl>, name: &'a str) -> &'a str {
+    let mut current = name;
+    let mut seen = HashSet::from([name]);
+    while let Some(decl) = types.get(current) {
+        if decl.form != TypeForm::Alias {
+            break;
+        }
+        let Some(next) = decl
+            .type_expr
+            .as_deref()
+            .and_then(|expr| local_name(types, expr))
+        else {
+            break;
+        };
+        if !seen.insert(next) {
+            break;
+        }
+        current = next;
+    }
+    current
+}
+
+/// The methods callable on values of the package type `name`, sorted.
+/// `declared` maps type names to the methods declared with them as
+/// receiver, or listed in them for interfaces. Aliases share the method set
+/// of the type they stand for, including methods declared through any other
+/// alias of it. A defined type keeps only its own methods, except that one
+/// declared from an interface has that interface's methods.
+pub fn go_method_set(
+    types: &HashMap<String, GoTypeDecl>,
+    declared: &HashMap<String, Vec<String>>,
+    name: &str,
+) -> Vec<String> {
+    let canonical = canonical_type(types, name);
+    let own = |type_name: &str| declared.get(type_name).into_iter().flatten().cloned();
+
+    let Some(decl) = types.get(canonical) else {
+        return own(canonical)
+            .collect::<BTreeSet<_>>()
+            .into_iter()
+            .collect();
+    };
+    if decl.form == TypeForm::Defined && !decl.interface {
+        let interface = decl
+            .type_expr
+            .as_deref()
+            .and_then(|expr| local_name(types, expr))
+            .map(|underlying| canonical_type(types, underlying))
+            .filter(|underlying| types.get(*underlying).is_some_and(|d| d.interface));
+        if let Some(interface) = interface {
+            return own(interface)
+                .collect::<BTreeSet<_>>()
+                .into_iter()
+                .collect();
+        }
+    }
+
+    let methods: BTreeSet<String> = std::iter::once(canonical)
+        .chain(
+            types
+                .keys()
+                .map(String::as_str)
+                .filter(|other| *other != canonical && canonical_type(types, other) == canonical),
+        )
+        .flat_map(own)
+        .collect();
+    methods.into_iter().collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn decl(form: TypeForm, type_expr: Option<&str>, interface: bool) -> GoTypeDecl {
+        GoTypeDecl {
+            form,
+            type_expr: type_expr.map(String::from),
+            interface,
+        }
+    }
+
+    #[test]
+    fn test_go_method_sets() {
+        // type Cache interface { Get(); Set() }
+        // type MemoryCache struct{}      with Get, Set and cleanup
+        // type Store = MemoryCache       with Stats declared on the alias
+        // type Shared = Store
+        // type LocalCache MemoryCache    with Warm
+        // type ReadOnly Cache
+        // type Count int
+        let types = HashMap::from([
+            ("Cache".to_string(), decl(TypeForm::Defined, None, true)),
+            (
+                "MemoryCache".to_string(),
+                decl(TypeForm::Defined, None, false),
+            ),
+            (
+                "Store".to_string(),
+                decl(TypeForm::Alias, Some("MemoryCache"), false),
+            ),
+            (
+                "Shared".to_string(),
+                decl(TypeForm::Alias, Some("Store"), false),
+            ),
+            (
+                "LocalCache".to_string(),
+                decl(TypeForm::Defined, Some("MemoryCache"), false),
+            ),
+            (
+                "ReadOnly".to_string(),
+                decl(TypeForm::Defined, Some("Cache"), false),
+            ),
+            (
+                "Count".to_string(),
+                decl(TypeForm::Defined, Some("int"), false),
+            ),
+        ]);
+        let methods = |names: &[&str]| names.iter().map(|n| n.to_string()).collect::<Vec<_>>();
+        let declared = HashMap::from([
+            ("Cache".to_string(), methods(&["Set", "Get"])),
+            (
+                "MemoryCache".to_string(),
+                methods(&["Get", "Set", "cleanup"]),
+            ),
+            ("Store".to_string(), methods(&["Stats"])),
+            ("LocalCache".to_string(), methods(&["Warm"])),
+        ]);
+
+        assert_eq!(canonical_type(&types, "Shared"), "MemoryCache");
+        assert_eq!(canonical_type(&types, "LocalCache"), "LocalCache");
+
+        let everything = methods(&["Get", "Set", "Stats", "cleanup"]);
+        assert_eq!(go_method_set(&types, &declared, "Store"), everything);
+        assert_eq!(go_method_set(&types, &declared, "Shared"), everything);
+        assert_eq!(go_method_set(&types, &declared, "MemoryCache"), everything);
+        assert_eq!(
+            go_method_set(&types, &declared, "LocalCache"),
+            methods(&["Warm"])
+        );
+        assert_eq!(
+            go_method_set(&types, &declared, "ReadOnly"),
+            methods(&["Get", "Set"])
+        );
+        assert!(go_method_set(&types, &declared, "Count").is_empty());
+    }
+
+    #[test]
+    fn test_alias_cycle_stops() {
+        let types = HashMap::from([
+            ("A".to_string(), decl(TypeForm::Alias, Some("B"), false)),
+            ("B".to_string(), decl(TypeForm::Alias, Some("A"), false)),
+        ]);
+        assert_eq!(canonical_type(&types, "A"), "B");
+        assert!(go_method_set(&types, &HashMap::new(), "A").is_empty());
+    }
+}
@@ -0,0 +1,987 @@
+use crate::indexing::anonymous_types::{
+    find_anonymous_type, is_anonymous_type, synthetic_type_name,
+};
+use crate::indexing::signature::extract_signature;
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use tree_sitter::{Node, Parser};
+
+/// Node kinds that define a type with members
+const TYPE_KINDS: &[&str] = &[
+    // Go
+    "type_spec",
+    // Rust
+    "struct_item",
+    "enum_item",
+    "union_item",
+    "trait_item",
+    // C, C++
+    "struct_specifier",
+    "class_specifier",
+    "union_specifier",
+    // Java, C#, TypeScript, JavaScript, Kotlin, Swift, PHP
+    "class_declaration",
+    "abstract_class_declaration",
+    "interface_declaration",
+    "enum_declaration",
+    "record_declaration",
+    "struct_declaration",
+    "trait_declaration",
+    "object_declaration",
+    "protocol_declaration",
+    "class",
+    // Python, Scala
+    "class_definition",
+    "object_definition",
+    "trait_definition",
+];
+
+/// Node kinds that define a method or method signature
+const METHOD_KINDS: &[&str] = &[
+    "function_item",
+    "function_signature_item",
+    "function_definition",
+    "function_declaration",
+    "method_declaration",
+    "method_definition",
+    "method_signature",
+    "abstract_method_signature",
+    "method_elem",
+    "method_spec",
+    "constructor_declaration",
+    "method",
+    "singleton_method",
+];
+
+/// Node kinds that declare one or more fields
+const FIELD_KINDS: &[&str] = &[
+    "field_declaration",
+    "public_field_definition",
+    "field_definition",
+    "property_signature",
+    "property_declaration",
+];
+
+/// A field declared on a type
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct TypeField {
+    pub name: String,
+    pub type_name: Option<String>,
+    pub line: u32,
+    pub exported: bool,
+    /// Go embedded field: its methods and fields are promoted to the outer type
+    pub embedded: bool,
+    /// The field holds a function (`func(...)` type) and is called like a method
+    pub callable: bool,
+    /// Synthetic name of an inline struct or interface in the field's type,
+    /// reported as its own entry in the file's types
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub anonymous_type: Option<String>,
+    /// Go: the embedded type this field is promoted from
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub promoted_from: Option<String>,
+    /// Go: embedded fields walked to reach a promoted field, outermost first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub promotion_path: Vec<String>,
+}
+
+/// A method attached to a type
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct TypeMethod {
+    pub name: String,
+    pub signature: Option<String>,
+    pub line: u32,
+    pub exported: bool,
+    /// Set when the method is defined in a different file than the type
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+    /// Go: the embedded type this method is promoted from
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub promoted_from: Option<String>,
+    /// Go: embedded fields walked to reach a promoted method, outermost first
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub promotion_path: Vec<String>,
+}
+
+/// A member of an embedded Go type that Go's promotion rules hide
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct ShadowedMember {
+    pub name: String,
+    /// `field` or `method`
+    pub kind: String,
+    /// The embedded type declaring it
+    pub from: String,
+    /// Embedded fields walked to reach it, outermost first
+    pub promotion_path: Vec<String>,
+    /// The type whose member of that name wins, the outer type itself for
+    /// its own members; `None` when the name is ambiguous
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub shadowed_by: Option<String>,
+    /// The name is found more than once at the shallowest depth it occurs
+    /// at, so no member of that name is promoted
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub ambiguous: bool,
+}
+
+/// A type defined in a file with its fields and methods grouped together
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct TypeMembers {
+    pub name: String,
+    pub kind: String,
+    pub line: u32,
+    pub exported: bool,
+    pub fields: Vec<TypeField>,
+    pub methods: Vec<TypeMethod>,
+    /// Go: members of embedded types hidden by shallower ones
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub shadowed: Vec<ShadowedMember>,
+}
+
+/// Parse `source` and group the fields and methods of every type it defines.
+///
+/// Methods are attached by containment, by Rust `impl` blocks and by Go
+/// receivers; methods for types defined elsewhere are dropped.
+pub fn extract_type_members(
+    source: &str,
+    language: Language,
+) -> Result<Vec<TypeMembers>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&language.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut extractor = Extractor {
+        source,
+        language,
+        types: Vec::new(),
+        methods: Vec::new(),
+    };
+    extractor.visit(tree.root_node(), None, false);
+
+    let Extractor {
+        mut types, methods, ..
+    } = extractor;
+    for (owner, method) in methods {
+        if let Some(owner_type) = types.iter_mut().find(|t| t.name == owner) {
+            if !owner_type.methods.iter().any(|m| m.line == method.line) {
+                owner_type.methods.push(method);
+            }
+        }
+    }
+    for owner_type in &mut types {
+        owner_type.methods.sort_by_key(|m| m.line);
+    }
+
+    Ok(types)
+}
+
+struct Extractor<'a> {
+    source: &'a str,
+    language: Language,
+    types: Vec<TypeMembers>,
+    /// Methods keyed by owner type name, attached once all types are known
+    methods: Vec<(String, TypeMethod)>,
+}
+
+impl<'a> Extractor<'a> {
+    fn visit(&mut self, node: Node, owner: Option<usize>, in_method: bool) {
+        let kind = node.kind();
+
+        // Go inline struct and interface literals are reported under a synthetic name
+        if self.language == Language::Go && is_anonymous_type(node) {
+            let index = self.types.len();
+            self.types.push(TypeMembers {
+                name: synthetic_type_name(node, self.source),
+                kind: kind.trim_end_matches("_type").to_string(),
+                line: node.start_position().row as u32 + 1,
+                exported: false,
+                fields: Vec::new(),
+                methods: Vec::new(),
+                shadowed: Vec::new(),
+            });
+            self.visit_children(node, Some(index), false);
+            return;
+        }
+
+        if TYPE_KINDS.contains(&kind) && self.has_members(node) {
+            if let Some(name) = self.type_name(node) {
+                let index = self.types.len();
+                self.types.push(TypeMembers {
+                    exported: self.is_exported(node, &name),
+                    kind: self.type_kind(node).to_string(),
+                    line: node.start_position().row as u32 + 1,
+                    name,
+                    fields: Vec::new(),
+                    methods: Vec::new(),
+                    shadowed: Vec::new(),
+                });
+                self.visit_children(node, Some(index), false);
+                return;
+            }
+        }
+
+        // Rust `impl Type { ... }` attaches methods to a type declared elsewhere in the file
+        if kind == "impl_item" {
+            if let Some(type_name) = node
+                .child_by_field_name("type")
+                .and_then(|n| self.text(n))
+                .map(|t| base_type_name(&t))
+            {
+                let mut cursor = node.walk();
+                for child in node.named_children(&mut cursor) {
+                    self.visit_impl_body(child, &type_name);
+                }
+            }
+            return;
+        }
+
+        if METHOD_KINDS.contains(&kind) {
+            // Go methods name their type through the receiver
+            let receiver_owner = node
+                .child_by_field_name("receiver")
+                .and_then(|r| self.text(r))
+                .map(|r| base_type_name(&r));
+            let owner_name = receiver_owner.or_else(|| owner.map(|i| self.types[i].name.clone()));
+
+            if let Some(owner_name) = owner_name {
+                if let Some(method) = self.method(node) {
+                    self.methods.push((owner_name, method));
+                }
+                // Python assigns instance fields inside methods (`self.x = ...`)
+                if self.language == Language::Python {
+                    self.visit_children(node, owner, true);
+                }
+                // Go method signatures and bodies may declare anonymous types
+                if self.language == Language::Go {
+                    self.visit_children(node, None, false);
+                }
+                return;
+            }
+        }
+
+        if let Some(owner) = owner {
+            if FIELD_KINDS.contains(&kind) {
+                if self.is_method_declaration(node) {
+                    if let Some(method) = self.method(node) {
+                        let owner_name = self.types[owner].name.clone();
+                        self.methods.push((owner_name, method));
+                    }
+                } else {
+                    let fields = self.fields(node);
+                    self.push_fields(owner, fields);
+                    if self.language == Language::Go {
+                        self.visit_children(node, None, false);
+                    }
+                }
+                return;
+            }
+            if self.language == Language::Python && kind == "assignment" {
+                if let Some(field) = self.python_field(node, in_method) {
+                    self.push_fields(owner, vec![field]);
+                }
+            }
+        }
+
+        self.visit_children(node, owner, in_method);
+    }
+
+    fn visit_children(&mut self, node: Node, owner: Option<usize>, in_method: bool) {
+        let mut cursor = node.walk();
+        let children: Vec<Node> = node.named_children(&mut cursor).collect();
+        for child in children {
+            self.visit(child, owner, in_method);
+        }
+    }
+
+    fn visit_impl_body(&mut self, node: Node, type_name: &str) {
+        if METHOD_KINDS.contains(&node.kind()) {
+            if let Some(method) = self.method(node) {
+                self.methods.push((type_name.to_string(), method));
+            }
+            return;
+        }
+        if node.kind() == "declaration_list" {
+            let mut cursor = node.walk();
+            let children: Vec<Node> = node.named_children(&mut cursor).collect();
+            for child in children {
+                self.visit_impl_body(child, type_name);
+            }
+        }
+    }
+
+    fn push_fields(&mut self, owner: usize, fields: Vec<TypeField>) {
+        let existing = &mut self.types[owner].fields;
+        for field in fields {
+            if !existing.iter().any(|f| f.name == field.name) {
+                existing.push(field);
+            }
+        }
+    }
+
+    /// Forward declarations like `struct Foo;` have no members to report
+    fn has_members(&self, node: Node) -> bool {
+        match node.kind() {
+            "struct_specifier" | "class_specifier" | "union_specifier" => {
+                node.child_by_field_name("body").is_some()
+            }
+            _ => true,
+        }
+    }
+
+    fn type_name(&self, node: Node) -> Option<String> {
+        if let Some(name) = node.child_by_field_name("name") {
+            return self.text(name);
+        }
+
+        // `typedef struct { ... } Name;`
+        if let Some(parent) = node.parent() {
+            if parent.kind() == "type_definition" {
+                if let Some(declarator) = parent.child_by_field_name("declarator") {
+                    return self.declarator_name(declarator);
+                }
+            }
+        }
+
+        let mut cursor = node.walk();
+        let name = node
+            .named_children(&mut cursor)
+            .find(|child| child.kind().ends_with("identifier") || child.kind() == "constant");
+        name.and_then(|n| self.text(n))
+    }
+
+    fn type_kind(&self, node: Node) -> &'static str {
+        let kind = node.kind();
+        let body_kind = node
+            .child_by_field_name("type")
+            .map(|t| t.kind())
+            .unwrap_or_default();
+
+        if kind.contains("interface")
+            || kind.contains("trait")
+            || kind.contains("protocol")
+            || body_kind == "interface_type"
+        {
+            "interface"
+        } else if kind.contains("enum") {
+            "enum"
+        } else if kind.contains("struct") || kind.contains("union") || body_kind == "struct_type" {
+            "struct"
+        } else if kind == "type_spec" {
+            "type"
+        } else {
+            "class"
+        }
+    }
+
+    fn method(&self, node: Node) -> Option<TypeMethod> {
+        let name = match node.child_by_field_name("name") {
+            Some(name) => self.text(name)?,
+            None => self.declarator_name(node.child_by_field_name("declarator")?)?,
+        };
+        let signature = extract_signature(node, self.source).map(|s| s.text);
+
+        Some(TypeMethod {
+            exported: self.is_exported(node, &name),
+            line: node.start_position().row as u32 + 1,
+            name,
+            signature,
+            file: None,
+            promoted_from: None,
+            promotion_path: Vec::new(),
+        })
+    }
+
+    /// C++ member function declarations share `field_declaration` with fields
+    fn is_method_declaration(&self, node: Node) -> bool {
+        node.child_by_field_name("declarator")
+            .map(|d| d.kind() == "function_declarator")
+            .unwrap_or(false)
+    }
+
+    fn fields(&self, node: Node) -> Vec<TypeField> {
+        let line = node.start_position().row as u32 + 1;
+        let type_node = self.field_type_node(node);
+        let type_name = type_node
+            .and_then(|t| self.text(t))
+            .map(|t| t.trim_start_matches(':').trim().to_string())
+            .filter(|t| !t.is_empty());
+        let callable = type_node.is_some_and(|t| t.kind() == "function_type");
+        let anonymous_type = match self.language {
+            Language::Go => type_node
+                .and_then(find_anonymous_type)
+                .map(|t| synthetic_type_name(t, self.source)),
+            _ => None,
+        };
+
+        let mut names: Vec<String> = {
+            let mut cursor = node.walk();
+            node.children_by_field_name("name", &mut cursor)
+                .filter_map(|n| self.declarator_name(n))
+                .collect()
+        };
+
+        // JavaScript class fields use `property`
+        if names.is_empty() {
+            if let Some(property) = node.child_by_field_name("property") {
+                names.extend(self.text(property));
+            }
+        }
+
+        if names.is_empty() {
+            let mut cursor = node.walk();
+            let declarators: Vec<Node> = node
+                .children_by_field_name("declarator", &mut cursor)
+                .collect();
+            names = declarators
+                .into_iter()
+                .filter_map(|d| self.declarator_name(d))
+                .collect();
+        }
+
+        if names.is_empty() {
+            names = self
+                .find_declarators(node)
+                .into_iter()
+                .filter_map(|d| self.declarator_name(d))
+                .collect();
+        }
+
+        // Go embedded field: `type Service struct { *Logger }`
+        if names.is_empty() && self.language == Language::Go {
+            if let Some(type_name) = &type_name {
+                let name = base_type_name(type_name);
+                let name = name.rsplit('.').next().unwrap_or(&name).to_string();
+                return vec![TypeField {
+                    exported: self.is_exported(node, &name),
+                    name,
+                    type_name: Some(type_name.clone()),
+                    line,
+                    embedded: true,
+                    callable: false,
+                    anonymous_type: None,
+                    promoted_from: None,
+                    promotion_path: Vec::new(),
+                }];
+            }
+        }
+
+        names
+            .into_iter()
+            .map(|name| {
+                let name = name.trim_start_matches('$').to_string();
+                TypeField {
+                    exported: self.is_exported(node, &name),
+                    name,
+                    type_name: type_name.clone(),
+                    line,
+                    embedded: false,
+                    callable,
+                    anonymous_type: anonymous_type.clone(),
+                    promoted_from: None,
+                    promotion_path: Vec::new(),
+                }
+            })
+            .collect()
+    }
+
+    fn field_type_node<'t>(&self, node: Node<'t>) -> Option<Node<'t>> {
+        node.child_by_field_name("type").or_else(|| {
+            // C# nests the type inside a variable_declaration
+            let mut cursor = node.walk();
+            let declaration = node
+                .named_children(&mut cursor)
+                .find(|child| child.kind() == "variable_declaration");
+            declaration.and_then(|d| d.child_by_field_name("type"))
+        })
+    }
+
+    fn find_declarators<'t>(&self, node: Node<'t>) -> Vec<Node<'t>> {
+        let mut found = Vec::new();
+        let mut cursor = node.walk();
+        for child in node.named_children(&mut cursor) {
+            match child.kind() {
+                "variable_declarator" | "property_element" => found.push(child),
+                "variable_declaration" => {
+                    let nested = self.find_declarators(child);
+                    if nested.is_empty() {
+                        found.push(child);
+                    } else {
+                        found.extend(nested);
+                    }
+                }
+                _ => {}
+            }
+        }
+        found
+    }
+
+    /// Resolve the declared name through pointer/array/initializer declarators
+    fn declarator_name(&self, node: Node) -> Option<String> {
+        let kind = node.kind();
+        if kind.ends_with("identifier") || kind == "variable_name" || kind == "name" {
+            return self.text(node);
+        }
+        if let Some(inner) = node
+            .child_by_field_name("name")
+            .or_else(|| node.child_by_field_name("declarator"))
+        {
+            return self.declarator_name(inner);
+        }
+        let mut cursor = node.walk();
+        let inner = node.named_children(&mut cursor).find(|child| {
+            let kind = child.kind();
+            kind.ends_with("identifier") || kind == "variable_name" || kind.ends_with("declarator")
+        });
+        inner.and_then(|n| self.declarator_name(n))
+    }
+
+    fn python_field(&self, node: Node, in_method: bool) -> Option<TypeField> {
+        let left = node.child_by_field_name("left")?;
+        let name = if in_method {
+            // `self.name = ...`
+            if left.kind() != "attribute" {
+                return None;
+            }
+            let object = left.child_by_field_name("object")?;
+            if self.text(object)? != "self" {
+                return None;
+            }
+            self.text(left.child_by_field_name("attribute")?)?
+        } else if left.kind() == "identifier" {
+            self.text(left)?
+        } else {
+            return None;
+        };
+
+        Some(TypeField {
+            exported: !name.starts_with('_'),
+            type_name: node.child_by_field_name("type").and_then(|t| self.text(t)),
+            line: node.start_position().row as u32 + 1,
+            name,
+            embedded: false,
+            callable: false,
+            anonymous_type: None,
+            promoted_from: None,
+            promotion_path: Vec::new(),
+        })
+    }
+
+    fn is_exported(&self, node: Node, name: &str) -> bool {
+        match self.language {
+            Language::Go => name.chars().next().is_some_and(|c| c.is_uppercase()),
+            Language::Python => {
+                !name.starts_with('_') || (name.starts_with("__") && name.ends_with("__"))
+            }
+            Language::Ruby => true,
+            Language::Rust => {
+                let mut cursor = node.walk();
+                let public = node
+                    .named_children(&mut cursor)
+                    .any(|child| child.kind() == "visibility_modifier");
+                public
+            }
+            Language::C | Language::Cpp | Language::ObjectiveC => true,
+            Language::Java | Language::CSharp => self.modifiers(node).contains("public"),
+            _ => {
+                let modifiers = self.modifiers(node);
+                !(modifiers.contains("private")
+                    || modifiers.contains("protected")
+                    || name.starts_with('#'))
+            }
+        }
+    }
+
+    /// Text of the modifier nodes (`public`, `private static`, ...) on a declaration
+    fn modifiers(&self, node: Node) -> String {
+        let mut cursor = node.walk();
+        let modifiers: Vec<String> = node
+            .named_children(&mut cursor)
+            .filter(|child| child.kind().contains("modifier"))
+            .filter_map(|child| self.text(child))
+            .collect();
+        modifiers.join(" ")
+    }
+
+    fn text(&self, node: Node) -> Option<String> {
+        node.utf8_text(self.source.as_bytes())
+            .ok()
+            .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+    }
+}
+
+/// Bare type name from a receiver or impl target: `p *Cache[K, V]` -> `Cache`
+pub fn base_type_name(text: &str) -> String {
+    let text = text
+        .trim()
+        .trim_start_matches('(')
+        .trim_end_matches(')')
+        .trim();
+    let without_generics = text.split(['[', '<']).next().unwrap_or(text).trim();
+    let last = without_generics
+        .rsplit(' ')
+        .next()
+        .unwrap_or(without_generics);
+    last.trim_start_matches(['*', '&']).trim().to_string()
+}
+
+/// A field or method reached through embedded fields
+enum Promoted {
+    Field(TypeField),
+    Method(TypeMethod),
+}
+
+impl Promoted {
+    fn name(&self) -> &str {
+        match self {
+            Promoted::Field(field) => &field.name,
+            Promoted::Method(method) => &method.name,
+        }
+    }
+
+    fn kind(&self) -> &'static str {
+        match self {
+            Promoted::Field(_) => "field",
+            Promoted::Method(_) => "method",
+        }
+    }
+}
+
+/// The package-local type an embedded Go field names; `None` for types of
+/// other packages (`sync.Mutex`)
+fn embedded_type(field: &TypeField) -> Option<String> {
+    let type_name = base_type_name(field.type_name.as_deref()?);
+    (!type_name.contains('.')).then_some(type_name)
+}
+
+/// Add the fields and methods Go promotes from embedded types to each
+/// struct in `types`. `package` holds the package's types by name with
+/// their own members. Embedded types are walked breadth-first: a name at a
+/// shallower depth wins over deeper ones, and a name found more than once
+/// at the same depth is ambiguous and not promoted. Members that lose are
+/// listed in `shadowed`. Embedded types from other packages are not
+/// followed.
+pub fn promote_embedded(types: &mut [TypeMembers], package: &HashMap<String, TypeMembers>) {
+    for outer in types.iter_mut() {
+        if outer.kind != "struct" {
+            continue;
+        }
+        // Who owns each name so far; `None` once it is ambiguous
+        let mut owners: HashMap<String, Option<String>> = outer
+            .fields
+            .iter()
+            .map(|field| field.name.clone())
+            .chain(outer.methods.iter().map(|method| method.name.clone()))
+            .map(|name| (name, Some(outer.name.clone())))
+            .collect();
+        let mut visited: HashSet<String> = HashSet::from([outer.name.clone()]);
+        let mut frontier: Vec<(String, Vec<String>)> = outer
+            .fields
+            .iter()
+            .filter(|field| field.embedded)
+            .filter_map(|field| Some((embedded_type(field)?, vec![field.name.clone()])))
+            .collect();
+
+        while !frontier.is_empty() {
+            let mut found: Vec<(String, Vec<String>, Promoted)> = Vec::new();
+            let mut next = Vec::new();
+            for (type_name, path) in &frontier {
+                if visited.contains(type_name) {
+                    continue;
+                }
+                let Some(inner) = package.get(type_name) else {
+                    continue;
+                };
+                for field in &inner.fields {
+                    if field.embedded {
+                        if let Some(embedded) = embedded_type(field) {
+                            let mut deeper = path.clone();
+                            deeper.push(field.name.clone());
+                            next.push((embedded, deeper));
+                        }
+                    }
+                    found.push((
+                        type_name.clone(),
+                        path.clone(),
+                        Promoted::Field(field.clone()),
+                    ));
+                }
+                for method in &inner.methods {
+                    found.push((
+                        type_name.clone(),
+                        path.clone(),
+                        Promoted::Method(method.clone()),
+                    ));
+                }
+            }
+            visited.extend(frontier.into_iter().map(|(type_name, _)| type_name));
+
+            let mut counts: HashMap<&str, usize> = HashMap::new();
+            for (_, _, member) in &found {
+                *counts.entry(member.name()).or_default() += 1;
+            }
+            let counts: HashMap<String, usize> = counts
+                .into_iter()
+                .map(|(name, count)| (name.to_string(), count))
+                .collect();
+
+            for (from, path, member) in found {
+                let name = member.name().to_string();
+                let shadowed_by = match owners.get(&name) {
+                    Some(owner) => Some(owner.clone()),
+                    None if counts[&name] > 1 => Some(None),
+                    None => None,
+                };
+                if let Some(shadowed_by) = shadowed_by {
+                    outer.shadowed.push(ShadowedMember {
+                        kind: member.kind().to_string(),
+                        name,
+                        from,
+                        promotion_path: path,
+                        ambiguous: shadowed_by.is_none(),
+                        shadowed_by,
+                    });
+                    continue;
+                }
+                owners.insert(name, Some(from.clone()));
+                match member {
+                    Promoted::Field(mut field) => {
+                        field.promoted_from = Some(from);
+                        field.promotion_path = path;
+                        outer.fields.push(field);
+                    }
+                    Promoted::Method(mut method) => {
+                        method.promoted_from = Some(from);
+                        method.promotion_path = path;
+                        outer.methods.push(method);
+                    }
+                }
+            }
+            // Names ambiguous at this depth stay ambiguous below it
+            for (name, count) in counts {
+                if count > 1 {
+                    owners.entry(name).or_insert(None);
+                }
+            }
+            frontier = next;
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_base_type_name() {
+        assert_eq!(
+            base_type_name("(p *PostgresConnection)"),
+            "PostgresConnection"
+        );
+        assert_eq!(base_type_name("c *Cache[K, V]"), "Cache");
+        assert_eq!(base_type_name("Stack<T>"), "Stack");
+        assert_eq!(base_type_name("MemoryCache"), "MemoryCache");
+    }
+
+    #[test]
+    fn test_go_type_members() {
+        let source = r#"
+package cache
+
+type MemoryCache struct {
+    mu    sync.RWMutex
+    items map[string]item
+    Size  int
+    *Logger
+}
+
+func (c *MemoryCache) Get(key string) (interface{}, bool) { return nil, false }
+func (c *MemoryCache) cleanup() {}
+func helper() {}
+"#;
+        let types = extract_type_members(source, Language::Go).unwrap();
+        assert_eq!(types.len(), 1);
+
+        let cache = &types[0];
+        assert_eq!(cache.name, "MemoryCache");
+        assert_eq!(cache.kind, "struct");
+
+        let fields: Vec<(&str, bool)> = cache
+            .fields
+            .iter()
+            .map(|f| (f.name.as_str(), f.exported))
+            .collect();
+        assert_eq!(
+            fields,
+            vec![
+                ("mu", false),
+                ("items", false),
+                ("Size", true),
+                ("Logger", true)
+            ]
+        );
+        assert!(cache.fields[3].embedded);
+
+        let methods: Vec<(&str, bool)> = cache
+            .methods
+            .iter()
+            .map(|m| (m.name.as_str(), m.exported))
+            .collect();
+        assert_eq!(methods, vec![("Get", true), ("cleanup", false)]);
+    }
+
+    #[test]
+    fn test_go_anonymous_and_function_fields() {
+        let source = r#"
+package server
+
+type Server struct {
+    config struct {
+        Port int
+    }
+    OnClose func(err error)
+}
+"#;
+        let types = extract_type_members(source, Language::Go).unwrap();
+        let names: Vec<&str> = types.iter().map(|t| t.name.as_str()).collect();
+        assert_eq!(names, vec!["Server", "Server.config"]);
+
+        let server = &types[0];
+        assert_eq!(
+            server.fields[0].anonymous_type.as_deref(),
+            Some("Server.config")
+        );
+        assert!(server.fields[1].callable);
+        assert!(!server.fields[0].callable);
+
+        let config = &types[1];
+        assert_eq!(config.kind, "struct");
+        assert_eq!(config.fields[0].name, "Port");
+    }
+
+    fn go_struct(name: &str, fields: &[(&str, Option<&str>)], methods: &[&str]) -> TypeMembers {
+        TypeMembers {
+            name: name.to_string(),
+            kind: "struct".to_string(),
+            line: 1,
+            exported: true,
+            fields: fields
+                .iter()
+                .map(|(field, embedded)| TypeField {
+                    name: field.to_string(),
+                    type_name: Some(embedded.unwrap_or("int").to_string()),
+                    line: 1,
+                    exported: true,
+                    embedded: embedded.is_some(),
+                    callable: false,
+                    anonymous_type: None,
+                    promoted_from: None,
+                    promotion_path: Vec::new(),
+                })
+                .collect(),
+            methods: methods
+                .iter()
+                .map(|method| TypeMethod {
+                    name: method.to_string(),
+                    signature: None,
+                    line: 1,
+                    exported: true,
+                    file: None,
+                    promoted_from: None,
+                    promotion_path: Vec::new(),
+                })
+                .collect(),
+            shadowed: Vec::new(),
+        }
+    }
+
+    #[test]
+    fn test_promote_embedded() {
+        // Server embeds *Base and Logger; Base embeds Store
+        let package: HashMap<String, TypeMembers> = [
+            go_struct(
+                "Base",
+                &[("ID", None), ("Store", Some("Store"))],
+                &["Close", "Name"],
+            ),
+            go_struct("Logger", &[("Level", None)], &["Log", "Close"]),
+            go_struct("Store", &[("ID", None), ("Path", None)], &["Save", "Log"]),
+        ]
+        .into_iter()
+        .map(|t| (t.name.clone(), t))
+        .collect();
+        let mut types = vec![go_struct(
+            "Server",
+            &[
+                ("Base", Some("*Base")),
+                ("Logger", Some("Logger")),
+                ("mu", Some("sync.Mutex")),
+            ],
+            &["Name"],
+        )];
+
+        promote_embedded(&mut types, &package);
+        let server = &types[0];
+
+        let fields: Vec<(&str, Option<&str>, usize)> = server
+            .fields
+            .iter()
+            .map(|f| {
+                (
+                    f.name.as_str(),
+                    f.promoted_from.as_deref(),
+                    f.promotion_path.len(),
+                )
+            })
+            .collect();
+        assert_eq!(
+            fields,
+            vec![
+                ("Base", None, 0),
+                ("Logger", None, 0),
+                ("mu", None, 0),
+                ("ID", Some("Base"), 1),
+                ("Store", Some("Base"), 1),
+                ("Level", Some("Logger"), 1),
+                ("Path", Some("Store"), 2),
+            ]
+        );
+        let methods: Vec<(&str, Option<&str>)> = server
+            .methods
+            .iter()
+            .map(|m| (m.name.as_str(), m.promoted_from.as_deref()))
+            .collect();
+        // Close is on both Base and Logger at depth 1: ambiguous
+        assert_eq!(
+            methods,
+            vec![
+                ("Name", None),
+                ("Log", Some("Logger")),
+                ("Save", Some("Store"))
+            ]
+        );
+        assert_eq!(server.methods[2].promotion_path, vec!["Base", "Store"]);
+
+        let shadowed: Vec<(&str, &str, Option<&str>, bool)> = server
+            .shadowed
+            .iter()
+            .map(|m| {
+                (
+                    m.name.as_str(),
+                    m.from.as_str(),
+                    m.shadowed_by.as_deref(),
+                    m.ambiguous,
+                )
+            })
+            .collect();
+        assert_eq!(
+            shadowed,
+            vec![
+                ("Close", "Base", None, true),
+                ("Name", "Base", Some("Server"), false),
+                ("Close", "Logger", None, true),
+                ("ID", "Store", Some("Base"), false),
+                ("Log", "Store", Some("Logger"), false),
+            ]
+        );
+    }
+}
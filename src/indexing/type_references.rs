@@ -0,0 +1,93 @@
+use tree_sitter::Node;
+
+/// Type expressions that wrap, qualify or instantiate other types, across
+/// the grammars: Go `[]*T`, `map[K]V`, `chan T`, `pkg.T`, `List[T]`; C++
+/// `vector<T>`; C# `List<T>`; Rust `&T`
+const TYPE_EXPRESSION_KINDS: &[&str] = &[
+    "generic_type",
+    "template_type",
+    "generic_name",
+    "user_type",
+    "pointer_type",
+    "reference_type",
+    "slice_type",
+    "array_type",
+    "map_type",
+    "channel_type",
+    "function_type",
+    "qualified_type",
+    "parenthesized_type",
+    "nullable_type",
+    "optional_type",
+    "type_elem",
+    "type_projection",
+];
+
+/// The type argument lists of generic instantiations
+const TYPE_ARGUMENT_KINDS: &[&str] = &[
+    "type_arguments",
+    "type_argument_list",
+    "template_argument_list",
+];
+
+pub fn is_type_expression(node: Node) -> bool {
+    TYPE_EXPRESSION_KINDS.contains(&node.kind()) || TYPE_ARGUMENT_KINDS.contains(&node.kind())
+}
+
+/// The nodes naming a type anywhere inside `node`, however deeply nested:
+/// `Page`, `ID` and `User` of `[]Page[map[ID]*models.User]`. A qualified
+/// type contributes its name; the package is not a type. Parameter names
+/// inside function types are left out.
+pub fn named_types(node: Node) -> Vec<Node> {
+    let mut names = Vec::new();
+    collect_named_types(node, &mut names);
+    names
+}
+
+fn collect_named_types<'a>(node: Node<'a>, names: &mut Vec<Node<'a>>) {
+    let named = match node.kind() {
+        "type_identifier" => true,
+        // Grammars such as C# and Kotlin name types with plain identifiers
+        "identifier" => node.parent().is_some_and(is_type_expression),
+        _ => false,
+    };
+    if named {
+        names.push(node);
+        return;
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_named_types(child, names);
+    }
+}
+
+/// The types named inside every type expression of a tree
+pub fn nested_type_names(root: Node) -> Vec<Node> {
+    let mut names = Vec::new();
+    let mut stack = vec![root];
+    while let Some(node) = stack.pop() {
+        if is_type_expression(node) {
+            collect_named_types(node, &mut names);
+            continue;
+        }
+        let mut cursor = node.walk();
+        stack.extend(node.named_children(&mut cursor));
+    }
+    names
+}
+
+/// Whether a type name is a type argument of a generic instantiation,
+/// directly or nested: `User` of `List[User]` and of `Page[[]*User]`
+pub fn in_type_arguments(node: Node) -> bool {
+    let mut current = node.parent();
+    while let Some(parent) = current {
+        if TYPE_ARGUMENT_KINDS.contains(&parent.kind()) {
+            return true;
+        }
+        if !TYPE_EXPRESSION_KINDS.contains(&parent.kind()) {
+            return false;
+        }
+        current = parent.parent();
+    }
+    false
+}
@@ -0,0 +1,79 @@
+/// Whether the type expression `type_text` mentions the type `query`.
+///
+/// Modifiers are ignored, so `QueryResult` and `*QueryResult` both match
+/// `(*QueryResult, error)`, `[]*QueryResult` and `Option<QueryResult>`. An
+/// unqualified query matches the type in any package (`Time` matches
+/// `time.Time`); a qualified one (`time.Time`, `std::time::Instant`) must
+/// match its qualifier too.
+pub fn mentions_type(type_text: &str, query: &str) -> bool {
+    let query = normalize(strip_modifiers(query));
+    if query.is_empty() {
+        return false;
+    }
+    let qualified = query.contains('.');
+
+    type_identifiers(type_text).any(|identifier| {
+        let identifier = normalize(identifier);
+        if qualified {
+            identifier == query || identifier.ends_with(&format!(".{}", query))
+        } else {
+            identifier.rsplit('.').next() == Some(query.as_str())
+        }
+    })
+}
+
+/// The bare type name of a query: `*db.QueryResult` -> `QueryResult`
+pub fn type_query_base(query: &str) -> &str {
+    let query = strip_modifiers(query);
+    query.rsplit(['.', ':']).next().unwrap_or(query)
+}
+
+fn strip_modifiers(query: &str) -> &str {
+    query
+        .trim()
+        .trim_start_matches(['*', '&', '[', ']', '?'])
+        .trim_end_matches(['?', '!'])
+        .trim()
+}
+
+/// `std::time::Instant` and `time.Time` compare the same way
+fn normalize(identifier: &str) -> String {
+    identifier.replace("::", ".")
+}
+
+/// Runs of identifier characters, keeping qualifiers: `map[string]*db.Row`
+/// yields `map`, `string` and `db.Row`
+fn type_identifiers(type_text: &str) -> impl Iterator<Item = &str> {
+    type_text
+        .split(|c: char| !(c.is_alphanumeric() || c == '_' || c == '.' || c == ':' || c == '$'))
+        .map(|token| token.trim_matches(['.', ':']))
+        .filter(|token| !token.is_empty())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_mentions_type() {
+        assert!(mentions_type("(*QueryResult, error)", "*QueryResult"));
+        assert!(mentions_type("[]*db.QueryResult", "QueryResult"));
+        assert!(mentions_type("Option<QueryResult>", "QueryResult"));
+        assert!(!mentions_type("*QueryResultSet", "QueryResult"));
+
+        assert!(mentions_type("time.Time", "time.Time"));
+        assert!(mentions_type("*time.Time", "Time"));
+        assert!(!mentions_type("mytime.Time", "time.Time"));
+        assert!(mentions_type("map[string]time.Time", "time.Time"));
+
+        assert!(mentions_type("std::time::Instant", "time::Instant"));
+        assert!(!mentions_type("string", ""));
+    }
+
+    #[test]
+    fn test_type_query_base() {
+        assert_eq!(type_query_base("*db.QueryResult"), "QueryResult");
+        assert_eq!(type_query_base("std::time::Instant"), "Instant");
+        assert_eq!(type_query_base("[]User"), "User");
+    }
+}
@@ -0,0 +1,246 @@
+use crate::models::Language;
+use serde::{Deserialize, Serialize};
+use tree_sitter::{Node, Parser};
+
+/// Calls whose errors are conventionally ignored. `*` matches any run of
+/// characters; patterns are matched against the callee as written.
+pub const DEFAULT_ERROR_ALLOWLIST: &[&str] = &[
+    "fmt.Print*",
+    "fmt.Fprint*",
+    "*.WriteString",
+    "*.WriteByte",
+    "*.WriteRune",
+];
+
+/// How a call's results are thrown away
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Discard {
+    /// `f()` as a statement
+    Ignored,
+    /// `defer f()`
+    Deferred,
+    /// `go f()`
+    Goroutine,
+    /// Some results assigned to `_`: `v, _ := f()`
+    Blank,
+}
+
+/// A Go call whose results are not all used
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DiscardedCall {
+    /// The called function as written: `tx.Rollback`, `os.Remove`, `save`
+    pub callee: String,
+    pub discard: Discard,
+    /// For `Blank`: positions of the results assigned to `_`
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub blank_results: Vec<usize>,
+    /// For `Blank`: number of results on the left-hand side
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub result_count: Option<usize>,
+    /// The enclosing function or method; `None` at package level
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    pub line: u32,
+    pub column: u32,
+    /// The statement as written, whitespace collapsed
+    pub statement: String,
+}
+
+/// Find calls in a Go source file whose results are dropped or partly
+/// blanked. Whether a dropped result is an `error` depends on the callee's
+/// signature, which callers check separately.
+pub fn find_discarded_calls(
+    source: &str,
+) -> Result<Vec<DiscardedCall>, Box<dyn std::error::Error>> {
+    let mut parser = Parser::new();
+    parser.set_language(&Language::Go.tree_sitter_language())?;
+    let tree = parser.parse(source, None).ok_or("Failed to parse source")?;
+
+    let mut calls = Vec::new();
+    visit(tree.root_node(), source, None, &mut calls);
+    Ok(calls)
+}
+
+fn visit(node: Node, source: &str, function: Option<&str>, calls: &mut Vec<DiscardedCall>) {
+    let named_function = match node.kind() {
+        "function_declaration" | "method_declaration" => node
+            .child_by_field_name("name")
+            .and_then(|name| text(name, source)),
+        _ => None,
+    };
+    let function = named_function.as_deref().or(function);
+
+    if let Some(call) = discarded_call(node, source, function) {
+        calls.push(call);
+    }
+
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        visit(child, source, function, calls);
+    }
+}
+
+fn discarded_call(node: Node, source: &str, function: Option<&str>) -> Option<DiscardedCall> {
+    let (call, discard, blank_results, result_count) = match node.kind() {
+        "expression_statement" => (node.named_child(0)?, Discard::Ignored, Vec::new(), None),
+        "defer_statement" => (node.named_child(0)?, Discard::Deferred, Vec::new(), None),
+        "go_statement" => (node.named_child(0)?, Discard::Goroutine, Vec::new(), None),
+        "assignment_statement" | "short_var_declaration" => {
+            let right = node.child_by_field_name("right")?;
+            if right.named_child_count() != 1 {
+                return None;
+            }
+            let left = node.child_by_field_name("left")?;
+            let mut cursor = left.walk();
+            let targets: Vec<Option<String>> = left
+                .named_children(&mut cursor)
+                .map(|target| text(target, source))
+                .collect();
+            let blanks: Vec<usize> = targets
+                .iter()
+                .enumerate()
+                .filter(|(_, target)| target.as_deref() == Some("_"))
+                .map(|(index, _)| index)
+                .collect();
+            if blanks.is_empty() {
+                return None;
+            }
+            (
+                right.named_child(0)?,
+                Discard::Blank,
+                blanks,
+                Some(targets.len()),
+            )
+        }
+        _ => return None,
+    };
+    if call.kind() != "call_expression" {
+        return None;
+    }
+    let callee = call.child_by_field_name("function")?;
+    // `defer func() { ... }()` is checked through the calls in its body
+    if callee.kind() == "func_literal" {
+        return None;
+    }
+
+    let start = node.start_position();
+    Some(DiscardedCall {
+        callee: text(callee, source)?,
+        discard,
+        blank_results,
+        result_count,
+        function: function.map(str::to_string),
+        line: start.row as u32 + 1,
+        column: start.column as u32,
+        statement: text(node, source).unwrap_or_default(),
+    })
+}
+
+/// Whether `callee` matches an allowlist pattern. A pattern without a
+/// qualifier also matches qualified calls of that name: `Close` allows
+/// `f.Close`.
+pub fn is_allowed(callee: &str, allowlist: &[String]) -> bool {
+    allowlist.iter().any(|pattern| {
+        glob_match(pattern, callee)
+            || (!pattern.contains('.')
+                && callee
+                    .rsplit_once('.')
+                    .is_some_and(|(_, name)| glob_match(pattern, name)))
+    })
+}
+
+/// Match `text` against a pattern where `*` stands for any characters
+fn glob_match(pattern: &str, text: &str) -> bool {
+    let mut parts = pattern.split('*');
+    let first = parts.next().unwrap_or_default();
+    let Some(mut rest) = text.strip_prefix(first) else {
+        return false;
+    };
+    let parts: Vec<&str> = parts.collect();
+    let Some((last, middle)) = parts.split_last() else {
+        return rest.is_empty();
+    };
+    for part in middle {
+        match rest.find(part) {
+            Some(index) => rest = &rest[index + part.len()..],
+            None => return false,
+        }
+    }
+    rest.len() >= last.len() && rest.ends_with(last)
+}
+
+fn text(node: Node, source: &str) -> Option<String> {
+    node.utf8_text(source.as_bytes())
+        .ok()
+        .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_glob_allowlist() {
+        let allowlist: Vec<String> = DEFAULT_ERROR_ALLOWLIST
+            .iter()
+            .map(|s| s.to_string())
+            .chain(["Close".to_string()])
+            .collect();
+        assert!(is_allowed("fmt.Println", &allowlist));
+        assert!(is_allowed("fmt.Fprintf", &allowlist));
+        assert!(is_allowed("buf.WriteString", &allowlist));
+        assert!(is_allowed("resp.Body.Close", &allowlist));
+        assert!(!is_allowed("tx.Rollback", &allowlist));
+        assert!(!is_allowed("fmt.Errorf", &allowlist));
+        assert!(glob_match("a*b*c", "axxbyyc"));
+        assert!(!glob_match("a*b", "ab c"));
+    }
+
+    #[test]
+    fn test_discarded_calls() {
+        let source = r#"package store
+
+func (s *Store) Save(u User) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer func() {
+        tx.Rollback()
+    }()
+    _, _ = tx.Exec("INSERT", u.Name)
+    id, _ := s.nextID()
+    defer tx.Close()
+    go s.notify(u)
+    fmt.Println("saved", id)
+    return tx.Commit()
+}
+"#;
+        let calls = find_discarded_calls(source).unwrap();
+        let summary: Vec<(&str, Discard, Vec<usize>, u32)> = calls
+            .iter()
+            .map(|c| {
+                (
+                    c.callee.as_str(),
+                    c.discard,
+                    c.blank_results.clone(),
+                    c.line,
+                )
+            })
+            .collect();
+        assert_eq!(
+            summary,
+            vec![
+                ("tx.Rollback", Discard::Ignored, vec![], 9),
+                ("tx.Exec", Discard::Blank, vec![0, 1], 11),
+                ("s.nextID", Discard::Blank, vec![1], 12),
+                ("tx.Close", Discard::Deferred, vec![], 13),
+                ("s.notify", Discard::Goroutine, vec![], 14),
+                ("fmt.Println", Discard::Ignored, vec![], 15),
+            ]
+        );
+        assert!(calls.iter().all(|c| c.function.as_deref() == Some("Save")));
+        assert_eq!(calls[2].result_count, Some(2));
+    }
+}
@@ -0,0 +1,477 @@
+use crate::models::{Symbol, SymbolType};
+use std::path::Path;
+use tree_sitter::Node;
+
+/// Tag on package-level Go vars with the type their declaration shows: the
+/// declared type, the type of a literal, or the result of a well-known
+/// standard library call
+pub const VAR_TYPE_TAG: &str = "var_type";
+
+/// Tag on package-level Go vars initialized by a call, with the callee as
+/// written: `NewClient`, `db.Open`
+pub const VAR_INIT_TAG: &str = "var_init";
+
+/// Which result of the `var_init` call the var receives, for
+/// `var a, b = f()`; absent for the first
+pub const VAR_INIT_RESULT_TAG: &str = "var_init_result";
+
+/// Type of a var whose initializer cannot be resolved
+pub const UNKNOWN_TYPE: &str = "unknown";
+
+/// Result types of standard library calls common in package-level vars,
+/// which the index does not cover
+const KNOWN_CALLS: &[(&str, &str)] = &[
+    ("errors.New", "error"),
+    ("fmt.Errorf", "error"),
+    ("fmt.Sprintf", "string"),
+    ("fmt.Sprint", "string"),
+    ("os.Getenv", "string"),
+    ("strings.Join", "string"),
+    ("strings.NewReader", "*strings.Reader"),
+    ("strings.NewReplacer", "*strings.Replacer"),
+    ("bytes.NewBuffer", "*bytes.Buffer"),
+    ("bytes.NewBufferString", "*bytes.Buffer"),
+    ("regexp.MustCompile", "*regexp.Regexp"),
+    ("time.Now", "time.Time"),
+    ("time.Since", "time.Duration"),
+    ("context.Background", "context.Context"),
+    ("context.TODO", "context.Context"),
+    ("reflect.TypeOf", "reflect.Type"),
+    ("log.New", "*log.Logger"),
+    ("slog.New", "*slog.Logger"),
+    ("slog.Default", "*slog.Logger"),
+    ("http.NewServeMux", "*http.ServeMux"),
+    ("expvar.NewInt", "*expvar.Int"),
+    ("expvar.NewMap", "*expvar.Map"),
+    ("expvar.NewString", "*expvar.String"),
+    ("flag.String", "*string"),
+    ("flag.Int", "*int"),
+    ("flag.Bool", "*bool"),
+    ("flag.Duration", "*time.Duration"),
+];
+
+/// Predeclared types a call can convert to: `int64(n)`
+const BASIC_TYPES: &[&str] = &[
+    "bool",
+    "byte",
+    "rune",
+    "string",
+    "int",
+    "int8",
+    "int16",
+    "int32",
+    "int64",
+    "uint",
+    "uint8",
+    "uint16",
+    "uint32",
+    "uint64",
+    "uintptr",
+    "float32",
+    "float64",
+    "complex64",
+    "complex128",
+    "error",
+];
+
+/// Words that start a type rather than name a result: `func() error`
+const TYPE_KEYWORDS: &[&str] = &["func", "map", "chan", "struct", "interface"];
+
+/// Tags for the package-level Go var `name` declared by `definition`, a
+/// `var_spec`; none for other nodes and for vars inside functions
+pub fn go_var_tags(definition: Node, name: &str, source: &str) -> Vec<(String, String)> {
+    if definition.kind() != "var_spec" || !package_level(definition) {
+        return Vec::new();
+    }
+    let text = |node: Node| {
+        node.utf8_text(source.as_bytes())
+            .ok()
+            .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+    };
+    let mut cursor = definition.walk();
+    let Some(index) = definition
+        .children_by_field_name("name", &mut cursor)
+        .position(|node| text(node).as_deref() == Some(name))
+    else {
+        return Vec::new();
+    };
+    let values: Vec<Node> = match definition.child_by_field_name("value") {
+        Some(list) => {
+            let mut cursor = list.walk();
+            let values = list.named_children(&mut cursor).collect();
+            values
+        }
+        None => Vec::new(),
+    };
+    // `var a, b = f()` assigns one result of the call to each name
+    let (value, result) = match values.as_slice() {
+        [call] if index > 0 && call.kind() == "call_expression" => (Some(*call), index),
+        _ => (values.get(index).copied(), 0),
+    };
+
+    let mut tags = Vec::new();
+    let callee = value
+        .filter(|value| value.kind() == "call_expression")
+        .and_then(|call| call.child_by_field_name("function"))
+        .and_then(text)
+        .filter(|callee| !matches!(callee.as_str(), "new" | "make"))
+        .filter(|callee| !BASIC_TYPES.contains(&callee.as_str()));
+    let var_type = definition
+        .child_by_field_name("type")
+        .and_then(text)
+        .or_else(|| value.and_then(|value| literal_type(value, source)))
+        .or_else(|| {
+            let callee = callee.as_deref().filter(|_| result == 0)?;
+            KNOWN_CALLS
+                .iter()
+                .find(|(call, _)| *call == callee)
+                .map(|(_, result_type)| result_type.to_string())
+        });
+    if let Some(var_type) = var_type {
+        tags.push((VAR_TYPE_TAG.to_string(), var_type));
+    }
+    if let Some(callee) = callee {
+        tags.push((VAR_INIT_TAG.to_string(), callee));
+        if result > 0 {
+            tags.push((VAR_INIT_RESULT_TAG.to_string(), result.to_string()));
+        }
+    }
+    tags
+}
+
+/// Whether a `var_spec` is declared at the top of the file rather than in a
+/// function body
+fn package_level(spec: Node) -> bool {
+    let mut parent = spec.parent();
+    while let Some(node) = parent {
+        match node.kind() {
+            "var_spec_list" | "var_declaration" => parent = node.parent(),
+            kind => return kind == "source_file",
+        }
+    }
+    false
+}
+
+/// The type of a value that shows it without looking anything up: basic
+/// and composite literals, `&T{}`, `new(T)`, `make(T, n)` and conversions
+/// to predeclared types
+fn literal_type(value: Node, source: &str) -> Option<String> {
+    let text = |node: Node| {
+        node.utf8_text(source.as_bytes())
+            .ok()
+            .map(|text| text.split_whitespace().collect::<Vec<_>>().join(" "))
+    };
+    match value.kind() {
+        "interpreted_string_literal" | "raw_string_literal" => Some("string".to_string()),
+        "int_literal" => Some("int".to_string()),
+        "float_literal" => Some("float64".to_string()),
+        "imaginary_literal" => Some("complex128".to_string()),
+        "rune_literal" => Some("rune".to_string()),
+        "true" | "false" => Some("bool".to_string()),
+        "composite_literal" => value.child_by_field_name("type").and_then(text),
+        "unary_expression" => {
+            let operand = value.child_by_field_name("operand")?;
+            let is_address = value
+                .child_by_field_name("operator")
+                .and_then(text)
+                .is_some_and(|operator| operator == "&");
+            if !is_address || operand.kind() != "composite_literal" {
+                return None;
+            }
+            literal_type(operand, source).map(|operand_type| format!("*{}", operand_type))
+        }
+        "type_conversion_expression" => value.child_by_field_name("type").and_then(text),
+        "call_expression" => {
+            let callee = text(value.child_by_field_name("function")?)?;
+            let first = value
+                .child_by_field_name("arguments")
+                .and_then(|arguments| arguments.named_child(0))
+                .and_then(text);
+            match callee.as_str() {
+                "new" => first.map(|argument| format!("*{}", argument)),
+                "make" => first,
+                basic if BASIC_TYPES.contains(&basic) => Some(callee),
+                _ => None,
+            }
+        }
+        "parenthesized_expression" => literal_type(value.named_child(0)?, source),
+        _ => None,
+    }
+}
+
+/// The types a Go function returns, in order: `(*Client, error)` gives
+/// `*Client` and `error`, named results `(n, m int, err error)` give `int`,
+/// `int` and `error`
+pub fn result_types(return_type: &str) -> Vec<String> {
+    let trimmed = return_type.trim();
+    let inner = match trimmed
+        .strip_prefix('(')
+        .and_then(|rest| rest.strip_suffix(')'))
+    {
+        Some(inner) => inner,
+        None if trimmed.is_empty() => return Vec::new(),
+        None => return vec![trimmed.to_string()],
+    };
+
+    let mut parts = Vec::new();
+    let mut depth = 0usize;
+    let mut start = 0;
+    for (index, c) in inner.char_indices() {
+        match c {
+            '(' | '[' | '{' => depth += 1,
+            ')' | ']' | '}' => depth = depth.saturating_sub(1),
+            ',' if depth == 0 => {
+                parts.push(inner[start..index].trim());
+                start = index + 1;
+            }
+            _ => {}
+        }
+    }
+    parts.push(inner[start..].trim());
+    parts.retain(|part| !part.is_empty());
+
+    // Results are either all named or all unnamed; named ones may share a
+    // type, as in `(n, m int)`
+    let named_type = |part: &str| {
+        let (name, rest) = part.split_once(' ')?;
+        let is_name =
+            name.chars().all(|c| c.is_alphanumeric() || c == '_') && !TYPE_KEYWORDS.contains(&name);
+        is_name.then(|| rest.trim().to_string())
+    };
+    if !parts.iter().any(|part| named_type(part).is_some()) {
+        return parts.into_iter().map(String::from).collect();
+    }
+    let mut types = Vec::new();
+    let mut pending = 0;
+    for part in parts {
+        match named_type(part) {
+            Some(part_type) => {
+                types.extend(std::iter::repeat_n(part_type, pending + 1));
+                pending = 0;
+            }
+            None => pending += 1,
+        }
+    }
+    types
+}
+
+/// The type the `var_init` call of `var` gives it, and the indexed function
+/// or type that decides it. `candidates` are the indexed symbols named like
+/// the callee. An unqualified callee is looked up in the var's package, a
+/// qualified one in the package of that name, then among methods of that
+/// name. A callee that is a type converts to it. `None` when nothing
+/// matches or the matches disagree.
+pub fn resolve_var_init<'a>(
+    var: &Symbol,
+    candidates: &'a [Symbol],
+) -> Option<(String, &'a Symbol)> {
+    let callee = var.tags.get(VAR_INIT_TAG)?;
+    let result: usize = var
+        .tags
+        .get(VAR_INIT_RESULT_TAG)
+        .and_then(|index| index.parse().ok())
+        .unwrap_or(0);
+    let (qualifier, name) = match callee.rsplit_once('.') {
+        Some((qualifier, name)) => (Some(qualifier), name),
+        None => (None, callee.as_str()),
+    };
+    let directory = var.location.file.parent();
+    let is_go = |file: &Path| file.extension().is_some_and(|extension| extension == "go");
+    let in_scope = |candidate: &Symbol| match qualifier {
+        None => candidate.location.file.parent() == directory,
+        Some(qualifier) => candidate.namespace.as_deref() == Some(qualifier),
+    };
+    let typed = |candidate: &'a Symbol| -> Option<(String, &'a Symbol)> {
+        let candidate_type = match candidate.symbol_type {
+            SymbolType::Function | SymbolType::Method => {
+                let signature = candidate.signature.as_ref()?;
+                result_types(signature.return_type.as_deref()?)
+                    .into_iter()
+                    .nth(result)?
+            }
+            SymbolType::Struct | SymbolType::Interface | SymbolType::Class | SymbolType::Enum
+                if result == 0 =>
+            {
+                callee.clone()
+            }
+            _ => return None,
+        };
+        Some((candidate_type, candidate))
+    };
+
+    let named = candidates
+        .iter()
+        .filter(|candidate| candidate.name == name && is_go(&candidate.location.file));
+    let mut matches: Vec<(String, &Symbol)> = named
+        .clone()
+        .filter(|candidate| candidate.symbol_type != SymbolType::Method && in_scope(candidate))
+        .filter_map(typed)
+        .collect();
+    if matches.is_empty() && qualifier.is_some() {
+        matches = named
+            .filter(|candidate| candidate.symbol_type == SymbolType::Method)
+            .filter_map(typed)
+            .collect();
+    }
+    let (first_type, first) = matches.first()?;
+    matches
+        .iter()
+        .all(|(other, _)| other == first_type)
+        .then(|| (first_type.clone(), *first))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::indexing::SymbolIndexer;
+    use crate::models::Language;
+    use std::collections::BTreeMap;
+    use std::path::PathBuf;
+
+    fn var_tags(source: &str) -> BTreeMap<String, BTreeMap<String, String>> {
+        let mut indexer = SymbolIndexer::new().unwrap();
+        indexer
+            .extract_symbols(source, Language::Go, &PathBuf::from("/repo/api/vars.go"))
+            .unwrap()
+            .into_iter()
+            .filter(|symbol| symbol.symbol_type == SymbolType::Variable)
+            .map(|symbol| {
+                let tags = symbol
+                    .tags
+                    .into_iter()
+                    .filter(|(key, _)| key.starts_with("var_"))
+                    .collect();
+                (symbol.name, tags)
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_literal_initialized_vars() {
+        let source = r#"package api
+
+var (
+    retries         = 3
+    ratio           = 0.5
+    name            = "api"
+    verbose         = false
+    defaults        = Config{Retries: 3}
+    shared          = &Client{}
+    handlers        = map[string]Handler{}
+    buffer          = make([]byte, 0, 64)
+    pool            = new(Pool)
+    limit           = int64(100)
+    timeout         time.Duration
+    ErrDeclared error = NewError("declared")
+)
+
+func run() {
+    var local = 1
+}
+"#;
+        let tags = var_tags(source);
+        let var_type = |name: &str| tags[name].get(VAR_TYPE_TAG).map(String::as_str);
+        assert_eq!(var_type("retries"), Some("int"));
+        assert_eq!(var_type("ratio"), Some("float64"));
+        assert_eq!(var_type("name"), Some("string"));
+        assert_eq!(var_type("verbose"), Some("bool"));
+        assert_eq!(var_type("defaults"), Some("Config"));
+        assert_eq!(var_type("shared"), Some("*Client"));
+        assert_eq!(var_type("handlers"), Some("map[string]Handler"));
+        assert_eq!(var_type("buffer"), Some("[]byte"));
+        assert_eq!(var_type("pool"), Some("*Pool"));
+        assert_eq!(var_type("limit"), Some("int64"));
+        assert_eq!(var_type("timeout"), Some("time.Duration"));
+        // The declared type wins over the initializer's
+        assert_eq!(var_type("ErrDeclared"), Some("error"));
+        assert_eq!(
+            tags["ErrDeclared"].get(VAR_INIT_TAG).map(String::as_str),
+            Some("NewError")
+        );
+        assert!(!tags["limit"].contains_key(VAR_INIT_TAG));
+        assert!(tags["local"].is_empty());
+    }
+
+    #[test]
+    fn test_constructor_initialized_vars() {
+        let source = r#"package api
+
+var ErrNotConnected = errors.New("not connected")
+var defaultClient = NewClient("localhost")
+var store, storeErr = db.Open("postgres")
+var started = clock.Now()
+"#;
+        let tags = var_tags(source);
+        assert_eq!(
+            tags["ErrNotConnected"],
+            BTreeMap::from([
+                (VAR_TYPE_TAG.to_string(), "error".to_string()),
+                (VAR_INIT_TAG.to_string(), "errors.New".to_string()),
+            ])
+        );
+        assert_eq!(
+            tags["storeErr"],
+            BTreeMap::from([
+                (VAR_INIT_TAG.to_string(), "db.Open".to_string()),
+                (VAR_INIT_RESULT_TAG.to_string(), "1".to_string()),
+            ])
+        );
+
+        let mut indexer = SymbolIndexer::new().unwrap();
+        let mut symbols = indexer
+            .extract_symbols(source, Language::Go, &PathBuf::from("/repo/api/vars.go"))
+            .unwrap();
+        symbols.extend(
+            indexer
+                .extract_symbols(
+                    "package api\n\nfunc NewClient(addr string) *Client { return nil }\n",
+                    Language::Go,
+                    &PathBuf::from("/repo/api/client.go"),
+                )
+                .unwrap(),
+        );
+        symbols.extend(
+            indexer
+                .extract_symbols(
+                    "package db\n\nfunc Open(dsn string) (s *Store, err error) { return }\n",
+                    Language::Go,
+                    &PathBuf::from("/repo/db/open.go"),
+                )
+                .unwrap(),
+        );
+        let resolve = |name: &str| {
+            let var = symbols.iter().find(|symbol| symbol.name == name).unwrap();
+            resolve_var_init(var, &symbols)
+                .map(|(var_type, function)| (var_type, function.location.file.clone()))
+        };
+        assert_eq!(
+            resolve("defaultClient"),
+            Some(("*Client".to_string(), PathBuf::from("/repo/api/client.go")))
+        );
+        assert_eq!(
+            resolve("store"),
+            Some(("*Store".to_string(), PathBuf::from("/repo/db/open.go")))
+        );
+        assert_eq!(
+            resolve("storeErr").map(|(var_type, _)| var_type),
+            Some("error".to_string())
+        );
+        // Not indexed
+        assert_eq!(resolve("started"), None);
+    }
+
+    #[test]
+    fn test_result_types() {
+        assert_eq!(result_types("*Client"), vec!["*Client"]);
+        assert_eq!(result_types("(*Client, error)"), vec!["*Client", "error"]);
+        assert_eq!(
+            result_types("(n, m int, err error)"),
+            vec!["int", "int", "error"]
+        );
+        assert_eq!(
+            result_types("(func(int) error, map[string]int)"),
+            vec!["func(int) error", "map[string]int"]
+        );
+        assert!(result_types("").is_empty());
+    }
+}
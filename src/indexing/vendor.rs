@@ -0,0 +1,215 @@
+use crate::indexing::settings::setting_var;
+use crate::utils::PathResolver;
+use std::path::{Component, Path, PathBuf};
+
+/// Tag marking symbols of vendored files; the value is the vendor prefix
+/// that matched, such as `vendor` or `node_modules`
+pub const VENDOR_TAG: &str = "vendor";
+
+/// Vendor prefixes used when ROBERTO_VENDOR_PATHS is not set
+const DEFAULT_VENDOR_PATHS: &str = "vendor/,node_modules/,third_party/,$GOPATH/pkg/mod";
+
+/// How vendored files are indexed, configured through ROBERTO_VENDOR
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum VendorMode {
+    /// Index vendored files like first-party code
+    #[default]
+    Include,
+    /// Index vendored files with the `vendor` tag; searches leave them out
+    /// unless asked to include them
+    Separate,
+    /// Leave vendored files out when indexing a directory, changed files
+    /// or a git revision; a vendored file indexed by name is still indexed
+    Skip,
+}
+
+impl VendorMode {
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_lowercase().as_str() {
+            "include" => Some(Self::Include),
+            "separate" => Some(Self::Separate),
+            "skip" => Some(Self::Skip),
+            _ => None,
+        }
+    }
+
+    pub fn as_str(self) -> &'static str {
+        match self {
+            Self::Include => "include",
+            Self::Separate => "separate",
+            Self::Skip => "skip",
+        }
+    }
+}
+
+/// One configured vendor prefix
+#[derive(Debug, Clone, PartialEq)]
+struct VendorPrefix {
+    /// The prefix as configured, without trailing slashes
+    name: String,
+    /// Absolute prefixes match from the filesystem root; relative ones
+    /// match a run of directories below the indexed root
+    path: PathBuf,
+}
+
+/// Paths holding third-party code, configured through ROBERTO_VENDOR_PATHS
+/// as a comma separated list of prefixes. Relative prefixes (`vendor/`)
+/// match those directories anywhere under an indexed root; `$GOPATH` and
+/// `~` are expanded, `$GOPATH` defaulting to `~/go`.
+#[derive(Debug, Clone, PartialEq)]
+pub struct VendorPaths {
+    mode: VendorMode,
+    prefixes: Vec<VendorPrefix>,
+}
+
+impl Default for VendorPaths {
+    fn default() -> Self {
+        Self::parse(VendorMode::default(), DEFAULT_VENDOR_PATHS)
+    }
+}
+
+impl VendorPaths {
+    /// Mode from ROBERTO_VENDOR and prefixes from ROBERTO_VENDOR_PATHS; an
+    /// invalid mode is logged and vendored code indexed like any other
+    pub fn from_env() -> Self {
+        let mode = match setting_var("ROBERTO_VENDOR") {
+            Ok(name) => VendorMode::from_name(&name).unwrap_or_else(|| {
+                tracing::warn!(
+                    "Ignoring ROBERTO_VENDOR '{}': expected include, separate or skip",
+                    name
+                );
+                VendorMode::default()
+            }),
+            Err(_) => VendorMode::default(),
+        };
+        let spec = setting_var("ROBERTO_VENDOR_PATHS")
+            .unwrap_or_else(|_| DEFAULT_VENDOR_PATHS.to_string());
+        Self::parse(mode, &spec)
+    }
+
+    pub fn parse(mode: VendorMode, spec: &str) -> Self {
+        let prefixes = spec
+            .split(',')
+            .map(|prefix| prefix.trim().trim_end_matches('/'))
+            .filter(|prefix| !prefix.is_empty())
+            .filter_map(|name| {
+                Some(VendorPrefix {
+                    name: name.to_string(),
+                    path: expand(name)?,
+                })
+            })
+            .collect();
+        Self { mode, prefixes }
+    }
+
+    pub fn mode(&self) -> VendorMode {
+        self.mode
+    }
+
+    /// The configured prefix `path` lies under, if any, whatever the mode.
+    /// Relative prefixes are looked for below the innermost indexed root
+    /// containing `path`, so a checkout inside a `vendor` directory is not
+    /// itself vendored.
+    pub fn vendor_prefix(&self, path: &Path) -> Option<&str> {
+        let relative = PathResolver::indexed_roots()
+            .iter()
+            .filter_map(|root| path.strip_prefix(root).ok())
+            .min_by_key(|relative| relative.components().count())
+            .unwrap_or(path);
+        self.prefixes
+            .iter()
+            .find(|prefix| match prefix.path.is_absolute() {
+                true => path.starts_with(&prefix.path),
+                false => has_directories(relative, &prefix.path),
+            })
+            .map(|prefix| prefix.name.as_str())
+    }
+
+    /// Whether `path` is indexed apart from first-party code
+    pub fn separates(&self, path: &Path) -> bool {
+        self.mode == VendorMode::Separate && self.vendor_prefix(path).is_some()
+    }
+
+    /// Whether indexing a directory leaves `path` out
+    pub fn skips(&self, path: &Path) -> bool {
+        self.mode == VendorMode::Skip && self.vendor_prefix(path).is_some()
+    }
+
+    /// Canonical form of the configuration, persisted with the cache.
+    /// Empty when vendored code is indexed like any other.
+    pub fn fingerprint(&self) -> String {
+        if self.mode == VendorMode::Include {
+            return String::new();
+        }
+        let names: Vec<&str> = self
+            .prefixes
+            .iter()
+            .map(|prefix| prefix.name.as_str())
+            .collect();
+        format!("{}:{}", self.mode.as_str(), names.join(","))
+    }
+}
+
+/// Expand a leading `$GOPATH` or `~`; `None` when the variable it needs is
+/// not set
+fn expand(prefix: &str) -> Option<PathBuf> {
+    let home = || std::env::var_os("HOME").map(PathBuf::from);
+    if let Some(rest) = prefix.strip_prefix("$GOPATH") {
+        let gopath = match std::env::var_os("GOPATH") {
+            // The first entry of a GOPATH list holds the module cache
+            Some(list) => std::env::split_paths(&list).next()?,
+            None => home()?.join("go"),
+        };
+        return Some(gopath.join(rest.trim_start_matches('/')));
+    }
+    if let Some(rest) = prefix.strip_prefix('~') {
+        return Some(home()?.join(rest.trim_start_matches('/')));
+    }
+    Some(PathBuf::from(prefix))
+}
+
+/// Whether the directories of `path` include `directories` in a row
+fn has_directories(path: &Path, directories: &Path) -> bool {
+    let wanted: Vec<Component> = directories.components().collect();
+    let components: Vec<Component> = path.components().collect();
+    // The last component is the file itself, never a vendor directory
+    let parents = &components[..components.len().saturating_sub(1)];
+    !wanted.is_empty()
+        && parents
+            .windows(wanted.len())
+            .any(|window| window == wanted.as_slice())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_vendor_prefix() {
+        let paths = VendorPaths::parse(
+            VendorMode::Separate,
+            "vendor/, node_modules/, third_party/go, /opt/deps",
+        );
+        let prefix = |path: &str| paths.vendor_prefix(Path::new(path));
+
+        assert_eq!(prefix("/repo/vendor/github.com/x/y.go"), Some("vendor"));
+        assert_eq!(
+            prefix("/repo/web/node_modules/react/index.js"),
+            Some("node_modules")
+        );
+        assert_eq!(
+            prefix("/repo/third_party/go/lib.go"),
+            Some("third_party/go")
+        );
+        assert_eq!(prefix("/opt/deps/lib.go"), Some("/opt/deps"));
+        assert_eq!(prefix("/repo/third_party/python/lib.py"), None);
+        assert_eq!(prefix("/repo/internal/vendors/lib.go"), None);
+        // A file named like a vendor directory is first-party
+        assert_eq!(prefix("/repo/cmd/vendor"), None);
+
+        assert!(paths.separates(Path::new("/repo/vendor/x.go")));
+        assert!(!paths.skips(Path::new("/repo/vendor/x.go")));
+        assert!(!VendorPaths::default().separates(Path::new("/repo/vendor/x.go")));
+        assert_eq!(VendorPaths::default().fingerprint(), "");
+    }
+}
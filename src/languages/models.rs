@@ -1,11 +1,15 @@
+use crate::indexing::build_constraints::{satisfies, BUILD_TAG};
 use bincode::{Decode, Encode};
 use serde::{Deserialize, Serialize};
 use std::collections::hash_map::DefaultHasher;
+use std::collections::BTreeMap;
 use std::hash::{Hash, Hasher};
 use std::path::PathBuf;
 use std::time::SystemTime;
 
-#[derive(Debug, Clone, Copy, Hash, Eq, PartialEq, Serialize, Deserialize, Encode, Decode)]
+#[derive(
+    Debug, Clone, Copy, Hash, Eq, PartialEq, PartialOrd, Ord, Serialize, Deserialize, Encode, Decode,
+)]
 pub struct SymbolId(pub u64);
 
 impl SymbolId {
@@ -27,6 +31,254 @@ pub struct Symbol {
     pub namespace: Option<String>,
     pub visibility: Visibility,
     pub source: Option<String>,
+    pub signature: Option<Signature>,
+    /// `@key: value` tags from the doc comment, limited to the configured keys
+    #[serde(default)]
+    pub tags: BTreeMap<String, String>,
+    /// Documentation comment of a package or module declaration
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub doc: Option<String>,
+    /// The symbol this one re-exports or aliases, as written: `U` for Go
+    /// `type T = U`, `X` for TypeScript `export { X } from './y'`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub alias_of: Option<String>,
+}
+
+impl Symbol {
+    /// Match a `key:value` tag filter, or just `key` to require the tag be present.
+    ///
+    /// `build:<tags>` selects symbols that exist in that build context, e.g.
+    /// `build:linux,amd64`; symbols without a build constraint always match.
+    pub fn has_tag(&self, filter: &str) -> bool {
+        if let Some((key, context)) = filter.split_once(':') {
+            if key.trim().eq_ignore_ascii_case(BUILD_TAG) {
+                return self
+                    .tags
+                    .get(BUILD_TAG)
+                    .is_none_or(|constraint| satisfies(constraint, context));
+            }
+        }
+        match filter.split_once(':') {
+            Some((key, value)) => self
+                .tags
+                .get(&key.trim().to_lowercase())
+                .is_some_and(|tag| tag == value.trim()),
+            None => self.tags.contains_key(&filter.trim().to_lowercase()),
+        }
+    }
+}
+
+/// Callable signature extracted from a function or method definition
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Encode, Decode)]
+pub struct Signature {
+    /// Declaration text up to the body, with whitespace collapsed
+    pub text: String,
+    pub receiver: Option<String>,
+    pub type_parameters: Vec<String>,
+    pub parameters: Vec<Parameter>,
+    pub return_type: Option<String>,
+    /// Go channels among the results, in declaration order
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub return_channels: Vec<ChannelType>,
+    /// Whether the Go body defers any call
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub uses_defer: bool,
+    /// Calls the Go body defers, in source order. Defers inside nested
+    /// closures run when the closure returns and are left out.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub deferred: Vec<DeferredCall>,
+}
+
+/// A Go `defer` statement: `defer db.Close()` or `defer func() {...}()`
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Encode, Decode)]
+pub struct DeferredCall {
+    /// The deferred function as written: `db.Close`, `mu.Unlock`, `cancel`.
+    /// `None` for closures.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub target: Option<String>,
+    /// The deferred function is a closure
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub closure: bool,
+    /// For closures, the functions called in the closure body: `tx.Rollback`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub calls: Vec<String>,
+    pub line: u32,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Encode, Decode)]
+pub struct Parameter {
+    pub name: Option<String>,
+    pub type_name: Option<String>,
+    pub variadic: bool,
+    /// Declared with a default value or as optional (`x?: T`)
+    pub optional: bool,
+    /// The default expression as written: `30` for `timeout=30`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub default_value: Option<String>,
+    /// Set when the parameter is a Go channel
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub channel: Option<ChannelType>,
+}
+
+/// Which operations a Go channel type allows
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize, Encode, Decode)]
+#[serde(rename_all = "snake_case")]
+pub enum ChannelDirection {
+    /// `chan T`
+    Bidirectional,
+    /// `<-chan T`
+    Receive,
+    /// `chan<- T`
+    Send,
+}
+
+impl ChannelDirection {
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_lowercase().as_str() {
+            "bidirectional" | "both" => Some(ChannelDirection::Bidirectional),
+            "receive" | "recv" | "receive_only" => Some(ChannelDirection::Receive),
+            "send" | "send_only" => Some(ChannelDirection::Send),
+            _ => None,
+        }
+    }
+}
+
+/// A Go channel type split into its direction and element type
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize, Encode, Decode)]
+pub struct ChannelType {
+    pub direction: ChannelDirection,
+    /// The element type as written: `User` for `<-chan User`
+    pub element_type: String,
+}
+
+impl ChannelType {
+    /// Parse a channel type expression; `None` for any other type
+    pub fn parse(type_text: &str) -> Option<Self> {
+        let text = type_text.trim();
+        let (direction, rest) = match text.strip_prefix("<-") {
+            Some(rest) => (ChannelDirection::Receive, strip_keyword(rest, "chan")?),
+            None => {
+                let rest = strip_keyword(text, "chan")?;
+                match rest.strip_prefix("<-") {
+                    Some(rest) => (ChannelDirection::Send, rest),
+                    None => (ChannelDirection::Bidirectional, rest),
+                }
+            }
+        };
+        let element_type = rest.split_whitespace().collect::<Vec<_>>().join(" ");
+        (!element_type.is_empty()).then_some(Self {
+            direction,
+            element_type,
+        })
+    }
+
+    pub fn render(&self) -> String {
+        match self.direction {
+            ChannelDirection::Bidirectional => format!("chan {}", self.element_type),
+            ChannelDirection::Receive => format!("<-chan {}", self.element_type),
+            ChannelDirection::Send => format!("chan<- {}", self.element_type),
+        }
+    }
+}
+
+/// `text` after a leading `keyword` that is not the start of a longer name
+fn strip_keyword<'a>(text: &'a str, keyword: &str) -> Option<&'a str> {
+    let rest = text.trim_start().strip_prefix(keyword)?;
+    if rest.starts_with(|c: char| c.is_alphanumeric() || c == '_') {
+        return None;
+    }
+    Some(rest.trim_start())
+}
+
+/// How signatures are rendered in tool responses
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Default)]
+pub enum SignatureStyle {
+    /// The declaration as written, with all structured details
+    #[default]
+    Full,
+    /// `Name[T](a, b, ...rest) -> Return`
+    Compact,
+    /// Just the symbol name
+    NameOnly,
+}
+
+impl SignatureStyle {
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.to_lowercase().as_str() {
+            "full" => Some(SignatureStyle::Full),
+            "compact" => Some(SignatureStyle::Compact),
+            "name_only" | "name" => Some(SignatureStyle::NameOnly),
+            _ => None,
+        }
+    }
+}
+
+impl Signature {
+    /// Render the signature of symbol `name` in the given style
+    pub fn render(&self, name: &str, style: SignatureStyle) -> String {
+        match style {
+            SignatureStyle::Full => self.text.clone(),
+            SignatureStyle::NameOnly => name.to_string(),
+            SignatureStyle::Compact => {
+                let mut rendered = name.to_string();
+                if !self.type_parameters.is_empty() {
+                    rendered.push_str(&format!("[{}]", self.type_parameters.join(", ")));
+                }
+
+                let params: Vec<String> = self
+                    .parameters
+                    .iter()
+                    .map(|param| {
+                        let label = param
+                            .name
+                            .as_deref()
+                            .or(param.type_name.as_deref())
+                            .unwrap_or("_");
+                        // A channel's direction and element are part of its contract
+                        let label = match (&param.name, &param.channel) {
+                            (Some(name), Some(channel)) => {
+                                format!("{} {}", name, channel.render())
+                            }
+                            _ => label.to_string(),
+                        };
+                        if param.variadic {
+                            format!("...{}", label)
+                        } else if let Some(default_value) = &param.default_value {
+                            format!("{}={}", label, default_value)
+                        } else if param.optional {
+                            format!("{}?", label)
+                        } else {
+                            label
+                        }
+                    })
+                    .collect();
+                rendered.push_str(&format!("({})", params.join(", ")));
+
+                if let Some(return_type) = &self.return_type {
+                    rendered.push_str(&format!(" -> {}", return_type));
+                }
+                rendered
+            }
+        }
+    }
+
+    /// Copy of this signature reduced to the requested style, dropping structured
+    /// details for the compact styles to keep payloads small
+    pub fn styled(&self, name: &str, style: SignatureStyle) -> Signature {
+        match style {
+            SignatureStyle::Full => self.clone(),
+            _ => Signature {
+                text: self.render(name, style),
+                receiver: None,
+                type_parameters: Vec::new(),
+                parameters: Vec::new(),
+                return_type: None,
+                return_channels: Vec::new(),
+                uses_defer: false,
+                deferred: Vec::new(),
+            },
+        }
+    }
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Encode, Decode)]
@@ -41,9 +293,27 @@ pub enum SymbolType {
     Enum,
     Struct,
     Import,
+    Test,
+    /// An HTTP route registered with a recognized router call
+    Route,
 }
 
 impl SymbolType {
+    pub const ALL: [SymbolType; 12] = [
+        SymbolType::Module,
+        SymbolType::Class,
+        SymbolType::Interface,
+        SymbolType::Method,
+        SymbolType::Function,
+        SymbolType::Constant,
+        SymbolType::Variable,
+        SymbolType::Enum,
+        SymbolType::Struct,
+        SymbolType::Import,
+        SymbolType::Test,
+        SymbolType::Route,
+    ];
+
     pub fn as_str(&self) -> &'static str {
         match self {
             SymbolType::Module => "module",
@@ -56,6 +326,27 @@ impl SymbolType {
             SymbolType::Enum => "enum",
             SymbolType::Struct => "struct",
             SymbolType::Import => "import",
+            SymbolType::Test => "test",
+            SymbolType::Route => "route",
+        }
+    }
+
+    /// Parse a kind name as accepted by tool filters and configuration
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name.trim().to_lowercase().as_str() {
+            "function" => Some(SymbolType::Function),
+            "method" => Some(SymbolType::Method),
+            "class" => Some(SymbolType::Class),
+            "struct" => Some(SymbolType::Struct),
+            "enum" => Some(SymbolType::Enum),
+            "interface" | "trait" => Some(SymbolType::Interface),
+            "constant" | "const" => Some(SymbolType::Constant),
+            "variable" | "var" => Some(SymbolType::Variable),
+            "module" | "mod" => Some(SymbolType::Module),
+            "import" => Some(SymbolType::Import),
+            "test" => Some(SymbolType::Test),
+            "route" => Some(SymbolType::Route),
+            _ => None,
         }
     }
 }
@@ -70,6 +361,7 @@ pub enum Visibility {
 
 #[derive(Debug, Clone, Serialize, Deserialize, Encode, Decode)]
 pub struct Location {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
     pub file: PathBuf,
     pub start_line: u32,
     pub start_column: u32,
@@ -108,6 +400,8 @@ pub enum ReferenceType {
     Usage,
     Import,
     Call,
+    /// The type of a composite literal such as Go `User{...}` or `&User{...}`
+    Construction,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize, Encode, Decode)]
@@ -168,6 +462,8 @@ pub enum ParseStatus {
     Success,
     PartialSuccess(Vec<String>),
     Failed(String),
+    /// Deliberately not parsed (oversized or binary file), with the reason
+    Skipped(String),
     NotParsed,
 }
 
@@ -188,9 +484,61 @@ pub enum Language {
     Swift,
     PHP,
     ObjectiveC,
+    Lua,
 }
 
 impl Language {
+    /// Every supported language
+    pub const ALL: [Language; 16] = [
+        Language::Rust,
+        Language::Python,
+        Language::C,
+        Language::Cpp,
+        Language::Java,
+        Language::Go,
+        Language::JavaScript,
+        Language::TypeScript,
+        Language::Ruby,
+        Language::CSharp,
+        Language::Kotlin,
+        Language::Scala,
+        Language::Swift,
+        Language::PHP,
+        Language::ObjectiveC,
+        Language::Lua,
+    ];
+
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Language::Rust => "rust",
+            Language::Python => "python",
+            Language::C => "c",
+            Language::Cpp => "cpp",
+            Language::Java => "java",
+            Language::Go => "go",
+            Language::JavaScript => "javascript",
+            Language::TypeScript => "typescript",
+            Language::Ruby => "ruby",
+            Language::CSharp => "csharp",
+            Language::Kotlin => "kotlin",
+            Language::Scala => "scala",
+            Language::Swift => "swift",
+            Language::PHP => "php",
+            Language::ObjectiveC => "objc",
+            Language::Lua => "lua",
+        }
+    }
+
+    /// Parse a language from its name (`go`, `python`) or file extension (`py`)
+    pub fn from_name(name: &str) -> Option<Self> {
+        let name = name.trim().to_lowercase();
+        Self::ALL
+            .iter()
+            .copied()
+            .find(|language| language.as_str() == name)
+            .or_else(|| Self::from_extension(&name))
+    }
+
     pub fn from_extension(ext: &str) -> Option<Self> {
         match ext.to_lowercase().as_str() {
             "rs" => Some(Language::Rust),
@@ -208,6 +556,7 @@ impl Language {
             "swift" => Some(Language::Swift),
             "php" => Some(Language::PHP),
             "m" | "mm" => Some(Language::ObjectiveC),
+            "lua" => Some(Language::Lua),
             _ => None,
         }
     }
@@ -235,6 +584,7 @@ impl Language {
             Language::Swift => tree_sitter_swift::LANGUAGE.into(),
             Language::PHP => tree_sitter_php::LANGUAGE_PHP.into(),
             Language::ObjectiveC => tree_sitter_objc::LANGUAGE.into(),
+            Language::Lua => tree_sitter_lua::LANGUAGE.into(),
         }
     }
 
@@ -255,6 +605,7 @@ impl Language {
             Language::Swift => &["swift"],
             Language::PHP => &["php"],
             Language::ObjectiveC => &["m", "mm"],
+            Language::Lua => &["lua"],
         }
     }
 
@@ -299,6 +650,191 @@ mod tests {
         assert_eq!(SymbolType::Module.as_str(), "module");
     }
 
+    #[test]
+    fn test_signature_rendering() {
+        let signature = Signature {
+            text: "func (p *PostgresConnection) ExecuteQuery(ctx context.Context, query string, args ...interface{}) (*QueryResult, error)".to_string(),
+            receiver: Some("p *PostgresConnection".to_string()),
+            type_parameters: Vec::new(),
+            parameters: vec![
+                Parameter {
+                    name: Some("ctx".to_string()),
+                    type_name: Some("context.Context".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("query".to_string()),
+                    type_name: Some("string".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("args".to_string()),
+                    type_name: Some("interface{}".to_string()),
+                    variadic: true,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+            ],
+            return_type: Some("(*QueryResult, error)".to_string()),
+            return_channels: Vec::new(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        };
+
+        assert_eq!(
+            signature.render("ExecuteQuery", SignatureStyle::Compact),
+            "ExecuteQuery(ctx, query, ...args) -> (*QueryResult, error)"
+        );
+        assert_eq!(
+            signature.render("ExecuteQuery", SignatureStyle::NameOnly),
+            "ExecuteQuery"
+        );
+        assert_eq!(
+            signature.render("ExecuteQuery", SignatureStyle::Full),
+            signature.text
+        );
+
+        let generic = Signature {
+            text: "func Map[T any, U any](xs []T, f func(T) U) []U".to_string(),
+            receiver: None,
+            type_parameters: vec!["T".to_string(), "U".to_string()],
+            parameters: vec![
+                Parameter {
+                    name: Some("xs".to_string()),
+                    type_name: Some("[]T".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("f".to_string()),
+                    type_name: Some("func(T) U".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+            ],
+            return_type: Some("[]U".to_string()),
+            return_channels: Vec::new(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        };
+        assert_eq!(
+            generic.render("Map", SignatureStyle::Compact),
+            "Map[T, U](xs, f) -> []U"
+        );
+
+        let with_defaults = Signature {
+            text: "def connect(self, host, timeout=30, *, retries: int | None = None)".to_string(),
+            receiver: Some("self".to_string()),
+            type_parameters: Vec::new(),
+            parameters: vec![
+                Parameter {
+                    name: Some("host".to_string()),
+                    type_name: None,
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("timeout".to_string()),
+                    type_name: None,
+                    variadic: false,
+                    optional: true,
+                    default_value: Some("30".to_string()),
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("retries".to_string()),
+                    type_name: Some("int | None".to_string()),
+                    variadic: false,
+                    optional: true,
+                    default_value: Some("None".to_string()),
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("verbose".to_string()),
+                    type_name: Some("boolean".to_string()),
+                    variadic: false,
+                    optional: true,
+                    default_value: None,
+                    channel: None,
+                },
+            ],
+            return_type: None,
+            return_channels: Vec::new(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        };
+        assert_eq!(
+            with_defaults.render("connect", SignatureStyle::Compact),
+            "connect(host, timeout=30, retries=None, verbose?)"
+        );
+        assert_eq!(
+            SignatureStyle::from_name("compact"),
+            Some(SignatureStyle::Compact)
+        );
+        assert_eq!(SignatureStyle::from_name("verbose"), None);
+    }
+
+    #[test]
+    fn test_channel_types() {
+        let receive = ChannelType::parse("<-chan User").unwrap();
+        assert_eq!(receive.direction, ChannelDirection::Receive);
+        assert_eq!(receive.element_type, "User");
+        let send = ChannelType::parse("chan<- *Event").unwrap();
+        assert_eq!(send.direction, ChannelDirection::Send);
+        assert_eq!(send.render(), "chan<- *Event");
+        let both = ChannelType::parse("chan struct{}").unwrap();
+        assert_eq!(both.direction, ChannelDirection::Bidirectional);
+        assert_eq!(both.element_type, "struct{}");
+        assert_eq!(ChannelType::parse("channel.Config"), None);
+        assert_eq!(ChannelType::parse("[]chan int"), None);
+
+        let subscribe = Signature {
+            text: "func (b *Broker) Subscribe(topic string, done <-chan struct{}) <-chan Message"
+                .to_string(),
+            receiver: Some("b *Broker".to_string()),
+            type_parameters: Vec::new(),
+            parameters: vec![
+                Parameter {
+                    name: Some("topic".to_string()),
+                    type_name: Some("string".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: None,
+                },
+                Parameter {
+                    name: Some("done".to_string()),
+                    type_name: Some("<-chan struct{}".to_string()),
+                    variadic: false,
+                    optional: false,
+                    default_value: None,
+                    channel: ChannelType::parse("<-chan struct{}"),
+                },
+            ],
+            return_type: Some("<-chan Message".to_string()),
+            return_channels: ChannelType::parse("<-chan Message").into_iter().collect(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        };
+        assert_eq!(
+            subscribe.render("Subscribe", SignatureStyle::Compact),
+            "Subscribe(topic, done <-chan struct{}) -> <-chan Message"
+        );
+    }
+
     #[test]
     fn test_serialization() {
         let path = PathBuf::from("test.rs");
@@ -313,6 +849,10 @@ mod tests {
             namespace: None,
             visibility: Visibility::Public,
             source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
         };
 
         // Test serialization/deserialization
@@ -321,5 +861,33 @@ mod tests {
 
         assert_eq!(symbol.name, deserialized.name);
         assert_eq!(symbol.symbol_type, deserialized.symbol_type);
+        assert!(serialized.contains("\"tags\":{}"));
+    }
+
+    #[test]
+    fn test_tag_filter() {
+        let path = PathBuf::from("billing.go");
+        let mut symbol = Symbol {
+            id: SymbolId::new(&path, 3, 0),
+            name: "Charge".to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location::new(path, 3, 0, 5, 1),
+            namespace: None,
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        };
+        assert!(!symbol.has_tag("owner"));
+
+        symbol
+            .tags
+            .insert("owner".to_string(), "payments-team".to_string());
+        assert!(symbol.has_tag("owner"));
+        assert!(symbol.has_tag("owner:payments-team"));
+        assert!(symbol.has_tag("Owner: payments-team"));
+        assert!(!symbol.has_tag("owner:search-team"));
     }
 }
@@ -6,7 +6,8 @@ pub mod storage;
 pub mod utils;
 
 // Re-export main types to avoid conflicts
-pub use indexing::{IndexingPipeline, SymbolIndexer};
+pub use indexing::frontend::{FrontendOutput, FrontendRegistry, LanguageFrontend};
+pub use indexing::{IndexProgress, IndexingPipeline, SymbolIndexer};
 pub use languages::*;
 pub use mcp::CodeAnalysisTools;
 pub use search::BM25CodeIndex;
@@ -1,27 +1,32 @@
 use anyhow::Result;
 use roberto_mcp::CodeAnalysisTools;
+use roberto_mcp::mcp::enable_reindex_on_sighup;
+use roberto_mcp::utils::{init_logging, reindex_on_sighup_from_env, LogConfig};
 use rmcp::{transport::stdio, ServiceExt};
-use tracing_subscriber::EnvFilter;
 
 #[tokio::main]
 async fn main() -> Result<()> {
-    // Initialize logging with stderr output
-    tracing_subscriber::fmt()
-        .with_env_filter(EnvFilter::from_default_env())
-        .with_writer(std::io::stderr)
-        .with_ansi(false)
-        .init();
+    // Logs go to stderr or a file; stdout carries the MCP protocol
+    init_logging(&LogConfig::from_env()).map_err(|e| anyhow::anyhow!("{}", e))?;
 
     tracing::info!("Starting CodeCortext MCP Server");
 
+    // Operators can send SIGHUP after deploying code to refresh the index
+    if reindex_on_sighup_from_env() {
+        if let Err(e) = enable_reindex_on_sighup() {
+            tracing::warn!("Re-indexing on SIGHUP is unavailable: {}", e);
+        }
+    }
+
     // Create and serve the server via stdio
-    let service = CodeAnalysisTools::new()
-        .serve(stdio())
-        .await
-        .inspect_err(|e| {
-            tracing::error!("Serving error: {:?}", e);
-        })?;
+    let tools = CodeAnalysisTools::new();
+    let service = tools.clone().serve(stdio()).await.inspect_err(|e| {
+        tracing::error!("Serving error: {:?}", e);
+    })?;
 
-    service.waiting().await?;
+    let quit = service.waiting().await;
+    // Stop pushing index changes to the closed connection
+    tools.end_session();
+    quit?;
     Ok(())
 }
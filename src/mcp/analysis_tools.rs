@@ -0,0 +1,7180 @@
+use crate::indexing::body_diff::{diff_bodies, BodyDiff, DiffGranularity};
+use crate::indexing::call_graph::{
+    has_pointer_receiver, receiver_type, strongly_connected_components, CallGraph,
+};
+use crate::indexing::centrality::page_rank;
+use crate::indexing::churn::{symbol_churn, LineSpan};
+use crate::indexing::construction::{find_composite_literals, CompositeLiteral};
+use crate::indexing::duplicates::{body_tokens, cluster, BodyFingerprint};
+use crate::indexing::error_sentinels::{find_go_errors, ReturnedName};
+use crate::indexing::go_enums::{find_go_enums, EnumMember};
+use crate::indexing::go_init::{find_go_initializers, init_order, InitKind, InitStep};
+use crate::indexing::implementations::{declared_supertypes, satisfies};
+use crate::indexing::interface_contract::{
+    error_interface_methods, go_interface, ContractMethod, GoInterface,
+};
+use crate::indexing::json_schema::{
+    field_map, go_type_definition, struct_schema, type_schema, GoTypeDefinition,
+};
+use crate::indexing::layering::{LayeringPolicy, Package};
+use crate::indexing::magic_literals::{
+    find_magic_literals, LiteralFilter, LiteralKind, MagicLiteral,
+};
+use crate::indexing::markers::{find_markers, Marker, MarkerKind};
+use crate::indexing::package_usage::{find_package_uses, PackageUse};
+use crate::indexing::panics::{find_panic_sites, PanicSite, PanicSiteKind};
+use crate::indexing::receiver_types::{
+    find_receiver_calls, PackageTypes, ReceiverCall, ResolutionStatus,
+};
+use crate::indexing::return_counts::{EARLY_RETURN_COUNT_TAG, RETURN_COUNT_TAG};
+use crate::indexing::routes::{
+    handler_name, RoutePatterns, ROUTE_HANDLER_TAG, ROUTE_METHOD_TAG, ROUTE_PATH_TAG,
+    ROUTE_ROUTER_TAG,
+};
+use crate::indexing::scala::EXTENDS_TAG;
+use crate::indexing::side_effects::{find_function_effects, FunctionEffects, SideEffect};
+use crate::indexing::signature_compat::{compare_signatures, return_values, SignatureComparison};
+use crate::indexing::sql_queries::{find_sql_queries, table_matches, SqlOperation, SqlQuery};
+use crate::indexing::symbol_analysis::analyze_definition;
+use crate::indexing::test_detection::{is_test_file, test_covers};
+use crate::indexing::type_assertions::{find_type_casts, CastForm, TypeCast};
+use crate::indexing::type_closure::{candidate_type_names, dependency_order};
+use crate::indexing::type_forms::TYPE_EXPR_TAG;
+use crate::indexing::type_members::{
+    base_type_name, extract_type_members, promote_embedded, TypeMembers,
+};
+use crate::indexing::type_usage::{mentions_type, type_query_base};
+use crate::mcp::budget::{fit_to_budget, TimeBudget};
+use crate::mcp::encoding::encode_response;
+use crate::mcp::graph_export::{render_dot, render_mermaid, GraphEdge, GraphNode, GRAPH_FORMATS};
+use crate::mcp::outline_tools::OutlineTools;
+use crate::mcp::tools::{
+    blame_files, cancelled_error, get_symbol_store, indexed_files, vendor_paths,
+};
+use crate::models::{
+    ChannelDirection, ChannelType, DeferredCall, Language, Location, Reference, ReferenceType,
+    Signature, Symbol, SymbolId, SymbolType, Visibility,
+};
+use crate::utils::error::CodeAnalysisError;
+use crate::utils::{
+    git_commit_diffs, git_worktree_diff, split_location, BlameInfo, CommitInfo, PathResolver,
+};
+use crate::SymbolStore;
+use rmcp::model::{CallToolResult, ErrorCode, ErrorData};
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+use serde_json::{json, Map, Value};
+use std::collections::{BTreeMap, HashMap, HashSet, VecDeque};
+use std::path::{Path, PathBuf};
+use std::time::SystemTime;
+use tokio_util::sync::CancellationToken;
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindTestsForRequest {
+    /// Name of the function or method to find tests for
+    pub name: String,
+    /// Optional package/module/namespace the tests must belong to
+    pub namespace: Option<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindTestsForResponse {
+    pub symbol: String,
+    pub tests: Vec<Symbol>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindUntestedRequest {
+    /// Optional directory to restrict the search to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Maximum number of results to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindUntestedResponse {
+    pub symbols: Vec<Symbol>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct CompareSignaturesRequest {
+    /// ID of the symbol before the change
+    pub old_id: u64,
+    /// ID of the symbol after the change
+    pub new_id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct CompareSignaturesResponse {
+    pub old_signature: String,
+    pub new_signature: String,
+    #[serde(flatten)]
+    pub comparison: SignatureComparison,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListRecursiveFunctionsRequest {
+    /// Optional directory to restrict the search to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Maximum number of results to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RecursiveFunction {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    pub is_recursive: bool,
+    /// Every function in the cycle, including this one; only this one for
+    /// direct self-recursion
+    pub recursion_cycle: Vec<RelatedSymbol>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListRecursiveFunctionsResponse {
+    pub functions: Vec<RecursiveFunction>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindDuplicatesRequest {
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Bodies with fewer normalized tokens are ignored (default: 40)
+    pub min_tokens: Option<u32>,
+    /// Minimum similarity from 0 to 1; 1 finds only exact clones (default: 0.9)
+    pub min_similarity: Option<f64>,
+    /// Also compare test functions (default: false)
+    pub include_tests: Option<bool>,
+    /// Maximum number of clusters to return (default: 20)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DuplicateMember {
+    pub name: String,
+    pub id: u64,
+    pub file: String,
+    pub start_line: u32,
+    pub end_line: u32,
+    /// Normalized tokens in the body
+    pub tokens: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DuplicateCluster {
+    /// Lowest similarity between two members, 1.0 for exact clones
+    pub similarity: f64,
+    pub identical: bool,
+    pub members: Vec<DuplicateMember>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindDuplicatesResponse {
+    pub clusters: Vec<DuplicateCluster>,
+    pub functions_compared: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetJsonSchemaRequest {
+    /// ID of the Go type to describe
+    pub id: u64,
+    /// "schema" for a JSON Schema document (default) or "fields" for a
+    /// simplified property-to-type map
+    pub format: Option<String>,
+    /// How many levels of referenced types to expand into $defs (default: 5)
+    pub max_depth: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetJsonSchemaResponse {
+    pub type_name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub schema: Option<Value>,
+    /// Property name to type; optional (`omitempty`) properties end in `?`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub fields: Option<Map<String, Value>>,
+    /// Referenced types that are not in the index
+    pub unresolved_types: Vec<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetInterfaceContractRequest {
+    /// ID of the Go interface type
+    pub id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetInterfaceContractResponse {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// The full method set, embedded interfaces expanded, in declaration order
+    pub methods: Vec<ContractMethod>,
+    /// Interfaces embedded directly or through other embedded interfaces
+    pub embedded: Vec<String>,
+    /// Embedded interfaces that are not in the index; their methods are missing
+    pub unresolved_embedded: Vec<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetSatisfiedInterfacesRequest {
+    /// ID of the Go type
+    pub id: u64,
+}
+
+/// Which form of a Go type has the methods an interface needs
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ReceiverForm {
+    /// Both `T` and `*T` satisfy the interface
+    Value,
+    /// Only `*T` does: some of the methods have pointer receivers
+    Pointer,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SatisfiedInterface {
+    pub id: u64,
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub receiver: ReceiverForm,
+    /// For `pointer`: the interface's methods declared on `*T`
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub pointer_methods: Vec<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetSatisfiedInterfacesResponse {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub interfaces: Vec<SatisfiedInterface>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetEnumsRequest {
+    /// Optional file or directory to restrict the listing to
+    pub path: Option<String>,
+    /// Optional package filter
+    pub namespace: Option<String>,
+    /// Optional enum name (the declared type, e.g. `Weekday`)
+    pub name: Option<String>,
+    /// Maximum number of enums to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct EnumMemberInfo {
+    #[serde(flatten)]
+    pub member: EnumMember,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct EnumInfo {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_name: Option<String>,
+    /// ID of the declared type, when it is indexed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_id: Option<u64>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub end_line: u32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    pub members: Vec<EnumMemberInfo>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetEnumsResponse {
+    pub enums: Vec<EnumInfo>,
+    pub total_found: usize,
+}
+
+/// Ways `find_by_type_usage` reports a type being used
+const TYPE_USAGE_KINDS: &[&str] = &["param", "return", "field"];
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindByTypeUsageRequest {
+    /// Type to look for, e.g. `*QueryResult` or `time.Time`
+    pub type_name: String,
+    /// Usage kinds to include (default: all). One or more of param, return, field
+    pub usage: Option<Vec<String>>,
+    /// Only match Go channels of `type_name` with this direction: send,
+    /// receive or bidirectional
+    pub channel: Option<String>,
+    /// Optional directory to restrict the search to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Maximum number of usages to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct TypeUsage {
+    /// The function, method or type using the type
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// param, return or field
+    pub usage: String,
+    /// Parameter or field name
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub member: Option<String>,
+    /// The type expression as written
+    pub type_text: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindByTypeUsageResponse {
+    pub type_name: String,
+    pub usages: Vec<TypeUsage>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListEntryPointsRequest {
+    /// Optional directory to restrict the listing to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Also list functions without callers from other packages (default: false)
+    pub include_internal: Option<bool>,
+    /// Maximum number of functions to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct EntryPoint {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// Called from at least one function outside its package
+    pub is_entry_point: bool,
+    /// Not exported, yet called from another package
+    pub crosses_visibility: bool,
+    /// Callers outside the package, capped at 20
+    pub external_callers: Vec<RelatedSymbol>,
+    pub external_caller_count: usize,
+    pub internal_caller_count: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListEntryPointsResponse {
+    pub functions: Vec<EntryPoint>,
+    pub total_found: usize,
+    pub entry_point_count: usize,
+    pub internal_count: usize,
+}
+
+/// Sections `explain_symbol` can include
+const EXPLAIN_SECTIONS: &[&str] = &[
+    "source",
+    "doc",
+    "signature",
+    "types",
+    "callers",
+    "callees",
+    "errors",
+    "complexity",
+];
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ExplainSymbolRequest {
+    /// ID of the symbol to explain
+    pub id: u64,
+    /// Sections to include (default: all). One or more of source, doc,
+    /// signature, types, callers, callees, errors, complexity
+    pub sections: Option<Vec<String>>,
+    /// Maximum number of source lines to include (default: 80)
+    pub max_source_lines: Option<u32>,
+    /// Maximum number of entries in each list section (default: 20)
+    pub max_items: Option<u32>,
+    /// Drop list entries, least important sections first, once the
+    /// response would exceed this many estimated tokens
+    pub max_tokens: Option<u32>,
+}
+
+/// A symbol mentioned by the one being explained, resolved against the index
+/// where possible
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RelatedSymbol {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub line: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct Complexity {
+    pub cyclomatic: u32,
+    pub lines: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ExplainSymbolResponse {
+    pub symbol: Symbol,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub source: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub source_truncated: Option<bool>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub doc_comment: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub signature: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub referenced_types: Option<Vec<RelatedSymbol>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub callers: Option<Vec<RelatedSymbol>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub callees: Option<Vec<RelatedSymbol>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub possible_errors: Option<Vec<String>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub complexity: Option<Complexity>,
+}
+
+/// Edges `get_neighbors` can follow
+const NEIGHBOR_EDGES: &[&str] = &[
+    "calls",
+    "called_by",
+    "references",
+    "referenced_by",
+    "implements",
+    "implemented_by",
+    "uses_type",
+];
+
+/// Deepest walk `get_neighbors` does; fan-out grows quickly with depth
+const MAX_NEIGHBOR_DEPTH: u32 = 3;
+
+/// Deepest walk `export_graph` does; the node cap bounds its output
+const MAX_GRAPH_DEPTH: u32 = 5;
+
+/// Most nodes `export_graph` draws
+const MAX_GRAPH_NODES: u32 = 500;
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetNeighborsRequest {
+    /// ID of the symbol to start from
+    pub id: u64,
+    /// Edges to follow. One or more of calls, called_by, references,
+    /// referenced_by, implements, implemented_by, uses_type
+    pub edges: Vec<String>,
+    /// Number of steps to walk (default: 1, at most 3)
+    pub depth: Option<u32>,
+    /// Maximum number of neighbors to return, and to follow from any one
+    /// symbol along one edge (default: 50)
+    pub limit: Option<u32>,
+    /// Time budget in milliseconds; when it runs out the neighbors found so
+    /// far are returned with the frontier left to walk (default: none)
+    pub timeout_ms: Option<u64>,
+}
+
+/// A symbol reached but not walked from when a graph query ran out of time.
+/// Walking from it with the remaining depth continues the query; edges it
+/// was partly walked along may be returned again.
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FrontierSymbol {
+    pub id: u64,
+    pub name: String,
+    /// Steps from the starting symbol
+    pub depth: u32,
+}
+
+impl FrontierSymbol {
+    fn new(symbol: &Symbol, depth: u32) -> Self {
+        Self {
+            id: symbol.id.0,
+            name: symbol.name.clone(),
+            depth,
+        }
+    }
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct Neighbor {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// The edge followed to reach this symbol
+    pub edge: String,
+    /// The symbol the edge starts from
+    pub from: u64,
+    /// Steps from the starting symbol
+    pub depth: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetNeighborsResponse {
+    pub symbol: RelatedSymbol,
+    pub neighbors: Vec<Neighbor>,
+    /// Some neighbors were left out to stay within `limit` or `timeout_ms`
+    pub truncated: bool,
+    /// The walk stopped when `timeout_ms` ran out
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub timed_out: bool,
+    /// After a timeout, the starting symbol or neighbors not yet walked from
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub frontier: Vec<FrontierSymbol>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindSqlRequest {
+    /// Table to look for; `users` also matches `public.users`
+    pub table: String,
+    /// Only queries of this kind: select, insert, update or delete
+    pub operation: Option<String>,
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Maximum number of queries to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SqlUsage {
+    /// The innermost function, method, constant or variable holding the query
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol: Option<RelatedSymbol>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol_type: Option<SymbolType>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub query: SqlQuery,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindSqlResponse {
+    pub table: String,
+    pub queries: Vec<SqlUsage>,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+/// Methods that release a resource, matched by `find_resource_cleanup`
+/// when no `methods` are given
+const CLEANUP_METHODS: &[&str] = &[
+    "Close", "Rollback", "Unlock", "RUnlock", "Done", "Stop", "Release", "Flush", "End", "Cancel",
+    "cancel",
+];
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindResourceCleanupRequest {
+    /// Deferred methods to look for, matched against the last part of the
+    /// call (`Close` matches `resp.Body.Close`). Default: Close, Rollback,
+    /// Unlock, RUnlock, Done, Stop, Release, Flush, End, Cancel, cancel
+    pub methods: Option<Vec<String>>,
+    /// Optional directory to restrict the search to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Maximum number of functions to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct CleanupFunction {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// The matching deferred calls
+    pub deferred: Vec<DeferredCall>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindResourceCleanupResponse {
+    pub functions: Vec<CleanupFunction>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetInitOrderRequest {
+    /// Directory of the Go package, or a file in it
+    pub path: String,
+    /// Include `_test.go` files, which are initialized with the package in
+    /// test binaries (default: false)
+    pub include_tests: Option<bool>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct InitOrderEntry {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    #[serde(flatten)]
+    pub step: InitStep,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetInitOrderResponse {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub package: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub directory: PathBuf,
+    /// The package's files in the order the compiler sees them
+    pub files: Vec<String>,
+    pub order: Vec<InitOrderEntry>,
+    pub init_functions: usize,
+    /// Vars whose initializers call functions
+    pub non_trivial_vars: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetSymbolChurnRequest {
+    /// Start of the commit range, exclusive (e.g. `v1.2.0`, `HEAD~50`)
+    pub from: String,
+    /// End of the commit range, inclusive (default: HEAD)
+    pub to: Option<String>,
+    /// Maximum number of commits to walk back from `to` (default: 100, max: 1000)
+    pub max_commits: Option<u32>,
+    /// Optional directory to restrict the analysis to
+    pub path: Option<String>,
+    /// Maximum number of symbols to return (default: 50)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ChurnedSymbol {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// Number of commits in the range that changed the symbol's lines
+    pub churn: usize,
+    /// Those commits, newest first
+    pub commits: Vec<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetSymbolChurnResponse {
+    pub from: String,
+    pub to: String,
+    pub commits_scanned: usize,
+    /// The range holds more than `max_commits` commits; older ones were not read
+    pub truncated: bool,
+    pub symbols: Vec<ChurnedSymbol>,
+    /// Details of the commits the returned symbols list, newest first
+    pub commits: Vec<CommitInfo>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListErrorSentinelsRequest {
+    /// Optional file or directory to restrict the sentinels to; return
+    /// sites are searched in every indexed Go file
+    pub path: Option<String>,
+    /// Optional package filter
+    pub namespace: Option<String>,
+    /// Maximum number of sentinels to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ErrorReturnSite {
+    /// The function or method holding the `return`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<RelatedSymbol>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// Returned wrapped by `fmt.Errorf("...: %w", ...)` or `errors.Join`
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub wrapped: bool,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SentinelInfo {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub message: Option<String>,
+    pub return_count: usize,
+    pub return_sites: Vec<ErrorReturnSite>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListErrorSentinelsResponse {
+    pub sentinels: Vec<SentinelInfo>,
+    /// Sentinels no function returns: likely dead errors
+    pub never_returned: Vec<String>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListTypeAssertionsRequest {
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Only casts inside functions or methods with this name
+    pub function: Option<String>,
+    /// Only unchecked `x.(T)` assertions, which panic on a mismatch (default: false)
+    pub unchecked_only: Option<bool>,
+    /// Also report conversions such as `int64(n)` (default: true)
+    pub include_conversions: Option<bool>,
+    /// Maximum number of casts to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct TypeCastInfo {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub cast: TypeCast,
+    /// The indexed function or method holding the cast
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function_id: Option<u64>,
+    /// An unchecked assertion: panics when the value holds another type
+    pub panics: bool,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListTypeAssertionsResponse {
+    pub casts: Vec<TypeCastInfo>,
+    pub unchecked: usize,
+    pub checked: usize,
+    pub conversions: usize,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindUsersOfPackageRequest {
+    /// Import path of the package, e.g. `encoding/json`; `prefix/...`
+    /// matches every package under `prefix`
+    pub import_path: String,
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Maximum number of functions to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct PackageUserInfo {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub usage: PackageUse,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindUsersOfPackageResponse {
+    pub import_path: String,
+    pub functions: Vec<PackageUserInfo>,
+    /// Files importing a matching package
+    pub importing_files: usize,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListByReturnCountRequest {
+    /// Optional directory to restrict the search to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Only functions with at least this many returns (default: 1)
+    pub min_returns: Option<u32>,
+    /// Only functions with at least this many early returns (default: 0)
+    pub min_early_returns: Option<u32>,
+    /// Rank by `returns` (default) or `early_returns`
+    pub sort_by: Option<String>,
+    /// Also compute the cyclomatic complexity of each returned function
+    pub include_complexity: Option<bool>,
+    /// Maximum number of results to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ReturnCountInfo {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    pub returns: u32,
+    /// Returns before the last statement of the body
+    pub early_returns: u32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub complexity: Option<Complexity>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListByReturnCountResponse {
+    pub functions: Vec<ReturnCountInfo>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ResolveReceiverTypesRequest {
+    /// Symbol ID of a Go function or method
+    pub id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ReceiverCallInfo {
+    #[serde(flatten)]
+    pub call: ReceiverCall,
+    /// `struct`, `interface` or `type` when the static type is declared in
+    /// the package
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_kind: Option<String>,
+    /// Indexed methods the call reaches: the method of a concrete package
+    /// type. Empty for interfaces, whose calls dispatch dynamically.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub targets: Vec<u64>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ResolveReceiverTypesResponse {
+    pub id: u64,
+    pub function: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub calls: Vec<ReceiverCallInfo>,
+    pub resolved: usize,
+    pub unresolved: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct InterfaceCoverageRequest {
+    /// Optional directory to restrict the reported interfaces to;
+    /// implementations are counted across the whole index
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Only report exported (public) interfaces
+    pub exported_only: Option<bool>,
+    /// Only report interfaces with at most this many implementations
+    pub max_implementations: Option<u32>,
+    /// Maximum number of interfaces to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+/// What an interface's implementation count suggests
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum CoverageStatus {
+    /// No implementations: possibly dead
+    Unimplemented,
+    /// Exactly one implementation: a candidate for inlining
+    SingleImplementation,
+    MultipleImplementations,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct Implementation {
+    pub id: u64,
+    pub name: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct InterfaceCoverage {
+    pub id: u64,
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub exported: bool,
+    pub status: CoverageStatus,
+    pub implementation_count: usize,
+    pub implementations: Vec<Implementation>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct InterfaceCoverageResponse {
+    pub interfaces: Vec<InterfaceCoverage>,
+    /// Interfaces matching the filters, before `max_implementations`
+    pub total_interfaces: usize,
+    pub unimplemented: usize,
+    pub single_implementation: usize,
+    pub multiple_implementations: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ExportGraphRequest {
+    /// ID of the symbol to start from
+    pub id: u64,
+    /// Edge to follow: calls (default), called_by, uses_type, implements,
+    /// implemented_by, references or referenced_by
+    pub edge_type: Option<String>,
+    /// Number of steps to walk (default: 2, at most 5)
+    pub depth: Option<u32>,
+    /// dot (default) or mermaid
+    pub format: Option<String>,
+    /// Maximum number of nodes to draw, the start included (default: 50,
+    /// at most 500)
+    pub max_nodes: Option<u32>,
+    /// Time budget in milliseconds; when it runs out the graph drawn so far
+    /// is returned with the frontier left to walk (default: none)
+    pub timeout_ms: Option<u64>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ExportGraphResponse {
+    pub format: String,
+    /// The graph as DOT or Mermaid text
+    pub graph: String,
+    pub nodes: usize,
+    pub edges: usize,
+    /// Nodes past `max_nodes` and the edges to them, or nodes past the
+    /// time budget, were left out
+    pub truncated: bool,
+    /// The walk stopped when `timeout_ms` ran out
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub timed_out: bool,
+    /// After a timeout, drawn nodes whose edges were not all walked
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub frontier: Vec<FrontierSymbol>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindMagicLiteralsRequest {
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Literal kinds to report: number, string (default: both)
+    pub kinds: Option<Vec<String>>,
+    /// Skip bare numbers whose magnitude is below this; 0 and 1 are always
+    /// skipped
+    pub min_number: Option<f64>,
+    /// Skip strings shorter than this many characters (default: 1)
+    pub min_string_length: Option<u32>,
+    /// Values never reported: numbers as written, strings without quotes
+    pub ignore: Option<Vec<String>>,
+    /// Only report values that occur at least this often in the searched
+    /// files (default: 1)
+    pub min_occurrences: Option<u32>,
+    /// Maximum number of symbols to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LiteralUse {
+    #[serde(flatten)]
+    pub literal: MagicLiteral,
+    /// Times this value occurs in the searched files
+    pub occurrences: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SymbolLiterals {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub literals: Vec<LiteralUse>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindMagicLiteralsResponse {
+    pub symbols: Vec<SymbolLiterals>,
+    pub total_literals: usize,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListRoutesRequest {
+    /// Optional file or directory to restrict the listing to
+    pub path: Option<String>,
+    /// Only routes for this HTTP method; routes registered for any method
+    /// are always listed
+    pub method: Option<String>,
+    /// Only routes whose path starts with this prefix
+    pub prefix: Option<String>,
+    /// Maximum number of routes to return (default: 200)
+    pub limit: Option<u32>,
+}
+
+/// The function or method a route's handler names
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RouteHandler {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RouteInfo {
+    pub id: u64,
+    /// `ANY` for routes registered for every method
+    pub method: String,
+    pub path: String,
+    pub router: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// The handler argument as written
+    pub handler: String,
+    /// The indexed handler definition, when it is a named function or method
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub handler_symbol: Option<RouteHandler>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListRoutesResponse {
+    pub routes: Vec<RouteInfo>,
+    pub total_found: usize,
+    /// Set when route extraction is not configured
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub note: Option<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindConstructionSitesRequest {
+    /// Go type name, bare (`User`) or package-qualified (`models.User`)
+    pub type_name: String,
+    /// Optional file or directory to restrict the sites to
+    pub path: Option<String>,
+    /// Also list sites inside the type's constructors (default: true)
+    pub include_constructors: Option<bool>,
+    /// Maximum number of sites to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+/// A type or function a construction report refers to
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ConstructionSymbol {
+    pub id: u64,
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+}
+
+impl From<&Symbol> for ConstructionSymbol {
+    fn from(symbol: &Symbol) -> Self {
+        Self {
+            id: symbol.id.0,
+            name: symbol.name.clone(),
+            namespace: symbol.namespace.clone(),
+            file: symbol.location.file.clone(),
+            line: symbol.location.start_line,
+        }
+    }
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ConstructionSite {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    /// The type built, as written at the site
+    pub type_text: String,
+    pub type_id: u64,
+    pub line: u32,
+    pub column: u32,
+    pub pointer: bool,
+    /// Fields set by name
+    pub fields: Vec<String>,
+    /// Elements given without a field name
+    pub positional: usize,
+    /// The function, method or variable the literal is in
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub enclosing: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub enclosing_id: Option<u64>,
+    /// The literal is inside one of the type's constructors
+    pub in_constructor: bool,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindConstructionSitesResponse {
+    pub type_name: String,
+    /// The indexed types the name resolved to
+    pub types: Vec<ConstructionSymbol>,
+    /// Functions of each type's package whose first result is the type
+    pub constructors: Vec<ConstructionSymbol>,
+    pub sites: Vec<ConstructionSite>,
+    pub total_found: usize,
+    /// Sites outside the constructors, which bypass them
+    pub bypassing: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListHotspotsRequest {
+    /// Optional directory to restrict the results to
+    pub path: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Symbol kinds to rank, e.g. `["class", "function"]` (default: every
+    /// kind but variables and imports)
+    pub kinds: Option<Vec<String>>,
+    /// `references` (default) ranks by incoming references; `pagerank`
+    /// weighs each reference by how central the referencing symbol is
+    pub rank_by: Option<String>,
+    /// Maximum number of results to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct Hotspot {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// Incoming references from outside the symbol's own body
+    pub references: usize,
+    /// The incoming references that are calls
+    pub calls: usize,
+    /// Files the incoming references come from
+    pub referencing_files: usize,
+    /// PageRank relative to the average symbol, which scores 1.0
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub score: Option<f64>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListHotspotsResponse {
+    pub hotspots: Vec<Hotspot>,
+    pub ranked_by: String,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListPanicsRequest {
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Only functions or methods with this name
+    pub function: Option<String>,
+    /// Also report unchecked `x.(T)` assertions, which panic on a mismatch
+    /// (default: true)
+    pub include_implicit: Option<bool>,
+    /// Maximum number of functions to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FunctionPanics {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    /// The function or method; `None` at package level
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function: Option<String>,
+    /// The indexed function or method
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function_id: Option<u64>,
+    /// Direct `panic(...)` calls
+    pub explicit: Vec<PanicSite>,
+    /// Unchecked type assertions, which panic when the value holds another
+    /// type
+    pub implicit: Vec<TypeCast>,
+    /// `recover()` calls
+    pub recovers: Vec<PanicSite>,
+    /// A deferred closure calls `recover()`, so panics raised here are
+    /// stopped before reaching the caller
+    pub recovered: bool,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListPanicsResponse {
+    pub functions: Vec<FunctionPanics>,
+    pub explicit_panics: usize,
+    pub implicit_panics: usize,
+    pub recovers: usize,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetPackageGraphRequest {
+    /// Optional directory; only dependencies from or to packages under it
+    /// are returned
+    pub path: Option<String>,
+    /// Leave out dependencies with fewer references (default: 1)
+    pub min_references: Option<u32>,
+    /// Example references to return per dependency (default: 0)
+    pub examples: Option<u32>,
+    /// Maximum number of dependencies to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct PackageReference {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub column: u32,
+    pub reference_type: ReferenceType,
+    /// The symbol holding the reference
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub from_symbol: Option<String>,
+    /// The referenced symbol
+    pub symbol: String,
+    pub symbol_id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct PackageDependency {
+    pub from: Package,
+    pub to: Package,
+    /// References from code of `from` to symbols of `to`
+    pub references: usize,
+    /// Distinct symbols of `to` referenced
+    pub symbols: usize,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub examples: Vec<PackageReference>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct PackageNode {
+    #[serde(flatten)]
+    pub package: Package,
+    pub files: usize,
+    /// Packages this package depends on
+    pub depends_on: usize,
+    /// Packages depending on this package
+    pub depended_on_by: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetPackageGraphResponse {
+    pub packages: Vec<PackageNode>,
+    pub dependencies: Vec<PackageDependency>,
+    pub total_dependencies: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct CheckLayeringRequest {
+    /// JSON file holding the layering policy
+    pub policy_file: Option<String>,
+    /// The layering policy inline, instead of `policy_file`
+    pub policy: Option<LayeringPolicy>,
+    /// Optional directory; only dependencies from or to packages under it
+    /// are checked
+    pub path: Option<String>,
+    /// Example references to return per violation (default: 3)
+    pub examples: Option<u32>,
+    /// Maximum number of violations to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LayeringViolation {
+    /// The policy rule the dependency breaks
+    pub rule: String,
+    #[serde(flatten)]
+    pub dependency: PackageDependency,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct CheckLayeringResponse {
+    pub passed: bool,
+    pub violations: Vec<LayeringViolation>,
+    pub dependencies_checked: usize,
+    pub total_violations: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListPureFunctionsRequest {
+    /// Optional file or directory to restrict the results to
+    pub path: Option<String>,
+    /// Also list effectful functions with their effects (default: false)
+    pub include_effectful: Option<bool>,
+    /// Maximum number of functions to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FunctionPurity {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub function: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub receiver_type: Option<String>,
+    pub line: u32,
+    /// The indexed function or method
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub function_id: Option<u64>,
+    pub pure: bool,
+    /// Effects of the function's own body; `unknown_call` when it calls
+    /// something not found in the index
+    pub effects: Vec<SideEffect>,
+    /// For a function effectful only through what it calls, the first
+    /// effectful or unknown call: `s.repo.Save` shows as `.Save`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub via: Option<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListPureFunctionsResponse {
+    pub functions: Vec<FunctionPurity>,
+    pub pure: usize,
+    pub effectful: usize,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListStaleMarkersRequest {
+    /// Optional file or directory to restrict the search to
+    pub path: Option<String>,
+    /// Only these marker kinds (default: all of TODO, FIXME, HACK, XXX)
+    pub kinds: Option<Vec<MarkerKind>>,
+    /// Only markers at least this many days old; markers of unknown age
+    /// are left out when set
+    pub min_age_days: Option<u32>,
+    /// Maximum number of markers to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct StaleMarker {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub marker: Marker,
+    /// The innermost indexed symbol containing the marker
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol_id: Option<u64>,
+    /// Days since the commit that last touched the marker's line; absent
+    /// for files outside git
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub age_days: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub blame: Option<BlameInfo>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListStaleMarkersResponse {
+    pub markers: Vec<StaleMarker>,
+    /// Markers found of each kind, before `min_age_days` and `limit`
+    pub by_kind: BTreeMap<MarkerKind, usize>,
+    pub files_checked: usize,
+    /// Files with markers but no blame, being outside git or untracked
+    pub files_without_history: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct DiffBodiesRequest {
+    /// ID of the first definition
+    pub id_a: u64,
+    /// ID of the second definition
+    pub id_b: u64,
+    /// Compare whole lines or tokens (default: line)
+    pub granularity: Option<DiffGranularity>,
+    /// Compare lines with whitespace collapsed and blank lines skipped
+    /// (default: false)
+    pub ignore_whitespace: Option<bool>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DiffedDefinition {
+    pub id: u64,
+    pub name: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub start_line: u32,
+    pub end_line: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DiffBodiesResponse {
+    pub a: DiffedDefinition,
+    pub b: DiffedDefinition,
+    pub granularity: DiffGranularity,
+    /// No added or removed lines or tokens
+    pub identical: bool,
+    #[serde(flatten)]
+    pub diff: BodyDiff,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetTransitiveTypesRequest {
+    /// ID of the struct, class, interface or enum to start from
+    pub id: u64,
+    /// Namespaces or qualified type names (`models`, `models.Audit`) to
+    /// list without expanding, like types outside the index
+    #[serde(default)]
+    pub stop_at: Vec<String>,
+    /// Expand types of vendored code instead of stopping at them
+    /// (default: false)
+    pub include_vendor: Option<bool>,
+    /// Levels of referenced types to expand (default: 10)
+    pub max_depth: Option<u32>,
+    /// Include each type's definition source (default: true)
+    pub include_source: Option<bool>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct TransitiveType {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub namespace: Option<String>,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub start_line: u32,
+    pub end_line: u32,
+    /// Reference hops from the requested type, which is at 0
+    pub depth: u32,
+    /// IDs of the listed types this one references through its fields and
+    /// method signatures
+    pub depends_on: Vec<u64>,
+    /// `max_depth` stopped expansion here, so `depends_on` is empty
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub truncated: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub source: Option<String>,
+}
+
+/// Why a referenced type was not expanded
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum BoundaryReason {
+    /// Standard library or another package outside the index
+    NotIndexed,
+    /// Indexed under a vendor prefix
+    Vendored,
+    /// Matched by `stop_at`
+    StopAt,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct BoundaryType {
+    /// The name as referenced: `context.Context`
+    pub name: String,
+    pub reason: BoundaryReason,
+    /// Set for indexed types that were not expanded
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    /// Names of the listed types referencing it
+    pub referenced_by: Vec<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetTransitiveTypesResponse {
+    pub type_name: String,
+    /// The requested type and every type it needs, each listed after the
+    /// types it depends on; the requested type comes last
+    pub types: Vec<TransitiveType>,
+    /// Referenced types left out, sorted by name
+    pub external: Vec<BoundaryType>,
+}
+
+/// The dependency graph `find_cycles` searches
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize, JsonSchema)]
+#[serde(rename_all = "snake_case")]
+pub enum CycleScope {
+    /// Structs, classes, interfaces and enums, through their field types
+    Types,
+    /// Packages, through references between their code
+    Packages,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindCyclesRequest {
+    /// `types` or `packages`
+    pub scope: CycleScope,
+    /// Optional directory; only cycles with a member in it are reported
+    pub path: Option<String>,
+    /// Types: also follow the types named in method signatures (default: false)
+    pub include_methods: Option<bool>,
+    /// Types: also follow types of vendored code (default: false)
+    pub include_vendor: Option<bool>,
+    /// Packages: example references per edge (default: 1)
+    pub examples: Option<u32>,
+    /// Maximum number of cycles to return (default: 50)
+    pub limit: Option<u32>,
+}
+
+/// A dependency of one type on another within a cycle
+#[derive(Debug, Serialize, Deserialize)]
+pub struct TypeDependency {
+    pub from: String,
+    pub from_id: u64,
+    pub to: String,
+    pub to_id: u64,
+    /// The fields, methods or `type` definition naming `to`
+    pub via: Vec<String>,
+}
+
+/// Members of a cycle, each reachable from every other
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DependencyCycle<M, E> {
+    pub size: usize,
+    pub members: Vec<M>,
+    /// The dependencies between members
+    pub edges: Vec<E>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindCyclesResponse {
+    pub scope: CycleScope,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub type_cycles: Vec<DependencyCycle<RelatedSymbol, TypeDependency>>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub package_cycles: Vec<DependencyCycle<Package, PackageDependency>>,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct FindReferencesRequest {
+    /// Symbol name, optionally qualified as `pkg.Name`
+    pub name: Option<String>,
+    /// `file:line` or `file:line:column` of a definition or a reference to
+    /// the symbol; lines are 1-based and columns 0-based, as in responses
+    pub location: Option<String>,
+    /// Reference kinds to report: usage, call, import, construction,
+    /// definition (default: all but definition)
+    pub kinds: Option<Vec<String>>,
+    /// Optional file or directory; only references in it are reported
+    pub path: Option<String>,
+    /// Maximum number of references to return (default: 100)
+    pub limit: Option<u32>,
+    /// Also gather references made through aliases and re-exports of the
+    /// symbol (default: false)
+    pub include_aliases: Option<bool>,
+}
+
+/// Reference kinds `find_references` accepts, by name
+const REFERENCE_KINDS: &[(&str, ReferenceType)] = &[
+    ("usage", ReferenceType::Usage),
+    ("call", ReferenceType::Call),
+    ("import", ReferenceType::Import),
+    ("construction", ReferenceType::Construction),
+    ("definition", ReferenceType::Definition),
+];
+
+/// One place a symbol is referenced
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ReferenceSite {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub column: u32,
+    pub reference_type: ReferenceType,
+    /// The trimmed source line
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub snippet: Option<String>,
+    /// The symbol holding the reference
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub from_symbol: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub from_symbol_id: Option<u64>,
+    /// The referenced symbol
+    pub symbol: String,
+    pub symbol_id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FindReferencesResponse {
+    /// The symbols whose references were gathered
+    pub symbols: Vec<RelatedSymbol>,
+    pub references: Vec<ReferenceSite>,
+    pub total_found: usize,
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub truncated: bool,
+}
+
+/// What `get_neighbors` builds on first use of an edge that needs it
+#[derive(Default)]
+struct NeighborIndex {
+    call_graph: Option<CallGraph>,
+    callers: HashMap<SymbolId, Vec<SymbolId>>,
+    go_types: Option<GoTypeIndex>,
+}
+
+/// The neighbors a walk found and where it stopped
+struct NeighborWalk {
+    neighbors: Vec<Neighbor>,
+    truncated: bool,
+    /// Stopped because the time budget ran out
+    timed_out: bool,
+    frontier: Vec<FrontierSymbol>,
+}
+
+/// The part of a graph a walk drew and where it stopped
+struct GraphWalk {
+    nodes: Vec<GraphNode>,
+    edges: Vec<GraphEdge>,
+    truncated: bool,
+    /// Stopped because the time budget ran out
+    timed_out: bool,
+    frontier: Vec<FrontierSymbol>,
+}
+
+/// Go method sets and interfaces, for structural `implements` edges
+struct GoTypeIndex {
+    /// Method name to parameter count, per (package directory, type name).
+    /// This is the method set of the pointer type `*T`.
+    methods: HashMap<(PathBuf, String), HashMap<String, usize>>,
+    /// Methods with pointer receivers, which the value type `T` lacks
+    pointer_methods: HashSet<(PathBuf, String, String)>,
+    /// Every indexed interface with its full method set
+    interfaces: Vec<(Symbol, Vec<ContractMethod>)>,
+}
+
+pub struct AnalysisTools;
+
+impl AnalysisTools {
+    pub async fn find_tests_for(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindTestsForRequest = Self::parse_arguments(arguments)?;
+
+        let store = get_symbol_store();
+        let mut tests: Vec<Symbol> = store
+            .get_symbols_by_type(&SymbolType::Test)
+            .into_iter()
+            .filter(|test| test_covers(&test.name, &params.name))
+            .filter(|test| match &params.namespace {
+                Some(namespace) => test.namespace.as_deref() == Some(namespace.as_str()),
+                None => true,
+            })
+            .collect();
+
+        tests.sort_by(|a, b| {
+            a.location
+                .file
+                .cmp(&b.location.file)
+                .then(a.location.start_line.cmp(&b.location.start_line))
+        });
+
+        let response = FindTestsForResponse {
+            symbol: params.name,
+            total_found: tests.len(),
+            tests,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_untested_functions(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindUntestedRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let test_names: Vec<String> = store
+            .get_symbols_by_type(&SymbolType::Test)
+            .into_iter()
+            .map(|test| test.name)
+            .collect();
+
+        let mut untested: Vec<Symbol> = Vec::new();
+        for entry in store.symbol_data.iter() {
+            // Each candidate is matched against every test, so check between symbols
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_untested_functions".to_string(),
+                }));
+            }
+
+            let symbol = entry.value();
+            let candidate = matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ) && !is_test_file(&symbol.location.file)
+                && match &directory {
+                    Some(directory) => symbol.location.file.starts_with(directory),
+                    None => true,
+                }
+                && match &params.namespace {
+                    Some(namespace) => symbol.namespace.as_deref() == Some(namespace.as_str()),
+                    None => true,
+                };
+
+            if candidate
+                && !test_names
+                    .iter()
+                    .any(|test| test_covers(test, &symbol.name))
+            {
+                untested.push(symbol.clone());
+            }
+        }
+
+        untested.sort_by(|a, b| {
+            a.location
+                .file
+                .cmp(&b.location.file)
+                .then(a.location.start_line.cmp(&b.location.start_line))
+        });
+
+        let total_found = untested.len();
+        untested.truncate(limit);
+
+        let response = FindUntestedResponse {
+            symbols: untested,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn compare_signatures(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: CompareSignaturesRequest = Self::parse_arguments(arguments)?;
+
+        let store = get_symbol_store();
+        let (old_symbol, old_signature) = Self::symbol_signature(&store, params.old_id)?;
+        let (_, new_signature) = Self::symbol_signature(&store, params.new_id)?;
+
+        let language = Language::from_path(&old_symbol.location.file);
+        let comparison = compare_signatures(&old_signature, &new_signature, language);
+
+        let response = CompareSignaturesResponse {
+            old_signature: old_signature.text,
+            new_signature: new_signature.text,
+            comparison,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn explain_symbol(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ExplainSymbolRequest = Self::parse_arguments(arguments)?;
+
+        let sections: Vec<String> = match params.sections {
+            Some(sections) => sections,
+            None => EXPLAIN_SECTIONS.iter().map(|s| s.to_string()).collect(),
+        };
+        if let Some(unknown) = sections
+            .iter()
+            .find(|section| !EXPLAIN_SECTIONS.contains(&section.as_str()))
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown section '{}'. Expected one of: {}",
+                    unknown,
+                    EXPLAIN_SECTIONS.join(", ")
+                ),
+                None,
+            ));
+        }
+        let wants = |section: &str| sections.iter().any(|s| s == section);
+        let max_source_lines = params.max_source_lines.unwrap_or(80).max(1) as usize;
+        let max_items = params.max_items.unwrap_or(20).max(1) as usize;
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+
+        let content = tokio::fs::read_to_string(&symbol.location.file).await.ok();
+        let language = Language::from_path(&symbol.location.file);
+        let analysis = match (content.as_deref(), language) {
+            (Some(content), Some(language)) => {
+                analyze_definition(content, language, &symbol.location)
+            }
+            _ => None,
+        };
+
+        let mut response = ExplainSymbolResponse {
+            symbol: symbol.clone(),
+            source: None,
+            source_truncated: None,
+            doc_comment: None,
+            signature: None,
+            referenced_types: None,
+            callers: None,
+            callees: None,
+            possible_errors: None,
+            complexity: None,
+        };
+
+        if wants("source") {
+            if let Some(content) = &content {
+                let start = (symbol.location.start_line as usize).saturating_sub(1);
+                let end = symbol.location.end_line as usize;
+                let lines: Vec<&str> = content.lines().skip(start).take(end - start).collect();
+                response.source_truncated = Some(lines.len() > max_source_lines);
+                response.source = Some(
+                    lines
+                        .into_iter()
+                        .take(max_source_lines)
+                        .collect::<Vec<_>>()
+                        .join("\n"),
+                );
+            }
+        }
+
+        if wants("signature") {
+            response.signature = symbol.signature.as_ref().map(|s| s.text.clone());
+        }
+
+        if wants("callers") {
+            response.callers = Some(Self::callers(&store, &symbol, max_items));
+        }
+
+        if let Some(analysis) = analysis {
+            if wants("doc") {
+                response.doc_comment = analysis.doc_comment;
+            }
+            if wants("types") {
+                response.referenced_types = Some(Self::resolve_names(
+                    &store,
+                    &analysis.referenced_types,
+                    &symbol,
+                    max_items,
+                ));
+            }
+            if wants("callees") {
+                response.callees = Some(Self::resolve_names(
+                    &store,
+                    &analysis.callees,
+                    &symbol,
+                    max_items,
+                ));
+            }
+            if wants("errors") {
+                let mut errors = analysis.possible_errors;
+                errors.truncate(max_items);
+                response.possible_errors = Some(errors);
+            }
+            if wants("complexity") {
+                response.complexity = Some(Complexity {
+                    cyclomatic: analysis.cyclomatic_complexity,
+                    lines: symbol.location.end_line - symbol.location.start_line + 1,
+                });
+            }
+        }
+
+        match params.max_tokens {
+            Some(max_tokens) => Self::to_budgeted_result(
+                &response,
+                &["callers", "callees", "possible_errors", "referenced_types"],
+                max_tokens,
+            ),
+            None => Self::to_result(&response),
+        }
+    }
+
+    pub async fn list_recursive_functions(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListRecursiveFunctionsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Recursion cycles can span packages, so the graph covers every function
+        let store = get_symbol_store();
+        let graph = Self::call_graph(&store, cancel, "list_recursive_functions").await?;
+
+        let mut recursive: Vec<RecursiveFunction> = Vec::new();
+        for cycle in graph.recursion_cycles() {
+            let members: Vec<Symbol> = cycle
+                .iter()
+                .filter_map(|id| store.get_symbol_by_id(id))
+                .collect();
+            let recursion_cycle: Vec<RelatedSymbol> = members
+                .iter()
+                .map(|member| RelatedSymbol {
+                    name: member.name.clone(),
+                    id: Some(member.id.0),
+                    file: Some(PathResolver::display_path(&member.location.file)),
+                    line: Some(member.location.start_line),
+                })
+                .collect();
+
+            for member in members {
+                let wanted = match &directory {
+                    Some(directory) => member.location.file.starts_with(directory),
+                    None => true,
+                } && match &params.namespace {
+                    Some(namespace) => member.namespace.as_deref() == Some(namespace.as_str()),
+                    None => true,
+                };
+                if wanted {
+                    recursive.push(RecursiveFunction {
+                        symbol: member,
+                        is_recursive: true,
+                        recursion_cycle: recursion_cycle.clone(),
+                    });
+                }
+            }
+        }
+
+        recursive.sort_by(|a, b| {
+            a.symbol.location.file.cmp(&b.symbol.location.file).then(
+                a.symbol
+                    .location
+                    .start_line
+                    .cmp(&b.symbol.location.start_line),
+            )
+        });
+
+        let total_found = recursive.len();
+        recursive.truncate(limit);
+
+        let response = ListRecursiveFunctionsResponse {
+            functions: recursive,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_duplicates(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindDuplicatesRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let min_tokens = params.min_tokens.unwrap_or(40) as usize;
+        let min_similarity = params.min_similarity.unwrap_or(0.9);
+        if !(0.0..=1.0).contains(&min_similarity) || min_similarity == 0.0 {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "min_similarity must be greater than 0 and at most 1, got {}",
+                    min_similarity
+                ),
+                None,
+            ));
+        }
+        let include_tests = params.include_tests.unwrap_or(false);
+        let limit = params.limit.unwrap_or(20).max(1) as usize;
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+
+        let store = get_symbol_store();
+        let mut functions_by_file: HashMap<PathBuf, Vec<Symbol>> = HashMap::new();
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            let callable = match symbol.symbol_type {
+                SymbolType::Function | SymbolType::Method => true,
+                SymbolType::Test => include_tests,
+                _ => false,
+            };
+            let in_scope = scope
+                .as_ref()
+                .is_none_or(|scope| symbol.location.file.starts_with(scope));
+            if callable && in_scope {
+                functions_by_file
+                    .entry(symbol.location.file.clone())
+                    .or_default()
+                    .push(symbol.clone());
+            }
+        }
+        let mut files: Vec<_> = functions_by_file.into_iter().collect();
+        files.sort_by(|a, b| a.0.cmp(&b.0));
+
+        let mut functions: Vec<(Symbol, BodyFingerprint)> = Vec::new();
+        for (file, symbols) in files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_duplicates".to_string(),
+                }));
+            }
+            let Some(language) = Language::from_path(&file) else {
+                continue;
+            };
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            let starts: Vec<(u32, u32)> = symbols
+                .iter()
+                .map(|symbol| (symbol.location.start_line, symbol.location.start_column))
+                .collect();
+            let bodies = match body_tokens(&content, language, &starts) {
+                Ok(bodies) => bodies,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+            for symbol in symbols {
+                let start = (symbol.location.start_line, symbol.location.start_column);
+                if let Some(tokens) = bodies.get(&start).filter(|t| t.len() >= min_tokens) {
+                    functions.push((symbol, BodyFingerprint::from_tokens(tokens)));
+                }
+            }
+        }
+
+        let fingerprints: Vec<BodyFingerprint> =
+            functions.iter().map(|(_, print)| print.clone()).collect();
+        let mut clusters: Vec<DuplicateCluster> = cluster(&fingerprints, min_similarity)
+            .into_iter()
+            .map(|(members, similarity)| DuplicateCluster {
+                similarity,
+                identical: similarity == 1.0,
+                members: members
+                    .into_iter()
+                    .map(|index| {
+                        let (symbol, print) = &functions[index];
+                        DuplicateMember {
+                            name: symbol.name.clone(),
+                            id: symbol.id.0,
+                            file: PathResolver::display_path(&symbol.location.file),
+                            start_line: symbol.location.start_line,
+                            end_line: symbol.location.end_line,
+                            tokens: print.tokens,
+                        }
+                    })
+                    .collect(),
+            })
+            .collect();
+
+        // Largest amount of repeated code first
+        let weight = |cluster: &DuplicateCluster| {
+            cluster.members.iter().map(|m| m.tokens).sum::<usize>()
+                - cluster.members.iter().map(|m| m.tokens).max().unwrap_or(0)
+        };
+        clusters.sort_by(|a, b| weight(b).cmp(&weight(a)));
+
+        let total_found = clusters.len();
+        clusters.truncate(limit);
+
+        let response = FindDuplicatesResponse {
+            clusters,
+            functions_compared: functions.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_json_schema(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetJsonSchemaRequest = Self::parse_arguments(arguments)?;
+        let fields_only = match params.format.as_deref().unwrap_or("schema") {
+            "schema" => false,
+            "fields" => true,
+            other => {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Unknown format '{}'. Expected schema or fields", other),
+                    None,
+                ))
+            }
+        };
+        let max_depth = params.max_depth.unwrap_or(5);
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+        let definition = Self::go_type(&symbol).await.ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol {} is not a Go type declaration", params.id),
+                None,
+            )
+        })?;
+
+        let mut refs = Vec::new();
+        let mut schema = match definition {
+            GoTypeDefinition::Struct(fields) => struct_schema(&fields, &mut refs),
+            GoTypeDefinition::Other(type_text) => type_schema(&type_text, &mut refs),
+        };
+
+        // Expand referenced types breadth first, each resolved in the package
+        // of the type that mentions it
+        let mut defs = Map::new();
+        let mut unresolved_types = Vec::new();
+        let mut pending: VecDeque<(String, u32, Option<String>)> = refs
+            .into_iter()
+            .map(|name| (name, 1, symbol.namespace.clone()))
+            .collect();
+        while let Some((name, depth, namespace)) = pending.pop_front() {
+            if defs.contains_key(&name) {
+                continue;
+            }
+            if depth > max_depth {
+                defs.insert(
+                    name,
+                    json!({ "description": "Not expanded: max_depth reached" }),
+                );
+                continue;
+            }
+
+            let resolved = match Self::resolve_go_type(&store, &name, namespace.as_deref()) {
+                Some(target) => Self::go_type(&target)
+                    .await
+                    .map(|definition| (target, definition)),
+                None => None,
+            };
+            let Some((target, definition)) = resolved else {
+                defs.insert(
+                    name.clone(),
+                    json!({ "description": format!("Go type {} is not indexed", name) }),
+                );
+                unresolved_types.push(name);
+                continue;
+            };
+
+            let mut nested = Vec::new();
+            let def = match definition {
+                GoTypeDefinition::Struct(fields) => struct_schema(&fields, &mut nested),
+                GoTypeDefinition::Other(type_text) => type_schema(&type_text, &mut nested),
+            };
+            defs.insert(name, def);
+            pending.extend(
+                nested
+                    .into_iter()
+                    .map(|nested| (nested, depth + 1, target.namespace.clone())),
+            );
+        }
+
+        let response = if fields_only {
+            GetJsonSchemaResponse {
+                type_name: symbol.name,
+                schema: None,
+                fields: Some(field_map(&schema, &defs)),
+                unresolved_types,
+            }
+        } else {
+            if let Some(object) = schema.as_object_mut() {
+                object.insert(
+                    "$schema".to_string(),
+                    json!("https://json-schema.org/draft/2020-12/schema"),
+                );
+                object.insert("title".to_string(), json!(symbol.name));
+                if !defs.is_empty() {
+                    object.insert("$defs".to_string(), Value::Object(defs));
+                }
+            }
+            GetJsonSchemaResponse {
+                type_name: symbol.name,
+                schema: Some(schema),
+                fields: None,
+                unresolved_types,
+            }
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_enums(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetEnumsRequest = Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let mut enums = Vec::new();
+        for file in indexed_files(params.path.as_deref(), Language::Go)? {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "get_enums".to_string(),
+                }));
+            }
+
+            let file_symbols = store.get_symbols_by_file(&file);
+            let namespace = file_symbols
+                .iter()
+                .find_map(|symbol| symbol.namespace.clone());
+            if params.namespace.is_some() && namespace != params.namespace {
+                continue;
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            let file_enums = match find_go_enums(&content) {
+                Ok(file_enums) => file_enums,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for go_enum in file_enums {
+                if params
+                    .name
+                    .as_ref()
+                    .is_some_and(|name| *name != go_enum.name)
+                {
+                    continue;
+                }
+
+                // The type is usually declared next to its constants, else elsewhere in the package
+                let type_id = go_enum.type_name.as_ref().and_then(|type_name| {
+                    store
+                        .get_symbols(type_name)
+                        .into_iter()
+                        .filter(|symbol| symbol.symbol_type == SymbolType::Class)
+                        .find(|symbol| {
+                            symbol.location.file == file || symbol.namespace == namespace
+                        })
+                        .map(|symbol| symbol.id.0)
+                });
+                let members = go_enum
+                    .members
+                    .into_iter()
+                    .map(|member| EnumMemberInfo {
+                        id: file_symbols
+                            .iter()
+                            .find(|symbol| {
+                                symbol.name == member.name
+                                    && symbol.location.start_line <= member.line
+                                    && symbol.location.end_line >= member.line
+                            })
+                            .map(|symbol| symbol.id.0),
+                        member,
+                    })
+                    .collect();
+
+                enums.push(EnumInfo {
+                    name: go_enum.name,
+                    type_name: go_enum.type_name,
+                    type_id,
+                    file: file.clone(),
+                    line: go_enum.line,
+                    end_line: go_enum.end_line,
+                    namespace: namespace.clone(),
+                    members,
+                });
+            }
+        }
+
+        let total_found = enums.len();
+        enums.truncate(limit);
+
+        let response = GetEnumsResponse { enums, total_found };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_by_type_usage(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindByTypeUsageRequest = Self::parse_arguments(arguments)?;
+        let kinds: Vec<String> = match params.usage {
+            Some(kinds) => kinds,
+            None => TYPE_USAGE_KINDS.iter().map(|s| s.to_string()).collect(),
+        };
+        if let Some(unknown) = kinds
+            .iter()
+            .find(|kind| !TYPE_USAGE_KINDS.contains(&kind.as_str()))
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown usage '{}'. Expected one of: {}",
+                    unknown,
+                    TYPE_USAGE_KINDS.join(", ")
+                ),
+                None,
+            ));
+        }
+        let wants = |kind: &str| kinds.iter().any(|k| k == kind);
+        let direction = match &params.channel {
+            Some(name) => Some(ChannelDirection::from_name(name).ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Unknown channel direction '{}'. Expected one of: send, receive, bidirectional",
+                        name
+                    ),
+                    None,
+                )
+            })?),
+            None => None,
+        };
+        // With a direction, the type must be the channel's element type
+        let matches = |type_text: &str, channel: Option<&ChannelType>| match direction {
+            Some(direction) => channel.is_some_and(|channel| {
+                channel.direction == direction
+                    && mentions_type(&channel.element_type, &params.type_name)
+            }),
+            None => mentions_type(type_text, &params.type_name),
+        };
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let in_scope = |symbol: &Symbol| {
+            let in_directory = match &directory {
+                Some(directory) => symbol.location.file.starts_with(directory),
+                None => true,
+            };
+            in_directory
+                && match &params.namespace {
+                    Some(namespace) => symbol.namespace.as_deref() == Some(namespace.as_str()),
+                    None => true,
+                }
+        };
+        let cancelled = || {
+            cancelled_error(CodeAnalysisError::Cancelled {
+                operation: "find_by_type_usage".to_string(),
+            })
+        };
+
+        let store = get_symbol_store();
+        let mut usages: Vec<TypeUsage> = Vec::new();
+
+        // Parameters and return types come straight from indexed signatures
+        if wants("param") || wants("return") {
+            for entry in store.symbol_data.iter() {
+                if cancel.is_cancelled() {
+                    return Err(cancelled());
+                }
+                let symbol = entry.value();
+                let Some(signature) = &symbol.signature else {
+                    continue;
+                };
+                if !in_scope(symbol) {
+                    continue;
+                }
+                let usage = |usage: &str, member: Option<String>, type_text: &str| TypeUsage {
+                    name: symbol.name.clone(),
+                    id: Some(symbol.id.0),
+                    symbol_type: symbol.symbol_type.clone(),
+                    file: symbol.location.file.clone(),
+                    line: symbol.location.start_line,
+                    usage: usage.to_string(),
+                    member,
+                    type_text: type_text.to_string(),
+                };
+
+                if wants("param") {
+                    for parameter in &signature.parameters {
+                        if let Some(type_text) = &parameter.type_name {
+                            if matches(type_text, parameter.channel.as_ref()) {
+                                usages.push(usage("param", parameter.name.clone(), type_text));
+                            }
+                        }
+                    }
+                }
+                if wants("return") {
+                    if let Some(return_type) = &signature.return_type {
+                        match direction {
+                            // Each matching channel among the results is a usage
+                            Some(_) => {
+                                for channel in &signature.return_channels {
+                                    if matches(return_type, Some(channel)) {
+                                        usages.push(usage("return", None, &channel.render()));
+                                    }
+                                }
+                            }
+                            None => {
+                                if mentions_type(return_type, &params.type_name) {
+                                    usages.push(usage("return", None, return_type));
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        }
+
+        // Fields are not indexed; read the files that declare types and
+        // mention the type's name
+        if wants("field") {
+            let base = type_query_base(&params.type_name);
+            let mut files: Vec<PathBuf> = store
+                .symbol_data
+                .iter()
+                .filter(|entry| {
+                    matches!(
+                        entry.value().symbol_type,
+                        SymbolType::Class | SymbolType::Struct | SymbolType::Interface
+                    ) && in_scope(entry.value())
+                })
+                .map(|entry| entry.value().location.file.clone())
+                .collect();
+            files.sort();
+            files.dedup();
+
+            for file in files {
+                if cancel.is_cancelled() {
+                    return Err(cancelled());
+                }
+                let Some(language) = Language::from_path(&file) else {
+                    continue;
+                };
+                let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                    continue;
+                };
+                if base.is_empty() || !content.contains(base) {
+                    continue;
+                }
+                let Ok(types) = extract_type_members(&content, language) else {
+                    continue;
+                };
+
+                let file_symbols = store.get_symbols_by_file(&file);
+                for owner in types {
+                    let symbol = file_symbols
+                        .iter()
+                        .find(|s| s.name == owner.name && s.location.start_line == owner.line);
+                    if symbol.is_some_and(|symbol| !in_scope(symbol)) {
+                        continue;
+                    }
+                    for field in &owner.fields {
+                        let Some(type_text) = &field.type_name else {
+                            continue;
+                        };
+                        if matches(type_text, ChannelType::parse(type_text).as_ref()) {
+                            usages.push(TypeUsage {
+                                name: owner.name.clone(),
+                                id: symbol.map(|symbol| symbol.id.0),
+                                symbol_type: symbol.map_or(SymbolType::Struct, |symbol| {
+                                    symbol.symbol_type.clone()
+                                }),
+                                file: file.clone(),
+                                line: field.line,
+                                usage: "field".to_string(),
+                                member: Some(field.name.clone()),
+                                type_text: type_text.clone(),
+                            });
+                        }
+                    }
+                }
+            }
+        }
+
+        usages.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+        let total_found = usages.len();
+        usages.truncate(limit);
+
+        let response = FindByTypeUsageResponse {
+            type_name: params.type_name,
+            usages,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_entry_points(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListEntryPointsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let include_internal = params.include_internal.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Callers can be anywhere, so the graph covers every function
+        let store = get_symbol_store();
+        let graph = Self::call_graph(&store, cancel, "list_entry_points").await?;
+        let callers = graph.callers();
+
+        let mut functions: Vec<EntryPoint> = Vec::new();
+        let mut entry_point_count = 0;
+        let mut internal_count = 0;
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if !matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ) {
+                continue;
+            }
+            let in_directory = match &directory {
+                Some(directory) => symbol.location.file.starts_with(directory),
+                None => true,
+            };
+            let in_namespace = match &params.namespace {
+                Some(namespace) => symbol.namespace.as_deref() == Some(namespace.as_str()),
+                None => true,
+            };
+            if !in_directory || !in_namespace {
+                continue;
+            }
+
+            let (external, internal): (Vec<Symbol>, Vec<Symbol>) = callers
+                .get(&symbol.id)
+                .into_iter()
+                .flatten()
+                .filter(|id| **id != symbol.id)
+                .filter_map(|id| store.get_symbol_by_id(id))
+                .partition(|caller| !Self::same_package(caller, symbol));
+
+            let is_entry_point = !external.is_empty();
+            if is_entry_point {
+                entry_point_count += 1;
+            } else {
+                internal_count += 1;
+                if !include_internal {
+                    continue;
+                }
+            }
+
+            functions.push(EntryPoint {
+                symbol: symbol.clone(),
+                is_entry_point,
+                crosses_visibility: is_entry_point && symbol.visibility != Visibility::Public,
+                external_callers: external
+                    .iter()
+                    .take(20)
+                    .map(|caller| RelatedSymbol {
+                        name: caller.name.clone(),
+                        id: Some(caller.id.0),
+                        file: Some(PathResolver::display_path(&caller.location.file)),
+                        line: Some(caller.location.start_line),
+                    })
+                    .collect(),
+                external_caller_count: external.len(),
+                internal_caller_count: internal.len(),
+            });
+        }
+
+        // Most widely used entry points first
+        functions.sort_by(|a, b| {
+            b.external_caller_count
+                .cmp(&a.external_caller_count)
+                .then(a.symbol.location.file.cmp(&b.symbol.location.file))
+                .then(
+                    a.symbol
+                        .location
+                        .start_line
+                        .cmp(&b.symbol.location.start_line),
+                )
+        });
+        let total_found = functions.len();
+        functions.truncate(limit);
+
+        let response = ListEntryPointsResponse {
+            functions,
+            total_found,
+            entry_point_count,
+            internal_count,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_interface_contract(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetInterfaceContractRequest = Self::parse_arguments(arguments)?;
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+        let interface = Self::go_interface_of(&symbol).await.ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol {} is not a Go interface", params.id),
+                None,
+            )
+        })?;
+
+        let (methods, embedded, unresolved_embedded) =
+            Self::expand_interface(&store, &symbol, interface).await;
+
+        let response = GetInterfaceContractResponse {
+            name: symbol.name,
+            namespace: symbol.namespace,
+            file: symbol.location.file,
+            line: symbol.location.start_line,
+            methods,
+            embedded,
+            unresolved_embedded,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_satisfied_interfaces(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetSatisfiedInterfacesRequest = Self::parse_arguments(arguments)?;
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+        if Language::from_path(&symbol.location.file) != Some(Language::Go)
+            || !matches!(
+                symbol.symbol_type,
+                SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum
+            )
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol {} is not a Go type", params.id),
+                None,
+            ));
+        }
+
+        let go_types = Self::go_type_index(&store).await;
+        let package = symbol
+            .location
+            .file
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let key = (package.clone(), symbol.name.clone());
+        let mut interfaces = Vec::new();
+        if let Some(methods) = go_types.methods.get(&key) {
+            for (interface, contract) in &go_types.interfaces {
+                if interface.id == symbol.id || !satisfies(methods, contract) {
+                    continue;
+                }
+                let pointer_methods: Vec<String> = contract
+                    .iter()
+                    .filter(|method| {
+                        go_types.pointer_methods.contains(&(
+                            package.clone(),
+                            symbol.name.clone(),
+                            method.name.clone(),
+                        ))
+                    })
+                    .map(|method| method.name.clone())
+                    .collect();
+                interfaces.push(SatisfiedInterface {
+                    id: interface.id.0,
+                    name: interface.name.clone(),
+                    namespace: interface.namespace.clone(),
+                    file: interface.location.file.clone(),
+                    line: interface.location.start_line,
+                    receiver: if pointer_methods.is_empty() {
+                        ReceiverForm::Value
+                    } else {
+                        ReceiverForm::Pointer
+                    },
+                    pointer_methods,
+                });
+            }
+        }
+        interfaces.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+
+        let response = GetSatisfiedInterfacesResponse {
+            name: symbol.name,
+            namespace: symbol.namespace,
+            file: symbol.location.file,
+            line: symbol.location.start_line,
+            total_found: interfaces.len(),
+            interfaces,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_neighbors(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetNeighborsRequest = Self::parse_arguments(arguments)?;
+        if params.edges.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "No edges given. Expected one or more of: {}",
+                    NEIGHBOR_EDGES.join(", ")
+                ),
+                None,
+            ));
+        }
+        if let Some(unknown) = params
+            .edges
+            .iter()
+            .find(|edge| !NEIGHBOR_EDGES.contains(&edge.as_str()))
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown edge '{}'. Expected one of: {}",
+                    unknown,
+                    NEIGHBOR_EDGES.join(", ")
+                ),
+                None,
+            ));
+        }
+        let depth = params.depth.unwrap_or(1).clamp(1, MAX_NEIGHBOR_DEPTH);
+        let limit = params.limit.unwrap_or(50).max(1) as usize;
+
+        let store = get_symbol_store();
+        let root = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+
+        let budget = TimeBudget::new(cancel, params.timeout_ms);
+        let walk =
+            Self::walk_neighbors(&store, &root, &params.edges, depth, limit, &budget, cancel)
+                .await?;
+
+        let response = GetNeighborsResponse {
+            symbol: RelatedSymbol {
+                name: root.name.clone(),
+                id: Some(root.id.0),
+                file: Some(PathResolver::display_path(&root.location.file)),
+                line: Some(root.location.start_line),
+            },
+            neighbors: walk.neighbors,
+            truncated: walk.truncated,
+            timed_out: walk.timed_out,
+            frontier: walk.frontier,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_sql(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindSqlRequest = Self::parse_arguments(arguments)?;
+        let operation = match &params.operation {
+            Some(name) => Some(SqlOperation::from_name(name).ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Unknown operation '{}'. Expected one of: {}",
+                        name,
+                        SqlOperation::NAMES.join(", ")
+                    ),
+                    None,
+                )
+            })?),
+            None => None,
+        };
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Queries live in every language, so this covers all indexed files
+        let store = get_symbol_store();
+        let mut files: Vec<(PathBuf, Language)> = store
+            .files
+            .iter()
+            .filter_map(|entry| {
+                let file = entry.key().clone();
+                Language::from_path(&file).map(|language| (file, language))
+            })
+            .filter(|(file, _)| match &scope {
+                Some(scope) => file.starts_with(scope),
+                None => true,
+            })
+            .collect();
+        files.sort_by(|a, b| a.0.cmp(&b.0));
+
+        let mut queries = Vec::new();
+        for (file, language) in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_sql".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let found = match find_sql_queries(&content, *language) {
+                Ok(found) => found,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            let symbols = store.get_symbols_by_file(file);
+            for query in found {
+                if operation.is_some_and(|operation| operation != query.operation)
+                    || !query
+                        .tables
+                        .iter()
+                        .any(|table| table_matches(table, &params.table))
+                {
+                    continue;
+                }
+                let holder = symbols
+                    .iter()
+                    .filter(|symbol| {
+                        matches!(
+                            symbol.symbol_type,
+                            SymbolType::Function
+                                | SymbolType::Method
+                                | SymbolType::Test
+                                | SymbolType::Constant
+                                | SymbolType::Variable
+                        ) && symbol.location.start_line <= query.line
+                            && symbol.location.end_line >= query.line
+                    })
+                    .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line);
+                queries.push(SqlUsage {
+                    symbol: holder.map(|symbol| RelatedSymbol {
+                        name: symbol.name.clone(),
+                        id: Some(symbol.id.0),
+                        file: None,
+                        line: Some(symbol.location.start_line),
+                    }),
+                    symbol_type: holder.map(|symbol| symbol.symbol_type.clone()),
+                    file: file.clone(),
+                    query,
+                });
+            }
+        }
+
+        let total_found = queries.len();
+        queries.truncate(limit);
+
+        let response = FindSqlResponse {
+            table: params.table,
+            queries,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_resource_cleanup(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindResourceCleanupRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let methods: Vec<String> = params.methods.unwrap_or_else(|| {
+            CLEANUP_METHODS
+                .iter()
+                .map(|method| method.to_string())
+                .collect()
+        });
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let releases = |callee: &str| {
+            let method = callee.rsplit('.').next().unwrap_or(callee);
+            methods.iter().any(|candidate| candidate == method)
+        };
+
+        let store = get_symbol_store();
+        let mut functions: Vec<CleanupFunction> = store
+            .symbol_data
+            .iter()
+            .filter_map(|entry| {
+                let symbol = entry.value();
+                let signature = symbol.signature.as_ref()?;
+                if !signature.uses_defer
+                    || directory
+                        .as_ref()
+                        .is_some_and(|directory| !symbol.location.file.starts_with(directory))
+                    || params
+                        .namespace
+                        .as_ref()
+                        .is_some_and(|namespace| symbol.namespace.as_ref() != Some(namespace))
+                {
+                    return None;
+                }
+                // A deferred closure counts when it calls a cleanup method
+                let deferred: Vec<DeferredCall> = signature
+                    .deferred
+                    .iter()
+                    .filter(|call| {
+                        call.target
+                            .as_deref()
+                            .is_some_and(|target| releases(target))
+                            || call.calls.iter().any(|callee| releases(callee))
+                    })
+                    .cloned()
+                    .collect();
+                (!deferred.is_empty()).then(|| CleanupFunction {
+                    id: symbol.id.0,
+                    name: symbol.name.clone(),
+                    symbol_type: symbol.symbol_type.clone(),
+                    file: symbol.location.file.clone(),
+                    line: symbol.location.start_line,
+                    deferred,
+                })
+            })
+            .collect();
+        functions.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+
+        let total_found = functions.len();
+        functions.truncate(limit);
+
+        let response = FindResourceCleanupResponse {
+            functions,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_init_order(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetInitOrderRequest = Self::parse_arguments(arguments)?;
+        let resolved = PathResolver::resolve_file_or_directory_path(&params.path)?;
+        let directory = if resolved.is_file() {
+            resolved.parent().map(Path::to_path_buf).unwrap_or(resolved)
+        } else {
+            resolved
+        };
+        let include_tests = params.include_tests.unwrap_or(false);
+
+        // A Go package is the files directly in its directory; `go build`
+        // hands them to the compiler sorted by name
+        let files: Vec<PathBuf> = indexed_files(directory.to_str(), Language::Go)?
+            .into_iter()
+            .filter(|file| file.parent() == Some(directory.as_path()))
+            .filter(|file| include_tests || !is_test_file(file))
+            .collect();
+
+        let store = get_symbol_store();
+        let mut package = None;
+        let mut file_initializers = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "get_init_order".to_string(),
+                }));
+            }
+            if package.is_none() {
+                package = store
+                    .get_symbols_by_file(file)
+                    .into_iter()
+                    .find_map(|symbol| symbol.namespace);
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            match find_go_initializers(&content) {
+                Ok(found) => {
+                    file_initializers.push((PathResolver::display_path(file), found));
+                }
+                Err(e) => tracing::warn!("Failed to analyze {}: {}", file.display(), e),
+            }
+        }
+        let display_files: Vec<String> = file_initializers
+            .iter()
+            .map(|(file, _)| file.clone())
+            .collect();
+
+        let steps = init_order(file_initializers);
+        let init_functions = steps
+            .iter()
+            .filter(|step| step.initializer.kind == InitKind::Init)
+            .count();
+        let non_trivial_vars = steps
+            .iter()
+            .filter(|step| step.initializer.kind == InitKind::Var && step.initializer.non_trivial)
+            .count();
+        let order = steps
+            .into_iter()
+            .map(|step| {
+                let file = files
+                    .iter()
+                    .find(|file| PathResolver::display_path(file) == step.file);
+                let id = file.and_then(|file| {
+                    store
+                        .get_symbols_by_file(file)
+                        .into_iter()
+                        .find(|symbol| {
+                            symbol.location.start_line == step.initializer.line
+                                && step
+                                    .initializer
+                                    .name
+                                    .split(", ")
+                                    .any(|name| name == symbol.name)
+                        })
+                        .map(|symbol| symbol.id.0)
+                });
+                InitOrderEntry { id, step }
+            })
+            .collect();
+
+        let response = GetInitOrderResponse {
+            package,
+            directory,
+            files: display_files,
+            order,
+            init_functions,
+            non_trivial_vars,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_symbol_churn(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetSymbolChurnRequest = Self::parse_arguments(arguments)?;
+        let to = params.to.unwrap_or_else(|| "HEAD".to_string());
+        let max_commits = params.max_commits.unwrap_or(100).clamp(1, 1000) as usize;
+        let limit = params.limit.unwrap_or(50).max(1) as usize;
+        let roots = match &params.path {
+            Some(path) => vec![PathResolver::resolve_directory_path(path)?],
+            None => PathResolver::indexed_roots(),
+        };
+
+        let store = get_symbol_store();
+        let mut commit_details: Vec<CommitInfo> = Vec::new();
+        let mut commits_scanned = 0;
+        let mut truncated = false;
+        let mut symbols = Vec::new();
+        for root in roots {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "get_symbol_churn".to_string(),
+                }));
+            }
+            // Spans as indexed, which matches the working tree
+            let indexed: Vec<Symbol> = {
+                let _snapshot = store.read_snapshot();
+                store
+                    .files
+                    .iter()
+                    .filter(|entry| entry.key().starts_with(&root))
+                    .flat_map(|entry| store.get_symbols_by_file(entry.key()))
+                    .filter(|symbol| symbol.symbol_type != SymbolType::Import)
+                    .collect()
+            };
+            let spans = indexed
+                .iter()
+                .map(|symbol| {
+                    (
+                        symbol.id,
+                        symbol.location.file.clone(),
+                        LineSpan {
+                            start: symbol.location.start_line,
+                            end: symbol.location.end_line,
+                        },
+                    )
+                })
+                .collect();
+
+            let (from, to, history_root) = (params.from.clone(), to.clone(), root.clone());
+            let history = tokio::task::spawn_blocking(move || {
+                let (commits, truncated) =
+                    git_commit_diffs(&history_root, &from, &to, max_commits)?;
+                let worktree = git_worktree_diff(&history_root, &to)?;
+                Ok::<_, String>((commits, truncated, worktree))
+            })
+            .await
+            .unwrap_or_else(|e| Err(e.to_string()));
+            let (commits, root_truncated, worktree) = history.map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Failed to read git history of '{}': {}", root.display(), e),
+                    None,
+                )
+            })?;
+            commits_scanned += commits.len();
+            truncated |= root_truncated;
+
+            let churn = symbol_churn(spans, &worktree, &commits);
+            for symbol in indexed {
+                let Some(touching) = churn.get(&symbol.id) else {
+                    continue;
+                };
+                symbols.push(ChurnedSymbol {
+                    id: symbol.id.0,
+                    name: symbol.name,
+                    symbol_type: symbol.symbol_type,
+                    file: symbol.location.file,
+                    line: symbol.location.start_line,
+                    churn: touching.len(),
+                    commits: touching
+                        .iter()
+                        .map(|&index| commits[index].info.commit.clone())
+                        .collect(),
+                });
+            }
+            commit_details.extend(commits.into_iter().map(|commit| commit.info));
+        }
+        symbols.sort_by(|a, b| {
+            b.churn
+                .cmp(&a.churn)
+                .then_with(|| a.file.cmp(&b.file))
+                .then(a.line.cmp(&b.line))
+        });
+
+        let total_found = symbols.len();
+        symbols.truncate(limit);
+        let listed: HashSet<&str> = symbols
+            .iter()
+            .flat_map(|symbol| symbol.commits.iter().map(String::as_str))
+            .collect();
+        // Roots in the same checkout share commits
+        let mut seen = HashSet::new();
+        let commits: Vec<CommitInfo> = commit_details
+            .iter()
+            .filter(|info| listed.contains(info.commit.as_str()) && seen.insert(&info.commit))
+            .cloned()
+            .collect();
+
+        let response = GetSymbolChurnResponse {
+            from: params.from,
+            to,
+            commits_scanned,
+            truncated,
+            symbols,
+            commits,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_error_sentinels(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListErrorSentinelsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Sentinels can be returned from any package, so every Go file is read
+        let store = get_symbol_store();
+        let mut sentinels: Vec<SentinelInfo> = Vec::new();
+        let mut returns: Vec<(PathBuf, Option<String>, ReturnedName)> = Vec::new();
+        for file in indexed_files(None, Language::Go)? {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_error_sentinels".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            let (found, returned) = match find_go_errors(&content) {
+                Ok(analysis) => analysis,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+            let symbols = store.get_symbols_by_file(&file);
+            let namespace = symbols.iter().find_map(|symbol| symbol.namespace.clone());
+            for sentinel in found {
+                sentinels.push(SentinelInfo {
+                    id: symbols
+                        .iter()
+                        .find(|symbol| {
+                            symbol.name == sentinel.name
+                                && symbol.location.start_line == sentinel.line
+                        })
+                        .map(|symbol| symbol.id.0),
+                    name: sentinel.name,
+                    namespace: namespace.clone(),
+                    file: file.clone(),
+                    line: sentinel.line,
+                    message: sentinel.message,
+                    return_count: 0,
+                    return_sites: Vec::new(),
+                });
+            }
+            returns.extend(
+                returned
+                    .into_iter()
+                    .map(|name| (file.clone(), namespace.clone(), name)),
+            );
+        }
+
+        // A package is a directory: unqualified names resolve within it,
+        // `pkg.ErrX` to sentinels of a package named `pkg`
+        for (file, _, returned) in returns {
+            let matches: Vec<usize> = match returned.name.split_once('.') {
+                Some((qualifier, name)) => sentinels
+                    .iter()
+                    .enumerate()
+                    .filter(|(_, sentinel)| {
+                        sentinel.name == name && sentinel.namespace.as_deref() == Some(qualifier)
+                    })
+                    .map(|(index, _)| index)
+                    .collect(),
+                None => sentinels
+                    .iter()
+                    .enumerate()
+                    .filter(|(_, sentinel)| {
+                        sentinel.name == returned.name && sentinel.file.parent() == file.parent()
+                    })
+                    .map(|(index, _)| index)
+                    .collect(),
+            };
+            if matches.is_empty() {
+                continue;
+            }
+            let function = store
+                .get_symbols_by_file(&file)
+                .into_iter()
+                .filter(|symbol| {
+                    matches!(
+                        symbol.symbol_type,
+                        SymbolType::Function | SymbolType::Method | SymbolType::Test
+                    ) && symbol.location.start_line <= returned.line
+                        && symbol.location.end_line >= returned.line
+                })
+                .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                .map(|symbol| RelatedSymbol {
+                    name: symbol.name.clone(),
+                    id: Some(symbol.id.0),
+                    file: None,
+                    line: Some(symbol.location.start_line),
+                });
+            for index in matches {
+                sentinels[index].return_sites.push(ErrorReturnSite {
+                    function: function.clone(),
+                    file: file.clone(),
+                    line: returned.line,
+                    wrapped: returned.wrapped,
+                });
+            }
+        }
+
+        sentinels.retain(|sentinel| {
+            scope
+                .as_ref()
+                .is_none_or(|scope| sentinel.file.starts_with(scope))
+                && (params.namespace.is_none() || sentinel.namespace == params.namespace)
+        });
+        for sentinel in &mut sentinels {
+            sentinel.return_count = sentinel.return_sites.len();
+        }
+        let never_returned = sentinels
+            .iter()
+            .filter(|sentinel| sentinel.return_count == 0)
+            .map(|sentinel| sentinel.name.clone())
+            .collect();
+
+        let total_found = sentinels.len();
+        sentinels.truncate(limit);
+
+        let response = ListErrorSentinelsResponse {
+            sentinels,
+            never_returned,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_type_assertions(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListTypeAssertionsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let unchecked_only = params.unchecked_only.unwrap_or(false);
+        let include_conversions = params.include_conversions.unwrap_or(true);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut casts = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_type_assertions".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let found = match find_type_casts(&content) {
+                Ok(found) => found,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            let symbols = store.get_symbols_by_file(file);
+            for cast in found {
+                if (unchecked_only && !cast.panics())
+                    || (!include_conversions && cast.form == CastForm::Conversion)
+                    || params
+                        .function
+                        .as_ref()
+                        .is_some_and(|function| cast.function.as_ref() != Some(function))
+                {
+                    continue;
+                }
+                let function_id = symbols
+                    .iter()
+                    .filter(|symbol| {
+                        matches!(
+                            symbol.symbol_type,
+                            SymbolType::Function | SymbolType::Method | SymbolType::Test
+                        ) && symbol.location.start_line <= cast.line
+                            && symbol.location.end_line >= cast.line
+                    })
+                    .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                    .map(|symbol| symbol.id.0);
+                casts.push(TypeCastInfo {
+                    file: file.clone(),
+                    function_id,
+                    panics: cast.panics(),
+                    cast,
+                });
+            }
+        }
+
+        let count = |form: CastForm| casts.iter().filter(|info| info.cast.form == form).count();
+        let (unchecked, checked, conversions) = (
+            count(CastForm::Unchecked),
+            count(CastForm::Checked),
+            count(CastForm::Conversion),
+        );
+        let total_found = casts.len();
+        casts.truncate(limit);
+
+        let response = ListTypeAssertionsResponse {
+            casts,
+            unchecked,
+            checked,
+            conversions,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_users_of_package(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindUsersOfPackageRequest = Self::parse_arguments(arguments)?;
+        let import_path = params.import_path.trim().trim_matches('"').to_string();
+        if import_path.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                "import_path must not be empty",
+                None,
+            ));
+        }
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut functions = Vec::new();
+        let mut importing_files = 0;
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_users_of_package".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            // Skip parsing files that cannot import the package
+            let quoted = format!("\"{}", import_path.trim_end_matches("/..."));
+            if !content.contains(&quoted) {
+                continue;
+            }
+            let (imports, uses) = match find_package_uses(&content, &import_path) {
+                Ok(found) => found,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+            if !imports.is_empty() {
+                importing_files += 1;
+            }
+
+            let symbols = store.get_symbols_by_file(file);
+            for usage in uses {
+                let name = usage.function.rsplit('.').next().unwrap_or(&usage.function);
+                let id = symbols
+                    .iter()
+                    .find(|symbol| {
+                        symbol.name == name
+                            && symbol.location.start_line == usage.line
+                            && matches!(
+                                symbol.symbol_type,
+                                SymbolType::Function | SymbolType::Method | SymbolType::Test
+                            )
+                    })
+                    .map(|symbol| symbol.id.0);
+                functions.push(PackageUserInfo {
+                    id,
+                    file: file.clone(),
+                    usage,
+                });
+            }
+        }
+
+        let total_found = functions.len();
+        functions.truncate(limit);
+
+        let response = FindUsersOfPackageResponse {
+            import_path,
+            functions,
+            importing_files,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_by_return_count(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListByReturnCountRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let by_early_returns = match params.sort_by.as_deref() {
+            None | Some("returns") => false,
+            Some("early_returns") => true,
+            Some(other) => {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Invalid sort_by '{}': expected 'returns' or 'early_returns'",
+                        other
+                    ),
+                    None,
+                ))
+            }
+        };
+        let min_returns = params.min_returns.unwrap_or(1);
+        let min_early_returns = params.min_early_returns.unwrap_or(0);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Counts are recorded at indexing time, so no file is parsed to rank
+        let store = get_symbol_store();
+        let count = |symbol: &Symbol, tag: &str| -> u32 {
+            symbol
+                .tags
+                .get(tag)
+                .and_then(|count| count.parse().ok())
+                .unwrap_or(0)
+        };
+        let mut functions: Vec<ReturnCountInfo> = Vec::new();
+        for entry in store.symbol_data.iter() {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_by_return_count".to_string(),
+                }));
+            }
+
+            let symbol = entry.value();
+            let wanted = matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ) && match &directory {
+                Some(directory) => symbol.location.file.starts_with(directory),
+                None => true,
+            } && match &params.namespace {
+                Some(namespace) => symbol.namespace.as_deref() == Some(namespace.as_str()),
+                None => true,
+            };
+            if !wanted {
+                continue;
+            }
+
+            let returns = count(symbol, RETURN_COUNT_TAG);
+            let early_returns = count(symbol, EARLY_RETURN_COUNT_TAG);
+            if returns >= min_returns && early_returns >= min_early_returns {
+                functions.push(ReturnCountInfo {
+                    symbol: symbol.clone(),
+                    returns,
+                    early_returns,
+                    complexity: None,
+                });
+            }
+        }
+
+        functions.sort_by(|a, b| {
+            let key = |f: &ReturnCountInfo| match by_early_returns {
+                true => (f.early_returns, f.returns),
+                false => (f.returns, f.early_returns),
+            };
+            key(b)
+                .cmp(&key(a))
+                .then(a.symbol.location.file.cmp(&b.symbol.location.file))
+                .then(
+                    a.symbol
+                        .location
+                        .start_line
+                        .cmp(&b.symbol.location.start_line),
+                )
+        });
+
+        let total_found = functions.len();
+        functions.truncate(limit);
+
+        if params.include_complexity.unwrap_or(false) {
+            for function in &mut functions {
+                let location = &function.symbol.location;
+                let Some(language) = Language::from_path(&location.file) else {
+                    continue;
+                };
+                let Ok(content) = tokio::fs::read_to_string(&location.file).await else {
+                    continue;
+                };
+                function.complexity =
+                    analyze_definition(&content, language, location).map(|analysis| Complexity {
+                        cyclomatic: analysis.cyclomatic_complexity,
+                        lines: location.end_line - location.start_line + 1,
+                    });
+            }
+        }
+
+        let response = ListByReturnCountResponse {
+            functions,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn resolve_receiver_types(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ResolveReceiverTypesRequest = Self::parse_arguments(arguments)?;
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+        if Language::from_path(&symbol.location.file) != Some(Language::Go)
+            || !matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            )
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol {} is not a Go function or method", params.id),
+                None,
+            ));
+        }
+
+        let content = tokio::fs::read_to_string(&symbol.location.file)
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to read {}: {}", symbol.location.file.display(), e),
+                    None,
+                )
+            })?;
+        let directory = symbol
+            .location
+            .file
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let package = Self::go_package_types(&store, &directory).await;
+        let receiver_calls =
+            find_receiver_calls(&content, &symbol.location, &package).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to resolve receivers: {}", e),
+                    None,
+                )
+            })?;
+
+        let mut calls = Vec::new();
+        for call in receiver_calls {
+            let type_kind = call
+                .static_type
+                .as_deref()
+                .and_then(|static_type| package.kind(static_type))
+                .map(str::to_string);
+            let targets = match (&call.static_type, type_kind.as_deref()) {
+                (Some(static_type), Some(kind)) if kind != "interface" => {
+                    let type_name = base_type_name(static_type);
+                    let mut targets: Vec<u64> = store
+                        .get_symbols(&call.method)
+                        .into_iter()
+                        .filter(|method| {
+                            method.symbol_type == SymbolType::Method
+                                && method.location.file.parent() == Some(directory.as_path())
+                                && receiver_type(method).as_deref() == Some(type_name.as_str())
+                        })
+                        .map(|method| method.id.0)
+                        .collect();
+                    targets.sort_unstable();
+                    targets
+                }
+                _ => Vec::new(),
+            };
+            calls.push(ReceiverCallInfo {
+                call,
+                type_kind,
+                targets,
+            });
+        }
+
+        let resolved = calls
+            .iter()
+            .filter(|info| info.call.status == ResolutionStatus::Resolved)
+            .count();
+        let response = ResolveReceiverTypesResponse {
+            id: params.id,
+            function: symbol.name,
+            file: symbol.location.file,
+            unresolved: calls.len() - resolved,
+            resolved,
+            calls,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn interface_coverage(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: InterfaceCoverageRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let exported_only = params.exported_only.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let cancelled = || {
+            cancelled_error(CodeAnalysisError::Cancelled {
+                operation: "interface_coverage".to_string(),
+            })
+        };
+
+        // Go types implement interfaces structurally, by their method sets
+        let store = get_symbol_store();
+        let go_types = Self::go_type_index(&store).await;
+        let mut interfaces: Vec<Symbol> = Vec::new();
+        let mut implementations: HashMap<SymbolId, Vec<SymbolId>> = HashMap::new();
+        for (interface, contract) in &go_types.interfaces {
+            if cancel.is_cancelled() {
+                return Err(cancelled());
+            }
+            implementations.insert(
+                interface.id,
+                Self::go_implementations(&store, &go_types, contract),
+            );
+            interfaces.push(interface.clone());
+        }
+
+        // Other languages name the interfaces a class implements in its header
+        let mut classes_by_file: HashMap<PathBuf, Vec<Symbol>> = HashMap::new();
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if Language::from_path(&symbol.location.file) == Some(Language::Go) {
+                continue;
+            }
+            match symbol.symbol_type {
+                SymbolType::Interface => {
+                    implementations.insert(symbol.id, Vec::new());
+                    interfaces.push(symbol.clone());
+                }
+                SymbolType::Class | SymbolType::Struct | SymbolType::Enum => {
+                    classes_by_file
+                        .entry(symbol.location.file.clone())
+                        .or_default()
+                        .push(symbol.clone());
+                }
+                _ => {}
+            }
+        }
+        if interfaces.len() > go_types.interfaces.len() {
+            for (file, classes) in classes_by_file {
+                if cancel.is_cancelled() {
+                    return Err(cancelled());
+                }
+                let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                    continue;
+                };
+                for class in classes {
+                    for name in supertypes(&content, &class) {
+                        let Some(interface) = Self::resolve_type(&store, &name, &class) else {
+                            continue;
+                        };
+                        if let Some(found) = implementations.get_mut(&interface.id) {
+                            found.push(class.id);
+                        }
+                    }
+                }
+            }
+        }
+
+        interfaces.retain(|interface| {
+            let exported = interface.visibility == Visibility::Public;
+            (exported || !exported_only)
+                && match &directory {
+                    Some(directory) => interface.location.file.starts_with(directory),
+                    None => true,
+                }
+                && match &params.namespace {
+                    Some(namespace) => interface.namespace.as_deref() == Some(namespace.as_str()),
+                    None => true,
+                }
+        });
+
+        let mut report: Vec<InterfaceCoverage> = Vec::new();
+        for interface in interfaces {
+            let mut ids = implementations.remove(&interface.id).unwrap_or_default();
+            ids.sort_unstable_by_key(|id| id.0);
+            ids.dedup();
+            let mut found: Vec<Implementation> = ids
+                .iter()
+                .filter_map(|id| store.get_symbol_by_id(id))
+                .map(|implementation| Implementation {
+                    id: implementation.id.0,
+                    name: implementation.name,
+                    file: implementation.location.file,
+                    line: implementation.location.start_line,
+                })
+                .collect();
+            found.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+            report.push(InterfaceCoverage {
+                id: interface.id.0,
+                name: interface.name,
+                namespace: interface.namespace,
+                file: interface.location.file,
+                line: interface.location.start_line,
+                exported: interface.visibility == Visibility::Public,
+                status: match found.len() {
+                    0 => CoverageStatus::Unimplemented,
+                    1 => CoverageStatus::SingleImplementation,
+                    _ => CoverageStatus::MultipleImplementations,
+                },
+                implementation_count: found.len(),
+                implementations: found,
+            });
+        }
+
+        let total_interfaces = report.len();
+        let count = |status: CoverageStatus| report.iter().filter(|i| i.status == status).count();
+        let unimplemented = count(CoverageStatus::Unimplemented);
+        let single_implementation = count(CoverageStatus::SingleImplementation);
+        let multiple_implementations = count(CoverageStatus::MultipleImplementations);
+
+        if let Some(max) = params.max_implementations {
+            report.retain(|interface| interface.implementation_count <= max as usize);
+        }
+        // Gaps first: unimplemented, then single-implementation interfaces
+        report.sort_by(|a, b| {
+            a.implementation_count
+                .cmp(&b.implementation_count)
+                .then(a.file.cmp(&b.file))
+                .then(a.line.cmp(&b.line))
+        });
+        let total_found = report.len();
+        report.truncate(limit);
+
+        let response = InterfaceCoverageResponse {
+            interfaces: report,
+            total_interfaces,
+            unimplemented,
+            single_implementation,
+            multiple_implementations,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn export_graph(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ExportGraphRequest = Self::parse_arguments(arguments)?;
+        let edge = params.edge_type.unwrap_or_else(|| "calls".to_string());
+        if !NEIGHBOR_EDGES.contains(&edge.as_str()) {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown edge_type '{}'. Expected one of: {}",
+                    edge,
+                    NEIGHBOR_EDGES.join(", ")
+                ),
+                None,
+            ));
+        }
+        let format = params.format.unwrap_or_else(|| "dot".to_string());
+        if !GRAPH_FORMATS.contains(&format.as_str()) {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown format '{}'. Expected one of: {}",
+                    format,
+                    GRAPH_FORMATS.join(", ")
+                ),
+                None,
+            ));
+        }
+        let depth = params.depth.unwrap_or(2).clamp(1, MAX_GRAPH_DEPTH);
+        let max_nodes = params.max_nodes.unwrap_or(50).clamp(1, MAX_GRAPH_NODES) as usize;
+
+        let store = get_symbol_store();
+        let root = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+
+        let budget = TimeBudget::new(cancel, params.timeout_ms);
+        let GraphWalk {
+            nodes,
+            edges,
+            truncated,
+            timed_out,
+            frontier,
+        } = Self::walk_graph(&store, &root, &edge, depth, max_nodes, &budget, cancel).await?;
+
+        let graph = match format.as_str() {
+            "mermaid" => render_mermaid(&nodes, &edges),
+            _ => render_dot(&format!("{} {}", root.name, edge), &nodes, &edges),
+        };
+        let response = ExportGraphResponse {
+            format,
+            graph,
+            nodes: nodes.len(),
+            edges: edges.len(),
+            truncated,
+            timed_out,
+            frontier,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_magic_literals(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindMagicLiteralsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let mut filter = LiteralFilter::default();
+        if let Some(kinds) = &params.kinds {
+            filter.kinds = Vec::new();
+            for name in kinds {
+                let kind = LiteralKind::from_name(name).ok_or_else(|| {
+                    ErrorData::new(
+                        ErrorCode::INVALID_PARAMS,
+                        format!(
+                            "Unknown literal kind '{}'. Expected one of: {}",
+                            name,
+                            LiteralKind::NAMES.join(", ")
+                        ),
+                        None,
+                    )
+                })?;
+                filter.kinds.push(kind);
+            }
+        }
+        filter.min_number = params.min_number;
+        filter.min_string_length = params.min_string_length.unwrap_or(1).max(1) as usize;
+        filter.ignore = params.ignore.clone().unwrap_or_default();
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let min_occurrences = params.min_occurrences.unwrap_or(1).max(1) as usize;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Literals live in every language, so this covers all indexed files
+        let store = get_symbol_store();
+        let mut files: Vec<(PathBuf, Language)> = store
+            .files
+            .iter()
+            .filter_map(|entry| {
+                let file = entry.key().clone();
+                Language::from_path(&file).map(|language| (file, language))
+            })
+            .filter(|(file, _)| match &scope {
+                Some(scope) => file.starts_with(scope),
+                None => true,
+            })
+            .collect();
+        files.sort_by(|a, b| a.0.cmp(&b.0));
+
+        let mut holders: Vec<(Symbol, PathBuf, Vec<MagicLiteral>)> = Vec::new();
+        let mut occurrences: HashMap<(LiteralKind, String), usize> = HashMap::new();
+        for (file, language) in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_magic_literals".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let found = match find_magic_literals(&content, *language, &filter) {
+                Ok(found) => found,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            let symbols = store.get_symbols_by_file(file);
+            for literal in found {
+                // Attach the literal to the innermost function holding it
+                let Some(holder) = symbols
+                    .iter()
+                    .filter(|symbol| {
+                        matches!(
+                            symbol.symbol_type,
+                            SymbolType::Function | SymbolType::Method | SymbolType::Test
+                        ) && symbol.location.start_line <= literal.line
+                            && symbol.location.end_line >= literal.line
+                    })
+                    .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                else {
+                    continue;
+                };
+                *occurrences
+                    .entry((literal.kind, literal.value.clone()))
+                    .or_default() += 1;
+                match holders
+                    .iter_mut()
+                    .find(|(symbol, _, _)| symbol.id == holder.id)
+                {
+                    Some((_, _, literals)) => literals.push(literal),
+                    None => holders.push((holder.clone(), file.clone(), vec![literal])),
+                }
+            }
+        }
+
+        let mut symbols: Vec<SymbolLiterals> = Vec::new();
+        let mut total_literals = 0;
+        for (symbol, file, literals) in holders {
+            let literals: Vec<LiteralUse> = literals
+                .into_iter()
+                .map(|literal| LiteralUse {
+                    occurrences: occurrences[&(literal.kind, literal.value.clone())],
+                    literal,
+                })
+                .filter(|used| used.occurrences >= min_occurrences)
+                .collect();
+            if literals.is_empty() {
+                continue;
+            }
+            total_literals += literals.len();
+            symbols.push(SymbolLiterals {
+                id: symbol.id.0,
+                name: symbol.name,
+                symbol_type: symbol.symbol_type,
+                file,
+                line: symbol.location.start_line,
+                literals,
+            });
+        }
+        symbols.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+
+        let total_found = symbols.len();
+        symbols.truncate(limit);
+
+        let response = FindMagicLiteralsResponse {
+            symbols,
+            total_literals,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    /// HTTP routes extracted at indexing time, sorted by path and method,
+    /// each linked to the function or method handling it
+    pub async fn list_routes(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListRoutesRequest = Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let method = params.method.as_deref().map(str::to_uppercase);
+        let limit = params.limit.unwrap_or(200).max(1) as usize;
+
+        let store = get_symbol_store();
+        let _snapshot = store.read_snapshot();
+        let routes: Vec<Symbol> = store
+            .symbol_data
+            .iter()
+            .filter(|entry| entry.value().symbol_type == SymbolType::Route)
+            .map(|entry| entry.value().clone())
+            .collect();
+
+        let tag = |symbol: &Symbol, key: &str| symbol.tags.get(key).cloned().unwrap_or_default();
+        let mut found: Vec<RouteInfo> = routes
+            .iter()
+            .filter(|route| {
+                scope
+                    .as_ref()
+                    .is_none_or(|scope| route.location.file.starts_with(scope))
+            })
+            .filter(|route| {
+                method.as_ref().is_none_or(|method| {
+                    let registered = tag(route, ROUTE_METHOD_TAG);
+                    registered == *method || registered == "ANY"
+                })
+            })
+            .filter(|route| {
+                params
+                    .prefix
+                    .as_ref()
+                    .is_none_or(|prefix| tag(route, ROUTE_PATH_TAG).starts_with(prefix.as_str()))
+            })
+            .map(|route| {
+                let handler = tag(route, ROUTE_HANDLER_TAG);
+                let handler_symbol =
+                    Self::route_handler(&store, route, &handler).map(|symbol| RouteHandler {
+                        id: symbol.id.0,
+                        name: symbol.name,
+                        symbol_type: symbol.symbol_type,
+                        file: symbol.location.file,
+                        line: symbol.location.start_line,
+                    });
+                RouteInfo {
+                    id: route.id.0,
+                    method: tag(route, ROUTE_METHOD_TAG),
+                    path: tag(route, ROUTE_PATH_TAG),
+                    router: tag(route, ROUTE_ROUTER_TAG),
+                    file: route.location.file.clone(),
+                    line: route.location.start_line,
+                    handler,
+                    handler_symbol,
+                }
+            })
+            .collect();
+        found.sort_by(|a, b| {
+            a.path
+                .cmp(&b.path)
+                .then(a.method.cmp(&b.method))
+                .then(a.file.cmp(&b.file))
+                .then(a.line.cmp(&b.line))
+        });
+
+        let note = (routes.is_empty() && RoutePatterns::from_env().is_empty()).then(|| {
+            format!(
+                "Route extraction is off. Set ROBERTO_ROUTES to the routers to recognize ({}) and re-index",
+                RoutePatterns::ROUTERS.join(", ")
+            )
+        });
+        let total_found = found.len();
+        found.truncate(limit);
+
+        let response = ListRoutesResponse {
+            routes: found,
+            total_found,
+            note,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_construction_sites(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindConstructionSitesRequest = Self::parse_arguments(arguments)?;
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let include_constructors = params.include_constructors.unwrap_or(true);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let types = Self::go_types_named(&store, &params.type_name);
+        if types.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("No indexed Go type named '{}'", params.type_name),
+                None,
+            ));
+        }
+        let constructors: Vec<Symbol> = types
+            .iter()
+            .flat_map(|go_type| Self::go_constructors(&store, go_type))
+            .collect();
+        let constructor_ids: HashSet<SymbolId> = constructors.iter().map(|c| c.id).collect();
+
+        // Construction references by file, so each file is parsed once
+        let mut by_file: HashMap<PathBuf, Vec<(Location, SymbolId)>> = HashMap::new();
+        for go_type in &types {
+            for reference in store.get_references(&go_type.id) {
+                if reference.reference_type != ReferenceType::Construction {
+                    continue;
+                }
+                let in_scope = scope
+                    .as_ref()
+                    .is_none_or(|scope| reference.location.file.starts_with(scope));
+                if in_scope {
+                    by_file
+                        .entry(reference.location.file.clone())
+                        .or_default()
+                        .push((reference.location, go_type.id));
+                }
+            }
+        }
+        let mut files: Vec<PathBuf> = by_file.keys().cloned().collect();
+        files.sort();
+
+        let mut sites = Vec::new();
+        for file in files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_construction_sites".to_string(),
+                }));
+            }
+            let literals: Vec<CompositeLiteral> = match tokio::fs::read_to_string(&file).await {
+                Ok(content) => find_composite_literals(&content).unwrap_or_default(),
+                Err(_) => Vec::new(),
+            };
+            for (location, type_id) in &by_file[&file] {
+                let enclosing = Self::enclosing_symbol(&store, location, type_id);
+                let in_constructor = enclosing
+                    .as_ref()
+                    .is_some_and(|symbol| constructor_ids.contains(&symbol.id));
+                if in_constructor && !include_constructors {
+                    continue;
+                }
+                // A file edited since indexing may no longer have the literal
+                let literal = literals.iter().find(|literal| {
+                    literal.line == location.start_line && literal.column == location.start_column
+                });
+                sites.push(ConstructionSite {
+                    file: file.clone(),
+                    type_text: literal.map_or_else(
+                        || params.type_name.clone(),
+                        |literal| literal.type_text.clone(),
+                    ),
+                    type_id: type_id.0,
+                    line: location.start_line,
+                    column: location.start_column,
+                    pointer: literal.is_some_and(|literal| literal.pointer),
+                    fields: literal
+                        .map(|literal| literal.fields.clone())
+                        .unwrap_or_default(),
+                    positional: literal.map_or(0, |literal| literal.positional),
+                    enclosing: enclosing.as_ref().map(|symbol| symbol.name.clone()),
+                    enclosing_id: enclosing.as_ref().map(|symbol| symbol.id.0),
+                    in_constructor,
+                });
+            }
+        }
+        sites.sort_by(|a, b| {
+            a.file
+                .cmp(&b.file)
+                .then(a.line.cmp(&b.line))
+                .then(a.column.cmp(&b.column))
+        });
+
+        let total_found = sites.len();
+        let bypassing = sites.iter().filter(|site| !site.in_constructor).count();
+        sites.truncate(limit);
+
+        let response = FindConstructionSitesResponse {
+            type_name: params.type_name,
+            types: types.iter().map(ConstructionSymbol::from).collect(),
+            constructors: constructors.iter().map(ConstructionSymbol::from).collect(),
+            sites,
+            total_found,
+            bypassing,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_hotspots(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListHotspotsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let by_page_rank = match params.rank_by.as_deref() {
+            None | Some("references") => false,
+            Some("pagerank") => true,
+            Some(other) => {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Invalid rank_by '{}': expected 'references' or 'pagerank'",
+                        other
+                    ),
+                    None,
+                ))
+            }
+        };
+        let kinds = match &params.kinds {
+            Some(names) => {
+                let mut kinds = Vec::new();
+                for name in names {
+                    kinds.push(SymbolType::from_name(name).ok_or_else(|| {
+                        ErrorData::new(
+                            ErrorCode::INVALID_PARAMS,
+                            format!("Unknown symbol kind '{}'", name),
+                            None,
+                        )
+                    })?);
+                }
+                Some(kinds)
+            }
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let mut hotspots: Vec<Hotspot> = Vec::new();
+        let mut edges: Vec<(SymbolId, SymbolId)> = Vec::new();
+        // Referencing symbols are looked up by file, grouped in one pass
+        let mut file_symbols: HashMap<PathBuf, Vec<Symbol>> = HashMap::new();
+        if by_page_rank {
+            for entry in store.symbol_data.iter() {
+                file_symbols
+                    .entry(entry.value().location.file.clone())
+                    .or_default()
+                    .push(entry.value().clone());
+            }
+        }
+        for entry in store.references.iter() {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_hotspots".to_string(),
+                }));
+            }
+            let Some(symbol) = store.get_symbol_by_id(entry.key()) else {
+                continue;
+            };
+            // References from the symbol's own body, such as recursion, don't count
+            let incoming: Vec<&Reference> = entry
+                .value()
+                .iter()
+                .filter(|reference| {
+                    reference.reference_type != ReferenceType::Definition
+                        && !(reference.location.file == symbol.location.file
+                            && reference.location.start_line >= symbol.location.start_line
+                            && reference.location.end_line <= symbol.location.end_line)
+                })
+                .collect();
+            if incoming.is_empty() {
+                continue;
+            }
+
+            if by_page_rank {
+                for reference in &incoming {
+                    let referrer = file_symbols
+                        .get(&reference.location.file)
+                        .into_iter()
+                        .flatten()
+                        .filter(|candidate| {
+                            candidate.location.start_line <= reference.location.start_line
+                                && candidate.location.end_line >= reference.location.end_line
+                        })
+                        .min_by_key(|candidate| {
+                            candidate.location.end_line - candidate.location.start_line
+                        });
+                    if let Some(referrer) = referrer {
+                        edges.push((referrer.id, symbol.id));
+                    }
+                }
+            }
+
+            let wanted = match &kinds {
+                Some(kinds) => kinds.contains(&symbol.symbol_type),
+                None => !matches!(
+                    symbol.symbol_type,
+                    SymbolType::Variable | SymbolType::Import
+                ),
+            } && match &directory {
+                Some(directory) => symbol.location.file.starts_with(directory),
+                None => true,
+            } && match &params.namespace {
+                Some(namespace) => symbol.namespace.as_deref() == Some(namespace.as_str()),
+                None => true,
+            };
+            if !wanted {
+                continue;
+            }
+            let referencing_files: HashSet<&PathBuf> = incoming
+                .iter()
+                .map(|reference| &reference.location.file)
+                .collect();
+            hotspots.push(Hotspot {
+                references: incoming.len(),
+                calls: incoming
+                    .iter()
+                    .filter(|reference| reference.reference_type == ReferenceType::Call)
+                    .count(),
+                referencing_files: referencing_files.len(),
+                score: None,
+                symbol,
+            });
+        }
+
+        if by_page_rank {
+            // Every referenced symbol takes part, whatever the filters keep
+            let scores = page_rank(&[], &edges);
+            for hotspot in &mut hotspots {
+                hotspot.score = scores.get(&hotspot.symbol.id).copied();
+            }
+        }
+        let score = |hotspot: &Hotspot| hotspot.score.unwrap_or(0.0);
+        hotspots.sort_by(|a, b| {
+            score(b)
+                .total_cmp(&score(a))
+                .then(b.references.cmp(&a.references))
+                .then(b.referencing_files.cmp(&a.referencing_files))
+                .then(a.symbol.name.cmp(&b.symbol.name))
+        });
+
+        let total_found = hotspots.len();
+        hotspots.truncate(limit);
+
+        let response = ListHotspotsResponse {
+            hotspots,
+            ranked_by: if by_page_rank {
+                "pagerank"
+            } else {
+                "references"
+            }
+            .to_string(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_panics(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListPanicsRequest = Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let include_implicit = params.include_implicit.unwrap_or(true);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut functions: Vec<FunctionPanics> = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_panics".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let sites = match find_panic_sites(&content) {
+                Ok(sites) => sites,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+            let casts = match include_implicit {
+                true => find_type_casts(&content).unwrap_or_default(),
+                false => Vec::new(),
+            };
+
+            // Methods of different types may share a name; the indexed
+            // function tells them apart
+            let symbols = store.get_symbols_by_file(file);
+            let function_at = |line: u32| {
+                symbols
+                    .iter()
+                    .filter(|symbol| {
+                        matches!(
+                            symbol.symbol_type,
+                            SymbolType::Function | SymbolType::Method | SymbolType::Test
+                        ) && symbol.location.start_line <= line
+                            && symbol.location.end_line >= line
+                    })
+                    .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                    .map(|symbol| symbol.id.0)
+            };
+            let mut in_file: Vec<FunctionPanics> = Vec::new();
+            for site in sites {
+                let function_id = function_at(site.line);
+                let function =
+                    Self::function_panics(&mut in_file, file, &site.function, function_id);
+                match site.kind {
+                    PanicSiteKind::Panic => function.explicit.push(site),
+                    PanicSiteKind::Recover => {
+                        function.recovered |= site.deferred;
+                        function.recovers.push(site);
+                    }
+                }
+            }
+            for cast in casts.into_iter().filter(TypeCast::panics) {
+                let function_id = function_at(cast.line);
+                Self::function_panics(&mut in_file, file, &cast.function, function_id)
+                    .implicit
+                    .push(cast);
+            }
+            if let Some(name) = &params.function {
+                in_file.retain(|function| function.function.as_ref() == Some(name));
+            }
+            functions.extend(in_file);
+        }
+
+        // Most panic sites first; ties keep source order
+        functions.sort_by_key(|function| {
+            std::cmp::Reverse(function.explicit.len() + function.implicit.len())
+        });
+        let explicit_panics = functions.iter().map(|f| f.explicit.len()).sum();
+        let implicit_panics = functions.iter().map(|f| f.implicit.len()).sum();
+        let recovers = functions.iter().map(|f| f.recovers.len()).sum();
+        let total_found = functions.len();
+        functions.truncate(limit);
+
+        let response = ListPanicsResponse {
+            functions,
+            explicit_panics,
+            implicit_panics,
+            recovers,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_package_graph(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetPackageGraphRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let min_references = params.min_references.unwrap_or(1).max(1) as usize;
+        let examples = params.examples.unwrap_or(0) as usize;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let (packages, mut dependencies) = Self::package_dependencies(
+            &store,
+            directory.as_deref(),
+            examples,
+            cancel,
+            "get_package_graph",
+        )?;
+        dependencies.retain(|dependency| dependency.references >= min_references);
+
+        let mut files: HashMap<PathBuf, usize> = HashMap::new();
+        for entry in store.files.iter() {
+            if let Some(parent) = entry.key().parent() {
+                *files.entry(parent.to_path_buf()).or_default() += 1;
+            }
+        }
+        let mut nodes: Vec<PackageNode> = packages
+            .into_iter()
+            .filter(|(path, _)| match &directory {
+                Some(directory) => path.starts_with(directory),
+                None => true,
+            })
+            .map(|(path, package)| PackageNode {
+                files: files.get(&path).copied().unwrap_or_default(),
+                depends_on: dependencies.iter().filter(|d| d.from == package).count(),
+                depended_on_by: dependencies.iter().filter(|d| d.to == package).count(),
+                package,
+            })
+            .collect();
+        nodes.sort_by(|a, b| a.package.cmp(&b.package));
+
+        let total_dependencies = dependencies.len();
+        dependencies.truncate(limit);
+
+        let response = GetPackageGraphResponse {
+            packages: nodes,
+            dependencies,
+            total_dependencies,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn check_layering(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: CheckLayeringRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let invalid = |message: String| ErrorData::new(ErrorCode::INVALID_PARAMS, message, None);
+        let policy = match (&params.policy_file, params.policy) {
+            (Some(_), Some(_)) => {
+                return Err(invalid(
+                    "Pass either policy_file or policy, not both".to_string(),
+                ))
+            }
+            (Some(policy_file), None) => {
+                let file = PathResolver::resolve_file_path(policy_file)?;
+                let content = tokio::fs::read_to_string(&file).await.map_err(|e| {
+                    invalid(format!(
+                        "Failed to read policy file {}: {}",
+                        file.display(),
+                        e
+                    ))
+                })?;
+                serde_json::from_str::<LayeringPolicy>(&content).map_err(|e| {
+                    invalid(format!(
+                        "Invalid layering policy in {}: {}",
+                        file.display(),
+                        e
+                    ))
+                })?
+            }
+            (None, Some(policy)) => policy,
+            (None, None) => {
+                return Err(invalid(
+                    "Either policy_file or policy is required".to_string(),
+                ))
+            }
+        };
+        if let Some(cycle) = policy.cycle() {
+            return Err(invalid(format!(
+                "The allow rules of a layering policy must form a DAG; found the cycle {}",
+                cycle.join(" -> ")
+            )));
+        }
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let examples = params.examples.unwrap_or(3) as usize;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let store = get_symbol_store();
+        let (_, dependencies) = Self::package_dependencies(
+            &store,
+            directory.as_deref(),
+            examples,
+            cancel,
+            "check_layering",
+        )?;
+        let dependencies_checked = dependencies.len();
+        let mut violations: Vec<LayeringViolation> = dependencies
+            .into_iter()
+            .filter_map(|dependency| {
+                let rule = policy.violation(&dependency.from, &dependency.to)?;
+                Some(LayeringViolation { rule, dependency })
+            })
+            .collect();
+
+        let total_violations = violations.len();
+        violations.truncate(limit);
+
+        let response = CheckLayeringResponse {
+            passed: total_violations == 0,
+            violations,
+            dependencies_checked,
+            total_violations,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_pure_functions(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListPureFunctionsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let include_effectful = params.include_effectful.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        // Callees anywhere in the index decide purity, whatever the scope
+        let scope: HashSet<PathBuf> = indexed_files(params.path.as_deref(), Language::Go)?
+            .into_iter()
+            .collect();
+        let files = indexed_files(None, Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut functions: Vec<(PathBuf, Option<String>, FunctionEffects)> = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_pure_functions".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let found = match find_function_effects(&content) {
+                Ok(found) => found,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+            let package = store
+                .get_symbols_by_file(file)
+                .into_iter()
+                .find_map(|symbol| symbol.namespace);
+            functions.extend(
+                found
+                    .into_iter()
+                    .map(|effects| (file.clone(), package.clone(), effects)),
+            );
+        }
+
+        // Functions by bare name per directory, by package and name, and
+        // methods by name alone since the receiver's type is unknown
+        let mut by_directory: HashMap<(&Path, &str), Vec<usize>> = HashMap::new();
+        let mut by_package: HashMap<(&str, &str), Vec<usize>> = HashMap::new();
+        let mut methods: HashMap<&str, Vec<usize>> = HashMap::new();
+        for (index, (file, package, effects)) in functions.iter().enumerate() {
+            let name = effects.function.as_str();
+            if effects.receiver_type.is_some() {
+                methods.entry(name).or_default().push(index);
+                continue;
+            }
+            if let Some(directory) = file.parent() {
+                by_directory
+                    .entry((directory, name))
+                    .or_default()
+                    .push(index);
+            }
+            if let Some(package) = package {
+                by_package.entry((package, name)).or_default().push(index);
+            }
+        }
+
+        // Each call resolves to every function it may reach; a call to an
+        // indexed type is a conversion, and anything else is unknown
+        let mut effects: Vec<Vec<SideEffect>> = Vec::with_capacity(functions.len());
+        let mut callees: Vec<Vec<(String, Vec<usize>)>> = Vec::with_capacity(functions.len());
+        let mut via: Vec<Option<String>> = vec![None; functions.len()];
+        for (index, (file, _, function)) in functions.iter().enumerate() {
+            let mut direct: Vec<SideEffect> = function.effects.iter().copied().collect();
+            let mut resolved = Vec::new();
+            for call in &function.calls {
+                let label = match (&call.package, call.method) {
+                    (Some(package), _) => format!("{}.{}", package, call.name),
+                    (None, true) => format!(".{}", call.name),
+                    (None, false) => call.name.clone(),
+                };
+                let targets = match (&call.package, call.method) {
+                    (Some(package), _) => by_package.get(&(package.as_str(), call.name.as_str())),
+                    (None, true) => methods.get(call.name.as_str()),
+                    (None, false) => file
+                        .parent()
+                        .and_then(|directory| by_directory.get(&(directory, call.name.as_str()))),
+                };
+                match targets {
+                    Some(targets) => resolved.push((label, targets.clone())),
+                    None if !call.method && Self::is_indexed_type(&store, &call.name) => {}
+                    None => {
+                        if !direct.contains(&SideEffect::UnknownCall) {
+                            direct.push(SideEffect::UnknownCall);
+                        }
+                        if via[index].is_none() && function.effects.is_empty() {
+                            via[index] = Some(label);
+                        }
+                    }
+                }
+            }
+            effects.push(direct);
+            callees.push(resolved);
+        }
+
+        // Effects spread from callees to callers until nothing changes
+        let mut effectful: Vec<bool> = effects.iter().map(|direct| !direct.is_empty()).collect();
+        loop {
+            let mut changed = false;
+            for index in 0..functions.len() {
+                if effectful[index] {
+                    continue;
+                }
+                let cause = callees[index]
+                    .iter()
+                    .find(|(_, targets)| targets.iter().any(|target| effectful[*target]));
+                if let Some((label, _)) = cause {
+                    via[index] = Some(label.clone());
+                    effectful[index] = true;
+                    changed = true;
+                }
+            }
+            if !changed {
+                break;
+            }
+        }
+
+        let (mut pure, mut impure) = (0, 0);
+        let mut results: Vec<FunctionPurity> = Vec::new();
+        for (index, (file, _, function)) in functions.into_iter().enumerate() {
+            if !scope.contains(&file) {
+                continue;
+            }
+            if effectful[index] {
+                impure += 1;
+                if !include_effectful {
+                    continue;
+                }
+            } else {
+                pure += 1;
+            }
+            let function_id = store
+                .get_symbols_by_file(&file)
+                .into_iter()
+                .filter(|symbol| {
+                    matches!(
+                        symbol.symbol_type,
+                        SymbolType::Function | SymbolType::Method
+                    ) && symbol.name == function.function
+                        && symbol.location.start_line <= function.line
+                        && symbol.location.end_line >= function.line
+                })
+                .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                .map(|symbol| symbol.id.0);
+            results.push(FunctionPurity {
+                file,
+                function: function.function,
+                receiver_type: function.receiver_type,
+                line: function.line,
+                function_id,
+                pure: !effectful[index],
+                effects: std::mem::take(&mut effects[index]),
+                via: via[index].take().filter(|_| effectful[index]),
+            });
+        }
+
+        let total_found = results.len();
+        results.truncate(limit);
+
+        let response = ListPureFunctionsResponse {
+            functions: results,
+            pure,
+            effectful: impure,
+            files_checked: scope.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn list_stale_markers(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ListStaleMarkersRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+
+        let store = get_symbol_store();
+        let mut files: Vec<PathBuf> = store
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| match &scope {
+                Some(scope) => file.starts_with(scope),
+                None => true,
+            })
+            .collect();
+        files.sort();
+
+        let mut found: Vec<(PathBuf, Marker, Option<Symbol>)> = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "list_stale_markers".to_string(),
+                }));
+            }
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let symbols = store.get_symbols_by_file(file);
+            for marker in find_markers(&content) {
+                if params
+                    .kinds
+                    .as_ref()
+                    .is_some_and(|kinds| !kinds.contains(&marker.kind))
+                {
+                    continue;
+                }
+                let symbol = symbols
+                    .iter()
+                    .filter(|symbol| {
+                        symbol.location.start_line <= marker.line
+                            && symbol.location.end_line >= marker.line
+                    })
+                    .min_by_key(|symbol| symbol.location.end_line - symbol.location.start_line)
+                    .cloned();
+                found.push((file.clone(), marker, symbol));
+            }
+        }
+
+        let with_markers: HashSet<PathBuf> =
+            found.iter().map(|(file, _, _)| file.clone()).collect();
+        let blames = blame_files(with_markers.iter().cloned().collect()).await;
+        let now = SystemTime::now();
+        let mut by_kind: BTreeMap<MarkerKind, usize> = BTreeMap::new();
+        let mut markers: Vec<StaleMarker> = Vec::new();
+        for (file, marker, symbol) in found {
+            *by_kind.entry(marker.kind).or_default() += 1;
+            let blame = blames
+                .get(&file)
+                .and_then(|blame| blame.last_change(marker.line, marker.line))
+                .cloned();
+            let age_days = blame.as_ref().map(|info| {
+                let age = now.duration_since(info.time()).unwrap_or_default();
+                age.as_secs() / (24 * 60 * 60)
+            });
+            if let Some(min_age_days) = params.min_age_days {
+                if age_days.is_none_or(|age| age < min_age_days as u64) {
+                    continue;
+                }
+            }
+            markers.push(StaleMarker {
+                file,
+                symbol: symbol.as_ref().map(|symbol| symbol.name.clone()),
+                symbol_id: symbol.as_ref().map(|symbol| symbol.id.0),
+                marker,
+                age_days,
+                blame,
+            });
+        }
+
+        // Oldest first; markers of unknown age last, in source order
+        markers.sort_by_key(|marker| std::cmp::Reverse(marker.age_days.map(|age| age + 1)));
+        let total_found = markers.len();
+        markers.truncate(limit);
+
+        let response = ListStaleMarkersResponse {
+            markers,
+            by_kind,
+            files_checked: files.len(),
+            files_without_history: with_markers
+                .iter()
+                .filter(|file| !blames.contains_key(*file))
+                .count(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn diff_bodies(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: DiffBodiesRequest = Self::parse_arguments(arguments)?;
+        let granularity = params.granularity.unwrap_or_default();
+
+        let store = get_symbol_store();
+        let (a, a_source) = Self::definition_source(&store, params.id_a).await?;
+        let (b, b_source) = Self::definition_source(&store, params.id_b).await?;
+        let diff = diff_bodies(
+            (&a_source, a.location.start_line),
+            (&b_source, b.location.start_line),
+            granularity,
+            params.ignore_whitespace.unwrap_or(false),
+        )
+        .map_err(|e| ErrorData::new(ErrorCode::INVALID_PARAMS, e, None))?;
+
+        let definition = |symbol: Symbol| DiffedDefinition {
+            id: symbol.id.0,
+            name: symbol.name,
+            file: symbol.location.file,
+            start_line: symbol.location.start_line,
+            end_line: symbol.location.end_line,
+        };
+        let response = DiffBodiesResponse {
+            a: definition(a),
+            b: definition(b),
+            granularity,
+            identical: diff.added == 0 && diff.removed == 0,
+            diff,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn get_transitive_types(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: GetTransitiveTypesRequest = Self::parse_arguments(arguments)?;
+        let max_depth = params.max_depth.unwrap_or(10);
+        let include_vendor = params.include_vendor.unwrap_or(false);
+
+        let store = get_symbol_store();
+        let root = store
+            .get_symbol_by_id(&SymbolId(params.id))
+            .ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Symbol not found: {}", params.id),
+                    None,
+                )
+            })?;
+        if !Self::is_type_declaration(&root) {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Symbol {} is a {}, not a struct, class, interface or enum",
+                    params.id,
+                    root.symbol_type.as_str()
+                ),
+                None,
+            ));
+        }
+        let stops = |symbol: &Symbol| {
+            params.stop_at.iter().any(|stop| {
+                symbol.namespace.as_deref().is_some_and(|namespace| {
+                    stop == namespace
+                        || *stop == format!("{}.{}", namespace, symbol.name)
+                        || *stop == format!("{}::{}", namespace, symbol.name)
+                })
+            })
+        };
+
+        // Expand breadth first so each type's depth is its shortest path
+        let mut nodes: Vec<(Symbol, u32)> = vec![(root.clone(), 0)];
+        let mut edges: Vec<Vec<usize>> = vec![Vec::new()];
+        let mut index_of: HashMap<SymbolId, usize> = HashMap::from([(root.id, 0)]);
+        let mut truncated = HashSet::new();
+        let mut external: BTreeMap<String, BoundaryType> = BTreeMap::new();
+        let mut members_by_file: HashMap<PathBuf, Vec<TypeMembers>> = HashMap::new();
+        let mut next = 0;
+        while next < nodes.len() {
+            let (symbol, depth) = nodes[next].clone();
+            let current = next;
+            next += 1;
+            if depth >= max_depth {
+                truncated.insert(current);
+                continue;
+            }
+
+            for name in Self::referenced_type_names(&symbol, &mut members_by_file).await {
+                let target = Self::resolve_type_name(&store, &name, &symbol);
+                let boundary = match &target {
+                    None if name.contains('.') || name.contains("::") => {
+                        Some(BoundaryReason::NotIndexed)
+                    }
+                    // Unqualified names that are not indexed types are
+                    // builtins, parameters or method names
+                    None => continue,
+                    Some(target) if target.id == symbol.id => continue,
+                    Some(target)
+                        if !include_vendor
+                            && vendor_paths()
+                                .vendor_prefix(&target.location.file)
+                                .is_some() =>
+                    {
+                        Some(BoundaryReason::Vendored)
+                    }
+                    Some(target) if stops(target) => Some(BoundaryReason::StopAt),
+                    Some(_) => None,
+                };
+                if let Some(reason) = boundary {
+                    let entry = external
+                        .entry(name.clone())
+                        .or_insert_with(|| BoundaryType {
+                            name,
+                            reason,
+                            id: target.map(|target| target.id.0),
+                            referenced_by: Vec::new(),
+                        });
+                    if !entry.referenced_by.contains(&symbol.name) {
+                        entry.referenced_by.push(symbol.name.clone());
+                    }
+                    continue;
+                }
+
+                let Some(target) = target else { continue };
+                let index = match index_of.get(&target.id) {
+                    Some(&index) => index,
+                    None => {
+                        index_of.insert(target.id, nodes.len());
+                        nodes.push((target, depth + 1));
+                        edges.push(Vec::new());
+                        nodes.len() - 1
+                    }
+                };
+                if !edges[current].contains(&index) {
+                    edges[current].push(index);
+                }
+            }
+        }
+
+        let mut types = Vec::new();
+        for index in dependency_order(0, &edges) {
+            let (symbol, depth) = nodes[index].clone();
+            let source = match params.include_source.unwrap_or(true) {
+                true => Self::definition_source(&store, symbol.id.0)
+                    .await
+                    .ok()
+                    .map(|(_, source)| source),
+                false => None,
+            };
+            types.push(TransitiveType {
+                id: symbol.id.0,
+                name: symbol.name,
+                symbol_type: symbol.symbol_type,
+                namespace: symbol.namespace,
+                file: symbol.location.file,
+                start_line: symbol.location.start_line,
+                end_line: symbol.location.end_line,
+                depth,
+                depends_on: edges[index].iter().map(|&i| nodes[i].0.id.0).collect(),
+                truncated: truncated.contains(&index),
+                source,
+            });
+        }
+
+        let response = GetTransitiveTypesResponse {
+            type_name: root.name,
+            types,
+            external: external.into_values().collect(),
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn find_cycles(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindCyclesRequest = Self::parse_arguments(arguments)?;
+        let directory = match &params.path {
+            Some(path) => Some(PathResolver::resolve_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(50).max(1) as usize;
+
+        // Cycles can pass outside the directory, so the whole graph is searched
+        let store = get_symbol_store();
+        let mut response = FindCyclesResponse {
+            scope: params.scope,
+            type_cycles: Vec::new(),
+            package_cycles: Vec::new(),
+            total_found: 0,
+        };
+        match params.scope {
+            CycleScope::Types => {
+                let mut cycles = Self::type_cycles(
+                    &store,
+                    directory.as_deref(),
+                    params.include_methods.unwrap_or(false),
+                    params.include_vendor.unwrap_or(false),
+                    cancel,
+                )
+                .await?;
+                response.total_found = cycles.len();
+                cycles.truncate(limit);
+                response.type_cycles = cycles;
+            }
+            CycleScope::Packages => {
+                let examples = params.examples.unwrap_or(1) as usize;
+                let (packages, dependencies) =
+                    Self::package_dependencies(&store, None, examples, cancel, "find_cycles")?;
+                let mut cycles = Self::package_cycles(dependencies);
+                cycles.retain(|cycle| {
+                    directory.as_ref().is_none_or(|directory| {
+                        packages.iter().any(|(path, package)| {
+                            path.starts_with(directory) && cycle.members.contains(package)
+                        })
+                    })
+                });
+                response.total_found = cycles.len();
+                cycles.truncate(limit);
+                response.package_cycles = cycles;
+            }
+        }
+        Self::to_result(&response)
+    }
+
+    /// Cycles among type declarations through the types their fields, and
+    /// optionally their method signatures, name; largest first. With a
+    /// directory, only cycles with a member declared in it.
+    async fn type_cycles(
+        store: &SymbolStore,
+        directory: Option<&Path>,
+        include_methods: bool,
+        include_vendor: bool,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<DependencyCycle<RelatedSymbol, TypeDependency>>, ErrorData> {
+        let mut types: Vec<Symbol> = [
+            SymbolType::Class,
+            SymbolType::Struct,
+            SymbolType::Interface,
+            SymbolType::Enum,
+        ]
+        .iter()
+        .flat_map(|symbol_type| store.get_symbols_by_type(symbol_type))
+        .filter(|symbol| {
+            include_vendor
+                || vendor_paths()
+                    .vendor_prefix(&symbol.location.file)
+                    .is_none()
+        })
+        .collect();
+        types.sort_by_key(|symbol| symbol.id);
+        let by_id: HashMap<SymbolId, &Symbol> =
+            types.iter().map(|symbol| (symbol.id, symbol)).collect();
+
+        let mut edges: HashMap<SymbolId, Vec<SymbolId>> = HashMap::new();
+        let mut via: HashMap<(SymbolId, SymbolId), Vec<String>> = HashMap::new();
+        let mut members_by_file: HashMap<PathBuf, Vec<TypeMembers>> = HashMap::new();
+        for symbol in &types {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "find_cycles".to_string(),
+                }));
+            }
+            let targets = edges.entry(symbol.id).or_default();
+            let texts =
+                Self::type_dependency_texts(symbol, &mut members_by_file, include_methods).await;
+            for (source, text) in texts {
+                for name in candidate_type_names(&text) {
+                    let Some(target) = Self::resolve_type_name(store, &name, symbol) else {
+                        continue;
+                    };
+                    // A type referring to itself is not a cycle between types
+                    if target.id == symbol.id || !by_id.contains_key(&target.id) {
+                        continue;
+                    }
+                    if !targets.contains(&target.id) {
+                        targets.push(target.id);
+                    }
+                    let sources = via.entry((symbol.id, target.id)).or_default();
+                    if !sources.contains(&source) {
+                        sources.push(source.clone());
+                    }
+                }
+            }
+        }
+
+        let mut cycles: Vec<DependencyCycle<RelatedSymbol, TypeDependency>> =
+            strongly_connected_components(&edges)
+                .into_iter()
+                .filter(|component| {
+                    component.len() > 1
+                        && directory.is_none_or(|directory| {
+                            component
+                                .iter()
+                                .any(|id| by_id[id].location.file.starts_with(directory))
+                        })
+                })
+                .map(|component| {
+                    let mut cycle_edges = Vec::new();
+                    for from in &component {
+                        for to in &edges[from] {
+                            if !component.contains(to) {
+                                continue;
+                            }
+                            cycle_edges.push(TypeDependency {
+                                from: by_id[from].name.clone(),
+                                from_id: from.0,
+                                to: by_id[to].name.clone(),
+                                to_id: to.0,
+                                via: via.remove(&(*from, *to)).unwrap_or_default(),
+                            });
+                        }
+                    }
+                    DependencyCycle {
+                        size: component.len(),
+                        members: component
+                            .iter()
+                            .map(|id| RelatedSymbol {
+                                name: by_id[id].name.clone(),
+                                id: Some(id.0),
+                                file: Some(PathResolver::display_path(&by_id[id].location.file)),
+                                line: Some(by_id[id].location.start_line),
+                            })
+                            .collect(),
+                        edges: cycle_edges,
+                    }
+                })
+                .collect();
+        cycles.sort_by(|a, b| {
+            b.size
+                .cmp(&a.size)
+                .then_with(|| a.members[0].name.cmp(&b.members[0].name))
+        });
+        Ok(cycles)
+    }
+
+    /// Cycles in the package graph; largest first
+    fn package_cycles(
+        dependencies: Vec<PackageDependency>,
+    ) -> Vec<DependencyCycle<Package, PackageDependency>> {
+        let mut packages: Vec<Package> = dependencies
+            .iter()
+            .flat_map(|dependency| [dependency.from.clone(), dependency.to.clone()])
+            .collect();
+        packages.sort();
+        packages.dedup();
+        let index = |package: &Package| packages.binary_search(package).unwrap_or_default();
+
+        let mut edges: HashMap<usize, Vec<usize>> = HashMap::new();
+        for dependency in &dependencies {
+            edges
+                .entry(index(&dependency.from))
+                .or_default()
+                .push(index(&dependency.to));
+        }
+
+        let components: Vec<Vec<usize>> = strongly_connected_components(&edges)
+            .into_iter()
+            .filter(|component| component.len() > 1)
+            .collect();
+        let mut cycles: Vec<DependencyCycle<Package, PackageDependency>> = components
+            .iter()
+            .map(|component| DependencyCycle {
+                size: component.len(),
+                members: component.iter().map(|&i| packages[i].clone()).collect(),
+                edges: Vec::new(),
+            })
+            .collect();
+        for dependency in dependencies {
+            let (from, to) = (index(&dependency.from), index(&dependency.to));
+            if let Some(position) = components
+                .iter()
+                .position(|component| component.contains(&from) && component.contains(&to))
+            {
+                cycles[position].edges.push(dependency);
+            }
+        }
+        for cycle in &mut cycles {
+            cycle
+                .edges
+                .sort_by(|a, b| (&a.from, &a.to).cmp(&(&b.from, &b.to)));
+        }
+        cycles.sort_by(|a, b| {
+            b.size
+                .cmp(&a.size)
+                .then_with(|| a.members[0].cmp(&b.members[0]))
+        });
+        cycles
+    }
+
+    pub async fn find_references(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: FindReferencesRequest = Self::parse_arguments(arguments)?;
+        let store = get_symbol_store();
+        let mut response = {
+            let _snapshot = store.read_snapshot();
+            Self::collect_references(&store, &params)?
+        };
+
+        // Snippets come from the files, each read once, not from the index
+        let mut lines: Option<(PathBuf, Vec<String>)> = None;
+        for site in &mut response.references {
+            if lines.as_ref().is_none_or(|(file, _)| *file != site.file) {
+                if cancel.is_cancelled() {
+                    return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                        operation: "find_references".to_string(),
+                    }));
+                }
+                let content = tokio::fs::read_to_string(&site.file)
+                    .await
+                    .unwrap_or_default();
+                lines = Some((
+                    site.file.clone(),
+                    content.lines().map(str::to_string).collect(),
+                ));
+            }
+            site.snippet = lines.as_ref().and_then(|(_, lines)| {
+                let line = lines.get(site.line.checked_sub(1)? as usize)?;
+                Some(Self::reference_snippet(line))
+            });
+        }
+        Self::to_result(&response)
+    }
+
+    /// The `find_references` response as far as the index answers it, with
+    /// no snippets
+    fn collect_references(
+        store: &SymbolStore,
+        params: &FindReferencesRequest,
+    ) -> Result<FindReferencesResponse, ErrorData> {
+        let mut kinds: Vec<ReferenceType> = Vec::new();
+        for kind in params.kinds.iter().flatten() {
+            let Some((_, reference_type)) = REFERENCE_KINDS
+                .iter()
+                .find(|(name, _)| name.eq_ignore_ascii_case(kind))
+            else {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Unknown reference kind '{}'. Expected one of: {}",
+                        kind,
+                        REFERENCE_KINDS
+                            .iter()
+                            .map(|(name, _)| *name)
+                            .collect::<Vec<_>>()
+                            .join(", ")
+                    ),
+                    None,
+                ));
+            };
+            kinds.push(reference_type.clone());
+        }
+        let wants = |reference_type: &ReferenceType| match kinds.is_empty() {
+            true => *reference_type != ReferenceType::Definition,
+            false => kinds.contains(reference_type),
+        };
+        let scope = match &params.path {
+            Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+            None => None,
+        };
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let mut targets = match (&params.name, &params.location) {
+            (Some(name), None) => {
+                let mut targets = store.get_symbols(name);
+                if targets.is_empty() && (name.contains('.') || name.contains("::")) {
+                    targets = store.get_symbols_qualified(name);
+                }
+                if targets.is_empty() {
+                    return Err(ErrorData::new(
+                        ErrorCode::INVALID_PARAMS,
+                        format!("Symbol not found: {}", name),
+                        None,
+                    ));
+                }
+                targets
+            }
+            (None, Some(location)) => Self::symbols_at_location(store, location)?,
+            _ => {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    "Exactly one of name or location is required".to_string(),
+                    None,
+                ))
+            }
+        };
+        if params.include_aliases.unwrap_or(false) {
+            Self::add_aliases(store, &mut targets);
+        }
+
+        let mut references = Self::gather_references(store, &targets, wants, scope.as_deref());
+        let total_found = references.len();
+        references.truncate(limit);
+        let sites: Vec<ReferenceSite> = references
+            .into_iter()
+            .map(|(reference, target)| {
+                let from = Self::enclosing_symbol(store, &reference.location, &target.id);
+                ReferenceSite {
+                    file: reference.location.file,
+                    line: reference.location.start_line,
+                    column: reference.location.start_column,
+                    reference_type: reference.reference_type,
+                    snippet: None,
+                    from_symbol: from.as_ref().map(|symbol| symbol.name.clone()),
+                    from_symbol_id: from.as_ref().map(|symbol| symbol.id.0),
+                    symbol: target.name.clone(),
+                    symbol_id: target.id.0,
+                }
+            })
+            .collect();
+
+        Ok(FindReferencesResponse {
+            symbols: targets
+                .iter()
+                .map(|target| RelatedSymbol {
+                    name: target.name.clone(),
+                    id: Some(target.id.0),
+                    file: Some(PathResolver::display_path(&target.location.file)),
+                    line: Some(target.location.start_line),
+                })
+                .collect(),
+            truncated: sites.len() < total_found,
+            references: sites,
+            total_found,
+        })
+    }
+
+    /// Add the aliases and re-exports of `targets` that carry another name
+    pub(crate) fn add_aliases(store: &SymbolStore, targets: &mut Vec<Symbol>) {
+        let mut seen: HashSet<SymbolId> = targets.iter().map(|target| target.id).collect();
+        for target in targets.clone() {
+            for alias in store.get_aliases(&target) {
+                if alias.name != target.name && seen.insert(alias.id) {
+                    targets.push(alias);
+                }
+            }
+        }
+    }
+
+    /// References to `targets` of the wanted kinds, within `scope` when
+    /// given, ordered by position and with each place reported once per
+    /// target
+    pub(crate) fn gather_references<'a>(
+        store: &SymbolStore,
+        targets: &'a [Symbol],
+        wants: impl Fn(&ReferenceType) -> bool,
+        scope: Option<&Path>,
+    ) -> Vec<(Reference, &'a Symbol)> {
+        let mut references: Vec<(Reference, &Symbol)> = Vec::new();
+        for target in targets {
+            for reference in store.get_references(&target.id) {
+                if wants(&reference.reference_type)
+                    && scope.is_none_or(|scope| reference.location.file.starts_with(scope))
+                {
+                    references.push((reference, target));
+                }
+            }
+        }
+        references.sort_by(|(a, _), (b, _)| {
+            (
+                &a.location.file,
+                a.location.start_line,
+                a.location.start_column,
+                a.target_symbol.0,
+            )
+                .cmp(&(
+                    &b.location.file,
+                    b.location.start_line,
+                    b.location.start_column,
+                    b.target_symbol.0,
+                ))
+        });
+        references.dedup_by(|(a, _), (b, _)| {
+            a.location.file == b.location.file
+                && a.location.start_line == b.location.start_line
+                && a.location.start_column == b.location.start_column
+                && a.target_symbol == b.target_symbol
+        });
+        references
+    }
+
+    /// The symbols a `file:line[:column]` location names: those referenced
+    /// at it, or failing that those defined on the line. Without a column,
+    /// definitions are preferred.
+    fn symbols_at_location(store: &SymbolStore, location: &str) -> Result<Vec<Symbol>, ErrorData> {
+        let Some((file, line, column)) = split_location(location) else {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Invalid location '{}'. Expected file:line or file:line:column",
+                    location
+                ),
+                None,
+            ));
+        };
+        let file = PathResolver::resolve_file_path(file)?;
+
+        let defined = || -> Vec<Symbol> {
+            store
+                .get_symbols_by_file(&file)
+                .into_iter()
+                .filter(|symbol| symbol.location.start_line == line)
+                .collect()
+        };
+        let referenced = || -> Vec<Symbol> {
+            let mut seen: HashSet<SymbolId> = HashSet::new();
+            let mut ids: Vec<SymbolId> = store
+                .get_references_in_file(&file)
+                .into_iter()
+                .filter(|reference| {
+                    let at = &reference.location;
+                    at.start_line == line
+                        && column.is_none_or(|column| {
+                            at.start_column <= column
+                                && (at.end_line > line || column <= at.end_column)
+                        })
+                })
+                .map(|reference| reference.target_symbol)
+                .filter(|id| seen.insert(*id))
+                .collect();
+            ids.sort();
+            ids.iter()
+                .filter_map(|id| store.get_symbol_by_id(id))
+                .collect()
+        };
+
+        let symbols = match column {
+            Some(_) => Some(referenced())
+                .filter(|symbols| !symbols.is_empty())
+                .unwrap_or_else(defined),
+            None => Some(defined())
+                .filter(|symbols| !symbols.is_empty())
+                .unwrap_or_else(referenced),
+        };
+        if symbols.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("No symbol defined or referenced at {}", location),
+                None,
+            ));
+        }
+        Ok(symbols)
+    }
+
+    /// A reference's source line, trimmed and cut to a readable length
+    fn reference_snippet(line: &str) -> String {
+        const MAX_SNIPPET_CHARS: usize = 200;
+        let line = line.trim();
+        if line.chars().count() <= MAX_SNIPPET_CHARS {
+            return line.to_string();
+        }
+        let cut: String = line.chars().take(MAX_SNIPPET_CHARS).collect();
+        format!("{}...", cut)
+    }
+
+    fn is_type_declaration(symbol: &Symbol) -> bool {
+        matches!(
+            symbol.symbol_type,
+            SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum
+        )
+    }
+
+    /// Names that may refer to types in the fields and method signatures of
+    /// a type declaration, or in the type a Go declaration is defined with.
+    /// Parsed members are kept per file in `members_by_file`.
+    async fn referenced_type_names(
+        symbol: &Symbol,
+        members_by_file: &mut HashMap<PathBuf, Vec<TypeMembers>>,
+    ) -> Vec<String> {
+        let mut names: Vec<String> = Vec::new();
+        let texts = Self::type_dependency_texts(symbol, members_by_file, true).await;
+        for (_, text) in texts {
+            for name in candidate_type_names(&text) {
+                if !names.contains(&name) {
+                    names.push(name);
+                }
+            }
+        }
+        names
+    }
+
+    /// The field types, method signatures if asked, and the type a Go
+    /// declaration is defined with, each with the field or method it comes
+    /// from (`type` for the definition)
+    async fn type_dependency_texts(
+        symbol: &Symbol,
+        members_by_file: &mut HashMap<PathBuf, Vec<TypeMembers>>,
+        include_methods: bool,
+    ) -> Vec<(String, String)> {
+        let file = &symbol.location.file;
+        if !members_by_file.contains_key(file) {
+            let types = match (
+                Language::from_path(file),
+                tokio::fs::read_to_string(file).await,
+            ) {
+                (Some(language), Ok(content)) => {
+                    let mut types = extract_type_members(&content, language).unwrap_or_default();
+                    if language == Language::Go {
+                        OutlineTools::attach_package_methods(file, &mut types);
+                    }
+                    types
+                }
+                _ => Vec::new(),
+            };
+            members_by_file.insert(file.clone(), types);
+        }
+
+        let mut texts: Vec<(String, String)> = symbol
+            .tags
+            .get(TYPE_EXPR_TAG)
+            .map(|text| ("type".to_string(), text.clone()))
+            .into_iter()
+            .collect();
+        let members = members_by_file[file]
+            .iter()
+            .find(|members| {
+                members.name == symbol.name && members.line == symbol.location.start_line
+            })
+            .or_else(|| {
+                members_by_file[file]
+                    .iter()
+                    .find(|members| members.name == symbol.name)
+            });
+        if let Some(members) = members {
+            texts.extend(
+                members
+                    .fields
+                    .iter()
+                    .filter_map(|field| Some((field.name.clone(), field.type_name.clone()?))),
+            );
+            if include_methods {
+                texts.extend(
+                    members.methods.iter().filter_map(|method| {
+                        Some((method.name.clone(), method.signature.clone()?))
+                    }),
+                );
+            }
+        }
+        texts
+    }
+
+    /// The indexed type a name in `from`'s declaration refers to: a
+    /// qualified name in the namespace it names, a bare one preferably in
+    /// `from`'s namespace, then its directory
+    fn resolve_type_name(store: &SymbolStore, name: &str, from: &Symbol) -> Option<Symbol> {
+        let candidates = match name.contains('.') || name.contains("::") {
+            true => store.get_symbols_qualified(name),
+            false => store.get_symbols(name),
+        };
+        let language = Language::from_path(&from.location.file);
+        candidates
+            .into_iter()
+            .filter(|candidate| {
+                Self::is_type_declaration(candidate)
+                    && Language::from_path(&candidate.location.file) == language
+            })
+            .min_by_key(|candidate| {
+                (
+                    candidate.namespace != from.namespace,
+                    candidate.location.file.parent() != from.location.file.parent(),
+                    candidate.id.0,
+                )
+            })
+    }
+
+    /// Go type declarations named `name`, or `pkg.Name` in the package `pkg`
+    fn go_types_named(store: &SymbolStore, name: &str) -> Vec<Symbol> {
+        let candidates = if name.contains('.') {
+            store.get_symbols_qualified(name)
+        } else {
+            store.get_symbols(name)
+        };
+        candidates
+            .into_iter()
+            .filter(|candidate| {
+                matches!(
+                    candidate.symbol_type,
+                    SymbolType::Class | SymbolType::Struct
+                ) && Language::from_path(&candidate.location.file) == Some(Language::Go)
+            })
+            .collect()
+    }
+
+    /// Functions of a Go type's package whose first result is the type or a
+    /// pointer to it
+    fn go_constructors(store: &SymbolStore, go_type: &Symbol) -> Vec<Symbol> {
+        let directory = go_type.location.file.parent();
+        let mut constructors: Vec<Symbol> = store
+            .get_symbols_by_type(&SymbolType::Function)
+            .into_iter()
+            .filter(|function| function.location.file.parent() == directory)
+            .filter(|function| {
+                let first = function
+                    .signature
+                    .as_ref()
+                    .and_then(|signature| signature.return_type.as_deref())
+                    .and_then(|returns| return_values(returns).into_iter().next());
+                first.is_some_and(|first| {
+                    let type_name = first.trim_start_matches('*');
+                    type_name.split('[').next() == Some(go_type.name.as_str())
+                })
+            })
+            .collect();
+        constructors.sort_by(|a, b| a.name.cmp(&b.name));
+        constructors
+    }
+
+    /// The function or method a handler expression names: package-qualified
+    /// functions by their package, method values and plain names preferably
+    /// in the route's own package
+    fn route_handler(store: &SymbolStore, route: &Symbol, handler: &str) -> Option<Symbol> {
+        let (qualifier, name) = handler_name(handler)?;
+        let callable = |symbol: &Symbol| {
+            matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            )
+        };
+        if qualifier.is_some() {
+            let qualified = store.get_symbols_qualified(handler);
+            if let Some(function) = qualified.into_iter().find(|symbol| callable(symbol)) {
+                return Some(function);
+            }
+        }
+        let candidates: Vec<Symbol> = store
+            .get_symbols(name)
+            .into_iter()
+            .filter(|symbol| callable(symbol))
+            .collect();
+        let directory = route.location.file.parent();
+        candidates
+            .iter()
+            .find(|candidate| candidate.location.file.parent() == directory)
+            .cloned()
+            .or_else(|| candidates.into_iter().next())
+    }
+
+    /// Types, function results and method results of the Go package in
+    /// `directory`, with promoted members of embedded structs
+    async fn go_package_types(store: &SymbolStore, directory: &Path) -> PackageTypes {
+        let mut files: Vec<PathBuf> = store
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| {
+                file.parent() == Some(directory) && Language::from_path(file) == Some(Language::Go)
+            })
+            .collect();
+        files.sort();
+
+        let mut package = PackageTypes::default();
+        for file in files {
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            let Ok(types) = extract_type_members(&content, Language::Go) else {
+                continue;
+            };
+            for members in types {
+                package.types.entry(members.name.clone()).or_insert(members);
+            }
+        }
+        let declared = package.types.clone();
+        let mut types: Vec<TypeMembers> = package.types.into_values().collect();
+        promote_embedded(&mut types, &declared);
+        package.types = types.into_iter().map(|t| (t.name.clone(), t)).collect();
+
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if symbol.location.file.parent() != Some(directory)
+                || Language::from_path(&symbol.location.file) != Some(Language::Go)
+            {
+                continue;
+            }
+            let Some(results) = symbol
+                .signature
+                .as_ref()
+                .and_then(|signature| signature.return_type.clone())
+            else {
+                continue;
+            };
+            match symbol.symbol_type {
+                SymbolType::Function => {
+                    package.functions.insert(symbol.name.clone(), results);
+                }
+                SymbolType::Method => {
+                    if let Some(type_name) = receiver_type(symbol) {
+                        package
+                            .functions
+                            .insert(format!("{}.{}", type_name, symbol.name), results);
+                    }
+                }
+                _ => {}
+            }
+        }
+        package
+    }
+
+    /// Breadth-first walk along `edges` from `root`, up to `depth` steps and
+    /// `limit` neighbors, stopping with the frontier left to walk when
+    /// `budget` runs out
+    async fn walk_neighbors(
+        store: &SymbolStore,
+        root: &Symbol,
+        edges: &[String],
+        depth: u32,
+        limit: usize,
+        budget: &TimeBudget,
+        cancel: &CancellationToken,
+    ) -> Result<NeighborWalk, ErrorData> {
+        let mut index = NeighborIndex::default();
+        let mut neighbors: Vec<Neighbor> = Vec::new();
+        let mut truncated = false;
+        let mut timed_out = false;
+        let mut unwalked: Vec<FrontierSymbol> = Vec::new();
+        // Each symbol is walked from once, at the depth it was first reached
+        let mut reached: HashMap<SymbolId, u32> = HashMap::from([(root.id, 0)]);
+        let mut frontier = vec![root.clone()];
+        'walk: for level in 1..=depth {
+            let mut next = Vec::new();
+            for (position, symbol) in frontier.iter().enumerate() {
+                for edge in edges {
+                    if cancel.is_cancelled() {
+                        return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                            operation: "get_neighbors".to_string(),
+                        }));
+                    }
+                    let adjacent =
+                        Self::budgeted_adjacent(store, &mut index, symbol, edge, budget).await?;
+                    let Some(mut adjacent) = adjacent else {
+                        // Everything found so far links back to the start;
+                        // what is left to walk is the frontier
+                        truncated = true;
+                        timed_out = true;
+                        unwalked.extend(
+                            frontier[position..]
+                                .iter()
+                                .map(|symbol| FrontierSymbol::new(symbol, level - 1)),
+                        );
+                        if level < depth {
+                            unwalked.extend(
+                                next.iter().map(|symbol| FrontierSymbol::new(symbol, level)),
+                            );
+                        }
+                        break 'walk;
+                    };
+                    if adjacent.len() > limit {
+                        truncated = true;
+                        adjacent.truncate(limit);
+                    }
+                    for neighbor in adjacent {
+                        if reached.get(&neighbor.id).is_some_and(|&d| d < level) {
+                            continue;
+                        }
+                        if neighbors.len() >= limit {
+                            truncated = true;
+                            break 'walk;
+                        }
+                        neighbors.push(Neighbor {
+                            id: neighbor.id.0,
+                            name: neighbor.name.clone(),
+                            symbol_type: neighbor.symbol_type.clone(),
+                            file: neighbor.location.file.clone(),
+                            line: neighbor.location.start_line,
+                            edge: edge.clone(),
+                            from: symbol.id.0,
+                            depth: level,
+                        });
+                        if !reached.contains_key(&neighbor.id) {
+                            reached.insert(neighbor.id, level);
+                            next.push(neighbor);
+                        }
+                    }
+                }
+            }
+            frontier = next;
+        }
+
+        Ok(NeighborWalk {
+            neighbors,
+            truncated,
+            timed_out,
+            frontier: unwalked,
+        })
+    }
+
+    /// Breadth-first walk along `edge` from `root` for `export_graph`, so
+    /// the nodes kept under `max_nodes` are the closest ones
+    async fn walk_graph(
+        store: &SymbolStore,
+        root: &Symbol,
+        edge: &str,
+        depth: u32,
+        max_nodes: usize,
+        budget: &TimeBudget,
+        cancel: &CancellationToken,
+    ) -> Result<GraphWalk, ErrorData> {
+        let mut index = NeighborIndex::default();
+        let mut nodes = vec![GraphNode::from_symbol(root)];
+        let mut included: HashSet<SymbolId> = HashSet::from([root.id]);
+        let mut edges: Vec<GraphEdge> = Vec::new();
+        let mut truncated = false;
+        let mut timed_out = false;
+        let mut unwalked: Vec<FrontierSymbol> = Vec::new();
+        let mut frontier = vec![root.clone()];
+        'walk: for level in 1..=depth {
+            let mut next = Vec::new();
+            for (position, symbol) in frontier.iter().enumerate() {
+                if cancel.is_cancelled() {
+                    return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                        operation: "export_graph".to_string(),
+                    }));
+                }
+                let adjacent =
+                    Self::budgeted_adjacent(store, &mut index, symbol, edge, budget).await?;
+                let Some(adjacent) = adjacent else {
+                    // Edges only join drawn nodes; those left to walk are
+                    // the frontier
+                    truncated = true;
+                    timed_out = true;
+                    unwalked.extend(
+                        frontier[position..]
+                            .iter()
+                            .map(|symbol| FrontierSymbol::new(symbol, level - 1)),
+                    );
+                    if level < depth {
+                        unwalked
+                            .extend(next.iter().map(|symbol| FrontierSymbol::new(symbol, level)));
+                    }
+                    break 'walk;
+                };
+                for neighbor in adjacent {
+                    if !included.contains(&neighbor.id) {
+                        if nodes.len() >= max_nodes {
+                            truncated = true;
+                            continue;
+                        }
+                        included.insert(neighbor.id);
+                        nodes.push(GraphNode::from_symbol(&neighbor));
+                        next.push(neighbor.clone());
+                    }
+                    let graph_edge = GraphEdge {
+                        from: symbol.id.0,
+                        to: neighbor.id.0,
+                        label: edge.to_string(),
+                    };
+                    if !edges.contains(&graph_edge) {
+                        edges.push(graph_edge);
+                    }
+                }
+            }
+            frontier = next;
+        }
+
+        Ok(GraphWalk {
+            nodes,
+            edges,
+            truncated,
+            timed_out,
+            frontier: unwalked,
+        })
+    }
+
+    /// `adjacent` within a time budget: `None` once the budget has run out,
+    /// an error only when the client cancels
+    async fn budgeted_adjacent(
+        store: &SymbolStore,
+        index: &mut NeighborIndex,
+        symbol: &Symbol,
+        edge: &str,
+        budget: &TimeBudget,
+    ) -> Result<Option<Vec<Symbol>>, ErrorData> {
+        if budget.expired() {
+            return Ok(None);
+        }
+        match Self::adjacent(store, index, symbol, edge, budget.token()).await {
+            Ok(adjacent) => Ok(Some(adjacent)),
+            Err(_) if budget.expired() => Ok(None),
+            Err(e) => Err(e),
+        }
+    }
+
+    /// Symbols one `edge` away from `symbol`, in file and line order
+    async fn adjacent(
+        store: &SymbolStore,
+        index: &mut NeighborIndex,
+        symbol: &Symbol,
+        edge: &str,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<Symbol>, ErrorData> {
+        let mut ids: Vec<SymbolId> = Vec::new();
+        match edge {
+            "calls" | "called_by" => {
+                if index.call_graph.is_none() {
+                    let graph = Self::call_graph(store, cancel, "get_neighbors").await?;
+                    index.callers = graph.callers();
+                    index.call_graph = Some(graph);
+                }
+                ids = match (edge, &index.call_graph) {
+                    ("calls", Some(graph)) => graph.callees(&symbol.id).to_vec(),
+                    _ => index.callers.get(&symbol.id).cloned().unwrap_or_default(),
+                };
+            }
+            "references" => {
+                let location = &symbol.location;
+                for entry in store.references.iter() {
+                    let inside = entry.value().iter().any(|reference| {
+                        reference.location.file == location.file
+                            && reference.location.start_line >= location.start_line
+                            && reference.location.end_line <= location.end_line
+                    });
+                    if inside {
+                        ids.push(*entry.key());
+                    }
+                }
+            }
+            "referenced_by" => {
+                for reference in store.get_references(&symbol.id) {
+                    if let Some(enclosing) =
+                        Self::enclosing_symbol(store, &reference.location, &symbol.id)
+                    {
+                        ids.push(enclosing.id);
+                    }
+                }
+            }
+            "implements" | "implemented_by" => {
+                ids = Self::implementation_edges(store, index, symbol, edge == "implements").await;
+            }
+            "uses_type" => {
+                let language = Language::from_path(&symbol.location.file);
+                let content = tokio::fs::read_to_string(&symbol.location.file).await.ok();
+                if let (Some(language), Some(content)) = (language, content) {
+                    if let Some(analysis) = analyze_definition(&content, language, &symbol.location)
+                    {
+                        ids = analysis
+                            .referenced_types
+                            .iter()
+                            .filter_map(|name| Self::resolve_type(store, name, symbol))
+                            .map(|found| found.id)
+                            .collect();
+                    }
+                }
+            }
+            _ => {}
+        }
+
+        let mut symbols: Vec<Symbol> = Vec::new();
+        for id in ids {
+            if id == symbol.id || symbols.iter().any(|s| s.id == id) {
+                continue;
+            }
+            if let Some(found) = store.get_symbol_by_id(&id) {
+                symbols.push(found);
+            }
+        }
+        symbols.sort_by(|a, b| {
+            a.location
+                .file
+                .cmp(&b.location.file)
+                .then(a.location.start_line.cmp(&b.location.start_line))
+        });
+        Ok(symbols)
+    }
+
+    /// Interfaces `symbol` implements, or types implementing it. Go types
+    /// implement interfaces structurally; other languages declare them in
+    /// the class header.
+    async fn implementation_edges(
+        store: &SymbolStore,
+        index: &mut NeighborIndex,
+        symbol: &Symbol,
+        implements: bool,
+    ) -> Vec<SymbolId> {
+        if Language::from_path(&symbol.location.file) == Some(Language::Go) {
+            if index.go_types.is_none() {
+                index.go_types = Some(Self::go_type_index(store).await);
+            }
+            let Some(go_types) = &index.go_types else {
+                return Vec::new();
+            };
+            let package = symbol.location.file.parent().map(Path::to_path_buf);
+            if implements {
+                let Some(methods) = package
+                    .and_then(|package| go_types.methods.get(&(package, symbol.name.clone())))
+                else {
+                    return Vec::new();
+                };
+                return go_types
+                    .interfaces
+                    .iter()
+                    .filter(|(_, contract)| satisfies(methods, contract))
+                    .map(|(interface, _)| interface.id)
+                    .collect();
+            }
+            let Some((_, contract)) = go_types
+                .interfaces
+                .iter()
+                .find(|(interface, _)| interface.id == symbol.id)
+            else {
+                return Vec::new();
+            };
+            return Self::go_implementations(store, go_types, contract);
+        }
+
+        if implements {
+            let Ok(content) = tokio::fs::read_to_string(&symbol.location.file).await else {
+                return Vec::new();
+            };
+            return supertypes(&content, symbol)
+                .iter()
+                .filter_map(|name| Self::resolve_type(store, name, symbol))
+                .map(|found| found.id)
+                .collect();
+        }
+
+        // Read the headers of classes in files that mention the interface
+        let mut classes_by_file: HashMap<PathBuf, Vec<Symbol>> = HashMap::new();
+        for entry in store.symbol_data.iter() {
+            let candidate = entry.value();
+            if matches!(
+                candidate.symbol_type,
+                SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum
+            ) && candidate.id != symbol.id
+                && Language::from_path(&candidate.location.file) != Some(Language::Go)
+            {
+                classes_by_file
+                    .entry(candidate.location.file.clone())
+                    .or_default()
+                    .push(candidate.clone());
+            }
+        }
+        let mut implementations = Vec::new();
+        for (file, classes) in classes_by_file {
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            if !content.contains(symbol.name.as_str()) {
+                continue;
+            }
+            for class in classes {
+                if supertypes(&content, &class).contains(&symbol.name) {
+                    implementations.push(class.id);
+                }
+            }
+        }
+        implementations
+    }
+
+    /// Go types in the index whose method sets satisfy `contract`
+    fn go_implementations(
+        store: &SymbolStore,
+        go_types: &GoTypeIndex,
+        contract: &[ContractMethod],
+    ) -> Vec<SymbolId> {
+        let mut implementations = Vec::new();
+        for ((package, type_name), methods) in &go_types.methods {
+            if !satisfies(methods, contract) {
+                continue;
+            }
+            implementations.extend(
+                store
+                    .get_symbols(type_name)
+                    .into_iter()
+                    .filter(|candidate| {
+                        matches!(
+                            candidate.symbol_type,
+                            SymbolType::Class | SymbolType::Struct
+                        ) && candidate.location.file.parent() == Some(package.as_path())
+                    })
+                    .map(|candidate| candidate.id),
+            );
+        }
+        implementations
+    }
+
+    /// Method sets of Go types and the full method sets of Go interfaces
+    async fn go_type_index(store: &SymbolStore) -> GoTypeIndex {
+        let mut methods: HashMap<(PathBuf, String), HashMap<String, usize>> = HashMap::new();
+        let mut pointer_methods: HashSet<(PathBuf, String, String)> = HashSet::new();
+        let mut types: Vec<Symbol> = Vec::new();
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if Language::from_path(&symbol.location.file) != Some(Language::Go) {
+                continue;
+            }
+            match symbol.symbol_type {
+                SymbolType::Method => {
+                    let (Some(type_name), Some(package)) =
+                        (receiver_type(symbol), symbol.location.file.parent())
+                    else {
+                        continue;
+                    };
+                    let parameters = symbol
+                        .signature
+                        .as_ref()
+                        .map_or(0, |signature| signature.parameters.len());
+                    if has_pointer_receiver(symbol) {
+                        pointer_methods.insert((
+                            package.to_path_buf(),
+                            type_name.clone(),
+                            symbol.name.clone(),
+                        ));
+                    }
+                    methods
+                        .entry((package.to_path_buf(), type_name))
+                        .or_default()
+                        .insert(symbol.name.clone(), parameters);
+                }
+                SymbolType::Class | SymbolType::Interface => types.push(symbol.clone()),
+                _ => {}
+            }
+        }
+
+        let mut interfaces = Vec::new();
+        let mut contents: HashMap<PathBuf, Option<String>> = HashMap::new();
+        for symbol in types {
+            if !contents.contains_key(&symbol.location.file) {
+                let content = tokio::fs::read_to_string(&symbol.location.file).await.ok();
+                contents.insert(symbol.location.file.clone(), content);
+            }
+            let Some(content) = &contents[&symbol.location.file] else {
+                continue;
+            };
+            // Skip parsing types whose declaration line is not an interface
+            let declared = content
+                .lines()
+                .nth(symbol.location.start_line.saturating_sub(1) as usize)
+                .unwrap_or_default();
+            if !declared.contains("interface") {
+                continue;
+            }
+            if let Some(interface) = go_interface(content, &symbol.location) {
+                let (contract, _, _) = Self::expand_interface(store, &symbol, interface).await;
+                interfaces.push((symbol, contract));
+            }
+        }
+        GoTypeIndex {
+            methods,
+            pointer_methods,
+            interfaces,
+        }
+    }
+
+    /// The indexed type a name in `context` refers to, preferring the same
+    /// namespace
+    fn resolve_type(store: &SymbolStore, name: &str, context: &Symbol) -> Option<Symbol> {
+        let base = name.rsplit(['.', ':']).next().unwrap_or(name);
+        let candidates: Vec<Symbol> = store
+            .get_symbols(base)
+            .into_iter()
+            .filter(|candidate| {
+                candidate.id != context.id
+                    && matches!(
+                        candidate.symbol_type,
+                        SymbolType::Class
+                            | SymbolType::Struct
+                            | SymbolType::Interface
+                            | SymbolType::Enum
+                    )
+            })
+            .collect();
+        let same_namespace = candidates
+            .iter()
+            .position(|candidate| candidate.namespace == context.namespace);
+        match same_namespace {
+            Some(index) => candidates.into_iter().nth(index),
+            None => candidates.into_iter().next(),
+        }
+    }
+
+    /// The full method set of a Go interface, expanding embedded interfaces
+    /// breadth-first. Also returns the embedded interfaces, and those of
+    /// them that are not in the index.
+    async fn expand_interface(
+        store: &SymbolStore,
+        symbol: &Symbol,
+        interface: GoInterface,
+    ) -> (Vec<ContractMethod>, Vec<String>, Vec<String>) {
+        let mut methods = interface.methods;
+        let mut embedded: Vec<String> = Vec::new();
+        let mut unresolved_embedded: Vec<String> = Vec::new();
+
+        // Expand embedded interfaces breadth-first; the first declaration of
+        // a method name wins, as the outer interface's own methods come first
+        let mut queue: VecDeque<(String, Option<String>)> = interface
+            .embedded
+            .into_iter()
+            .map(|name| (name, symbol.namespace.clone()))
+            .collect();
+        while let Some((name, namespace)) = queue.pop_front() {
+            if embedded.contains(&name) {
+                continue;
+            }
+            embedded.push(name.clone());
+
+            let promoted = if name == "error" {
+                error_interface_methods()
+            } else {
+                let resolved = Self::resolve_go_type(store, &name, namespace.as_deref());
+                let nested = match &resolved {
+                    Some(resolved) => Self::go_interface_of(resolved).await,
+                    None => None,
+                };
+                let (Some(resolved), Some(nested)) = (resolved, nested) else {
+                    unresolved_embedded.push(name);
+                    continue;
+                };
+                queue.extend(
+                    nested
+                        .embedded
+                        .into_iter()
+                        .map(|inner| (inner, resolved.namespace.clone())),
+                );
+                nested
+                    .methods
+                    .into_iter()
+                    .map(|method| ContractMethod {
+                        from: Some(name.clone()),
+                        ..method
+                    })
+                    .collect()
+            };
+            for method in promoted {
+                if !methods.iter().any(|m| m.name == method.name) {
+                    methods.push(method);
+                }
+            }
+        }
+        (methods, embedded, unresolved_embedded)
+    }
+
+    /// Calls between every indexed function and method
+    async fn call_graph(
+        store: &SymbolStore,
+        cancel: &CancellationToken,
+        operation: &str,
+    ) -> Result<CallGraph, ErrorData> {
+        let mut functions_by_file: HashMap<PathBuf, Vec<Symbol>> = HashMap::new();
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ) {
+                functions_by_file
+                    .entry(symbol.location.file.clone())
+                    .or_default()
+                    .push(symbol.clone());
+            }
+        }
+
+        let mut graph = CallGraph::new();
+        for (file, functions) in &functions_by_file {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: operation.to_string(),
+                }));
+            }
+            if let Ok(content) = tokio::fs::read_to_string(file).await {
+                graph.add_file(store, file, &content, functions);
+            }
+        }
+        Ok(graph)
+    }
+
+    /// Whether two symbols live in the same package: the same namespace in
+    /// the same directory
+    fn same_package(a: &Symbol, b: &Symbol) -> bool {
+        a.namespace == b.namespace && a.location.file.parent() == b.location.file.parent()
+    }
+
+    /// Read the method set of an indexed Go interface from its file
+    async fn go_interface_of(symbol: &Symbol) -> Option<GoInterface> {
+        if Language::from_path(&symbol.location.file) != Some(Language::Go) {
+            return None;
+        }
+        let content = tokio::fs::read_to_string(&symbol.location.file)
+            .await
+            .ok()?;
+        go_interface(&content, &symbol.location)
+    }
+
+    /// Read the declaration of an indexed Go type from its file
+    async fn go_type(symbol: &Symbol) -> Option<GoTypeDefinition> {
+        if Language::from_path(&symbol.location.file) != Some(Language::Go) {
+            return None;
+        }
+        let content = tokio::fs::read_to_string(&symbol.location.file)
+            .await
+            .ok()?;
+        go_type_definition(&content, &symbol.location)
+    }
+
+    /// Find the Go type a name refers to: `pkg.Name` in the package named
+    /// `pkg`, a bare name preferably in `namespace`
+    fn resolve_go_type(store: &SymbolStore, name: &str, namespace: Option<&str>) -> Option<Symbol> {
+        let (qualifier, base) = match name.rsplit_once('.') {
+            Some((qualifier, base)) => (Some(qualifier), base),
+            None => (None, name),
+        };
+        let candidates: Vec<Symbol> = store
+            .get_symbols(base)
+            .into_iter()
+            .filter(|candidate| {
+                candidate.symbol_type == SymbolType::Class
+                    && Language::from_path(&candidate.location.file) == Some(Language::Go)
+            })
+            .collect();
+
+        match qualifier {
+            Some(qualifier) => candidates.into_iter().find(|candidate| {
+                candidate
+                    .namespace
+                    .as_deref()
+                    .and_then(|namespace| namespace.rsplit(['/', '.']).next())
+                    == Some(qualifier)
+            }),
+            None => {
+                let same_package = candidates
+                    .iter()
+                    .position(|candidate| candidate.namespace.as_deref() == namespace);
+                match same_package {
+                    Some(index) => candidates.into_iter().nth(index),
+                    None => candidates.into_iter().next(),
+                }
+            }
+        }
+    }
+
+    /// Symbols whose bodies reference `symbol`, one entry per enclosing symbol
+    fn callers(store: &SymbolStore, symbol: &Symbol, limit: usize) -> Vec<RelatedSymbol> {
+        let mut callers: Vec<RelatedSymbol> = Vec::new();
+        for reference in store.get_references(&symbol.id) {
+            let location = &reference.location;
+            let enclosing = Self::enclosing_symbol(store, location, &symbol.id);
+
+            let caller = match enclosing {
+                Some(enclosing) => RelatedSymbol {
+                    name: enclosing.name,
+                    id: Some(enclosing.id.0),
+                    file: Some(PathResolver::display_path(&location.file)),
+                    line: Some(location.start_line),
+                },
+                None => RelatedSymbol {
+                    name: PathResolver::display_path(&location.file),
+                    id: None,
+                    file: Some(PathResolver::display_path(&location.file)),
+                    line: Some(location.start_line),
+                },
+            };
+            if !callers.iter().any(|c| c.id.is_some() && c.id == caller.id) {
+                callers.push(caller);
+            }
+            if callers.len() >= limit {
+                break;
+            }
+        }
+        callers
+    }
+
+    /// The dependencies between packages, from references in one package's
+    /// directory to symbols of another, with up to `examples` sample
+    /// references each, most referenced first. With `directory`, only
+    /// dependencies from or to packages under it. Also returns every
+    /// package, keyed by directory.
+    fn package_dependencies(
+        store: &SymbolStore,
+        directory: Option<&Path>,
+        examples: usize,
+        cancel: &CancellationToken,
+        operation: &str,
+    ) -> Result<(HashMap<PathBuf, Package>, Vec<PackageDependency>), ErrorData> {
+        #[derive(Default)]
+        struct Dependency {
+            references: usize,
+            symbols: HashSet<SymbolId>,
+            examples: Vec<(Reference, Symbol)>,
+        }
+
+        let _snapshot = store.read_snapshot();
+        let package = |directory: &Path| Package {
+            path: PathResolver::display_path(directory),
+            name: None,
+        };
+        // A directory mixing namespaces is named after the first in order
+        let mut packages: HashMap<PathBuf, Package> = HashMap::new();
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            let Some(parent) = symbol.location.file.parent() else {
+                continue;
+            };
+            let entry = packages
+                .entry(parent.to_path_buf())
+                .or_insert_with(|| package(parent));
+            if let Some(namespace) = &symbol.namespace {
+                if entry.name.as_ref().is_none_or(|name| namespace < name) {
+                    entry.name = Some(namespace.clone());
+                }
+            }
+        }
+
+        let mut dependencies: HashMap<(PathBuf, PathBuf), Dependency> = HashMap::new();
+        for entry in store.references.iter() {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: operation.to_string(),
+                }));
+            }
+            let Some(target) = store.get_symbol_by_id(entry.key()) else {
+                continue;
+            };
+            let Some(to) = target.location.file.parent() else {
+                continue;
+            };
+            for reference in entry.value() {
+                let Some(from) = reference.location.file.parent() else {
+                    continue;
+                };
+                if reference.reference_type == ReferenceType::Definition
+                    || from == to
+                    || directory.is_some_and(|directory| {
+                        !from.starts_with(directory) && !to.starts_with(directory)
+                    })
+                {
+                    continue;
+                }
+                let dependency = dependencies
+                    .entry((from.to_path_buf(), to.to_path_buf()))
+                    .or_default();
+                dependency.references += 1;
+                dependency.symbols.insert(target.id);
+                if examples > 0 {
+                    // Keep the first references in file order
+                    dependency
+                        .examples
+                        .push((reference.clone(), target.clone()));
+                    if dependency.examples.len() > examples {
+                        dependency.examples.sort_by(|(a, _), (b, _)| {
+                            (
+                                &a.location.file,
+                                a.location.start_line,
+                                a.location.start_column,
+                            )
+                                .cmp(&(
+                                    &b.location.file,
+                                    b.location.start_line,
+                                    b.location.start_column,
+                                ))
+                        });
+                        dependency.examples.pop();
+                    }
+                }
+            }
+        }
+
+        let mut result: Vec<PackageDependency> = dependencies
+            .into_iter()
+            .map(|((from, to), dependency)| {
+                let mut examples: Vec<PackageReference> = dependency
+                    .examples
+                    .into_iter()
+                    .map(|(reference, target)| PackageReference {
+                        from_symbol: Self::enclosing_symbol(store, &reference.location, &target.id)
+                            .map(|symbol| symbol.name),
+                        file: reference.location.file,
+                        line: reference.location.start_line,
+                        column: reference.location.start_column,
+                        reference_type: reference.reference_type,
+                        symbol: target.name,
+                        symbol_id: target.id.0,
+                    })
+                    .collect();
+                examples
+                    .sort_by(|a, b| (&a.file, a.line, a.column).cmp(&(&b.file, b.line, b.column)));
+                PackageDependency {
+                    from: packages
+                        .get(&from)
+                        .cloned()
+                        .unwrap_or_else(|| package(&from)),
+                    to: packages.get(&to).cloned().unwrap_or_else(|| package(&to)),
+                    references: dependency.references,
+                    symbols: dependency.symbols.len(),
+                    examples,
+                }
+            })
+            .collect();
+        result.sort_by(|a, b| {
+            b.references
+                .cmp(&a.references)
+                .then_with(|| a.from.cmp(&b.from))
+                .then_with(|| a.to.cmp(&b.to))
+        });
+        Ok((packages, result))
+    }
+
+    /// Whether a name is an indexed type, so calling it is a conversion
+    fn is_indexed_type(store: &SymbolStore, name: &str) -> bool {
+        store.get_symbols(name).iter().any(|symbol| {
+            matches!(
+                symbol.symbol_type,
+                SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum
+            )
+        })
+    }
+
+    /// The panic sites of `function` in one file, added on first use
+    fn function_panics<'a>(
+        functions: &'a mut Vec<FunctionPanics>,
+        file: &Path,
+        function: &Option<String>,
+        function_id: Option<u64>,
+    ) -> &'a mut FunctionPanics {
+        let position = match functions
+            .iter()
+            .position(|f| &f.function == function && f.function_id == function_id)
+        {
+            Some(position) => position,
+            None => {
+                functions.push(FunctionPanics {
+                    file: file.to_path_buf(),
+                    function: function.clone(),
+                    function_id,
+                    explicit: Vec::new(),
+                    implicit: Vec::new(),
+                    recovers: Vec::new(),
+                    recovered: false,
+                });
+                functions.len() - 1
+            }
+        };
+        &mut functions[position]
+    }
+
+    /// The innermost symbol other than `exclude` whose lines contain `location`
+    fn enclosing_symbol(
+        store: &SymbolStore,
+        location: &Location,
+        exclude: &SymbolId,
+    ) -> Option<Symbol> {
+        store
+            .get_symbols_by_file(&location.file)
+            .into_iter()
+            .filter(|candidate| {
+                candidate.id != *exclude
+                    && candidate.location.start_line <= location.start_line
+                    && candidate.location.end_line >= location.end_line
+            })
+            .min_by_key(|candidate| candidate.location.end_line - candidate.location.start_line)
+    }
+
+    /// Look names up in the index, preferring definitions in the same namespace
+    fn resolve_names(
+        store: &SymbolStore,
+        names: &[String],
+        context: &Symbol,
+        limit: usize,
+    ) -> Vec<RelatedSymbol> {
+        names
+            .iter()
+            .filter(|name| **name != context.name)
+            .take(limit)
+            .map(|name| {
+                let candidates = store.get_symbols(name);
+                let best = candidates
+                    .iter()
+                    .find(|c| c.namespace == context.namespace)
+                    .or_else(|| candidates.first());
+                match best {
+                    Some(found) => RelatedSymbol {
+                        name: name.clone(),
+                        id: Some(found.id.0),
+                        file: Some(PathResolver::display_path(&found.location.file)),
+                        line: Some(found.location.start_line),
+                    },
+                    None => RelatedSymbol {
+                        name: name.clone(),
+                        id: None,
+                        file: None,
+                        line: None,
+                    },
+                }
+            })
+            .collect()
+    }
+
+    /// A symbol and the lines of its definition as they are on disk
+    async fn definition_source(
+        store: &SymbolStore,
+        id: u64,
+    ) -> Result<(Symbol, String), ErrorData> {
+        let symbol = store.get_symbol_by_id(&SymbolId(id)).ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol not found: {}", id),
+                None,
+            )
+        })?;
+        let content = tokio::fs::read_to_string(&symbol.location.file)
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to read {}: {}", symbol.location.file.display(), e),
+                    None,
+                )
+            })?;
+        let lines: Vec<&str> = content.lines().collect();
+        let start_line = (symbol.location.start_line as usize).saturating_sub(1);
+        let end_line = std::cmp::min(symbol.location.end_line as usize, lines.len());
+        let source = match start_line < end_line {
+            true => lines[start_line..end_line].join("\n"),
+            false => String::new(),
+        };
+        Ok((symbol, source))
+    }
+
+    fn symbol_signature(store: &SymbolStore, id: u64) -> Result<(Symbol, Signature), ErrorData> {
+        let symbol = store.get_symbol_by_id(&SymbolId(id)).ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol not found: {}", id),
+                None,
+            )
+        })?;
+        let signature = symbol.signature.clone().ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Symbol '{}' ({}) has no signature", symbol.name, id),
+                None,
+            )
+        })?;
+        Ok((symbol, signature))
+    }
+
+    fn parse_arguments<T: for<'de> Deserialize<'de>>(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<T, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        serde_json::from_value(Value::Object(args)).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Invalid arguments: {}", e),
+                None,
+            )
+        })
+    }
+
+    fn to_result<T: Serialize>(response: &T) -> Result<CallToolResult, ErrorData> {
+        encode_response(response)
+    }
+
+    /// `to_result`, shortening the `lists` of the response to fit `max_tokens`
+    fn to_budgeted_result<T: Serialize>(
+        response: &T,
+        lists: &[&str],
+        max_tokens: u32,
+    ) -> Result<CallToolResult, ErrorData> {
+        let mut value = serde_json::to_value(response).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Serialization error: {}", e),
+                None,
+            )
+        })?;
+        fit_to_budget(&mut value, lists, max_tokens as usize);
+        Self::to_result(&value)
+    }
+}
+
+/// Supertypes a class declares: those the indexer recorded where the
+/// grammar exposes them (Scala), otherwise read from its header
+fn supertypes(content: &str, symbol: &Symbol) -> Vec<String> {
+    match symbol.tags.get(EXTENDS_TAG) {
+        Some(names) => names.split(", ").map(String::from).collect(),
+        None => declared_supertypes(&declaration_header(content, symbol), &symbol.name),
+    }
+}
+
+/// The first lines of a declaration, up to its opening brace or colon
+fn declaration_header(content: &str, symbol: &Symbol) -> String {
+    let mut header = String::new();
+    for line in content
+        .lines()
+        .skip(symbol.location.start_line.saturating_sub(1) as usize)
+        .take(5)
+    {
+        header.push_str(line.trim());
+        header.push(' ');
+        if line.contains('{') || line.trim_end().ends_with(':') {
+            break;
+        }
+    }
+    header
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::time::Duration;
+
+    fn test_symbol(name: &str, line: u32) -> Symbol {
+        let file = PathBuf::from("/repo/walk.go");
+        Symbol {
+            id: SymbolId::new(&file, line, 0),
+            name: name.to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location::new(file, line, 0, line + 1, 1),
+            namespace: None,
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    /// A tree of functions each referencing `fan_out` others from its
+    /// first line, `levels` deep below the root
+    fn reference_tree(fan_out: usize, levels: u32) -> (SymbolStore, Symbol) {
+        let store = SymbolStore::new();
+        let root = test_symbol("root", 1);
+        store.insert_symbol_unchecked(root.clone());
+        let mut parents = vec![root.clone()];
+        let mut line = 1;
+        for level in 1..=levels {
+            let mut children = Vec::new();
+            for parent in &parents {
+                for i in 0..fan_out {
+                    line += 2;
+                    let child = test_symbol(&format!("f{}_{}", level, line), line);
+                    store.insert_symbol_unchecked(child.clone());
+                    store.add_reference(
+                        child.id,
+                        Reference {
+                            location: Location::new(
+                                parent.location.file.clone(),
+                                parent.location.start_line,
+                                4 + i as u32,
+                                parent.location.start_line,
+                                5 + i as u32,
+                            ),
+                            reference_type: ReferenceType::Call,
+                            target_symbol: child.id,
+                        },
+                    );
+                    children.push(child);
+                }
+            }
+            parents = children;
+        }
+        (store, root)
+    }
+
+    fn neighbor_key(neighbor: &Neighbor) -> (u64, u64, u32) {
+        (neighbor.id, neighbor.from, neighbor.depth)
+    }
+
+    /// A walk cut short agrees with the full one: its neighbors come first
+    /// in it, and everything it misses is reachable from its frontier
+    fn assert_partial_neighbors(partial: &NeighborWalk, full: &NeighborWalk, root: &Symbol) {
+        assert!(!partial.timed_out || partial.truncated);
+        assert_eq!(partial.timed_out, !partial.frontier.is_empty());
+        let found: Vec<_> = partial.neighbors.iter().map(neighbor_key).collect();
+        let expected: Vec<_> = full.neighbors.iter().map(neighbor_key).collect();
+        assert_eq!(found, expected[..found.len()]);
+
+        let frontier: HashSet<u64> = partial.frontier.iter().map(|symbol| symbol.id).collect();
+        let returned: HashSet<u64> = found.iter().map(|(id, _, _)| *id).collect();
+        for id in &frontier {
+            assert!(*id == root.id.0 || returned.contains(id));
+        }
+        let missing: HashSet<u64> = expected[found.len()..]
+            .iter()
+            .map(|(id, _, _)| *id)
+            .collect();
+        for (_, from, _) in &expected[found.len()..] {
+            assert!(frontier.contains(from) || missing.contains(from));
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_walk_neighbors_within_budget() {
+        let (store, root) = reference_tree(12, 3);
+        let edges = vec!["references".to_string()];
+        let cancel = CancellationToken::new();
+
+        let unbounded = TimeBudget::new(&cancel, None);
+        let full =
+            AnalysisTools::walk_neighbors(&store, &root, &edges, 3, 100_000, &unbounded, &cancel)
+                .await
+                .unwrap();
+        assert_eq!(full.neighbors.len(), 12 + 144 + 1728);
+        assert!(!full.truncated && !full.timed_out && full.frontier.is_empty());
+
+        // A budget spent before the walk starts leaves only the root to walk
+        let spent = TimeBudget::new(&cancel, Some(0));
+        tokio::time::sleep(Duration::from_millis(20)).await;
+        let walk =
+            AnalysisTools::walk_neighbors(&store, &root, &edges, 3, 100_000, &spent, &cancel)
+                .await
+                .unwrap();
+        assert!(walk.neighbors.is_empty());
+        assert!(walk.timed_out && walk.truncated);
+        assert_eq!(walk.frontier.len(), 1);
+        assert_eq!(
+            (walk.frontier[0].id, walk.frontier[0].depth),
+            (root.id.0, 0)
+        );
+
+        // Budgets running out part way through stay consistent wherever
+        // they stop
+        for timeout_ms in [1, 2, 5, 10] {
+            let budget = TimeBudget::new(&cancel, Some(timeout_ms));
+            let walk =
+                AnalysisTools::walk_neighbors(&store, &root, &edges, 3, 100_000, &budget, &cancel)
+                    .await
+                    .unwrap();
+            assert_partial_neighbors(&walk, &full, &root);
+        }
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 2)]
+    async fn test_walk_graph_within_budget() {
+        let (store, root) = reference_tree(12, 3);
+        let cancel = CancellationToken::new();
+
+        let unbounded = TimeBudget::new(&cancel, None);
+        let full =
+            AnalysisTools::walk_graph(&store, &root, "references", 3, 100_000, &unbounded, &cancel)
+                .await
+                .unwrap();
+        assert_eq!(full.nodes.len(), 1 + 12 + 144 + 1728);
+        assert!(!full.timed_out);
+
+        let spent = TimeBudget::new(&cancel, Some(0));
+        tokio::time::sleep(Duration::from_millis(20)).await;
+        let walk =
+            AnalysisTools::walk_graph(&store, &root, "references", 3, 100_000, &spent, &cancel)
+                .await
+                .unwrap();
+        assert_eq!(walk.nodes.len(), 1);
+        assert!(walk.edges.is_empty());
+        assert!(walk.timed_out && walk.truncated);
+        assert_eq!(walk.frontier[0].id, root.id.0);
+
+        for timeout_ms in [1, 2, 5, 10] {
+            let budget = TimeBudget::new(&cancel, Some(timeout_ms));
+            let walk = AnalysisTools::walk_graph(
+                &store,
+                &root,
+                "references",
+                3,
+                100_000,
+                &budget,
+                &cancel,
+            )
+            .await
+            .unwrap();
+            assert!(!walk.timed_out || walk.truncated);
+            assert_eq!(walk.timed_out, !walk.frontier.is_empty());
+            let node_ids: Vec<u64> = walk.nodes.iter().map(|node| node.id).collect();
+            let full_ids: Vec<u64> = full.nodes.iter().map(|node| node.id).collect();
+            assert_eq!(node_ids, full_ids[..node_ids.len()]);
+            assert_eq!(walk.edges, full.edges[..walk.edges.len()]);
+            // Every drawn node is the root or the end of a drawn edge, and
+            // the frontier is drawn
+            let drawn: HashSet<u64> = node_ids.iter().copied().collect();
+            for edge in &walk.edges {
+                assert!(drawn.contains(&edge.from) && drawn.contains(&edge.to));
+            }
+            for symbol in &walk.frontier {
+                assert!(drawn.contains(&symbol.id));
+            }
+        }
+    }
+
+    /// `handler.go` defines `Handle`, which `main.go` calls and uses and
+    /// `cmd/run.go` calls. The call in `main.go` is recorded twice.
+    fn reference_fixture() -> (tempfile::TempDir, PathBuf, SymbolStore) {
+        let dir = tempfile::TempDir::new().unwrap();
+        let root = dir.path().canonicalize().unwrap();
+        std::fs::create_dir(root.join("cmd")).unwrap();
+        for file in ["handler.go", "main.go", "cmd/run.go"] {
+            std::fs::write(root.join(file), "package main\n").unwrap();
+        }
+
+        let store = SymbolStore::new();
+        let symbol = |name: &str, file: &str, start_line: u32, end_line: u32| {
+            let file = root.join(file);
+            Symbol {
+                id: SymbolId::new(&file, start_line, 0),
+                name: name.to_string(),
+                symbol_type: SymbolType::Function,
+                location: Location::new(file, start_line, 0, end_line, 1),
+                ..test_symbol(name, start_line)
+            }
+        };
+        let handle = symbol("Handle", "handler.go", 3, 5);
+        store.insert_symbol_unchecked(handle.clone());
+        store.insert_symbol_unchecked(symbol("main", "main.go", 3, 9));
+        store.insert_symbol_unchecked(symbol("Run", "cmd/run.go", 1, 6));
+
+        let reference = |file: &str, line: u32, column: u32, reference_type| Reference {
+            location: Location::new(root.join(file), line, column, line, column + 6),
+            reference_type,
+            target_symbol: handle.id,
+        };
+        for (file, line, column, reference_type) in [
+            ("main.go", 7, 4, ReferenceType::Usage),
+            ("handler.go", 3, 5, ReferenceType::Definition),
+            ("main.go", 5, 4, ReferenceType::Call),
+            ("cmd/run.go", 2, 8, ReferenceType::Call),
+            ("main.go", 5, 4, ReferenceType::Call),
+        ] {
+            store.add_reference(handle.id, reference(file, line, column, reference_type));
+        }
+        (dir, root, store)
+    }
+
+    fn find_references(store: &SymbolStore, request: Value) -> FindReferencesResponse {
+        let params: FindReferencesRequest = serde_json::from_value(request).unwrap();
+        AnalysisTools::collect_references(store, &params).unwrap()
+    }
+
+    fn site_key(site: &ReferenceSite) -> (PathBuf, u32, Option<String>) {
+        (site.file.clone(), site.line, site.from_symbol.clone())
+    }
+
+    #[test]
+    fn test_find_references_by_name_and_location() {
+        let (_dir, root, store) = reference_fixture();
+        let by_name = find_references(&store, json!({"name": "Handle"}));
+        assert_eq!(by_name.symbols.len(), 1);
+        assert_eq!(by_name.symbols[0].name, "Handle");
+        // Sorted by file and line, the repeated call reported once and the
+        // definition left out
+        let sites: Vec<_> = by_name.references.iter().map(site_key).collect();
+        assert_eq!(
+            sites,
+            vec![
+                (root.join("cmd/run.go"), 2, Some("Run".to_string())),
+                (root.join("main.go"), 5, Some("main".to_string())),
+                (root.join("main.go"), 7, Some("main".to_string())),
+            ]
+        );
+        assert_eq!(by_name.total_found, 3);
+        assert!(!by_name.truncated);
+
+        let at = |location: String| {
+            let response = find_references(&store, json!({ "location": location }));
+            let symbols: Vec<String> = response.symbols.into_iter().map(|s| s.name).collect();
+            let sites: Vec<_> = response.references.iter().map(site_key).collect();
+            (symbols, sites)
+        };
+        let expected = (vec!["Handle".to_string()], sites);
+        // A reference, with and without its column, and the definition
+        assert_eq!(
+            at(format!("{}:5:6", root.join("main.go").display())),
+            expected
+        );
+        assert_eq!(
+            at(format!("{}:7", root.join("main.go").display())),
+            expected
+        );
+        assert_eq!(
+            at(format!("{}:3", root.join("handler.go").display())),
+            expected
+        );
+    }
+
+    #[test]
+    fn test_find_references_filters_and_limit() {
+        let (_dir, root, store) = reference_fixture();
+        let lines = |request: Value| -> Vec<(PathBuf, u32)> {
+            find_references(&store, request)
+                .references
+                .iter()
+                .map(|site| (site.file.clone(), site.line))
+                .collect()
+        };
+
+        assert_eq!(
+            lines(json!({"name": "Handle", "kinds": ["call"]})),
+            vec![(root.join("cmd/run.go"), 2), (root.join("main.go"), 5)]
+        );
+        assert_eq!(
+            lines(json!({"name": "Handle", "kinds": ["Definition"]})),
+            vec![(root.join("handler.go"), 3)]
+        );
+        assert_eq!(
+            lines(json!({"name": "Handle", "path": root.join("cmd")})),
+            vec![(root.join("cmd/run.go"), 2)]
+        );
+
+        let limited = find_references(&store, json!({"name": "Handle", "limit": 1}));
+        assert_eq!(limited.references.len(), 1);
+        assert_eq!(limited.references[0].file, root.join("cmd/run.go"));
+        assert_eq!(limited.total_found, 3);
+        assert!(limited.truncated);
+    }
+
+    #[test]
+    fn test_find_references_invalid_requests() {
+        let (_dir, root, store) = reference_fixture();
+        let error = |request: Value| -> String {
+            let params: FindReferencesRequest = serde_json::from_value(request).unwrap();
+            match AnalysisTools::collect_references(&store, &params) {
+                Ok(_) => panic!("expected an error"),
+                Err(e) => e.message.to_string(),
+            }
+        };
+        let main = root.join("main.go").display().to_string();
+
+        assert!(error(json!({ "location": main })).starts_with("Invalid location"));
+        assert!(error(json!({ "location": format!("{}:x", main) })).starts_with("Invalid location"));
+        assert!(error(json!({ "location": format!("{}:1:0", main) }))
+            .starts_with("No symbol defined or referenced"));
+        assert!(error(json!({"name": "Missing"})).starts_with("Symbol not found"));
+        assert!(error(json!({"kinds": ["call"]})).starts_with("Exactly one"));
+        assert!(
+            error(json!({"name": "Handle", "location": format!("{}:5", main)}))
+                .starts_with("Exactly one")
+        );
+        assert!(error(json!({"name": "Handle", "kinds": ["write"]}))
+            .starts_with("Unknown reference kind"));
+    }
+}
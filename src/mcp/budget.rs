@@ -0,0 +1,204 @@
+use serde_json::Value;
+use std::sync::{Arc, OnceLock, RwLock};
+use std::time::Duration;
+use tokio::task::JoinHandle;
+use tokio_util::sync::CancellationToken;
+
+/// Estimates how many model tokens a piece of response text costs
+pub trait TokenEstimator: Send + Sync {
+    fn estimate(&self, text: &str) -> usize;
+}
+
+/// One token per `chars_per_token` characters, rounded up. Four characters
+/// per token is close for English text and JSON with most tokenizers.
+#[derive(Debug, Clone, Copy)]
+pub struct CharsPerToken {
+    pub chars_per_token: usize,
+}
+
+impl Default for CharsPerToken {
+    fn default() -> Self {
+        Self { chars_per_token: 4 }
+    }
+}
+
+impl TokenEstimator for CharsPerToken {
+    fn estimate(&self, text: &str) -> usize {
+        text.chars().count().div_ceil(self.chars_per_token.max(1))
+    }
+}
+
+static TOKEN_ESTIMATOR: OnceLock<RwLock<Arc<dyn TokenEstimator>>> = OnceLock::new();
+
+fn estimator_slot() -> &'static RwLock<Arc<dyn TokenEstimator>> {
+    TOKEN_ESTIMATOR.get_or_init(|| RwLock::new(Arc::new(CharsPerToken::default())))
+}
+
+/// Replace the estimator behind `max_tokens` budgets, e.g. with a real
+/// tokenizer for the client's model
+pub fn set_token_estimator(estimator: Arc<dyn TokenEstimator>) {
+    *estimator_slot()
+        .write()
+        .unwrap_or_else(|poisoned| poisoned.into_inner()) = estimator;
+}
+
+/// Estimated tokens of a value as it is sent: pretty-printed JSON
+pub fn estimate_tokens(value: &Value) -> usize {
+    let text = serde_json::to_string_pretty(value).unwrap_or_default();
+    estimator_slot()
+        .read()
+        .unwrap_or_else(|poisoned| poisoned.into_inner())
+        .estimate(&text)
+}
+
+/// Drop entries from the ends of the result collections `lists` of a
+/// serialized response until it fits in `max_tokens`. The last list named is
+/// shortened first, so pass lists in order of importance. Arrays lose their
+/// last items; objects keyed by name lose their last keys. Adds `truncated`
+/// and `omitted` (entries dropped) to the response and returns `omitted`.
+pub fn fit_to_budget(response: &mut Value, lists: &[&str], max_tokens: usize) -> usize {
+    if !response.is_object() {
+        return 0;
+    }
+    // The flags count against the budget too
+    response["truncated"] = Value::Bool(false);
+    response["omitted"] = Value::from(0);
+    let mut total = estimate_tokens(response);
+    let mut omitted = 0;
+    for list in lists.iter().rev() {
+        let Some(entries) = response.get_mut(*list) else {
+            continue;
+        };
+        while total > max_tokens {
+            let removed = match entries {
+                Value::Array(items) => items.pop(),
+                Value::Object(map) => match map.keys().next_back().cloned() {
+                    Some(key) => map.remove(&key),
+                    None => None,
+                },
+                _ => None,
+            };
+            let Some(removed) = removed else {
+                break;
+            };
+            total = total.saturating_sub(estimate_tokens(&removed));
+            omitted += 1;
+        }
+    }
+    response["truncated"] = Value::Bool(omitted > 0);
+    response["omitted"] = Value::from(omitted);
+    omitted
+}
+
+/// A time budget for one query. Its token is cancelled when the client
+/// cancels or the time runs out, so a long step such as building the call
+/// graph stops as promptly as on cancellation; `expired` tells the two
+/// apart.
+pub struct TimeBudget {
+    token: CancellationToken,
+    client: CancellationToken,
+    timer: Option<JoinHandle<()>>,
+}
+
+impl TimeBudget {
+    /// A budget of `timeout_ms` milliseconds under the client's `cancel`;
+    /// without a timeout only the client can stop the query
+    pub fn new(cancel: &CancellationToken, timeout_ms: Option<u64>) -> Self {
+        let token = cancel.child_token();
+        let timer = timeout_ms.map(|timeout_ms| {
+            let token = token.clone();
+            tokio::spawn(async move {
+                tokio::time::sleep(Duration::from_millis(timeout_ms)).await;
+                token.cancel();
+            })
+        });
+        Self {
+            token,
+            client: cancel.clone(),
+            timer,
+        }
+    }
+
+    /// The token to pass to the steps of the query
+    pub fn token(&self) -> &CancellationToken {
+        &self.token
+    }
+
+    /// Whether the time ran out, as opposed to the client cancelling
+    pub fn expired(&self) -> bool {
+        self.token.is_cancelled() && !self.client.is_cancelled()
+    }
+}
+
+impl Drop for TimeBudget {
+    fn drop(&mut self) {
+        if let Some(timer) = &self.timer {
+            timer.abort();
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[tokio::test]
+    async fn test_time_budget() {
+        let cancel = CancellationToken::new();
+        let budget = TimeBudget::new(&cancel, Some(10));
+        assert!(!budget.expired());
+        tokio::time::sleep(Duration::from_millis(50)).await;
+        assert!(budget.token().is_cancelled());
+        assert!(budget.expired());
+
+        // Cancellation by the client is not the budget running out
+        let unbounded = TimeBudget::new(&cancel, None);
+        cancel.cancel();
+        assert!(unbounded.token().is_cancelled());
+        assert!(!unbounded.expired());
+    }
+
+    #[test]
+    fn test_chars_per_token() {
+        let estimator = CharsPerToken::default();
+        assert_eq!(estimator.estimate(""), 0);
+        assert_eq!(estimator.estimate("abcd"), 1);
+        assert_eq!(estimator.estimate("abcde"), 2);
+    }
+
+    #[test]
+    fn test_fit_to_budget() {
+        let symbols: Vec<Value> = (0..20)
+            .map(|i| json!({"name": format!("handler_{}", i), "file": "/src/handlers.go"}))
+            .collect();
+        let mut response = json!({ "symbols": symbols });
+        let budget = estimate_tokens(&response) / 2;
+
+        let omitted = fit_to_budget(&mut response, &["symbols"], budget);
+        assert!(omitted > 0 && omitted < 20);
+        assert!(estimate_tokens(&response) <= budget + 1);
+        assert_eq!(response["truncated"], json!(true));
+        assert_eq!(response["omitted"], json!(omitted));
+        assert_eq!(response["symbols"][0]["name"], "handler_0");
+
+        let mut small = json!({ "symbols": [{"name": "main"}] });
+        assert_eq!(fit_to_budget(&mut small, &["symbols"], 1000), 0);
+        assert_eq!(small["truncated"], json!(false));
+    }
+
+    #[test]
+    fn test_fit_to_budget_trims_last_list_first() {
+        let mut response = json!({
+            "callers": ["a", "b", "c"],
+            "callees": ["d", "e", "f"],
+            "files": {"a.go": 1, "b.go": 2},
+        });
+        fit_to_budget(&mut response, &[], usize::MAX);
+        let budget = estimate_tokens(&response) - 3;
+        fit_to_budget(&mut response, &["callers", "files", "callees"], budget);
+        assert_eq!(response["callers"], json!(["a", "b", "c"]));
+        assert_eq!(response["files"], json!({"a.go": 1, "b.go": 2}));
+        assert!(response["callees"].as_array().unwrap().len() < 3);
+    }
+}
@@ -0,0 +1,148 @@
+use serde::{Deserialize, Serialize};
+use std::path::PathBuf;
+
+/// Parts of a context pack, most important first
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ContextSection {
+    /// The package doc comment
+    Doc,
+    /// Signatures of exported functions, methods, constants and variables
+    Api,
+    /// Full definitions of exported types
+    Types,
+    /// Full bodies of the most-referenced functions
+    Bodies,
+}
+
+/// One item of a context pack
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ContextEntry {
+    pub section: ContextSection,
+    pub name: String,
+    pub symbol_type: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    /// Indexed references to the symbol, the rank within a section
+    pub references: usize,
+    /// Estimated tokens of `text`
+    pub tokens: usize,
+    pub text: String,
+}
+
+/// An entry left out of the pack, without its text
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct OmittedEntry {
+    pub section: ContextSection,
+    pub name: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub references: usize,
+    pub tokens: usize,
+}
+
+impl From<ContextEntry> for OmittedEntry {
+    fn from(entry: ContextEntry) -> Self {
+        Self {
+            section: entry.section,
+            name: entry.name,
+            file: entry.file,
+            line: entry.line,
+            references: entry.references,
+            tokens: entry.tokens,
+        }
+    }
+}
+
+/// Rank entries by section, then by reference count, and keep those that
+/// fit in `max_tokens` in that order. An entry too large for what is left
+/// is omitted, and smaller, less important ones may still fit after it.
+/// Returns the kept entries in rank order and the omitted ones.
+pub fn pack_entries(
+    mut entries: Vec<ContextEntry>,
+    max_tokens: usize,
+) -> (Vec<ContextEntry>, Vec<ContextEntry>) {
+    entries.sort_by(|a, b| {
+        a.section
+            .cmp(&b.section)
+            .then(b.references.cmp(&a.references))
+            .then(a.file.cmp(&b.file))
+            .then(a.line.cmp(&b.line))
+    });
+    let mut remaining = max_tokens;
+    let (mut included, mut omitted) = (Vec::new(), Vec::new());
+    for entry in entries {
+        if entry.tokens <= remaining {
+            remaining -= entry.tokens;
+            included.push(entry);
+        } else {
+            omitted.push(entry);
+        }
+    }
+    (included, omitted)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn entry(
+        section: ContextSection,
+        name: &str,
+        references: usize,
+        tokens: usize,
+    ) -> ContextEntry {
+        ContextEntry {
+            section,
+            name: name.to_string(),
+            symbol_type: "function".to_string(),
+            file: PathBuf::from("users/service.go"),
+            line: 1,
+            references,
+            tokens,
+            text: String::new(),
+        }
+    }
+
+    #[test]
+    fn test_pack_entries() {
+        let entries = vec![
+            entry(ContextSection::Bodies, "CreateUser", 9, 120),
+            entry(ContextSection::Api, "GetUser", 2, 20),
+            entry(ContextSection::Types, "User", 12, 50),
+            entry(ContextSection::Api, "CreateUser", 9, 20),
+            entry(ContextSection::Doc, "users", 0, 10),
+            entry(ContextSection::Types, "Cache", 1, 30),
+        ];
+        let (included, omitted) = pack_entries(entries, 100);
+        let names = |entries: &[ContextEntry]| {
+            entries
+                .iter()
+                .map(|e| (e.section, e.name.clone()))
+                .collect::<Vec<_>>()
+        };
+        assert_eq!(
+            names(&included),
+            vec![
+                (ContextSection::Doc, "users".to_string()),
+                (ContextSection::Api, "CreateUser".to_string()),
+                (ContextSection::Api, "GetUser".to_string()),
+                (ContextSection::Types, "User".to_string()),
+            ]
+        );
+        // Cache fits on its own but the budget is spent by then
+        assert_eq!(
+            names(&omitted),
+            vec![
+                (ContextSection::Types, "Cache".to_string()),
+                (ContextSection::Bodies, "CreateUser".to_string()),
+            ]
+        );
+
+        let (included, omitted) = pack_entries(vec![entry(ContextSection::Doc, "users", 0, 10)], 0);
+        assert!(included.is_empty());
+        assert_eq!(omitted.len(), 1);
+    }
+}
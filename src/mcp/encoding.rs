@@ -0,0 +1,210 @@
+use crate::mcp::kind_map::KindMap;
+use base64::Engine;
+use rmcp::model::{
+    CallToolResult, ClientInfo, Content, ErrorCode, ErrorData, ExperimentalCapabilities,
+    ResourceContents,
+};
+use serde::Serialize;
+use serde_json::{json, Map, Value};
+use std::future::Future;
+
+/// Experimental capability clients declare in `initialize` to choose how
+/// tool responses are encoded: `{"roberto/encoding": {"format": "msgpack"}}`
+pub const ENCODING_CAPABILITY: &str = "roberto/encoding";
+
+/// MIME type of MessagePack tool responses
+pub const MSGPACK_MIME_TYPE: &str = "application/msgpack";
+
+tokio::task_local! {
+    static ENCODING: ResponseEncoding;
+}
+
+/// How tool responses are serialized for a session
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum ResponseEncoding {
+    /// Pretty-printed JSON text content
+    #[default]
+    Json,
+    /// MessagePack with named fields, sent base64-encoded as an embedded
+    /// blob resource
+    MessagePack,
+}
+
+impl ResponseEncoding {
+    /// The encoding a client asked for during initialization; clients that
+    /// do not ask, or name an unknown format, get JSON
+    pub fn from_client(client: Option<&ClientInfo>) -> Self {
+        let format = client
+            .and_then(|client| client.capabilities.experimental.as_ref())
+            .and_then(|experimental| experimental.get(ENCODING_CAPABILITY))
+            .and_then(|capability| capability.get("format"))
+            .and_then(Value::as_str);
+        match format {
+            Some(format) => Self::parse(format).unwrap_or_else(|e| {
+                tracing::warn!("Ignoring requested response encoding: {}", e);
+                Self::Json
+            }),
+            None => Self::Json,
+        }
+    }
+
+    pub fn parse(format: &str) -> Result<Self, String> {
+        match format.to_ascii_lowercase().as_str() {
+            "json" => Ok(Self::Json),
+            "msgpack" | "messagepack" => Ok(Self::MessagePack),
+            other => Err(format!("unknown format '{}'", other)),
+        }
+    }
+
+    /// The capability advertised in the server's `initialize` result
+    pub fn experimental_capabilities() -> ExperimentalCapabilities {
+        let mut capability = Map::new();
+        capability.insert("formats".into(), json!(["json", "msgpack"]));
+        [(ENCODING_CAPABILITY.to_string(), capability)].into()
+    }
+
+    /// The encoding of the tool call being handled; JSON outside a call
+    pub fn current() -> Self {
+        ENCODING.try_with(|encoding| *encoding).unwrap_or_default()
+    }
+
+    /// Run a tool call with its responses serialized as `self`
+    pub async fn scope<F: Future>(self, call: F) -> F::Output {
+        ENCODING.scope(self, call).await
+    }
+
+    /// The serialized response, before transport framing
+    pub fn to_bytes<T: Serialize>(self, response: &T) -> Result<Vec<u8>, String> {
+        match self {
+            Self::Json => serde_json::to_vec_pretty(response).map_err(|e| e.to_string()),
+            Self::MessagePack => rmp_serde::to_vec_named(response).map_err(|e| e.to_string()),
+        }
+    }
+
+    /// Size of `response` as sent: MessagePack goes out base64-encoded, so
+    /// four bytes for every three of the encoding
+    pub fn wire_size<T: Serialize>(self, response: &T) -> Result<usize, String> {
+        let size = self.to_bytes(response)?.len();
+        Ok(match self {
+            Self::Json => size,
+            Self::MessagePack => size.div_ceil(3) * 4,
+        })
+    }
+
+    /// The tool result carrying `response`
+    pub fn to_result<T: Serialize>(self, response: &T) -> Result<CallToolResult, ErrorData> {
+        let serialization_error = |e: String| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Serialization error: {}", e),
+                None,
+            )
+        };
+        let content = match self {
+            Self::Json => Content::text(
+                serde_json::to_string_pretty(response)
+                    .map_err(|e| serialization_error(e.to_string()))?,
+            ),
+            Self::MessagePack => {
+                let bytes = self.to_bytes(response).map_err(serialization_error)?;
+                let resource: ResourceContents = serde_json::from_value(json!({
+                    "uri": "roberto://response",
+                    "mimeType": MSGPACK_MIME_TYPE,
+                    "blob": base64::engine::general_purpose::STANDARD.encode(bytes),
+                }))
+                .map_err(|e| serialization_error(e.to_string()))?;
+                Content::resource(resource)
+            }
+        };
+        Ok(CallToolResult::success(vec![content]))
+    }
+}
+
+/// Serialize a tool response in the encoding of the current session, with
+/// symbol kinds named by the session's kind map
+pub fn encode_response<T: Serialize>(response: &T) -> Result<CallToolResult, ErrorData> {
+    let encoding = ResponseEncoding::current();
+    let kinds = KindMap::current();
+    if kinds.is_internal() {
+        return encoding.to_result(response);
+    }
+    let mut value = serde_json::to_value(response).map_err(|e| {
+        ErrorData::new(
+            ErrorCode::INTERNAL_ERROR,
+            format!("Serialization error: {}", e),
+            None,
+        )
+    })?;
+    kinds.apply(&mut value);
+    encoding.to_result(&value)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde::Deserialize;
+
+    #[derive(Debug, Serialize, Deserialize, PartialEq)]
+    struct Response {
+        results: Vec<String>,
+        total_found: usize,
+    }
+
+    #[test]
+    fn test_parse_encoding() {
+        assert_eq!(ResponseEncoding::parse("json"), Ok(ResponseEncoding::Json));
+        assert_eq!(
+            ResponseEncoding::parse("MsgPack"),
+            Ok(ResponseEncoding::MessagePack)
+        );
+        assert!(ResponseEncoding::parse("cbor").is_err());
+    }
+
+    #[test]
+    fn test_encodings_agree() {
+        let response = Response {
+            results: vec!["UserService".into(), "NewUserService".into()],
+            total_found: 2,
+        };
+        let json = ResponseEncoding::Json.to_bytes(&response).unwrap();
+        let msgpack = ResponseEncoding::MessagePack.to_bytes(&response).unwrap();
+        assert!(msgpack.len() < json.len());
+        assert_eq!(
+            serde_json::from_slice::<Response>(&json).unwrap(),
+            rmp_serde::from_slice::<Response>(&msgpack).unwrap()
+        );
+    }
+
+    #[test]
+    fn test_msgpack_result_round_trip() {
+        let response = json!({
+            "symbols": [{"name": "UserService", "line": 12, "tags": {"vendor": "true"}}],
+            "total_found": 1,
+            "truncated": false,
+        });
+        let result = ResponseEncoding::MessagePack.to_result(&response).unwrap();
+        let result = serde_json::to_value(&result).unwrap();
+        let resource = &result["content"][0]["resource"];
+        assert_eq!(resource["uri"], "roberto://response");
+        assert_eq!(resource["mimeType"], MSGPACK_MIME_TYPE);
+
+        let blob = resource["blob"].as_str().unwrap();
+        let bytes = base64::engine::general_purpose::STANDARD
+            .decode(blob)
+            .unwrap();
+        assert_eq!(rmp_serde::from_slice::<Value>(&bytes).unwrap(), response);
+        assert_eq!(
+            ResponseEncoding::MessagePack.wire_size(&response).unwrap(),
+            blob.len()
+        );
+    }
+
+    #[tokio::test]
+    async fn test_current_encoding() {
+        assert_eq!(ResponseEncoding::current(), ResponseEncoding::Json);
+        let inside = ResponseEncoding::MessagePack
+            .scope(async { ResponseEncoding::current() })
+            .await;
+        assert_eq!(inside, ResponseEncoding::MessagePack);
+    }
+}
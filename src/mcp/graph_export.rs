@@ -0,0 +1,163 @@
+use crate::models::{Symbol, SymbolType};
+
+/// Text formats `export_graph` renders to
+pub const GRAPH_FORMATS: &[&str] = &["dot", "mermaid"];
+
+/// A symbol in an exported graph
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GraphNode {
+    /// Symbol id; node names are derived from it so they are stable
+    /// across exports and rebuilds
+    pub id: u64,
+    pub label: String,
+    /// Functions and methods are drawn as boxes, types as ellipses
+    pub callable: bool,
+}
+
+impl GraphNode {
+    pub fn from_symbol(symbol: &Symbol) -> Self {
+        Self {
+            id: symbol.id.0,
+            label: symbol.name.clone(),
+            callable: matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ),
+        }
+    }
+
+    fn name(&self) -> String {
+        format!("n{}", self.id)
+    }
+}
+
+/// A relationship between two nodes, labeled with the edge followed
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct GraphEdge {
+    pub from: u64,
+    pub to: u64,
+    pub label: String,
+}
+
+/// Render a graph as Graphviz DOT, ready for `dot -Tsvg`
+pub fn render_dot(title: &str, nodes: &[GraphNode], edges: &[GraphEdge]) -> String {
+    let mut out = format!("digraph \"{}\" {{\n", escape_dot(title));
+    out.push_str("  rankdir=LR;\n");
+    for node in nodes {
+        let shape = if node.callable { "box" } else { "ellipse" };
+        out.push_str(&format!(
+            "  {} [label=\"{}\", shape={}];\n",
+            node.name(),
+            escape_dot(&node.label),
+            shape
+        ));
+    }
+    for edge in edges {
+        out.push_str(&format!(
+            "  n{} -> n{} [label=\"{}\"];\n",
+            edge.from,
+            edge.to,
+            escape_dot(&edge.label)
+        ));
+    }
+    out.push_str("}\n");
+    out
+}
+
+/// Render a graph as a Mermaid flowchart
+pub fn render_mermaid(nodes: &[GraphNode], edges: &[GraphEdge]) -> String {
+    let mut out = String::from("flowchart LR\n");
+    for node in nodes {
+        let label = escape_mermaid(&node.label);
+        if node.callable {
+            out.push_str(&format!("  {}[\"{}\"]\n", node.name(), label));
+        } else {
+            out.push_str(&format!("  {}([\"{}\"])\n", node.name(), label));
+        }
+    }
+    for edge in edges {
+        out.push_str(&format!(
+            "  n{} -->|\"{}\"| n{}\n",
+            edge.from,
+            escape_mermaid(&edge.label),
+            edge.to
+        ));
+    }
+    out
+}
+
+/// Escape text for a double-quoted DOT string
+fn escape_dot(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '"' => escaped.push_str("\\\""),
+            '\\' => escaped.push_str("\\\\"),
+            '\n' => escaped.push_str("\\n"),
+            '\r' => {}
+            c => escaped.push(c),
+        }
+    }
+    escaped
+}
+
+/// Escape text for a quoted Mermaid label, where quotes and markup
+/// characters are written as entity codes
+fn escape_mermaid(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        match c {
+            '"' => escaped.push_str("#quot;"),
+            '<' => escaped.push_str("#lt;"),
+            '>' => escaped.push_str("#gt;"),
+            '#' => escaped.push_str("#35;"),
+            '\n' | '\r' => escaped.push(' '),
+            c => escaped.push(c),
+        }
+    }
+    escaped
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn graph() -> (Vec<GraphNode>, Vec<GraphEdge>) {
+        let nodes = vec![
+            GraphNode {
+                id: 7,
+                label: "CreateUser".to_string(),
+                callable: true,
+            },
+            GraphNode {
+                id: 9,
+                label: "Map<\"k\", V>".to_string(),
+                callable: false,
+            },
+        ];
+        let edges = vec![GraphEdge {
+            from: 7,
+            to: 9,
+            label: "uses_type".to_string(),
+        }];
+        (nodes, edges)
+    }
+
+    #[test]
+    fn test_render_dot() {
+        let (nodes, edges) = graph();
+        assert_eq!(
+            render_dot("CreateUser calls", &nodes, &edges),
+            "digraph \"CreateUser calls\" {\n  rankdir=LR;\n  n7 [label=\"CreateUser\", shape=box];\n  n9 [label=\"Map<\\\"k\\\", V>\", shape=ellipse];\n  n7 -> n9 [label=\"uses_type\"];\n}\n"
+        );
+    }
+
+    #[test]
+    fn test_render_mermaid() {
+        let (nodes, edges) = graph();
+        assert_eq!(
+            render_mermaid(&nodes, &edges),
+            "flowchart LR\n  n7[\"CreateUser\"]\n  n9([\"Map#lt;#quot;k#quot;, V#gt;\"])\n  n7 -->|\"uses_type\"| n9\n"
+        );
+    }
+}
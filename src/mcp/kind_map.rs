@@ -0,0 +1,263 @@
+use crate::indexing::settings::setting_var;
+use crate::models::SymbolType;
+use crate::mcp::lsp;
+use rmcp::model::{ClientInfo, ExperimentalCapabilities};
+use serde_json::{json, Map, Value};
+use std::collections::HashMap;
+use std::future::Future;
+use std::sync::{Arc, OnceLock};
+
+/// Experimental capability clients declare in `initialize` to choose the
+/// vocabulary of `symbol_type` fields: `{"roberto/kinds": {"scheme": "lsp"}}`
+/// or `{"roberto/kinds": {"map": {"function": "func", ...}}}`
+pub const KINDS_CAPABILITY: &str = "roberto/kinds";
+
+tokio::task_local! {
+    static KIND_MAP: Arc<KindMap>;
+}
+
+static SERVER_KIND_MAP: OnceLock<Arc<KindMap>> = OnceLock::new();
+
+/// How symbol kinds are named in tool responses. The mapping is applied
+/// when a response is serialized; the index and tool filters keep the
+/// internal kinds.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub enum KindMap {
+    /// The internal kind names: `Function`, `Method`, `Struct`
+    #[default]
+    Internal,
+    /// LSP `SymbolKind` numbers, as used by `get_document_symbols`
+    Lsp,
+    /// A client-chosen name or number for every kind, keyed by the
+    /// lowercase internal name
+    Custom(HashMap<&'static str, Value>),
+}
+
+impl KindMap {
+    /// The mapping from ROBERTO_KIND_MAP, read on first use: `internal`,
+    /// `lsp`, or a complete `kind=name,kind=name` list. An invalid value is
+    /// logged and the internal names are used.
+    pub fn server_default() -> Arc<Self> {
+        SERVER_KIND_MAP
+            .get_or_init(|| {
+                let map = match setting_var("ROBERTO_KIND_MAP") {
+                    Ok(spec) => Self::parse(&spec).unwrap_or_else(|e| {
+                        tracing::warn!("Ignoring ROBERTO_KIND_MAP: {}", e);
+                        Self::Internal
+                    }),
+                    Err(_) => Self::Internal,
+                };
+                Arc::new(map)
+            })
+            .clone()
+    }
+
+    /// The mapping a client asked for during initialization; clients that
+    /// do not ask, or send an invalid mapping, get the server default
+    pub fn from_client(client: Option<&ClientInfo>) -> Arc<Self> {
+        let capability = client
+            .and_then(|client| client.capabilities.experimental.as_ref())
+            .and_then(|experimental| experimental.get(KINDS_CAPABILITY));
+        let Some(capability) = capability else {
+            return Self::server_default();
+        };
+        let requested = match (capability.get("scheme"), capability.get("map")) {
+            (_, Some(Value::Object(map))) => {
+                Self::from_entries(map.iter().map(|(kind, name)| (kind.as_str(), name.clone())))
+            }
+            (_, Some(_)) => Err("map must be an object".to_string()),
+            (Some(Value::String(scheme)), None) => Self::scheme(scheme),
+            (Some(_), None) => Err("scheme must be a string".to_string()),
+            (None, None) => return Self::server_default(),
+        };
+        match requested {
+            Ok(map) => Arc::new(map),
+            Err(e) => {
+                tracing::warn!("Ignoring requested symbol kind mapping: {}", e);
+                Self::server_default()
+            }
+        }
+    }
+
+    /// A named scheme, or a `kind=name,kind=name` list covering every kind
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let spec = spec.trim();
+        if !spec.contains('=') {
+            return Self::scheme(spec);
+        }
+        let entries = spec
+            .split(',')
+            .map(str::trim)
+            .filter(|entry| !entry.is_empty())
+            .map(|entry| match entry.split_once('=') {
+                Some((kind, name)) => Ok((kind, Value::String(name.trim().to_string()))),
+                None => Err(format!("expected kind=name, got '{}'", entry)),
+            })
+            .collect::<Result<Vec<_>, String>>()?;
+        Self::from_entries(entries)
+    }
+
+    fn scheme(name: &str) -> Result<Self, String> {
+        match name.trim().to_lowercase().as_str() {
+            "" | "internal" => Ok(Self::Internal),
+            "lsp" => Ok(Self::Lsp),
+            other => Err(format!(
+                "unknown scheme '{}': expected internal, lsp or a kind map",
+                other
+            )),
+        }
+    }
+
+    /// A custom mapping; every kind must be named exactly once, by a
+    /// non-empty string or a number
+    fn from_entries<'a>(
+        entries: impl IntoIterator<Item = (&'a str, Value)>,
+    ) -> Result<Self, String> {
+        let mut names = HashMap::new();
+        for (kind, name) in entries {
+            let symbol_type =
+                SymbolType::from_name(kind).ok_or_else(|| format!("unknown kind '{}'", kind))?;
+            let valid = match &name {
+                Value::String(name) => !name.trim().is_empty(),
+                Value::Number(_) => true,
+                _ => false,
+            };
+            if !valid {
+                return Err(format!(
+                    "kind '{}' must map to a non-empty string or a number",
+                    kind
+                ));
+            }
+            if names.insert(symbol_type.as_str(), name).is_some() {
+                return Err(format!(
+                    "kind '{}' is mapped more than once",
+                    symbol_type.as_str()
+                ));
+            }
+        }
+        let missing: Vec<&str> = SymbolType::ALL
+            .iter()
+            .map(SymbolType::as_str)
+            .filter(|kind| !names.contains_key(kind))
+            .collect();
+        if !missing.is_empty() {
+            return Err(format!("no mapping for {}", missing.join(", ")));
+        }
+        Ok(Self::Custom(names))
+    }
+
+    /// The capability advertised in the server's `initialize` result
+    pub fn experimental_capabilities() -> ExperimentalCapabilities {
+        let mut capability = Map::new();
+        capability.insert("schemes".into(), json!(["internal", "lsp"]));
+        capability.insert(
+            "kinds".into(),
+            json!(SymbolType::ALL
+                .iter()
+                .map(SymbolType::as_str)
+                .collect::<Vec<_>>()),
+        );
+        [(KINDS_CAPABILITY.to_string(), capability)].into()
+    }
+
+    /// The mapping of the tool call being handled; the server default
+    /// outside a call
+    pub fn current() -> Arc<Self> {
+        KIND_MAP
+            .try_with(Arc::clone)
+            .unwrap_or_else(|_| Self::server_default())
+    }
+
+    /// Run a tool call with its responses naming kinds through `self`
+    pub async fn scope<F: Future>(self: Arc<Self>, call: F) -> F::Output {
+        KIND_MAP.scope(self, call).await
+    }
+
+    pub fn is_internal(&self) -> bool {
+        *self == Self::Internal
+    }
+
+    /// The output form of one kind
+    pub fn name(&self, symbol_type: &SymbolType) -> Value {
+        match self {
+            Self::Internal => serde_json::to_value(symbol_type).unwrap_or(Value::Null),
+            Self::Lsp => Value::from(lsp::symbol_kind(symbol_type)),
+            Self::Custom(names) => names
+                .get(symbol_type.as_str())
+                .cloned()
+                .unwrap_or(Value::Null),
+        }
+    }
+
+    /// Rename the kinds in every `symbol_type` field of a serialized
+    /// response, at any depth
+    pub fn apply(&self, value: &mut Value) {
+        match value {
+            Value::Object(fields) => {
+                for (key, field) in fields.iter_mut() {
+                    let kind = match (key.as_str(), &*field) {
+                        ("symbol_type", Value::String(kind)) => SymbolType::from_name(kind),
+                        _ => None,
+                    };
+                    match kind {
+                        Some(kind) => *field = self.name(&kind),
+                        None => self.apply(field),
+                    }
+                }
+            }
+            Value::Array(items) => items.iter_mut().for_each(|item| self.apply(item)),
+            _ => {}
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const COMPLETE: &str = "module=namespace,class=class,interface=interface,method=func,\
+        function=func,constant=const,variable=var,enum=enum,struct=struct,import=import,\
+        test=func,route=route";
+
+    #[test]
+    fn test_parse_kind_map() {
+        assert_eq!(KindMap::parse("internal"), Ok(KindMap::Internal));
+        assert_eq!(KindMap::parse("LSP"), Ok(KindMap::Lsp));
+        assert!(KindMap::parse("ctags").is_err());
+
+        let custom = KindMap::parse(COMPLETE).unwrap();
+        assert_eq!(custom.name(&SymbolType::Method), json!("func"));
+        assert_eq!(custom.name(&SymbolType::Module), json!("namespace"));
+
+        let incomplete = KindMap::parse("function=func,method=func").unwrap_err();
+        assert!(incomplete.contains("no mapping for module, class"));
+        assert!(KindMap::parse(&format!("{},const=constant", COMPLETE))
+            .unwrap_err()
+            .contains("more than once"));
+        assert!(KindMap::parse(&format!("{},widget=w", COMPLETE)).is_err());
+    }
+
+    #[test]
+    fn test_apply_kind_map() {
+        let mut response = json!({
+            "results": [
+                {"name": "GetUser", "symbol_type": "Method", "callers": [
+                    {"name": "main", "symbol_type": "Function"}
+                ]},
+                {"name": "symbol_type", "symbol_type": "Struct"}
+            ],
+            "symbol_type": "not a kind"
+        });
+        KindMap::Lsp.apply(&mut response);
+        assert_eq!(response["results"][0]["symbol_type"], json!(6));
+        assert_eq!(
+            response["results"][0]["callers"][0]["symbol_type"],
+            json!(12)
+        );
+        assert_eq!(response["results"][1]["symbol_type"], json!(23));
+        assert_eq!(response["results"][1]["name"], json!("symbol_type"));
+        assert_eq!(response["symbol_type"], json!("not a kind"));
+
+        assert_eq!(KindMap::Internal.name(&SymbolType::Route), json!("Route"));
+    }
+}
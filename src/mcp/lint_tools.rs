@@ -0,0 +1,1020 @@
+use crate::indexing::context_propagation::{find_fresh_context_calls, FreshContextCall};
+use crate::indexing::goroutine_leaks::{
+    find_goroutine_launches, function_loop_profile, GoroutineLaunch, LeakConfidence,
+};
+use crate::indexing::naming_rules::{
+    default_go_rules, is_go_exported, NameScope, NamingRule, OPTIONAL_RULES,
+};
+use crate::indexing::receiver_mutation::{find_receiver_mutations, ReceiverMutation};
+use crate::indexing::shadowing::{find_shadowing, Shadowing};
+use crate::indexing::signature_compat::return_values;
+use crate::indexing::type_members::base_type_name;
+use crate::indexing::unchecked_errors::{
+    find_discarded_calls, is_allowed, Discard, DiscardedCall, DEFAULT_ERROR_ALLOWLIST,
+};
+use crate::mcp::encoding::encode_response;
+use crate::mcp::tools::{cancelled_error, get_symbol_store, indexed_files};
+use crate::models::{Language, Symbol, SymbolId, SymbolType};
+use crate::utils::error::CodeAnalysisError;
+use crate::SymbolStore;
+use rmcp::model::{CallToolResult, ErrorCode, ErrorData};
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use tokio_util::sync::CancellationToken;
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintReceiverMutationRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Also report pointer-receiver assignments, which do persist (default: false)
+    pub include_pointer_receivers: Option<bool>,
+    /// Maximum number of findings to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ReceiverMutationFinding {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub mutation: ReceiverMutation,
+    pub persists: bool,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintReceiverMutationResponse {
+    pub findings: Vec<ReceiverMutationFinding>,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+/// Standard library methods outside the index whose only result is an error
+const KNOWN_ERROR_METHODS: &[&str] = &[
+    "Close", "Commit", "Rollback", "Flush", "Sync", "Shutdown", "Chmod", "Truncate",
+];
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintUncheckedErrorsRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Extra callees whose errors may be ignored, e.g. `Close` or `log.*`
+    pub allow: Option<Vec<String>>,
+    /// Apply the built-in allowlist (fmt.Print*, Write* on builders) (default: true)
+    pub use_default_allowlist: Option<bool>,
+    /// Maximum number of findings to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct UncheckedErrorFinding {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub call: DiscardedCall,
+    /// The indexed function or method called, when resolved to a single one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub callee_id: Option<u64>,
+    /// The callee's result types
+    pub returns: Vec<String>,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintUncheckedErrorsResponse {
+    pub findings: Vec<UncheckedErrorFinding>,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct NamingRuleSpec {
+    /// Rule name reported with each violation
+    pub name: String,
+    /// Symbol kinds the rule checks, e.g. `function` or `interface`
+    pub kinds: Vec<String>,
+    /// Regex the names are matched against
+    pub pattern: String,
+    /// Symbols checked: all, exported, unexported or constructor (default: all)
+    pub scope: Option<String>,
+    /// Names must match the pattern; set to false for names that must not
+    /// (default: true)
+    pub must_match: Option<bool>,
+    /// Explanation reported with each violation
+    pub message: Option<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintNamingRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Extra rules checked along with the built-in ones
+    pub rules: Option<Vec<NamingRuleSpec>>,
+    /// Optional built-in rules to turn on, e.g. `interface-er-suffix`
+    pub enable: Option<Vec<String>>,
+    /// Built-in rules to turn off
+    pub disable: Option<Vec<String>>,
+    /// Check the built-in Go rules (default: true)
+    pub use_default_rules: Option<bool>,
+    /// Maximum number of violations to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct NamingViolation {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+    pub rule: String,
+    pub pattern: String,
+    pub must_match: bool,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintNamingResponse {
+    pub violations: Vec<NamingViolation>,
+    /// Names of the rules that were checked
+    pub rules: Vec<String>,
+    pub symbols_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintShadowingRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Error variables (err, parseErr): include, skip or only (default: include)
+    pub errors: Option<String>,
+    /// Maximum number of findings to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ShadowingFinding {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub shadowing: Shadowing,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintShadowingResponse {
+    pub findings: Vec<ShadowingFinding>,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintContextPropagationRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Callees or enclosing functions allowed to use a fresh context, e.g.
+    /// `s.audit.Record`, `*.Shutdown` or `Warm*`
+    pub allow: Option<Vec<String>>,
+    /// Leave out calls made in goroutines the function starts (default: false)
+    pub skip_goroutines: Option<bool>,
+    /// Maximum number of findings to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ContextPropagationFinding {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub call: FreshContextCall,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintContextPropagationResponse {
+    pub findings: Vec<ContextPropagationFinding>,
+    pub files_checked: usize,
+    pub total_found: usize,
+}
+
+/// Methods whose presence on a goroutine's type suggests it can be stopped
+const STOP_METHODS: &[&str] = &["Stop", "Close", "Shutdown", "Cancel"];
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct LintGoroutineLeaksRequest {
+    /// Optional file or directory to restrict the check to (default: whole index)
+    pub path: Option<String>,
+    /// Goroutine targets or launching functions known to be long-lived, e.g.
+    /// `c.cleanup`, `*.run` or `main`
+    pub allow: Option<Vec<String>>,
+    /// Least confidence to report: low, medium or high (default: low)
+    pub min_confidence: Option<String>,
+    /// Maximum number of findings to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+/// The function a goroutine runs, when resolved in the index
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LeakTarget {
+    pub id: u64,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GoroutineLeakFinding {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    #[serde(flatten)]
+    pub launch: GoroutineLaunch,
+    /// Absent for func literals
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub target_function: Option<LeakTarget>,
+    /// Line of the loop that never exits
+    pub loop_line: u32,
+    /// Channels and timers the loop blocks on
+    pub waits_on: Vec<String>,
+    pub confidence: LeakConfidence,
+    /// A method of the target's type that may stop it, though the loop
+    /// shows no sign of it
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub stop_method: Option<String>,
+    pub message: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct LintGoroutineLeaksResponse {
+    pub findings: Vec<GoroutineLeakFinding>,
+    pub files_checked: usize,
+    /// Launches left out by `allow` or a `nolint:goroutineleak` comment
+    pub suppressed: usize,
+    pub total_found: usize,
+}
+
+/// Review checks over indexed source that flag likely bugs
+pub struct LintTools;
+
+impl LintTools {
+    pub async fn lint_receiver_mutation(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintReceiverMutationRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let include_pointer = params.include_pointer_receivers.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+
+        let mut findings = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_receiver_mutation".to_string(),
+                }));
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let mutations = match find_receiver_mutations(&content) {
+                Ok(mutations) => mutations,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for mutation in mutations {
+                if mutation.persists() && !include_pointer {
+                    continue;
+                }
+                let message = if mutation.persists() {
+                    format!(
+                        "{}.{} is assigned through pointer receiver *{}; the change is visible to the caller",
+                        mutation.receiver, mutation.field, mutation.receiver_type
+                    )
+                } else {
+                    format!(
+                        "{}.{} is assigned in {} but the value receiver is a copy, so the change is lost on return; use a pointer receiver (*{})",
+                        mutation.receiver, mutation.field, mutation.method, mutation.receiver_type
+                    )
+                };
+                findings.push(ReceiverMutationFinding {
+                    file: file.clone(),
+                    persists: mutation.persists(),
+                    message,
+                    mutation,
+                });
+            }
+        }
+
+        let total_found = findings.len();
+        findings.truncate(limit);
+
+        let response = LintReceiverMutationResponse {
+            findings,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn lint_unchecked_errors(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintUncheckedErrorsRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let mut allowlist: Vec<String> = if params.use_default_allowlist.unwrap_or(true) {
+            DEFAULT_ERROR_ALLOWLIST
+                .iter()
+                .map(|s| s.to_string())
+                .collect()
+        } else {
+            Vec::new()
+        };
+        allowlist.extend(params.allow.unwrap_or_default());
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut findings = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_unchecked_errors".to_string(),
+                }));
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let calls = match find_discarded_calls(&content) {
+                Ok(calls) => calls,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for call in calls {
+                if is_allowed(&call.callee, &allowlist) {
+                    continue;
+                }
+                let Some((returns, callee_id)) = Self::callee_results(&store, file, &call) else {
+                    continue;
+                };
+                let discards_error = match call.discard {
+                    Discard::Blank => {
+                        call.result_count == Some(returns.len())
+                            && call
+                                .blank_results
+                                .iter()
+                                .any(|index| returns.get(*index).is_some_and(|r| r == "error"))
+                    }
+                    _ => returns.iter().any(|r| r == "error"),
+                };
+                if !discards_error {
+                    continue;
+                }
+
+                let message = match call.discard {
+                    Discard::Ignored => format!("error returned by {} is not checked", call.callee),
+                    Discard::Deferred => format!(
+                        "error returned by deferred {} is discarded; check it in a deferred func",
+                        call.callee
+                    ),
+                    Discard::Goroutine => format!(
+                        "error returned by {} is lost when it runs as a goroutine",
+                        call.callee
+                    ),
+                    Discard::Blank => {
+                        format!("error returned by {} is assigned to _", call.callee)
+                    }
+                };
+                findings.push(UncheckedErrorFinding {
+                    file: file.clone(),
+                    callee_id: callee_id.map(|id| id.0),
+                    returns,
+                    message,
+                    call,
+                });
+            }
+        }
+
+        let total_found = findings.len();
+        findings.truncate(limit);
+
+        let response = LintUncheckedErrorsResponse {
+            findings,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn lint_naming(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintNamingRequest = Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let rules = Self::naming_rules(&params)?;
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut violations = Vec::new();
+        let mut symbols_checked = 0;
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_naming".to_string(),
+                }));
+            }
+
+            for symbol in store.get_symbols_by_file(file) {
+                symbols_checked += 1;
+                let exported = is_go_exported(&symbol.name);
+                let constructor = Self::is_go_constructor(&store, &symbol);
+                for rule in &rules {
+                    if !rule.applies_to(&symbol.symbol_type, exported, constructor)
+                        || !rule.is_violated_by(&symbol.name)
+                    {
+                        continue;
+                    }
+                    violations.push(NamingViolation {
+                        id: symbol.id.0,
+                        name: symbol.name.clone(),
+                        symbol_type: symbol.symbol_type.clone(),
+                        file: file.clone(),
+                        line: symbol.location.start_line,
+                        rule: rule.name.clone(),
+                        pattern: rule.pattern().to_string(),
+                        must_match: rule.must_match,
+                        message: rule.message.clone(),
+                    });
+                }
+            }
+        }
+        violations.sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+
+        let total_found = violations.len();
+        violations.truncate(limit);
+
+        let response = LintNamingResponse {
+            violations,
+            rules: rules.into_iter().map(|rule| rule.name).collect(),
+            symbols_checked,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn lint_shadowing(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintShadowingRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let errors = params.errors.as_deref().unwrap_or("include");
+        if !matches!(errors, "include" | "skip" | "only") {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown errors mode '{}'; expected include, skip or only",
+                    errors
+                ),
+                None,
+            ));
+        }
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+
+        let mut findings = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_shadowing".to_string(),
+                }));
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            let shadowings = match find_shadowing(&content) {
+                Ok(shadowings) => shadowings,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for shadowing in shadowings {
+                let wanted = match errors {
+                    "skip" => !shadowing.error_variable,
+                    "only" => shadowing.error_variable,
+                    _ => true,
+                };
+                if !wanted {
+                    continue;
+                }
+                let mut message = format!(
+                    "{} declared at line {} in {} shadows {} declared at line {} in the enclosing {} scope",
+                    shadowing.name,
+                    shadowing.shadowing.line,
+                    shadowing.function,
+                    shadowing.name,
+                    shadowing.shadowed.line,
+                    shadowing.shadowed.scope
+                );
+                if shadowing.read_by_defer {
+                    message.push_str(&format!(
+                        "; a deferred func reads {} and will not see this one",
+                        shadowing.name
+                    ));
+                }
+                findings.push(ShadowingFinding {
+                    file: file.clone(),
+                    shadowing,
+                    message,
+                });
+            }
+        }
+
+        let total_found = findings.len();
+        findings.truncate(limit);
+
+        let response = LintShadowingResponse {
+            findings,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn lint_context_propagation(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintContextPropagationRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let allow = params.allow.unwrap_or_default();
+        let skip_goroutines = params.skip_goroutines.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+
+        let mut findings = Vec::new();
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_context_propagation".to_string(),
+                }));
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            if !content.contains("context.") {
+                continue;
+            }
+            let calls = match find_fresh_context_calls(&content) {
+                Ok(calls) => calls,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for call in calls {
+                if (skip_goroutines && call.in_goroutine)
+                    || is_allowed(&call.callee, &allow)
+                    || is_allowed(&call.function, &allow)
+                {
+                    continue;
+                }
+                let message = format!(
+                    "{} passes {} to {} instead of its {} parameter; cancellation and deadlines of the caller are lost",
+                    call.function, call.origin, call.callee, call.context_param
+                );
+                findings.push(ContextPropagationFinding {
+                    file: file.clone(),
+                    call,
+                    message,
+                });
+            }
+        }
+
+        let total_found = findings.len();
+        findings.truncate(limit);
+
+        let response = LintContextPropagationResponse {
+            findings,
+            files_checked: files.len(),
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    pub async fn lint_goroutine_leaks(
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: LintGoroutineLeaksRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let allow = params.allow.unwrap_or_default();
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
+        let min_confidence = match params.min_confidence.as_deref() {
+            None | Some("low") => LeakConfidence::Low,
+            Some("medium") => LeakConfidence::Medium,
+            Some("high") => LeakConfidence::High,
+            Some(other) => {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!(
+                        "Invalid min_confidence '{}': expected low, medium or high",
+                        other
+                    ),
+                    None,
+                ))
+            }
+        };
+
+        let files = indexed_files(params.path.as_deref(), Language::Go)?;
+        let store = get_symbol_store();
+
+        let mut sources: HashMap<PathBuf, String> = HashMap::new();
+        let mut findings = Vec::new();
+        let mut suppressed = 0;
+        for file in &files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "lint_goroutine_leaks".to_string(),
+                }));
+            }
+
+            let Ok(content) = tokio::fs::read_to_string(file).await else {
+                continue;
+            };
+            if !content.contains("go ") {
+                continue;
+            }
+            let launches = match find_goroutine_launches(&content) {
+                Ok(launches) => launches,
+                Err(e) => {
+                    tracing::warn!("Failed to analyze {}: {}", file.display(), e);
+                    continue;
+                }
+            };
+
+            for mut launch in launches {
+                if launch.suppressed
+                    || is_allowed(&launch.target, &allow)
+                    || launch
+                        .function
+                        .as_deref()
+                        .is_some_and(|function| is_allowed(function, &allow))
+                {
+                    suppressed += 1;
+                    continue;
+                }
+
+                let target = match launch.target.as_str() {
+                    "func literal" => None,
+                    name => match Self::goroutine_target(&store, file, name) {
+                        Some(target) => Some(target),
+                        None => continue,
+                    },
+                };
+                let profile = match &target {
+                    None => launch.literal_loop.take(),
+                    Some(target) => {
+                        let path = &target.location.file;
+                        if !sources.contains_key(path) {
+                            let Ok(source) = tokio::fs::read_to_string(path).await else {
+                                continue;
+                            };
+                            sources.insert(path.clone(), source);
+                        }
+                        function_loop_profile(&sources[path], target.location.start_line)
+                            .ok()
+                            .flatten()
+                    }
+                };
+                let Some(profile) = profile else { continue };
+                let Some(mut confidence) = profile.leak_confidence() else {
+                    continue;
+                };
+                let stop_method = target
+                    .as_ref()
+                    .and_then(|target| Self::stop_method(&store, target));
+                if stop_method.is_some() {
+                    confidence = confidence.lowered();
+                }
+                if confidence < min_confidence {
+                    continue;
+                }
+
+                let mut message = format!(
+                    "goroutine {} loops forever on {} with no context, done channel or return to stop it",
+                    launch.target,
+                    profile.waits_on.join(", ")
+                );
+                if let Some(stop_method) = &stop_method {
+                    message.push_str(&format!(
+                        "; {} may stop it, but the loop never checks for it",
+                        stop_method
+                    ));
+                }
+                findings.push(GoroutineLeakFinding {
+                    file: file.clone(),
+                    launch,
+                    target_function: target.map(|target| LeakTarget {
+                        id: target.id.0,
+                        file: target.location.file,
+                        line: target.location.start_line,
+                    }),
+                    loop_line: profile.line,
+                    waits_on: profile.waits_on,
+                    confidence,
+                    stop_method,
+                    message,
+                });
+            }
+        }
+
+        let total_found = findings.len();
+        findings.truncate(limit);
+
+        let response = LintGoroutineLeaksResponse {
+            findings,
+            files_checked: files.len(),
+            suppressed,
+            total_found,
+        };
+        Self::to_result(&response)
+    }
+
+    /// The Go function a `go` statement in `file` starts, resolved by name:
+    /// a bare call against functions of the file's directory, `pkg.Func`
+    /// against the package named `pkg`, other qualified calls against
+    /// methods of that name in the directory, else anywhere. `None` unless
+    /// exactly one candidate is found.
+    fn goroutine_target(store: &SymbolStore, file: &Path, target: &str) -> Option<Symbol> {
+        let (qualifier, name) = match target.rsplit_once('.') {
+            Some((qualifier, name)) => (Some(qualifier), name),
+            None => (None, target),
+        };
+        let candidates: Vec<Symbol> = store
+            .get_symbols(name)
+            .into_iter()
+            .filter(|symbol| Language::from_path(&symbol.location.file) == Some(Language::Go))
+            .collect();
+        let in_directory = |symbol: &&Symbol| symbol.location.file.parent() == file.parent();
+        let functions = candidates
+            .iter()
+            .filter(|symbol| symbol.symbol_type == SymbolType::Function);
+        let methods = candidates
+            .iter()
+            .filter(|symbol| symbol.symbol_type == SymbolType::Method);
+
+        let tiers: Vec<Vec<&Symbol>> = match qualifier {
+            None => vec![functions.filter(in_directory).collect()],
+            Some(qualifier) => vec![
+                functions
+                    .filter(|symbol| {
+                        symbol
+                            .namespace
+                            .as_deref()
+                            .and_then(|namespace| namespace.rsplit(['/', '.']).next())
+                            == Some(qualifier)
+                    })
+                    .collect(),
+                methods.clone().filter(in_directory).collect(),
+                methods.collect(),
+            ],
+        };
+        let tier = tiers.into_iter().find(|tier| !tier.is_empty())?;
+        match tier.as_slice() {
+            [only] => Some((*only).clone()),
+            _ => None,
+        }
+    }
+
+    /// A `Stop`, `Close`, `Shutdown` or `Cancel` method declared on the
+    /// receiver type of a Go method, in its package
+    fn stop_method(store: &SymbolStore, method: &Symbol) -> Option<String> {
+        let receiver = method.signature.as_ref()?.receiver.as_deref()?;
+        let owner = base_type_name(receiver);
+        STOP_METHODS.iter().find_map(|name| {
+            store
+                .get_symbols(name)
+                .into_iter()
+                .find(|candidate| {
+                    candidate.symbol_type == SymbolType::Method
+                        && candidate.location.file.parent() == method.location.file.parent()
+                        && candidate
+                            .signature
+                            .as_ref()
+                            .and_then(|signature| signature.receiver.as_deref())
+                            .is_some_and(|receiver| base_type_name(receiver) == owner)
+                })
+                .map(|_| format!("{}.{}", owner, name))
+        })
+    }
+
+    /// The built-in rules left after `enable` and `disable`, followed by the
+    /// request's own rules
+    fn naming_rules(params: &LintNamingRequest) -> Result<Vec<NamingRule>, ErrorData> {
+        let invalid = |message: String| ErrorData::new(ErrorCode::INVALID_PARAMS, message, None);
+
+        let enable = params.enable.clone().unwrap_or_default();
+        let disable = params.disable.clone().unwrap_or_default();
+        let mut rules = if params.use_default_rules.unwrap_or(true) {
+            default_go_rules(&enable)
+        } else {
+            Vec::new()
+        };
+        let builtin: Vec<String> = default_go_rules(&[])
+            .into_iter()
+            .map(|rule| rule.name)
+            .chain(OPTIONAL_RULES.iter().map(|name| name.to_string()))
+            .collect();
+        if let Some(unknown) = enable.iter().chain(&disable).find(|n| !builtin.contains(n)) {
+            return Err(invalid(format!(
+                "Unknown built-in rule '{}'. Expected one of: {}",
+                unknown,
+                builtin.join(", ")
+            )));
+        }
+        rules.retain(|rule| !disable.contains(&rule.name));
+
+        for spec in params.rules.iter().flatten() {
+            let mut kinds = Vec::new();
+            for kind in &spec.kinds {
+                kinds.push(SymbolType::from_name(kind).ok_or_else(|| {
+                    invalid(format!("Unknown kind '{}' in rule '{}'", kind, spec.name))
+                })?);
+            }
+            let scope = match spec.scope.as_deref() {
+                Some(scope) => NameScope::from_name(scope).ok_or_else(|| {
+                    invalid(format!(
+                        "Unknown scope '{}' in rule '{}'. Expected one of: {}",
+                        scope,
+                        spec.name,
+                        NameScope::NAMES.join(", ")
+                    ))
+                })?,
+                None => NameScope::All,
+            };
+            let must_match = spec.must_match.unwrap_or(true);
+            let message = spec.message.clone().unwrap_or_else(|| {
+                let verb = if must_match { "match" } else { "not match" };
+                format!("{} names must {} {}", scope.as_str(), verb, spec.pattern)
+            });
+            let rule = NamingRule::new(
+                &spec.name,
+                kinds,
+                scope,
+                &spec.pattern,
+                must_match,
+                &message,
+            )
+            .map_err(|e| invalid(format!("Rule '{}': {}", spec.name, e)))?;
+            rules.push(rule);
+        }
+        Ok(rules)
+    }
+
+    /// A Go function whose first result, pointer or not, is a type declared
+    /// in the function's package
+    fn is_go_constructor(store: &SymbolStore, symbol: &Symbol) -> bool {
+        if symbol.symbol_type != SymbolType::Function {
+            return false;
+        }
+        let Some(first) = symbol
+            .signature
+            .as_ref()
+            .and_then(|signature| signature.return_type.as_deref())
+            .and_then(|returns| return_values(returns).into_iter().next())
+        else {
+            return false;
+        };
+        let type_name = first.trim_start_matches('*');
+        let type_name = type_name.split('[').next().unwrap_or(type_name);
+        if type_name.is_empty() || type_name.contains('.') {
+            return false;
+        }
+        store.get_symbols(type_name).iter().any(|candidate| {
+            matches!(
+                candidate.symbol_type,
+                SymbolType::Struct | SymbolType::Class | SymbolType::Interface
+            ) && candidate.location.file.parent() == symbol.location.file.parent()
+        })
+    }
+
+    /// Result types of the functions a Go call can reach, resolved by name:
+    /// `pkg.Func` against the package named `pkg`, other qualified calls
+    /// against methods of that name, bare calls against functions in the
+    /// caller's directory. Candidates must agree, otherwise the call is
+    /// skipped; unresolved method calls fall back to KNOWN_ERROR_METHODS.
+    fn callee_results(
+        store: &SymbolStore,
+        file: &PathBuf,
+        call: &DiscardedCall,
+    ) -> Option<(Vec<String>, Option<SymbolId>)> {
+        let (qualifier, name) = match call.callee.rsplit_once('.') {
+            Some((qualifier, name)) => (Some(qualifier), name),
+            None => (None, call.callee.as_str()),
+        };
+        let candidates: Vec<_> = store
+            .get_symbols(name)
+            .into_iter()
+            .filter(|symbol| {
+                symbol.signature.is_some()
+                    && Language::from_path(&symbol.location.file) == Some(Language::Go)
+            })
+            .collect();
+
+        let in_package = |symbol: &Symbol, qualifier: &str| {
+            symbol
+                .namespace
+                .as_deref()
+                .and_then(|namespace| namespace.rsplit(['/', '.']).next())
+                == Some(qualifier)
+        };
+        let reachable: Vec<_> = match qualifier {
+            None => candidates
+                .into_iter()
+                .filter(|symbol| {
+                    symbol.symbol_type == SymbolType::Function
+                        && symbol.location.file.parent() == file.parent()
+                })
+                .collect(),
+            Some(qualifier) => {
+                let functions: Vec<_> = candidates
+                    .iter()
+                    .filter(|symbol| {
+                        symbol.symbol_type == SymbolType::Function && in_package(symbol, qualifier)
+                    })
+                    .cloned()
+                    .collect();
+                if functions.is_empty() {
+                    candidates
+                        .into_iter()
+                        .filter(|symbol| symbol.symbol_type == SymbolType::Method)
+                        .collect()
+                } else {
+                    functions
+                }
+            }
+        };
+
+        let Some(first) = reachable.first() else {
+            return (qualifier.is_some()
+                && call.discard != Discard::Blank
+                && KNOWN_ERROR_METHODS.contains(&name))
+            .then(|| (vec!["error".to_string()], None));
+        };
+        let results = |symbol: &Symbol| {
+            symbol
+                .signature
+                .as_ref()
+                .and_then(|signature| signature.return_type.as_deref())
+                .map(return_values)
+                .unwrap_or_default()
+        };
+        let returns = results(first);
+        if reachable.iter().any(|symbol| results(symbol) != returns) {
+            return None;
+        }
+        let id = (reachable.len() == 1).then_some(first.id);
+        Some((returns, id))
+    }
+
+    fn parse_arguments<T: for<'de> Deserialize<'de>>(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<T, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        serde_json::from_value(Value::Object(args)).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Invalid arguments: {}", e),
+                None,
+            )
+        })
+    }
+
+    fn to_result<T: Serialize>(response: &T) -> Result<CallToolResult, ErrorData> {
+        encode_response(response)
+    }
+}
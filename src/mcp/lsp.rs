@@ -0,0 +1,300 @@
+use crate::indexing::sections::Section;
+use crate::models::{Symbol, SymbolType};
+use serde::{Deserialize, Serialize};
+
+/// A position in the LSP encoding: 0-based line and UTF-16 code unit offset
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct Position {
+    pub line: u32,
+    pub character: u32,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Range {
+    pub start: Position,
+    pub end: Position,
+}
+
+impl Range {
+    fn contains(&self, other: &Range) -> bool {
+        self.start <= other.start && other.end <= self.end
+    }
+}
+
+/// The LSP `DocumentSymbol` shape returned by `textDocument/documentSymbol`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DocumentSymbol {
+    pub name: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub detail: Option<String>,
+    /// LSP `SymbolKind`
+    pub kind: u8,
+    /// The whole definition
+    pub range: Range,
+    /// The symbol's name within `range`
+    pub selection_range: Range,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub children: Vec<DocumentSymbol>,
+}
+
+/// The LSP `SymbolKind` value closest to each of our kinds
+pub fn symbol_kind(symbol_type: &SymbolType) -> u8 {
+    match symbol_type {
+        SymbolType::Module | SymbolType::Import => 2,
+        SymbolType::Class => 5,
+        SymbolType::Method => 6,
+        SymbolType::Enum => 10,
+        SymbolType::Interface => 11,
+        SymbolType::Function | SymbolType::Test => 12,
+        SymbolType::Variable => 13,
+        SymbolType::Constant => 14,
+        SymbolType::Struct => 23,
+        SymbolType::Route => 24,
+    }
+}
+
+/// Build the `DocumentSymbol` tree for one file's symbols. A symbol whose
+/// range lies inside another's becomes its child, so methods nest under
+/// their class where the language declares them inside it.
+pub fn document_symbols(mut symbols: Vec<Symbol>, content: &str) -> Vec<DocumentSymbol> {
+    let lines: Vec<&str> = content.lines().collect();
+    symbols.sort_by(|a, b| {
+        (a.location.start_line, a.location.start_column)
+            .cmp(&(b.location.start_line, b.location.start_column))
+            .then(
+                (b.location.end_line, b.location.end_column)
+                    .cmp(&(a.location.end_line, a.location.end_column)),
+            )
+    });
+
+    let mut roots = Vec::new();
+    // Open ancestors of the next symbol, outermost first
+    let mut stack: Vec<DocumentSymbol> = Vec::new();
+    for symbol in symbols {
+        let node = to_document_symbol(&symbol, &lines);
+        while stack
+            .last()
+            .is_some_and(|parent| !parent.range.contains(&node.range))
+        {
+            close(&mut stack, &mut roots);
+        }
+        stack.push(node);
+    }
+    while !stack.is_empty() {
+        close(&mut stack, &mut roots);
+    }
+    roots
+}
+
+/// LSP `SymbolKind` String, which editors also use for markdown headings
+const SECTION_KIND: u8 = 15;
+
+/// Nest top-level symbols under the section comment they follow. Symbols
+/// before the first section stay at the top level.
+pub fn group_into_sections(
+    roots: Vec<DocumentSymbol>,
+    sections: &[Section],
+    content: &str,
+) -> Vec<DocumentSymbol> {
+    let lines: Vec<&str> = content.lines().collect();
+    let mut grouped: Vec<DocumentSymbol> = Vec::new();
+    let mut groups: Vec<DocumentSymbol> = sections
+        .iter()
+        .map(|section| {
+            let title_end = lines
+                .get(section.line.saturating_sub(1) as usize)
+                .map_or(0, |line| line.len() as u32);
+            let last_end = lines
+                .get(section.end_line.saturating_sub(1) as usize)
+                .map_or(0, |line| line.len() as u32);
+            let start = position(&lines, section.line, 0);
+            DocumentSymbol {
+                name: section.title.clone(),
+                detail: None,
+                kind: SECTION_KIND,
+                range: Range {
+                    start,
+                    end: position(&lines, section.end_line, last_end),
+                },
+                selection_range: Range {
+                    start,
+                    end: position(&lines, section.line, title_end),
+                },
+                children: Vec::new(),
+            }
+        })
+        .collect();
+
+    for root in roots {
+        let group = groups
+            .iter_mut()
+            .rev()
+            .find(|group| group.range.start.line <= root.range.start.line);
+        match group {
+            Some(group) => group.children.push(root),
+            None => grouped.push(root),
+        }
+    }
+    grouped.append(&mut groups);
+    grouped
+}
+
+fn close(stack: &mut Vec<DocumentSymbol>, roots: &mut Vec<DocumentSymbol>) {
+    if let Some(done) = stack.pop() {
+        match stack.last_mut() {
+            Some(parent) => parent.children.push(done),
+            None => roots.push(done),
+        }
+    }
+}
+
+fn to_document_symbol(symbol: &Symbol, lines: &[&str]) -> DocumentSymbol {
+    let location = &symbol.location;
+    let start = position(lines, location.start_line, location.start_column);
+    let end = position(lines, location.end_line, location.end_column);
+    let range = Range { start, end };
+
+    // The name's first occurrence from the start of the definition
+    let selection_range = lines
+        .get(location.start_line.saturating_sub(1) as usize)
+        .and_then(|line| {
+            let from = (location.start_column as usize).min(line.len());
+            let offset = line.get(from..)?.find(symbol.name.as_str())? + from;
+            Some(Range {
+                start: position(lines, location.start_line, offset as u32),
+                end: position(
+                    lines,
+                    location.start_line,
+                    (offset + symbol.name.len()) as u32,
+                ),
+            })
+        })
+        .filter(|selection| range.contains(selection))
+        .unwrap_or(Range { start, end: start });
+
+    DocumentSymbol {
+        name: symbol.name.clone(),
+        detail: symbol.signature.as_ref().map(|s| s.text.clone()),
+        kind: symbol_kind(&symbol.symbol_type),
+        range,
+        selection_range,
+        children: Vec::new(),
+    }
+}
+
+/// Convert a 1-based line and byte column to an LSP position
+fn position(lines: &[&str], line: u32, byte_column: u32) -> Position {
+    let row = line.saturating_sub(1);
+    let character = lines
+        .get(row as usize)
+        .map(|text| {
+            let mut end = (byte_column as usize).min(text.len());
+            while !text.is_char_boundary(end) {
+                end -= 1;
+            }
+            text[..end].encode_utf16().count() as u32
+        })
+        .unwrap_or(byte_column);
+    Position {
+        line: row,
+        character,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, SymbolId, Visibility};
+    use std::collections::BTreeMap;
+    use std::path::PathBuf;
+
+    fn symbol(name: &str, symbol_type: SymbolType, span: (u32, u32, u32, u32)) -> Symbol {
+        let file = PathBuf::from("shapes.py");
+        Symbol {
+            id: SymbolId::new(&file, span.0, span.1),
+            name: name.to_string(),
+            symbol_type,
+            location: Location::new(file, span.0, span.1, span.2, span.3),
+            namespace: None,
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    #[test]
+    fn test_document_symbol_tree() {
+        let content = "class Café:\n    def größe(self):\n        return 1\n\nPI = 3.14\n";
+        let symbols = vec![
+            symbol("PI", SymbolType::Constant, (5, 0, 5, 9)),
+            symbol("größe", SymbolType::Method, (2, 4, 3, 16)),
+            symbol("Café", SymbolType::Class, (1, 0, 3, 16)),
+        ];
+
+        let tree = document_symbols(symbols, content);
+        assert_eq!(tree.len(), 2);
+        let class = &tree[0];
+        assert_eq!(class.name, "Café");
+        assert_eq!(class.kind, 5);
+        assert_eq!(
+            class.range.start,
+            Position {
+                line: 0,
+                character: 0
+            }
+        );
+        // "Café" is 5 bytes but 4 UTF-16 code units
+        assert_eq!(
+            class.selection_range,
+            Range {
+                start: Position {
+                    line: 0,
+                    character: 6
+                },
+                end: Position {
+                    line: 0,
+                    character: 10
+                },
+            }
+        );
+
+        assert_eq!(class.children.len(), 1);
+        let method = &class.children[0];
+        assert_eq!(method.kind, 6);
+        assert_eq!(
+            method.selection_range,
+            Range {
+                start: Position {
+                    line: 1,
+                    character: 8
+                },
+                end: Position {
+                    line: 1,
+                    character: 13
+                },
+            }
+        );
+
+        assert_eq!(tree[1].name, "PI");
+        assert_eq!(tree[1].kind, 14);
+        assert!(tree[1].children.is_empty());
+
+        let sections = vec![Section {
+            title: "Constants".to_string(),
+            line: 4,
+            end_line: 5,
+        }];
+        let content = content.replace("\n\nPI", "\n# Constants\nPI");
+        let grouped = group_into_sections(tree, &sections, &content);
+        assert_eq!(grouped.len(), 2);
+        assert_eq!(grouped[0].name, "Café");
+        assert_eq!(grouped[1].name, "Constants");
+        assert_eq!(grouped[1].kind, SECTION_KIND);
+        assert_eq!(grouped[1].children.len(), 1);
+        assert_eq!(grouped[1].children[0].name, "PI");
+    }
+}
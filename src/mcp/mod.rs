@@ -1,4 +1,15 @@
+pub mod analysis_tools;
+pub mod budget;
+pub mod context_pack;
+pub mod encoding;
+pub mod graph_export;
+pub mod kind_map;
+pub mod lint_tools;
+pub mod lsp;
 pub mod outline_tools;
+pub mod projection;
+pub mod source_chunks;
+pub mod subscriptions;
 pub mod tools;
 
 pub use tools::*;
@@ -1,8 +1,87 @@
-use crate::mcp::tools::get_symbol_store;
-use crate::models::{Symbol, SymbolType, Visibility};
-use crate::utils::PathResolver;
+use crate::indexing::naming_rules::is_go_exported;
+use crate::indexing::sections::{find_sections, Section};
+use crate::indexing::type_members::{
+    base_type_name, extract_type_members, promote_embedded, TypeMembers, TypeMethod,
+};
+use crate::mcp::budget::{estimate_tokens, fit_to_budget};
+use crate::mcp::context_pack::{pack_entries, ContextEntry, ContextSection, OmittedEntry};
+use crate::mcp::encoding::encode_response;
+use crate::mcp::lsp::{document_symbols, group_into_sections, DocumentSymbol};
+use crate::mcp::tools::{get_symbol_store, package_symbols};
+use crate::models::{Language, Symbol, SymbolType, Visibility};
+use crate::utils::{FileSystemWalker, PathResolver, SymlinkPolicy};
 use rmcp::model::{CallToolResult, Content, ErrorCode, ErrorData};
+use serde::{Deserialize, Serialize};
 use serde_json::{Map, Value};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::{Path, PathBuf};
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetFileTypesResponse {
+    pub file_path: String,
+    pub types: Vec<TypeMembers>,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct GetSourceRangeRequest {
+    pub file_path: String,
+    pub start_line: u32,
+    pub end_line: u32,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetSourceRangeResponse {
+    pub file_path: String,
+    pub start_line: u32,
+    pub end_line: u32,
+    pub total_lines: u32,
+    /// The requested end line was past the end of the file
+    pub truncated: bool,
+    pub source: String,
+    /// Symbols whose span intersects the returned lines
+    pub symbols: Vec<Symbol>,
+}
+
+/// One file of a `get_directory_symbols` listing
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DirectoryFileSymbols {
+    pub symbol_count: usize,
+    /// Symbols per kind: `{"function": 3, "struct": 1}`
+    pub counts: BTreeMap<String, usize>,
+    /// The file's outline as LSP `DocumentSymbol[]`, positions in UTF-16
+    pub outline: Vec<DocumentSymbol>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetDirectorySymbolsResponse {
+    pub directory: String,
+    /// Outlines keyed by path relative to `directory`
+    pub files: BTreeMap<String, DirectoryFileSymbols>,
+    pub file_count: usize,
+    pub symbol_count: usize,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct ExportContextRequest {
+    pub path: String,
+    pub max_tokens: Option<usize>,
+    pub bodies: Option<usize>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ExportContextResponse {
+    /// The directory or package exported
+    pub package: String,
+    pub file_count: usize,
+    /// Included entries: doc, then API signatures, type definitions and
+    /// bodies, each section ranked by reference count
+    pub entries: Vec<ContextEntry>,
+    /// Entries that did not fit, in the same order
+    pub omitted: Vec<OmittedEntry>,
+    pub tokens: usize,
+    pub max_tokens: usize,
+    pub truncated: bool,
+}
 
 pub struct OutlineTools;
 
@@ -17,11 +96,31 @@ impl OutlineTools {
             .and_then(|v| v.as_str())
             .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing file_path", None))?;
 
+        let format = args
+            .get("format")
+            .and_then(|v| v.as_str())
+            .unwrap_or("text");
+        if !matches!(format, "text" | "lsp") {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Unknown format '{}'. Expected text or lsp", format),
+                None,
+            ));
+        }
+
+        let include_sections = args
+            .get("include_sections")
+            .and_then(|v| v.as_bool())
+            .unwrap_or(false);
+
         // Use comprehensive path resolution
         let canonical_path = PathResolver::resolve_file_path(file_path)?;
 
         let store = get_symbol_store();
-        let symbols = store.get_symbols_by_file(&canonical_path);
+        let symbols = {
+            let _snapshot = store.read_snapshot();
+            store.get_symbols_by_file(&canonical_path)
+        };
 
         // Check if file has symbols (is indexed)
         if symbols.is_empty() {
@@ -32,7 +131,22 @@ impl OutlineTools {
             ));
         }
 
+        if format == "lsp" {
+            return Self::lsp_outline(&canonical_path, symbols, include_sections).await;
+        }
+
         let mut outline = std::collections::BTreeMap::new();
+        if include_sections {
+            for section in Self::file_sections(&canonical_path).await {
+                outline
+                    .entry("Sections")
+                    .or_insert_with(Vec::new)
+                    .push(format!(
+                        "  ├─ {} ({}-{})",
+                        section.title, section.line, section.end_line
+                    ));
+            }
+        }
         for mut symbol in symbols {
             Self::extract_source_if_needed(&mut symbol).await;
 
@@ -104,12 +218,13 @@ impl OutlineTools {
                     SymbolType::Constant => includes.contains(&"constants".to_string()),
                     SymbolType::Variable => includes.contains(&"variables".to_string()),
                     SymbolType::Module => includes.contains(&"modules".to_string()),
+                    SymbolType::Test => includes.contains(&"tests".to_string()),
                     _ => false,
                 };
 
                 if should_include {
                     let rel_path = file_path.strip_prefix(&canonical_path).unwrap_or(file_path);
-                    let file_name = rel_path.to_string_lossy().to_string();
+                    let file_name = PathResolver::display_path(rel_path);
 
                     file_symbols
                         .entry(file_name)
@@ -148,10 +263,484 @@ impl OutlineTools {
         Ok(CallToolResult::success(vec![Content::text(result)]))
     }
 
+    pub async fn get_directory_symbols(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let directory_path = args
+            .get("directory_path")
+            .and_then(|v| v.as_str())
+            .ok_or_else(|| {
+                ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing directory_path", None)
+            })?;
+        let recursive = args
+            .get("recursive")
+            .and_then(|v| v.as_bool())
+            .unwrap_or(false);
+        let max_tokens = args.get("max_tokens").and_then(|v| v.as_u64());
+
+        let canonical_path = PathResolver::resolve_directory_path(directory_path)?;
+
+        let store = get_symbol_store();
+        let indexed: HashSet<PathBuf> = store
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| {
+                if recursive {
+                    file.starts_with(&canonical_path)
+                } else {
+                    file.parent() == Some(canonical_path.as_path())
+                }
+            })
+            .collect();
+
+        // Files indexed before an ignore rule was added stay in the index
+        // until the next rebuild; walking the directory leaves them out
+        let files = FileSystemWalker::find_files_with_policy(
+            &canonical_path,
+            SymlinkPolicy::from_env(),
+            |file| indexed.contains(file),
+        )
+        .map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Failed to walk '{}': {}", directory_path, e),
+                None,
+            )
+        })?;
+
+        let mut listing = BTreeMap::new();
+        for file in files {
+            let symbols = {
+                let _snapshot = store.read_snapshot();
+                store.get_symbols_by_file(&file)
+            };
+            let Ok(content) = tokio::fs::read_to_string(&file).await else {
+                continue;
+            };
+            let mut counts = BTreeMap::new();
+            for symbol in &symbols {
+                *counts
+                    .entry(symbol.symbol_type.as_str().to_string())
+                    .or_insert(0) += 1;
+            }
+            let rel_path = file.strip_prefix(&canonical_path).unwrap_or(&file);
+            listing.insert(
+                PathResolver::display_path(rel_path),
+                DirectoryFileSymbols {
+                    symbol_count: symbols.len(),
+                    counts,
+                    outline: document_symbols(symbols, &content),
+                },
+            );
+        }
+
+        let response = GetDirectorySymbolsResponse {
+            directory: PathResolver::display_path(&canonical_path),
+            file_count: listing.len(),
+            symbol_count: listing.values().map(|file| file.symbol_count).sum(),
+            files: listing,
+        };
+        let serialization_error = |e: serde_json::Error| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Serialization error: {}", e),
+                None,
+            )
+        };
+        let mut value = serde_json::to_value(&response).map_err(serialization_error)?;
+        if let Some(max_tokens) = max_tokens {
+            // Counts stay those of the whole directory
+            fit_to_budget(&mut value, &["files"], max_tokens as usize);
+        }
+        encode_response(&value)
+    }
+
+    pub async fn get_file_types(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let file_path = args
+            .get("file_path")
+            .and_then(|v| v.as_str())
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing file_path", None))?;
+
+        let canonical_path = PathResolver::resolve_file_path(file_path)?;
+        let language = Language::from_path(&canonical_path).ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Unsupported file type: '{}'", file_path),
+                None,
+            )
+        })?;
+
+        let content = tokio::fs::read_to_string(&canonical_path)
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to read '{}': {}", file_path, e),
+                    None,
+                )
+            })?;
+
+        let mut types = extract_type_members(&content, language).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Failed to parse '{}': {}", file_path, e),
+                None,
+            )
+        })?;
+
+        // Go methods may live in any file of the package, and so may the
+        // types whose members embedding promotes
+        if language == Language::Go {
+            Self::attach_package_methods(&canonical_path, &mut types);
+            if types
+                .iter()
+                .any(|t| t.fields.iter().any(|field| field.embedded))
+            {
+                let package = Self::package_types(&canonical_path, &types).await;
+                promote_embedded(&mut types, &package);
+            }
+        }
+
+        let response = GetFileTypesResponse {
+            file_path: PathResolver::display_path(&canonical_path),
+            types,
+        };
+        encode_response(&response)
+    }
+
+    pub async fn get_source_range(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: GetSourceRangeRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+
+        if params.start_line == 0 || params.end_line < params.start_line {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Invalid line range {}-{}: lines are 1-based and end_line must not precede start_line",
+                    params.start_line, params.end_line
+                ),
+                None,
+            ));
+        }
+
+        let canonical_path = PathResolver::resolve_file_path(&params.file_path)?;
+        let content = tokio::fs::read_to_string(&canonical_path)
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to read '{}': {}", params.file_path, e),
+                    None,
+                )
+            })?;
+
+        let lines: Vec<&str> = content.lines().collect();
+        let total_lines = lines.len() as u32;
+        if params.start_line > total_lines {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "start_line {} is past the end of '{}' ({} lines)",
+                    params.start_line, params.file_path, total_lines
+                ),
+                None,
+            ));
+        }
+
+        // Clamp to the file bounds
+        let end_line = params.end_line.min(total_lines);
+        let source = lines[(params.start_line - 1) as usize..end_line as usize].join("\n");
+
+        let mut symbols: Vec<Symbol> = get_symbol_store()
+            .get_symbols_by_file(&canonical_path)
+            .into_iter()
+            .filter(|symbol| {
+                symbol.location.start_line <= end_line
+                    && symbol.location.end_line >= params.start_line
+            })
+            .collect();
+        symbols.sort_by_key(|symbol| (symbol.location.start_line, symbol.location.start_column));
+
+        let response = GetSourceRangeResponse {
+            file_path: PathResolver::display_path(&canonical_path),
+            start_line: params.start_line,
+            end_line,
+            total_lines,
+            truncated: end_line < params.end_line,
+            source,
+            symbols,
+        };
+        encode_response(&response)
+    }
+
+    /// Assemble the context of one package within a token budget: its doc,
+    /// the signatures of its exported API, its exported type definitions and
+    /// optionally the bodies of its most-referenced functions. Entries are
+    /// dropped from the least important end until the pack fits.
+    pub async fn export_context(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: ExportContextRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+        let max_tokens = params.max_tokens.unwrap_or(8000);
+        let body_count = params.bodies.unwrap_or(0);
+
+        // A directory holds one package; anything else names a package
+        let store = get_symbol_store();
+        let (package, symbols) = package_symbols(&store, &params.path);
+        if symbols.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "No indexed symbols for '{}'. Pass an indexed directory or a package name from list_packages",
+                    params.path
+                ),
+                None,
+            ));
+        }
+
+        let mut sources: HashMap<PathBuf, String> = HashMap::new();
+        for file in symbols.iter().map(|symbol| &symbol.location.file) {
+            if !sources.contains_key(file) {
+                if let Ok(content) = tokio::fs::read_to_string(file).await {
+                    sources.insert(file.clone(), content);
+                }
+            }
+        }
+
+        let entry = |section: ContextSection, symbol: &Symbol, references: usize, text: String| {
+            ContextEntry {
+                section,
+                name: symbol.name.clone(),
+                symbol_type: symbol.symbol_type.as_str().to_string(),
+                file: symbol.location.file.clone(),
+                line: symbol.location.start_line,
+                references,
+                tokens: estimate_tokens(&Value::String(text.clone())),
+                text,
+            }
+        };
+        let definition = |symbol: &Symbol| -> Option<String> {
+            let content = sources.get(&symbol.location.file)?;
+            let lines: Vec<&str> = content.lines().collect();
+            let start = (symbol.location.start_line as usize).saturating_sub(1);
+            let end = (symbol.location.end_line as usize).min(lines.len());
+            (start < end).then(|| lines[start..end].join("\n"))
+        };
+
+        let mut entries = Vec::new();
+        let mut callables: Vec<(usize, &Symbol)> = Vec::new();
+        for symbol in &symbols {
+            let references = store.get_references(&symbol.id).len();
+            if symbol.symbol_type == SymbolType::Module {
+                if let Some(doc) = &symbol.doc {
+                    if !entries
+                        .iter()
+                        .any(|e: &ContextEntry| e.section == ContextSection::Doc)
+                    {
+                        entries.push(entry(ContextSection::Doc, symbol, 0, doc.clone()));
+                    }
+                }
+                continue;
+            }
+            if matches!(
+                symbol.symbol_type,
+                SymbolType::Function | SymbolType::Method
+            ) {
+                callables.push((references, symbol));
+            }
+            let language = Language::from_path(&symbol.location.file);
+            let exported = match language {
+                Some(Language::Go) => is_go_exported(&symbol.name),
+                _ => symbol.visibility == Visibility::Public && !symbol.name.starts_with('_'),
+            };
+            if !exported {
+                continue;
+            }
+            match symbol.symbol_type {
+                SymbolType::Function
+                | SymbolType::Method
+                | SymbolType::Constant
+                | SymbolType::Variable => {
+                    let text = match &symbol.signature {
+                        Some(signature) => Some(signature.text.clone()),
+                        None => definition(symbol),
+                    };
+                    if let Some(text) = text {
+                        entries.push(entry(ContextSection::Api, symbol, references, text));
+                    }
+                }
+                SymbolType::Struct
+                | SymbolType::Class
+                | SymbolType::Interface
+                | SymbolType::Enum => {
+                    if let Some(text) = definition(symbol) {
+                        entries.push(entry(ContextSection::Types, symbol, references, text));
+                    }
+                }
+                _ => {}
+            }
+        }
+
+        // Bodies of the functions the rest of the code leans on most
+        callables.sort_by(|a, b| b.0.cmp(&a.0));
+        for (references, symbol) in callables.into_iter().take(body_count) {
+            if let Some(text) = definition(symbol) {
+                entries.push(entry(ContextSection::Bodies, symbol, references, text));
+            }
+        }
+
+        let file_count = sources.len();
+        let (entries, omitted) = pack_entries(entries, max_tokens);
+        let response = ExportContextResponse {
+            package,
+            file_count,
+            tokens: entries.iter().map(|entry| entry.tokens).sum(),
+            truncated: !omitted.is_empty(),
+            entries,
+            omitted: omitted.into_iter().map(OmittedEntry::from).collect(),
+            max_tokens,
+        };
+        encode_response(&response)
+    }
+
+    /// The outline as an LSP `DocumentSymbol[]`, positions in UTF-16
+    async fn lsp_outline(
+        file_path: &Path,
+        symbols: Vec<Symbol>,
+        include_sections: bool,
+    ) -> Result<CallToolResult, ErrorData> {
+        let content = tokio::fs::read_to_string(file_path).await.map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INTERNAL_ERROR,
+                format!("Failed to read '{}': {}", file_path.display(), e),
+                None,
+            )
+        })?;
+
+        let mut outline = document_symbols(symbols, &content);
+        if include_sections {
+            let sections = Self::file_sections(file_path).await;
+            outline = group_into_sections(outline, &sections, &content);
+        }
+        encode_response(&outline)
+    }
+
+    /// Section comments of a file; none when it cannot be read or parsed
+    async fn file_sections(file_path: &Path) -> Vec<Section> {
+        let Some(language) = Language::from_path(file_path) else {
+            return Vec::new();
+        };
+        let Ok(content) = tokio::fs::read_to_string(file_path).await else {
+            return Vec::new();
+        };
+        find_sections(&content, language).unwrap_or_default()
+    }
+
+    /// Every type of the Go package `file_path` belongs to, with its own
+    /// members: `types` of the file itself and those of the other indexed
+    /// files in its directory
+    async fn package_types(
+        file_path: &Path,
+        types: &[TypeMembers],
+    ) -> HashMap<String, TypeMembers> {
+        let mut package: HashMap<String, TypeMembers> =
+            types.iter().map(|t| (t.name.clone(), t.clone())).collect();
+
+        let directory = file_path.parent();
+        let mut siblings: Vec<PathBuf> = get_symbol_store()
+            .files
+            .iter()
+            .map(|entry| entry.key().clone())
+            .filter(|file| {
+                file != file_path
+                    && file.parent() == directory
+                    && Language::from_path(file) == Some(Language::Go)
+            })
+            .collect();
+        siblings.sort();
+        for sibling in siblings {
+            let Ok(content) = tokio::fs::read_to_string(&sibling).await else {
+                continue;
+            };
+            let Ok(mut sibling_types) = extract_type_members(&content, Language::Go) else {
+                continue;
+            };
+            Self::attach_package_methods(&sibling, &mut sibling_types);
+            for sibling_type in sibling_types {
+                package
+                    .entry(sibling_type.name.clone())
+                    .or_insert(sibling_type);
+            }
+        }
+        package
+    }
+
+    /// Add indexed methods declared on these types from sibling files in the same directory
+    pub(crate) fn attach_package_methods(file_path: &Path, types: &mut [TypeMembers]) {
+        let store = get_symbol_store();
+        let directory = file_path.parent();
+
+        for entry in store.symbol_data.iter() {
+            let symbol = entry.value();
+            if symbol.location.file == file_path || symbol.location.file.parent() != directory {
+                continue;
+            }
+            let receiver = match symbol.signature.as_ref().and_then(|s| s.receiver.as_ref()) {
+                Some(receiver) => receiver,
+                None => continue,
+            };
+
+            let owner = base_type_name(receiver);
+            if let Some(owner_type) = types.iter_mut().find(|t| t.name == owner) {
+                owner_type.methods.push(TypeMethod {
+                    name: symbol.name.clone(),
+                    signature: symbol.signature.as_ref().map(|s| s.text.clone()),
+                    line: symbol.location.start_line,
+                    exported: symbol.name.chars().next().is_some_and(|c| c.is_uppercase()),
+                    file: Some(PathResolver::display_path(&symbol.location.file)),
+                    promoted_from: None,
+                    promotion_path: Vec::new(),
+                });
+            }
+        }
+
+        for owner_type in types.iter_mut() {
+            owner_type
+                .methods
+                .sort_by(|a, b| a.file.cmp(&b.file).then(a.line.cmp(&b.line)));
+        }
+    }
+
     async fn extract_source_if_needed(symbol: &mut Symbol) {
         if matches!(
             symbol.symbol_type,
-            SymbolType::Function | SymbolType::Method
+            SymbolType::Function | SymbolType::Method | SymbolType::Test
         ) && symbol.source.is_none()
         {
             if let Ok(content) = tokio::fs::read_to_string(&symbol.location.file).await {
@@ -177,15 +766,24 @@ impl OutlineTools {
             SymbolType::Module => "Modules",
             SymbolType::Import => "Imports",
             SymbolType::Variable => "Variables",
+            SymbolType::Test => "Tests",
+            SymbolType::Route => "Routes",
         }
     }
 
     fn format_symbol_display(symbol: &Symbol) -> String {
         if matches!(
             symbol.symbol_type,
-            SymbolType::Function | SymbolType::Method
+            SymbolType::Function | SymbolType::Method | SymbolType::Test
         ) {
-            if let Some(source) = &symbol.source {
+            if let Some(signature) = &symbol.signature {
+                let text = signature.text.as_str();
+                if text.len() > 80 {
+                    format!("{}...", text.chars().take(77).collect::<String>())
+                } else {
+                    text.to_string()
+                }
+            } else if let Some(source) = &symbol.source {
                 let first_line = source.lines().next().unwrap_or(&symbol.name).trim();
                 if first_line.len() > 80 {
                     format!("{}...", &first_line[..77])
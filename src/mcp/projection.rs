@@ -0,0 +1,99 @@
+use rmcp::model::{ErrorCode, ErrorData};
+use serde_json::Value;
+
+/// Fields of a serialized `Symbol`
+pub const SYMBOL_FIELDS: &[&str] = &[
+    "id",
+    "name",
+    "symbol_type",
+    "location",
+    "namespace",
+    "visibility",
+    "source",
+    "signature",
+    "tags",
+    "doc",
+    "alias_of",
+];
+
+/// Result fields selected through a tool's `fields` argument. Without a
+/// selection every field is returned.
+#[derive(Debug, Clone, Default)]
+pub struct FieldProjection {
+    fields: Option<Vec<String>>,
+}
+
+impl FieldProjection {
+    /// Validate the requested fields against those a tool's results carry
+    pub fn parse(fields: Option<Vec<String>>, available: &[&str]) -> Result<Self, ErrorData> {
+        let Some(fields) = fields else {
+            return Ok(Self::default());
+        };
+        if let Some(unknown) = fields
+            .iter()
+            .find(|field| !available.contains(&field.as_str()))
+        {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown field '{}'. Expected one of: {}",
+                    unknown,
+                    available.join(", ")
+                ),
+                None,
+            ));
+        }
+        Ok(Self {
+            fields: Some(fields),
+        })
+    }
+
+    /// Whether `field` is returned, so tools can skip deriving the others
+    pub fn includes(&self, field: &str) -> bool {
+        match &self.fields {
+            Some(fields) => fields.iter().any(|f| f == field),
+            None => true,
+        }
+    }
+
+    /// Drop unselected keys from every object in a serialized result list
+    pub fn apply(&self, results: &mut Value) {
+        let Some(fields) = &self.fields else {
+            return;
+        };
+        if let Some(items) = results.as_array_mut() {
+            for item in items {
+                if let Some(object) = item.as_object_mut() {
+                    object.retain(|key, _| fields.iter().any(|f| f == key));
+                }
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    #[test]
+    fn test_projection() {
+        let projection =
+            FieldProjection::parse(Some(vec!["name".into(), "location".into()]), SYMBOL_FIELDS)
+                .unwrap();
+        assert!(projection.includes("name"));
+        assert!(!projection.includes("source"));
+
+        let mut results = json!([
+            {"id": 1, "name": "Run", "location": {"file": "a.go"}, "source": "func Run() {}"}
+        ]);
+        projection.apply(&mut results);
+        assert_eq!(
+            results,
+            json!([{"name": "Run", "location": {"file": "a.go"}}])
+        );
+
+        let all = FieldProjection::default();
+        assert!(all.includes("source"));
+    }
+}
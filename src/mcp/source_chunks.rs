@@ -0,0 +1,153 @@
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+
+/// Largest chunk `get_source_chunk` returns when no size is given
+pub const DEFAULT_CHUNK_BYTES: usize = 32 * 1024;
+
+/// One chunk of a definition's source too large for a single message
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SourceChunk {
+    /// Byte offset of the chunk in the full source
+    pub offset: usize,
+    /// Bytes in this chunk
+    pub length: usize,
+    /// Bytes in the full source, for showing progress
+    pub total_bytes: usize,
+    /// Lines in the full source
+    pub total_lines: usize,
+    /// File lines the chunk covers, inclusive
+    pub start_line: u32,
+    pub end_line: u32,
+    /// Pass to `get_source_chunk` for the next chunk; absent on the last one
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub next_cursor: Option<String>,
+}
+
+/// Where the next chunk of a definition starts. The digest of the full
+/// source detects a file edited between chunks.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ChunkCursor {
+    pub symbol_id: u64,
+    pub offset: usize,
+    pub digest: String,
+}
+
+impl ChunkCursor {
+    /// An opaque token: `<symbol id>:<offset>:<digest>`
+    pub fn encode(&self) -> String {
+        format!("{}:{}:{}", self.symbol_id, self.offset, self.digest)
+    }
+
+    pub fn parse(token: &str) -> Option<Self> {
+        let mut parts = token.trim().splitn(3, ':');
+        let symbol_id = parts.next()?.parse().ok()?;
+        let offset = parts.next()?.parse().ok()?;
+        let digest = parts.next().filter(|digest| !digest.is_empty())?;
+        Some(Self {
+            symbol_id,
+            offset,
+            digest: digest.to_string(),
+        })
+    }
+}
+
+/// A short SHA-256 hex digest of a definition's full source
+pub fn source_digest(source: &str) -> String {
+    let mut hasher = Sha256::new();
+    hasher.update(source.as_bytes());
+    format!("{:x}", hasher.finalize())[..16].to_string()
+}
+
+/// Cut the chunk of `source` starting at byte `offset`, at most `max_bytes`
+/// long, ending after the last line break that fits. A single line longer
+/// than `max_bytes` is split at a character boundary instead. `first_line`
+/// is the file line `source` starts on. Returns the chunk text and its
+/// description, with a cursor for `symbol_id` when source remains.
+pub fn source_chunk(
+    source: &str,
+    symbol_id: u64,
+    first_line: u32,
+    offset: usize,
+    max_bytes: usize,
+) -> (String, SourceChunk) {
+    let offset = floor_char_boundary(source, offset.min(source.len()));
+    let limit = offset.saturating_add(max_bytes.max(1));
+    let end = if limit >= source.len() {
+        source.len()
+    } else {
+        let limit = floor_char_boundary(source, limit);
+        match source[offset..limit].rfind('\n') {
+            Some(newline) => offset + newline + 1,
+            None if limit > offset => limit,
+            // A character wider than the whole budget still moves on
+            None => offset + source[offset..].chars().next().map_or(0, char::len_utf8),
+        }
+    };
+
+    let text = &source[offset..end];
+    let lines_before = source[..offset].matches('\n').count() as u32;
+    let start_line = first_line + lines_before;
+    let end_line = start_line + text.trim_end_matches('\n').matches('\n').count() as u32;
+    let next_cursor = (end < source.len()).then(|| {
+        ChunkCursor {
+            symbol_id,
+            offset: end,
+            digest: source_digest(source),
+        }
+        .encode()
+    });
+    let chunk = SourceChunk {
+        offset,
+        length: text.len(),
+        total_bytes: source.len(),
+        total_lines: source.lines().count(),
+        start_line,
+        end_line,
+        next_cursor,
+    };
+    (text.to_string(), chunk)
+}
+
+fn floor_char_boundary(text: &str, mut index: usize) -> usize {
+    while !text.is_char_boundary(index) {
+        index -= 1;
+    }
+    index
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_source_chunks() {
+        let source = "func Big() {\n    a := 1\n    b := 2\n}";
+        let (text, chunk) = source_chunk(source, 7, 10, 0, 30);
+        assert_eq!(text, "func Big() {\n    a := 1\n");
+        assert_eq!((chunk.start_line, chunk.end_line), (10, 11));
+        assert_eq!((chunk.total_bytes, chunk.total_lines), (source.len(), 4));
+
+        let cursor = ChunkCursor::parse(chunk.next_cursor.as_deref().unwrap()).unwrap();
+        assert_eq!(cursor.symbol_id, 7);
+        assert_eq!(cursor.offset, text.len());
+        assert_eq!(cursor.digest, source_digest(source));
+
+        let (rest, last) = source_chunk(source, 7, 10, cursor.offset, 30);
+        assert_eq!(rest, "    b := 2\n}");
+        assert_eq!((last.start_line, last.end_line), (12, 13));
+        assert_eq!(last.next_cursor, None);
+
+        // A line longer than the budget is split inside it
+        let (text, chunk) = source_chunk("héllo\n", 1, 1, 0, 2);
+        assert_eq!(text, "h");
+        assert_eq!(
+            ChunkCursor::parse(&chunk.next_cursor.unwrap())
+                .unwrap()
+                .offset,
+            1
+        );
+
+        assert_eq!(ChunkCursor::parse("7:12"), None);
+        assert_eq!(ChunkCursor::parse("x:1:abc"), None);
+    }
+}
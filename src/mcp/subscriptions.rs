@@ -0,0 +1,382 @@
+use crate::mcp::encoding::encode_response;
+use crate::storage::changes::{subscribe_file_changes, FileChange};
+use regex::Regex;
+use rmcp::model::{
+    CallToolResult, ErrorCode, ErrorData, LoggingLevel, LoggingMessageNotificationParam,
+};
+use rmcp::service::{Peer, RoleServer};
+use schemars::JsonSchema;
+use serde::{Deserialize, Serialize};
+use serde_json::{Map, Value};
+use std::collections::HashMap;
+use std::path::Path;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Mutex, OnceLock};
+use tokio::sync::broadcast::error::RecvError;
+use tokio::sync::broadcast::Receiver;
+use tokio_util::sync::CancellationToken;
+
+/// Logger name of the `notifications/message` carrying index changes
+pub const CHANGES_LOGGER: &str = "roberto.index_changes";
+
+static SUBSCRIPTIONS: OnceLock<Mutex<HashMap<u64, Subscription>>> = OnceLock::new();
+static NEXT_SUBSCRIPTION: AtomicU64 = AtomicU64::new(1);
+static NEXT_SESSION: AtomicU64 = AtomicU64::new(1);
+
+/// The client connection a subscription belongs to. Every value made with
+/// `new` or `default` is distinct.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub struct SessionId(u64);
+
+impl SessionId {
+    pub fn new() -> Self {
+        Self(NEXT_SESSION.fetch_add(1, Ordering::Relaxed))
+    }
+}
+
+impl Default for SessionId {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+struct Subscription {
+    session: SessionId,
+    paths: Vec<String>,
+    cancel: CancellationToken,
+}
+
+fn subscriptions() -> &'static Mutex<HashMap<u64, Subscription>> {
+    SUBSCRIPTIONS.get_or_init(|| Mutex::new(HashMap::new()))
+}
+
+/// Translate a path glob into a regex: `*` and `?` stay within a path
+/// component, `**` spans any number of them. Patterns that are not anchored
+/// with `/` match at any depth, so `users/*.go` matches
+/// `/src/app/users/service.go`.
+pub fn glob_regex(pattern: &str) -> Result<Regex, regex::Error> {
+    let anchored = pattern.starts_with('/');
+    let mut regex = String::from(if anchored { "^" } else { "(^|/)" });
+    let mut chars = pattern.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            '*' if chars.peek() == Some(&'*') => {
+                chars.next();
+                if chars.peek() == Some(&'/') {
+                    chars.next();
+                    regex.push_str("(.*/)?");
+                } else {
+                    regex.push_str(".*");
+                }
+            }
+            '*' => regex.push_str("[^/]*"),
+            '?' => regex.push_str("[^/]"),
+            c => regex.push_str(&regex::escape(&c.to_string())),
+        }
+    }
+    regex.push('$');
+    Regex::new(&regex)
+}
+
+/// Paths a subscription hears about; no patterns means every path
+pub struct PathFilter {
+    patterns: Vec<Regex>,
+}
+
+impl PathFilter {
+    pub fn new(globs: &[String]) -> Result<Self, regex::Error> {
+        let patterns = globs
+            .iter()
+            .map(|glob| glob_regex(glob))
+            .collect::<Result<_, _>>()?;
+        Ok(Self { patterns })
+    }
+
+    pub fn matches(&self, path: &Path) -> bool {
+        let path = path.to_string_lossy().replace('\\', "/");
+        self.patterns.is_empty() || self.patterns.iter().any(|p| p.is_match(&path))
+    }
+}
+
+#[derive(Debug, Serialize)]
+struct ChangeNotification<'a> {
+    subscription_id: u64,
+    #[serde(flatten)]
+    change: &'a FileChange,
+}
+
+/// Forward file changes matching `filter` to the client until the
+/// subscription is cancelled or a notification cannot be delivered, which
+/// is how a disconnected client is noticed
+async fn forward_changes(
+    id: u64,
+    filter: PathFilter,
+    mut changes: Receiver<FileChange>,
+    peer: Peer<RoleServer>,
+    cancel: CancellationToken,
+) {
+    loop {
+        let change = tokio::select! {
+            _ = cancel.cancelled() => break,
+            change = changes.recv() => change,
+        };
+        let (level, data) = match change {
+            Ok(change) if filter.matches(&change.file) => {
+                let notification = ChangeNotification {
+                    subscription_id: id,
+                    change: &change,
+                };
+                let Ok(data) = serde_json::to_value(&notification) else {
+                    continue;
+                };
+                (LoggingLevel::Info, data)
+            }
+            Ok(_) => continue,
+            // Events were dropped; the client should re-query what it shows
+            Err(RecvError::Lagged(missed)) => (
+                LoggingLevel::Warning,
+                serde_json::json!({"subscription_id": id, "missed": missed}),
+            ),
+            Err(RecvError::Closed) => break,
+        };
+        let sent = peer
+            .notify_logging_message(LoggingMessageNotificationParam {
+                level,
+                logger: Some(CHANGES_LOGGER.to_string()),
+                data,
+            })
+            .await;
+        if sent.is_err() {
+            tracing::info!("Dropping change subscription {}: client is gone", id);
+            break;
+        }
+    }
+    if let Ok(mut subscriptions) = subscriptions().lock() {
+        subscriptions.remove(&id);
+    }
+}
+
+/// Cancel every subscription of `session`, returning how many there were.
+/// Call when the session's connection closes; a subscription whose client
+/// is gone otherwise lingers until a change fails to send.
+pub fn end_session(session: SessionId) -> usize {
+    let ended: Vec<(u64, Subscription)> = match subscriptions().lock() {
+        Ok(mut subscriptions) => {
+            let ids: Vec<u64> = subscriptions
+                .iter()
+                .filter(|(_, subscription)| subscription.session == session)
+                .map(|(id, _)| *id)
+                .collect();
+            ids.into_iter()
+                .filter_map(|id| {
+                    subscriptions
+                        .remove(&id)
+                        .map(|subscription| (id, subscription))
+                })
+                .collect()
+        }
+        Err(_) => Vec::new(),
+    };
+    for (id, subscription) in &ended {
+        subscription.cancel.cancel();
+        tracing::info!("Change subscription {} ended with its session", id);
+    }
+    ended.len()
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct SubscribeChangesRequest {
+    /// Path globs to hear about, e.g. `internal/**/*.go` (default: every file)
+    pub paths: Option<Vec<String>>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SubscribeChangesResponse {
+    pub subscription_id: u64,
+    /// Logger name of the notifications carrying the changes
+    pub logger: String,
+    pub paths: Vec<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct UnsubscribeChangesRequest {
+    pub subscription_id: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct UnsubscribeChangesResponse {
+    pub subscription_id: u64,
+    /// False when no such subscription was active
+    pub unsubscribed: bool,
+    /// Subscriptions the session still has
+    pub remaining: usize,
+}
+
+/// Push notifications of index changes made by the file watcher
+pub struct SubscriptionTools;
+
+impl SubscriptionTools {
+    pub async fn subscribe_changes(
+        arguments: Option<Map<String, Value>>,
+        peer: &Peer<RoleServer>,
+        session: SessionId,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: SubscribeChangesRequest =
+            Self::parse_arguments(Some(arguments.unwrap_or_default()))?;
+        let paths = params.paths.unwrap_or_default();
+        let filter = PathFilter::new(&paths).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Invalid path glob: {}", e),
+                None,
+            )
+        })?;
+
+        let id = NEXT_SUBSCRIPTION.fetch_add(1, Ordering::Relaxed);
+        let cancel = CancellationToken::new();
+        if let Ok(mut subscriptions) = subscriptions().lock() {
+            subscriptions.insert(
+                id,
+                Subscription {
+                    session,
+                    paths: paths.clone(),
+                    cancel: cancel.clone(),
+                },
+            );
+        }
+        // Listen before returning so no change after the reply is missed
+        let changes = subscribe_file_changes();
+        tokio::spawn(forward_changes(id, filter, changes, peer.clone(), cancel));
+        tracing::info!("Change subscription {} started for {:?}", id, paths);
+
+        let response = SubscribeChangesResponse {
+            subscription_id: id,
+            logger: CHANGES_LOGGER.to_string(),
+            paths,
+        };
+        Self::to_result(&response)
+    }
+
+    /// End a subscription of `session`; another session's subscriptions are
+    /// left running and the request is rejected
+    pub async fn unsubscribe_changes(
+        arguments: Option<Map<String, Value>>,
+        session: SessionId,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: UnsubscribeChangesRequest = Self::parse_arguments(arguments)?;
+        let (subscription, remaining) = match subscriptions().lock() {
+            Ok(mut subscriptions) => {
+                let owner = subscriptions
+                    .get(&params.subscription_id)
+                    .map(|subscription| subscription.session);
+                if owner.is_some_and(|owner| owner != session) {
+                    return Err(ErrorData::new(
+                        ErrorCode::INVALID_PARAMS,
+                        format!(
+                            "Subscription {} belongs to another session",
+                            params.subscription_id
+                        ),
+                        None,
+                    ));
+                }
+                let subscription = subscriptions.remove(&params.subscription_id);
+                let remaining = subscriptions
+                    .values()
+                    .filter(|subscription| subscription.session == session)
+                    .count();
+                (subscription, remaining)
+            }
+            Err(_) => (None, 0),
+        };
+        if let Some(subscription) = &subscription {
+            subscription.cancel.cancel();
+            tracing::info!(
+                "Change subscription {} for {:?} ended",
+                params.subscription_id,
+                subscription.paths
+            );
+        }
+
+        let response = UnsubscribeChangesResponse {
+            subscription_id: params.subscription_id,
+            unsubscribed: subscription.is_some(),
+            remaining,
+        };
+        Self::to_result(&response)
+    }
+
+    fn parse_arguments<T: for<'de> Deserialize<'de>>(
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<T, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        serde_json::from_value(Value::Object(args)).map_err(|e| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Invalid arguments: {}", e),
+                None,
+            )
+        })
+    }
+
+    fn to_result<T: Serialize>(response: &T) -> Result<CallToolResult, ErrorData> {
+        encode_response(response)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_path_filter() {
+        let filter =
+            PathFilter::new(&["users/*.go".to_string(), "/srv/api/**".to_string()]).unwrap();
+        assert!(filter.matches(Path::new("users/service.go")));
+        assert!(filter.matches(Path::new("/src/app/users/service.go")));
+        assert!(!filter.matches(Path::new("users/internal/cache.go")));
+        assert!(!filter.matches(Path::new("ausers/service.go")));
+        assert!(filter.matches(Path::new("/srv/api/v1/routes.go")));
+        assert!(!filter.matches(Path::new("/home/srv/api/routes.go")));
+
+        let nested = PathFilter::new(&["internal/**/*_test.go".to_string()]).unwrap();
+        assert!(nested.matches(Path::new("internal/cache_test.go")));
+        assert!(nested.matches(Path::new("internal/store/lru/cache_test.go")));
+        assert!(!nested.matches(Path::new("internal/store/cache.go")));
+
+        assert!(PathFilter::new(&[]).unwrap().matches(Path::new("main.go")));
+    }
+
+    #[tokio::test]
+    async fn test_subscriptions_belong_to_their_session() {
+        let (owner, other) = (SessionId::new(), SessionId::new());
+        let id = NEXT_SUBSCRIPTION.fetch_add(1, Ordering::Relaxed);
+        let cancel = CancellationToken::new();
+        subscriptions().lock().unwrap().insert(
+            id,
+            Subscription {
+                session: owner,
+                paths: Vec::new(),
+                cancel: cancel.clone(),
+            },
+        );
+        let mut arguments = Map::new();
+        arguments.insert("subscription_id".to_string(), id.into());
+
+        // Another session cannot end it
+        let result = SubscriptionTools::unsubscribe_changes(Some(arguments.clone()), other).await;
+        assert!(result.is_err());
+        assert!(!cancel.is_cancelled());
+        assert_eq!(end_session(other), 0);
+
+        // Closing the owner's connection does
+        assert_eq!(end_session(owner), 1);
+        assert!(cancel.is_cancelled());
+        assert!(!subscriptions().lock().unwrap().contains_key(&id));
+        assert!(
+            SubscriptionTools::unsubscribe_changes(Some(arguments), owner)
+                .await
+                .is_ok()
+        );
+    }
+}
@@ -1,31 +1,72 @@
+use crate::indexing::api_diff::{
+    api_hash, api_surface, diff_public_api, render_report, ApiChangeKind, ReportFormat,
+};
+use crate::indexing::attributes::has_attribute;
+use crate::indexing::call_graph::receiver_type;
+use crate::indexing::declaration::declaration_text;
+use crate::indexing::frontend::LanguageFrontend;
+use crate::indexing::settings::{
+    reindex_scope, set_setting, setting_scope, ReindexScope, SettingChange, SETTINGS,
+};
+use crate::indexing::type_forms::{go_method_set, GoTypeDecl, TypeForm, TYPE_EXPR_TAG};
+use crate::indexing::type_members::extract_type_members;
+use crate::indexing::var_types::{resolve_var_init, UNKNOWN_TYPE, VAR_INIT_TAG, VAR_TYPE_TAG};
+use crate::indexing::vendor::{VendorPaths, VENDOR_TAG};
+use crate::mcp::analysis_tools::AnalysisTools;
+use crate::mcp::budget::fit_to_budget;
+use crate::mcp::encoding::{encode_response, ResponseEncoding};
+use crate::mcp::kind_map::KindMap;
+use crate::mcp::lint_tools::LintTools;
 use crate::mcp::outline_tools::OutlineTools;
-use crate::models::{Reference, Symbol, SymbolType};
-use crate::utils::{FileWatcher, PathResolver};
+use crate::mcp::projection::{FieldProjection, SYMBOL_FIELDS};
+use crate::mcp::source_chunks::{
+    source_chunk, source_digest, ChunkCursor, SourceChunk, DEFAULT_CHUNK_BYTES,
+};
+use crate::mcp::subscriptions::{end_session, SessionId, SubscriptionTools};
+use crate::models::{
+    Language, ParseStatus, Reference, SignatureStyle, Symbol, SymbolId, SymbolType,
+};
+use crate::search::{MatchedSynonym, SynonymMap, SynonymQuery};
+use crate::storage::{
+    repair_index, validate_index, DefinitionCacheStats, IndexIssue, IssueKind, MergeReport,
+    RefIndexCache,
+};
+use crate::utils::error::CodeAnalysisError;
+use crate::utils::{
+    format_timestamp, git_changed_files, git_files_committed_since, git_resolve_commit,
+    parse_since, spawn_sighup_handler, BlameCache, BlameInfo, FileBlame, FileWatcher, GitChange,
+    PathResolver,
+};
 use crate::{IndexingPipeline, SymbolStore};
 use rmcp::{
     model::{
-        CallToolRequestParam, CallToolResult, Content, ErrorCode, ErrorData, GetPromptRequestParam,
+        CallToolRequestParam, CallToolResult, ErrorCode, ErrorData, GetPromptRequestParam,
         GetPromptResult, ListPromptsResult, ListToolsResult, PaginatedRequestParam, Prompt,
         PromptArgument, PromptMessage, PromptMessageContent, PromptMessageRole, ServerCapabilities,
         ServerInfo, Tool,
     },
-    service::{RequestContext, RoleServer},
+    service::{Peer, RequestContext, RoleServer},
     ServerHandler,
 };
 use schemars::JsonSchema;
 use serde::{Deserialize, Serialize};
 use serde_json::{json, Map, Value};
-use std::collections::HashMap;
-use std::path::PathBuf;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::path::{Path, PathBuf};
 use std::sync::Arc;
-use std::sync::OnceLock;
-use std::time::Instant;
+use std::sync::{OnceLock, RwLock};
+use std::time::{Instant, SystemTime};
+use tokio_util::sync::CancellationToken;
 
 // Global instances (initialized once per process)
 pub static SYMBOL_STORE: OnceLock<Arc<SymbolStore>> = OnceLock::new();
 static INDEXING_PIPELINE: OnceLock<Arc<tokio::sync::Mutex<IndexingPipeline>>> = OnceLock::new();
+static BLAME_CACHE: OnceLock<BlameCache> = OnceLock::new();
 static FILE_WATCHERS: OnceLock<Arc<tokio::sync::Mutex<HashMap<PathBuf, Arc<FileWatcher>>>>> =
     OnceLock::new();
+static SYNONYMS: OnceLock<SynonymMap> = OnceLock::new();
+static VENDOR_PATHS: RwLock<Option<Arc<VendorPaths>>> = RwLock::new(None);
+static REF_INDEXES: OnceLock<tokio::sync::Mutex<RefIndexCache>> = OnceLock::new();
 
 pub fn get_symbol_store() -> Arc<SymbolStore> {
     SYMBOL_STORE
@@ -33,6 +74,139 @@ pub fn get_symbol_store() -> Arc<SymbolStore> {
         .clone()
 }
 
+/// Query synonyms from ROBERTO_SYNONYMS, read on first search
+fn synonyms() -> &'static SynonymMap {
+    SYNONYMS.get_or_init(SynonymMap::from_env)
+}
+
+/// Vendored code locations from ROBERTO_VENDOR and ROBERTO_VENDOR_PATHS,
+/// read on first use and replaced when the settings are reloaded
+pub(crate) fn vendor_paths() -> Arc<VendorPaths> {
+    if let Some(paths) = VENDOR_PATHS.read().unwrap().as_ref() {
+        return paths.clone();
+    }
+    VENDOR_PATHS
+        .write()
+        .unwrap()
+        .get_or_insert_with(|| Arc::new(VendorPaths::from_env()))
+        .clone()
+}
+
+/// Re-read the settings into the pipeline, keeping the vendored code
+/// locations the tools check in step with the ones it indexes by
+fn reload_pipeline_settings(pipeline: &mut IndexingPipeline) -> Vec<SettingChange> {
+    let changes = pipeline.reload_settings();
+    *VENDOR_PATHS.write().unwrap() = Some(Arc::new(pipeline.vendor_paths().clone()));
+    changes
+}
+
+/// Indexed files of a language under an optional file or directory, sorted
+pub(crate) fn indexed_files(
+    path: Option<&str>,
+    language: Language,
+) -> Result<Vec<PathBuf>, ErrorData> {
+    let scope = match path {
+        Some(path) => Some(PathResolver::resolve_file_or_directory_path(path)?),
+        None => None,
+    };
+
+    let store = get_symbol_store();
+    let mut files: Vec<PathBuf> = store
+        .files
+        .iter()
+        .map(|entry| entry.key().clone())
+        .filter(|file| Language::from_path(file) == Some(language))
+        .filter(|file| match &scope {
+            Some(scope) => file.starts_with(scope),
+            None => true,
+        })
+        .collect();
+    files.sort();
+    Ok(files)
+}
+
+/// The symbols of one package: the files directly in `package` when it is a
+/// directory, otherwise the symbols whose namespace is `package`. Returns
+/// the package's display name with them.
+pub(crate) fn package_symbols(store: &SymbolStore, package: &str) -> (String, Vec<Symbol>) {
+    let _snapshot = store.read_snapshot();
+    match PathResolver::resolve_directory_path(package) {
+        Ok(directory) => {
+            let mut files: Vec<PathBuf> = store
+                .files
+                .iter()
+                .map(|entry| entry.key().clone())
+                .filter(|file| file.parent() == Some(directory.as_path()))
+                .collect();
+            files.sort();
+            let symbols: Vec<Symbol> = files
+                .iter()
+                .flat_map(|file| store.get_symbols_by_file(file))
+                .collect();
+            (PathResolver::display_path(&directory), symbols)
+        }
+        Err(_) => {
+            let symbols: Vec<Symbol> = store
+                .symbol_data
+                .iter()
+                .filter(|entry| entry.value().namespace.as_deref() == Some(package))
+                .map(|entry| entry.value().clone())
+                .collect();
+            (package.to_string(), symbols)
+        }
+    }
+}
+
+/// The index of the indexed directories as they are at git revision `rev`,
+/// built from the object store on first use and cached by commit
+async fn ref_index(rev: &str) -> Result<Arc<SymbolStore>, ErrorData> {
+    let invalid = |message: String| ErrorData::new(ErrorCode::INVALID_PARAMS, message, None);
+    let roots = PathResolver::indexed_roots();
+    if roots.is_empty() {
+        return Err(invalid(
+            "No directory indexed yet; run index_code before querying a git ref".to_string(),
+        ));
+    }
+
+    let mut key = Vec::new();
+    let mut last_error = String::new();
+    for root in roots {
+        match git_resolve_commit(&root, rev) {
+            Ok(commit) => key.push((root, commit)),
+            Err(e) => last_error = e,
+        }
+    }
+    if key.is_empty() {
+        return Err(invalid(format!(
+            "Cannot resolve git ref '{}': {}",
+            rev, last_error
+        )));
+    }
+
+    // Held while building so concurrent queries for a new ref build it once
+    let mut cache = REF_INDEXES
+        .get_or_init(|| tokio::sync::Mutex::new(RefIndexCache::from_env()))
+        .lock()
+        .await;
+    if let Some(store) = cache.get(&key) {
+        return Ok(store);
+    }
+
+    let store = Arc::new(SymbolStore::new());
+    let mut pipeline = IndexingPipeline::new(store.clone())
+        .map_err(|e| ErrorData::new(ErrorCode::INTERNAL_ERROR, e.to_string(), None))?;
+    for (root, commit) in &key {
+        let result = pipeline.index_git_ref(root, commit).await;
+        if result.files_processed == 0 {
+            if let Some(error) = result.errors.first() {
+                return Err(invalid(error.clone()));
+            }
+        }
+    }
+    cache.insert(key, store.clone());
+    Ok(store)
+}
+
 fn get_indexing_pipeline() -> Arc<tokio::sync::Mutex<IndexingPipeline>> {
     INDEXING_PIPELINE
         .get_or_init(|| {
@@ -43,6 +217,92 @@ fn get_indexing_pipeline() -> Arc<tokio::sync::Mutex<IndexingPipeline>> {
         .clone()
 }
 
+/// Register a custom frontend with the server's pipeline. Call before the
+/// first `index_code` so the files it handles are part of the initial build.
+pub async fn register_frontend(frontend: Box<dyn LanguageFrontend>) {
+    get_indexing_pipeline().lock().await.register_frontend(frontend);
+}
+
+/// Re-index every directory indexed so far, reading only files whose
+/// content changed. Settings changed in the environment since they were
+/// last read are applied first, re-parsing as much as they require.
+/// Queries are served from the current index meanwhile.
+pub async fn reindex_roots() {
+    let pipeline = get_indexing_pipeline();
+    let changes = reload_pipeline_settings(&mut *pipeline.lock().await);
+    for change in &changes {
+        match change.reindex {
+            ReindexScope::None => tracing::warn!(
+                "Setting {} changed; it takes effect after a restart",
+                change.setting
+            ),
+            scope => tracing::info!(
+                "Setting {} changed (re-index: {})",
+                change.setting,
+                scope.as_str()
+            ),
+        }
+    }
+    let scope = reindex_scope(&changes);
+    for root in PathResolver::indexed_roots() {
+        let result = match scope {
+            ReindexScope::None => pipeline.lock().await.rescan_directory(&root).await,
+            scope => {
+                let (result, _) = pipeline.lock().await.reindex_directory(&root, scope).await;
+                result
+            }
+        };
+        tracing::info!(
+            "Re-indexed {:?}: {} files, {} symbols, {} errors in {}ms",
+            root,
+            result.files_processed,
+            result.symbols_found,
+            result.errors.len(),
+            result.duration_ms
+        );
+    }
+}
+
+/// Apply the settings changed since they were last read. Indexed
+/// directories are re-indexed as far as the widest change requires and not
+/// at all for changes that leave the index alone; settings read only at
+/// startup are reported as needing a restart.
+pub async fn reload_index_settings() -> ReloadConfigResponse {
+    let pipeline = get_indexing_pipeline();
+    let mut pipeline = pipeline.lock().await;
+    let (requires_restart, changed): (Vec<_>, Vec<_>) = reload_pipeline_settings(&mut pipeline)
+        .into_iter()
+        .partition(|change| change.reindex == ReindexScope::None);
+    let reindex = reindex_scope(&changed);
+    let mut roots = Vec::new();
+    if reindex != ReindexScope::None {
+        for root in PathResolver::indexed_roots() {
+            let (result, files_removed) = pipeline.reindex_directory(&root, reindex).await;
+            roots.push(RootReindex {
+                root,
+                files_indexed: result.files_processed,
+                files_removed: files_removed as u32,
+                symbols_found: result.symbols_found,
+                errors: result.errors,
+                duration_ms: result.duration_ms,
+            });
+        }
+    }
+    ReloadConfigResponse {
+        changed,
+        requires_restart,
+        reindex,
+        roots,
+    }
+}
+
+/// Re-index the indexed directories whenever the process receives SIGHUP,
+/// for deployments that update code without a file watcher. Fails on
+/// platforms without SIGHUP.
+pub fn enable_reindex_on_sighup() -> Result<(), String> {
+    spawn_sighup_handler(reindex_roots)
+}
+
 fn get_file_watchers() -> Arc<tokio::sync::Mutex<HashMap<PathBuf, Arc<FileWatcher>>>> {
     FILE_WATCHERS
         .get_or_init(|| Arc::new(tokio::sync::Mutex::new(HashMap::new())))
@@ -76,11 +336,133 @@ pub struct IndexCodeResponse {
     pub symbols_found: u32,
     pub errors: Vec<String>,
     pub duration_ms: u64,
+    /// With `base_ref`: files that differ from the ref, and those removed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub files_changed: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub files_removed: Option<u32>,
+    /// Why a `base_ref` request indexed the whole tree instead
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub full_index_reason: Option<String>,
+    /// Where the index was written when `snapshot_path` was given
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub snapshot_path: Option<String>,
+}
+
+#[derive(Debug, Default, Deserialize)]
+pub struct ReloadConfigRequest {
+    /// Settings to change before reloading, by environment variable name;
+    /// `null` unsets one
+    #[serde(default)]
+    pub settings: BTreeMap<String, Option<String>>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ReloadConfigResponse {
+    /// Settings whose value changed since they were last read, now applied
+    pub changed: Vec<SettingChange>,
+    /// Settings whose value changed but that are read once at startup, so
+    /// take effect when the server restarts
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub requires_restart: Vec<SettingChange>,
+    /// The re-indexing the changes required
+    pub reindex: ReindexScope,
+    /// One entry per indexed directory when re-indexing ran
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub roots: Vec<RootReindex>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RootReindex {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub root: PathBuf,
+    pub files_indexed: u32,
+    /// Files dropped because they are no longer selected, or were deleted
+    pub files_removed: u32,
+    pub symbols_found: u32,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub errors: Vec<String>,
+    pub duration_ms: u64,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct MergeIndexesResponse {
+    #[serde(flatten)]
+    pub report: MergeReport,
+    pub loaded: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub output_path: Option<String>,
+    pub duration_ms: u64,
 }
 
 #[derive(Debug, Serialize, Deserialize)]
 pub struct GetSymbolResponse {
-    pub symbols: Vec<Symbol>,
+    pub symbols: Vec<BlamedSymbol>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct BlamedSymbol {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// The last commit touching the definition's lines, with `include_blame`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub blame: Option<BlameInfo>,
+    /// Go type declarations: alias or defined type, and its method set
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_identity: Option<TypeIdentity>,
+    /// Package-level Go vars: the type of the var, from its declaration or
+    /// the function its initializer calls
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub inferred_type: Option<InferredVarType>,
+    /// With `max_source_bytes`, where the returned source stops in a
+    /// definition longer than that and the cursor for the rest
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub source_chunk: Option<SourceChunk>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetSourceChunkResponse {
+    pub symbol_id: u64,
+    pub name: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub source: String,
+    #[serde(flatten)]
+    pub chunk: SourceChunk,
+}
+
+/// Whether a Go type name is an alias (`type T = U`), the same type as U
+/// with U's methods, or a defined type (`type T U`), a new type with U's
+/// underlying type and only the methods declared on T
+#[derive(Debug, Serialize, Deserialize)]
+pub struct TypeIdentity {
+    pub form: TypeForm,
+    /// The type written on the right; omitted for struct and interface literals
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub type_expr: Option<String>,
+    /// The indexed type an alias resolves to, or the package type a defined
+    /// type is declared from
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub target: Option<SymbolAlias>,
+    /// Methods callable on values of the type, from its package
+    pub method_set: Vec<String>,
+}
+
+/// The type of a package-level Go var. A declared type, a literal or a
+/// well-known standard library call decide it directly; otherwise it is the
+/// result type of the indexed function the initializer calls.
+#[derive(Debug, Serialize, Deserialize)]
+pub struct InferredVarType {
+    /// As written in Go, or `unknown` when the initializer calls something
+    /// not indexed
+    #[serde(rename = "type")]
+    pub type_name: String,
+    /// The call initializing the var, as written
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub initializer: Option<String>,
+    /// The indexed function or type the call resolved to
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub resolved: Option<SymbolAlias>,
 }
 
 #[derive(Debug, Serialize, Deserialize)]
@@ -88,30 +470,250 @@ pub struct GetSymbolReferencesResponse {
     pub references: Vec<Reference>,
 }
 
+/// A search hit with the characters of its name that matched the query
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SymbolMatch {
+    #[serde(flatten)]
+    pub symbol: Symbol,
+    /// The name matched in qualified mode: `PostgresConnection.ExecuteQuery`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub qualified_name: Option<String>,
+    /// `[start, end)` character offsets within the symbol name, or within
+    /// `qualified_name` when set
+    pub match_ranges: Vec<(usize, usize)>,
+    /// Aliases and re-exports of this symbol that also matched
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub aliases: Vec<SymbolAlias>,
+    /// Set when the symbol only matched a synonym of a query term; such
+    /// results follow the direct matches
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub synonym: Option<MatchedSynonym>,
+}
+
+/// An alias or re-export folded into the symbol it stands for
+#[derive(Debug, Serialize, Deserialize)]
+pub struct SymbolAlias {
+    pub id: u64,
+    pub name: String,
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub line: u32,
+}
+
 #[derive(Debug, Serialize, Deserialize)]
 pub struct FindSymbolsResponse {
-    pub symbols: Vec<Symbol>,
+    pub symbols: Vec<SymbolMatch>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct PackageInfo {
+    pub name: String,
+    pub symbol_count: usize,
+    pub file_count: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListPackagesResponse {
+    pub packages: Vec<PackageInfo>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct FileDiagnostic {
+    pub file_path: String,
+    pub file_size: u64,
+    pub reason: String,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct IndexDiagnosticsResponse {
+    pub files_indexed: usize,
+    pub max_file_size_bytes: u64,
+    pub skipped: Vec<FileDiagnostic>,
+    pub failed: Vec<FileDiagnostic>,
+    pub partial: Vec<FileDiagnostic>,
+    /// Hit/miss counters of the rendered definition cache used by `get_symbol`
+    pub definition_cache: DefinitionCacheStats,
+    /// How vendored code is indexed: include, separate or skip
+    pub vendor_mode: String,
+    pub first_party: SourceCounts,
+    /// Files under the vendor prefixes, whatever the mode
+    pub vendored: SourceCounts,
+}
+
+#[derive(Debug, Default, Serialize, Deserialize)]
+pub struct SourceCounts {
+    pub files: usize,
+    pub symbols: usize,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RecentSymbol {
+    pub file_modified: String,
+    /// With `use_git`: the last commit touching the definition's lines
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub last_change: Option<BlameInfo>,
+    #[serde(flatten)]
+    pub symbol: Symbol,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ListRecentSymbolsResponse {
+    pub since: String,
+    pub files_changed: usize,
+    pub symbols: Vec<RecentSymbol>,
+    pub truncated: bool,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ListRecentSymbolsRequest {
+    /// RFC 3339 timestamp, Unix seconds, or a relative duration such as `24h`
+    pub since: String,
+    /// Maximum number of symbols to return (default: 100, max: 1000)
+    pub limit: Option<u32>,
+    /// Signature rendering: full, compact or name_only (default: full)
+    pub signature_style: Option<String>,
+    /// Use the date of the last commit touching each symbol instead of its
+    /// file's modification time (default: false)
+    pub use_git: Option<bool>,
 }
 
 #[derive(Debug, Deserialize, Serialize, JsonSchema)]
 pub struct IndexCodeRequest {
     /// Path to directory or file to index
     pub path: String,
+    /// Only index files changed since this git ref (branch, tag or commit)
+    pub base_ref: Option<String>,
+    /// Also write the resulting index to this file, for `merge_indexes`
+    pub snapshot_path: Option<String>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct MergeIndexesRequest {
+    /// Index files written by `index_code` with `snapshot_path`
+    pub snapshots: Vec<String>,
+    /// Write the merged index to this file
+    pub output_path: Option<String>,
+    /// Replace the server's index with the merged one (default: true)
+    pub load: Option<bool>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct DiffPublicApiRequest {
+    /// Index of the old version, written by `index_code` with `snapshot_path`
+    pub old_snapshot: Option<String>,
+    /// Git branch, tag or commit of the old version, instead of `old_snapshot`
+    pub old_ref: Option<String>,
+    /// Index of the new version (default: the current index)
+    pub new_snapshot: Option<String>,
+    /// Git branch, tag or commit of the new version, instead of `new_snapshot`
+    pub new_ref: Option<String>,
+    /// Report layout: markdown or text (default: markdown)
+    pub format: Option<String>,
+    /// Report heading (default: "Public API changes")
+    pub title: Option<String>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct DiffPublicApiResponse {
+    pub added: usize,
+    pub removed: usize,
+    pub changed: usize,
+    /// Removed symbols and changes that break existing callers
+    pub breaking: usize,
+    pub report: String,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetApiHashRequest {
+    /// Package directory, or a package name as listed by list_packages
+    pub package: String,
+    /// Git branch, tag or commit to hash the package at (default: the
+    /// current index)
+    #[serde(rename = "ref")]
+    pub rev: Option<String>,
+    /// Also return the lines the hash is computed from (default: false)
+    pub include_surface: Option<bool>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct GetApiHashResponse {
+    pub package: String,
+    #[serde(rename = "ref", skip_serializing_if = "Option::is_none")]
+    pub rev: Option<String>,
+    /// SHA-256 hex digest of the public API surface
+    pub hash: String,
+    /// Public symbols hashed
+    pub symbols: usize,
+    /// One line per public symbol: kind, qualified name and signature shape
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub surface: Option<Vec<String>>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct ValidateIndexRequest {
+    /// Drop dangling reference edges and stale name entries after checking
+    /// (default: false, read-only)
+    pub repair: Option<bool>,
+    /// Maximum number of issues to return (default: 100)
+    pub limit: Option<u32>,
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ValidateIndexResponse {
+    /// No inconsistency was found
+    pub healthy: bool,
+    pub symbols_checked: usize,
+    pub references_checked: usize,
+    pub files_checked: usize,
+    /// Issues found by kind
+    pub counts: BTreeMap<IssueKind, usize>,
+    pub issues: Vec<IndexIssue>,
+    pub total_issues: usize,
+    /// With `repair`: the edges and entries dropped
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub repaired: Option<usize>,
+    /// With `repair`: the issues left, which repair does not fix
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub remaining_issues: Option<usize>,
 }
 
 #[derive(Debug, Deserialize, Serialize, JsonSchema)]
 pub struct GetSymbolRequest {
-    /// Name of the symbol to search for
+    /// Name of the symbol to search for (qualified names like `pkg.Name` are supported)
     pub name: String,
     /// Include source code in the response
     #[serde(default)]
     pub include_source: Option<bool>,
+    /// With source, include definition bodies (default: true). false keeps
+    /// only function signatures and type headers with their fields collapsed
+    pub body: Option<bool>,
+    /// Signature rendering: full, compact or name_only (default: full)
+    pub signature_style: Option<String>,
+    /// Symbol fields to return (default: all); listing `source` includes it
+    pub fields: Option<Vec<String>>,
+    /// Add the last commit, author and date touching each definition
+    pub include_blame: Option<bool>,
+    /// With source, cut definitions longer than this many bytes at a line
+    /// break and return a `source_chunk` cursor for `get_source_chunk`
+    /// (default: no limit)
+    pub max_source_bytes: Option<u32>,
+}
+
+#[derive(Debug, Deserialize, Serialize, JsonSchema)]
+pub struct GetSourceChunkRequest {
+    /// The `next_cursor` of the previous chunk
+    pub cursor: String,
+    /// Maximum bytes to return, cut at a line break (default: 32768)
+    pub max_bytes: Option<u32>,
 }
 
 #[derive(Debug, Deserialize, Serialize, JsonSchema)]
 pub struct GetSymbolReferencesRequest {
     /// Name of the symbol to find references for
     pub name: String,
+    /// Also return references made through aliases and re-exports of the
+    /// symbol (default: false)
+    pub include_aliases: Option<bool>,
 }
 
 #[derive(Debug, Deserialize, Serialize, JsonSchema)]
@@ -124,6 +726,16 @@ pub struct CodeSearchRequest {
     pub max_results: Option<u32>,
     /// Number of lines of context around matches
     pub context_lines: Option<u32>,
+    /// Result fields to return (default: all)
+    pub fields: Option<Vec<String>>,
+    /// Also search with configured synonyms of query terms (default: true)
+    pub use_synonyms: Option<bool>,
+    /// Drop trailing results once the response would exceed this many
+    /// estimated tokens
+    pub max_tokens: Option<u32>,
+    /// Also search vendored code kept apart from first-party code
+    /// (default: false)
+    pub include_vendor: Option<bool>,
 }
 
 #[derive(Debug, Serialize, Deserialize)]
@@ -138,24 +750,360 @@ pub struct FindSymbolsRequest {
     pub query: String,
     /// Optional symbol type filter
     pub symbol_type: Option<String>,
+    /// Optional package/module/namespace filter
+    pub namespace: Option<String>,
+    /// Optional tag filter: `key:value` or `key`; `build:linux,amd64` scopes
+    /// to a build context
+    pub tag: Option<String>,
+    /// Optional annotation, decorator or attribute filter: `@Deprecated` for
+    /// presence, `@stability=experimental` or `@json=*_*` for a value
+    pub attribute: Option<String>,
     /// Maximum number of results to return (default: 10, max: 50)
     pub limit: Option<u32>,
+    /// Signature rendering: full, compact or name_only (default: full)
+    pub signature_style: Option<String>,
+    /// Result fields to return (default: all): symbol fields plus
+    /// `match_ranges`, `aliases` and `synonym`
+    pub fields: Option<Vec<String>>,
+    /// Return aliases and re-exports as results of their own instead of
+    /// folding them into the symbol they stand for (default: false)
+    pub include_aliases: Option<bool>,
+    /// Also search with configured synonyms of query terms (default: true)
+    pub use_synonyms: Option<bool>,
+    /// Drop trailing results once the response would exceed this many
+    /// estimated tokens
+    pub max_tokens: Option<u32>,
+    /// Answer from the index of this git branch, tag or commit instead of
+    /// the working tree
+    #[serde(rename = "ref")]
+    pub git_ref: Option<String>,
+    /// Also return symbols of vendored code kept apart from first-party
+    /// code (default: false)
+    pub include_vendor: Option<bool>,
+    /// Fuzzy match against qualified names, `Type.Method` and
+    /// `package.Func`, instead of bare names (default: false)
+    pub qualified: Option<bool>,
 }
 
+/// Fields of a `CodeSearchResult`
+const CODE_SEARCH_FIELDS: &[&str] = &[
+    "score",
+    "file_path",
+    "language",
+    "content_snippet",
+    "synonym",
+];
+
 #[derive(Debug, Serialize, Deserialize)]
 pub struct CodeSearchResult {
     pub score: f32,
     pub file_path: String,
     pub language: String,
     pub content_snippet: String,
+    /// Set when the file only matched a synonym of a query term; such
+    /// results follow the direct matches
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub synonym: Option<MatchedSynonym>,
+}
+
+/// Parse the `signature_style` argument shared by symbol-returning tools
+fn parse_signature_style(style: Option<&str>) -> Result<SignatureStyle, ErrorData> {
+    match style {
+        None => Ok(SignatureStyle::default()),
+        Some(name) => SignatureStyle::from_name(name).ok_or_else(|| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Invalid signature_style '{}': expected full, compact or name_only",
+                    name
+                ),
+                None,
+            )
+        }),
+    }
+}
+
+/// Render symbol signatures in the requested style
+fn apply_signature_style(symbols: &mut [Symbol], style: SignatureStyle) {
+    for symbol in symbols {
+        if let Some(signature) = &symbol.signature {
+            symbol.signature = Some(signature.styled(&symbol.name, style));
+        }
+    }
+}
+
+/// Serialize a response, keeping only the projected fields of the entries in
+/// its `list` array and, with a `max_tokens` budget, as many of them as fit
+fn projected_response<T: Serialize>(
+    response: &T,
+    list: &str,
+    projection: &FieldProjection,
+    max_tokens: Option<u32>,
+) -> Result<CallToolResult, ErrorData> {
+    let serialization_error = |e: serde_json::Error| {
+        ErrorData::new(
+            ErrorCode::INTERNAL_ERROR,
+            format!("Serialization error: {}", e),
+            None,
+        )
+    };
+    let mut value = serde_json::to_value(response).map_err(serialization_error)?;
+    if let Some(results) = value.get_mut(list) {
+        projection.apply(results);
+    }
+    if let Some(max_tokens) = max_tokens {
+        fit_to_budget(&mut value, &[list], max_tokens as usize);
+    }
+    encode_response(&value)
+}
+
+/// Fold aliases and re-exports among `symbols` into the symbol they stand
+/// for. That symbol leads its group when it matched too, otherwise the
+/// first-ranked alias does; groups keep the rank of their best member.
+fn fold_aliases(store: &SymbolStore, symbols: Vec<Symbol>) -> Vec<(Symbol, Vec<SymbolAlias>)> {
+    let keys: Vec<SymbolId> = symbols
+        .iter()
+        .map(|symbol| {
+            store
+                .resolve_alias(symbol)
+                .map_or(symbol.id, |target| target.id)
+        })
+        .collect();
+    let by_id: HashMap<SymbolId, &Symbol> = symbols.iter().map(|s| (s.id, s)).collect();
+
+    let mut groups: Vec<(Symbol, Vec<SymbolAlias>)> = Vec::new();
+    let mut group_of: HashMap<SymbolId, usize> = HashMap::new();
+    for (symbol, key) in symbols.iter().zip(keys) {
+        let index = *group_of.entry(key).or_insert_with(|| {
+            let primary = by_id.get(&key).copied().unwrap_or(symbol);
+            groups.push((primary.clone(), Vec::new()));
+            groups.len() - 1
+        });
+        if groups[index].0.id != symbol.id {
+            groups[index].1.push(SymbolAlias {
+                id: symbol.id.0,
+                name: symbol.name.clone(),
+                file: symbol.location.file.clone(),
+                line: symbol.location.start_line,
+            });
+        }
+    }
+    groups
+}
+
+/// Alias or defined-type identity of a Go type symbol. The method set is
+/// gathered from the symbol's package: receiver methods from the index and
+/// interface methods from the package's interface declarations.
+async fn go_type_identity(store: &SymbolStore, symbol: &Symbol) -> Option<TypeIdentity> {
+    let form = TypeForm::of(symbol)?;
+    let directory = symbol.location.file.parent()?;
+
+    let mut package: Vec<Symbol> = Vec::new();
+    let mut types: HashMap<String, GoTypeDecl> = HashMap::new();
+    let mut declared: HashMap<String, Vec<String>> = HashMap::new();
+    let mut interface_files: Vec<PathBuf> = Vec::new();
+    for entry in store.symbol_data.iter() {
+        let other = entry.value();
+        if other.location.file.parent() != Some(directory) {
+            continue;
+        }
+        if let Some(form) = TypeForm::of(other) {
+            let interface = other.symbol_type == SymbolType::Interface;
+            if interface && !interface_files.contains(&other.location.file) {
+                interface_files.push(other.location.file.clone());
+            }
+            types.insert(
+                other.name.clone(),
+                GoTypeDecl {
+                    form,
+                    type_expr: other.tags.get(TYPE_EXPR_TAG).cloned(),
+                    interface,
+                },
+            );
+            package.push(other.clone());
+        } else if other.symbol_type == SymbolType::Method {
+            if let Some(type_name) = receiver_type(other) {
+                declared
+                    .entry(type_name)
+                    .or_default()
+                    .push(other.name.clone());
+            }
+        }
+    }
+    for file in interface_files {
+        let Ok(content) = tokio::fs::read_to_string(&file).await else {
+            continue;
+        };
+        let Ok(members) = extract_type_members(&content, Language::Go) else {
+            continue;
+        };
+        for interface in members.into_iter().filter(|t| t.kind == "interface") {
+            declared.insert(
+                interface.name,
+                interface.methods.into_iter().map(|m| m.name).collect(),
+            );
+        }
+    }
+
+    let type_expr = symbol.tags.get(TYPE_EXPR_TAG).cloned();
+    let target = match form {
+        TypeForm::Alias => store.resolve_alias(symbol),
+        TypeForm::Defined => type_expr
+            .as_deref()
+            .and_then(|expr| package.iter().find(|other| other.name == expr))
+            .cloned(),
+    }
+    .map(|target| SymbolAlias {
+        id: target.id.0,
+        name: target.name,
+        file: target.location.file,
+        line: target.location.start_line,
+    });
+
+    Some(TypeIdentity {
+        form,
+        type_expr,
+        target,
+        method_set: go_method_set(&types, &declared, &symbol.name),
+    })
+}
+
+/// The type of a package-level Go var, resolving its initializer call
+/// through the index when the declaration does not show it
+fn go_var_type(store: &SymbolStore, symbol: &Symbol) -> Option<InferredVarType> {
+    let initializer = symbol.tags.get(VAR_INIT_TAG).cloned();
+    if let Some(var_type) = symbol.tags.get(VAR_TYPE_TAG) {
+        return Some(InferredVarType {
+            type_name: var_type.clone(),
+            initializer,
+            resolved: None,
+        });
+    }
+    let callee = initializer.as_deref()?;
+    let name = callee.rsplit('.').next().unwrap_or(callee);
+    let candidates = store.get_symbols(name);
+    let (type_name, resolved) = match resolve_var_init(symbol, &candidates) {
+        Some((type_name, target)) => (
+            type_name,
+            Some(SymbolAlias {
+                id: target.id.0,
+                name: target.name.clone(),
+                file: target.location.file.clone(),
+                line: target.location.start_line,
+            }),
+        ),
+        None => (UNKNOWN_TYPE.to_string(), None),
+    };
+    Some(InferredVarType {
+        type_name,
+        initializer,
+        resolved,
+    })
+}
+
+/// Error code for a request abandoned because it was cancelled, the
+/// JSON-RPC `RequestCancelled` code LSP also uses
+pub const REQUEST_CANCELLED: ErrorCode = ErrorCode(-32800);
+
+/// Error returned when a query is abandoned because the request was cancelled
+pub(crate) fn cancelled_error(error: CodeAnalysisError) -> ErrorData {
+    let data = match &error {
+        CodeAnalysisError::Cancelled { operation } => {
+            json!({"cancelled": true, "operation": operation})
+        }
+        _ => json!({"cancelled": true}),
+    };
+    ErrorData::new(REQUEST_CANCELLED, error.to_string(), Some(data))
+}
+
+/// Blame of indexed files, run once per file and cached until the file
+/// changes. Files outside git are left out.
+pub(crate) async fn blame_files(files: Vec<PathBuf>) -> HashMap<PathBuf, Arc<FileBlame>> {
+    let store = get_symbol_store();
+    let files: Vec<(PathBuf, [u8; 32])> = files
+        .into_iter()
+        .filter_map(|file| {
+            let hash = store.get_file_info(&file)?.content_hash;
+            Some((file, hash))
+        })
+        .collect();
+
+    // git runs synchronously, off the async workers
+    tokio::task::spawn_blocking(move || {
+        let cache = BLAME_CACHE.get_or_init(BlameCache::new);
+        files
+            .into_iter()
+            .filter_map(|(file, hash)| cache.blame(&file, hash).map(|blame| (file, blame)))
+            .collect()
+    })
+    .await
+    .unwrap_or_default()
+}
+
+/// The last commit touching each symbol's definition; symbols in files
+/// outside git are left out.
+async fn blame_symbols(symbols: &[Symbol]) -> HashMap<SymbolId, BlameInfo> {
+    let files: HashSet<PathBuf> = symbols
+        .iter()
+        .map(|symbol| symbol.location.file.clone())
+        .collect();
+    let blames = blame_files(files.into_iter().collect()).await;
+    symbols
+        .iter()
+        .filter_map(|symbol| {
+            let info = blames
+                .get(&symbol.location.file)?
+                .last_change(symbol.location.start_line, symbol.location.end_line)?;
+            Some((symbol.id, info.clone()))
+        })
+        .collect()
+}
+
+/// BM25 search for the best `limit` files, leaving out vendored code kept
+/// apart unless `include_vendor` is set. The search window widens until
+/// enough first-party files are found or the matches run out. Snippets are
+/// only extracted with `context_lines`.
+fn search_code(
+    store: &SymbolStore,
+    query: &str,
+    limit: usize,
+    context_lines: Option<usize>,
+    include_vendor: bool,
+    cancel: &CancellationToken,
+) -> Result<Vec<crate::search::CodeSearchResult>, ErrorData> {
+    let mut window = limit;
+    loop {
+        let mut results = store
+            .search_code_cancellable(query, window, context_lines, cancel)
+            .map_err(cancelled_error)?;
+        let exhausted = results.len() < window;
+        if !include_vendor {
+            results.retain(|result| !vendor_paths().separates(&result.file_path));
+        }
+        if include_vendor || exhausted || results.len() >= limit {
+            results.truncate(limit);
+            return Ok(results);
+        }
+        window = window.saturating_mul(2);
+    }
 }
 
+/// The MCP server. Each connection needs its own, made with `new`; clones
+/// share the connection's change subscriptions.
 #[derive(Clone, Debug, Default)]
-pub struct CodeAnalysisTools;
+pub struct CodeAnalysisTools {
+    session: SessionId,
+}
 
 impl CodeAnalysisTools {
     pub fn new() -> Self {
-        Self
+        Self::default()
+    }
+
+    /// Cancel the connection's change subscriptions once its service has
+    /// stopped
+    pub fn end_session(&self) {
+        end_session(self.session);
     }
 }
 
@@ -165,6 +1113,14 @@ impl ServerHandler for CodeAnalysisTools {
             capabilities: ServerCapabilities::builder()
                 .enable_tools()
                 .enable_prompts()
+                // Index changes reach subscribers as log messages
+                .enable_logging()
+                .enable_experimental_with(
+                    ResponseEncoding::experimental_capabilities()
+                        .into_iter()
+                        .chain(KindMap::experimental_capabilities())
+                        .collect(),
+                )
                 .build(),
             instructions: Some(
                 "Roberto MCP server for analyzing source code symbols and references"
@@ -189,6 +1145,14 @@ impl ServerHandler for CodeAnalysisTools {
                         "path": {
                             "type": "string",
                             "description": "Path to directory or file to index"
+                        },
+                        "base_ref": {
+                            "type": "string",
+                            "description": "Only index files changed since this git ref (branch, tag or commit), including renames, deletions and untracked files. Falls back to a full index outside a git checkout"
+                        },
+                        "snapshot_path": {
+                            "type": "string",
+                            "description": "Also write the resulting index to this file so it can be combined with merge_indexes"
                         }
                     },
                     "required": ["path"]
@@ -198,6 +1162,34 @@ impl ServerHandler for CodeAnalysisTools {
                 icons: None,
                 title: None,
             },
+            Tool {
+                name: "merge_indexes".into(),
+                description: Some("Merge index snapshots built over disjoint parts of one checkout (e.g. by parallel CI workers running index_code with snapshot_path) into one index. References between shards are resolved in a final pass; files indexed by more than one shard are reported as conflicts and kept from the first snapshot listed. Shards must be indexed from the same absolute checkout path with the same settings.".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "snapshots": {
+                            "type": "array",
+                            "items": { "type": "string" },
+                            "description": "Snapshot files to merge, in priority order"
+                        },
+                        "output_path": {
+                            "type": "string",
+                            "description": "Write the merged index to this file"
+                        },
+                        "load": {
+                            "type": "boolean",
+                            "description": "Replace the server's index with the merged one (default: true)",
+                            "default": true
+                        }
+                    },
+                    "required": ["snapshots"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
             Tool {
                 name: "get_symbol".into(),
                 description: Some("Retrieve symbol information by name with optional source code inclusion".into()),
@@ -206,12 +1198,38 @@ impl ServerHandler for CodeAnalysisTools {
                     "properties": {
                         "name": {
                             "type": "string",
-                            "description": "Name of the symbol to search for"
+                            "description": "Name of the symbol to search for (qualified names like 'pkg.Name' are supported)"
                         },
                         "include_source": {
                             "type": "boolean",
                             "description": "Include source code in the response",
                             "default": false
+                        },
+                        "body": {
+                            "type": "boolean",
+                            "description": "With source, include definition bodies. false returns only declarations: full function signatures, including multi-line ones, and type headers with fields collapsed to { ... }. Constants and variables are unaffected",
+                            "default": true
+                        },
+                        "signature_style": {
+                            "type": "string",
+                            "enum": ["full", "compact", "name_only"],
+                            "description": "How to render function signatures (default: full)",
+                            "default": "full"
+                        },
+                        "fields": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": SYMBOL_FIELDS.iter().chain(&["blame", "type_identity", "inferred_type"]).collect::<Vec<_>>()},
+                            "description": "Symbol fields to return (default: all), e.g. [\"name\", \"symbol_type\", \"location\"]. Listing source includes it without include_source"
+                        },
+                        "include_blame": {
+                            "type": "boolean",
+                            "description": "Add git blame for each definition: the last commit, author, date and summary touching its lines. Omitted for files outside git",
+                            "default": false
+                        },
+                        "max_source_bytes": {
+                            "type": "integer",
+                            "description": "With source, cut definitions longer than this many bytes at a line break and add a source_chunk with the total size and a next_cursor for get_source_chunk (default: no limit)",
+                            "minimum": 1
                         }
                     },
                     "required": ["name"]
@@ -223,13 +1241,18 @@ impl ServerHandler for CodeAnalysisTools {
             },
             Tool {
                 name: "get_symbol_references".into(),
-                description: Some("Find all references to a symbol (usages, imports, calls)".into()),
+                description: Some("Find all references to a symbol (usages, imports, calls) as raw reference records, sorted by position. Kept for existing clients; prefer find_references, which returns the same references with snippets, the enclosing symbol, kind and path filters and lookup by location".into()),
                 input_schema: Arc::new(serde_json::from_value(json!({
                     "type": "object",
                     "properties": {
                         "name": {
                             "type": "string",
                             "description": "Name of the symbol to find references for"
+                        },
+                        "include_aliases": {
+                            "type": "boolean",
+                            "description": "Also return references made through aliases and re-exports of the symbol, such as uses of T for Go `type T = U`. Defined types (`type T U`) are distinct types and never included",
+                            "default": false
                         }
                     },
                     "required": ["name"]
@@ -251,7 +1274,19 @@ impl ServerHandler for CodeAnalysisTools {
                         },
                         "symbol_type": {
                             "type": "string",
-                            "description": "Optional symbol type filter (function, class, struct, enum, interface, constant, variable, module, import)"
+                            "description": "Optional symbol type filter (function, class, struct, enum, interface, constant, variable, module, import, test)"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "tag": {
+                            "type": "string",
+                            "description": "Optional tag filter, e.g. 'owner:payments-team' or 'stability'; 'build:linux,amd64' keeps symbols that exist in that Go build"
+                        },
+                        "attribute": {
+                            "type": "string",
+                            "description": "Optional annotation, decorator or attribute filter: '@Deprecated' or '@login_required' keeps symbols that carry it, '@stability=experimental' or '@json=*_*' those whose value, or an item of it, matches ('*' matches anything). Covers Java, Kotlin and Scala annotations, Python and TypeScript decorators, Rust, C#, PHP and Swift attributes, Go directive comments (@nolint, @go:noinline) and Go struct tags by key, and doc comment tags. Combine with an empty query to list every match"
                         },
                         "limit": {
                             "type": "integer",
@@ -259,42 +1294,1832 @@ impl ServerHandler for CodeAnalysisTools {
                             "default": 10,
                             "minimum": 1,
                             "maximum": 50
-                        }
-                    },
-                    "required": ["query"]
-                })).unwrap()),
-                output_schema: None,
-                annotations: None,
-                icons: None,
-                title: None,
+                        },
+                        "signature_style": {
+                            "type": "string",
+                            "enum": ["full", "compact", "name_only"],
+                            "description": "How to render function signatures (default: full)",
+                            "default": "full"
+                        },
+                        "fields": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": SYMBOL_FIELDS.iter().chain(&["match_ranges", "aliases", "synonym", "qualified_name"]).collect::<Vec<_>>()},
+                            "description": "Result fields to return (default: all), e.g. [\"name\", \"symbol_type\", \"location\"]"
+                        },
+                        "include_aliases": {
+                            "type": "boolean",
+                            "description": "Return type aliases and re-exports as results of their own. By default they are folded into the symbol they stand for and listed in its aliases",
+                            "default": false
+                        },
+                        "use_synonyms": {
+                            "type": "boolean",
+                            "description": "Also search with the synonyms configured in ROBERTO_SYNONYMS for query terms. Results found only through a synonym come after direct matches and name the synonym (default: true)",
+                            "default": true
+                        },
+                        "max_tokens": {
+                            "type": "integer",
+                            "description": "Token budget for the response, estimated at 4 characters per token. Trailing results are dropped to fit and the response gets truncated: true and the number omitted",
+                            "minimum": 1
+                        },
+                        "ref": {
+                            "type": "string",
+                            "description": "Git branch, tag or commit to answer from instead of the working tree, e.g. v1.2.0. The indexed directories are indexed at that commit from the git object store on first use, without a checkout, and kept in a small cache"
+                        },
+                        "include_vendor": {
+                            "type": "boolean",
+                            "description": "Also return vendored third-party code. With ROBERTO_VENDOR=separate, files under the vendor prefixes (vendor/, node_modules/, third_party/, $GOPATH/pkg/mod by default) are left out of searches unless this is set (default: false)",
+                            "default": false
+                        },
+                        "qualified": {
+                            "type": "boolean",
+                            "description": "Fuzzy match against qualified names instead of bare names: Type.Method for methods, package.Func for everything else, so PstgrExec finds PostgresConnection.ExecuteQuery. Narrows by owner and member in one query but ranks results quite differently; each result gets qualified_name and match_ranges within it (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["query"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "code_search".into(),
+                description: Some("Search through indexed code content using BM25 statistical search".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "query": {
+                            "type": "string",
+                            "description": "Search query for code content"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of results to return",
+                            "default": 10
+                        },
+                        "max_results": {
+                            "type": "integer", 
+                            "description": "Alias for limit - maximum number of results to return",
+                            "default": 10
+                        },
+                        "context_lines": {
+                            "type": "integer",
+                            "description": "Number of lines of context around matches (default: 2)",
+                            "default": 2
+                        },
+                        "fields": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": CODE_SEARCH_FIELDS},
+                            "description": "Result fields to return (default: all), e.g. [\"file_path\", \"score\"]"
+                        },
+                        "use_synonyms": {
+                            "type": "boolean",
+                            "description": "Also search with the synonyms configured in ROBERTO_SYNONYMS for query terms. Results found only through a synonym come after direct matches and name the synonym (default: true)",
+                            "default": true
+                        },
+                        "max_tokens": {
+                            "type": "integer",
+                            "description": "Token budget for the response, estimated at 4 characters per token. Trailing results are dropped to fit and the response gets truncated: true and the number omitted",
+                            "minimum": 1
+                        },
+                        "include_vendor": {
+                            "type": "boolean",
+                            "description": "Also return vendored third-party code. With ROBERTO_VENDOR=separate, files under the vendor prefixes (vendor/, node_modules/, third_party/, $GOPATH/pkg/mod by default) are left out of searches unless this is set (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["query"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_file_outline".into(),
+                description: Some("Get compact symbol outline for a specific file grouped by type".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "file_path": {
+                            "type": "string",
+                            "description": "Path to the file to outline"
+                        },
+                        "format": {
+                            "type": "string",
+                            "enum": ["text", "lsp"],
+                            "description": "text (default) for a compact outline grouped by type, or lsp for an LSP DocumentSymbol[] tree with 0-based UTF-16 positions",
+                            "default": "text"
+                        },
+                        "include_sections": {
+                            "type": "boolean",
+                            "description": "Also list section comments such as '// Database implementations' that group the declarations after them; in lsp format symbols are nested under their section (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["file_path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_directory_outline".into(),
+                description: Some("Get compact directory structure showing classes by default, with optional symbol type includes".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "directory_path": {
+                            "type": "string",
+                            "description": "Path to directory to outline"
+                        },
+                        "includes": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Optional symbol types to include: methods, constants, functions, enums",
+                            "default": []
+                        }
+                    },
+                    "required": ["directory_path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_directory_symbols".into(),
+                description: Some("Get the outline of every indexed source file in a directory in one call, keyed by relative path, with per-file symbol counts. Outlines are LSP DocumentSymbol trees; ignored files are left out".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "directory_path": {
+                            "type": "string",
+                            "description": "Path to the directory"
+                        },
+                        "recursive": {
+                            "type": "boolean",
+                            "description": "Include files in subdirectories (default: false, only files directly under the directory)",
+                            "default": false
+                        },
+                        "max_tokens": {
+                            "type": "integer",
+                            "description": "Token budget for the response, estimated at 4 characters per token. Trailing files are dropped to fit and the response gets truncated: true and the number omitted",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["directory_path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_recent_symbols".into(),
+                description: Some("List symbols from files modified after a given time, most recently modified files first. With use_git, symbols whose own lines were last committed after that time, newest commit first, with the commit that changed them".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "since": {
+                            "type": "string",
+                            "description": "RFC 3339 timestamp (e.g. '2024-05-01T09:00:00Z'), Unix seconds, or a relative duration (e.g. '24h', '30m', '7d')"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of symbols to return (default: 100, max: 1000)",
+                            "default": 100,
+                            "minimum": 1,
+                            "maximum": 1000
+                        },
+                        "signature_style": {
+                            "type": "string",
+                            "enum": ["full", "compact", "name_only"],
+                            "description": "How to render function signatures (default: full)",
+                            "default": "full"
+                        },
+                        "use_git": {
+                            "type": "boolean",
+                            "description": "Use the date of the last commit touching each symbol's lines (git blame) instead of file modification times; uncommitted edits count as newest. Files outside git fall back to modification time",
+                            "default": false
+                        }
+                    },
+                    "required": ["since"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_tests_for".into(),
+                description: Some("Find test functions that appear to cover a function or method, matched by naming convention (TestCreateUser -> CreateUser, test_create_user -> create_user)".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "name": {
+                            "type": "string",
+                            "description": "Name of the function or method to find tests for"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace the tests must belong to"
+                        }
+                    },
+                    "required": ["name"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_untested_functions".into(),
+                description: Some("List functions and methods that no test appears to cover, for coverage-gap analysis without coverage data".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the search to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of results to return (default: 100)",
+                            "default": 100,
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_file_types".into(),
+                description: Some("List every type defined in a file with its fields and methods already grouped (Go methods are matched by receiver across the package). Unexported members are included and flagged".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "file_path": {
+                            "type": "string",
+                            "description": "Path to the file to analyze"
+                        }
+                    },
+                    "required": ["file_path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_source_range".into(),
+                description: Some("Return the source lines of a file range (e.g. from a stack trace or diff hunk) together with the symbols whose spans intersect it. The range is clamped to the file".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "file_path": {
+                            "type": "string",
+                            "description": "Path to the file"
+                        },
+                        "start_line": {
+                            "type": "integer",
+                            "description": "First line of the range (1-based)",
+                            "minimum": 1
+                        },
+                        "end_line": {
+                            "type": "integer",
+                            "description": "Last line of the range (inclusive)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["file_path", "start_line", "end_line"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "compare_signatures".into(),
+                description: Some("Compare two versions of a function signature by symbol ID and classify the change as identical, compatible or breaking, with reasons".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "old_id": {
+                            "type": "integer",
+                            "description": "ID of the symbol before the change"
+                        },
+                        "new_id": {
+                            "type": "integer",
+                            "description": "ID of the symbol after the change"
+                        }
+                    },
+                    "required": ["old_id", "new_id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "diff_public_api".into(),
+                description: Some("Report the public API changes between two index snapshots or git refs as Markdown or plain text for a changelog: added and removed public symbols with their declarations, and changed signatures with before/after and whether the change breaks callers. Grouped by change and then by symbol kind; symbols are matched by qualified name, so moved code is not reported".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "old_snapshot": {
+                            "type": "string",
+                            "description": "Index of the old version, written by index_code with snapshot_path"
+                        },
+                        "old_ref": {
+                            "type": "string",
+                            "description": "Git branch, tag or commit of the old version, indexed from the object store; instead of old_snapshot"
+                        },
+                        "new_snapshot": {
+                            "type": "string",
+                            "description": "Index of the new version (default: the current index)"
+                        },
+                        "new_ref": {
+                            "type": "string",
+                            "description": "Git branch, tag or commit of the new version; instead of new_snapshot"
+                        },
+                        "format": {
+                            "type": "string",
+                            "enum": ["markdown", "text"],
+                            "description": "Report layout (default: markdown)",
+                            "default": "markdown"
+                        },
+                        "title": {
+                            "type": "string",
+                            "description": "Report heading (default: Public API changes)"
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "explain_symbol".into(),
+                description: Some("Explain a symbol in one call: definition source, doc comment, signature, referenced types, direct callers and callees, errors it can produce and its complexity. Sections can be selected and lists are capped to fit a context window".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the symbol to explain"
+                        },
+                        "sections": {
+                            "type": "array",
+                            "items": {
+                                "type": "string",
+                                "enum": ["source", "doc", "signature", "types", "callers", "callees", "errors", "complexity"]
+                            },
+                            "description": "Sections to include (default: all)"
+                        },
+                        "max_source_lines": {
+                            "type": "integer",
+                            "description": "Maximum number of source lines to include (default: 80)",
+                            "minimum": 1
+                        },
+                        "max_items": {
+                            "type": "integer",
+                            "description": "Maximum number of entries in each list section (default: 20)",
+                            "minimum": 1
+                        },
+                        "max_tokens": {
+                            "type": "integer",
+                            "description": "Token budget for the response, estimated at 4 characters per token. Entries of referenced_types, then possible_errors, callees and callers are dropped to fit and the response gets truncated: true and the number omitted",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_duplicates".into(),
+                description: Some("Find clusters of functions and methods with identical or structurally similar bodies, for refactoring. Bodies are compared as normalized token streams, ignoring identifier names, literal values, comments and whitespace, so renamed copies match. Each cluster has a similarity score; small bodies are skipped".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "min_tokens": {
+                            "type": "integer",
+                            "description": "Bodies with fewer normalized tokens are ignored, so trivial one-liners are not flagged (default: 40)",
+                            "minimum": 1
+                        },
+                        "min_similarity": {
+                            "type": "number",
+                            "description": "Minimum similarity from 0 to 1; 1 finds only exact clones (default: 0.9)",
+                            "exclusiveMinimum": 0,
+                            "maximum": 1
+                        },
+                        "include_tests": {
+                            "type": "boolean",
+                            "description": "Also compare test functions (default: false)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of clusters to return (default: 20)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_interface_contract".into(),
+                description: Some("Describe a Go interface as data for mock or stub generation: its full method set with embedded interfaces expanded, each method's parameters and result types, and whether it can return an error".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the Go interface type (from find_symbols or get_symbol)"
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_neighbors".into(),
+                description: Some("Walk the code graph from a symbol: return the symbols adjacent along the requested edges (calls, called_by, references, referenced_by, implements, implemented_by, uses_type), optionally several steps deep. Each neighbor records the edge and symbol it was reached from; large fan-outs are capped and flagged as truncated".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the symbol to start from (from find_symbols or get_symbol)"
+                        },
+                        "edges": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": ["calls", "called_by", "references", "referenced_by", "implements", "implemented_by", "uses_type"]},
+                            "description": "Edges to follow"
+                        },
+                        "depth": {
+                            "type": "integer",
+                            "description": "Number of steps to walk (default: 1)",
+                            "minimum": 1,
+                            "maximum": 3
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of neighbors to return, and to follow from any one symbol along one edge (default: 50)",
+                            "minimum": 1
+                        },
+                        "timeout_ms": {
+                            "type": "integer",
+                            "description": "Time budget in milliseconds. When it runs out, the neighbors found so far are returned with truncated and timed_out set and the frontier of symbols not yet walked from, each with its depth, to resume from (default: none)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["id", "edges"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_sql".into(),
+                description: Some("Find SQL queries embedded as string literals that use a table, with the operation (select, insert, update, delete) and the function, method or constant holding each query. Parsing is lenient: tables are the names after FROM, INTO, UPDATE and JOIN".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "table": {
+                            "type": "string",
+                            "description": "Table name, matched case-insensitively; an unqualified name also matches schema-qualified ones (users matches public.users)"
+                        },
+                        "operation": {
+                            "type": "string",
+                            "enum": ["select", "insert", "update", "delete"],
+                            "description": "Only return queries of this kind"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of queries to return (default: 100)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["table"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_resource_cleanup".into(),
+                description: Some("Find Go functions that defer cleanup calls such as Close, Rollback or Unlock, with each matching deferred call. Deferred closures match when their body calls a cleanup method. Function signatures also carry uses_defer and all deferred calls".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "methods": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Deferred methods to look for, matched against the last part of the call (Close matches resp.Body.Close). Default: Close, Rollback, Unlock, RUnlock, Done, Stop, Release, Flush, End, Cancel, cancel"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the search to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of functions to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_init_order".into(),
+                description: Some("Approximate the order in which a Go package initializes: package-level var initializers in dependency order, then every init function in file order, with the vars each step depends on. Vars whose initializers call functions are marked non_trivial; such vars and init functions also carry an init tag".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Directory of the Go package, or a file in it"
+                        },
+                        "include_tests": {
+                            "type": "boolean",
+                            "description": "Include _test.go files, which test binaries initialize with the package (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_references".into(),
+                description: Some("Find every place a symbol is used across the indexed workspace, by name or by a file:line location of its definition or of a reference to it. Each reference carries its file, line, column, kind, the trimmed source line and the symbol containing it, sorted by file and position. Served from the reference index built during parsing, so no files are searched".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "name": {
+                            "type": "string",
+                            "description": "Symbol name, optionally qualified as pkg.Name"
+                        },
+                        "location": {
+                            "type": "string",
+                            "description": "file:line or file:line:column of a definition or reference; lines are 1-based and columns 0-based, as in responses"
+                        },
+                        "kinds": {
+                            "type": "array",
+                            "items": {
+                                "type": "string",
+                                "enum": ["usage", "call", "import", "construction", "definition"]
+                            },
+                            "description": "Reference kinds to report (default: all but definition)"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory; only references in it are reported"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of references to return (default: 100)",
+                            "minimum": 1
+                        },
+                        "include_aliases": {
+                            "type": "boolean",
+                            "description": "Also gather references made through aliases and re-exports of the symbol, such as uses of T for Go `type T = U`",
+                            "default": false
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "reload_config".into(),
+                description: Some("Apply changed settings without restarting: optionally set ROBERTO_* settings, then re-read them and re-index as far as the changes require. Kind, name, tag, route and language settings re-parse every file; file size, symlink, vendor and docs settings only add and drop the files they select; settings such as the log level are read only at startup and are reported as needing a restart. Returns what changed and the re-indexing performed".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "settings": {
+                            "type": "object",
+                            "description": "Settings to change first, by environment variable name, e.g. {\"ROBERTO_INDEX_KINDS\": \"go=function,method\"}; null unsets one",
+                            "additionalProperties": {"type": ["string", "null"]}
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_cycles".into(),
+                description: Some("Find circular dependencies, reported as strongly connected components of more than one member with the edges forming them. scope types: structs, classes, interfaces and enums that reach each other through their field types (and method signatures with include_methods), each edge naming the fields it goes through. scope packages: directories whose code references each other, each edge with its reference count and example references; in Go these are import cycles, which do not compile".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "scope": {
+                            "type": "string",
+                            "enum": ["types", "packages"],
+                            "description": "The dependency graph to search"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory; only cycles with a member in it are reported"
+                        },
+                        "include_methods": {
+                            "type": "boolean",
+                            "description": "Types: also follow the types named in method signatures (default: false)",
+                            "default": false
+                        },
+                        "include_vendor": {
+                            "type": "boolean",
+                            "description": "Types: also follow types of vendored code (default: false)",
+                            "default": false
+                        },
+                        "examples": {
+                            "type": "integer",
+                            "description": "Packages: example references per edge (default: 1)",
+                            "minimum": 0
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of cycles to return (default: 50)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["scope"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "lint_goroutine_leaks".into(),
+                description: Some("Flag Go goroutines whose function loops forever on a ticker, timer or channel with no visible way to stop: no context Done or stop channel in the select, no return or break out of the loop. Reports the go statement and the function it starts, with a confidence: high for loops that only wait on timers, medium when they also receive from other channels, lowered when the function's type has a Stop, Close, Shutdown or Cancel method. Launches marked nolint:goroutineleak or matched by allow are counted as suppressed".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
+                        },
+                        "allow": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Goroutine targets or launching functions known to be long-lived, e.g. c.cleanup, *.run or main"
+                        },
+                        "min_confidence": {
+                            "type": "string",
+                            "enum": ["low", "medium", "high"],
+                            "description": "Least confidence to report (default: low)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of findings to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_transitive_types".into(),
+                description: Some("Return a type and every struct, class, interface or enum it needs, transitively, through field types and method signatures: a complete minimal type context for generating or explaining code in one call. Types are deduplicated and ordered so each comes after the types it depends on, the requested type last, each with its definition source and the ids it depends_on. Standard library and other unindexed types, vendored types and anything matched by stop_at are listed in external instead of being expanded".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the struct, class, interface or enum to start from"
+                        },
+                        "stop_at": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Namespaces or qualified type names (models, models.Audit) to list in external without expanding"
+                        },
+                        "include_vendor": {
+                            "type": "boolean",
+                            "description": "Expand types of vendored code instead of stopping at them (default: false)",
+                            "default": false
+                        },
+                        "max_depth": {
+                            "type": "integer",
+                            "description": "Levels of referenced types to expand (default: 10)",
+                            "default": 10
+                        },
+                        "include_source": {
+                            "type": "boolean",
+                            "description": "Include each type's definition source (default: true)",
+                            "default": true
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "diff_bodies".into(),
+                description: Some("Diff the definitions of two symbols, by id, as segments of common, added and removed lines or tokens, to see exactly how near-duplicates found by find_duplicates differ before unifying them. Each segment gives the file line where it starts in each definition. Token granularity ignores layout entirely; ignore_whitespace makes line diffs skip indentation and blank line changes. Also returns counts and a similarity from 0 to 1".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id_a": {
+                            "type": "integer",
+                            "description": "ID of the first definition; its lines show as removed"
+                        },
+                        "id_b": {
+                            "type": "integer",
+                            "description": "ID of the second definition; its lines show as added"
+                        },
+                        "granularity": {
+                            "type": "string",
+                            "enum": ["line", "token"],
+                            "description": "Compare whole lines or tokens (default: line)",
+                            "default": "line"
+                        },
+                        "ignore_whitespace": {
+                            "type": "boolean",
+                            "description": "Compare lines with whitespace collapsed and blank lines skipped (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["id_a", "id_b"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_stale_markers".into(),
+                description: Some("List TODO, FIXME, HACK and XXX markers in comments, oldest first, to surface long-standing debt. Each marker's age is the days since the commit that last touched its line, from git blame, with the commit's author and date; markers in files outside git have no age and are listed last. Reports the owner in TODO(name): and the innermost symbol containing the marker. Works for all indexed languages".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "kinds": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": ["TODO", "FIXME", "HACK", "XXX"]},
+                            "description": "Only these marker kinds (default: all)"
+                        },
+                        "min_age_days": {
+                            "type": "integer",
+                            "description": "Only markers at least this many days old; markers of unknown age are left out when set",
+                            "minimum": 0
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of markers to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_pure_functions".into(),
+                description: Some("Classify Go functions and methods as pure or effectful, for judging refactoring safety. Effects are I/O (fmt printing, log, os, io, net, database/sql, or methods of values named like a logger, db, client or writer), writes through the receiver or a parameter, writes to package-level state, starting goroutines and channel operations. Calls are followed through the index: a function is pure only if everything it calls is pure or a known-pure standard library function. Conservative: unknown calls count as effects".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the results to"
+                        },
+                        "include_effectful": {
+                            "type": "boolean",
+                            "description": "Also list effectful functions with their effects (default: false)",
+                            "default": false
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of functions to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_source_chunk".into(),
+                description: Some("Fetch the next chunk of a definition too large for one message. get_symbol with max_source_bytes returns the first chunk and a next_cursor; pass it here until no next_cursor is returned. Chunks end at line breaks and report their byte offset, the total size and the file lines covered, so partial source can be shown with progress. Fails if the definition changed since the first chunk".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "cursor": {
+                            "type": "string",
+                            "description": "The next_cursor of the previous chunk"
+                        },
+                        "max_bytes": {
+                            "type": "integer",
+                            "description": "Maximum bytes to return, cut at a line break (default: 32768)",
+                            "minimum": 1,
+                            "default": 32768
+                        }
+                    },
+                    "required": ["cursor"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_package_graph".into(),
+                description: Some("Package-to-package dependency graph built from resolved references: an edge from A to B when code in A's directory references symbols of B, weighted by the reference count and the number of distinct symbols used. Shows the real architecture, including dependencies that go through type names and calls rather than imports alone. Optionally includes example references per edge".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory; only dependencies from or to packages under it are returned"
+                        },
+                        "min_references": {
+                            "type": "integer",
+                            "description": "Leave out dependencies with fewer references (default: 1)",
+                            "minimum": 1,
+                            "default": 1
+                        },
+                        "examples": {
+                            "type": "integer",
+                            "description": "Example references to return per dependency (default: 0)",
+                            "minimum": 0,
+                            "default": 0
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of dependencies to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "check_layering".into(),
+                description: Some("Check the package dependency graph against a layering policy and report every dependency that breaks it, with example references naming the symbols that cause it. The policy, a JSON file or inline object, lists the packages each package may depend on (\"allow\", which must form a DAG) and dependencies that are forbidden outright (\"deny\"). Packages are named by package name, directory, trailing directories or a \"dir/...\" subtree pattern".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "policy_file": {
+                            "type": "string",
+                            "description": "JSON file holding the layering policy"
+                        },
+                        "policy": {
+                            "type": "object",
+                            "description": "The layering policy inline, instead of policy_file",
+                            "properties": {
+                                "allow": {
+                                    "type": "object",
+                                    "additionalProperties": {"type": "array", "items": {"type": "string"}},
+                                    "description": "Packages each package may depend on, e.g. {\"transport\": [\"service\"], \"service\": [\"storage\"]}. Packages matched by no key may depend on anything"
+                                },
+                                "deny": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "from": {"type": "string"},
+                                            "to": {"type": "string"}
+                                        },
+                                        "required": ["from", "to"]
+                                    },
+                                    "description": "Dependencies forbidden whatever allow says"
+                                }
+                            }
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory; only dependencies from or to packages under it are checked"
+                        },
+                        "examples": {
+                            "type": "integer",
+                            "description": "Example references to return per violation (default: 3)",
+                            "minimum": 0,
+                            "default": 3
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of violations to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_panics".into(),
+                description: Some("Audit where Go code panics instead of returning errors. Per function, lists explicit panic(...) calls with the panic value (read out when it is a literal), recover() calls and whether a deferred closure recovers, and separately the implicit risks: unchecked type assertions (v := x.(T)) that panic on a mismatch. Functions with the most panic sites come first".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "function": {
+                            "type": "string",
+                            "description": "Only functions or methods with this name"
+                        },
+                        "include_implicit": {
+                            "type": "boolean",
+                            "description": "Also report unchecked type assertions, which panic on a mismatch (default: true)",
+                            "default": true
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of functions to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "validate_index".into(),
+                description: Some("Check the index for drift after incremental updates: reference edges to missing symbols or from untracked files, duplicate edges, stale name index entries, symbols under the wrong id or in untracked files, tracked files gone from disk and spans past the end of their file. Read-only unless repair is set, which drops the dangling edges and stale entries".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "repair": {
+                            "type": "boolean",
+                            "description": "Drop dangling reference edges and stale name entries after checking (default: false, read-only)",
+                            "default": false
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of issues to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_hotspots".into(),
+                description: Some("Rank symbols by how heavily the codebase uses them: incoming references and calls from outside their own body, or PageRank over the reference graph so references from central code weigh more. Shows a newcomer the core types and functions first. Filter by kind, package or directory".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the results to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "kinds": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Symbol kinds to rank, e.g. [\"class\", \"function\"] (default: every kind but variables and imports)"
+                        },
+                        "rank_by": {
+                            "type": "string",
+                            "enum": ["references", "pagerank"],
+                            "description": "references (default) ranks by incoming references; pagerank weighs each reference by how central the referencing symbol is",
+                            "default": "references"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of results to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "lint_context_propagation".into(),
+                description: Some("Find Go functions that accept a context.Context but pass context.Background() or context.TODO() to a call instead, directly, through context.With* or through a local variable, which drops the caller's cancellation and deadline. Allowlist intentional background work by callee or function pattern".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
+                        },
+                        "allow": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Callees or enclosing functions allowed to use a fresh context; * matches any characters and an unqualified pattern matches any receiver, e.g. s.audit.Record, Shutdown, Warm*"
+                        },
+                        "skip_goroutines": {
+                            "type": "boolean",
+                            "description": "Leave out calls made in goroutines the function starts (default: false)",
+                            "default": false
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of findings to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_api_hash".into(),
+                description: Some("Hash a package's public API surface into one stable value: every exported symbol with its kind, qualified name and signature shape. Editing bodies, moving code or renaming parameters keeps the hash; changing a signature or adding or removing an exported symbol changes it. A cheap check before running diff_public_api".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "package": {
+                            "type": "string",
+                            "description": "Package directory, or a package name as listed by list_packages"
+                        },
+                        "ref": {
+                            "type": "string",
+                            "description": "Git branch, tag or commit to hash the package at (default: the current index)"
+                        },
+                        "include_surface": {
+                            "type": "boolean",
+                            "description": "Also return the lines the hash is computed from (default: false)",
+                            "default": false
+                        }
+                    },
+                    "required": ["package"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_construction_sites".into(),
+                description: Some("Find every Go composite literal that builds a type directly, such as User{...} or &models.User{...}, with the fields each one sets and the function it is in. Sites inside the type's constructors (functions of its package returning it, like NewUser) are marked; the rest bypass them and are counted as bypassing".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "type_name": {
+                            "type": "string",
+                            "description": "Go type name, bare (User) or package-qualified (models.User)"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the sites to"
+                        },
+                        "include_constructors": {
+                            "type": "boolean",
+                            "description": "Also list sites inside the type's constructors (default: true)",
+                            "default": true
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of sites to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    },
+                    "required": ["type_name"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "subscribe_changes".into(),
+                description: Some("Subscribe to index changes pushed as the file watcher re-indexes files, instead of polling. Each change arrives as a notifications/message log message from the roberto.index_changes logger whose data names the subscription, the file, whether it was reindexed or removed, and the symbols added, removed and changed. Filter by path globs; end with unsubscribe_changes. Subscriptions also end when the client disconnects".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "paths": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Path globs to hear about, e.g. internal/**/*.go; * stays within a directory, ** spans any. Unanchored globs match at any depth (default: every file)"
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "unsubscribe_changes".into(),
+                description: Some("End a subscription started with subscribe_changes".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "subscription_id": {
+                            "type": "integer",
+                            "description": "Id returned by subscribe_changes"
+                        }
+                    },
+                    "required": ["subscription_id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "lint_shadowing".into(),
+                description: Some("Find Go variables declared with := or var in an inner scope (if, for, switch, block or closure) under the name of a variable of an enclosing scope in the same function, such as err redeclared inside an if block so the outer err stays nil. Reports both declarations with their scopes and lines, and flags names read by a deferred func. _ is skipped".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
+                        },
+                        "errors": {
+                            "type": "string",
+                            "enum": ["include", "skip", "only"],
+                            "description": "Error variables (err, parseErr, errTx): include them, skip them or report only them (default: include)",
+                            "default": "include"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of findings to return (default: 100)",
+                            "minimum": 1,
+                            "default": 100
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_routes".into(),
+                description: Some("List HTTP routes registered in Go code with net/http, chi, gin, echo or custom router calls: method, path pattern, router and the handler function or method each route is linked to. Routes are extracted at indexing time when ROBERTO_ROUTES names the routers to recognize".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the listing to"
+                        },
+                        "method": {
+                            "type": "string",
+                            "description": "Only routes for this HTTP method, e.g. GET. Routes registered for any method are always listed"
+                        },
+                        "prefix": {
+                            "type": "string",
+                            "description": "Only routes whose path starts with this prefix, e.g. /api/"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of routes to return (default: 200)",
+                            "minimum": 1,
+                            "default": 200
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "export_context".into(),
+                description: Some("Assemble a token-bounded context pack for one package, ready to paste into a prompt: the package doc, signatures of the exported API, exported type definitions and optionally the full bodies of the most-referenced functions. Entries rank by section, then by reference count; the least important are dropped to fit and reported as omitted".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Package directory, or a package name as listed by list_packages"
+                        },
+                        "max_tokens": {
+                            "type": "integer",
+                            "description": "Token budget for the included entries (default: 8000)",
+                            "minimum": 0,
+                            "default": 8000
+                        },
+                        "bodies": {
+                            "type": "integer",
+                            "description": "Include the full bodies of this many most-referenced functions and methods (default: 0)",
+                            "minimum": 0,
+                            "default": 0
+                        }
+                    },
+                    "required": ["path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "lint_naming".into(),
+                description: Some("Check Go symbol names against naming conventions and report each violation with the rule it broke. Built-in rules: mixed-caps (no underscores), constructor-prefix (functions returning a type of their package start with New), unexported-type-case (hTTPClient should be httpClient) and the opt-in interface-er-suffix. Add rules as regexes per symbol kind".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
+                        },
+                        "rules": {
+                            "type": "array",
+                            "description": "Extra rules checked along with the built-in ones",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "name": {"type": "string", "description": "Rule name reported with each violation"},
+                                    "kinds": {"type": "array", "items": {"type": "string"}, "description": "Symbol kinds the rule checks, e.g. function, method, struct, interface, constant"},
+                                    "pattern": {"type": "string", "description": "Regex the names are matched against"},
+                                    "scope": {"type": "string", "enum": ["all", "exported", "unexported", "constructor"], "description": "Symbols checked (default: all)"},
+                                    "must_match": {"type": "boolean", "description": "Names must match the pattern; false for names that must not (default: true)"},
+                                    "message": {"type": "string", "description": "Explanation reported with each violation"}
+                                },
+                                "required": ["name", "kinds", "pattern"]
+                            }
+                        },
+                        "enable": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Optional built-in rules to turn on: interface-er-suffix"
+                        },
+                        "disable": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Built-in rules to turn off"
+                        },
+                        "use_default_rules": {
+                            "type": "boolean",
+                            "description": "Check the built-in Go rules (default: true)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of violations to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_magic_literals".into(),
+                description: Some("Code-quality review: numeric and string literals in function bodies that should arguably be named constants, grouped under the function or method holding them. 0, 1, empty strings, constant declarations and docstrings are skipped; a number multiplying a unit is reported as the product (100 * time.Millisecond, 1*time.Hour). Threshold numbers by magnitude and strings by length, ignore known values, or keep only values repeated across the searched files".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "kinds": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": ["number", "string"]},
+                            "description": "Literal kinds to report (default: both)"
+                        },
+                        "min_number": {
+                            "type": "number",
+                            "description": "Skip bare numbers whose magnitude is below this; 0 and 1 are always skipped"
+                        },
+                        "min_string_length": {
+                            "type": "integer",
+                            "description": "Skip strings shorter than this many characters (default: 1)",
+                            "minimum": 1
+                        },
+                        "ignore": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Values never reported: numbers as written (\"1024\"), strings without quotes"
+                        },
+                        "min_occurrences": {
+                            "type": "integer",
+                            "description": "Only report values occurring at least this often in the searched files (default: 1)",
+                            "minimum": 1
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of symbols to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "export_graph".into(),
+                description: Some("Export the subgraph reached from a symbol as Graphviz DOT (pipe to `dot -Tsvg`) or a Mermaid flowchart, for visualization. Follows one edge type: calls for a call graph, uses_type for a type-dependency graph, or any other get_neighbors edge. Nodes are labeled with symbol names and have stable ids (n<symbol id>); edges are labeled with the relationship. The node count is capped".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the symbol to start from (from find_symbols)"
+                        },
+                        "edge_type": {
+                            "type": "string",
+                            "enum": ["calls", "called_by", "uses_type", "implements", "implemented_by", "references", "referenced_by"],
+                            "description": "Edge to follow (default: calls)"
+                        },
+                        "depth": {
+                            "type": "integer",
+                            "description": "Number of steps to walk (default: 2)",
+                            "minimum": 1,
+                            "maximum": 5
+                        },
+                        "format": {
+                            "type": "string",
+                            "enum": ["dot", "mermaid"],
+                            "description": "Output format (default: dot)"
+                        },
+                        "max_nodes": {
+                            "type": "integer",
+                            "description": "Maximum number of nodes to draw, the start included (default: 50)",
+                            "minimum": 1,
+                            "maximum": 500
+                        },
+                        "timeout_ms": {
+                            "type": "integer",
+                            "description": "Time budget in milliseconds. When it runs out, the graph drawn so far is returned with truncated and timed_out set and the frontier of drawn nodes whose edges were not all walked (default: none)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "interface_coverage".into(),
+                description: Some("Architectural report of implementations per interface across the index: interfaces with no implementation (possibly dead) and with exactly one (candidates for inlining), gaps first. Go types count when their method sets satisfy the interface; other languages when a class declares it. Filter to exported interfaces, since unexported single-implementation interfaces are often intentional".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the reported interfaces to; implementations are counted across the whole index"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "exported_only": {
+                            "type": "boolean",
+                            "description": "Only report exported (public) interfaces (default: false)"
+                        },
+                        "max_implementations": {
+                            "type": "integer",
+                            "description": "Only report interfaces with at most this many implementations, e.g. 1 for gaps and inlining candidates",
+                            "minimum": 0
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of interfaces to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "resolve_receiver_types".into(),
+                description: Some("Resolve the static receiver type of every method call in a Go function or method, for precise call edges: s.cache.Set resolves to Cache by tracing s to the receiver *UserService and its cache field. Each call carries a trace of the steps, whether the type is an interface (dynamic dispatch) and, for concrete package types, the indexed method it calls. Receivers that cannot be traced are marked unresolved with a reason instead of guessed".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the Go function or method (from find_symbols)"
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_by_return_count".into(),
+                description: Some("Readability metric: list functions and methods by their number of return statements, most first. Early returns (guard clauses, error paths) are those before the last statement of the body; returns inside closures belong to the closure. Counts are recorded at indexing time; cyclomatic complexity can be added to find refactoring candidates".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the search to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "min_returns": {
+                            "type": "integer",
+                            "description": "Only functions with at least this many returns (default: 1)",
+                            "minimum": 0
+                        },
+                        "min_early_returns": {
+                            "type": "integer",
+                            "description": "Only functions with at least this many early returns (default: 0)",
+                            "minimum": 0
+                        },
+                        "sort_by": {
+                            "type": "string",
+                            "enum": ["returns", "early_returns"],
+                            "description": "Rank by total or early returns (default: returns)"
+                        },
+                        "include_complexity": {
+                            "type": "boolean",
+                            "description": "Also compute the cyclomatic complexity of each returned function (default: false)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of results to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_users_of_package".into(),
+                description: Some("Dependency and security audit: find every Go function or method whose body uses an imported package (e.g. which functions touch os/exec or encoding/json), with the package identifiers each one uses and the lines using them. Import aliases are followed".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "import_path": {
+                            "type": "string",
+                            "description": "Import path of the package, e.g. 'encoding/json'; 'prefix/...' matches every package under prefix"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of functions to return (default: 100)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["import_path"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_satisfied_interfaces".into(),
+                description: Some("List every indexed Go interface a concrete type satisfies structurally, the inverse of get_neighbors' implemented_by edge. Each interface notes whether the value type T satisfies it or only the pointer type *T, because some of its methods have pointer receivers".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the Go type (from find_symbols)"
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_type_assertions".into(),
+                description: Some("List Go type assertions and conversions with the enclosing function, asserted type and form. Unchecked assertions (v := x.(T)) panic when the value holds another type and are flagged; the comma-ok form (v, ok := x.(T)) is reported as checked".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the search to"
+                        },
+                        "function": {
+                            "type": "string",
+                            "description": "Only casts inside functions or methods with this name"
+                        },
+                        "unchecked_only": {
+                            "type": "boolean",
+                            "description": "Only unchecked assertions, which can panic (default: false)",
+                            "default": false
+                        },
+                        "include_conversions": {
+                            "type": "boolean",
+                            "description": "Also report conversions such as int64(n) (default: true)",
+                            "default": true
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of casts to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_error_sentinels".into(),
+                description: Some("Audit the error vocabulary of Go packages: package-level error vars (sentinels such as ErrNotFound) with every function that returns them, directly or wrapped with fmt.Errorf %w. Sentinels that are never returned are listed as likely dead errors".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the sentinels to; return sites are searched everywhere"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package filter"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of sentinels to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_symbol_churn".into(),
+                description: Some("Find hotspots: symbols whose lines changed in a git commit range, with a churn count (commits that touched them) and the touching commits, highest churn first. Walks history on demand, bounded by max_commits".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "from": {
+                            "type": "string",
+                            "description": "Start of the commit range, exclusive (e.g. 'v1.2.0', 'HEAD~50')"
+                        },
+                        "to": {
+                            "type": "string",
+                            "description": "End of the commit range, inclusive (default: HEAD)",
+                            "default": "HEAD"
+                        },
+                        "max_commits": {
+                            "type": "integer",
+                            "description": "Maximum number of commits to walk back from 'to' (default: 100, max: 1000)",
+                            "minimum": 1,
+                            "maximum": 1000
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the analysis to"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of symbols to return (default: 50)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["from"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_entry_points".into(),
+                description: Some("List functions and methods called from outside their package (entry points) as opposed to internal helpers, using package-aware call resolution. Flags unexported functions that are nonetheless called from another package".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the listing to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "include_internal": {
+                            "type": "boolean",
+                            "description": "Also list functions without callers from other packages, with is_entry_point false (default: false)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of functions to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "find_by_type_usage".into(),
+                description: Some("Find functions and methods that take or return a type, and types with fields of that type, e.g. everything using *QueryResult or time.Time. Each usage is reported as param, return or field".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "type_name": {
+                            "type": "string",
+                            "description": "Type to look for, e.g. '*QueryResult' or 'time.Time'. Pointer and slice modifiers are ignored; unqualified names match any package"
+                        },
+                        "usage": {
+                            "type": "array",
+                            "items": {"type": "string", "enum": ["param", "return", "field"]},
+                            "description": "Usage kinds to include (default: all)"
+                        },
+                        "channel": {
+                            "type": "string",
+                            "enum": ["send", "receive", "bidirectional"],
+                            "description": "Only match Go channels whose element type is type_name and that have this direction"
+                        },
+                        "path": {
+                            "type": "string",
+                            "description": "Optional directory to restrict the search to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of usages to return (default: 100)",
+                            "minimum": 1
+                        }
+                    },
+                    "required": ["type_name"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_enums".into(),
+                description: Some("List Go enumerations: const blocks using iota, grouped under their declared type with ordered members and computed integer values (including bit-shift patterns like 1 << iota)".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "path": {
+                            "type": "string",
+                            "description": "Optional file or directory to restrict the listing to"
+                        },
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package filter"
+                        },
+                        "name": {
+                            "type": "string",
+                            "description": "Optional enum name (the declared type, e.g. 'Weekday')"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of enums to return (default: 100)",
+                            "minimum": 1
+                        }
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_json_schema".into(),
+                description: Some("Derive the JSON encoding of a Go type from its fields and json struct tags: a JSON Schema with referenced types expanded into $defs, or a simplified field-to-type map. json:\"-\" fields are omitted and omitempty fields are optional".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {
+                        "id": {
+                            "type": "integer",
+                            "description": "ID of the Go type to describe"
+                        },
+                        "format": {
+                            "type": "string",
+                            "enum": ["schema", "fields"],
+                            "description": "\"schema\" for a JSON Schema document (default) or \"fields\" for a simplified property-to-type map"
+                        },
+                        "max_depth": {
+                            "type": "integer",
+                            "description": "How many levels of referenced types to expand into $defs (default: 5)",
+                            "minimum": 0
+                        }
+                    },
+                    "required": ["id"]
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
             },
             Tool {
-                name: "code_search".into(),
-                description: Some("Search through indexed code content using BM25 statistical search".into()),
+                name: "list_recursive_functions".into(),
+                description: Some("List functions and methods that call themselves or take part in a mutual recursion cycle, each with is_recursive and the recursion_cycle it belongs to, computed from the strongly connected components of the call graph".into()),
                 input_schema: Arc::new(serde_json::from_value(json!({
                     "type": "object",
                     "properties": {
-                        "query": {
+                        "path": {
                             "type": "string",
-                            "description": "Search query for code content"
-                        },
-                        "limit": {
-                            "type": "integer",
-                            "description": "Maximum number of results to return",
-                            "default": 10
+                            "description": "Optional directory to restrict the search to"
                         },
-                        "max_results": {
-                            "type": "integer", 
-                            "description": "Alias for limit - maximum number of results to return",
-                            "default": 10
+                        "namespace": {
+                            "type": "string",
+                            "description": "Optional package/module/namespace filter"
                         },
-                        "context_lines": {
+                        "limit": {
                             "type": "integer",
-                            "description": "Number of lines of context around matches (default: 2)",
-                            "default": 2
+                            "description": "Maximum number of results to return (default: 100)",
+                            "minimum": 1
                         }
-                    },
-                    "required": ["query"]
+                    }
                 })).unwrap()),
                 output_schema: None,
                 annotations: None,
@@ -302,17 +3127,30 @@ impl ServerHandler for CodeAnalysisTools {
                 title: None,
             },
             Tool {
-                name: "get_file_outline".into(),
-                description: Some("Get compact symbol outline for a specific file grouped by type".into()),
+                name: "lint_unchecked_errors".into(),
+                description: Some("Find Go call sites that discard an error: calls returning error (or (T, error)) used as statements, deferred, started as goroutines or with the error assigned to _. Callees are resolved against indexed signatures; conventionally ignorable calls like fmt.Println are allowlisted".into()),
                 input_schema: Arc::new(serde_json::from_value(json!({
                     "type": "object",
                     "properties": {
-                        "file_path": {
+                        "path": {
                             "type": "string",
-                            "description": "Path to the file to outline"
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
+                        },
+                        "allow": {
+                            "type": "array",
+                            "items": {"type": "string"},
+                            "description": "Extra callees whose errors may be ignored; '*' matches any characters and unqualified names match any receiver, e.g. 'Close' or 'log.*'"
+                        },
+                        "use_default_allowlist": {
+                            "type": "boolean",
+                            "description": "Apply the built-in allowlist: fmt.Print*, fmt.Fprint*, *.WriteString, *.WriteByte, *.WriteRune (default: true)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of findings to return (default: 100)",
+                            "minimum": 1
                         }
-                    },
-                    "required": ["file_path"]
+                    }
                 })).unwrap()),
                 output_schema: None,
                 annotations: None,
@@ -320,23 +3158,49 @@ impl ServerHandler for CodeAnalysisTools {
                 title: None,
             },
             Tool {
-                name: "get_directory_outline".into(),
-                description: Some("Get compact directory structure showing classes by default, with optional symbol type includes".into()),
+                name: "lint_receiver_mutation".into(),
+                description: Some("Flag Go value-receiver methods that assign to receiver fields, which only changes a copy and is lost on return. Pointer-receiver assignments, which persist, can be included for comparison".into()),
                 input_schema: Arc::new(serde_json::from_value(json!({
                     "type": "object",
                     "properties": {
-                        "directory_path": {
+                        "path": {
                             "type": "string",
-                            "description": "Path to directory to outline"
+                            "description": "Optional file or directory to restrict the check to (default: whole index)"
                         },
-                        "includes": {
-                            "type": "array",
-                            "items": {"type": "string"},
-                            "description": "Optional symbol types to include: methods, constants, functions, enums",
-                            "default": []
+                        "include_pointer_receivers": {
+                            "type": "boolean",
+                            "description": "Also report pointer-receiver assignments, which do persist (default: false)"
+                        },
+                        "limit": {
+                            "type": "integer",
+                            "description": "Maximum number of findings to return (default: 100)",
+                            "minimum": 1
                         }
-                    },
-                    "required": ["directory_path"]
+                    }
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "get_index_diagnostics".into(),
+                description: Some("Report files that were skipped (oversized or binary), failed to parse, or were only partially indexed, with the reason for each".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {}
+                })).unwrap()),
+                output_schema: None,
+                annotations: None,
+                icons: None,
+                title: None,
+            },
+            Tool {
+                name: "list_packages".into(),
+                description: Some("List all packages/modules/namespaces in the index with their symbol and file counts".into()),
+                input_schema: Arc::new(serde_json::from_value(json!({
+                    "type": "object",
+                    "properties": {}
                 })).unwrap()),
                 output_schema: None,
                 annotations: None,
@@ -396,22 +3260,31 @@ impl ServerHandler for CodeAnalysisTools {
     async fn call_tool(
         &self,
         request: CallToolRequestParam,
-        _context: RequestContext<RoleServer>,
+        context: RequestContext<RoleServer>,
     ) -> Result<CallToolResult, ErrorData> {
-        match request.name.as_ref() {
-            "index_code" => self.index_code(request.arguments).await,
-            "get_symbol" => self.get_symbol(request.arguments).await,
-            "get_symbol_references" => self.get_symbol_references(request.arguments).await,
-            "find_symbols" => self.find_symbols(request.arguments).await,
-            "code_search" => self.code_search(request.arguments).await,
-            "get_file_outline" => OutlineTools::get_file_outline(request.arguments).await,
-            "get_directory_outline" => OutlineTools::get_directory_outline(request.arguments).await,
-            _ => Err(ErrorData::new(
-                ErrorCode::METHOD_NOT_FOUND,
-                "Method not found",
-                None,
-            )),
+        // Long-running queries stop early when the client cancels or disconnects
+        let cancel = &context.ct;
+        let tool = request.name.to_string();
+        let started = Instant::now();
+        // Responses are serialized in the encoding and kind vocabulary the
+        // client negotiated
+        let encoding = ResponseEncoding::from_client(context.peer.peer_info());
+        let kinds = KindMap::from_client(context.peer.peer_info());
+        let result = encoding
+            .scope(kinds.scope(self.dispatch_tool(request, cancel, &context.peer)))
+            .await;
+
+        let duration_ms = started.elapsed().as_millis() as u64;
+        match &result {
+            Ok(_) => tracing::info!(tool = %tool, duration_ms, "Tool call completed"),
+            Err(e) => tracing::warn!(
+                tool = %tool,
+                duration_ms,
+                error = %e.message,
+                "Tool call failed"
+            ),
         }
+        result
     }
 
     async fn get_prompt(
@@ -518,18 +3391,410 @@ BE EXTREMELY FRUGAL - Skip details that don't contribute to understanding the ov
             }),
         }
     }
-}
+}
+
+impl CodeAnalysisTools {
+    async fn dispatch_tool(
+        &self,
+        request: CallToolRequestParam,
+        cancel: &CancellationToken,
+        peer: &Peer<RoleServer>,
+    ) -> Result<CallToolResult, ErrorData> {
+        match request.name.as_ref() {
+            "index_code" => self.index_code(request.arguments).await,
+            "merge_indexes" => self.merge_indexes(request.arguments).await,
+            "diff_public_api" => self.diff_public_api(request.arguments).await,
+            "get_symbol" => self.get_symbol(request.arguments).await,
+            "get_symbol_references" => self.get_symbol_references(request.arguments).await,
+            "find_symbols" => self.find_symbols(request.arguments, cancel).await,
+            "code_search" => self.code_search(request.arguments, cancel).await,
+            "get_file_outline" => OutlineTools::get_file_outline(request.arguments).await,
+            "get_directory_outline" => OutlineTools::get_directory_outline(request.arguments).await,
+            "get_directory_symbols" => OutlineTools::get_directory_symbols(request.arguments).await,
+            "get_file_types" => OutlineTools::get_file_types(request.arguments).await,
+            "get_source_range" => OutlineTools::get_source_range(request.arguments).await,
+            "list_packages" => self.list_packages().await,
+            "find_tests_for" => AnalysisTools::find_tests_for(request.arguments).await,
+            "find_untested_functions" => {
+                AnalysisTools::find_untested_functions(request.arguments, cancel).await
+            }
+            "list_recent_symbols" => self.list_recent_symbols(request.arguments).await,
+            "get_index_diagnostics" => self.get_index_diagnostics().await,
+            "compare_signatures" => AnalysisTools::compare_signatures(request.arguments).await,
+            "explain_symbol" => AnalysisTools::explain_symbol(request.arguments).await,
+            "get_json_schema" => AnalysisTools::get_json_schema(request.arguments).await,
+            "get_enums" => AnalysisTools::get_enums(request.arguments, cancel).await,
+            "find_by_type_usage" => {
+                AnalysisTools::find_by_type_usage(request.arguments, cancel).await
+            }
+            "get_neighbors" => AnalysisTools::get_neighbors(request.arguments, cancel).await,
+            "find_sql" => AnalysisTools::find_sql(request.arguments, cancel).await,
+            "find_resource_cleanup" => {
+                AnalysisTools::find_resource_cleanup(request.arguments).await
+            }
+            "get_init_order" => AnalysisTools::get_init_order(request.arguments, cancel).await,
+            "list_error_sentinels" => {
+                AnalysisTools::list_error_sentinels(request.arguments, cancel).await
+            }
+            "list_type_assertions" => {
+                AnalysisTools::list_type_assertions(request.arguments, cancel).await
+            }
+            "get_satisfied_interfaces" => {
+                AnalysisTools::get_satisfied_interfaces(request.arguments).await
+            }
+            "find_users_of_package" => {
+                AnalysisTools::find_users_of_package(request.arguments, cancel).await
+            }
+            "list_by_return_count" => {
+                AnalysisTools::list_by_return_count(request.arguments, cancel).await
+            }
+            "resolve_receiver_types" => {
+                AnalysisTools::resolve_receiver_types(request.arguments).await
+            }
+            "interface_coverage" => {
+                AnalysisTools::interface_coverage(request.arguments, cancel).await
+            }
+            "export_graph" => AnalysisTools::export_graph(request.arguments, cancel).await,
+            "find_magic_literals" => {
+                AnalysisTools::find_magic_literals(request.arguments, cancel).await
+            }
+            "lint_naming" => LintTools::lint_naming(request.arguments, cancel).await,
+            "export_context" => OutlineTools::export_context(request.arguments).await,
+            "list_routes" => AnalysisTools::list_routes(request.arguments).await,
+            "lint_shadowing" => LintTools::lint_shadowing(request.arguments, cancel).await,
+            "subscribe_changes" => {
+                SubscriptionTools::subscribe_changes(request.arguments, peer, self.session).await
+            }
+            "unsubscribe_changes" => {
+                SubscriptionTools::unsubscribe_changes(request.arguments, self.session).await
+            }
+            "find_construction_sites" => {
+                AnalysisTools::find_construction_sites(request.arguments, cancel).await
+            }
+            "get_api_hash" => self.get_api_hash(request.arguments).await,
+            "lint_context_propagation" => {
+                LintTools::lint_context_propagation(request.arguments, cancel).await
+            }
+            "list_hotspots" => AnalysisTools::list_hotspots(request.arguments, cancel).await,
+            "validate_index" => self.validate_index(request.arguments, cancel).await,
+            "list_panics" => AnalysisTools::list_panics(request.arguments, cancel).await,
+            "get_package_graph" => {
+                AnalysisTools::get_package_graph(request.arguments, cancel).await
+            }
+            "check_layering" => AnalysisTools::check_layering(request.arguments, cancel).await,
+            "get_source_chunk" => self.get_source_chunk(request.arguments).await,
+            "list_pure_functions" => {
+                AnalysisTools::list_pure_functions(request.arguments, cancel).await
+            }
+            "list_stale_markers" => {
+                AnalysisTools::list_stale_markers(request.arguments, cancel).await
+            }
+            "diff_bodies" => AnalysisTools::diff_bodies(request.arguments).await,
+            "get_transitive_types" => AnalysisTools::get_transitive_types(request.arguments).await,
+            "lint_goroutine_leaks" => {
+                LintTools::lint_goroutine_leaks(request.arguments, cancel).await
+            }
+            "find_cycles" => AnalysisTools::find_cycles(request.arguments, cancel).await,
+            "reload_config" => self.reload_config(request.arguments).await,
+            "find_references" => AnalysisTools::find_references(request.arguments, cancel).await,
+            "get_symbol_churn" => AnalysisTools::get_symbol_churn(request.arguments, cancel).await,
+            "list_entry_points" => {
+                AnalysisTools::list_entry_points(request.arguments, cancel).await
+            }
+            "get_interface_contract" => {
+                AnalysisTools::get_interface_contract(request.arguments).await
+            }
+            "list_recursive_functions" => {
+                AnalysisTools::list_recursive_functions(request.arguments, cancel).await
+            }
+            "find_duplicates" => AnalysisTools::find_duplicates(request.arguments, cancel).await,
+            "lint_receiver_mutation" => {
+                LintTools::lint_receiver_mutation(request.arguments, cancel).await
+            }
+            "lint_unchecked_errors" => {
+                LintTools::lint_unchecked_errors(request.arguments, cancel).await
+            }
+            _ => Err(ErrorData::new(
+                ErrorCode::METHOD_NOT_FOUND,
+                "Method not found",
+                None,
+            )),
+        }
+    }
+
+    async fn index_code(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let start_time = Instant::now();
+
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: IndexCodeRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+
+        // Use comprehensive path resolution
+        let path = PathResolver::resolve_file_or_directory_path(&params.path)?;
+        if path.is_dir() {
+            PathResolver::register_root(&path);
+        }
+
+        let pipeline = get_indexing_pipeline();
+        let mut pipeline_guard = pipeline.lock().await;
+
+        let mut files_changed = None;
+        let mut files_removed = None;
+        let mut full_index_reason = None;
+        let result = if path.is_file() {
+            pipeline_guard.index_file(&path).await.map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to index file: {}", e),
+                    None,
+                )
+            })?;
+            (1, get_symbol_store().get_symbol_count() as u32)
+        } else {
+            let changes = match params.base_ref {
+                Some(base_ref) => {
+                    let root = path.clone();
+                    tokio::task::spawn_blocking(move || git_changed_files(&root, &base_ref))
+                        .await
+                        .unwrap_or_else(|e| Err(e.to_string()))
+                        .map(Some)
+                        .unwrap_or_else(|e| {
+                            tracing::warn!("Falling back to a full index of {:?}: {}", path, e);
+                            full_index_reason = Some(e);
+                            None
+                        })
+                }
+                None => None,
+            };
+            let index_result = match changes {
+                Some(changes) => {
+                    files_changed = Some(changes.len() as u32);
+                    files_removed = Some(
+                        changes
+                            .iter()
+                            .filter(|change| !matches!(change, GitChange::Modified(_)))
+                            .count() as u32,
+                    );
+                    pipeline_guard.index_changes(&changes).await
+                }
+                None => pipeline_guard.index_directory(&path).await,
+            };
+            (index_result.files_processed, index_result.symbols_found)
+        };
+
+        let snapshot_path = match params.snapshot_path {
+            Some(snapshot_path) => {
+                pipeline_guard
+                    .write_snapshot(&path, Path::new(&snapshot_path))
+                    .await
+                    .map_err(|e| {
+                        ErrorData::new(
+                            ErrorCode::INTERNAL_ERROR,
+                            format!("Failed to write snapshot: {}", e),
+                            None,
+                        )
+                    })?;
+                Some(snapshot_path)
+            }
+            None => None,
+        };
+
+        // Start file watching for directories
+        if path.is_dir() {
+            if let Err(e) = start_file_watcher(path.clone()).await {
+                tracing::warn!("Failed to start file watcher for {:?}: {}", path, e);
+                // Don't fail the indexing if file watching fails
+            }
+        }
+
+        let duration = start_time.elapsed();
+
+        let response = IndexCodeResponse {
+            status: "success".to_string(),
+            files_indexed: result.0,
+            symbols_found: result.1,
+            errors: vec![],
+            duration_ms: duration.as_millis() as u64,
+            files_changed,
+            files_removed,
+            full_index_reason,
+            snapshot_path,
+        };
+
+        encode_response(&response)
+    }
+
+    async fn merge_indexes(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let start_time = Instant::now();
+
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: MergeIndexesRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+        if params.snapshots.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                "At least one snapshot is required",
+                None,
+            ));
+        }
+        let load = params.load.unwrap_or(true);
+        let snapshots: Vec<PathBuf> = params.snapshots.iter().map(PathBuf::from).collect();
+
+        let pipeline = get_indexing_pipeline();
+        let mut pipeline_guard = pipeline.lock().await;
+        let report = pipeline_guard
+            .merge_snapshots(
+                &snapshots,
+                params.output_path.as_deref().map(Path::new),
+                load,
+            )
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to merge indexes: {}", e),
+                    None,
+                )
+            })?;
+        if load && report.root_path.is_dir() {
+            PathResolver::register_root(&report.root_path);
+        }
+
+        let response = MergeIndexesResponse {
+            report,
+            loaded: load,
+            output_path: params.output_path,
+            duration_ms: start_time.elapsed().as_millis() as u64,
+        };
+
+        encode_response(&response)
+    }
+
+    async fn diff_public_api(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: DiffPublicApiRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+        let format = match params.format.as_deref() {
+            None => ReportFormat::Markdown,
+            Some(name) => ReportFormat::from_name(name).ok_or_else(|| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Unknown format '{}'. Expected markdown or text", name),
+                    None,
+                )
+            })?,
+        };
+
+        let read_error = |e: Box<dyn std::error::Error>| {
+            ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!("Failed to read snapshot: {}", e),
+                None,
+            )
+        };
+        let versions = [
+            ("old", &params.old_snapshot, &params.old_ref),
+            ("new", &params.new_snapshot, &params.new_ref),
+        ];
+        for (side, snapshot, rev) in versions {
+            if snapshot.is_some() && rev.is_some() {
+                return Err(ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Give either {side}_snapshot or {side}_ref, not both"),
+                    None,
+                ));
+            }
+        }
+        if params.old_snapshot.is_none() && params.old_ref.is_none() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                "Missing old_snapshot or old_ref",
+                None,
+            ));
+        }
+
+        // Each version is a snapshot file, the index of a git ref or, for
+        // the new version by default, the current index
+        let pipeline = get_indexing_pipeline();
+        let mut symbols_by_version: Vec<Vec<Symbol>> = Vec::new();
+        for (_, snapshot, rev) in versions {
+            let symbols = match (snapshot, rev) {
+                (Some(path), _) => pipeline
+                    .lock()
+                    .await
+                    .read_snapshot(Path::new(path))
+                    .await
+                    .map_err(read_error)?
+                    .symbol_data
+                    .into_values()
+                    .collect(),
+                (None, rev) => {
+                    let store = match rev {
+                        Some(rev) => ref_index(rev).await?,
+                        None => get_symbol_store(),
+                    };
+                    let _snapshot = store.read_snapshot();
+                    store
+                        .symbol_data
+                        .iter()
+                        .map(|entry| entry.value().clone())
+                        .collect()
+                }
+            };
+            symbols_by_version.push(symbols);
+        }
+        let changes = diff_public_api(&symbols_by_version[0], &symbols_by_version[1]);
+
+        let count = |kind: ApiChangeKind| changes.iter().filter(|c| c.change == kind).count();
+        let title = params.title.as_deref().unwrap_or("Public API changes");
+        let response = DiffPublicApiResponse {
+            added: count(ApiChangeKind::Added),
+            removed: count(ApiChangeKind::Removed),
+            changed: count(ApiChangeKind::Changed),
+            breaking: changes.iter().filter(|c| c.breaking()).count(),
+            report: render_report(&changes, format, title),
+        };
+
+        encode_response(&response)
+    }
 
-impl CodeAnalysisTools {
-    async fn index_code(
+    async fn get_api_hash(
         &self,
         arguments: Option<Map<String, Value>>,
     ) -> Result<CallToolResult, ErrorData> {
-        let start_time = Instant::now();
-
         let args = arguments
             .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
-        let params: IndexCodeRequest =
+        let params: GetApiHashRequest =
             serde_json::from_value(Value::Object(args)).map_err(|e| {
                 ErrorData::new(
                     ErrorCode::INVALID_PARAMS,
@@ -538,53 +3803,109 @@ impl CodeAnalysisTools {
                 )
             })?;
 
-        // Use comprehensive path resolution
-        let path = PathResolver::resolve_file_or_directory_path(&params.path)?;
+        let store = match &params.rev {
+            Some(rev) => ref_index(rev).await?,
+            None => get_symbol_store(),
+        };
+        let (package, symbols) = package_symbols(&store, &params.package);
+        if symbols.is_empty() {
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "No indexed symbols for '{}'. Pass an indexed directory or a package name from list_packages",
+                    params.package
+                ),
+                None,
+            ));
+        }
+        let surface = api_surface(&symbols);
 
-        let pipeline = get_indexing_pipeline();
-        let mut pipeline_guard = pipeline.lock().await;
+        let response = GetApiHashResponse {
+            package,
+            rev: params.rev,
+            hash: api_hash(&surface),
+            symbols: surface.len(),
+            surface: params.include_surface.unwrap_or(false).then_some(surface),
+        };
+        encode_response(&response)
+    }
 
-        let result = if path.is_file() {
-            pipeline_guard.index_file(&path).await.map_err(|e| {
+    async fn validate_index(
+        &self,
+        arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ValidateIndexRequest =
+            serde_json::from_value(Value::Object(arguments.unwrap_or_default())).map_err(|e| {
                 ErrorData::new(
-                    ErrorCode::INTERNAL_ERROR,
-                    format!("Failed to index file: {}", e),
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
                     None,
                 )
             })?;
-            (1, get_symbol_store().get_symbol_count() as u32)
-        } else {
-            let index_result = pipeline_guard.index_directory(&path).await;
-            (index_result.files_processed, index_result.symbols_found)
-        };
+        let repair = params.repair.unwrap_or(false);
+        let limit = params.limit.unwrap_or(100).max(1) as usize;
 
-        // Start file watching for directories
-        if path.is_dir() {
-            if let Err(e) = start_file_watcher(path.clone()).await {
-                tracing::warn!("Failed to start file watcher for {:?}: {}", path, e);
-                // Don't fail the indexing if file watching fails
+        let store = get_symbol_store();
+        // Line counts of the tracked files still on disk, to bound spans
+        let files: Vec<PathBuf> = store.files.iter().map(|entry| entry.key().clone()).collect();
+        let mut line_counts = HashMap::new();
+        for file in files {
+            if cancel.is_cancelled() {
+                return Err(cancelled_error(CodeAnalysisError::Cancelled {
+                    operation: "validate_index".to_string(),
+                }));
+            }
+            if let Ok(bytes) = tokio::fs::read(&file).await {
+                let newlines = bytes.iter().filter(|b| **b == b'\n').count();
+                let unterminated = bytes.last().is_some_and(|b| *b != b'\n');
+                line_counts.insert(file, (newlines + unterminated as usize) as u32);
             }
         }
 
-        let duration = start_time.elapsed();
-
-        let response = IndexCodeResponse {
-            status: "success".to_string(),
-            files_indexed: result.0,
-            symbols_found: result.1,
-            errors: vec![],
-            duration_ms: duration.as_millis() as u64,
+        let validation = {
+            let _snapshot = store.read_snapshot();
+            validate_index(&store, &line_counts)
+        };
+        let (repaired, remaining_issues) = if repair && !validation.issues.is_empty() {
+            let repaired = {
+                let _update = store.begin_update();
+                repair_index(&store)
+            };
+            let _snapshot = store.read_snapshot();
+            let remaining = validate_index(&store, &line_counts).issues.len();
+            tracing::info!(
+                "validate_index dropped {} dangling edges and entries, {} issues remain",
+                repaired,
+                remaining
+            );
+            (Some(repaired), Some(remaining))
+        } else if repair {
+            (Some(0), Some(0))
+        } else {
+            (None, None)
         };
 
-        let response_text = serde_json::to_string_pretty(&response).map_err(|e| {
-            ErrorData::new(
-                ErrorCode::INTERNAL_ERROR,
-                format!("Serialization error: {}", e),
-                None,
-            )
-        })?;
+        let mut counts = BTreeMap::new();
+        for issue in &validation.issues {
+            *counts.entry(issue.kind).or_insert(0) += 1;
+        }
+        let total_issues = validation.issues.len();
+        let mut issues = validation.issues;
+        issues.truncate(limit);
 
-        Ok(CallToolResult::success(vec![Content::text(response_text)]))
+        let response = ValidateIndexResponse {
+            healthy: total_issues == 0,
+            symbols_checked: validation.symbols_checked,
+            references_checked: validation.references_checked,
+            files_checked: validation.files_checked,
+            counts,
+            issues,
+            total_issues,
+            repaired,
+            remaining_issues,
+        };
+        encode_response(&response)
     }
 
     async fn get_symbol(
@@ -602,15 +3923,81 @@ impl CodeAnalysisTools {
                 )
             })?;
 
+        let style = parse_signature_style(params.signature_style.as_deref())?;
+        let source_listed = params
+            .fields
+            .as_ref()
+            .is_some_and(|fields| fields.iter().any(|field| field == "source"));
+        let blame_listed = params
+            .fields
+            .as_ref()
+            .is_some_and(|fields| fields.iter().any(|field| field == "blame"));
+        let available: Vec<&str> = SYMBOL_FIELDS
+            .iter()
+            .copied()
+            .chain(["blame", "type_identity", "inferred_type", "source_chunk"])
+            .collect();
+        // Cut source is useless without the cursor to the rest
+        let mut fields = params.fields;
+        if let Some(fields) = fields.as_mut().filter(|_| source_listed) {
+            if !fields.iter().any(|field| field == "source_chunk") {
+                fields.push("source_chunk".to_string());
+            }
+        }
+        let projection = FieldProjection::parse(fields, &available)?;
+
         let store = get_symbol_store();
-        let mut symbols = store.get_symbols(&params.name);
+        let mut symbols = {
+            let _snapshot = store.read_snapshot();
+            let symbols = store.get_symbols(&params.name);
+            // Fall back to qualified-name resolution (e.g. `pkg.Name`)
+            if symbols.is_empty() {
+                store.get_symbols_qualified(&params.name)
+            } else {
+                symbols
+            }
+        };
 
-        // Add source code if requested
-        if params.include_source.unwrap_or(false) {
+        // Add source code if requested. Rendered definitions are cached, so
+        // repeated fetches of a popular symbol skip the file read.
+        let include_source = params.include_source.unwrap_or(false) || source_listed;
+        // Declarations without bodies are not cached; the cache holds full definitions
+        let bodies = params.body.unwrap_or(true);
+        if include_source && projection.includes("source") {
+            let style = if projection.includes("signature") {
+                style
+            } else {
+                SignatureStyle::Full
+            };
             for symbol in &mut symbols {
+                if bodies {
+                    if let Some(cached) = store.definition_cache.get(symbol.id, style) {
+                        *symbol = cached;
+                        continue;
+                    }
+                }
+                // Taken before the read so a re-index in between is not cached
+                let generation = store.definition_cache.generation(&symbol.location.file);
                 if symbol.source.is_none() {
                     // Try to read source code from file
                     if let Ok(content) = tokio::fs::read_to_string(&symbol.location.file).await {
+                        let declaration = if bodies {
+                            None
+                        } else {
+                            Language::from_path(&symbol.location.file).and_then(|language| {
+                                declaration_text(
+                                    &content,
+                                    language,
+                                    &symbol.location,
+                                    &symbol.symbol_type,
+                                )
+                            })
+                        };
+                        if declaration.is_some() {
+                            symbol.source = declaration;
+                            apply_signature_style(std::slice::from_mut(symbol), style);
+                            continue;
+                        }
                         let lines: Vec<&str> = content.lines().collect();
                         let start_line = (symbol.location.start_line as usize).saturating_sub(1);
                         let end_line =
@@ -622,20 +4009,130 @@ impl CodeAnalysisTools {
                         }
                     }
                 }
+                apply_signature_style(std::slice::from_mut(symbol), style);
+                if bodies && symbol.source.is_some() {
+                    store
+                        .definition_cache
+                        .insert(style, symbol.clone(), generation);
+                }
             }
+        } else if projection.includes("signature") {
+            apply_signature_style(&mut symbols, style);
+        }
+
+        let mut blame = if params.include_blame.unwrap_or(false) || blame_listed {
+            blame_symbols(&symbols).await
+        } else {
+            HashMap::new()
+        };
+        let max_source_bytes = params
+            .max_source_bytes
+            .filter(|_| bodies)
+            .map(|max| max.max(1) as usize);
+        let mut blamed = Vec::with_capacity(symbols.len());
+        for mut symbol in symbols {
+            let type_identity = if projection.includes("type_identity") {
+                go_type_identity(&store, &symbol).await
+            } else {
+                None
+            };
+            let inferred_type = if projection.includes("inferred_type") {
+                go_var_type(&store, &symbol)
+            } else {
+                None
+            };
+            let source_chunk = match (max_source_bytes, &mut symbol.source) {
+                (Some(max), Some(source)) if source.len() > max => {
+                    let (text, chunk) =
+                        source_chunk(source, symbol.id.0, symbol.location.start_line, 0, max);
+                    *source = text;
+                    Some(chunk)
+                }
+                _ => None,
+            };
+            blamed.push(BlamedSymbol {
+                blame: blame.remove(&symbol.id),
+                type_identity,
+                inferred_type,
+                source_chunk,
+                symbol,
+            });
         }
+        let symbols = blamed;
 
         let response = GetSymbolResponse { symbols };
+        projected_response(&response, "symbols", &projection, None)
+    }
 
-        let response_text = serde_json::to_string_pretty(&response).map_err(|e| {
-            ErrorData::new(
-                ErrorCode::INTERNAL_ERROR,
-                format!("Serialization error: {}", e),
-                None,
-            )
-        })?;
+    async fn get_source_chunk(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: GetSourceChunkRequest =
+            serde_json::from_value(Value::Object(args)).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+        let invalid = |message: String| ErrorData::new(ErrorCode::INVALID_PARAMS, message, None);
+        let cursor = ChunkCursor::parse(&params.cursor)
+            .ok_or_else(|| invalid(format!("Invalid cursor '{}'", params.cursor)))?;
+        let max_bytes = params
+            .max_bytes
+            .map_or(DEFAULT_CHUNK_BYTES, |max| max.max(1) as usize);
+
+        let store = get_symbol_store();
+        let symbol = store
+            .get_symbol_by_id(&SymbolId(cursor.symbol_id))
+            .ok_or_else(|| {
+                invalid(format!(
+                    "Symbol {} is no longer indexed; fetch its definition again",
+                    cursor.symbol_id
+                ))
+            })?;
+        let content = tokio::fs::read_to_string(&symbol.location.file)
+            .await
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INTERNAL_ERROR,
+                    format!("Failed to read {}: {}", symbol.location.file.display(), e),
+                    None,
+                )
+            })?;
+        let lines: Vec<&str> = content.lines().collect();
+        let start_line = (symbol.location.start_line as usize).saturating_sub(1);
+        let end_line = std::cmp::min(symbol.location.end_line as usize, lines.len());
+        let source = if start_line < end_line {
+            lines[start_line..end_line].join("\n")
+        } else {
+            String::new()
+        };
+        if source_digest(&source) != cursor.digest {
+            return Err(invalid(format!(
+                "The definition of {} changed since the cursor was issued; fetch it again",
+                symbol.name
+            )));
+        }
 
-        Ok(CallToolResult::success(vec![Content::text(response_text)]))
+        let (source, chunk) = source_chunk(
+            &source,
+            symbol.id.0,
+            symbol.location.start_line,
+            cursor.offset,
+            max_bytes,
+        );
+        let response = GetSourceChunkResponse {
+            symbol_id: symbol.id.0,
+            name: symbol.name,
+            file: symbol.location.file,
+            source,
+            chunk,
+        };
+        encode_response(&response)
     }
 
     async fn get_symbol_references(
@@ -653,24 +4150,27 @@ impl CodeAnalysisTools {
                 )
             })?;
 
+        // The references find_references gathers, of every kind and as
+        // raw records
         let store = get_symbol_store();
-        let references = store.get_references_by_name(&params.name);
+        let _snapshot = store.read_snapshot();
+        let mut targets = store.get_symbols(&params.name);
+        if params.include_aliases.unwrap_or(false) {
+            AnalysisTools::add_aliases(&store, &mut targets);
+        }
+        let references = AnalysisTools::gather_references(&store, &targets, |_| true, None)
+            .into_iter()
+            .map(|(reference, _)| reference)
+            .collect();
         let response = GetSymbolReferencesResponse { references };
 
-        let response_text = serde_json::to_string_pretty(&response).map_err(|e| {
-            ErrorData::new(
-                ErrorCode::INTERNAL_ERROR,
-                format!("Serialization error: {}", e),
-                None,
-            )
-        })?;
-
-        Ok(CallToolResult::success(vec![Content::text(response_text)]))
+        encode_response(&response)
     }
 
     async fn find_symbols(
         &self,
         arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
     ) -> Result<CallToolResult, ErrorData> {
         let args = arguments
             .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
@@ -683,57 +4183,129 @@ impl CodeAnalysisTools {
                 )
             })?;
 
-        let store = get_symbol_store();
+        let style = parse_signature_style(params.signature_style.as_deref())?;
+        let available: Vec<&str> = SYMBOL_FIELDS
+            .iter()
+            .copied()
+            .chain(["match_ranges", "aliases", "synonym", "qualified_name"])
+            .collect();
+        let projection = FieldProjection::parse(params.fields, &available)?;
+        let qualified = params.qualified.unwrap_or(false);
+
+        let store = match &params.git_ref {
+            Some(rev) => ref_index(rev).await?,
+            None => get_symbol_store(),
+        };
 
         // Apply limit with bounds checking
         let limit = params.limit.unwrap_or(10).min(50).max(1) as usize;
 
-        let fuzzy_results = store.find_symbols_fuzzy(&params.query);
-        let mut symbols: Vec<_> = fuzzy_results
-            .into_iter()
-            .map(|(symbol, _score)| symbol)
-            .collect();
+        let _snapshot = store.read_snapshot();
+        // Qualified names matched, by symbol, in qualified mode
+        let mut qualified_names: HashMap<SymbolId, String> = HashMap::new();
+        let mut search = |query: &str| -> Result<Vec<Symbol>, ErrorData> {
+            if !qualified {
+                let matches = store
+                    .find_symbols_fuzzy_cancellable(query, cancel)
+                    .map_err(cancelled_error)?;
+                return Ok(matches.into_iter().map(|(symbol, _score)| symbol).collect());
+            }
+            let matches = store
+                .find_symbols_fuzzy_qualified_cancellable(query, cancel)
+                .map_err(cancelled_error)?;
+            Ok(matches
+                .into_iter()
+                .map(|(symbol, _score, name)| {
+                    qualified_names.entry(symbol.id).or_insert(name);
+                    symbol
+                })
+                .collect())
+        };
+        let mut symbols = search(&params.query)?;
+
+        // Symbols only a synonym matches rank below every direct match
+        let mut via_synonym: HashMap<SymbolId, SynonymQuery> = HashMap::new();
+        if params.use_synonyms.unwrap_or(true) {
+            let mut seen: HashSet<SymbolId> = symbols.iter().map(|s| s.id).collect();
+            for expansion in synonyms().expand(&params.query) {
+                for symbol in search(&expansion.query)? {
+                    if seen.insert(symbol.id) {
+                        via_synonym.insert(symbol.id, expansion.clone());
+                        symbols.push(symbol);
+                    }
+                }
+            }
+        }
 
         // Filter by symbol type if specified
         if let Some(ref type_filter) = params.symbol_type {
-            let target_type = match type_filter.to_lowercase().as_str() {
-                "function" => Some(SymbolType::Function),
-                "method" => Some(SymbolType::Method),
-                "class" => Some(SymbolType::Class),
-                "struct" => Some(SymbolType::Struct),
-                "enum" => Some(SymbolType::Enum),
-                "interface" | "trait" => Some(SymbolType::Interface),
-                "constant" | "const" => Some(SymbolType::Constant),
-                "variable" | "var" => Some(SymbolType::Variable),
-                "module" | "mod" => Some(SymbolType::Module),
-                "import" => Some(SymbolType::Import),
-                _ => None,
-            };
-
-            if let Some(target_type) = target_type {
+            if let Some(target_type) = SymbolType::from_name(type_filter) {
                 symbols.retain(|s| s.symbol_type == target_type);
             }
         }
 
-        // Apply limit to results
-        symbols.truncate(limit);
+        // Filter by namespace if specified
+        if let Some(ref namespace) = params.namespace {
+            symbols.retain(|s| s.namespace.as_deref() == Some(namespace.as_str()));
+        }
 
-        let response = FindSymbolsResponse { symbols };
+        // Filter by doc comment tag if specified
+        if let Some(ref tag) = params.tag {
+            symbols.retain(|s| s.has_tag(tag));
+        }
 
-        let response_text = serde_json::to_string_pretty(&response).map_err(|e| {
-            ErrorData::new(
-                ErrorCode::INTERNAL_ERROR,
-                format!("Serialization error: {}", e),
-                None,
-            )
-        })?;
+        // Filter by annotation, decorator or attribute if specified
+        if let Some(ref attribute) = params.attribute {
+            symbols.retain(|s| has_attribute(s, attribute));
+        }
 
-        Ok(CallToolResult::success(vec![Content::text(response_text)]))
+        if !params.include_vendor.unwrap_or(false) {
+            symbols.retain(|s| !s.tags.contains_key(VENDOR_TAG));
+        }
+
+        let mut results = if params.include_aliases.unwrap_or(false) {
+            symbols
+                .into_iter()
+                .map(|symbol| (symbol, Vec::new()))
+                .collect()
+        } else {
+            fold_aliases(&store, symbols)
+        };
+
+        // Apply limit to results
+        results.truncate(limit);
+        let with_ranges = projection.includes("match_ranges");
+        let symbols = results
+            .into_iter()
+            .map(|(mut symbol, aliases)| {
+                if projection.includes("signature") {
+                    apply_signature_style(std::slice::from_mut(&mut symbol), style);
+                }
+                let expansion = via_synonym.get(&symbol.id);
+                let query = expansion.map_or(params.query.as_str(), |e| e.query.as_str());
+                let qualified_name = qualified_names.remove(&symbol.id);
+                let matched = qualified_name.as_deref().unwrap_or(&symbol.name);
+                SymbolMatch {
+                    match_ranges: if with_ranges {
+                        SymbolStore::match_ranges(matched, query)
+                    } else {
+                        Vec::new()
+                    },
+                    qualified_name,
+                    synonym: expansion.map(|e| e.matched.clone()),
+                    symbol,
+                    aliases,
+                }
+            })
+            .collect();
+        let response = FindSymbolsResponse { symbols };
+        projected_response(&response, "symbols", &projection, params.max_tokens)
     }
 
     async fn code_search(
         &self,
         arguments: Option<Map<String, Value>>,
+        cancel: &CancellationToken,
     ) -> Result<CallToolResult, ErrorData> {
         let args = arguments
             .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
@@ -746,20 +4318,53 @@ impl CodeAnalysisTools {
                 )
             })?;
 
+        let projection = FieldProjection::parse(params.fields, CODE_SEARCH_FIELDS)?;
+
         let store = get_symbol_store();
         let limit = params.max_results.or(params.limit).unwrap_or(10) as usize;
-        let context_lines = params.context_lines.unwrap_or(2) as usize;
+        // Snippets are the costly part of a result; skip them when not returned
+        let context_lines = projection
+            .includes("content_snippet")
+            .then(|| params.context_lines.unwrap_or(2) as usize);
+        let include_vendor = params.include_vendor.unwrap_or(false);
+        let search =
+            |query: &str| search_code(&store, query, limit, context_lines, include_vendor, cancel);
+
+        let _snapshot = store.read_snapshot();
+        let search_results = search(&params.query)?;
+        let mut search_results: Vec<_> = search_results
+            .into_iter()
+            .map(|result| (result, None))
+            .collect();
 
-        let search_results = store.search_code(&params.query, limit, context_lines);
+        // Files only a synonym matches rank below every direct match
+        if params.use_synonyms.unwrap_or(true) {
+            let mut seen: HashSet<PathBuf> = search_results
+                .iter()
+                .map(|(result, _)| result.file_path.clone())
+                .collect();
+            for expansion in synonyms().expand(&params.query) {
+                if search_results.len() >= limit {
+                    break;
+                }
+                for result in search(&expansion.query)? {
+                    if seen.insert(result.file_path.clone()) {
+                        search_results.push((result, Some(expansion.matched.clone())));
+                    }
+                }
+            }
+            search_results.truncate(limit);
+        }
 
         // Convert to response format
         let results: Vec<CodeSearchResult> = search_results
             .into_iter()
-            .map(|result| CodeSearchResult {
+            .map(|(result, synonym)| CodeSearchResult {
                 score: result.score,
-                file_path: result.file_path.to_string_lossy().to_string(),
+                file_path: PathResolver::display_path(&result.file_path),
                 language: result.language,
                 content_snippet: result.content_snippet,
+                synonym,
             })
             .collect();
 
@@ -767,22 +4372,273 @@ impl CodeAnalysisTools {
             total_found: results.len(),
             results,
         };
+        projected_response(&response, "results", &projection, params.max_tokens)
+    }
 
-        let response_text = serde_json::to_string_pretty(&response).map_err(|e| {
-            ErrorData::new(
-                ErrorCode::INTERNAL_ERROR,
-                format!("Serialization error: {}", e),
-                None,
+    async fn list_recent_symbols(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let args = arguments
+            .ok_or_else(|| ErrorData::new(ErrorCode::INVALID_PARAMS, "Missing arguments", None))?;
+        let params: ListRecentSymbolsRequest = serde_json::from_value(Value::Object(args))
+            .map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+
+        let since = parse_since(&params.since)
+            .map_err(|e| ErrorData::new(ErrorCode::INVALID_PARAMS, e, None))?;
+        let limit = params.limit.unwrap_or(100).min(1000).max(1) as usize;
+        let style = parse_signature_style(params.signature_style.as_deref())?;
+
+        let store = get_symbol_store();
+        if params.use_git.unwrap_or(false) {
+            return Self::list_recent_commits(since, limit, style).await;
+        }
+        let _snapshot = store.read_snapshot();
+        let files = store.get_files_modified_since(since);
+
+        let mut symbols = Vec::new();
+        let mut truncated = false;
+        'files: for (file_path, modified) in &files {
+            let mut file_symbols = store.get_symbols_by_file(file_path);
+            file_symbols.sort_by_key(|s| s.location.start_line);
+            apply_signature_style(&mut file_symbols, style);
+
+            for symbol in file_symbols {
+                if symbols.len() >= limit {
+                    truncated = true;
+                    break 'files;
+                }
+                symbols.push(RecentSymbol {
+                    file_modified: format_timestamp(*modified),
+                    last_change: None,
+                    symbol,
+                });
+            }
+        }
+
+        let response = ListRecentSymbolsResponse {
+            since: format_timestamp(since),
+            files_changed: files.len(),
+            symbols,
+            truncated,
+        };
+
+        encode_response(&response)
+    }
+
+    /// `list_recent_symbols` by commit date: symbols whose defining lines
+    /// were last committed (or edited without committing) after `since`,
+    /// newest first. Files outside git fall back to their modification time.
+    async fn list_recent_commits(
+        since: SystemTime,
+        limit: usize,
+        style: SignatureStyle,
+    ) -> Result<CallToolResult, ErrorData> {
+        let store = get_symbol_store();
+        let mut modified: HashMap<PathBuf, SystemTime> =
+            store.get_files_modified_since(since).into_iter().collect();
+
+        // Committing doesn't touch the file, so ask git which files changed
+        let roots = PathResolver::indexed_roots();
+        let committed = tokio::task::spawn_blocking(move || {
+            roots
+                .iter()
+                .filter_map(|root| git_files_committed_since(root, since).ok())
+                .flatten()
+                .collect::<Vec<_>>()
+        })
+        .await
+        .unwrap_or_default();
+        for file in committed {
+            if let Some(info) = store.get_file_info(&file) {
+                modified.entry(file).or_insert(info.last_modified);
+            }
+        }
+
+        let mut candidates: Vec<Symbol> = {
+            let _snapshot = store.read_snapshot();
+            modified
+                .keys()
+                .flat_map(|file| store.get_symbols_by_file(file))
+                .collect()
+        };
+        apply_signature_style(&mut candidates, style);
+        let mut blame = blame_symbols(&candidates).await;
+
+        // Uncommitted edits sort first, then by commit or modification time
+        let seconds = |time: SystemTime| {
+            time.duration_since(SystemTime::UNIX_EPOCH)
+                .map(|elapsed| elapsed.as_secs())
+                .unwrap_or(0)
+        };
+        let mut recent: Vec<(u64, RecentSymbol)> = candidates
+            .into_iter()
+            .filter_map(|symbol| {
+                let file_modified = modified.get(&symbol.location.file).copied()?;
+                let last_change = blame.remove(&symbol.id);
+                let changed_at = match &last_change {
+                    Some(info) if info.uncommitted => u64::MAX,
+                    Some(info) => info.timestamp,
+                    None => seconds(file_modified),
+                };
+                (changed_at >= seconds(since)).then(|| {
+                    (
+                        changed_at,
+                        RecentSymbol {
+                            file_modified: format_timestamp(file_modified),
+                            last_change,
+                            symbol,
+                        },
+                    )
+                })
+            })
+            .collect();
+        recent.sort_by(|(a_time, a), (b_time, b)| {
+            b_time.cmp(a_time).then(
+                (&a.symbol.location.file, a.symbol.location.start_line)
+                    .cmp(&(&b.symbol.location.file, b.symbol.location.start_line)),
             )
-        })?;
+        });
+        let mut symbols: Vec<RecentSymbol> = recent.into_iter().map(|(_, symbol)| symbol).collect();
+
+        let files_changed = symbols
+            .iter()
+            .map(|recent| &recent.symbol.location.file)
+            .collect::<std::collections::HashSet<_>>()
+            .len();
+        let truncated = symbols.len() > limit;
+        symbols.truncate(limit);
+
+        let response = ListRecentSymbolsResponse {
+            since: format_timestamp(since),
+            files_changed,
+            symbols,
+            truncated,
+        };
+
+        encode_response(&response)
+    }
+
+    async fn get_index_diagnostics(&self) -> Result<CallToolResult, ErrorData> {
+        let store = get_symbol_store();
+        let max_file_size_bytes = get_indexing_pipeline().lock().await.max_file_size();
+
+        let mut skipped = Vec::new();
+        let mut failed = Vec::new();
+        let mut partial = Vec::new();
+        let mut files_indexed = 0;
+        let mut first_party = SourceCounts::default();
+        let mut vendored = SourceCounts::default();
+
+        for entry in store.files.iter() {
+            let file_info = entry.value();
+            let counts = match vendor_paths().vendor_prefix(entry.key()) {
+                Some(_) => &mut vendored,
+                None => &mut first_party,
+            };
+            counts.files += 1;
+            counts.symbols += file_info.symbol_count as usize;
+            let (bucket, reason) = match &file_info.parse_status {
+                ParseStatus::Success => {
+                    files_indexed += 1;
+                    continue;
+                }
+                ParseStatus::NotParsed => continue,
+                ParseStatus::Skipped(reason) => (&mut skipped, reason.clone()),
+                ParseStatus::Failed(reason) => (&mut failed, reason.clone()),
+                ParseStatus::PartialSuccess(errors) => (&mut partial, errors.join("; ")),
+            };
+            bucket.push(FileDiagnostic {
+                file_path: PathResolver::display_path(entry.key()),
+                file_size: file_info.file_size,
+                reason,
+            });
+        }
 
-        Ok(CallToolResult::success(vec![Content::text(response_text)]))
+        for bucket in [&mut skipped, &mut failed, &mut partial] {
+            bucket.sort_by(|a, b| a.file_path.cmp(&b.file_path));
+        }
+
+        let response = IndexDiagnosticsResponse {
+            files_indexed,
+            max_file_size_bytes,
+            skipped,
+            failed,
+            partial,
+            definition_cache: store.definition_cache.get_stats(),
+            vendor_mode: vendor_paths().mode().as_str().to_string(),
+            first_party,
+            vendored,
+        };
+
+        encode_response(&response)
+    }
+
+    async fn reload_config(
+        &self,
+        arguments: Option<Map<String, Value>>,
+    ) -> Result<CallToolResult, ErrorData> {
+        let params: ReloadConfigRequest =
+            serde_json::from_value(Value::Object(arguments.unwrap_or_default())).map_err(|e| {
+                ErrorData::new(
+                    ErrorCode::INVALID_PARAMS,
+                    format!("Invalid arguments: {}", e),
+                    None,
+                )
+            })?;
+        if let Some(unknown) = params
+            .settings
+            .keys()
+            .find(|setting| setting_scope(setting).is_none())
+        {
+            let known: Vec<&str> = SETTINGS.iter().map(|(name, _)| *name).collect();
+            return Err(ErrorData::new(
+                ErrorCode::INVALID_PARAMS,
+                format!(
+                    "Unknown setting '{}'; expected one of {}",
+                    unknown,
+                    known.join(", ")
+                ),
+                None,
+            ));
+        }
+
+        for (setting, value) in params.settings {
+            set_setting(&setting, value);
+        }
+        let response = reload_index_settings().await;
+        encode_response(&response)
+    }
+
+    async fn list_packages(&self) -> Result<CallToolResult, ErrorData> {
+        let store = get_symbol_store();
+
+        let packages = store
+            .get_namespaces()
+            .into_iter()
+            .map(|(name, symbol_count, file_count)| PackageInfo {
+                name,
+                symbol_count,
+                file_count,
+            })
+            .collect();
+
+        let response = ListPackagesResponse { packages };
+
+        encode_response(&response)
     }
 }
 
 #[cfg(test)]
 mod tests {
     use crate::models::{Location, Symbol, SymbolId, SymbolType, Visibility};
+    use std::collections::BTreeMap;
     use std::io::Write;
     use tempfile::NamedTempFile;
 
@@ -811,6 +4667,10 @@ mod tests {
             namespace: None,
             visibility: Visibility::Public,
             source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
         };
 
         // Test source extraction
@@ -831,4 +4691,26 @@ mod tests {
         assert!(source.contains("test_function"));
         assert!(source.contains("println!"));
     }
+
+    #[test]
+    fn test_search_code_without_snippets() {
+        let store = crate::storage::SymbolStore::new();
+        let file_path = std::path::PathBuf::from("handler.rs");
+        store.index_file_content(
+            &file_path,
+            "fn handle() {\n    process_request();\n}",
+            "rust",
+        );
+        let cancel = tokio_util::sync::CancellationToken::new();
+
+        // A projection without content_snippet searches with no context
+        let results =
+            super::search_code(&store, "process_request", 10, None, true, &cancel).unwrap();
+        assert_eq!(results.len(), 1);
+        assert!(results[0].content_snippet.is_empty());
+
+        let results =
+            super::search_code(&store, "process_request", 10, Some(1), true, &cancel).unwrap();
+        assert!(results[0].content_snippet.contains("process_request();"));
+    }
 }
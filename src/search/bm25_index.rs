@@ -1,3 +1,4 @@
+use crate::utils::error::CodeAnalysisError;
 use bm25::{Document, Language, SearchEngine, SearchEngineBuilder};
 use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
@@ -6,6 +7,7 @@ use std::sync::{
     atomic::{AtomicUsize, Ordering},
     RwLock,
 };
+use tokio_util::sync::CancellationToken;
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct CodeDocument {
@@ -99,25 +101,63 @@ impl BM25CodeIndex {
     }
 
     pub fn search(&self, query: &str, limit: usize, context_lines: usize) -> Vec<CodeSearchResult> {
+        self.search_cancellable(query, limit, Some(context_lines), &CancellationToken::new())
+            .unwrap_or_default()
+    }
+
+    /// Search, stopping between results once `cancel` is triggered. Results
+    /// carry an empty snippet when `context_lines` is `None`.
+    pub fn search_cancellable(
+        &self,
+        query: &str,
+        limit: usize,
+        context_lines: Option<usize>,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<CodeSearchResult>, CodeAnalysisError> {
+        self.search_with_progress(query, limit, context_lines, cancel, |_| {})
+    }
+
+    /// `search_cancellable`, calling `progress` with the number of results
+    /// built so far after each one
+    fn search_with_progress(
+        &self,
+        query: &str,
+        limit: usize,
+        context_lines: Option<usize>,
+        cancel: &CancellationToken,
+        mut progress: impl FnMut(usize),
+    ) -> Result<Vec<CodeSearchResult>, CodeAnalysisError> {
+        let cancelled = || CodeAnalysisError::Cancelled {
+            operation: "code search".to_string(),
+        };
+        if cancel.is_cancelled() {
+            return Err(cancelled());
+        }
+
         let engine = self.engine.read().unwrap();
         let results = engine.search(query, limit);
         let docs = self.documents.read().unwrap();
 
-        results
-            .into_iter()
-            .filter_map(|result| {
-                docs.get(&result.document.id).map(|doc| CodeSearchResult {
+        let mut search_results = Vec::with_capacity(results.len());
+        for result in results {
+            if cancel.is_cancelled() {
+                return Err(cancelled());
+            }
+            if let Some(doc) = docs.get(&result.document.id) {
+                search_results.push(CodeSearchResult {
                     score: result.score,
                     file_path: doc.file_path.clone(),
                     language: doc.language.clone(),
-                    content_snippet: self.extract_snippet(
-                        &result.document.contents,
-                        query,
-                        context_lines,
-                    ),
-                })
-            })
-            .collect()
+                    content_snippet: context_lines
+                        .map(|context_lines| {
+                            self.extract_snippet(&result.document.contents, query, context_lines)
+                        })
+                        .unwrap_or_default(),
+                });
+            }
+            progress(search_results.len());
+        }
+        Ok(search_results)
     }
 
     fn extract_snippet(&self, content: &str, query: &str, context_lines: usize) -> String {
@@ -195,6 +235,41 @@ mod tests {
         assert_eq!(results[0].language, "rust");
     }
 
+    #[test]
+    fn test_cancelled_search() {
+        let index = BM25CodeIndex::new();
+        for i in 0..50 {
+            index.add_document(
+                PathBuf::from(format!("file_{}.rs", i)),
+                format!("fn handler_{}() {{ process_request(); }}", i),
+                "rust".to_string(),
+            );
+        }
+
+        let cancel = CancellationToken::new();
+        cancel.cancel();
+        let result = index.search_cancellable("process_request", 50, Some(2), &cancel);
+        assert!(matches!(result, Err(CodeAnalysisError::Cancelled { .. })));
+
+        // Cancelling part way through stops before the next result
+        let cancel = CancellationToken::new();
+        let mut built = 0;
+        let result = index.search_with_progress("process_request", 50, Some(2), &cancel, |count| {
+            built = count;
+            if count == 10 {
+                cancel.cancel();
+            }
+        });
+        assert!(matches!(result, Err(CodeAnalysisError::Cancelled { .. })));
+        assert_eq!(built, 10);
+
+        // An uncancelled token runs to completion
+        let results = index
+            .search_cancellable("process_request", 50, Some(2), &CancellationToken::new())
+            .unwrap();
+        assert_eq!(results.len(), 50);
+    }
+
     #[test]
     fn test_remove_document() {
         let index = BM25CodeIndex::new();
@@ -1,3 +1,5 @@
 pub mod bm25_index;
+pub mod synonyms;
 
 pub use bm25_index::*;
+pub use synonyms::*;
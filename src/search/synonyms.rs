@@ -0,0 +1,139 @@
+use crate::indexing::settings::setting_var;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+
+/// Query-time synonyms for domain terms, configured through ROBERTO_SYNONYMS
+/// as `db=database,datastore;auth=authentication`. A query token equal to a
+/// term (ignoring case) is also searched as each of its synonyms. Mappings
+/// go one way; list both directions to have them work both ways.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct SynonymMap {
+    synonyms: HashMap<String, Vec<String>>,
+}
+
+/// The substitution that produced a search result
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct MatchedSynonym {
+    /// The query token, as typed
+    pub term: String,
+    /// What it was replaced with
+    pub synonym: String,
+}
+
+/// A query with one token replaced by a synonym
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SynonymQuery {
+    pub query: String,
+    pub matched: MatchedSynonym,
+}
+
+impl SynonymMap {
+    /// Synonyms configured through ROBERTO_SYNONYMS; invalid values are
+    /// logged and ignored so queries still match directly
+    pub fn from_env() -> Self {
+        match setting_var("ROBERTO_SYNONYMS") {
+            Ok(spec) => Self::parse(&spec).unwrap_or_else(|e| {
+                tracing::warn!("Ignoring ROBERTO_SYNONYMS: {}", e);
+                Self::default()
+            }),
+            Err(_) => Self::default(),
+        }
+    }
+
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let mut synonyms: HashMap<String, Vec<String>> = HashMap::new();
+
+        for entry in spec.split(';').filter(|entry| !entry.trim().is_empty()) {
+            let (term, replacements) = entry
+                .split_once('=')
+                .ok_or_else(|| format!("expected term=synonym,... but got '{}'", entry))?;
+            let term = term.trim().to_lowercase();
+            if term.is_empty() || term.contains(char::is_whitespace) {
+                return Err(format!("invalid term '{}'", term));
+            }
+
+            let known = synonyms.entry(term.clone()).or_default();
+            for replacement in replacements
+                .split(',')
+                .map(str::trim)
+                .filter(|replacement| !replacement.is_empty())
+            {
+                if !replacement.eq_ignore_ascii_case(&term)
+                    && !known.iter().any(|k| k.eq_ignore_ascii_case(replacement))
+                {
+                    known.push(replacement.to_string());
+                }
+            }
+        }
+        synonyms.retain(|_, replacements| !replacements.is_empty());
+
+        Ok(Self { synonyms })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.synonyms.is_empty()
+    }
+
+    /// The alternative queries for `query`: each whitespace-separated token
+    /// with synonyms replaced by each of them in turn, one substitution per
+    /// query, in token then configuration order
+    pub fn expand(&self, query: &str) -> Vec<SynonymQuery> {
+        if self.is_empty() {
+            return Vec::new();
+        }
+        let tokens: Vec<&str> = query.split_whitespace().collect();
+        let mut queries = Vec::new();
+        for (index, token) in tokens.iter().enumerate() {
+            let Some(replacements) = self.synonyms.get(&token.to_lowercase()) else {
+                continue;
+            };
+            for replacement in replacements {
+                let mut replaced = tokens.clone();
+                replaced[index] = replacement;
+                queries.push(SynonymQuery {
+                    query: replaced.join(" "),
+                    matched: MatchedSynonym {
+                        term: token.to_string(),
+                        synonym: replacement.clone(),
+                    },
+                });
+            }
+        }
+        queries
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_synonyms() {
+        let map = SynonymMap::parse("db=database, datastore;Auth=authentication;;x=x").unwrap();
+        assert_eq!(map.synonyms["db"], vec!["database", "datastore"]);
+        assert_eq!(map.synonyms["auth"], vec!["authentication"]);
+        assert!(!map.synonyms.contains_key("x"));
+
+        assert!(SynonymMap::parse("db").is_err());
+        assert!(SynonymMap::parse("").unwrap().is_empty());
+    }
+
+    #[test]
+    fn test_expand_query() {
+        let map = SynonymMap::parse("db=database,store;conn=connection").unwrap();
+        let queries: Vec<(String, String, String)> = map
+            .expand("DB conn")
+            .into_iter()
+            .map(|q| (q.query, q.matched.term, q.matched.synonym))
+            .collect();
+        assert_eq!(
+            queries,
+            vec![
+                ("database conn".into(), "DB".into(), "database".into()),
+                ("store conn".into(), "DB".into(), "store".into()),
+                ("DB connection".into(), "conn".into(), "connection".into()),
+            ]
+        );
+        assert!(map.expand("user").is_empty());
+    }
+}
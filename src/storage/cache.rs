@@ -3,23 +3,59 @@ use crate::storage::store::SymbolStore;
 use bincode::{Decode, Encode};
 use serde::{Deserialize, Serialize};
 use sha2::{Digest, Sha256};
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
 use std::time::SystemTime;
 
+/// Bumped whenever the persisted symbol layout changes
+pub const CACHE_VERSION: u32 = 24;
+
 #[derive(Serialize, Deserialize, Encode, Decode)]
 pub struct PersistedIndex {
     pub version: u32,
+    /// Indexing configuration (enabled symbol kinds) the index was built with
+    pub index_config: String,
     pub created_at: SystemTime,
     pub root_path: PathBuf,
     pub symbols_by_name: HashMap<String, Vec<SymbolId>>,
     pub symbol_data: HashMap<SymbolId, Symbol>,
     pub references: HashMap<SymbolId, Vec<Reference>>,
+    /// References to names no indexed symbol carried yet, keyed by name
+    pub unresolved_references: HashMap<String, Vec<Reference>>,
     pub files: HashMap<PathBuf, FileInfo>,
 }
 
+/// A file indexed by more than one shard. The first shard listed keeps it.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct FileConflict {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub shards: Vec<String>,
+    /// Every shard saw the same content, so dropping the copies loses nothing
+    pub identical: bool,
+}
+
+/// What merging shard indexes did
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct MergeReport {
+    /// Checkout root every shard was indexed from
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub root_path: PathBuf,
+    pub shards: usize,
+    pub files: usize,
+    pub symbols: usize,
+    /// Cross-shard references linked by the final resolution pass
+    pub references_resolved: usize,
+    /// References whose name no shard defines
+    pub references_unresolved: usize,
+    pub conflicts: Vec<FileConflict>,
+    /// Symbols dropped because another shard already had their ID
+    pub id_collisions: usize,
+}
+
 pub struct CacheManager {
     cache_dir: PathBuf,
+    index_config: String,
 }
 
 impl CacheManager {
@@ -27,7 +63,10 @@ impl CacheManager {
         let cache_dir = Self::get_cache_directory()?;
         std::fs::create_dir_all(&cache_dir)?;
 
-        Ok(Self { cache_dir })
+        Ok(Self {
+            cache_dir,
+            index_config: String::new(),
+        })
     }
 
     fn get_cache_directory() -> Result<PathBuf, Box<dyn std::error::Error>> {
@@ -53,6 +92,11 @@ impl CacheManager {
         Ok(cache_dir)
     }
 
+    /// Indexing configuration that cached indexes must match to be reused
+    pub fn set_index_config(&mut self, index_config: String) {
+        self.index_config = index_config;
+    }
+
     pub fn get_cache_key(&self, root_path: &Path) -> Result<String, Box<dyn std::error::Error>> {
         let canonical_path = root_path.canonicalize()?;
         let mut hasher = Sha256::new();
@@ -75,7 +119,8 @@ impl CacheManager {
         let temp_file = cache_file.with_extension("tmp");
 
         // Create persisted index from store
-        let index = PersistedIndex::from_store(store, root_path.to_path_buf());
+        let mut index = PersistedIndex::from_store(store, root_path.to_path_buf());
+        index.index_config = self.index_config.clone();
 
         // Serialize to temporary file
         let encoded = bincode::encode_to_vec(&index, bincode::config::standard())?;
@@ -120,12 +165,19 @@ impl CacheManager {
             };
 
         // Validate cache version
-        if index.version != 1 {
+        if index.version != CACHE_VERSION {
             tracing::info!("Cache version mismatch, ignoring cache file");
             let _ = tokio::fs::remove_file(&cache_file).await;
             return Ok(None);
         }
 
+        // An index built with other enabled kinds is missing or has extra symbols
+        if index.index_config != self.index_config {
+            tracing::info!("Index configuration changed, ignoring cache file");
+            let _ = tokio::fs::remove_file(&cache_file).await;
+            return Ok(None);
+        }
+
         // Validate cache integrity
         if !self.validate_cache_integrity(&index) {
             tracing::warn!("Cache integrity check failed, removing cache");
@@ -213,12 +265,67 @@ impl CacheManager {
 
         Ok(())
     }
+
+    /// Write the store to `output` in the cache format, for merging with
+    /// indexes built elsewhere
+    pub async fn write_snapshot(
+        &self,
+        store: &SymbolStore,
+        root_path: &Path,
+        output: &Path,
+    ) -> Result<(), Box<dyn std::error::Error>> {
+        let mut index = PersistedIndex::from_store(store, root_path.to_path_buf());
+        index.index_config = self.index_config.clone();
+        self.write_index(&index, output).await
+    }
+
+    pub async fn write_index(
+        &self,
+        index: &PersistedIndex,
+        output: &Path,
+    ) -> Result<(), Box<dyn std::error::Error>> {
+        let temp_file = output.with_extension("tmp");
+        let encoded = bincode::encode_to_vec(index, bincode::config::standard())?;
+        tokio::fs::write(&temp_file, encoded).await?;
+        tokio::fs::rename(temp_file, output).await?;
+        Ok(())
+    }
+
+    /// Read an index written by `write_snapshot`. Unlike cache files, a
+    /// snapshot that does not match this server is an error, not a miss.
+    pub async fn read_snapshot(
+        &self,
+        path: &Path,
+    ) -> Result<PersistedIndex, Box<dyn std::error::Error>> {
+        let data = tokio::fs::read(path).await?;
+        let (index, _): (PersistedIndex, _) =
+            bincode::decode_from_slice(&data, bincode::config::standard())
+                .map_err(|e| format!("{} is not a valid index: {}", path.display(), e))?;
+        if index.version != CACHE_VERSION {
+            return Err(format!(
+                "{} has cache version {}, expected {}",
+                path.display(),
+                index.version,
+                CACHE_VERSION
+            )
+            .into());
+        }
+        if index.index_config != self.index_config {
+            return Err(format!(
+                "{} was built with a different indexing configuration",
+                path.display()
+            )
+            .into());
+        }
+        Ok(index)
+    }
 }
 
 impl PersistedIndex {
     pub fn from_store(store: &SymbolStore, root_path: PathBuf) -> Self {
         Self {
-            version: 1,
+            version: CACHE_VERSION,
+            index_config: String::new(),
             created_at: SystemTime::now(),
             root_path,
             symbols_by_name: store
@@ -236,6 +343,11 @@ impl PersistedIndex {
                 .iter()
                 .map(|entry| (*entry.key(), entry.value().clone()))
                 .collect(),
+            unresolved_references: store
+                .unresolved_references
+                .iter()
+                .map(|entry| (entry.key().clone(), entry.value().clone()))
+                .collect(),
             files: store
                 .files
                 .iter()
@@ -249,7 +361,10 @@ impl PersistedIndex {
         store.symbols_by_name.clear();
         store.symbol_data.clear();
         store.references.clear();
+        store.references_by_file.clear();
+        store.unresolved_references.clear();
         store.files.clear();
+        store.definition_cache.clear();
 
         // Restore data from cache
         for (name, symbol_ids) in &self.symbols_by_name {
@@ -263,7 +378,13 @@ impl PersistedIndex {
         }
 
         for (symbol_id, refs) in &self.references {
-            store.references.insert(*symbol_id, refs.clone());
+            store.add_references(*symbol_id, refs.clone());
+        }
+
+        for (name, refs) in &self.unresolved_references {
+            store
+                .unresolved_references
+                .insert(name.clone(), refs.clone());
         }
 
         for (path, file_info) in &self.files {
@@ -278,12 +399,194 @@ impl PersistedIndex {
             std::sync::atomic::Ordering::Relaxed,
         );
     }
+
+    /// Combine indexes built over disjoint parts of one checkout. Symbol IDs
+    /// hash the file path, so shards must be indexed from the same absolute
+    /// root for IDs to agree with a single full index. A file claimed by
+    /// several shards is kept from the first one listed. References left
+    /// unresolved inside a shard are linked against the merged symbols, and
+    /// references to a name defined in several shards are shared between
+    /// all of its symbols, as a full index would link them.
+    pub fn merge(shards: Vec<(String, PersistedIndex)>) -> Result<(Self, MergeReport), String> {
+        let mut shards = shards.into_iter();
+        let Some((first_label, mut merged)) = shards.next() else {
+            return Err("No indexes to merge".to_string());
+        };
+        let mut report = MergeReport {
+            shards: 1,
+            ..Default::default()
+        };
+        let mut owners: HashMap<PathBuf, Vec<String>> = merged
+            .files
+            .keys()
+            .map(|file| (file.clone(), vec![first_label.clone()]))
+            .collect();
+        let mut identical: HashMap<PathBuf, bool> = HashMap::new();
+
+        for (label, shard) in shards {
+            if shard.version != merged.version {
+                return Err(format!(
+                    "{} has cache version {}, expected {}",
+                    label, shard.version, merged.version
+                ));
+            }
+            if shard.index_config != merged.index_config {
+                return Err(format!(
+                    "{} was built with a different indexing configuration",
+                    label
+                ));
+            }
+            if shard.root_path != merged.root_path {
+                return Err(format!(
+                    "{} was indexed from {}, expected {}; symbol IDs would not match",
+                    label,
+                    shard.root_path.display(),
+                    merged.root_path.display()
+                ));
+            }
+            report.shards += 1;
+
+            let mut claimed = HashSet::new();
+            for (file, info) in shard.files {
+                let claimants = owners.entry(file.clone()).or_default();
+                claimants.push(label.clone());
+                if claimants.len() == 1 {
+                    merged.files.insert(file, info);
+                    continue;
+                }
+                let same = merged
+                    .files
+                    .get(&file)
+                    .is_some_and(|kept| kept.content_hash == info.content_hash);
+                *identical.entry(file.clone()).or_insert(true) &= same;
+                claimed.insert(file);
+            }
+            let owned = |file: &PathBuf| !claimed.contains(file);
+
+            for (id, symbol) in shard.symbol_data {
+                if !owned(&symbol.location.file) {
+                    continue;
+                }
+                if merged.symbol_data.contains_key(&id) {
+                    report.id_collisions += 1;
+                    continue;
+                }
+                merged
+                    .symbols_by_name
+                    .entry(symbol.name.clone())
+                    .or_default()
+                    .push(id);
+                merged.symbol_data.insert(id, symbol);
+            }
+            for (id, refs) in shard.references {
+                let refs: Vec<_> = refs
+                    .into_iter()
+                    .filter(|reference| owned(&reference.location.file))
+                    .collect();
+                if !refs.is_empty() {
+                    merged.references.entry(id).or_default().extend(refs);
+                }
+            }
+            for (name, refs) in shard.unresolved_references {
+                let refs: Vec<_> = refs
+                    .into_iter()
+                    .filter(|reference| owned(&reference.location.file))
+                    .collect();
+                if !refs.is_empty() {
+                    merged
+                        .unresolved_references
+                        .entry(name)
+                        .or_default()
+                        .extend(refs);
+                }
+            }
+        }
+
+        // Final pass: link references that only another shard could resolve
+        for (name, refs) in std::mem::take(&mut merged.unresolved_references) {
+            match merged.symbols_by_name.get(&name) {
+                Some(targets) if !targets.is_empty() => {
+                    for target in targets {
+                        merged
+                            .references
+                            .entry(*target)
+                            .or_default()
+                            .extend(refs.iter().map(|reference| Reference {
+                                target_symbol: *target,
+                                ..reference.clone()
+                            }));
+                    }
+                    report.references_resolved += refs.len();
+                }
+                _ => {
+                    report.references_unresolved += refs.len();
+                    merged.unresolved_references.insert(name, refs);
+                }
+            }
+        }
+        merged.share_references_by_name();
+
+        report.conflicts = owners
+            .into_iter()
+            .filter(|(_, shards)| shards.len() > 1)
+            .map(|(file, shards)| FileConflict {
+                identical: identical.get(&file).copied().unwrap_or(false),
+                file,
+                shards,
+            })
+            .collect();
+        report.conflicts.sort_by(|a, b| a.file.cmp(&b.file));
+        report.root_path = merged.root_path.clone();
+        report.files = merged.files.len();
+        report.symbols = merged.symbol_data.len();
+        merged.created_at = SystemTime::now();
+        Ok((merged, report))
+    }
+
+    /// Give every symbol of a name the references of all symbols of that
+    /// name, so a reference resolved inside one shard also reaches the
+    /// same-named symbols another shard defined
+    fn share_references_by_name(&mut self) {
+        for ids in self.symbols_by_name.values() {
+            if ids.len() < 2 {
+                continue;
+            }
+            let mut seen = HashSet::new();
+            let shared: Vec<Reference> = ids
+                .iter()
+                .filter_map(|id| self.references.get(id))
+                .flatten()
+                .filter(|reference| {
+                    seen.insert((
+                        reference.location.file.clone(),
+                        reference.location.start_line,
+                        reference.location.start_column,
+                    ))
+                })
+                .cloned()
+                .collect();
+            if shared.is_empty() {
+                continue;
+            }
+            for id in ids {
+                let linked = shared
+                    .iter()
+                    .map(|reference| Reference {
+                        target_symbol: *id,
+                        ..reference.clone()
+                    })
+                    .collect();
+                self.references.insert(*id, linked);
+            }
+        }
+    }
 }
 
 #[cfg(test)]
 mod tests {
     use super::*;
     use crate::models::{Location, SymbolType, Visibility};
+    use std::collections::BTreeMap;
     use std::sync::Arc;
     use tempfile::TempDir;
 
@@ -300,6 +603,10 @@ mod tests {
             namespace: None,
             visibility: Visibility::Public,
             source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
         }
     }
 
@@ -324,7 +631,7 @@ mod tests {
         assert!(loaded_index.is_some());
 
         let index = loaded_index.unwrap();
-        assert_eq!(index.version, 1);
+        assert_eq!(index.version, CACHE_VERSION);
         assert_eq!(index.root_path, root_path);
         assert!(index.symbol_data.len() > 0);
     }
@@ -358,6 +665,21 @@ mod tests {
         assert_eq!(symbols[0].name, "test_function");
     }
 
+    #[tokio::test]
+    async fn test_cache_rejects_other_index_config() {
+        let temp_dir = TempDir::new().unwrap();
+        let root_path = temp_dir.path();
+
+        let mut cache_manager = CacheManager::new().unwrap();
+        cache_manager.set_index_config("go=function".to_string());
+        let store = Arc::new(SymbolStore::new());
+        let _ = store.insert_symbol(create_test_symbol("test_function", "test.rs"));
+        cache_manager.save_index(&store, root_path).await.unwrap();
+
+        cache_manager.set_index_config("go=function,method".to_string());
+        assert!(cache_manager.load_index(root_path).await.unwrap().is_none());
+    }
+
     #[tokio::test]
     async fn test_cache_key_generation() {
         let cache_manager = CacheManager::new().unwrap();
@@ -396,4 +718,62 @@ mod tests {
         // Verify cache is gone
         assert!(!cache_file.exists());
     }
+
+    #[test]
+    fn test_merge_shards() {
+        let root = PathBuf::from("/repo");
+        let shard = |symbols: Vec<Symbol>, unresolved: Vec<(&str, Reference)>| {
+            let store = SymbolStore::new();
+            for symbol in symbols {
+                store.update_file_info(symbol.location.file.clone(), FileInfo::new([0; 32], 10));
+                let _ = store.insert_symbol(symbol);
+            }
+            for (name, reference) in unresolved {
+                store.add_unresolved_reference(name, reference);
+            }
+            PersistedIndex::from_store(&store, root.clone())
+        };
+        let call_in = |file: &str| Reference {
+            location: Location::new(PathBuf::from(file), 5, 4, 5, 10),
+            reference_type: crate::models::ReferenceType::Call,
+            target_symbol: SymbolId(0),
+        };
+
+        let a = shard(
+            vec![create_test_symbol("Save", "/repo/a/store.go")],
+            vec![("Load", call_in("/repo/a/store.go"))],
+        );
+        let b = shard(
+            vec![
+                create_test_symbol("Load", "/repo/b/load.go"),
+                create_test_symbol("Save", "/repo/a/store.go"),
+            ],
+            vec![],
+        );
+        let (merged, report) =
+            PersistedIndex::merge(vec![("a".to_string(), a), ("b".to_string(), b)]).unwrap();
+
+        assert_eq!(report.shards, 2);
+        assert_eq!(report.files, 2);
+        assert_eq!(report.symbols, 2);
+        assert_eq!(report.references_resolved, 1);
+        assert_eq!(report.references_unresolved, 0);
+        assert_eq!(report.conflicts.len(), 1);
+        assert_eq!(report.conflicts[0].shards, vec!["a", "b"]);
+        assert!(report.conflicts[0].identical);
+
+        let load = merged.symbols_by_name["Load"][0];
+        assert_eq!(load, SymbolId::new(&PathBuf::from("/repo/b/load.go"), 1, 0));
+        assert_eq!(merged.references[&load].len(), 1);
+        assert_eq!(merged.references[&load][0].target_symbol, load);
+        assert!(merged.unresolved_references.is_empty());
+
+        let other_root = PersistedIndex::from_store(&SymbolStore::new(), PathBuf::from("/other"));
+        let again = shard(vec![], vec![]);
+        assert!(PersistedIndex::merge(vec![
+            ("a".to_string(), again),
+            ("c".to_string(), other_root)
+        ])
+        .is_err());
+    }
 }
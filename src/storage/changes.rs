@@ -0,0 +1,209 @@
+use crate::models::{Symbol, SymbolType};
+use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+use std::sync::OnceLock;
+use tokio::sync::broadcast;
+
+/// Events a slow subscriber may fall behind by before it misses some
+const FEED_CAPACITY: usize = 256;
+
+static CHANGE_FEED: OnceLock<broadcast::Sender<FileChange>> = OnceLock::new();
+
+/// What the watcher did with a file
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum FileChangeKind {
+    Reindexed,
+    Removed,
+}
+
+/// A symbol of a changed file
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct SymbolChange {
+    pub id: u64,
+    pub name: String,
+    pub symbol_type: SymbolType,
+    pub line: u32,
+}
+
+impl From<&Symbol> for SymbolChange {
+    fn from(symbol: &Symbol) -> Self {
+        Self {
+            id: symbol.id.0,
+            name: symbol.name.clone(),
+            symbol_type: symbol.symbol_type.clone(),
+            line: symbol.location.start_line,
+        }
+    }
+}
+
+/// The symbols a re-indexed or removed file gained, lost and changed
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FileChange {
+    #[serde(serialize_with = "crate::utils::path::serialize_path")]
+    pub file: PathBuf,
+    pub change: FileChangeKind,
+    pub added: Vec<SymbolChange>,
+    pub removed: Vec<SymbolChange>,
+    /// Symbols whose signature, doc, tags or length differ; a symbol that
+    /// only moved is left out
+    pub changed: Vec<SymbolChange>,
+}
+
+impl FileChange {
+    pub fn is_empty(&self) -> bool {
+        self.added.is_empty() && self.removed.is_empty() && self.changed.is_empty()
+    }
+}
+
+/// Symbols of one file keyed by kind, receiver and name, which survive a
+/// re-index while ids and lines do not
+fn by_key(symbols: &[Symbol]) -> BTreeMap<(String, String, String), Vec<&Symbol>> {
+    let mut keyed: BTreeMap<_, Vec<&Symbol>> = BTreeMap::new();
+    for symbol in symbols {
+        let receiver = symbol
+            .signature
+            .as_ref()
+            .and_then(|signature| signature.receiver.clone())
+            .unwrap_or_default();
+        keyed
+            .entry((
+                symbol.symbol_type.as_str().to_string(),
+                receiver,
+                symbol.name.clone(),
+            ))
+            .or_default()
+            .push(symbol);
+    }
+    keyed
+}
+
+fn differs(old: &Symbol, new: &Symbol) -> bool {
+    let length = |s: &Symbol| s.location.end_line.saturating_sub(s.location.start_line);
+    old.signature.as_ref().map(|s| &s.text) != new.signature.as_ref().map(|s| &s.text)
+        || old.doc != new.doc
+        || old.tags != new.tags
+        || length(old) != length(new)
+}
+
+/// Compare a file's symbols before and after the watcher processed it.
+/// Symbols sharing a key pair up in declaration order; the rest of either
+/// side are added or removed.
+pub fn diff_file_symbols(
+    file: PathBuf,
+    change: FileChangeKind,
+    before: &[Symbol],
+    after: &[Symbol],
+) -> FileChange {
+    let (old, new) = (by_key(before), by_key(after));
+    let mut diff = FileChange {
+        file,
+        change,
+        added: Vec::new(),
+        removed: Vec::new(),
+        changed: Vec::new(),
+    };
+    for (key, old_symbols) in &old {
+        let new_symbols = new.get(key).map(Vec::as_slice).unwrap_or_default();
+        for (index, old_symbol) in old_symbols.iter().enumerate() {
+            match new_symbols.get(index) {
+                Some(new_symbol) if differs(old_symbol, new_symbol) => {
+                    diff.changed.push(SymbolChange::from(*new_symbol))
+                }
+                Some(_) => {}
+                None => diff.removed.push(SymbolChange::from(*old_symbol)),
+            }
+        }
+    }
+    for (key, new_symbols) in &new {
+        let known = old.get(key).map(Vec::len).unwrap_or(0);
+        diff.added.extend(
+            new_symbols
+                .iter()
+                .skip(known)
+                .map(|s| SymbolChange::from(*s)),
+        );
+    }
+    for symbols in [&mut diff.added, &mut diff.removed, &mut diff.changed] {
+        symbols.sort_by_key(|symbol| symbol.line);
+    }
+    diff
+}
+
+fn feed() -> &'static broadcast::Sender<FileChange> {
+    CHANGE_FEED.get_or_init(|| broadcast::channel(FEED_CAPACITY).0)
+}
+
+/// Receive every file change published from now on
+pub fn subscribe_file_changes() -> broadcast::Receiver<FileChange> {
+    feed().subscribe()
+}
+
+/// Whether anyone listens, so publishers can skip computing the diff
+pub fn has_change_listeners() -> bool {
+    feed().receiver_count() > 0
+}
+
+pub fn publish_file_change(change: FileChange) {
+    // No receivers is not an error; the change is simply unobserved
+    let _ = feed().send(change);
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, SymbolId, Visibility};
+
+    fn symbol(id: u64, name: &str, line: u32, end_line: u32) -> Symbol {
+        Symbol {
+            id: SymbolId(id),
+            name: name.to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location::new(PathBuf::from("users/service.go"), line, 1, end_line, 2),
+            namespace: Some("users".to_string()),
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    #[test]
+    fn test_diff_file_symbols() {
+        let before = vec![
+            symbol(1, "CreateUser", 10, 20),
+            symbol(2, "GetUser", 22, 30),
+            symbol(3, "deleteUser", 32, 40),
+        ];
+        // A line was added above GetUser, CreateUser grew, deleteUser went
+        let after = vec![
+            symbol(7, "CreateUser", 10, 22),
+            symbol(8, "GetUser", 24, 32),
+            symbol(9, "ListUsers", 34, 40),
+        ];
+        let diff = diff_file_symbols(
+            PathBuf::from("users/service.go"),
+            FileChangeKind::Reindexed,
+            &before,
+            &after,
+        );
+        let names =
+            |symbols: &[SymbolChange]| symbols.iter().map(|s| s.name.clone()).collect::<Vec<_>>();
+        assert_eq!(names(&diff.added), vec!["ListUsers"]);
+        assert_eq!(names(&diff.removed), vec!["deleteUser"]);
+        assert_eq!(names(&diff.changed), vec!["CreateUser"]);
+        assert_eq!(diff.changed[0].id, 7);
+
+        let removed = diff_file_symbols(
+            PathBuf::from("users/service.go"),
+            FileChangeKind::Removed,
+            &after,
+            &[],
+        );
+        assert_eq!(removed.removed.len(), 3);
+        assert!(removed.added.is_empty() && !removed.is_empty());
+    }
+}
@@ -0,0 +1,309 @@
+use crate::indexing::settings::setting_var;
+use crate::models::{SignatureStyle, Symbol, SymbolId};
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use std::path::PathBuf;
+use std::sync::Mutex;
+
+type CacheKey = (SymbolId, SignatureStyle);
+
+/// Rough per-entry overhead on top of the cached text
+const ENTRY_OVERHEAD_BYTES: usize = 256;
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct DefinitionCacheConfig {
+    /// Maximum number of cached definitions; 0 disables the cache
+    pub max_entries: usize,
+    /// Maximum estimated size of all cached definitions
+    pub max_bytes: usize,
+}
+
+impl Default for DefinitionCacheConfig {
+    fn default() -> Self {
+        Self {
+            max_entries: 1000,
+            max_bytes: 16 * 1024 * 1024,
+        }
+    }
+}
+
+impl DefinitionCacheConfig {
+    /// Read ROBERTO_DEFINITION_CACHE_ENTRIES and ROBERTO_DEFINITION_CACHE_MB
+    pub fn from_env() -> Self {
+        let defaults = Self::default();
+        let max_entries = setting_var("ROBERTO_DEFINITION_CACHE_ENTRIES")
+            .ok()
+            .and_then(|s| s.parse().ok())
+            .unwrap_or(defaults.max_entries);
+        let max_bytes = setting_var("ROBERTO_DEFINITION_CACHE_MB")
+            .ok()
+            .and_then(|s| s.parse::<usize>().ok())
+            .map(|mb| mb * 1024 * 1024)
+            .unwrap_or(defaults.max_bytes);
+        Self {
+            max_entries,
+            max_bytes,
+        }
+    }
+}
+
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DefinitionCacheStats {
+    pub entries: usize,
+    pub bytes: usize,
+    pub max_entries: usize,
+    pub max_bytes: usize,
+    pub hits: u64,
+    pub misses: u64,
+    pub evictions: u64,
+}
+
+struct CacheEntry {
+    symbol: Symbol,
+    bytes: usize,
+    last_used: u64,
+}
+
+#[derive(Default)]
+struct CacheState {
+    entries: HashMap<CacheKey, CacheEntry>,
+    by_file: HashMap<PathBuf, HashSet<CacheKey>>,
+    /// Invalidation count at each file's last invalidation
+    generations: HashMap<PathBuf, u64>,
+    /// Invalidation count at the last `clear`
+    cleared_at: u64,
+    invalidations: u64,
+    bytes: usize,
+    tick: u64,
+    hits: u64,
+    misses: u64,
+    evictions: u64,
+}
+
+/// LRU cache of symbols with their source loaded and signature rendered, so
+/// popular definitions are not re-read from disk on every fetch. Entries are
+/// dropped when their file is re-indexed or removed.
+pub struct DefinitionCache {
+    config: DefinitionCacheConfig,
+    state: Mutex<CacheState>,
+}
+
+impl DefinitionCache {
+    pub fn new(config: DefinitionCacheConfig) -> Self {
+        Self {
+            config,
+            state: Mutex::new(CacheState::default()),
+        }
+    }
+
+    pub fn from_env() -> Self {
+        Self::new(DefinitionCacheConfig::from_env())
+    }
+
+    /// Cached definition of `id` rendered with `style`, counting a hit or miss
+    pub fn get(&self, id: SymbolId, style: SignatureStyle) -> Option<Symbol> {
+        let mut state = self.state.lock().unwrap();
+        state.tick += 1;
+        let tick = state.tick;
+        match state.entries.get_mut(&(id, style)) {
+            Some(entry) => {
+                entry.last_used = tick;
+                let symbol = entry.symbol.clone();
+                state.hits += 1;
+                Some(symbol)
+            }
+            None => {
+                state.misses += 1;
+                None
+            }
+        }
+    }
+
+    /// Generation of the cached definitions from `file_path`. Take it before
+    /// reading a definition and pass it to `insert`.
+    pub fn generation(&self, file_path: &PathBuf) -> u64 {
+        self.state.lock().unwrap().generation(file_path)
+    }
+
+    /// Cache a rendered definition, evicting the least recently used entries
+    /// to stay within the configured limits. The definition is dropped when
+    /// its file was invalidated since `generation` was taken, as it may have
+    /// been read before the re-index.
+    pub fn insert(&self, style: SignatureStyle, symbol: Symbol, generation: u64) {
+        let bytes = Self::estimate_size(&symbol);
+        if self.config.max_entries == 0 || bytes > self.config.max_bytes {
+            return;
+        }
+
+        let mut state = self.state.lock().unwrap();
+        if state.generation(&symbol.location.file) != generation {
+            return;
+        }
+        let key = (symbol.id, style);
+        state.remove_key(&key);
+
+        state.tick += 1;
+        let last_used = state.tick;
+        let file = symbol.location.file.clone();
+        state.entries.insert(
+            key,
+            CacheEntry {
+                symbol,
+                bytes,
+                last_used,
+            },
+        );
+        state.by_file.entry(file).or_default().insert(key);
+        state.bytes += bytes;
+
+        while state.entries.len() > self.config.max_entries || state.bytes > self.config.max_bytes {
+            let Some(oldest) = state
+                .entries
+                .iter()
+                .min_by_key(|(_, entry)| entry.last_used)
+                .map(|(key, _)| *key)
+            else {
+                break;
+            };
+            state.remove_key(&oldest);
+            state.evictions += 1;
+        }
+    }
+
+    /// Drop every cached definition from `file_path`
+    pub fn invalidate_file(&self, file_path: &PathBuf) {
+        let mut state = self.state.lock().unwrap();
+        state.invalidations += 1;
+        let invalidations = state.invalidations;
+        state.generations.insert(file_path.clone(), invalidations);
+        if let Some(keys) = state.by_file.remove(file_path) {
+            for key in keys {
+                if let Some(entry) = state.entries.remove(&key) {
+                    state.bytes -= entry.bytes;
+                }
+            }
+        }
+    }
+
+    /// Drop every cached definition, keeping the hit and miss counters
+    pub fn clear(&self) {
+        let mut state = self.state.lock().unwrap();
+        state.entries.clear();
+        state.by_file.clear();
+        state.generations.clear();
+        state.invalidations += 1;
+        state.cleared_at = state.invalidations;
+        state.bytes = 0;
+    }
+
+    pub fn get_stats(&self) -> DefinitionCacheStats {
+        let state = self.state.lock().unwrap();
+        DefinitionCacheStats {
+            entries: state.entries.len(),
+            bytes: state.bytes,
+            max_entries: self.config.max_entries,
+            max_bytes: self.config.max_bytes,
+            hits: state.hits,
+            misses: state.misses,
+            evictions: state.evictions,
+        }
+    }
+
+    fn estimate_size(symbol: &Symbol) -> usize {
+        ENTRY_OVERHEAD_BYTES
+            + symbol.name.len()
+            + symbol.source.as_ref().map_or(0, |source| source.len())
+            + symbol
+                .signature
+                .as_ref()
+                .map_or(0, |signature| signature.text.len())
+    }
+}
+
+impl Default for DefinitionCache {
+    fn default() -> Self {
+        Self::new(DefinitionCacheConfig::default())
+    }
+}
+
+impl CacheState {
+    fn generation(&self, file: &PathBuf) -> u64 {
+        let invalidated = self.generations.get(file).copied().unwrap_or(0);
+        invalidated.max(self.cleared_at)
+    }
+
+    fn remove_key(&mut self, key: &CacheKey) {
+        let Some(entry) = self.entries.remove(key) else {
+            return;
+        };
+        self.bytes -= entry.bytes;
+        let file = &entry.symbol.location.file;
+        if let Some(keys) = self.by_file.get_mut(file) {
+            keys.remove(key);
+            if keys.is_empty() {
+                self.by_file.remove(file);
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{Location, SymbolType, Visibility};
+    use std::collections::BTreeMap;
+
+    fn symbol(name: &str, file: &str, line: u32) -> Symbol {
+        let path = PathBuf::from(file);
+        Symbol {
+            id: SymbolId::new(&path, line, 0),
+            name: name.to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location {
+                file: path,
+                start_line: line,
+                start_column: 0,
+                end_line: line + 2,
+                end_column: 1,
+            },
+            namespace: None,
+            visibility: Visibility::Public,
+            source: Some(format!("func {}() {{}}", name)),
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    #[test]
+    fn test_definition_cache_lru_and_invalidation() {
+        let cache = DefinitionCache::new(DefinitionCacheConfig {
+            max_entries: 2,
+            max_bytes: 1024 * 1024,
+        });
+        let create = symbol("CreateUser", "user.go", 10);
+        let delete = symbol("DeleteUser", "user.go", 20);
+        let serve = symbol("Serve", "server.go", 5);
+
+        assert!(cache.get(create.id, SignatureStyle::Full).is_none());
+        cache.insert(SignatureStyle::Full, create.clone(), 0);
+        cache.insert(SignatureStyle::Full, delete.clone(), 0);
+        assert!(cache.get(create.id, SignatureStyle::Full).is_some());
+        assert!(cache.get(create.id, SignatureStyle::Compact).is_none());
+
+        // DeleteUser is the least recently used
+        cache.insert(SignatureStyle::Full, serve.clone(), 0);
+        assert!(cache.get(delete.id, SignatureStyle::Full).is_none());
+
+        cache.invalidate_file(&PathBuf::from("user.go"));
+        assert!(cache.get(create.id, SignatureStyle::Full).is_none());
+        assert!(cache.get(serve.id, SignatureStyle::Full).is_some());
+
+        let stats = cache.get_stats();
+        assert_eq!(stats.entries, 1);
+        assert_eq!(stats.hits, 2);
+        assert_eq!(stats.misses, 4);
+        assert_eq!(stats.evictions, 1);
+    }
+}
@@ -1,5 +1,13 @@
 pub mod cache;
+pub mod changes;
+pub mod definition_cache;
+pub mod ref_indexes;
 pub mod store;
+pub mod validation;
 
 pub use cache::*;
+pub use changes::*;
+pub use definition_cache::*;
+pub use ref_indexes::*;
 pub use store::*;
+pub use validation::*;
@@ -0,0 +1,86 @@
+use crate::indexing::settings::setting_var;
+use crate::storage::store::SymbolStore;
+use std::path::PathBuf;
+use std::sync::Arc;
+
+/// The commit each indexed root was at when a revision was indexed
+pub type RefIndexKey = Vec<(PathBuf, String)>;
+
+/// Indexes of git revisions built on demand. They are keyed by commit, so a
+/// branch that moved is indexed again. The least recently used index is
+/// dropped past the capacity, configured through ROBERTO_REF_INDEX_CACHE
+/// (default 4; 0 keeps none).
+pub struct RefIndexCache {
+    capacity: usize,
+    /// Most recently used last
+    entries: Vec<(RefIndexKey, Arc<SymbolStore>)>,
+}
+
+impl RefIndexCache {
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity,
+            entries: Vec::new(),
+        }
+    }
+
+    pub fn from_env() -> Self {
+        let capacity = setting_var("ROBERTO_REF_INDEX_CACHE")
+            .ok()
+            .and_then(|s| s.trim().parse().ok())
+            .unwrap_or(4);
+        Self::new(capacity)
+    }
+
+    pub fn get(&mut self, key: &RefIndexKey) -> Option<Arc<SymbolStore>> {
+        let position = self.entries.iter().position(|(k, _)| k == key)?;
+        let entry = self.entries.remove(position);
+        let store = entry.1.clone();
+        self.entries.push(entry);
+        Some(store)
+    }
+
+    pub fn insert(&mut self, key: RefIndexKey, store: Arc<SymbolStore>) {
+        self.entries.retain(|(k, _)| *k != key);
+        self.entries.push((key, store));
+        while self.entries.len() > self.capacity {
+            let (evicted, _) = self.entries.remove(0);
+            tracing::debug!("Evicted the index of {:?}", evicted);
+        }
+    }
+
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.entries.is_empty()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn key(commit: &str) -> RefIndexKey {
+        vec![(PathBuf::from("/repo"), commit.to_string())]
+    }
+
+    #[test]
+    fn test_evicts_least_recently_used() {
+        let mut cache = RefIndexCache::new(2);
+        cache.insert(key("a1"), Arc::new(SymbolStore::new()));
+        cache.insert(key("b2"), Arc::new(SymbolStore::new()));
+        assert!(cache.get(&key("a1")).is_some());
+
+        cache.insert(key("c3"), Arc::new(SymbolStore::new()));
+        assert_eq!(cache.len(), 2);
+        assert!(cache.get(&key("b2")).is_none());
+        assert!(cache.get(&key("a1")).is_some());
+        assert!(cache.get(&key("c3")).is_some());
+
+        let mut disabled = RefIndexCache::new(0);
+        disabled.insert(key("a1"), Arc::new(SymbolStore::new()));
+        assert!(disabled.is_empty());
+    }
+}
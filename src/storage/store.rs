@@ -1,23 +1,42 @@
-use crate::models::{FileInfo, Reference, Symbol, SymbolId};
+use crate::indexing::settings::setting_var;
+use crate::indexing::type_members::base_type_name;
+use crate::models::{FileInfo, Reference, Symbol, SymbolId, SymbolType};
 use crate::search::bm25_index::{BM25CodeIndex, CodeSearchResult};
+use crate::storage::definition_cache::DefinitionCache;
+use crate::utils::error::CodeAnalysisError;
 use crate::utils::lru::LruEvictionManager;
 use crate::utils::memory::MemoryManager;
 use dashmap::DashMap;
 use fuzzy_matcher::skim::SkimMatcherV2;
 use fuzzy_matcher::FuzzyMatcher;
+use std::collections::{HashMap, HashSet};
 use std::path::PathBuf;
 use std::sync::atomic::{AtomicU64, Ordering};
-use std::sync::Arc;
+use std::sync::{Arc, RwLock, RwLockReadGuard, RwLockWriteGuard};
+use std::time::SystemTime;
+use tokio_util::sync::CancellationToken;
 
 pub struct SymbolStore {
     pub symbols_by_name: DashMap<String, Vec<SymbolId>>,
     pub symbol_data: DashMap<SymbolId, Symbol>,
     pub references: DashMap<SymbolId, Vec<Reference>>,
+    /// The targets referenced from each file, so a file's references are
+    /// found without scanning every target. A target may stay listed after
+    /// its references from the file are gone; lookups check.
+    pub references_by_file: DashMap<PathBuf, HashSet<SymbolId>>,
+    /// References whose name matched no symbol when their file was indexed,
+    /// keyed by name, linked once a symbol of that name is added
+    pub unresolved_references: DashMap<String, Vec<Reference>>,
     pub files: DashMap<PathBuf, FileInfo>,
     pub memory_usage: AtomicU64,
     pub memory_manager: Arc<MemoryManager>,
     pub lru_manager: LruEvictionManager,
     pub bm25_index: BM25CodeIndex,
+    pub definition_cache: DefinitionCache,
+    /// Shared by queries, taken exclusively while a file's symbols are
+    /// replaced so no query sees part of an update
+    update_lock: RwLock<()>,
+    consistent_reads: bool,
 }
 
 impl SymbolStore {
@@ -30,14 +49,43 @@ impl SymbolStore {
             symbols_by_name: DashMap::new(),
             symbol_data: DashMap::new(),
             references: DashMap::new(),
+            references_by_file: DashMap::new(),
+            unresolved_references: DashMap::new(),
             files: DashMap::new(),
             memory_usage: AtomicU64::new(0),
             memory_manager,
             lru_manager: LruEvictionManager::new(),
             bm25_index: BM25CodeIndex::new(),
+            definition_cache: DefinitionCache::from_env(),
+            update_lock: RwLock::new(()),
+            consistent_reads: Self::consistent_reads_from_env(),
         }
     }
 
+    /// Whether queries wait for in-flight file updates, configured through
+    /// ROBERTO_CONSISTENT_READS (default: true)
+    pub fn consistent_reads_from_env() -> bool {
+        setting_var("ROBERTO_CONSISTENT_READS")
+            .map(|value| !matches!(value.trim().to_lowercase().as_str(), "0" | "false" | "no"))
+            .unwrap_or(true)
+    }
+
+    /// Hold while a query reads the store: every file's symbols, references
+    /// and search content stay either all old or all new until the guard is
+    /// dropped. Updates wait for it, so don't hold it across an await.
+    /// `None` when consistent reads are disabled.
+    pub fn read_snapshot(&self) -> Option<RwLockReadGuard<'_, ()>> {
+        self.consistent_reads
+            .then(|| self.update_lock.read().unwrap_or_else(|e| e.into_inner()))
+    }
+
+    /// Hold while replacing or removing a file's contents; waits for running
+    /// queries to finish and keeps new ones out until dropped
+    pub fn begin_update(&self) -> Option<RwLockWriteGuard<'_, ()>> {
+        self.consistent_reads
+            .then(|| self.update_lock.write().unwrap_or_else(|e| e.into_inner()))
+    }
+
     /// O(1) symbol lookup by name
     pub fn get_symbols(&self, name: &str) -> Vec<Symbol> {
         let symbols = if let Some(symbol_ids) = self.symbols_by_name.get(name) {
@@ -63,6 +111,117 @@ impl SymbolStore {
         symbols
     }
 
+    /// Resolve a qualified name such as `pkg.Name` or `module::Name`
+    pub fn get_symbols_qualified(&self, qualified_name: &str) -> Vec<Symbol> {
+        let split = qualified_name
+            .rfind("::")
+            .map(|idx| (&qualified_name[..idx], &qualified_name[idx + 2..]))
+            .or_else(|| {
+                qualified_name
+                    .rfind('.')
+                    .map(|idx| (&qualified_name[..idx], &qualified_name[idx + 1..]))
+            });
+
+        let Some((qualifier, name)) = split else {
+            return Vec::new();
+        };
+
+        self.get_symbols(name)
+            .into_iter()
+            .filter(|symbol| {
+                symbol.namespace.as_deref().is_some_and(|namespace| {
+                    namespace == qualifier
+                        || namespace.ends_with(&format!(".{}", qualifier))
+                        || namespace.ends_with(&format!("::{}", qualifier))
+                })
+            })
+            .collect()
+    }
+
+    /// The symbol an alias or re-export stands for, following chains of
+    /// re-exports. Qualified targets (`pkg.U`) are looked up in that
+    /// package, others preferably in the alias's own namespace. `None` when
+    /// `symbol` is no alias or its target is not indexed, as for aliases of
+    /// standard library types.
+    pub fn resolve_alias(&self, symbol: &Symbol) -> Option<Symbol> {
+        let mut seen = HashSet::from([symbol.id]);
+        let mut resolved: Option<Symbol> = None;
+        let mut current = symbol;
+        while let Some(target) = &current.alias_of {
+            let candidates: Vec<Symbol> = if target.contains('.') || target.contains("::") {
+                self.get_symbols_qualified(target)
+            } else {
+                self.get_symbols(target)
+            }
+            .into_iter()
+            .filter(|candidate| {
+                !seen.contains(&candidate.id) && candidate.symbol_type != SymbolType::Import
+            })
+            .collect();
+            let same_namespace = |candidate: &&Symbol| candidate.namespace == current.namespace;
+            let next = candidates
+                .iter()
+                .filter(|candidate| candidate.alias_of.is_none())
+                .find(same_namespace)
+                .or_else(|| candidates.iter().find(|c| c.alias_of.is_none()))
+                .or_else(|| candidates.iter().find(same_namespace))
+                .or_else(|| candidates.first());
+            let Some(next) = next else {
+                break;
+            };
+            seen.insert(next.id);
+            current = resolved.insert(next.clone());
+        }
+        resolved
+    }
+
+    /// Aliases and re-exports that resolve to `target`, through chains of
+    /// them included
+    pub fn get_aliases(&self, target: &Symbol) -> Vec<Symbol> {
+        let aliases: Vec<Symbol> = self
+            .symbol_data
+            .iter()
+            .filter(|entry| entry.value().alias_of.is_some())
+            .map(|entry| entry.value().clone())
+            .collect();
+        aliases
+            .into_iter()
+            .filter(|alias| {
+                self.resolve_alias(alias)
+                    .is_some_and(|resolved| resolved.id == target.id)
+            })
+            .collect()
+    }
+
+    /// Get all namespaces with their symbol and file counts
+    pub fn get_namespaces(&self) -> Vec<(String, usize, usize)> {
+        let mut namespaces: std::collections::BTreeMap<String, (usize, HashSet<PathBuf>)> =
+            std::collections::BTreeMap::new();
+
+        for entry in self.symbol_data.iter() {
+            let symbol = entry.value();
+            if let Some(namespace) = &symbol.namespace {
+                let (count, files) = namespaces.entry(namespace.clone()).or_default();
+                *count += 1;
+                files.insert(symbol.location.file.clone());
+            }
+        }
+
+        namespaces
+            .into_iter()
+            .map(|(name, (count, files))| (name, count, files.len()))
+            .collect()
+    }
+
+    /// Get all symbols of a given type
+    pub fn get_symbols_by_type(&self, symbol_type: &SymbolType) -> Vec<Symbol> {
+        self.symbol_data
+            .iter()
+            .filter(|entry| entry.value().symbol_type == *symbol_type)
+            .map(|entry| entry.value().clone())
+            .collect()
+    }
+
     /// Find symbols by prefix matching
     pub fn find_symbols_by_prefix(&self, prefix: &str) -> Vec<Symbol> {
         let mut results = Vec::new();
@@ -82,6 +241,10 @@ impl SymbolStore {
 
     /// Add a reference for a symbol
     pub fn add_reference(&self, symbol_id: SymbolId, reference: Reference) {
+        self.references_by_file
+            .entry(reference.location.file.clone())
+            .or_default()
+            .insert(symbol_id);
         self.references
             .entry(symbol_id)
             .or_insert_with(Vec::new)
@@ -90,10 +253,36 @@ impl SymbolStore {
 
     /// Find symbols using fuzzy matching
     pub fn find_symbols_fuzzy(&self, query: &str) -> Vec<(Symbol, i64)> {
-        let matcher = SkimMatcherV2::default();
+        self.find_symbols_fuzzy_cancellable(query, &CancellationToken::new())
+            .unwrap_or_default()
+    }
+
+    /// Fuzzy matching that stops scanning once `cancel` is triggered
+    pub fn find_symbols_fuzzy_cancellable(
+        &self,
+        query: &str,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<(Symbol, i64)>, CodeAnalysisError> {
+        self.find_symbols_fuzzy_with_progress(query, cancel, |_| {})
+    }
+
+    /// `find_symbols_fuzzy_cancellable`, calling `progress` with the number
+    /// of names scanned so far after each one
+    fn find_symbols_fuzzy_with_progress(
+        &self,
+        query: &str,
+        cancel: &CancellationToken,
+        mut progress: impl FnMut(usize),
+    ) -> Result<Vec<(Symbol, i64)>, CodeAnalysisError> {
+        let matcher = SkimMatcherV2::default().ignore_case();
         let mut results = Vec::new();
 
-        for entry in self.symbols_by_name.iter() {
+        for (scanned, entry) in self.symbols_by_name.iter().enumerate() {
+            if cancel.is_cancelled() {
+                return Err(CodeAnalysisError::Cancelled {
+                    operation: "symbol search".to_string(),
+                });
+            }
             if let Some(score) = matcher.fuzzy_match(entry.key(), query) {
                 for symbol_id in entry.value() {
                     if let Some(symbol_entry) = self.symbol_data.get(symbol_id) {
@@ -101,11 +290,130 @@ impl SymbolStore {
                     }
                 }
             }
+            progress(scanned + 1);
         }
 
         // Sort by score (higher is better)
         results.sort_by(|a, b| b.1.cmp(&a.1));
-        results
+        Ok(results)
+    }
+
+    /// Fuzzy matching against qualified names, `Owner.Method` for methods
+    /// and `namespace.Name` for everything else, so one query can narrow by
+    /// both parts. Returns each match with the qualified name it matched.
+    pub fn find_symbols_fuzzy_qualified_cancellable(
+        &self,
+        query: &str,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<(Symbol, i64, String)>, CodeAnalysisError> {
+        let matcher = SkimMatcherV2::default().ignore_case();
+        let cancelled = || CodeAnalysisError::Cancelled {
+            operation: "symbol search".to_string(),
+        };
+
+        // Type declarations by file, for methods owned by containment
+        let mut types_by_file: HashMap<PathBuf, Vec<(u32, u32, String)>> = HashMap::new();
+        for entry in self.symbol_data.iter() {
+            if cancel.is_cancelled() {
+                return Err(cancelled());
+            }
+            let symbol = entry.value();
+            if matches!(
+                symbol.symbol_type,
+                SymbolType::Class | SymbolType::Struct | SymbolType::Interface | SymbolType::Enum
+            ) {
+                types_by_file
+                    .entry(symbol.location.file.clone())
+                    .or_default()
+                    .push((
+                        symbol.location.start_line,
+                        symbol.location.end_line,
+                        symbol.name.clone(),
+                    ));
+            }
+        }
+
+        let mut results = Vec::new();
+        for entry in self.symbol_data.iter() {
+            if cancel.is_cancelled() {
+                return Err(cancelled());
+            }
+            let symbol = entry.value();
+            let qualified = Self::qualified_name(symbol, &types_by_file);
+            if let Some(score) = matcher.fuzzy_match(&qualified, query) {
+                results.push((symbol.clone(), score, qualified));
+            }
+        }
+
+        results.sort_by(|a, b| b.1.cmp(&a.1));
+        Ok(results)
+    }
+
+    /// The owner of a method is its typed receiver (`p *Conn`), else the
+    /// innermost type declared around it; `self` receivers say nothing.
+    /// `types_by_file` holds the line range and name of each file's types.
+    fn qualified_name(
+        symbol: &Symbol,
+        types_by_file: &HashMap<PathBuf, Vec<(u32, u32, String)>>,
+    ) -> String {
+        let owner = match symbol.symbol_type {
+            SymbolType::Method => {
+                let receiver = symbol
+                    .signature
+                    .as_ref()
+                    .and_then(|signature| signature.receiver.as_deref())
+                    .filter(|receiver| receiver.contains(' '))
+                    .map(base_type_name);
+                let location = &symbol.location;
+                receiver.or_else(|| {
+                    types_by_file
+                        .get(&location.file)?
+                        .iter()
+                        .filter(|(start, end, _)| {
+                            *start <= location.start_line && location.end_line <= *end
+                        })
+                        .max_by_key(|(start, _, _)| *start)
+                        .map(|(_, _, name)| name.clone())
+                })
+            }
+            _ => None,
+        };
+        match owner.or_else(|| symbol.namespace.clone()) {
+            Some(owner) => format!("{}.{}", owner, symbol.name),
+            None => symbol.name.clone(),
+        }
+    }
+
+    /// Character ranges `[start, end)` of `name` matched by `query`.
+    /// Substring matches produce a single range; fuzzy matches one range per
+    /// run of consecutive matched characters.
+    pub fn match_ranges(name: &str, query: &str) -> Vec<(usize, usize)> {
+        if query.is_empty() {
+            return Vec::new();
+        }
+
+        let lower_name = name.to_lowercase();
+        let lower_query = query.to_lowercase();
+        if let Some(byte_start) = lower_name.find(&lower_query) {
+            let start = lower_name[..byte_start].chars().count();
+            return vec![(start, start + lower_query.chars().count())];
+        }
+
+        let indices = match SkimMatcherV2::default()
+            .ignore_case()
+            .fuzzy_indices(name, query)
+        {
+            Some((_, indices)) => indices,
+            None => return Vec::new(),
+        };
+        let mut ranges: Vec<(usize, usize)> = Vec::new();
+        for index in indices {
+            match ranges.last_mut() {
+                Some(last) if last.1 == index => last.1 = index + 1,
+                _ => ranges.push((index, index + 1)),
+            }
+        }
+        ranges
     }
 
     /// Insert symbol with memory tracking
@@ -191,6 +499,19 @@ impl SymbolStore {
             .unwrap_or_default()
     }
 
+    /// References made from a file, to any symbol
+    pub fn get_references_in_file(&self, file_path: &PathBuf) -> Vec<Reference> {
+        let targets: Vec<SymbolId> = match self.references_by_file.get(file_path) {
+            Some(targets) => targets.iter().copied().collect(),
+            None => return Vec::new(),
+        };
+        targets
+            .iter()
+            .flat_map(|target| self.get_references(target))
+            .filter(|reference| reference.location.file == *file_path)
+            .collect()
+    }
+
     /// Get all references for symbols with a given name
     pub fn get_references_by_name(&self, name: &str) -> Vec<Reference> {
         let mut all_references = Vec::new();
@@ -209,6 +530,12 @@ impl SymbolStore {
 
     /// Add multiple references efficiently
     pub fn add_references(&self, symbol_id: SymbolId, references: Vec<Reference>) {
+        for reference in &references {
+            self.references_by_file
+                .entry(reference.location.file.clone())
+                .or_default()
+                .insert(symbol_id);
+        }
         if !references.is_empty() {
             self.references
                 .entry(symbol_id)
@@ -217,17 +544,126 @@ impl SymbolStore {
         }
     }
 
+    /// Remember a reference to `name` that no indexed symbol matches yet
+    pub fn add_unresolved_reference(&self, name: &str, reference: Reference) {
+        self.unresolved_references
+            .entry(name.to_string())
+            .or_default()
+            .push(reference);
+    }
+
+    /// Link pending references to `name` to the symbols now carrying that
+    /// name, returning how many references were linked
+    pub fn resolve_pending_references(&self, name: &str) -> usize {
+        let targets = match self.symbols_by_name.get(name) {
+            Some(ids) if !ids.is_empty() => ids.clone(),
+            _ => return 0,
+        };
+        let Some((_, pending)) = self.unresolved_references.remove(name) else {
+            return 0;
+        };
+        for target in &targets {
+            let linked = pending
+                .iter()
+                .map(|reference| Reference {
+                    target_symbol: *target,
+                    ..reference.clone()
+                })
+                .collect();
+            self.add_references(*target, linked);
+        }
+        pending.len() * targets.len()
+    }
+
+    /// Remove a file's symbols together with every reference edge touching
+    /// them: references made from the file and references into it. References
+    /// into it from other files are returned with the name they were linked
+    /// by, for `relink_references` once the file's new symbols are in.
+    pub fn detach_file(&self, file_path: &PathBuf) -> Vec<(String, Reference)> {
+        let mut incoming = Vec::new();
+        for symbol in self.get_symbols_by_file(file_path) {
+            if let Some((_, references)) = self.references.remove(&symbol.id) {
+                incoming.extend(
+                    references
+                        .into_iter()
+                        .filter(|reference| reference.location.file != *file_path)
+                        .map(|reference| (symbol.name.clone(), reference)),
+                );
+            }
+        }
+        self.remove_file_references(file_path);
+        self.remove_file_symbols(file_path);
+        incoming
+    }
+
+    /// Link references detached by `detach_file` to the symbols now carrying
+    /// the name they were linked by. References to names no symbol carries
+    /// any more wait with the unresolved ones. A target never gets the same
+    /// reference twice. Returns how many references were linked.
+    pub fn relink_references(&self, references: Vec<(String, Reference)>) -> usize {
+        let same_place = |a: &Reference, b: &Reference| {
+            a.location.file == b.location.file
+                && a.location.start_line == b.location.start_line
+                && a.location.start_column == b.location.start_column
+        };
+        let mut linked = 0;
+        for (name, reference) in references {
+            let targets = self
+                .symbols_by_name
+                .get(&name)
+                .map(|ids| ids.clone())
+                .unwrap_or_default();
+            if targets.is_empty() {
+                let mut pending = self.unresolved_references.entry(name).or_default();
+                if !pending
+                    .iter()
+                    .any(|existing| same_place(existing, &reference))
+                {
+                    pending.push(reference);
+                }
+                continue;
+            }
+            for target in targets {
+                let mut existing = self.references.entry(target).or_default();
+                if existing
+                    .iter()
+                    .any(|existing| same_place(existing, &reference))
+                {
+                    continue;
+                }
+                existing.push(Reference {
+                    target_symbol: target,
+                    ..reference.clone()
+                });
+                self.references_by_file
+                    .entry(reference.location.file.clone())
+                    .or_default()
+                    .insert(target);
+                linked += 1;
+            }
+        }
+        linked
+    }
+
     /// Remove references for a specific file
     pub fn remove_file_references(&self, file_path: &PathBuf) {
-        // Remove references that point to symbols in this file
-        for mut entry in self.references.iter_mut() {
-            entry
-                .value_mut()
-                .retain(|reference| reference.location.file != *file_path);
+        // Only the symbols this file references hold references from it
+        let Some((_, targets)) = self.references_by_file.remove(file_path) else {
+            return;
+        };
+        for target in targets {
+            let emptied = match self.references.get_mut(&target) {
+                Some(mut refs) => {
+                    refs.retain(|reference| reference.location.file != *file_path);
+                    refs.is_empty()
+                }
+                None => false,
+            };
+            if emptied {
+                self.references
+                    .remove_if(&target, |_, refs| refs.is_empty());
+            }
         }
-
-        // Remove empty reference entries
-        self.references.retain(|_, refs| !refs.is_empty());
     }
 
     /// Get reference statistics
@@ -262,6 +698,19 @@ impl SymbolStore {
         self.bm25_index.search(query, limit, context_lines)
     }
 
+    /// Search code content using BM25, honouring request cancellation.
+    /// Snippets are left empty when `context_lines` is `None`.
+    pub fn search_code_cancellable(
+        &self,
+        query: &str,
+        limit: usize,
+        context_lines: Option<usize>,
+        cancel: &CancellationToken,
+    ) -> Result<Vec<CodeSearchResult>, CodeAnalysisError> {
+        self.bm25_index
+            .search_cancellable(query, limit, context_lines, cancel)
+    }
+
     /// Remove file from BM25 index
     pub fn remove_file_from_index(&self, file_path: &PathBuf) {
         let removed = self.bm25_index.remove_document(file_path);
@@ -276,6 +725,11 @@ impl SymbolStore {
             file_path
         );
         self.remove_file_from_index(file_path);
+        self.definition_cache.invalidate_file(file_path);
+        self.unresolved_references.retain(|_, pending| {
+            pending.retain(|reference| reference.location.file != *file_path);
+            !pending.is_empty()
+        });
 
         if let Some((_, _file_info)) = self.files.remove(file_path) {
             // Collect symbols to remove
@@ -344,6 +798,19 @@ impl SymbolStore {
         self.files.get(file_path).map(|entry| entry.value().clone())
     }
 
+    /// Get files modified after `since`, most recently modified first
+    pub fn get_files_modified_since(&self, since: SystemTime) -> Vec<(PathBuf, SystemTime)> {
+        let mut files: Vec<(PathBuf, SystemTime)> = self
+            .files
+            .iter()
+            .filter(|entry| entry.value().last_modified > since)
+            .map(|entry| (entry.key().clone(), entry.value().last_modified))
+            .collect();
+
+        files.sort_by(|a, b| b.1.cmp(&a.1));
+        files
+    }
+
     /// Get all symbols from a specific file
     pub fn get_symbols_by_file(&self, file_path: &PathBuf) -> Vec<Symbol> {
         // Track file access for LRU
@@ -389,7 +856,8 @@ impl SymbolStore {
 #[cfg(test)]
 mod tests {
     use super::*;
-    use crate::models::{Location, SymbolType, Visibility};
+    use crate::models::{Location, Signature, Visibility};
+    use std::collections::BTreeMap;
 
     fn create_test_symbol(name: &str, file: &str) -> Symbol {
         let path = PathBuf::from(file);
@@ -406,6 +874,10 @@ mod tests {
             namespace: None,
             visibility: Visibility::Public,
             source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
         }
     }
 
@@ -461,6 +933,95 @@ mod tests {
         assert!(names.contains(&"TestClass".to_string()));
     }
 
+    #[test]
+    fn test_match_ranges() {
+        assert_eq!(
+            SymbolStore::match_ranges("test_function", "test"),
+            vec![(0, 4)]
+        );
+        assert_eq!(
+            SymbolStore::match_ranges("NewConnection", "conn"),
+            vec![(3, 7)]
+        );
+        assert!(SymbolStore::match_ranges("handler", "").is_empty());
+
+        let ranges = SymbolStore::match_ranges("NewPostgresConnection", "NwPG");
+        assert_eq!(ranges, vec![(0, 1), (2, 4), (7, 8)]);
+    }
+
+    #[test]
+    fn test_fuzzy_search_cancellation() {
+        let store = SymbolStore::new();
+        for i in 0..1000 {
+            store.insert_symbol_unchecked(create_test_symbol(
+                &format!("handler_{}", i),
+                &format!("file_{}.rs", i),
+            ));
+        }
+
+        let cancel = CancellationToken::new();
+        cancel.cancel();
+        let result = store.find_symbols_fuzzy_cancellable("handler", &cancel);
+        assert!(matches!(result, Err(CodeAnalysisError::Cancelled { .. })));
+
+        // Cancelling part way through the scan stops it at the next name
+        let cancel = CancellationToken::new();
+        let mut scanned = 0;
+        let result = store.find_symbols_fuzzy_with_progress("handler", &cancel, |count| {
+            scanned = count;
+            if count == 100 {
+                cancel.cancel();
+            }
+        });
+        assert!(matches!(result, Err(CodeAnalysisError::Cancelled { .. })));
+        assert_eq!(scanned, 100);
+
+        let result = store.find_symbols_fuzzy_cancellable("handler", &CancellationToken::new());
+        assert_eq!(result.unwrap().len(), 1000);
+    }
+
+    #[test]
+    fn test_qualified_fuzzy_search() {
+        let mut method = create_test_symbol("ExecuteQuery", "db.go");
+        method.symbol_type = SymbolType::Method;
+        method.namespace = Some("db".to_string());
+        method.signature = Some(Signature {
+            text: "func (c *PostgresConnection) ExecuteQuery(q string) error".to_string(),
+            receiver: Some("c *PostgresConnection".to_string()),
+            type_parameters: Vec::new(),
+            parameters: Vec::new(),
+            return_type: Some("error".to_string()),
+            return_channels: Vec::new(),
+            uses_defer: false,
+            deferred: Vec::new(),
+        });
+        let mut function = create_test_symbol("ExecuteQuery", "exec.go");
+        function.namespace = Some("exec".to_string());
+        let mut python_method = create_test_symbol("execute", "repo.py");
+        python_method.symbol_type = SymbolType::Method;
+
+        let types_by_file = HashMap::from([(
+            PathBuf::from("repo.py"),
+            vec![
+                (1, 2000, "Repository".to_string()),
+                (5, 9, "Unrelated".to_string()),
+            ],
+        )]);
+        let qualified = |symbol: &Symbol| SymbolStore::qualified_name(symbol, &types_by_file);
+        assert_eq!(qualified(&method), "PostgresConnection.ExecuteQuery");
+        assert_eq!(qualified(&function), "exec.ExecuteQuery");
+        assert_eq!(qualified(&python_method), "Repository.execute");
+
+        let store = SymbolStore::new();
+        store.insert_symbol_unchecked(method);
+        store.insert_symbol_unchecked(function);
+        let results = store
+            .find_symbols_fuzzy_qualified_cancellable("PstgrExec", &CancellationToken::new())
+            .unwrap();
+        let names: Vec<&str> = results.iter().map(|(_, _, name)| name.as_str()).collect();
+        assert_eq!(names, vec!["PostgresConnection.ExecuteQuery"]);
+    }
+
     #[test]
     fn test_memory_tracking() {
         let store = SymbolStore::new();
@@ -542,6 +1103,32 @@ mod tests {
         assert_eq!(retrieved_info.content_hash, file_info.content_hash);
     }
 
+    #[test]
+    fn test_files_modified_since() {
+        use std::time::Duration;
+
+        let store = SymbolStore::new();
+        let now = SystemTime::now();
+
+        let mut old_info = FileInfo::from_file_content("fn old() {}");
+        old_info.last_modified = now - Duration::from_secs(3600);
+        let mut new_info = FileInfo::from_file_content("fn new() {}");
+        new_info.last_modified = now - Duration::from_secs(60);
+        let mut newest_info = FileInfo::from_file_content("fn newest() {}");
+        newest_info.last_modified = now;
+
+        store.update_file_info(PathBuf::from("old.rs"), old_info);
+        store.update_file_info(PathBuf::from("new.rs"), new_info);
+        store.update_file_info(PathBuf::from("newest.rs"), newest_info);
+
+        let recent = store.get_files_modified_since(now - Duration::from_secs(600));
+        let paths: Vec<PathBuf> = recent.into_iter().map(|(path, _)| path).collect();
+        assert_eq!(
+            paths,
+            vec![PathBuf::from("newest.rs"), PathBuf::from("new.rs")]
+        );
+    }
+
     #[test]
     fn test_file_change_detection() {
         let content1 = "fn test() {}";
@@ -554,6 +1141,30 @@ mod tests {
         assert!(!info1.has_changed(&info1));
     }
 
+    #[test]
+    fn test_qualified_lookup_and_namespaces() {
+        let store = SymbolStore::new();
+
+        let mut db_symbol = create_test_symbol("Open", "db/conn.go");
+        db_symbol.namespace = Some("db".to_string());
+        let mut file_symbol = create_test_symbol("Open", "fs/file.go");
+        file_symbol.namespace = Some("fs".to_string());
+
+        store.insert_symbol_unchecked(db_symbol);
+        store.insert_symbol_unchecked(file_symbol);
+
+        let resolved = store.get_symbols_qualified("db.Open");
+        assert_eq!(resolved.len(), 1);
+        assert_eq!(resolved[0].location.file, PathBuf::from("db/conn.go"));
+        assert!(store.get_symbols_qualified("net.Open").is_empty());
+
+        let namespaces = store.get_namespaces();
+        assert_eq!(
+            namespaces,
+            vec![("db".to_string(), 1, 1), ("fs".to_string(), 1, 1)]
+        );
+    }
+
     #[test]
     fn test_reference_management() {
         use crate::models::{Location, ReferenceType};
@@ -581,5 +1192,96 @@ mod tests {
         // Test references by name
         let refs_by_name = store.get_references_by_name("test_function");
         assert_eq!(refs_by_name.len(), 1);
+
+        // Removing another file's references leaves these alone
+        store.remove_file_references(&PathBuf::from("test.rs"));
+        assert_eq!(store.get_references(&symbol_id).len(), 1);
+
+        store.remove_file_references(&PathBuf::from("other.rs"));
+        assert!(store.get_references(&symbol_id).is_empty());
+        assert!(store.references.get(&symbol_id).is_none());
+    }
+
+    #[test]
+    fn test_detach_and_relink_file() {
+        use crate::models::{Location, ReferenceType};
+
+        let store = SymbolStore::new();
+        let helper = create_test_symbol("helper", "lib.rs");
+        let caller = create_test_symbol("main", "main.rs");
+        let _ = store.insert_symbol(helper.clone());
+        let _ = store.insert_symbol(caller.clone());
+        store.update_file_info(
+            PathBuf::from("lib.rs"),
+            FileInfo::from_file_content("fn helper() {}"),
+        );
+        let call = |file: &str, line: u32, target: SymbolId| Reference {
+            location: Location::new(PathBuf::from(file), line, 4, line, 10),
+            reference_type: ReferenceType::Call,
+            target_symbol: target,
+        };
+        // main.rs calls helper; lib.rs calls main
+        store.add_reference(helper.id, call("main.rs", 2, helper.id));
+        store.add_reference(caller.id, call("lib.rs", 3, caller.id));
+        let from_main = store.get_references_in_file(&PathBuf::from("main.rs"));
+        assert_eq!(from_main.len(), 1);
+        assert_eq!(from_main[0].target_symbol, helper.id);
+
+        let incoming = store.detach_file(&PathBuf::from("lib.rs"));
+        assert_eq!(incoming.len(), 1);
+        assert_eq!(incoming[0].0, "helper");
+        // The edge from lib.rs went with it
+        assert!(store.get_references(&caller.id).is_empty());
+        assert!(store
+            .get_references_in_file(&PathBuf::from("lib.rs"))
+            .is_empty());
+        assert!(store
+            .get_references_in_file(&PathBuf::from("main.rs"))
+            .is_empty());
+
+        // Renamed: nothing carries the name, so the reference waits
+        let _ = store.insert_symbol(create_test_symbol("assist", "lib.rs"));
+        assert_eq!(store.relink_references(incoming.clone()), 0);
+        assert_eq!(store.unresolved_references.get("helper").unwrap().len(), 1);
+
+        // Renamed back: linked once, from pending or by relinking
+        let _ = store.insert_symbol(helper.clone());
+        assert_eq!(store.resolve_pending_references("helper"), 1);
+        assert_eq!(store.relink_references(incoming), 0);
+        assert_eq!(store.get_references_by_name("helper").len(), 1);
+        assert_eq!(
+            store
+                .get_references_in_file(&PathBuf::from("main.rs"))
+                .len(),
+            1
+        );
+    }
+
+    #[test]
+    fn test_resolve_alias() {
+        let store = SymbolStore::new();
+        let mut user = create_test_symbol("User", "models/user.ts");
+        user.namespace = Some("models".to_string());
+        let mut export = create_test_symbol("User", "models/index.ts");
+        export.namespace = Some("models".to_string());
+        export.alias_of = Some("User".to_string());
+        let mut reexport = create_test_symbol("User", "index.ts");
+        reexport.alias_of = Some("User".to_string());
+        let mut external = create_test_symbol("Ctx", "server.go");
+        external.alias_of = Some("context.Context".to_string());
+        for symbol in [&user, &export, &reexport, &external] {
+            store.insert_symbol_unchecked(symbol.clone());
+        }
+
+        assert_eq!(store.resolve_alias(&export).unwrap().id, user.id);
+        assert_eq!(store.resolve_alias(&reexport).unwrap().id, user.id);
+        assert!(store.resolve_alias(&external).is_none());
+        assert!(store.resolve_alias(&user).is_none());
+
+        let mut aliases: Vec<SymbolId> = store.get_aliases(&user).iter().map(|a| a.id).collect();
+        aliases.sort_by_key(|id| id.0);
+        let mut expected = vec![export.id, reexport.id];
+        expected.sort_by_key(|id| id.0);
+        assert_eq!(aliases, expected);
     }
 }
@@ -0,0 +1,429 @@
+use crate::models::{Reference, SymbolId};
+use crate::storage::store::SymbolStore;
+use crate::utils::PathResolver;
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use std::path::PathBuf;
+
+/// An inconsistency `validate_index` can find
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum IssueKind {
+    /// References stored for a symbol id that no symbol has
+    DanglingReference,
+    /// A reference, linked or pending, made from a file the index no longer
+    /// tracks
+    UntrackedReferenceSource,
+    /// The same reference stored twice for one symbol
+    DuplicateReference,
+    /// The name index lists an id no symbol has, or lists it twice
+    StaleNameEntry,
+    /// A symbol the name index does not list under its name
+    UnlistedSymbol,
+    /// A symbol stored under an id other than its own
+    DuplicateId,
+    /// A symbol in a file the index no longer tracks
+    UntrackedSymbolFile,
+    /// A tracked file that is gone from disk
+    MissingFile,
+    /// A symbol whose span ends before it starts or runs past the end of
+    /// its file
+    SpanOutOfBounds,
+}
+
+impl IssueKind {
+    /// Whether repair mode fixes this kind by dropping the edge or entry
+    pub fn repairable(&self) -> bool {
+        matches!(
+            self,
+            IssueKind::DanglingReference
+                | IssueKind::UntrackedReferenceSource
+                | IssueKind::DuplicateReference
+                | IssueKind::StaleNameEntry
+        )
+    }
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct IndexIssue {
+    pub kind: IssueKind,
+    pub message: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub symbol_id: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub file: Option<String>,
+}
+
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct IndexValidation {
+    pub symbols_checked: usize,
+    pub references_checked: usize,
+    pub files_checked: usize,
+    pub issues: Vec<IndexIssue>,
+}
+
+fn issue(
+    kind: IssueKind,
+    message: String,
+    symbol_id: Option<SymbolId>,
+    file: Option<PathBuf>,
+) -> IndexIssue {
+    IndexIssue {
+        kind,
+        message,
+        symbol_id: symbol_id.map(|id| id.0),
+        file: file.map(|file| PathResolver::display_path(&file)),
+    }
+}
+
+fn same_place(a: &Reference, b: &Reference) -> bool {
+    a.location.file == b.location.file
+        && a.location.start_line == b.location.start_line
+        && a.location.start_column == b.location.start_column
+}
+
+/// Check the store's symbols, name index and reference edges against each
+/// other. `line_counts` holds the number of lines of every tracked file
+/// still on disk; tracked files missing from it are reported as gone.
+pub fn validate_index(store: &SymbolStore, line_counts: &HashMap<PathBuf, u32>) -> IndexValidation {
+    let mut validation = IndexValidation {
+        files_checked: store.files.len(),
+        ..Default::default()
+    };
+    let issues = &mut validation.issues;
+
+    for entry in store.files.iter() {
+        if !line_counts.contains_key(entry.key()) {
+            issues.push(issue(
+                IssueKind::MissingFile,
+                format!(
+                    "{} is indexed but no longer on disk",
+                    PathResolver::display_path(entry.key())
+                ),
+                None,
+                Some(entry.key().clone()),
+            ));
+        }
+    }
+
+    for entry in store.symbol_data.iter() {
+        validation.symbols_checked += 1;
+        let (id, symbol) = (*entry.key(), entry.value());
+        let file = &symbol.location.file;
+        if symbol.id != id {
+            issues.push(issue(
+                IssueKind::DuplicateId,
+                format!(
+                    "{} is stored under id {} but carries id {}",
+                    symbol.name, id.0, symbol.id.0
+                ),
+                Some(id),
+                Some(file.clone()),
+            ));
+        }
+        if !store.files.contains_key(file) {
+            issues.push(issue(
+                IssueKind::UntrackedSymbolFile,
+                format!(
+                    "{} is in {}, which is not indexed",
+                    symbol.name,
+                    PathResolver::display_path(file)
+                ),
+                Some(id),
+                Some(file.clone()),
+            ));
+        }
+        let listed = store
+            .symbols_by_name
+            .get(&symbol.name)
+            .is_some_and(|ids| ids.contains(&id));
+        if !listed {
+            issues.push(issue(
+                IssueKind::UnlistedSymbol,
+                format!("{} is missing from the name index", symbol.name),
+                Some(id),
+                Some(file.clone()),
+            ));
+        }
+
+        let location = &symbol.location;
+        let inverted = location.start_line == 0
+            || (location.end_line, location.end_column)
+                < (location.start_line, location.start_column);
+        let past_end = line_counts
+            .get(file)
+            .is_some_and(|lines| location.end_line > *lines);
+        if inverted || past_end {
+            issues.push(issue(
+                IssueKind::SpanOutOfBounds,
+                format!(
+                    "{} spans lines {}-{}{}",
+                    symbol.name,
+                    location.start_line,
+                    location.end_line,
+                    match line_counts.get(file) {
+                        Some(lines) if past_end => format!(" of a {}-line file", lines),
+                        _ => String::new(),
+                    }
+                ),
+                Some(id),
+                Some(file.clone()),
+            ));
+        }
+    }
+
+    for entry in store.symbols_by_name.iter() {
+        let mut seen = HashSet::new();
+        for id in entry.value() {
+            let problem = if !seen.insert(*id) {
+                Some("lists it twice")
+            } else {
+                match store.symbol_data.get(id) {
+                    None => Some("lists it but no symbol has it"),
+                    Some(symbol) if symbol.name != *entry.key() => {
+                        Some("lists it for a symbol with another name")
+                    }
+                    Some(_) => None,
+                }
+            };
+            if let Some(problem) = problem {
+                issues.push(issue(
+                    IssueKind::StaleNameEntry,
+                    format!("Name index entry '{}' {}", entry.key(), problem),
+                    Some(*id),
+                    None,
+                ));
+            }
+        }
+    }
+
+    for entry in store.references.iter() {
+        let target = *entry.key();
+        validation.references_checked += entry.value().len();
+        if !store.symbol_data.contains_key(&target) {
+            issues.push(issue(
+                IssueKind::DanglingReference,
+                format!(
+                    "{} references point to id {}, which no symbol has",
+                    entry.value().len(),
+                    target.0
+                ),
+                Some(target),
+                None,
+            ));
+            continue;
+        }
+        let mut untracked: HashSet<&PathBuf> = HashSet::new();
+        for (index, reference) in entry.value().iter().enumerate() {
+            if !store.files.contains_key(&reference.location.file) {
+                untracked.insert(&reference.location.file);
+            }
+            if entry.value()[..index]
+                .iter()
+                .any(|earlier| same_place(earlier, reference))
+            {
+                issues.push(issue(
+                    IssueKind::DuplicateReference,
+                    format!(
+                        "Reference at {}:{} is stored twice",
+                        PathResolver::display_path(&reference.location.file),
+                        reference.location.start_line
+                    ),
+                    Some(target),
+                    Some(reference.location.file.clone()),
+                ));
+            }
+        }
+        for file in untracked {
+            issues.push(issue(
+                IssueKind::UntrackedReferenceSource,
+                format!(
+                    "References from {}, which is not indexed",
+                    PathResolver::display_path(file)
+                ),
+                Some(target),
+                Some(file.clone()),
+            ));
+        }
+    }
+
+    for entry in store.unresolved_references.iter() {
+        validation.references_checked += entry.value().len();
+        let untracked: HashSet<&PathBuf> = entry
+            .value()
+            .iter()
+            .map(|reference| &reference.location.file)
+            .filter(|file| !store.files.contains_key(*file))
+            .collect();
+        for file in untracked {
+            issues.push(issue(
+                IssueKind::UntrackedReferenceSource,
+                format!(
+                    "Pending references to '{}' from {}, which is not indexed",
+                    entry.key(),
+                    PathResolver::display_path(file)
+                ),
+                None,
+                Some(file.clone()),
+            ));
+        }
+    }
+
+    validation.issues.sort_by(|a, b| {
+        a.kind
+            .cmp(&b.kind)
+            .then(a.file.cmp(&b.file))
+            .then(a.symbol_id.cmp(&b.symbol_id))
+    });
+    validation
+}
+
+/// Drop the dangling edges and stale entries `validate_index` reports:
+/// references to missing symbols or from untracked files, duplicate
+/// references and name index entries without a matching symbol. Symbols
+/// and files are left alone. Returns how many edges and entries went.
+pub fn repair_index(store: &SymbolStore) -> usize {
+    let mut removed = 0;
+
+    let before: usize = store
+        .references
+        .iter()
+        .map(|entry| entry.value().len())
+        .sum();
+    store
+        .references
+        .retain(|target, _| store.symbol_data.contains_key(target));
+    for mut entry in store.references.iter_mut() {
+        let mut kept: Vec<Reference> = Vec::new();
+        for reference in entry.value_mut().drain(..) {
+            if store.files.contains_key(&reference.location.file)
+                && !kept.iter().any(|earlier| same_place(earlier, &reference))
+            {
+                kept.push(reference);
+            }
+        }
+        *entry.value_mut() = kept;
+    }
+    store
+        .references
+        .retain(|_, references| !references.is_empty());
+    let after: usize = store
+        .references
+        .iter()
+        .map(|entry| entry.value().len())
+        .sum();
+    removed += before - after;
+
+    for mut entry in store.unresolved_references.iter_mut() {
+        let count = entry.value().len();
+        entry
+            .value_mut()
+            .retain(|reference| store.files.contains_key(&reference.location.file));
+        removed += count - entry.value().len();
+    }
+    store
+        .unresolved_references
+        .retain(|_, references| !references.is_empty());
+
+    for mut entry in store.symbols_by_name.iter_mut() {
+        let name = entry.key().clone();
+        let count = entry.value().len();
+        let mut seen = HashSet::new();
+        entry.value_mut().retain(|id| {
+            seen.insert(*id)
+                && store
+                    .symbol_data
+                    .get(id)
+                    .is_some_and(|symbol| symbol.name == name)
+        });
+        removed += count - entry.value().len();
+    }
+    store.symbols_by_name.retain(|_, ids| !ids.is_empty());
+
+    removed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::models::{FileInfo, Location, ReferenceType, Symbol, SymbolType, Visibility};
+    use std::collections::BTreeMap;
+
+    fn symbol(name: &str, file: &str, line: u32, end_line: u32) -> Symbol {
+        let path = PathBuf::from(file);
+        Symbol {
+            id: SymbolId::new(&path, line, 0),
+            name: name.to_string(),
+            symbol_type: SymbolType::Function,
+            location: Location::new(path, line, 0, end_line, 1),
+            namespace: Some("users".to_string()),
+            visibility: Visibility::Public,
+            source: None,
+            signature: None,
+            tags: BTreeMap::new(),
+            doc: None,
+            alias_of: None,
+        }
+    }
+
+    fn reference(file: &str, line: u32, target: SymbolId) -> Reference {
+        Reference {
+            location: Location::new(PathBuf::from(file), line, 4, line, 11),
+            reference_type: ReferenceType::Call,
+            target_symbol: target,
+        }
+    }
+
+    #[test]
+    fn test_validate_and_repair_index() {
+        let store = SymbolStore::new();
+        let get_user = symbol("GetUser", "users/service.go", 10, 20);
+        let long = symbol("ListUsers", "users/service.go", 22, 90);
+        for file in ["users/service.go", "users/handler.go"] {
+            store.update_file_info(PathBuf::from(file), FileInfo::new([0; 32], 100));
+        }
+        store.insert_symbol_unchecked(get_user.clone());
+        store.insert_symbol_unchecked(long.clone());
+
+        let call = reference("users/handler.go", 5, get_user.id);
+        store.add_references(get_user.id, vec![call.clone(), call.clone()]);
+        store.add_references(
+            get_user.id,
+            vec![reference("users/deleted.go", 3, get_user.id)],
+        );
+        let gone = SymbolId::new(&PathBuf::from("users/old.go"), 1, 0);
+        store.add_references(gone, vec![reference("users/handler.go", 8, gone)]);
+        store
+            .symbols_by_name
+            .entry("GetUser".to_string())
+            .or_default()
+            .push(gone);
+
+        let line_counts = HashMap::from([(PathBuf::from("users/service.go"), 40)]);
+        let validation = validate_index(&store, &line_counts);
+        let kinds: Vec<IssueKind> = validation.issues.iter().map(|i| i.kind).collect();
+        assert_eq!(
+            kinds,
+            vec![
+                IssueKind::DanglingReference,
+                IssueKind::UntrackedReferenceSource,
+                IssueKind::DuplicateReference,
+                IssueKind::StaleNameEntry,
+                IssueKind::MissingFile,
+                IssueKind::SpanOutOfBounds,
+            ]
+        );
+        assert_eq!(validation.issues[5].symbol_id, Some(long.id.0));
+        assert_eq!(validation.references_checked, 4);
+
+        // Three edges and one name entry go; the missing file and the span
+        // are reported again
+        assert_eq!(repair_index(&store), 4);
+        let repaired = validate_index(&store, &line_counts);
+        assert!(repaired.issues.iter().all(|issue| !issue.kind.repairable()));
+        assert_eq!(repaired.issues.len(), 2);
+        let kept = store.get_references(&get_user.id);
+        assert_eq!(kept.len(), 1);
+        assert_eq!(kept[0].location.start_line, call.location.start_line);
+    }
+}
@@ -35,8 +35,17 @@ pub enum CodeAnalysisError {
     #[error("Permission denied: {path}")]
     PermissionDenied { path: String },
 
-    #[error("File too large: {size_mb}MB exceeds limit")]
-    FileTooLarge { size_mb: u64 },
+    #[error("File too large: {size_bytes} bytes exceeds limit of {limit_bytes} bytes")]
+    FileTooLarge { size_bytes: u64, limit_bytes: u64 },
+
+    #[error("Binary content detected")]
+    BinaryFile,
+
+    #[error("Parsing took longer than {timeout_ms}ms")]
+    ParseTimeout { timeout_ms: u64 },
+
+    #[error("Operation cancelled: {operation}")]
+    Cancelled { operation: String },
 }
 
 impl CodeAnalysisError {
@@ -46,12 +55,15 @@ impl CodeAnalysisError {
             CodeAnalysisError::ParseError { .. } => true,
             CodeAnalysisError::UnsupportedFileType { .. } => true,
             CodeAnalysisError::FileTooLarge { .. } => true,
+            CodeAnalysisError::BinaryFile => true,
+            CodeAnalysisError::ParseTimeout { .. } => true,
             CodeAnalysisError::PermissionDenied { .. } => true,
 
             // Non-recoverable errors - should stop processing
             CodeAnalysisError::MemoryLimitExceeded { .. } => false,
             CodeAnalysisError::FileSystemError(_) => false,
             CodeAnalysisError::SerializationError(_) => false,
+            CodeAnalysisError::Cancelled { .. } => false,
 
             // Context-dependent
             _ => true,
@@ -1,8 +1,33 @@
+use crate::indexing::settings::setting_var;
 use crate::models::Language;
 use ignore::WalkBuilder;
+use std::collections::HashMap;
 use std::path::{Path, PathBuf};
 use tokio::fs;
 
+/// How the walker treats symbolic links under the indexed root
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum SymlinkPolicy {
+    /// Ignore symlinked files and directories
+    #[default]
+    Skip,
+    /// Follow symlinks, stopping at directory cycles and indexing each file
+    /// once under its canonical path
+    Follow,
+}
+
+impl SymlinkPolicy {
+    /// Policy configured through ROBERTO_FOLLOW_SYMLINKS (`true`/`1` to follow)
+    pub fn from_env() -> Self {
+        match setting_var("ROBERTO_FOLLOW_SYMLINKS") {
+            Ok(value) if matches!(value.trim().to_lowercase().as_str(), "1" | "true" | "yes") => {
+                SymlinkPolicy::Follow
+            }
+            _ => SymlinkPolicy::Skip,
+        }
+    }
+}
+
 pub struct FileSystemWalker;
 
 impl FileSystemWalker {
@@ -11,21 +36,64 @@ impl FileSystemWalker {
     }
 
     /// Find all source files in a directory recursively, respecting .gitignore
+    /// and skipping symlinks
     pub fn find_source_files<P: AsRef<Path>>(
         path: P,
     ) -> Result<Vec<PathBuf>, Box<dyn std::error::Error>> {
+        Self::find_source_files_with_policy(path, SymlinkPolicy::Skip)
+    }
+
+    /// Find all source files, treating symlinks according to `policy`
+    pub fn find_source_files_with_policy<P: AsRef<Path>>(
+        path: P,
+        policy: SymlinkPolicy,
+    ) -> Result<Vec<PathBuf>, Box<dyn std::error::Error>> {
+        Self::find_files_with_policy(path, policy, Language::is_source_file)
+    }
+
+    /// Find all files accepted by `is_source`, treating symlinks according to `policy`
+    pub fn find_files_with_policy<P: AsRef<Path>>(
+        path: P,
+        policy: SymlinkPolicy,
+        is_source: impl Fn(&Path) -> bool,
+    ) -> Result<Vec<PathBuf>, Box<dyn std::error::Error>> {
+        let path = path.as_ref();
         let mut source_files = Vec::new();
+        // Canonical path of each file found while following links, with the
+        // position of the walk path kept for it in `source_files`
+        let mut seen: HashMap<PathBuf, usize> = HashMap::new();
 
-        // Use ignore crate to respect .gitignore files
-        let walker = WalkBuilder::new(&path)
-            .follow_links(false)
+        // Use ignore crate to respect .gitignore files. When following links it
+        // reports directory loops as errors, which ends the descent into them.
+        // Sorting makes the path kept for a file reached several ways stable.
+        let walker = WalkBuilder::new(path)
+            .follow_links(policy == SymlinkPolicy::Follow)
             .git_ignore(true)
             .git_exclude(true)
             .git_global(true)
             .hidden(false) // Include hidden files but respect .gitignore
+            .sort_by_file_name(|a, b| a.cmp(b))
             .build();
 
+        // A walk path is the real one when no component of it is a link:
+        // its directory resolves to the same place under the resolved root
+        let canonical_root = std::fs::canonicalize(path).unwrap_or_else(|_| path.to_path_buf());
+        let is_real = |candidate: &Path| {
+            let (Some(parent), Ok(relative)) = (candidate.parent(), candidate.strip_prefix(path))
+            else {
+                return false;
+            };
+            let expected = match relative.parent() {
+                Some(relative) => canonical_root.join(relative),
+                None => canonical_root.clone(),
+            };
+            !candidate.is_symlink() && std::fs::canonicalize(parent).is_ok_and(|p| p == expected)
+        };
+
         for entry in walker.filter_map(|e| e.ok()) {
+            if policy == SymlinkPolicy::Skip && entry.path_is_symlink() {
+                continue;
+            }
             let path = entry.path();
 
             // Skip directories
@@ -34,8 +102,39 @@ impl FileSystemWalker {
             }
 
             // Check if it's a supported source file
-            if Language::is_source_file(path) {
+            if !is_source(path) {
+                continue;
+            }
+
+            if policy == SymlinkPolicy::Skip {
                 source_files.push(path.to_path_buf());
+                continue;
+            }
+
+            // A file reachable through several links is indexed once, under
+            // the path the walk found it at so store keys match Skip mode.
+            // The canonical path only detects the duplicates.
+            let canonical = match std::fs::canonicalize(path) {
+                Ok(canonical) => canonical,
+                Err(error) => {
+                    tracing::warn!("Skipping {}: {}", path.display(), error);
+                    continue;
+                }
+            };
+            match seen.get(&canonical) {
+                None => {
+                    seen.insert(canonical, source_files.len());
+                    source_files.push(path.to_path_buf());
+                }
+                // Prefer the real path over one through a link to the file
+                // or to a directory above it, then the shortest path
+                Some(&position) => {
+                    let rank =
+                        |candidate: &Path| (!is_real(candidate), candidate.components().count());
+                    if rank(path) < rank(&source_files[position]) {
+                        source_files[position] = path.to_path_buf();
+                    }
+                }
             }
         }
 
@@ -56,6 +155,12 @@ impl FileSystemWalker {
         Ok(metadata.len())
     }
 
+    /// Detect binary content the way git does: a NUL byte within the first 8000 bytes
+    pub fn is_binary_content(bytes: &[u8]) -> bool {
+        const SNIFF_LEN: usize = 8000;
+        bytes.iter().take(SNIFF_LEN).any(|&b| b == 0)
+    }
+
     /// Read file content as string
     pub async fn read_file_content<P: AsRef<Path>>(path: P) -> Result<String, std::io::Error> {
         fs::read_to_string(path).await
@@ -115,6 +220,94 @@ mod tests {
         assert!(source_files.contains(&root_file));
     }
 
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_symlink_cycles_and_duplicates() {
+        let temp_dir = TempDir::new().unwrap();
+        let base_path = temp_dir.path();
+
+        let real_file = base_path.join("lib.rs");
+        fs::write(&real_file, "pub fn test() {}").await.unwrap();
+        std::os::unix::fs::symlink(&real_file, base_path.join("alias.rs")).unwrap();
+        // Directory that links back to its parent
+        std::os::unix::fs::symlink(base_path, base_path.join("loop")).unwrap();
+        // Directory link sorting before its target: `link/a.rs` is walked
+        // first, but `real/a.rs` is the path the watcher reports
+        let real_dir = base_path.join("real");
+        fs::create_dir(&real_dir).await.unwrap();
+        let nested_file = real_dir.join("a.rs");
+        fs::write(&nested_file, "pub fn nested() {}").await.unwrap();
+        std::os::unix::fs::symlink(&real_dir, base_path.join("link")).unwrap();
+
+        let skipped =
+            FileSystemWalker::find_source_files_with_policy(base_path, SymlinkPolicy::Skip)
+                .unwrap();
+        assert_eq!(skipped, vec![real_file.clone(), nested_file.clone()]);
+
+        let followed =
+            FileSystemWalker::find_source_files_with_policy(base_path, SymlinkPolicy::Follow)
+                .unwrap();
+        assert_eq!(followed, vec![real_file, nested_file]);
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_dangling_symlink() {
+        let temp_dir = TempDir::new().unwrap();
+        let base_path = temp_dir.path();
+
+        let real_file = base_path.join("lib.rs");
+        fs::write(&real_file, "pub fn test() {}").await.unwrap();
+        std::os::unix::fs::symlink(base_path.join("missing.rs"), base_path.join("broken.rs"))
+            .unwrap();
+
+        for policy in [SymlinkPolicy::Skip, SymlinkPolicy::Follow] {
+            let files = FileSystemWalker::find_source_files_with_policy(base_path, policy).unwrap();
+            assert_eq!(files, vec![real_file.clone()], "{:?}", policy);
+        }
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_symlink_policy_path_form() {
+        let temp_dir = TempDir::new().unwrap();
+        let outside_dir = TempDir::new().unwrap();
+        let base_path = temp_dir.path();
+
+        let src_dir = base_path.join("src");
+        fs::create_dir(&src_dir).await.unwrap();
+        let real_file = src_dir.join("lib.rs");
+        fs::write(&real_file, "pub fn test() {}").await.unwrap();
+        fs::write(outside_dir.path().join("shared.rs"), "pub fn shared() {}")
+            .await
+            .unwrap();
+        std::os::unix::fs::symlink(outside_dir.path(), base_path.join("shared")).unwrap();
+
+        let skipped =
+            FileSystemWalker::find_source_files_with_policy(base_path, SymlinkPolicy::Skip)
+                .unwrap();
+        assert_eq!(skipped, vec![real_file.clone()]);
+
+        // Files keep the path they were walked at, under the root, rather
+        // than the canonical path of their target
+        let followed =
+            FileSystemWalker::find_source_files_with_policy(base_path, SymlinkPolicy::Follow)
+                .unwrap();
+        assert_eq!(
+            followed,
+            vec![base_path.join("shared").join("shared.rs"), real_file]
+        );
+    }
+
+    #[test]
+    fn test_binary_detection() {
+        assert!(!FileSystemWalker::is_binary_content(b"package main\n"));
+        assert!(FileSystemWalker::is_binary_content(
+            b"\x7fELF\x02\x01\x00\x00"
+        ));
+        assert!(!FileSystemWalker::is_binary_content(b""));
+    }
+
     #[tokio::test]
     async fn test_file_accessibility() {
         let temp_dir = TempDir::new().unwrap();
@@ -0,0 +1,799 @@
+use crate::utils::time::format_timestamp;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::io::Write;
+use std::path::{Path, PathBuf};
+use std::process::{Command, Stdio};
+use std::sync::{Arc, Mutex};
+use std::time::{Duration, SystemTime};
+
+/// Commit id git blame reports for lines not committed yet
+const UNCOMMITTED: &str = "0000000000000000000000000000000000000000";
+
+/// A file changed between a base ref and the working tree
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum GitChange {
+    /// Added, modified or untracked
+    Modified(PathBuf),
+    Deleted(PathBuf),
+    Renamed {
+        from: PathBuf,
+        to: PathBuf,
+    },
+}
+
+/// Files under `root` that differ from `base_ref`: committed, staged and
+/// unstaged changes plus untracked files that aren't ignored. Renames across
+/// the boundary of `root` count as a deletion or an addition. Fails when `root` is not inside a git checkout, git is not
+/// installed or the ref is unknown.
+pub fn git_changed_files(root: &Path, base_ref: &str) -> Result<Vec<GitChange>, String> {
+    if base_ref.is_empty() || base_ref.starts_with('-') {
+        return Err(format!("Invalid git ref '{}'", base_ref));
+    }
+    let toplevel = PathBuf::from(git(root, &["rev-parse", "--show-toplevel"])?.trim());
+
+    let diff = git(root, &["diff", "--name-status", "-z", "-M", base_ref, "--"])?;
+    let mut changes = parse_name_status(&diff);
+
+    let untracked = git(
+        root,
+        &[
+            "ls-files",
+            "--others",
+            "--exclude-standard",
+            "--full-name",
+            "-z",
+        ],
+    )?;
+    changes.extend(
+        untracked
+            .split('\0')
+            .filter(|path| !path.is_empty())
+            .map(|path| GitChange::Modified(PathBuf::from(path))),
+    );
+
+    let under_root = |path: &PathBuf| under_root(root, &toplevel, path);
+    Ok(changes
+        .into_iter()
+        .filter_map(|change| match change {
+            GitChange::Modified(path) => under_root(&path).map(GitChange::Modified),
+            GitChange::Deleted(path) => under_root(&path).map(GitChange::Deleted),
+            GitChange::Renamed { from, to } => match (under_root(&from), under_root(&to)) {
+                (Some(from), Some(to)) => Some(GitChange::Renamed { from, to }),
+                (Some(from), None) => Some(GitChange::Deleted(from)),
+                (None, Some(to)) => Some(GitChange::Modified(to)),
+                (None, None) => None,
+            },
+        })
+        .collect())
+}
+
+/// Files under `root` with commits after `since`, reported as given under
+/// `root` like `git_changed_files`
+pub fn git_files_committed_since(root: &Path, since: SystemTime) -> Result<Vec<PathBuf>, String> {
+    let toplevel = PathBuf::from(git(root, &["rev-parse", "--show-toplevel"])?.trim());
+    let seconds = since
+        .duration_since(SystemTime::UNIX_EPOCH)
+        .unwrap_or_default()
+        .as_secs();
+    let log = git(
+        root,
+        &[
+            "log",
+            &format!("--since=@{}", seconds),
+            "--name-only",
+            "--format=",
+            "-z",
+            "--",
+        ],
+    )?;
+    let mut files: Vec<PathBuf> = log
+        .split(['\0', '\n'])
+        .filter(|path| !path.is_empty())
+        .filter_map(|path| under_root(root, &toplevel, &PathBuf::from(path)))
+        .collect();
+    files.sort();
+    files.dedup();
+    Ok(files)
+}
+
+/// Map a path relative to the checkout's top level to one under `root` as
+/// given, so it matches the paths a directory walk from `root` produces.
+/// `None` for paths outside `root`.
+fn under_root(root: &Path, toplevel: &Path, path: &Path) -> Option<PathBuf> {
+    let canonical_root = root.canonicalize().unwrap_or_else(|_| root.to_path_buf());
+    toplevel
+        .join(path)
+        .strip_prefix(&canonical_root)
+        .ok()
+        .map(|relative| root.join(relative))
+}
+
+/// The commit that last touched some lines, from `git blame`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BlameInfo {
+    pub commit: String,
+    pub author: String,
+    pub author_email: String,
+    /// Author date, RFC 3339
+    pub date: String,
+    /// Author date in Unix seconds
+    #[serde(skip)]
+    pub timestamp: u64,
+    pub summary: String,
+    /// The lines have changes that are not committed yet
+    #[serde(skip_serializing_if = "std::ops::Not::not", default)]
+    pub uncommitted: bool,
+}
+
+impl BlameInfo {
+    pub fn time(&self) -> SystemTime {
+        SystemTime::UNIX_EPOCH + Duration::from_secs(self.timestamp)
+    }
+}
+
+/// Line-by-line blame of one file
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct FileBlame {
+    commits: Vec<BlameInfo>,
+    /// For each line, 0-based, the index of its commit in `commits`
+    lines: Vec<usize>,
+}
+
+impl FileBlame {
+    /// The most recent commit among lines `start_line..=end_line` (1-based)
+    pub fn last_change(&self, start_line: u32, end_line: u32) -> Option<&BlameInfo> {
+        let start = start_line.saturating_sub(1) as usize;
+        let end = (end_line as usize).min(self.lines.len());
+        self.lines
+            .get(start..end)?
+            .iter()
+            .map(|&commit| &self.commits[commit])
+            .max_by_key(|info| (info.uncommitted, info.timestamp))
+    }
+
+    pub fn has_uncommitted(&self) -> bool {
+        self.commits.iter().any(|info| info.uncommitted)
+    }
+}
+
+/// Blame every line of `file`. Fails for files outside a git checkout or
+/// not tracked by it.
+pub fn git_blame(file: &Path) -> Result<FileBlame, String> {
+    let dir = file.parent().unwrap_or(Path::new("."));
+    let name = file
+        .file_name()
+        .ok_or_else(|| format!("Not a file: {}", file.display()))?;
+    let output = git(
+        dir,
+        &["blame", "--porcelain", "--", &name.to_string_lossy()],
+    )?;
+    Ok(parse_blame_porcelain(&output))
+}
+
+/// Parse `git blame --porcelain`: a header per line naming its commit, the
+/// commit's details the first time it appears, then the line prefixed by a tab
+fn parse_blame_porcelain(output: &str) -> FileBlame {
+    let mut blame = FileBlame::default();
+    let mut index: HashMap<String, usize> = HashMap::new();
+    let mut current: Option<usize> = None;
+    let mut expect_header = true;
+
+    for line in output.lines() {
+        if line.starts_with('\t') {
+            if let Some(commit) = current {
+                blame.lines.push(commit);
+            }
+            expect_header = true;
+            continue;
+        }
+        if expect_header {
+            let Some(sha) = line.split(' ').next().filter(|sha| sha.len() == 40) else {
+                continue;
+            };
+            let next = blame.commits.len();
+            let commit = *index.entry(sha.to_string()).or_insert(next);
+            if commit == next {
+                blame.commits.push(BlameInfo {
+                    commit: sha.to_string(),
+                    author: String::new(),
+                    author_email: String::new(),
+                    date: String::new(),
+                    timestamp: 0,
+                    summary: String::new(),
+                    uncommitted: sha == UNCOMMITTED,
+                });
+            }
+            current = Some(commit);
+            expect_header = false;
+            continue;
+        }
+
+        let Some(info) = current.map(|commit| &mut blame.commits[commit]) else {
+            continue;
+        };
+        let (key, value) = line.split_once(' ').unwrap_or((line, ""));
+        match key {
+            "author" => info.author = value.to_string(),
+            "author-mail" => {
+                info.author_email = value
+                    .trim_start_matches('<')
+                    .trim_end_matches('>')
+                    .to_string()
+            }
+            "author-time" => {
+                info.timestamp = value.parse().unwrap_or(0);
+                info.date = format_timestamp(info.time());
+            }
+            "summary" => info.summary = value.to_string(),
+            _ => {}
+        }
+    }
+    blame
+}
+
+/// Blames reused until a file's content changes. Blames with uncommitted
+/// lines are not kept, since committing changes them without touching the
+/// file.
+#[derive(Default)]
+pub struct BlameCache {
+    files: Mutex<HashMap<PathBuf, ([u8; 32], Arc<FileBlame>)>>,
+}
+
+impl BlameCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Blame of `file` with the given content hash; `None` outside git
+    pub fn blame(&self, file: &Path, content_hash: [u8; 32]) -> Option<Arc<FileBlame>> {
+        if let Some((hash, blame)) = self.files.lock().unwrap().get(file) {
+            if *hash == content_hash {
+                return Some(blame.clone());
+            }
+        }
+
+        let blame = match git_blame(file) {
+            Ok(blame) => Arc::new(blame),
+            Err(e) => {
+                tracing::debug!("No blame for {}: {}", file.display(), e);
+                return None;
+            }
+        };
+        if !blame.has_uncommitted() {
+            self.files
+                .lock()
+                .unwrap()
+                .insert(file.to_path_buf(), (content_hash, blame.clone()));
+        }
+        Some(blame)
+    }
+}
+
+/// A commit from `git log`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct CommitInfo {
+    pub commit: String,
+    pub author: String,
+    /// Author date, RFC 3339
+    pub date: String,
+    pub summary: String,
+}
+
+/// Lines one change replaced, from a `@@ -old_start,old_count
+/// +new_start,new_count @@` header. With no lines on a side, its start is
+/// the line before the change.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Hunk {
+    pub old_start: u32,
+    pub old_count: u32,
+    pub new_start: u32,
+    pub new_count: u32,
+}
+
+/// The changes to one file. Paths are as given under the root the diff
+/// was requested for; `old_path` is `None` for added files and `new_path`
+/// for deleted ones.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FileDiff {
+    pub old_path: Option<PathBuf>,
+    pub new_path: Option<PathBuf>,
+    pub hunks: Vec<Hunk>,
+}
+
+/// A commit and the files it changed
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CommitDiff {
+    pub info: CommitInfo,
+    pub files: Vec<FileDiff>,
+}
+
+/// Commits in `from..to` along the first-parent chain, newest first, with
+/// their line-level changes to files under `root`. Merges are diffed
+/// against their first parent. At most `max_commits` are read; the flag is
+/// set when the range holds more.
+pub fn git_commit_diffs(
+    root: &Path,
+    from: &str,
+    to: &str,
+    max_commits: usize,
+) -> Result<(Vec<CommitDiff>, bool), String> {
+    for reference in [from, to] {
+        if reference.is_empty() || reference.starts_with('-') {
+            return Err(format!("Invalid git ref '{}'", reference));
+        }
+    }
+    let toplevel = PathBuf::from(git(root, &["rev-parse", "--show-toplevel"])?.trim());
+    let log = git(
+        root,
+        &[
+            "-c",
+            "core.quotepath=false",
+            "log",
+            "--first-parent",
+            "-m",
+            "-p",
+            "-U0",
+            "-M",
+            "--no-color",
+            "--no-ext-diff",
+            &format!("-n{}", max_commits + 1),
+            "--format=%x1e%H%x1f%an%x1f%at%x1f%s",
+            &format!("{}..{}", from, to),
+            "--",
+            ".",
+        ],
+    )?;
+
+    let mut commits = Vec::new();
+    for entry in log.split('\x1e').filter(|entry| !entry.trim().is_empty()) {
+        let (header, patch) = entry.split_once('\n').unwrap_or((entry, ""));
+        let fields: Vec<&str> = header.splitn(4, '\x1f').collect();
+        let [commit, author, time, summary] = fields[..] else {
+            continue;
+        };
+        let timestamp = time.parse().unwrap_or(0);
+        commits.push(CommitDiff {
+            info: CommitInfo {
+                commit: commit.to_string(),
+                author: author.to_string(),
+                date: format_timestamp(SystemTime::UNIX_EPOCH + Duration::from_secs(timestamp)),
+                summary: summary.to_string(),
+            },
+            files: parse_unified_diff(patch, |path| under_root(root, &toplevel, path)),
+        });
+    }
+    let truncated = commits.len() > max_commits;
+    commits.truncate(max_commits);
+    Ok((commits, truncated))
+}
+
+/// Line-level changes from `rev` to the working tree for files under `root`
+pub fn git_worktree_diff(root: &Path, rev: &str) -> Result<Vec<FileDiff>, String> {
+    if rev.is_empty() || rev.starts_with('-') {
+        return Err(format!("Invalid git ref '{}'", rev));
+    }
+    let toplevel = PathBuf::from(git(root, &["rev-parse", "--show-toplevel"])?.trim());
+    let diff = git(
+        root,
+        &[
+            "-c",
+            "core.quotepath=false",
+            "diff",
+            "-U0",
+            "-M",
+            "--no-color",
+            "--no-ext-diff",
+            rev,
+            "--",
+            ".",
+        ],
+    )?;
+    Ok(parse_unified_diff(&diff, |path| {
+        under_root(root, &toplevel, path)
+    }))
+}
+
+/// Parse `git diff -U0` output into per-file hunks. `resolve` maps the
+/// paths git prints, relative to the checkout, to the caller's paths;
+/// files it rejects are left out.
+fn parse_unified_diff(output: &str, resolve: impl Fn(&Path) -> Option<PathBuf>) -> Vec<FileDiff> {
+    let mut files: Vec<FileDiff> = Vec::new();
+    // Paths as printed, `None` for /dev/null
+    let mut current: Option<(Option<String>, Option<String>, Vec<Hunk>)> = None;
+    let mut remaining = 0u32;
+
+    let finish = |file: Option<(Option<String>, Option<String>, Vec<Hunk>)>,
+                  files: &mut Vec<FileDiff>| {
+        let Some((old_path, new_path, hunks)) = file else {
+            return;
+        };
+        let old_path = old_path.and_then(|path| resolve(Path::new(&path)));
+        let new_path = new_path.and_then(|path| resolve(Path::new(&path)));
+        if old_path.is_some() || new_path.is_some() {
+            files.push(FileDiff {
+                old_path,
+                new_path,
+                hunks,
+            });
+        }
+    };
+
+    for line in output.lines() {
+        // Changed lines of the current hunk, whatever they start with
+        if remaining > 0 {
+            if !line.starts_with('\\') {
+                remaining -= 1;
+            }
+            continue;
+        }
+        if let Some(paths) = line.strip_prefix("diff --git ") {
+            finish(current.take(), &mut files);
+            // `a/x b/y`; rename and file mode lines below refine them
+            let (old, new) = paths.split_once(" b/").unwrap_or((paths, paths));
+            let old = old.strip_prefix("a/").unwrap_or(old).to_string();
+            current = Some((Some(old), Some(new.to_string()), Vec::new()));
+            continue;
+        }
+        let Some((old_path, new_path, hunks)) = current.as_mut() else {
+            continue;
+        };
+        if let Some(path) = line.strip_prefix("rename from ") {
+            *old_path = Some(path.to_string());
+        } else if let Some(path) = line.strip_prefix("rename to ") {
+            *new_path = Some(path.to_string());
+        } else if line.starts_with("new file mode") {
+            *old_path = None;
+        } else if line.starts_with("deleted file mode") {
+            *new_path = None;
+        } else if let Some(header) = line.strip_prefix("@@ -") {
+            let Some(hunk) = parse_hunk_header(header) else {
+                continue;
+            };
+            remaining = hunk.old_count + hunk.new_count;
+            hunks.push(hunk);
+        }
+    }
+    finish(current, &mut files);
+    files
+}
+
+/// `12,3 +14 @@ fn context` into a hunk; a missing count is 1
+fn parse_hunk_header(header: &str) -> Option<Hunk> {
+    let (ranges, _) = header.split_once(" @@")?;
+    let (old, new) = ranges.split_once(" +")?;
+    let range = |range: &str| -> Option<(u32, u32)> {
+        match range.split_once(',') {
+            Some((start, count)) => Some((start.parse().ok()?, count.parse().ok()?)),
+            None => Some((range.parse().ok()?, 1)),
+        }
+    };
+    let (old_start, old_count) = range(old)?;
+    let (new_start, new_count) = range(new)?;
+    Some(Hunk {
+        old_start,
+        old_count,
+        new_start,
+        new_count,
+    })
+}
+
+/// A file in the tree of a git revision: its path under the root the tree
+/// was listed for and the id of its content
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TreeFile {
+    pub path: PathBuf,
+    pub blob: String,
+}
+
+/// The commit `rev` names in the checkout holding `root`. Fails for unknown
+/// refs and outside git.
+pub fn git_resolve_commit(root: &Path, rev: &str) -> Result<String, String> {
+    if rev.is_empty() || rev.starts_with('-') {
+        return Err(format!("Invalid git ref '{}'", rev));
+    }
+    let commit = git(
+        root,
+        &["rev-parse", "--verify", &format!("{}^{{commit}}", rev)],
+    )?;
+    Ok(commit.trim().to_string())
+}
+
+/// Regular files under `root` in the tree of `rev`, listed from the object
+/// store without checking anything out. Symlinks and submodules are left
+/// out.
+pub fn git_tree_files(root: &Path, rev: &str) -> Result<Vec<TreeFile>, String> {
+    if rev.is_empty() || rev.starts_with('-') {
+        return Err(format!("Invalid git ref '{}'", rev));
+    }
+    let listing = git(root, &["ls-tree", "-r", "-z", rev, "--", "."])?;
+    Ok(parse_ls_tree(&listing, root))
+}
+
+/// Contents of blobs in the order given, read with one `git cat-file
+/// --batch`
+pub fn git_read_blobs(root: &Path, blobs: &[String]) -> Result<Vec<Vec<u8>>, String> {
+    let mut child = Command::new("git")
+        .arg("-C")
+        .arg(root)
+        .args(["cat-file", "--batch"])
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .map_err(|e| format!("Failed to run git: {}", e))?;
+
+    // Write from another thread so a full stdout pipe can't stall the request
+    let mut stdin = child.stdin.take().ok_or("Failed to open git stdin")?;
+    let request: String = blobs.iter().map(|blob| format!("{}\n", blob)).collect();
+    let writer = std::thread::spawn(move || stdin.write_all(request.as_bytes()));
+
+    let output = child
+        .wait_with_output()
+        .map_err(|e| format!("Failed to run git: {}", e))?;
+    let _ = writer.join();
+    if !output.status.success() {
+        return Err(format!(
+            "git cat-file failed: {}",
+            String::from_utf8_lossy(&output.stderr).trim()
+        ));
+    }
+    parse_cat_file_batch(&output.stdout, blobs.len())
+}
+
+/// Parse `git ls-tree -r -z` entries, `<mode> <type> <id>\t<path>`, keeping
+/// regular files
+fn parse_ls_tree(output: &str, root: &Path) -> Vec<TreeFile> {
+    output
+        .split('\0')
+        .filter_map(|entry| {
+            let (meta, path) = entry.split_once('\t')?;
+            let mut fields = meta.split(' ');
+            let mode = fields.next()?;
+            let kind = fields.next()?;
+            let blob = fields.next()?;
+            (kind == "blob" && mode != "120000").then(|| TreeFile {
+                path: root.join(path),
+                blob: blob.to_string(),
+            })
+        })
+        .collect()
+}
+
+/// Parse `git cat-file --batch` output: per object an `<id> <type> <size>`
+/// header line, the content and a newline
+fn parse_cat_file_batch(output: &[u8], expected: usize) -> Result<Vec<Vec<u8>>, String> {
+    let mut contents = Vec::with_capacity(expected);
+    let mut rest = output;
+    while contents.len() < expected {
+        let end = rest
+            .iter()
+            .position(|&b| b == b'\n')
+            .ok_or("Truncated git cat-file output")?;
+        let header = String::from_utf8_lossy(&rest[..end]);
+        let size: usize = match header.split(' ').nth(2).and_then(|s| s.parse().ok()) {
+            Some(size) => size,
+            None => return Err(format!("git cat-file: {}", header)),
+        };
+        let body = &rest[end + 1..];
+        if body.len() < size {
+            return Err("Truncated git cat-file output".to_string());
+        }
+        contents.push(body[..size].to_vec());
+        rest = body.get(size + 1..).unwrap_or_default();
+    }
+    Ok(contents)
+}
+
+/// Run git in `dir`, returning stdout
+fn git(dir: &Path, args: &[&str]) -> Result<String, String> {
+    let output = Command::new("git")
+        .arg("-C")
+        .arg(dir)
+        .args(args)
+        .output()
+        .map_err(|e| format!("Failed to run git: {}", e))?;
+    if !output.status.success() {
+        return Err(format!(
+            "git {} failed: {}",
+            args[0],
+            String::from_utf8_lossy(&output.stderr).trim()
+        ));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).into_owned())
+}
+
+/// Parse `git diff --name-status -z` output. Renames and copies carry two
+/// paths; copies are reported as modifications of the new path.
+fn parse_name_status(output: &str) -> Vec<GitChange> {
+    let mut fields = output.split('\0').filter(|field| !field.is_empty());
+    let mut changes = Vec::new();
+    while let Some(status) = fields.next() {
+        let Some(path) = fields.next().map(PathBuf::from) else {
+            break;
+        };
+        match status.chars().next() {
+            Some('D') => changes.push(GitChange::Deleted(path)),
+            Some('R') => {
+                if let Some(to) = fields.next() {
+                    changes.push(GitChange::Renamed {
+                        from: path,
+                        to: PathBuf::from(to),
+                    });
+                }
+            }
+            Some('C') => {
+                if let Some(to) = fields.next() {
+                    changes.push(GitChange::Modified(PathBuf::from(to)));
+                }
+            }
+            _ => changes.push(GitChange::Modified(path)),
+        }
+    }
+    changes
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_name_status() {
+        let output =
+            "M\0src/main.go\0A\0src/new.go\0D\0src/old.go\0R087\0a.go\0b.go\0C100\0c.go\0d.go\0";
+        assert_eq!(
+            parse_name_status(output),
+            vec![
+                GitChange::Modified(PathBuf::from("src/main.go")),
+                GitChange::Modified(PathBuf::from("src/new.go")),
+                GitChange::Deleted(PathBuf::from("src/old.go")),
+                GitChange::Renamed {
+                    from: PathBuf::from("a.go"),
+                    to: PathBuf::from("b.go")
+                },
+                GitChange::Modified(PathBuf::from("d.go")),
+            ]
+        );
+        assert!(parse_name_status("").is_empty());
+    }
+
+    #[test]
+    fn test_parse_blame_porcelain() {
+        let output = "\
+3f2a9c1e7b4d5a6f8e9d0c1b2a3f4e5d6c7b8a90 1 1 2
+author Ada Lovelace
+author-mail <ada@example.com>
+author-time 1714557600
+author-tz +0000
+summary Add connection pool
+filename pool.go
+\tpackage db
+3f2a9c1e7b4d5a6f8e9d0c1b2a3f4e5d6c7b8a90 2 2
+\t
+9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c 3 3 1
+author Grace Hopper
+author-mail <grace@example.com>
+author-time 1717236000
+author-tz +0000
+summary Retry failed connections
+previous 3f2a9c1e7b4d5a6f8e9d0c1b2a3f4e5d6c7b8a90 pool.go
+filename pool.go
+\tfunc Open() {}
+0000000000000000000000000000000000000000 4 4 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1717300000
+author-tz +0000
+summary Version of pool.go from pool.go
+filename pool.go
+\t// TODO
+";
+        let blame = parse_blame_porcelain(output);
+        assert_eq!(blame.lines, vec![0, 0, 1, 2]);
+
+        let first = blame.last_change(1, 2).unwrap();
+        assert_eq!(first.author, "Ada Lovelace");
+        assert_eq!(first.author_email, "ada@example.com");
+        assert_eq!(first.date, "2024-05-01T10:00:00Z");
+        assert_eq!(first.summary, "Add connection pool");
+
+        let latest = blame.last_change(1, 3).unwrap();
+        assert_eq!(latest.commit, "9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c");
+        assert!(!latest.uncommitted);
+
+        assert!(blame.last_change(3, 10).unwrap().uncommitted);
+        assert!(blame.has_uncommitted());
+        assert!(blame.last_change(9, 10).is_none());
+    }
+
+    #[test]
+    fn test_parse_unified_diff() {
+        let output = "\
+diff --git a/pool.go b/pool.go
+index 3f2a9c1..9b8c7d6 100644
+--- a/pool.go
++++ b/pool.go
+@@ -3,0 +4,2 @@ package db
++// Open dials the database
++--- not a header
+@@ -10 +12 @@ func Open() {
+-\treturn nil
++\treturn dial()
+diff --git a/old.go b/new.go
+similarity index 90%
+rename from old.go
+rename to new.go
+@@ -1,2 +1 @@
+-package a
+-
++package b
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package gone
+";
+        let files = parse_unified_diff(output, |path| Some(Path::new("/repo").join(path)));
+        assert_eq!(files.len(), 3);
+        assert_eq!(files[0].old_path, Some(PathBuf::from("/repo/pool.go")));
+        assert_eq!(
+            files[0].hunks,
+            vec![
+                Hunk {
+                    old_start: 3,
+                    old_count: 0,
+                    new_start: 4,
+                    new_count: 2
+                },
+                Hunk {
+                    old_start: 10,
+                    old_count: 1,
+                    new_start: 12,
+                    new_count: 1
+                },
+            ]
+        );
+        assert_eq!(files[1].old_path, Some(PathBuf::from("/repo/old.go")));
+        assert_eq!(files[1].new_path, Some(PathBuf::from("/repo/new.go")));
+        assert_eq!(files[1].hunks.len(), 1);
+        assert_eq!(files[2].new_path, None);
+    }
+
+    #[test]
+    fn test_parse_ls_tree() {
+        let output = "100644 blob 3f2a9c1e\tpool.go\0100755 blob 9b8c7d6e\tcmd/run.sh\0120000 blob 1a2b3c4d\tlatest.go\0160000 commit 5e6f7a8b\tvendor/lib\0";
+        assert_eq!(
+            parse_ls_tree(output, Path::new("/repo")),
+            vec![
+                TreeFile {
+                    path: PathBuf::from("/repo/pool.go"),
+                    blob: "3f2a9c1e".to_string()
+                },
+                TreeFile {
+                    path: PathBuf::from("/repo/cmd/run.sh"),
+                    blob: "9b8c7d6e".to_string()
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_parse_cat_file_batch() {
+        let output = b"3f2a9c1e blob 11\npackage db\n\n9b8c7d6e blob 0\n\n";
+        assert_eq!(
+            parse_cat_file_batch(output, 2).unwrap(),
+            vec![b"package db\n".to_vec(), Vec::new()]
+        );
+        assert!(parse_cat_file_batch(b"1a2b3c4d missing\n", 1).is_err());
+        assert!(parse_cat_file_batch(b"3f2a9c1e blob 11\npack", 1).is_err());
+    }
+
+    #[test]
+    fn test_rejects_option_like_refs() {
+        assert!(git_changed_files(Path::new("."), "--output=/tmp/x").is_err());
+        assert!(git_changed_files(Path::new("."), "").is_err());
+        assert!(git_tree_files(Path::new("."), "--output=/tmp/x").is_err());
+        assert!(git_resolve_commit(Path::new("."), "").is_err());
+    }
+}
@@ -0,0 +1,157 @@
+use crate::indexing::settings::setting_var;
+use std::fs::{File, OpenOptions};
+use std::io::Write;
+use std::path::PathBuf;
+use std::sync::Arc;
+use tracing_subscriber::EnvFilter;
+
+/// Filter used when neither ROBERTO_LOG_LEVEL nor RUST_LOG is set
+const DEFAULT_LOG_FILTER: &str = "error";
+
+/// How log lines are rendered
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum LogFormat {
+    /// Human readable lines
+    #[default]
+    Text,
+    /// One JSON object per line with `timestamp`, `level`, `target` and the
+    /// event's fields, for log collectors
+    Json,
+}
+
+/// Where log lines are written. stdout carries the MCP protocol on the stdio
+/// transport, so it is deliberately not a choice.
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub enum LogTarget {
+    #[default]
+    Stderr,
+    /// Appended to this file
+    File(PathBuf),
+}
+
+/// Logging settings, configured through ROBERTO_LOG_LEVEL (a level such as
+/// `debug` or a full filter like `roberto_mcp::indexing=debug,warn`; RUST_LOG
+/// is used when unset), ROBERTO_LOG_FORMAT (`text` or `json`) and
+/// ROBERTO_LOG_FILE (a file to append to instead of stderr).
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LogConfig {
+    pub filter: String,
+    pub format: LogFormat,
+    pub target: LogTarget,
+}
+
+impl Default for LogConfig {
+    fn default() -> Self {
+        Self {
+            filter: DEFAULT_LOG_FILTER.to_string(),
+            format: LogFormat::default(),
+            target: LogTarget::default(),
+        }
+    }
+}
+
+impl LogConfig {
+    pub fn from_env() -> Self {
+        Self::from_values(
+            setting_var("ROBERTO_LOG_LEVEL")
+                .or_else(|_| std::env::var("RUST_LOG"))
+                .ok()
+                .as_deref(),
+            setting_var("ROBERTO_LOG_FORMAT").ok().as_deref(),
+            setting_var("ROBERTO_LOG_FILE").ok().as_deref(),
+        )
+    }
+
+    /// Build from raw setting values; an unknown format falls back to text
+    pub fn from_values(filter: Option<&str>, format: Option<&str>, file: Option<&str>) -> Self {
+        let filter = filter
+            .map(str::trim)
+            .filter(|filter| !filter.is_empty())
+            .unwrap_or(DEFAULT_LOG_FILTER)
+            .to_string();
+        let format = match format.map(|f| f.trim().to_ascii_lowercase()).as_deref() {
+            Some("json") => LogFormat::Json,
+            _ => LogFormat::Text,
+        };
+        let target = match file.map(str::trim).filter(|file| !file.is_empty()) {
+            Some(file) => LogTarget::File(PathBuf::from(file)),
+            None => LogTarget::Stderr,
+        };
+        Self {
+            filter,
+            format,
+            target,
+        }
+    }
+}
+
+/// A log file shared by every writer the subscriber asks for
+struct LogFile(Arc<File>);
+
+impl Write for LogFile {
+    fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+        (&*self.0).write(buf)
+    }
+
+    fn flush(&mut self) -> std::io::Result<()> {
+        (&*self.0).flush()
+    }
+}
+
+/// Install the global `tracing` subscriber. Everything in the crate logs
+/// through the `tracing` macros, so this decides the format, level and
+/// destination of all of it.
+pub fn init_logging(config: &LogConfig) -> Result<(), Box<dyn std::error::Error>> {
+    let filter = EnvFilter::try_new(&config.filter)
+        .map_err(|e| format!("invalid log filter '{}': {}", config.filter, e))?;
+
+    let file = match &config.target {
+        LogTarget::Stderr => None,
+        LogTarget::File(path) => Some(Arc::new(
+            OpenOptions::new().create(true).append(true).open(path)?,
+        )),
+    };
+    let writer = move || -> Box<dyn Write + Send> {
+        match &file {
+            Some(file) => Box::new(LogFile(file.clone())),
+            None => Box::new(std::io::stderr()),
+        }
+    };
+
+    let builder = tracing_subscriber::fmt()
+        .with_env_filter(filter)
+        .with_writer(writer)
+        .with_ansi(false);
+    match config.format {
+        LogFormat::Text => builder.try_init()?,
+        LogFormat::Json => builder.json().flatten_event(true).try_init()?,
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_log_config_values() {
+        assert_eq!(
+            LogConfig::from_values(None, None, None),
+            LogConfig::default()
+        );
+
+        let config =
+            LogConfig::from_values(Some("debug"), Some("JSON"), Some("/var/log/roberto.log"));
+        assert_eq!(config.filter, "debug");
+        assert_eq!(config.format, LogFormat::Json);
+        assert_eq!(
+            config.target,
+            LogTarget::File(PathBuf::from("/var/log/roberto.log"))
+        );
+
+        let config = LogConfig::from_values(Some("  "), Some("yaml"), Some(""));
+        assert_eq!(config.filter, "error");
+        assert_eq!(config.format, LogFormat::Text);
+        assert_eq!(config.target, LogTarget::Stderr);
+    }
+}
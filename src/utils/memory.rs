@@ -1,3 +1,4 @@
+use crate::indexing::settings::setting_var;
 use std::sync::atomic::{AtomicU64, Ordering};
 use std::sync::Arc;
 use std::time::{Duration, Instant};
@@ -36,7 +37,7 @@ impl MemoryManager {
     }
 
     pub fn from_env() -> Self {
-        let max_mb = std::env::var("ROBERTO_MEMORY_LIMIT")
+        let max_mb = setting_var("ROBERTO_MEMORY_LIMIT")
             .ok()
             .and_then(|s| s.parse().ok())
             .unwrap_or(1024); // Default 1GB
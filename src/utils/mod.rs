@@ -1,13 +1,21 @@
 pub mod error;
 pub mod filesystem;
+pub mod git;
+pub mod logging;
 pub mod lru;
 pub mod memory;
 pub mod path;
+pub mod signals;
+pub mod time;
 pub mod watcher;
 
 pub use error::*;
 pub use filesystem::*;
+pub use git::*;
+pub use logging::*;
 pub use lru::*;
 pub use memory::*;
 pub use path::*;
+pub use signals::*;
+pub use time::*;
 pub use watcher::*;
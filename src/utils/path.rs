@@ -1,5 +1,14 @@
+use crate::indexing::settings::setting_var;
 use std::path::{Path, PathBuf};
+use std::sync::{OnceLock, RwLock};
 use rmcp::model::{ErrorCode, ErrorData};
+use serde::Serializer;
+
+/// Directories passed to `index_code`; normalized paths are written relative
+/// to them and relative paths are resolved against them
+static INDEXED_ROOTS: RwLock<Vec<PathBuf>> = RwLock::new(Vec::new());
+
+static NORMALIZE_PATHS: OnceLock<bool> = OnceLock::new();
 
 /// Comprehensive path resolution utility for all MCP tools
 pub struct PathResolver;
@@ -40,14 +49,33 @@ impl PathResolver {
                 None,
             ))?
         } else {
-            // Relative path without ./ prefix - add current directory
-            std::env::current_dir()
-                .map_err(|e| ErrorData::new(
-                    ErrorCode::INTERNAL_ERROR,
-                    format!("Cannot get current directory: {}", e),
-                    None,
-                ))?
-                .join(path_str.as_ref())
+            // Relative path without ./ prefix - add current directory, falling
+            // back to the indexed roots for repo-relative paths from responses.
+            // Normalized responses are resolved against the roots first.
+            let normalized = Self::normalize_paths()
+                .then(|| resolve_normalized(&path_str, &Self::indexed_roots()))
+                .flatten();
+            match normalized {
+                Some(resolved) => resolved,
+                None => {
+                    let from_cwd = std::env::current_dir()
+                        .map_err(|e| ErrorData::new(
+                            ErrorCode::INTERNAL_ERROR,
+                            format!("Cannot get current directory: {}", e),
+                            None,
+                        ))?
+                        .join(path_str.as_ref());
+                    if from_cwd.exists() {
+                        from_cwd
+                    } else {
+                        Self::indexed_roots()
+                            .iter()
+                            .map(|root| root.join(path_str.as_ref()))
+                            .find(|candidate| candidate.exists())
+                            .unwrap_or(from_cwd)
+                    }
+                }
+            }
         };
 
         // Canonicalize to resolve .. and . components and ensure path exists
@@ -102,6 +130,120 @@ impl PathResolver {
         
         Ok(resolved)
     }
+
+    /// Whether file paths in responses are written repo-relative with forward
+    /// slashes, configured through ROBERTO_NORMALIZE_PATHS (default: false)
+    pub fn normalize_paths() -> bool {
+        *NORMALIZE_PATHS.get_or_init(|| {
+            setting_var("ROBERTO_NORMALIZE_PATHS")
+                .map(|value| matches!(value.trim().to_lowercase().as_str(), "1" | "true" | "yes"))
+                .unwrap_or(false)
+        })
+    }
+
+    /// Remember an indexed directory for path normalization and resolution
+    pub fn register_root(root: &Path) {
+        let mut roots = INDEXED_ROOTS.write().unwrap_or_else(|e| e.into_inner());
+        if !roots.iter().any(|known| known == root) {
+            roots.push(root.to_path_buf());
+        }
+    }
+
+    pub fn indexed_roots() -> Vec<PathBuf> {
+        INDEXED_ROOTS
+            .read()
+            .unwrap_or_else(|e| e.into_inner())
+            .clone()
+    }
+
+    /// A file path as written in tool responses
+    pub fn display_path(path: &Path) -> String {
+        if Self::normalize_paths() {
+            normalize_path(&path.to_string_lossy(), &Self::indexed_roots())
+        } else {
+            path.display().to_string()
+        }
+    }
+}
+
+/// Forward-slash form of `path`, relative to the outermost root containing it
+/// so that files under nested roots never share a normalized path. Paths
+/// outside every root stay absolute. `/` and `\` separators are both
+/// accepted, and Windows drive letters match regardless of case.
+pub fn normalize_path(path: &str, roots: &[PathBuf]) -> String {
+    let path = forward_slashes(path);
+    roots
+        .iter()
+        .filter_map(|root| strip_root(&path, &forward_slashes(&root.to_string_lossy())))
+        .max_by_key(|relative| relative.len())
+        .unwrap_or(path)
+}
+
+/// The file a normalized `path` was written for: its join with an indexed
+/// root, innermost first, that exists and normalizes back to `path`
+fn resolve_normalized(path: &str, roots: &[PathBuf]) -> Option<PathBuf> {
+    let wanted = forward_slashes(path);
+    let mut innermost_first = roots.to_vec();
+    innermost_first.sort_by_key(|root| std::cmp::Reverse(root.components().count()));
+    innermost_first
+        .into_iter()
+        .map(|root| root.join(path))
+        .find(|candidate| {
+            candidate.exists() && normalize_path(&candidate.to_string_lossy(), roots) == wanted
+        })
+}
+
+/// Split a `file:line` or `file:line:column` location. The file part may
+/// contain colons itself, as Windows drive letters do.
+pub fn split_location(location: &str) -> Option<(&str, u32, Option<u32>)> {
+    let (rest, last) = location.trim().rsplit_once(':')?;
+    let last: u32 = last.parse().ok()?;
+    if let Some((file, line)) = rest.rsplit_once(':') {
+        if let Ok(line) = line.parse() {
+            if !file.is_empty() {
+                return Some((file, line, Some(last)));
+            }
+        }
+    }
+    (!rest.is_empty()).then_some((rest, last, None))
+}
+
+/// Serialize a file path the way `display_path` writes it
+pub fn serialize_path<S: Serializer>(path: &PathBuf, serializer: S) -> Result<S::Ok, S::Error> {
+    serializer.serialize_str(&PathResolver::display_path(path))
+}
+
+/// Replace `\` with `/` and drop the `\\?\` prefix Windows adds to canonical paths
+fn forward_slashes(path: &str) -> String {
+    let path = path.replace('\\', "/");
+    match path.strip_prefix("//?/") {
+        Some(stripped) => stripped.to_string(),
+        None => path,
+    }
+}
+
+fn strip_root(path: &str, root: &str) -> Option<String> {
+    let root = root.trim_end_matches('/');
+    if root.is_empty() || path.len() < root.len() || !path.is_char_boundary(root.len()) {
+        return None;
+    }
+
+    let (prefix, rest) = path.split_at(root.len());
+    let has_drive = root.as_bytes().get(1) == Some(&b':');
+    let matches = if has_drive {
+        prefix.eq_ignore_ascii_case(root)
+    } else {
+        prefix == root
+    };
+    if !matches {
+        return None;
+    }
+
+    match rest.strip_prefix('/') {
+        Some(relative) => Some(relative.to_string()),
+        None if rest.is_empty() => Some(".".to_string()),
+        None => None,
+    }
 }
 
 #[cfg(test)]
@@ -110,6 +252,24 @@ mod tests {
     use tempfile::TempDir;
     use std::fs;
 
+    #[test]
+    fn test_split_location() {
+        assert_eq!(
+            split_location("src/store.go:42"),
+            Some(("src/store.go", 42, None))
+        );
+        assert_eq!(
+            split_location("src/store.go:42:7"),
+            Some(("src/store.go", 42, Some(7)))
+        );
+        assert_eq!(
+            split_location(r"C:\repo\store.go:42"),
+            Some((r"C:\repo\store.go", 42, None))
+        );
+        assert_eq!(split_location("src/store.go"), None);
+        assert_eq!(split_location(":42"), None);
+    }
+
     #[test]
     fn test_resolve_current_directory() {
         let result = PathResolver::resolve_path(".");
@@ -176,4 +336,88 @@ mod tests {
         let file_result = PathResolver::resolve_directory_path(&test_file);
         assert!(file_result.is_err());
     }
+
+    #[test]
+    fn test_normalize_unix_paths() {
+        let roots = vec![
+            PathBuf::from("/home/dev/repo"),
+            PathBuf::from("/home/dev/repo/vendor/lib"),
+        ];
+        assert_eq!(
+            normalize_path("/home/dev/repo/src/main.go", &roots),
+            "src/main.go"
+        );
+        // The outermost root wins
+        assert_eq!(
+            normalize_path("/home/dev/repo/vendor/lib/x.go", &roots),
+            "vendor/lib/x.go"
+        );
+        assert_eq!(normalize_path("/home/dev/repo", &roots), ".");
+        // A sibling sharing the prefix is not inside the root
+        assert_eq!(
+            normalize_path("/home/dev/repository/a.go", &roots),
+            "/home/dev/repository/a.go"
+        );
+        assert_eq!(normalize_path("/etc/hosts", &roots), "/etc/hosts");
+    }
+
+    #[test]
+    fn test_normalize_windows_paths() {
+        let roots = vec![PathBuf::from(r"C:\Users\dev\repo")];
+        assert_eq!(
+            normalize_path(r"C:\Users\dev\repo\pkg\server.go", &roots),
+            "pkg/server.go"
+        );
+        assert_eq!(
+            normalize_path(r"\\?\C:\Users\dev\repo\pkg\server.go", &roots),
+            "pkg/server.go"
+        );
+        assert_eq!(
+            normalize_path(r"c:\users\dev\repo\main.go", &roots),
+            "main.go"
+        );
+        assert_eq!(
+            normalize_path(r"D:\other\main.go", &roots),
+            "D:/other/main.go"
+        );
+        assert_eq!(normalize_path(r"pkg\server.go", &[]), "pkg/server.go");
+    }
+
+    #[test]
+    fn test_resolve_relative_to_indexed_root() {
+        let temp_dir = TempDir::new().unwrap();
+        let nested = temp_dir.path().join("pkg");
+        fs::create_dir(&nested).unwrap();
+        fs::write(nested.join("only_in_root.go"), "package pkg").unwrap();
+
+        let root = temp_dir.path().canonicalize().unwrap();
+        PathResolver::register_root(&root);
+
+        let resolved = PathResolver::resolve_file_path("pkg/only_in_root.go").unwrap();
+        assert_eq!(resolved, root.join("pkg").join("only_in_root.go"));
+    }
+
+    #[test]
+    fn test_nested_roots_round_trip() {
+        let temp_dir = TempDir::new().unwrap();
+        let outer = temp_dir.path().canonicalize().unwrap();
+        let inner = outer.join("vendor").join("lib");
+        fs::create_dir_all(&inner).unwrap();
+        fs::write(outer.join("x.go"), "package repo").unwrap();
+        fs::write(inner.join("x.go"), "package lib").unwrap();
+        // A decoy that joining the normalized path onto the inner root finds
+        fs::create_dir_all(inner.join("vendor").join("lib")).unwrap();
+        fs::write(inner.join("vendor/lib/x.go"), "package decoy").unwrap();
+
+        let roots = vec![outer.clone(), inner.clone()];
+        for file in [outer.join("x.go"), inner.join("x.go")] {
+            let normalized = normalize_path(&file.to_string_lossy(), &roots);
+            assert_eq!(resolve_normalized(&normalized, &roots), Some(file));
+        }
+        assert_eq!(
+            normalize_path(&inner.join("x.go").to_string_lossy(), &roots),
+            "vendor/lib/x.go"
+        );
+        assert_eq!(resolve_normalized("missing.go", &roots), None);
+    }
 }
@@ -0,0 +1,134 @@
+use crate::indexing::settings::setting_var;
+use std::future::Future;
+use tokio::sync::mpsc;
+
+/// Whether SIGHUP re-indexes the indexed directories, configured through
+/// ROBERTO_REINDEX_ON_SIGHUP (`true`/`1` to enable; off by default)
+pub fn reindex_on_sighup_from_env() -> bool {
+    setting_var("ROBERTO_REINDEX_ON_SIGHUP")
+        .is_ok_and(|value| matches!(value.trim().to_lowercase().as_str(), "1" | "true" | "yes"))
+}
+
+/// Run `reload` for each hangup received on `hangups`, until every sender
+/// is dropped. Hangups that arrive while a reload runs are coalesced into
+/// one more reload after it.
+pub async fn run_reloads<F, Fut>(mut hangups: mpsc::Receiver<()>, reload: F)
+where
+    F: Fn() -> Fut,
+    Fut: Future<Output = ()>,
+{
+    while hangups.recv().await.is_some() {
+        while hangups.try_recv().is_ok() {}
+        reload().await;
+    }
+}
+
+/// Run `reload` each time the process receives SIGHUP, through
+/// `run_reloads`. Must be called from within a tokio runtime.
+#[cfg(unix)]
+pub fn spawn_sighup_handler<F, Fut>(reload: F) -> Result<(), String>
+where
+    F: Fn() -> Fut + Send + 'static,
+    Fut: Future<Output = ()> + Send,
+{
+    use tokio::signal::unix::{signal, SignalKind};
+
+    let mut signals =
+        signal(SignalKind::hangup()).map_err(|e| format!("failed to listen for SIGHUP: {}", e))?;
+    // One pending hangup is enough to run one more reload
+    let (sender, hangups) = mpsc::channel(1);
+    tokio::spawn(async move {
+        while signals.recv().await.is_some() {
+            tracing::info!("Received SIGHUP");
+            let _ = sender.try_send(());
+        }
+    });
+    tokio::spawn(async move { run_reloads(hangups, reload).await });
+    Ok(())
+}
+
+/// SIGHUP does not exist on this platform, so nothing is installed
+#[cfg(not(unix))]
+pub fn spawn_sighup_handler<F, Fut>(_reload: F) -> Result<(), String>
+where
+    F: Fn() -> Fut + Send + 'static,
+    Fut: Future<Output = ()> + Send,
+{
+    Err("SIGHUP is not supported on this platform".to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+    use std::sync::Arc;
+    use std::time::Duration;
+    use tokio::sync::Semaphore;
+
+    #[tokio::test]
+    async fn test_reloads_coalesce() {
+        let reloads = Arc::new(AtomicUsize::new(0));
+        // Each reload waits for a permit, so the test controls when it ends
+        let gate = Arc::new(Semaphore::new(0));
+        let (sender, hangups) = mpsc::channel(8);
+        let runner = tokio::spawn(run_reloads(hangups, {
+            let reloads = reloads.clone();
+            let gate = gate.clone();
+            move || {
+                let reloads = reloads.clone();
+                let gate = gate.clone();
+                async move {
+                    reloads.fetch_add(1, Ordering::SeqCst);
+                    gate.acquire().await.unwrap().forget();
+                }
+            }
+        }));
+
+        sender.send(()).await.unwrap();
+        while reloads.load(Ordering::SeqCst) == 0 {
+            tokio::task::yield_now().await;
+        }
+        // Three hangups during the running reload make one more
+        for _ in 0..3 {
+            sender.send(()).await.unwrap();
+        }
+        gate.add_permits(1);
+        while reloads.load(Ordering::SeqCst) == 1 {
+            tokio::task::yield_now().await;
+        }
+        gate.add_permits(1);
+        drop(sender);
+
+        tokio::time::timeout(Duration::from_secs(5), runner)
+            .await
+            .unwrap()
+            .unwrap();
+        assert_eq!(reloads.load(Ordering::SeqCst), 2);
+    }
+
+    /// Sends a real SIGHUP to the test process, which kills it if another
+    /// test is running without a handler installed. Run on its own with
+    /// `cargo test test_sighup_runs_reload -- --ignored`.
+    #[cfg(unix)]
+    #[tokio::test]
+    #[ignore = "signals the whole test process"]
+    async fn test_sighup_runs_reload() {
+        let (sender, mut receiver) = tokio::sync::mpsc::unbounded_channel();
+        spawn_sighup_handler(move || {
+            let sender = sender.clone();
+            async move {
+                let _ = sender.send(());
+            }
+        })
+        .unwrap();
+
+        let status = std::process::Command::new("kill")
+            .args(["-HUP", &std::process::id().to_string()])
+            .status()
+            .unwrap();
+        assert!(status.success());
+
+        let reloaded = tokio::time::timeout(Duration::from_secs(5), receiver.recv()).await;
+        assert_eq!(reloaded.ok().flatten(), Some(()));
+    }
+}
@@ -0,0 +1,61 @@
+use std::time::{Duration, SystemTime};
+
+/// Parse a point in time given either as an RFC 3339 timestamp (`2024-05-01T12:00:00Z`),
+/// a Unix timestamp in seconds, or a duration relative to now (`24h`, `30m`, `7d`).
+pub fn parse_since(value: &str) -> Result<SystemTime, String> {
+    let value = value.trim();
+
+    if let Ok(seconds) = value.parse::<u64>() {
+        return Ok(SystemTime::UNIX_EPOCH + Duration::from_secs(seconds));
+    }
+
+    if let Ok(timestamp) = humantime::parse_rfc3339_weak(value) {
+        return Ok(timestamp);
+    }
+
+    match humantime::parse_duration(value) {
+        Ok(duration) => Ok(SystemTime::now()
+            .checked_sub(duration)
+            .unwrap_or(SystemTime::UNIX_EPOCH)),
+        Err(_) => Err(format!(
+            "Invalid time '{}': expected an RFC 3339 timestamp, Unix seconds or a duration like '24h'",
+            value
+        )),
+    }
+}
+
+/// Format a timestamp as RFC 3339 in UTC
+pub fn format_timestamp(time: SystemTime) -> String {
+    humantime::format_rfc3339_seconds(time).to_string()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_relative_duration() {
+        let since = parse_since("24h").unwrap();
+        let elapsed = SystemTime::now().duration_since(since).unwrap();
+        assert!(elapsed >= Duration::from_secs(24 * 3600));
+        assert!(elapsed < Duration::from_secs(24 * 3600 + 60));
+    }
+
+    #[test]
+    fn test_parse_absolute_timestamp() {
+        let since = parse_since("2024-01-01T00:00:00Z").unwrap();
+        assert_eq!(
+            since,
+            SystemTime::UNIX_EPOCH + Duration::from_secs(1_704_067_200)
+        );
+        assert_eq!(format_timestamp(since), "2024-01-01T00:00:00Z");
+
+        let since = parse_since("1704067200").unwrap();
+        assert_eq!(format_timestamp(since), "2024-01-01T00:00:00Z");
+    }
+
+    #[test]
+    fn test_parse_invalid() {
+        assert!(parse_since("yesterday-ish").is_err());
+    }
+}
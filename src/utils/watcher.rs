@@ -1,5 +1,7 @@
 use crate::indexing::indexing_pipeline::IndexingPipeline;
-use crate::models::Language;
+use crate::storage::changes::{
+    diff_file_symbols, has_change_listeners, publish_file_change, FileChangeKind,
+};
 use crate::storage::store::SymbolStore;
 use notify::{Event, EventKind, RecommendedWatcher, RecursiveMode, Watcher};
 use std::collections::HashMap;
@@ -80,12 +82,11 @@ impl FileWatcher {
         match event.kind {
             EventKind::Create(_) | EventKind::Modify(_) | EventKind::Remove(_) => {
                 for path in event.paths {
-                    // Only process source files
-                    if let Some(extension) = path.extension() {
-                        if Language::from_extension(extension.to_str().unwrap_or("")).is_some() {
-                            let mut pending = debouncer.pending_changes.lock().await;
-                            pending.insert(path, Instant::now());
-                        }
+                    // Source files are told apart when processed, since custom
+                    // frontends can be registered after the watcher starts
+                    if path.extension().is_some() {
+                        let mut pending = debouncer.pending_changes.lock().await;
+                        pending.insert(path, Instant::now());
                     }
                 }
             }
@@ -138,8 +139,24 @@ impl FileWatcher {
                         normalized_path
                     );
                     let mut pipeline_guard = pipeline.lock().await;
+                    if !pipeline_guard.handles(&normalized_path) {
+                        continue;
+                    }
+                    // Subscribers hear which symbols the edit added, removed or changed
+                    let before =
+                        has_change_listeners().then(|| store.get_symbols_by_file(&normalized_path));
                     if let Err(e) = pipeline_guard.index_file(&normalized_path).await {
-                        eprintln!("Error reindexing file {:?}: {}", normalized_path, e);
+                        tracing::error!("Error reindexing file {:?}: {}", normalized_path, e);
+                        continue;
+                    }
+                    if let Some(before) = before {
+                        let after = store.get_symbols_by_file(&normalized_path);
+                        publish_file_change(diff_file_symbols(
+                            normalized_path,
+                            FileChangeKind::Reindexed,
+                            &before,
+                            &after,
+                        ));
                     }
                 } else {
                     // File was deleted - convert to relative path for consistency
@@ -157,8 +174,22 @@ impl FileWatcher {
                         file_path,
                         relative_path
                     );
-                    store.remove_file_symbols(&relative_path);
-                    store.remove_file_references(&relative_path);
+                    let before =
+                        has_change_listeners().then(|| store.get_symbols_by_file(&relative_path));
+                    {
+                        // References from other files wait for the name to reappear
+                        let _update = store.begin_update();
+                        let incoming = store.detach_file(&relative_path);
+                        store.relink_references(incoming);
+                    }
+                    if let Some(before) = before.filter(|before| !before.is_empty()) {
+                        publish_file_change(diff_file_symbols(
+                            relative_path,
+                            FileChangeKind::Removed,
+                            &before,
+                            &[],
+                        ));
+                    }
                 }
             }
         }
@@ -285,6 +285,41 @@ async fn test_ruby_integration() {
     );
 }
 
+#[tokio::test]
+async fn test_lua_integration() {
+    let temp_dir = TempDir::new().unwrap();
+    let lua_file = temp_dir.path().join("complex_example.lua");
+
+    let lua_content = include_str!("../samples/lua/complex_example.lua");
+    fs::write(&lua_file, lua_content).await.unwrap();
+
+    let store = Arc::new(SymbolStore::new());
+    let mut pipeline = IndexingPipeline::new(store.clone()).unwrap();
+
+    let result = pipeline.index_directory(temp_dir.path()).await;
+    assert!(result.files_processed >= 1);
+    assert!(result.symbols_found >= 5);
+
+    assert!(!store.get_symbols("DatabaseConnection").is_empty());
+    assert!(!store.get_symbols("PostgresConnection").is_empty());
+    assert!(!store.get_symbols("UserService").is_empty());
+    assert!(!store.get_symbols("validate_email").is_empty());
+
+    // Functions declared after Lua 5.3+ operators, goto and attributes
+    // are still extracted
+    assert!(!store.get_symbols("page_count").is_empty());
+    assert!(!store.get_symbols("first_active").is_empty());
+    assert!(!store.get_symbols("with_connection").is_empty());
+
+    let module = store.get_symbols("M");
+    assert_eq!(module[0].symbol_type, SymbolType::Module);
+
+    println!(
+        "✅ Lua integration test passed - {} symbols found",
+        result.symbols_found
+    );
+}
+
 #[tokio::test]
 async fn test_all_supported_languages() {
     let temp_dir = TempDir::new().unwrap();
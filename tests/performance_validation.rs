@@ -1,5 +1,6 @@
 use roberto_mcp::models::{Language, Location, Symbol, SymbolId, SymbolType, Visibility};
 use roberto_mcp::{SymbolIndexer, SymbolStore};
+use std::collections::BTreeMap;
 use std::path::PathBuf;
 use std::sync::Arc;
 use std::time::Instant;
@@ -17,6 +18,10 @@ fn create_test_symbol(name: &str, file: &str, line: u32) -> Symbol {
         namespace: None,
         visibility: Visibility::Public,
         source: None,
+        signature: None,
+        tags: BTreeMap::new(),
+        doc: None,
+        alias_of: None,
     }
 }
 